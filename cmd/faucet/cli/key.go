@@ -36,6 +36,7 @@ func newKeyCreateCmd() *cobra.Command {
 	var (
 		role  string
 		label string
+		meta  map[string]string
 	)
 
 	cmd := &cobra.Command{
@@ -43,20 +44,22 @@ func newKeyCreateCmd() *cobra.Command {
 		Short: "Create a new API key",
 		Long:  "Generate a new API key bound to a role. The raw key is shown once and cannot be retrieved again.",
 		Example: `  faucet key create --role readonly --label "CI pipeline"
-  faucet key create --role admin`,
+  faucet key create --role admin
+  faucet key create --role tenant --meta tenant_id=acme`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runKeyCreate(role, label)
+			return runKeyCreate(role, label, meta)
 		},
 	}
 
 	cmd.Flags().StringVar(&role, "role", "", "Role to bind the key to (required)")
 	cmd.Flags().StringVar(&label, "label", "", "Human-readable label for the key")
+	cmd.Flags().StringToStringVar(&meta, "meta", nil, "Key metadata as key=value pairs, available to column rules as key.<name>")
 	cmd.MarkFlagRequired("role")
 
 	return cmd
 }
 
-func runKeyCreate(roleName, label string) error {
+func runKeyCreate(roleName, label string, meta map[string]string) error {
 	store, err := openConfigStore()
 	if err != nil {
 		return fmt.Errorf("open config store: %w", err)
@@ -100,6 +103,7 @@ func runKeyCreate(roleName, label string) error {
 		KeyPrefix: keyPrefix,
 		Label:    label,
 		RoleID:   matchedRole.ID,
+		Metadata: meta,
 		IsActive: true,
 	}
 
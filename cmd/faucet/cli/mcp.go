@@ -9,9 +9,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/faucetdb/faucet/internal/changes"
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
 	fmcp "github.com/faucetdb/faucet/internal/mcp"
+	"github.com/faucetdb/faucet/internal/webhook"
 )
 
 func newMCPCmd() *cobra.Command {
@@ -85,6 +87,15 @@ func runMCP(transport string, port int) error {
 
 	// Create MCP server
 	mcpSrv := fmcp.NewMCPServer(registry, store, logger)
+	hub := changes.NewHub(registry, changes.Config{Logger: logger})
+	defer hub.Close()
+	mcpSrv.SetChangeHub(hub)
+	if dispatcher, err := webhook.NewDispatcher(registry, store, webhook.Config{Logger: logger}); err != nil {
+		logger.Warn("webhook deliveries disabled", "error", err)
+	} else {
+		defer dispatcher.Close()
+		mcpSrv.SetWebhooks(dispatcher)
+	}
 
 	switch transport {
 	case "stdio":
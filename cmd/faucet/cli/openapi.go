@@ -100,11 +100,17 @@ func runOpenAPI(serviceName string, all bool, outputFile, baseURL string) error
 				return fmt.Errorf("introspect schema for %q: %w", svc.Name, err)
 			}
 
+			queries, err := store.ListNamedQueries(ctx, svc.Name)
+			if err != nil {
+				return fmt.Errorf("list saved queries for %q: %w", svc.Name, err)
+			}
+
 			serviceSpecs = append(serviceSpecs, openapi.ServiceSpec{
-				Name:   svc.Name,
-				Label:  svc.Label,
-				Driver: svc.Driver,
-				Schema: schema,
+				Name:    svc.Name,
+				Label:   svc.Label,
+				Driver:  svc.Driver,
+				Schema:  schema,
+				Queries: queries,
 			})
 		}
 
@@ -143,7 +149,13 @@ func runOpenAPI(serviceName string, all bool, outputFile, baseURL string) error
 			return fmt.Errorf("introspect schema: %w", err)
 		}
 
+		queries, err := store.ListNamedQueries(ctx, svc.Name)
+		if err != nil {
+			return fmt.Errorf("list saved queries: %w", err)
+		}
+
 		doc := openapi.GenerateServiceSpec(svc.Name, svc.Label, svc.Driver, baseURL, schema)
+		openapi.AddNamedQueryPaths(doc, svc.Name, queries)
 		specJSON, err = json.MarshalIndent(doc, "", "  ")
 		if err != nil {
 			return fmt.Errorf("marshal openapi spec: %w", err)
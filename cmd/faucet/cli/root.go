@@ -45,6 +45,7 @@ and a built-in MCP server for AI agents.`,
 	cmd.AddCommand(newRoleCmd())
 	cmd.AddCommand(newAdminCmd())
 	cmd.AddCommand(newOpenAPICmd())
+	cmd.AddCommand(newExportCmd())
 	cmd.AddCommand(newMCPCmd())
 	cmd.AddCommand(newBenchmarkCmd())
 	cmd.AddCommand(newConfigCmd())
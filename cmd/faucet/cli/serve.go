@@ -18,6 +18,8 @@ import (
 
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/export"
+	"github.com/faucetdb/faucet/internal/handler"
 	"github.com/faucetdb/faucet/internal/model"
 	"github.com/faucetdb/faucet/internal/server"
 	"github.com/faucetdb/faucet/internal/service"
@@ -65,9 +67,11 @@ etc.). Use --foreground for Docker, systemd, or other process managers.`,
 	cmd.Flags().BoolVar(&noUI, "no-ui", false, "Disable the admin UI")
 	cmd.Flags().BoolVar(&dev, "dev", false, "Enable development mode (verbose logging, CORS *)")
 	cmd.Flags().BoolVar(&foreground, "foreground", false, "Run in foreground (for Docker, systemd, etc.)")
+	cmd.Flags().Bool("dreamfactory", false, "Serve the DreamFactory-compatible API at /api/v2")
 
 	viper.BindPFlag("server.port", cmd.Flags().Lookup("port"))
 	viper.BindPFlag("server.host", cmd.Flags().Lookup("host"))
+	viper.BindPFlag("server.dreamfactory", cmd.Flags().Lookup("dreamfactory"))
 
 	return cmd
 }
@@ -98,6 +102,9 @@ func runServeDaemon(host string, port int, noUI, dev bool) error {
 	if noUI {
 		args = append(args, "--no-ui")
 	}
+	if viper.GetBool("server.dreamfactory") {
+		args = append(args, "--dreamfactory")
+	}
 	if dataDir != "" {
 		args = append(args, "--data-dir", dataDir)
 	}
@@ -347,6 +354,11 @@ func runServe(host string, port int, noUI, dev bool) error {
 	}
 	defer removePID()
 
+	exportCfg, err := exportConfig(cmd_ctx())
+	if err != nil {
+		return fmt.Errorf("configure exports: %w", err)
+	}
+
 	// 7. Build and start HTTP server
 	srvCfg := server.Config{
 		Host:            host,
@@ -355,6 +367,8 @@ func runServe(host string, port int, noUI, dev bool) error {
 		CORSOrigins:     []string{"*"},
 		EnableUI:        !noUI,
 		MaxBodySize:     10 * 1024 * 1024,
+		DreamFactory:    viper.GetBool("server.dreamfactory"),
+		Exports:         exportCfg,
 	}
 
 	srv := server.New(srvCfg, registry, store, authSvc, logger)
@@ -366,6 +380,9 @@ func runServe(host string, port int, noUI, dev bool) error {
 	}
 	fmt.Printf("→ OpenAPI:    http://%s:%d/openapi.json\n", host, port)
 	fmt.Printf("→ MCP:        http://%s:%d/mcp\n", host, port)
+	if srvCfg.DreamFactory {
+		fmt.Printf("→ DreamFactory API: http://%s:%d/api/v2\n", host, port)
+	}
 	fmt.Printf("→ Connected databases: %d\n", len(registry.ListServices()))
 	if !hasAdmin {
 		fmt.Println()
@@ -379,6 +396,44 @@ func runServe(host string, port int, noUI, dev bool) error {
 	return srv.ListenAndServe()
 }
 
+// exportConfig reads the settings of background export jobs from the
+// "export" section of the config file. Finished files are kept in
+// export.dir (default: <data dir>/exports), or in S3 when export.s3.bucket
+// is set.
+func exportConfig(ctx context.Context) (handler.ExportConfig, error) {
+	cfg := handler.ExportConfig{
+		Workers:   viper.GetInt("export.workers"),
+		Retention: viper.GetDuration("export.retention"),
+	}
+
+	if bucket := viper.GetString("export.s3.bucket"); bucket != "" {
+		target, err := export.NewS3Target(ctx, export.S3Config{
+			Bucket:    bucket,
+			Prefix:    viper.GetString("export.s3.prefix"),
+			Region:    viper.GetString("export.s3.region"),
+			Endpoint:  viper.GetString("export.s3.endpoint"),
+			AccessKey: viper.GetString("export.s3.access_key"),
+			SecretKey: viper.GetString("export.s3.secret_key"),
+		})
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Target = target
+		return cfg, nil
+	}
+
+	dir := viper.GetString("export.dir")
+	if dir == "" {
+		dir = filepath.Join(resolveDataDir(), "exports")
+	}
+	target, err := export.NewDirTarget(dir)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Target = target
+	return cfg, nil
+}
+
 // cmd_ctx returns a background context for CLI initialization.
 func cmd_ctx() context.Context {
 	return context.Background()
@@ -0,0 +1,471 @@
+// Package changes streams captured row changes to subscribers. A Hub keeps
+// one watcher per service that notices new changes of the subscribed
+// tables, by LISTEN/NOTIFY where the connector supports it and by polling
+// otherwise, and wakes the streams reading them. Streams read the change
+// log themselves, so each resumes from its own offset.
+package changes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+// Defaults for Config.
+const (
+	DefaultPollInterval = 500 * time.Millisecond
+	DefaultHeartbeat    = 15 * time.Second
+	DefaultRetention    = 24 * time.Hour
+	DefaultBatchSize    = 500
+)
+
+// listenFallback is how often a watcher that is woken by notifications
+// still polls, to catch changes made while its listener reconnected.
+const listenFallback = 10 * time.Second
+
+// pruneInterval is how often a watcher prunes the change log.
+const pruneInterval = time.Hour
+
+// ErrUnsupported is returned when a service's connector cannot capture
+// changes.
+var ErrUnsupported = errors.New("change streams are not supported for this database")
+
+// ErrNotTable is returned when changes are requested for a view.
+var ErrNotTable = errors.New("change streams are only available for tables")
+
+// Config tunes a Hub. Zero values select the defaults.
+type Config struct {
+	PollInterval time.Duration // how often watchers poll for new changes
+	Heartbeat    time.Duration // idle time after which streams get a heartbeat
+	Retention    time.Duration // how long captured changes are kept
+	BatchSize    int           // changes read per query
+	Logger       *slog.Logger
+}
+
+// Hub fans captured changes out to streams. NewHub returns a running hub;
+// Close ends every stream.
+type Hub struct {
+	registry *connector.Registry
+	cfg      Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	watchers map[string]*watcher // by service
+	wg       sync.WaitGroup
+}
+
+// NewHub returns a hub reading changes from the registry's connectors.
+func NewHub(registry *connector.Registry, cfg Config) *Hub {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = DefaultHeartbeat
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Hub{
+		registry: registry,
+		cfg:      cfg,
+		ctx:      ctx,
+		cancel:   cancel,
+		watchers: make(map[string]*watcher),
+	}
+}
+
+// Close ends every stream and stops the watchers.
+func (h *Hub) Close() {
+	h.cancel()
+	h.wg.Wait()
+}
+
+// Subscription is a table's change feed opened by Subscribe. Call Close
+// when done with it.
+type Subscription struct {
+	hub     *Hub
+	w       *watcher
+	feed    *feed
+	service string
+	table   string
+	once    sync.Once
+}
+
+// Subscribe starts capturing changes of a table, if that is not already
+// happening, and returns its feed.
+func (h *Hub) Subscribe(ctx context.Context, service, table string) (*Subscription, error) {
+	conn, err := h.registry.Get(service)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conn.(connector.ChangeSource); !ok {
+		return nil, ErrUnsupported
+	}
+
+	h.mu.Lock()
+	if h.ctx.Err() != nil {
+		h.mu.Unlock()
+		return nil, h.ctx.Err()
+	}
+	w := h.watchers[service]
+	if w == nil {
+		w = newWatcher(h, service)
+		h.watchers[service] = w
+		h.wg.Add(1)
+		go w.run()
+	}
+	f := w.feeds[table]
+	if f == nil {
+		f = &feed{wake: make(chan struct{})}
+		w.feeds[table] = f
+	}
+	f.refs++
+	h.mu.Unlock()
+
+	sub := &Subscription{hub: h, w: w, feed: f, service: service, table: table}
+	if err := f.enable(ctx, h, service, table); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close releases the subscription. The table's capture stays enabled, so
+// that a client can resume from its last offset.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		h := s.hub
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		s.feed.refs--
+		if s.feed.refs == 0 && s.w.feeds[s.table] == s.feed {
+			delete(s.w.feeds, s.table)
+		}
+		if len(s.w.feeds) == 0 && h.watchers[s.service] == s.w {
+			delete(h.watchers, s.service)
+			s.w.cancel()
+		}
+	})
+}
+
+// Last returns the offset of the table's latest change, or "" when there is
+// none; streams that start there see only new changes.
+func (s *Subscription) Last(ctx context.Context) (string, error) {
+	src, err := s.hub.source(s.service)
+	if err != nil {
+		return "", err
+	}
+	return src.LastChangeOffset(ctx, s.table)
+}
+
+// Read returns the next batch of changes after the given offset, or none.
+func (s *Subscription) Read(ctx context.Context, after string) ([]connector.Change, error) {
+	src, err := s.hub.source(s.service)
+	if err != nil {
+		return nil, err
+	}
+	return src.ReadChanges(ctx, s.table, after, s.hub.cfg.BatchSize)
+}
+
+// Stream calls fn with every batch of changes after the given offset until
+// ctx is done, fn fails or the hub closes. After a heartbeat interval
+// without changes fn is called with an empty batch, so that callers can
+// keep idle connections alive. As with Read, the empty offset starts at
+// the oldest retained change; start at Last for new changes only.
+func (s *Subscription) Stream(ctx context.Context, after string, fn func([]connector.Change) error) error {
+	heartbeat := time.NewTimer(s.hub.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		// Take the wake channel before reading, so that a change logged
+		// after the read still wakes us.
+		wake := s.feed.waitChan(s.hub)
+		batch, err := s.Read(ctx, after)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			after = batch[len(batch)-1].Offset
+			heartbeat.Reset(s.hub.cfg.Heartbeat)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.hub.ctx.Done():
+			return s.hub.ctx.Err()
+		case <-s.feed.closedChan(s.hub):
+			return ErrClosed
+		case <-wake:
+		case <-heartbeat.C:
+			if err := fn(nil); err != nil {
+				return err
+			}
+			heartbeat.Reset(s.hub.cfg.Heartbeat)
+		}
+	}
+}
+
+// ErrClosed ends the streams of a table whose capture was disabled.
+var ErrClosed = errors.New("change capture was disabled")
+
+// Disable stops capturing changes of a table and ends its streams.
+func (h *Hub) Disable(ctx context.Context, service, table string) error {
+	src, err := h.source(service)
+	if err != nil {
+		return err
+	}
+	var f *feed
+	h.mu.Lock()
+	if w := h.watchers[service]; w != nil {
+		if f = w.feeds[table]; f != nil {
+			f.disabled = true
+			close(f.closed())
+			delete(w.feeds, table)
+		}
+	}
+	h.mu.Unlock()
+
+	// Wait out an enable in progress, so that it cannot reinstall the
+	// capture after it is dropped.
+	if f != nil {
+		f.enableMu.Lock()
+		defer f.enableMu.Unlock()
+	}
+	return src.DisableChanges(ctx, table)
+}
+
+func (h *Hub) source(service string) (connector.ChangeSource, error) {
+	conn, err := h.registry.Get(service)
+	if err != nil {
+		return nil, err
+	}
+	src, ok := conn.(connector.ChangeSource)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return src, nil
+}
+
+// feed is the shared state of one table's subscribers. Fields are guarded
+// by Hub.mu, except the capture state below enableMu.
+type feed struct {
+	refs     int
+	last     string        // latest offset the watcher has seen
+	wake     chan struct{} // closed and replaced when the table changes
+	done     chan struct{} // closed when capture is disabled
+	disabled bool
+
+	enableMu sync.Mutex
+	version  uint64 // schema version the capture was enabled for
+	enabled  bool
+}
+
+// enable installs or refreshes the table's capture when it has not been
+// enabled for the service's current schema.
+func (f *feed) enable(ctx context.Context, h *Hub, service, table string) error {
+	f.enableMu.Lock()
+	defer f.enableMu.Unlock()
+	h.mu.Lock()
+	disabled := f.disabled
+	h.mu.Unlock()
+	if disabled {
+		return ErrClosed
+	}
+	version := h.registry.SchemaVersion(service)
+	if f.enabled && f.version == version {
+		return nil
+	}
+	src, err := h.source(service)
+	if err != nil {
+		return err
+	}
+	def, err := h.registry.TableSchema(ctx, service, table)
+	if err != nil {
+		return err
+	}
+	if def.Type == "view" {
+		return ErrNotTable
+	}
+	if err := src.EnableChanges(ctx, *def); err != nil {
+		return err
+	}
+	f.enabled, f.version = true, version
+	return nil
+}
+
+func (f *feed) waitChan(h *Hub) <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return f.wake
+}
+
+func (f *feed) closedChan(h *Hub) <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return f.closed()
+}
+
+// closed returns the channel closed when capture is disabled. The caller
+// holds Hub.mu.
+func (f *feed) closed() chan struct{} {
+	if f.done == nil {
+		f.done = make(chan struct{})
+	}
+	return f.done
+}
+
+// signal wakes the feed's streams. The caller holds Hub.mu.
+func (f *feed) signal() {
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+// watcher notices new changes of a service's subscribed tables.
+type watcher struct {
+	hub     *Hub
+	service string
+	feeds   map[string]*feed // by table; guarded by Hub.mu
+	ctx     context.Context
+	cancel  context.CancelFunc
+	notify  chan string // tables named by the change listener
+}
+
+func newWatcher(h *Hub, service string) *watcher {
+	ctx, cancel := context.WithCancel(h.ctx)
+	return &watcher{
+		hub:     h,
+		service: service,
+		feeds:   make(map[string]*feed),
+		ctx:     ctx,
+		cancel:  cancel,
+		notify:  make(chan string, 64),
+	}
+}
+
+func (w *watcher) run() {
+	defer w.hub.wg.Done()
+	logger := w.hub.cfg.Logger.With("service", w.service)
+
+	interval := w.hub.cfg.PollInterval
+	if conn, err := w.hub.registry.Get(w.service); err == nil {
+		if l, ok := conn.(connector.ChangeListener); ok {
+			interval = listenFallback
+			w.hub.wg.Add(1)
+			go w.listen(l, logger)
+		}
+	}
+
+	// The first poll completes the captures being enabled, so the log
+	// exists by the time it is pruned.
+	w.poll(logger)
+	w.prune(logger)
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case table := <-w.notify:
+			w.hub.mu.Lock()
+			if f := w.feeds[table]; f != nil {
+				f.signal()
+			}
+			w.hub.mu.Unlock()
+		case <-poll.C:
+			w.poll(logger)
+		case <-prune.C:
+			w.prune(logger)
+		}
+	}
+}
+
+// poll checks each subscribed table for changes past the last seen offset,
+// and refreshes captures after schema changes.
+func (w *watcher) poll(logger *slog.Logger) {
+	src, err := w.hub.source(w.service)
+	if err != nil {
+		logger.Warn("change watcher: connector unavailable", "error", err)
+		return
+	}
+	w.hub.mu.Lock()
+	tables := make(map[string]*feed, len(w.feeds))
+	for table, f := range w.feeds {
+		tables[table] = f
+	}
+	w.hub.mu.Unlock()
+
+	for table, f := range tables {
+		if err := f.enable(w.ctx, w.hub, w.service, table); errors.Is(err, ErrClosed) {
+			continue
+		} else if err != nil && w.ctx.Err() == nil {
+			logger.Warn("change watcher: refresh capture failed", "table", table, "error", err)
+		}
+		last, err := src.LastChangeOffset(w.ctx, table)
+		if err != nil {
+			if w.ctx.Err() == nil {
+				logger.Warn("change watcher: poll failed", "table", table, "error", err)
+			}
+			continue
+		}
+		w.hub.mu.Lock()
+		if last != f.last {
+			f.last = last
+			f.signal()
+		}
+		w.hub.mu.Unlock()
+	}
+}
+
+// listen relays change notifications to the watcher, reconnecting after
+// failures until the watcher stops.
+func (w *watcher) listen(l connector.ChangeListener, logger *slog.Logger) {
+	defer w.hub.wg.Done()
+	for {
+		err := l.ListenChanges(w.ctx, func(table string) {
+			select {
+			case w.notify <- table:
+			default:
+				// The watcher is behind; its next poll catches up.
+			}
+		})
+		if w.ctx.Err() != nil {
+			return
+		}
+		logger.Warn("change listener stopped; reconnecting", "error", err)
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (w *watcher) prune(logger *slog.Logger) {
+	src, err := w.hub.source(w.service)
+	if err != nil {
+		return
+	}
+	if err := src.PruneChanges(w.ctx, time.Now().Add(-w.hub.cfg.Retention)); err != nil && w.ctx.Err() == nil {
+		logger.Warn("change watcher: prune failed", "error", fmt.Errorf("prune changes: %w", err))
+	}
+}
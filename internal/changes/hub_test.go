@@ -0,0 +1,138 @@
+package changes
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+)
+
+func newTestHub(t *testing.T) (*Hub, connector.Connector) {
+	t.Helper()
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{
+		Driver: "sqlite",
+		DSN:    filepath.Join(t.TempDir(), "hub.db"),
+	}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	t.Cleanup(func() { registry.Disconnect("testdb") })
+
+	conn, _ := registry.Get("testdb")
+	for _, stmt := range []string{
+		`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE VIEW item_names AS SELECT name FROM items`,
+	} {
+		if _, err := conn.DB().Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	hub := NewHub(registry, Config{PollInterval: 10 * time.Millisecond, Heartbeat: 50 * time.Millisecond})
+	t.Cleanup(hub.Close)
+	return hub, conn
+}
+
+func TestHubStream(t *testing.T) {
+	hub, conn := newTestHub(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Changes made before the first subscription are not captured.
+	if _, err := conn.DB().Exec(`INSERT INTO items (id, name) VALUES (1, 'before')`); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := hub.Subscribe(ctx, "testdb", "items")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	got := make(chan connector.Change, 10)
+	heartbeats := make(chan struct{}, 10)
+	done := make(chan error, 1)
+	start, err := sub.Last(ctx)
+	if err != nil {
+		t.Fatalf("Last: %v", err)
+	}
+	go func() {
+		done <- sub.Stream(ctx, start, func(batch []connector.Change) error {
+			if batch == nil {
+				select {
+				case heartbeats <- struct{}{}:
+				default:
+				}
+			}
+			for _, c := range batch {
+				got <- c
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-heartbeats:
+	case <-ctx.Done():
+		t.Fatal("no heartbeat on an idle stream")
+	}
+	for _, stmt := range []string{
+		`INSERT INTO items (id, name) VALUES (2, 'pen')`,
+		`UPDATE items SET name = 'ink pen' WHERE id = 2`,
+	} {
+		if _, err := conn.DB().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var first connector.Change
+	for i, want := range []string{connector.ChangeInsert, connector.ChangeUpdate} {
+		select {
+		case c := <-got:
+			if c.Op != want || c.Row["id"] != int64(2) {
+				t.Errorf("change %d = %+v, want %s of id 2", i, c, want)
+			}
+			if i == 0 {
+				first = c
+			}
+		case <-ctx.Done():
+			t.Fatalf("change %d not delivered", i)
+		}
+	}
+
+	// A second reader resumes after the insert.
+	batch, err := sub.Read(ctx, first.Offset)
+	if err != nil || len(batch) != 1 || batch[0].Op != connector.ChangeUpdate {
+		t.Errorf("Read after %s = %+v, %v", first.Offset, batch, err)
+	}
+
+	if err := hub.Disable(ctx, "testdb", "items"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Stream after Disable: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("stream did not end after Disable")
+	}
+}
+
+func TestHubSubscribeErrors(t *testing.T) {
+	hub, _ := newTestHub(t)
+	ctx := context.Background()
+
+	if _, err := hub.Subscribe(ctx, "testdb", "item_names"); !errors.Is(err, ErrNotTable) {
+		t.Errorf("view: err = %v", err)
+	}
+	if _, err := hub.Subscribe(ctx, "testdb", "missing"); err == nil {
+		t.Error("missing table: expected an error")
+	}
+	if _, err := hub.Subscribe(ctx, "nodb", "items"); err == nil {
+		t.Error("missing service: expected an error")
+	}
+}
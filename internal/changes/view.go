@@ -0,0 +1,73 @@
+package changes
+
+import (
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// Event is one change as sent to a subscriber.
+type Event struct {
+	Op     string                 `json:"op"`
+	Offset string                 `json:"offset"`
+	Table  string                 `json:"table"`
+	Row    map[string]interface{} `json:"row"`
+	Old    map[string]interface{} `json:"old,omitempty"`
+	Time   time.Time              `json:"time"`
+}
+
+// View decides which changes a subscriber sees and how: it encodes rows
+// like every other response and applies the subscriber's filter and
+// row-level security to the row images.
+type View struct {
+	Table   string
+	Encoder *connector.ValueEncoder
+	Match   query.RowFilter // nil when every row is visible
+}
+
+// Event returns the event a change becomes for the subscriber, if any. An
+// update that moves a row into the visible set is an insert, and one that
+// moves it out a delete of the old row.
+func (v *View) Event(c connector.Change) (Event, bool) {
+	ev := Event{Op: c.Op, Offset: c.Offset, Table: v.Table, Row: c.Row, Old: c.Old, Time: c.Time}
+	if v.Encoder != nil {
+		if ev.Row != nil {
+			v.Encoder.EncodeRow(ev.Row)
+		}
+		if ev.Old != nil {
+			v.Encoder.EncodeRow(ev.Old)
+		}
+	}
+	if v.Match == nil {
+		return ev, true
+	}
+
+	now := ev.Row != nil && v.Match(ev.Row)
+	if c.Op != connector.ChangeUpdate || ev.Old == nil {
+		return ev, now
+	}
+	before := v.Match(ev.Old)
+	switch {
+	case now && before:
+		return ev, true
+	case now:
+		ev.Op, ev.Old = connector.ChangeInsert, nil
+		return ev, true
+	case before:
+		ev.Op, ev.Row, ev.Old = connector.ChangeDelete, ev.Old, nil
+		return ev, true
+	}
+	return ev, false
+}
+
+// Events returns the events of a batch of changes the subscriber sees.
+func (v *View) Events(batch []connector.Change) []Event {
+	events := make([]Event, 0, len(batch))
+	for _, c := range batch {
+		if ev, ok := v.Event(c); ok {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
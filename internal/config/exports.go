@@ -0,0 +1,212 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+const exportJobColumns = `id, service_name, table_name, query_name, args_json, filter, fields,
+	order_by, format, gzip, status, rows_written, bytes_written, error, owner, location,
+	created_at, started_at, finished_at, expires_at`
+
+// CreateExportJob stores a new pending export job. The job's status and
+// creation time are set on success.
+func (s *Store) CreateExportJob(ctx context.Context, job *model.ExportJob) error {
+	argsJSON, err := marshalExportArgs(job.Args)
+	if err != nil {
+		return err
+	}
+
+	job.Status = model.ExportPending
+	job.CreatedAt = time.Now().UTC()
+	const q = `INSERT INTO export_jobs
+		(id, service_name, table_name, query_name, args_json, filter, fields, order_by,
+		format, gzip, status, owner, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := s.db.ExecContext(ctx, q,
+		job.ID, job.ServiceName, job.Table, job.Query, argsJSON, job.Filter, job.Fields, job.Order,
+		job.Format, job.Gzip, job.Status, job.Owner, job.CreatedAt); err != nil {
+		return fmt.Errorf("create export job: %w", err)
+	}
+	job.ArgsJSON = argsJSON
+	return nil
+}
+
+// GetExportJob returns a single export job by ID.
+func (s *Store) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	var job model.ExportJob
+	q := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE id = ?`
+	if err := s.db.GetContext(ctx, &job, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get export job: %w", err)
+	}
+	if err := unmarshalExportArgs(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListExportJobs returns the export jobs of a service, newest first. When
+// owner is non-empty only that principal's jobs are returned.
+func (s *Store) ListExportJobs(ctx context.Context, serviceName, owner string) ([]model.ExportJob, error) {
+	q := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE service_name = ?`
+	args := []interface{}{serviceName}
+	if owner != "" {
+		q += ` AND owner = ?`
+		args = append(args, owner)
+	}
+	q += ` ORDER BY created_at DESC, rowid DESC`
+
+	var jobs []model.ExportJob
+	if err := s.db.SelectContext(ctx, &jobs, q, args...); err != nil {
+		return nil, fmt.Errorf("list export jobs: %w", err)
+	}
+	for i := range jobs {
+		if err := unmarshalExportArgs(&jobs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// ClaimExportJob marks the oldest pending export job as running and returns
+// it, or returns nil when no job is pending.
+func (s *Store) ClaimExportJob(ctx context.Context) (*model.ExportJob, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("claim export job: %w", err)
+	}
+	defer tx.Rollback()
+
+	var job model.ExportJob
+	q := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE status = ? ORDER BY created_at, rowid LIMIT 1`
+	if err := tx.GetContext(ctx, &job, q, model.ExportPending); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claim export job: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `UPDATE export_jobs SET status = ?, started_at = ? WHERE id = ?`,
+		model.ExportRunning, now, job.ID); err != nil {
+		return nil, fmt.Errorf("claim export job: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("claim export job: %w", err)
+	}
+	job.Status = model.ExportRunning
+	job.StartedAt = &now
+	if err := unmarshalExportArgs(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateExportProgress records the rows a running export job has written.
+func (s *Store) UpdateExportProgress(ctx context.Context, id string, rows int64) error {
+	const q = `UPDATE export_jobs SET rows_written = ? WHERE id = ? AND status = ?`
+	if _, err := s.db.ExecContext(ctx, q, rows, id, model.ExportRunning); err != nil {
+		return fmt.Errorf("update export progress: %w", err)
+	}
+	return nil
+}
+
+// FinishExportJob stores the outcome of a running export job: its status,
+// counts, error, file location and expiry. It returns false, and stores
+// nothing, when the job is no longer running because it was cancelled.
+func (s *Store) FinishExportJob(ctx context.Context, job *model.ExportJob) (bool, error) {
+	now := time.Now().UTC()
+	const q = `UPDATE export_jobs SET status = ?, rows_written = ?, bytes_written = ?, error = ?,
+		location = ?, finished_at = ?, expires_at = ?
+		WHERE id = ? AND status = ?`
+	result, err := s.db.ExecContext(ctx, q,
+		job.Status, job.Rows, job.Bytes, job.Error, job.Location, now, job.ExpiresAt,
+		job.ID, model.ExportRunning)
+	if err != nil {
+		return false, fmt.Errorf("finish export job: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	job.FinishedAt = &now
+	return true, nil
+}
+
+// CancelExportJob marks a pending or running export job as cancelled, to
+// expire at expiresAt. It returns false when the job has already finished.
+func (s *Store) CancelExportJob(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	now := time.Now().UTC()
+	const q = `UPDATE export_jobs SET status = ?, finished_at = ?, expires_at = ?
+		WHERE id = ? AND status IN (?, ?)`
+	result, err := s.db.ExecContext(ctx, q, model.ExportCancelled, now, expiresAt,
+		id, model.ExportPending, model.ExportRunning)
+	if err != nil {
+		return false, fmt.Errorf("cancel export job: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// FailRunningExportJobs marks every running export job as failed with
+// message, expiring at expiresAt. It is called at startup for jobs whose
+// worker stopped with the previous process.
+func (s *Store) FailRunningExportJobs(ctx context.Context, message string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	const q = `UPDATE export_jobs SET status = ?, error = ?, finished_at = ?, expires_at = ? WHERE status = ?`
+	if _, err := s.db.ExecContext(ctx, q, model.ExportFailed, message, now, expiresAt, model.ExportRunning); err != nil {
+		return fmt.Errorf("fail running export jobs: %w", err)
+	}
+	return nil
+}
+
+// ExpiredExportJobs returns the finished export jobs whose expiry is at or
+// before now.
+func (s *Store) ExpiredExportJobs(ctx context.Context, now time.Time) ([]model.ExportJob, error) {
+	var jobs []model.ExportJob
+	q := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE expires_at IS NOT NULL AND expires_at <= ?`
+	if err := s.db.SelectContext(ctx, &jobs, q, now); err != nil {
+		return nil, fmt.Errorf("list expired export jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// DeleteExportJob removes an export job.
+func (s *Store) DeleteExportJob(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM export_jobs WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete export job: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func marshalExportArgs(args map[string]interface{}) (string, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("marshal export args: %w", err)
+	}
+	return string(b), nil
+}
+
+func unmarshalExportArgs(job *model.ExportJob) error {
+	if job.ArgsJSON == "" || job.ArgsJSON == "{}" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(job.ArgsJSON), &job.Args); err != nil {
+		return fmt.Errorf("unmarshal export args: %w", err)
+	}
+	return nil
+}
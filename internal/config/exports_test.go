@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestExportJobLifecycle(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	first := &model.ExportJob{ID: "a", ServiceName: "mydb", Table: "orders", Format: "csv", Owner: "key:1"}
+	second := &model.ExportJob{ID: "b", ServiceName: "mydb", Query: "top", Args: map[string]interface{}{"region": "EU"},
+		Format: "parquet", Gzip: true, Owner: "key:2"}
+	for _, job := range []*model.ExportJob{first, second} {
+		if err := store.CreateExportJob(ctx, job); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if first.Status != model.ExportPending {
+		t.Errorf("expected pending, got %q", first.Status)
+	}
+
+	got, err := store.GetExportJob(ctx, "b")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Query != "top" || got.Args["region"] != "EU" || !got.Gzip {
+		t.Errorf("unexpected job: %+v", got)
+	}
+	if _, err := store.GetExportJob(ctx, "missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	jobs, err := store.ListExportJobs(ctx, "mydb", "key:1")
+	if err != nil || len(jobs) != 1 || jobs[0].ID != "a" {
+		t.Errorf("owner list: expected job a, got %v (%v)", jobs, err)
+	}
+	jobs, _ = store.ListExportJobs(ctx, "mydb", "")
+	if len(jobs) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	// Jobs are claimed oldest first, once.
+	claimed, err := store.ClaimExportJob(ctx)
+	if err != nil || claimed == nil || claimed.ID != "a" || claimed.Status != model.ExportRunning || claimed.StartedAt == nil {
+		t.Fatalf("claim: expected running job a, got %+v (%v)", claimed, err)
+	}
+	if err := store.UpdateExportProgress(ctx, "a", 10); err != nil {
+		t.Fatalf("progress: %v", err)
+	}
+	got, _ = store.GetExportJob(ctx, "a")
+	if got.Rows != 10 {
+		t.Errorf("expected 10 rows, got %d", got.Rows)
+	}
+
+	// A cancelled job is neither claimed nor finished.
+	if ok, err := store.CancelExportJob(ctx, "b", time.Now().Add(time.Hour)); err != nil || !ok {
+		t.Fatalf("cancel: %v %v", ok, err)
+	}
+	if job, err := store.ClaimExportJob(ctx); err != nil || job != nil {
+		t.Errorf("expected nothing to claim, got %+v (%v)", job, err)
+	}
+
+	expired := time.Now().UTC().Add(-time.Minute)
+	claimed.Status, claimed.Rows, claimed.Bytes, claimed.Location, claimed.ExpiresAt = model.ExportCompleted, 12, 345, "a.csv", &expired
+	if ok, err := store.FinishExportJob(ctx, claimed); err != nil || !ok {
+		t.Fatalf("finish: %v %v", ok, err)
+	}
+	if ok, _ := store.FinishExportJob(ctx, claimed); ok {
+		t.Error("expected a finished job not to be finished again")
+	}
+	got, _ = store.GetExportJob(ctx, "a")
+	if got.Status != model.ExportCompleted || got.Rows != 12 || got.Bytes != 345 || got.Location != "a.csv" || got.FinishedAt == nil {
+		t.Errorf("unexpected finished job: %+v", got)
+	}
+
+	jobs, err = store.ExpiredExportJobs(ctx, time.Now().UTC())
+	if err != nil || len(jobs) != 1 || jobs[0].ID != "a" {
+		t.Fatalf("expired: expected job a, got %v (%v)", jobs, err)
+	}
+	if err := store.DeleteExportJob(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.DeleteExportJob(ctx, "a"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFailRunningExportJobs(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	store.CreateExportJob(ctx, &model.ExportJob{ID: "a", ServiceName: "mydb", Table: "t", Format: "csv", Owner: "admin:1"})
+	store.CreateExportJob(ctx, &model.ExportJob{ID: "b", ServiceName: "mydb", Table: "t", Format: "csv", Owner: "admin:1"})
+	store.ClaimExportJob(ctx)
+
+	if err := store.FailRunningExportJobs(ctx, "interrupted", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("fail running: %v", err)
+	}
+	a, _ := store.GetExportJob(ctx, "a")
+	b, _ := store.GetExportJob(ctx, "b")
+	if a.Status != model.ExportFailed || a.Error != "interrupted" || a.ExpiresAt == nil {
+		t.Errorf("expected the running job to fail, got %+v", a)
+	}
+	if b.Status != model.ExportPending {
+		t.Errorf("expected the pending job to stay pending, got %q", b.Status)
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+const idempotencyColumns = `scope, idem_key, fingerprint, token, status, header_json, body, created_at, expires_at`
+
+// ReserveIdempotencyKey claims rec's scope and key for a request about to run,
+// storing it with status 0. When the key is already held by an unexpired
+// record, nothing is stored and that record is returned instead; otherwise
+// the result is nil. Expired records are purged first.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	now := time.Now().UTC()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now); err != nil {
+		return nil, fmt.Errorf("purge idempotency keys: %w", err)
+	}
+
+	var existing model.IdempotencyRecord
+	q := `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE scope = ? AND idem_key = ?`
+	err = tx.GetContext(ctx, &existing, q, rec.Scope, rec.Key)
+	if err == nil {
+		return &existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+
+	rec.Status = 0
+	rec.CreatedAt = now
+	const ins = `INSERT INTO idempotency_keys (scope, idem_key, fingerprint, token, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)`
+	if _, err := tx.ExecContext(ctx, ins, rec.Scope, rec.Key, rec.Fingerprint, rec.Token, rec.CreatedAt, rec.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response of a reserved request. The
+// record's expiry replaces the reservation's, which is only a short lease.
+// It returns ErrNotFound when the reservation with rec's token is gone,
+// e.g. because its lease expired and another request reserved the key.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error {
+	const q = `UPDATE idempotency_keys SET status = ?, header_json = ?, body = ?, expires_at = ?
+		WHERE scope = ? AND idem_key = ? AND token = ? AND status = 0`
+	result, err := s.db.ExecContext(ctx, q, rec.Status, rec.HeaderJSON, rec.Body, rec.ExpiresAt, rec.Scope, rec.Key, rec.Token)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes the reservation holding token so the key can
+// be retried. A reservation or response stored by another request is kept.
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, scope, key, token string) error {
+	const q = `DELETE FROM idempotency_keys WHERE scope = ? AND idem_key = ? AND token = ? AND status = 0`
+	if _, err := s.db.ExecContext(ctx, q, scope, key, token); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestIdempotencyKeyExpiredLease(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// The first request's lease expires while it runs, and a retry takes
+	// the key over.
+	first := &model.IdempotencyRecord{Scope: "key:1", Key: "k1", Fingerprint: "f", Token: "a",
+		ExpiresAt: time.Now().UTC().Add(-time.Second)}
+	if existing, err := store.ReserveIdempotencyKey(ctx, first); err != nil || existing != nil {
+		t.Fatalf("reserve first: %v %v", existing, err)
+	}
+	second := &model.IdempotencyRecord{Scope: "key:1", Key: "k1", Fingerprint: "f", Token: "b",
+		ExpiresAt: time.Now().UTC().Add(time.Minute)}
+	if existing, err := store.ReserveIdempotencyKey(ctx, second); err != nil || existing != nil {
+		t.Fatalf("reserve second: %v %v", existing, err)
+	}
+
+	// The first request can neither complete nor release the retry's
+	// reservation.
+	first.Status = 201
+	if err := store.CompleteIdempotencyKey(ctx, first); err != ErrNotFound {
+		t.Errorf("complete with a lost reservation: expected ErrNotFound, got %v", err)
+	}
+	if err := store.ReleaseIdempotencyKey(ctx, "key:1", "k1", "a"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	existing, err := store.ReserveIdempotencyKey(ctx, &model.IdempotencyRecord{Scope: "key:1", Key: "k1", Token: "c",
+		ExpiresAt: time.Now().UTC().Add(time.Minute)})
+	if err != nil || existing == nil || existing.Token != "b" || existing.Status != 0 {
+		t.Fatalf("expected the retry's reservation to remain, got %+v (%v)", existing, err)
+	}
+
+	second.Status = 201
+	second.Body = []byte(`{}`)
+	if err := store.CompleteIdempotencyKey(ctx, second); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	// A stored response is not released.
+	if err := store.ReleaseIdempotencyKey(ctx, "key:1", "k1", "b"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, &model.IdempotencyRecord{Scope: "key:1", Key: "k1", Token: "d",
+		ExpiresAt: time.Now().UTC().Add(time.Minute)})
+	if err != nil || existing == nil || existing.Status != 201 {
+		t.Errorf("expected the stored response, got %+v (%v)", existing, err)
+	}
+}
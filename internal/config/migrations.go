@@ -94,6 +94,113 @@ func (s *Store) migrate() error {
 
 		// v5: Schema lock mode per service (none, auto, strict).
 		`ALTER TABLE services ADD COLUMN schema_lock TEXT NOT NULL DEFAULT 'none'`,
+
+		// v6: Admin-defined parameterized queries exposed at {service}/_query/{name}.
+		`CREATE TABLE IF NOT EXISTS named_queries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			service_name TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			sql_text TEXT NOT NULL,
+			params_json TEXT NOT NULL DEFAULT '[]',
+			is_write INTEGER NOT NULL DEFAULT 0,
+			cache_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(service_name, name)
+		)`,
+
+		// v7: Per-service version column for record ETags.
+		`ALTER TABLE services ADD COLUMN version_column TEXT NOT NULL DEFAULT ''`,
+
+		// v8: Stored responses of requests sent with an Idempotency-Key header.
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			scope TEXT NOT NULL,
+			idem_key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			header_json TEXT NOT NULL DEFAULT '{}',
+			body BLOB,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			PRIMARY KEY (scope, idem_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at)`,
+
+		// v9: Server-populated column rules per service and API key metadata
+		// they can draw values from.
+		`ALTER TABLE services ADD COLUMN column_rules_json TEXT NOT NULL DEFAULT '[]'`,
+		`ALTER TABLE api_keys ADD COLUMN metadata_json TEXT NOT NULL DEFAULT '{}'`,
+
+		// v10: How decimal columns are encoded in responses.
+		`ALTER TABLE services ADD COLUMN numeric_format TEXT NOT NULL DEFAULT ''`,
+
+		// v11: Per-role cap on the rows a table read may return.
+		`ALTER TABLE roles ADD COLUMN max_limit INTEGER NOT NULL DEFAULT 0`,
+
+		// v12: Background export jobs created at {service}/_export.
+		`CREATE TABLE IF NOT EXISTS export_jobs (
+			id TEXT PRIMARY KEY,
+			service_name TEXT NOT NULL,
+			table_name TEXT NOT NULL DEFAULT '',
+			query_name TEXT NOT NULL DEFAULT '',
+			args_json TEXT NOT NULL DEFAULT '{}',
+			filter TEXT NOT NULL DEFAULT '',
+			fields TEXT NOT NULL DEFAULT '',
+			order_by TEXT NOT NULL DEFAULT '',
+			format TEXT NOT NULL,
+			gzip INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'pending',
+			rows_written INTEGER NOT NULL DEFAULT 0,
+			bytes_written INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			owner TEXT NOT NULL,
+			location TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			started_at DATETIME,
+			finished_at DATETIME,
+			expires_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_export_jobs_status ON export_jobs(status)`,
+
+		// v13: Opt-in change streams at {service}/_table/{table}/_changes.
+		`ALTER TABLE services ADD COLUMN change_streams INTEGER NOT NULL DEFAULT 0`,
+
+		// v14: Outbound webhooks and their durable delivery queue.
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			service_name TEXT NOT NULL,
+			table_name TEXT NOT NULL DEFAULT '*',
+			verb_mask INTEGER NOT NULL DEFAULT 0,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			filter TEXT NOT NULL DEFAULT '',
+			is_active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_attempt_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id)`,
+
+		// v15: Opt-in PostgREST-compatible routes at {service}/rest/v1.
+		`ALTER TABLE services ADD COLUMN postgrest INTEGER NOT NULL DEFAULT 0`,
+
+		// v16: Reservation tokens, so that a request whose lease expired
+		// cannot complete or release a key reserved again since.
+		`ALTER TABLE idempotency_keys ADD COLUMN token TEXT NOT NULL DEFAULT ''`,
 	}
 
 	for _, m := range migrations {
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+const namedQueryColumns = `id, service_name, name, description, sql_text, params_json,
+	is_write, cache_ttl_seconds, created_at, updated_at`
+
+// CreateNamedQuery inserts a new named query. The query's ID and timestamps
+// are set on success.
+func (s *Store) CreateNamedQuery(ctx context.Context, nq *model.NamedQuery) error {
+	paramsJSON, err := marshalQueryParams(nq.Params)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	const q = `INSERT INTO named_queries
+		(service_name, name, description, sql_text, params_json, is_write, cache_ttl_seconds, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := s.db.ExecContext(ctx, q,
+		nq.ServiceName, nq.Name, nq.Description, nq.SQL, paramsJSON,
+		nq.Write, nq.CacheTTL, now, now)
+	if err != nil {
+		return fmt.Errorf("create named query: %w", err)
+	}
+
+	nq.ID, _ = result.LastInsertId()
+	nq.ParamsJSON = paramsJSON
+	nq.CreatedAt = now
+	nq.UpdatedAt = now
+	return nil
+}
+
+// GetNamedQuery returns a single named query by service and query name.
+func (s *Store) GetNamedQuery(ctx context.Context, serviceName, name string) (*model.NamedQuery, error) {
+	var nq model.NamedQuery
+	q := `SELECT ` + namedQueryColumns + ` FROM named_queries WHERE service_name = ? AND name = ?`
+	if err := s.db.GetContext(ctx, &nq, q, serviceName, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get named query: %w", err)
+	}
+	if err := unmarshalQueryParams(&nq); err != nil {
+		return nil, err
+	}
+	return &nq, nil
+}
+
+// ListNamedQueries returns all named queries for a service, ordered by name.
+func (s *Store) ListNamedQueries(ctx context.Context, serviceName string) ([]model.NamedQuery, error) {
+	var rows []model.NamedQuery
+	q := `SELECT ` + namedQueryColumns + ` FROM named_queries WHERE service_name = ? ORDER BY name`
+	if err := s.db.SelectContext(ctx, &rows, q, serviceName); err != nil {
+		return nil, fmt.Errorf("list named queries: %w", err)
+	}
+	for i := range rows {
+		if err := unmarshalQueryParams(&rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// ListAllNamedQueries returns the named queries of every service, ordered by
+// service and query name.
+func (s *Store) ListAllNamedQueries(ctx context.Context) ([]model.NamedQuery, error) {
+	var rows []model.NamedQuery
+	q := `SELECT ` + namedQueryColumns + ` FROM named_queries ORDER BY service_name, name`
+	if err := s.db.SelectContext(ctx, &rows, q); err != nil {
+		return nil, fmt.Errorf("list named queries: %w", err)
+	}
+	for i := range rows {
+		if err := unmarshalQueryParams(&rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// UpdateNamedQuery replaces the definition of an existing named query,
+// identified by its service and name.
+func (s *Store) UpdateNamedQuery(ctx context.Context, nq *model.NamedQuery) error {
+	paramsJSON, err := marshalQueryParams(nq.Params)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	const q = `UPDATE named_queries SET description = ?, sql_text = ?, params_json = ?,
+		is_write = ?, cache_ttl_seconds = ?, updated_at = ?
+		WHERE service_name = ? AND name = ?`
+	result, err := s.db.ExecContext(ctx, q,
+		nq.Description, nq.SQL, paramsJSON, nq.Write, nq.CacheTTL, now,
+		nq.ServiceName, nq.Name)
+	if err != nil {
+		return fmt.Errorf("update named query: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return ErrNotFound
+	}
+	nq.ParamsJSON = paramsJSON
+	nq.UpdatedAt = now
+	return nil
+}
+
+// DeleteNamedQuery removes a named query.
+func (s *Store) DeleteNamedQuery(ctx context.Context, serviceName, name string) error {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM named_queries WHERE service_name = ? AND name = ?",
+		serviceName, name)
+	if err != nil {
+		return fmt.Errorf("delete named query: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func marshalQueryParams(params []model.QueryParam) (string, error) {
+	if params == nil {
+		params = []model.QueryParam{}
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("marshal query params: %w", err)
+	}
+	return string(b), nil
+}
+
+func unmarshalQueryParams(nq *model.NamedQuery) error {
+	nq.Params = []model.QueryParam{}
+	if nq.ParamsJSON == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(nq.ParamsJSON), &nq.Params); err != nil {
+		return fmt.Errorf("unmarshal params for query %s: %w", nq.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestNamedQueryCRUD(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	nq := &model.NamedQuery{
+		ServiceName: "mydb",
+		Name:        "top_customers",
+		Description: "Highest spending customers",
+		SQL:         "SELECT * FROM customers ORDER BY spend DESC LIMIT :limit",
+		Params:      []model.QueryParam{{Name: "limit", Type: "integer", Default: float64(10)}},
+		CacheTTL:    30,
+	}
+	if err := store.CreateNamedQuery(ctx, nq); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if nq.ID == 0 {
+		t.Error("expected ID to be set")
+	}
+
+	// Duplicate names within a service are rejected by the unique index.
+	if err := store.CreateNamedQuery(ctx, &model.NamedQuery{ServiceName: "mydb", Name: "top_customers", SQL: "SELECT 1"}); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+
+	got, err := store.GetNamedQuery(ctx, "mydb", "top_customers")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.SQL != nq.SQL || got.CacheTTL != 30 || got.Write {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	if len(got.Params) != 1 || got.Params[0].Name != "limit" || got.Params[0].Default != float64(10) {
+		t.Errorf("unexpected params: %+v", got.Params)
+	}
+
+	// Update.
+	got.SQL = "DELETE FROM customers WHERE id = :limit"
+	got.Write = true
+	if err := store.UpdateNamedQuery(ctx, got); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, _ = store.GetNamedQuery(ctx, "mydb", "top_customers")
+	if !got.Write || got.SQL != "DELETE FROM customers WHERE id = :limit" {
+		t.Errorf("update not applied: %+v", got)
+	}
+
+	// Listing.
+	store.CreateNamedQuery(ctx, &model.NamedQuery{ServiceName: "mydb", Name: "a_first", SQL: "SELECT 1"})
+	store.CreateNamedQuery(ctx, &model.NamedQuery{ServiceName: "other", Name: "elsewhere", SQL: "SELECT 1"})
+
+	list, err := store.ListNamedQueries(ctx, "mydb")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "a_first" {
+		t.Errorf("expected 2 queries ordered by name, got %+v", list)
+	}
+	if list[0].Params == nil {
+		t.Error("expected empty params slice, got nil")
+	}
+
+	all, err := store.ListAllNamedQueries(ctx)
+	if err != nil {
+		t.Fatalf("list all: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 queries across services, got %d", len(all))
+	}
+
+	// Delete.
+	if err := store.DeleteNamedQuery(ctx, "mydb", "top_customers"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.GetNamedQuery(ctx, "mydb", "top_customers"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.DeleteNamedQuery(ctx, "mydb", "top_customers"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	if err := store.UpdateNamedQuery(ctx, &model.NamedQuery{ServiceName: "mydb", Name: "nope", SQL: "SELECT 1"}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound updating missing query, got %v", err)
+	}
+}
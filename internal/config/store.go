@@ -78,6 +78,11 @@ type serviceRow struct {
 	RawSQLAllowed     bool      `db:"raw_sql_allowed"`
 	IsActive          bool      `db:"is_active"`
 	SchemaLock        string    `db:"schema_lock"`
+	VersionColumn     string    `db:"version_column"`
+	NumericFormat     string    `db:"numeric_format"`
+	ChangeStreams     bool      `db:"change_streams"`
+	PostgREST         bool      `db:"postgrest"`
+	ColumnRulesJSON   string    `db:"column_rules_json"`
 	MaxOpenConns      int       `db:"max_open_conns"`
 	MaxIdleConns      int       `db:"max_idle_conns"`
 	ConnMaxLifetimeMs int64     `db:"conn_max_lifetime_ms"`
@@ -91,6 +96,11 @@ func serviceRowFromModel(svc *model.ServiceConfig) serviceRow {
 	if schemaLock == "" {
 		schemaLock = "none"
 	}
+	rulesJSON := "[]"
+	if len(svc.ColumnRules) > 0 {
+		b, _ := json.Marshal(svc.ColumnRules)
+		rulesJSON = string(b)
+	}
 	return serviceRow{
 		ID:                svc.ID,
 		Name:              svc.Name,
@@ -103,6 +113,11 @@ func serviceRowFromModel(svc *model.ServiceConfig) serviceRow {
 		RawSQLAllowed:     svc.RawSQL,
 		IsActive:          svc.IsActive,
 		SchemaLock:        schemaLock,
+		VersionColumn:     svc.VersionColumn,
+		NumericFormat:     svc.NumericFormat,
+		ChangeStreams:     svc.ChangeStreams,
+		PostgREST:         svc.PostgREST,
+		ColumnRulesJSON:   rulesJSON,
 		MaxOpenConns:      svc.Pool.MaxOpenConns,
 		MaxIdleConns:      svc.Pool.MaxIdleConns,
 		ConnMaxLifetimeMs: svc.Pool.ConnMaxLifetime.Milliseconds(),
@@ -113,6 +128,10 @@ func serviceRowFromModel(svc *model.ServiceConfig) serviceRow {
 }
 
 func (r serviceRow) toModel() model.ServiceConfig {
+	var rules []model.ColumnRule
+	if r.ColumnRulesJSON != "" {
+		_ = json.Unmarshal([]byte(r.ColumnRulesJSON), &rules)
+	}
 	return model.ServiceConfig{
 		ID:             r.ID,
 		Name:           r.Name,
@@ -125,6 +144,11 @@ func (r serviceRow) toModel() model.ServiceConfig {
 		RawSQL:         r.RawSQLAllowed,
 		IsActive:       r.IsActive,
 		SchemaLock:     r.SchemaLock,
+		VersionColumn:  r.VersionColumn,
+		NumericFormat:  r.NumericFormat,
+		ChangeStreams:  r.ChangeStreams,
+		PostgREST:      r.PostgREST,
+		ColumnRules:    rules,
 		Pool: model.PoolConfig{
 			MaxOpenConns:    r.MaxOpenConns,
 			MaxIdleConns:    r.MaxIdleConns,
@@ -147,11 +171,11 @@ func (s *Store) CreateService(ctx context.Context, svc *model.ServiceConfig) err
 
 	const q = `INSERT INTO services
 		(name, label, driver, dsn, private_key_path, schema_name, read_only, raw_sql_allowed, is_active, schema_lock,
-		 max_open_conns, max_idle_conns, conn_max_lifetime_ms, conn_max_idle_time_ms,
+		 version_column, numeric_format, change_streams, postgrest, column_rules_json, max_open_conns, max_idle_conns, conn_max_lifetime_ms, conn_max_idle_time_ms,
 		 created_at, updated_at)
 		VALUES
 		(:name, :label, :driver, :dsn, :private_key_path, :schema_name, :read_only, :raw_sql_allowed, :is_active, :schema_lock,
-		 :max_open_conns, :max_idle_conns, :conn_max_lifetime_ms, :conn_max_idle_time_ms,
+		 :version_column, :numeric_format, :change_streams, :postgrest, :column_rules_json, :max_open_conns, :max_idle_conns, :conn_max_lifetime_ms, :conn_max_idle_time_ms,
 		 :created_at, :updated_at)`
 
 	result, err := s.db.NamedExecContext(ctx, q, row)
@@ -216,9 +240,12 @@ func (s *Store) UpdateService(ctx context.Context, svc *model.ServiceConfig) err
 	const q = `UPDATE services SET
 		name = :name, label = :label, driver = :driver, dsn = :dsn, private_key_path = :private_key_path,
 		schema_name = :schema_name, read_only = :read_only, raw_sql_allowed = :raw_sql_allowed,
-		is_active = :is_active, schema_lock = :schema_lock, max_open_conns = :max_open_conns,
-		max_idle_conns = :max_idle_conns, conn_max_lifetime_ms = :conn_max_lifetime_ms,
-		conn_max_idle_time_ms = :conn_max_idle_time_ms, updated_at = :updated_at
+		is_active = :is_active, schema_lock = :schema_lock, version_column = :version_column,
+		numeric_format = :numeric_format, change_streams = :change_streams, postgrest = :postgrest,
+		column_rules_json = :column_rules_json,
+		max_open_conns = :max_open_conns, max_idle_conns = :max_idle_conns,
+		conn_max_lifetime_ms = :conn_max_lifetime_ms, conn_max_idle_time_ms = :conn_max_idle_time_ms,
+		updated_at = :updated_at
 		WHERE id = :id`
 
 	result, err := s.db.NamedExecContext(ctx, q, row)
@@ -263,8 +290,8 @@ func (s *Store) CreateRole(ctx context.Context, role *model.Role) error {
 	role.CreatedAt = now
 	role.UpdatedAt = now
 
-	const q = `INSERT INTO roles (name, description, is_active, created_at, updated_at)
-		VALUES (:name, :description, :is_active, :created_at, :updated_at)`
+	const q = `INSERT INTO roles (name, description, is_active, max_limit, created_at, updated_at)
+		VALUES (:name, :description, :is_active, :max_limit, :created_at, :updated_at)`
 
 	result, err := s.db.NamedExecContext(ctx, q, role)
 	if err != nil {
@@ -337,7 +364,8 @@ func (s *Store) UpdateRole(ctx context.Context, role *model.Role) error {
 	role.UpdatedAt = time.Now().UTC()
 
 	const q = `UPDATE roles SET
-		name = :name, description = :description, is_active = :is_active, updated_at = :updated_at
+		name = :name, description = :description, is_active = :is_active, max_limit = :max_limit,
+		updated_at = :updated_at
 		WHERE id = :id`
 
 	result, err := s.db.NamedExecContext(ctx, q, role)
@@ -562,11 +590,19 @@ func (s *Store) UpdateAdminLastLogin(ctx context.Context, id int64) error {
 // (use HashAPIKey). The ID and CreatedAt fields are populated after insert.
 func (s *Store) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
 	key.CreatedAt = time.Now().UTC()
+	key.MetadataJSON = "{}"
+	if len(key.Metadata) > 0 {
+		b, err := json.Marshal(key.Metadata)
+		if err != nil {
+			return fmt.Errorf("marshal api key metadata: %w", err)
+		}
+		key.MetadataJSON = string(b)
+	}
 
 	const q = `INSERT INTO api_keys
-		(key_hash, key_prefix, label, role_id, is_active, expires_at, created_at)
+		(key_hash, key_prefix, label, role_id, metadata_json, is_active, expires_at, created_at)
 		VALUES
-		(:key_hash, :key_prefix, :label, :role_id, :is_active, :expires_at, :created_at)`
+		(:key_hash, :key_prefix, :label, :role_id, :metadata_json, :is_active, :expires_at, :created_at)`
 
 	result, err := s.db.NamedExecContext(ctx, q, key)
 	if err != nil {
@@ -590,6 +626,7 @@ func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey
 		}
 		return nil, fmt.Errorf("get api key by hash: %w", err)
 	}
+	decodeAPIKeyMetadata(&key)
 	return &key, nil
 }
 
@@ -599,9 +636,19 @@ func (s *Store) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
 	if err := s.db.SelectContext(ctx, &keys, "SELECT * FROM api_keys ORDER BY created_at DESC"); err != nil {
 		return nil, fmt.Errorf("list api keys: %w", err)
 	}
+	for i := range keys {
+		decodeAPIKeyMetadata(&keys[i])
+	}
 	return keys, nil
 }
 
+// decodeAPIKeyMetadata fills Metadata from the stored JSON.
+func decodeAPIKeyMetadata(key *model.APIKey) {
+	if key.MetadataJSON != "" && key.MetadataJSON != "{}" {
+		_ = json.Unmarshal([]byte(key.MetadataJSON), &key.Metadata)
+	}
+}
+
 // RevokeAPIKey marks an API key as inactive by ID.
 func (s *Store) RevokeAPIKey(ctx context.Context, id int64) error {
 	result, err := s.db.ExecContext(ctx,
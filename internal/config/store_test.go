@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -550,3 +551,42 @@ func TestPoolConfigRoundTrip(t *testing.T) {
 		t.Errorf("ConnMaxLifetime: got %v, want 10m", got.Pool.ConnMaxLifetime)
 	}
 }
+
+func TestColumnRulesAndKeyMetadataRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	rules := []model.ColumnRule{
+		{Table: "*", Column: "created_by", Value: "principal.id", On: model.ColumnRuleOnCreate},
+		{Table: "orders", Column: "tenant_id", Value: "key.tenant", Mode: model.ColumnRuleReject},
+	}
+	svc := &model.ServiceConfig{Name: "rules", Driver: "sqlite", DSN: ":memory:", IsActive: true, ColumnRules: rules}
+	if err := s.CreateService(ctx, svc); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	got, err := s.GetService(ctx, svc.ID)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if !reflect.DeepEqual(got.ColumnRules, rules) {
+		t.Errorf("ColumnRules: got %+v, want %+v", got.ColumnRules, rules)
+	}
+
+	role := &model.Role{Name: "tenant", IsActive: true}
+	if err := s.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	hash := HashAPIKey("faucet_meta")
+	key := &model.APIKey{KeyHash: hash, KeyPrefix: "faucet_m", RoleID: role.ID, IsActive: true,
+		Metadata: map[string]string{"tenant": "acme"}}
+	if err := s.CreateAPIKey(ctx, key); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	gotKey, err := s.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetAPIKeyByHash: %v", err)
+	}
+	if gotKey.Metadata["tenant"] != "acme" {
+		t.Errorf("Metadata: got %v", gotKey.Metadata)
+	}
+}
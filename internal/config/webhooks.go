@@ -0,0 +1,271 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+const webhookColumns = `id, service_name, table_name, verb_mask, url, secret, filter, is_active,
+	created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status,
+	error, next_attempt_at, created_at, last_attempt_at`
+
+// CreateWebhook stores a new webhook. The webhook's ID and timestamps are
+// set on success.
+func (s *Store) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	now := time.Now().UTC()
+	hook.CreatedAt = now
+	hook.UpdatedAt = now
+
+	const q = `INSERT INTO webhooks
+		(service_name, table_name, verb_mask, url, secret, filter, is_active, created_at, updated_at)
+		VALUES (:service_name, :table_name, :verb_mask, :url, :secret, :filter, :is_active, :created_at, :updated_at)`
+
+	result, err := s.db.NamedExecContext(ctx, q, hook)
+	if err != nil {
+		return fmt.Errorf("create webhook: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get webhook id: %w", err)
+	}
+	hook.ID = id
+	return nil
+}
+
+// GetWebhook returns a single webhook by ID.
+func (s *Store) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	var hook model.Webhook
+	q := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	if err := s.db.GetContext(ctx, &hook, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get webhook: %w", err)
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns every webhook, ordered by ID. When serviceName is
+// non-empty only that service's webhooks are returned.
+func (s *Store) ListWebhooks(ctx context.Context, serviceName string) ([]model.Webhook, error) {
+	q := `SELECT ` + webhookColumns + ` FROM webhooks`
+	var args []interface{}
+	if serviceName != "" {
+		q += ` WHERE service_name = ?`
+		args = append(args, serviceName)
+	}
+	q += ` ORDER BY id`
+
+	var hooks []model.Webhook
+	if err := s.db.SelectContext(ctx, &hooks, q, args...); err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// ListWebhooksFor returns the active webhooks registered for a table of a
+// service, including those registered for every table with "*".
+func (s *Store) ListWebhooksFor(ctx context.Context, serviceName, tableName string) ([]model.Webhook, error) {
+	q := `SELECT ` + webhookColumns + ` FROM webhooks
+		WHERE service_name = ? AND table_name IN (?, '*') AND is_active = 1 ORDER BY id`
+	var hooks []model.Webhook
+	if err := s.db.SelectContext(ctx, &hooks, q, serviceName, tableName); err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook replaces a webhook's settings.
+func (s *Store) UpdateWebhook(ctx context.Context, hook *model.Webhook) error {
+	hook.UpdatedAt = time.Now().UTC()
+
+	const q = `UPDATE webhooks SET
+		service_name = :service_name, table_name = :table_name, verb_mask = :verb_mask, url = :url,
+		secret = :secret, filter = :filter, is_active = :is_active, updated_at = :updated_at
+		WHERE id = :id`
+
+	result, err := s.db.NamedExecContext(ctx, q, hook)
+	if err != nil {
+		return fmt.Errorf("update webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries.
+func (s *Store) DeleteWebhook(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+// CreateWebhookDelivery queues a delivery, due immediately. The delivery's
+// ID, status and timestamps are set on success.
+func (s *Store) CreateWebhookDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	now := time.Now().UTC()
+	d.Status = model.DeliveryPending
+	d.CreatedAt = now
+	d.NextAttemptAt = now
+	d.PayloadJSON = string(d.Payload)
+
+	const q = `INSERT INTO webhook_deliveries
+		(webhook_id, event, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := s.db.ExecContext(ctx, q, d.WebhookID, d.Event, d.PayloadJSON, d.Status, d.NextAttemptAt, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create webhook delivery: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get webhook delivery id: %w", err)
+	}
+	d.ID = id
+	return nil
+}
+
+// GetWebhookDelivery returns a single delivery by ID.
+func (s *Store) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	q := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = ?`
+	if err := s.db.GetContext(ctx, &d, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get webhook delivery: %w", err)
+	}
+	d.Payload = json.RawMessage(d.PayloadJSON)
+	return &d, nil
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook, newest
+// first. When status is non-empty only deliveries with that status are
+// returned; model.DeliveryDead lists the dead letters.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]model.WebhookDelivery, error) {
+	q := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = ?`
+	args := []interface{}{webhookID}
+	if status != "" {
+		q += ` AND status = ?`
+		args = append(args, status)
+	}
+	q += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	var deliveries []model.WebhookDelivery
+	if err := s.db.SelectContext(ctx, &deliveries, q, args...); err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	for i := range deliveries {
+		deliveries[i].Payload = json.RawMessage(deliveries[i].PayloadJSON)
+	}
+	return deliveries, nil
+}
+
+// ClaimWebhookDelivery marks the oldest pending delivery that is due at now
+// as sending and returns it, or returns nil when none is due.
+func (s *Store) ClaimWebhookDelivery(ctx context.Context, now time.Time) (*model.WebhookDelivery, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	defer tx.Rollback()
+
+	var d model.WebhookDelivery
+	q := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT 1`
+	if err := tx.GetContext(ctx, &d, q, model.DeliveryPending, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET status = ? WHERE id = ?`,
+		model.DeliverySending, d.ID); err != nil {
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	d.Status = model.DeliverySending
+	d.Payload = json.RawMessage(d.PayloadJSON)
+	return &d, nil
+}
+
+// FinishWebhookDelivery stores the outcome of an attempt to send a
+// delivery: its status, attempts, response status, error and next attempt.
+func (s *Store) FinishWebhookDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	now := time.Now().UTC()
+	const q = `UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?,
+		next_attempt_at = ?, last_attempt_at = ?
+		WHERE id = ? AND status = ?`
+	if _, err := s.db.ExecContext(ctx, q,
+		d.Status, d.Attempts, d.ResponseStatus, d.Error, d.NextAttemptAt.UTC(), now,
+		d.ID, model.DeliverySending); err != nil {
+		return fmt.Errorf("finish webhook delivery: %w", err)
+	}
+	d.LastAttemptAt = &now
+	return nil
+}
+
+// RequeueWebhookDelivery queues a delivery to be sent again immediately,
+// with a fresh set of attempts. It returns false when the delivery is being
+// sent.
+func (s *Store) RequeueWebhookDelivery(ctx context.Context, id int64) (bool, error) {
+	const q = `UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND status <> ?`
+	result, err := s.db.ExecContext(ctx, q, model.DeliveryPending, time.Now().UTC(), id, model.DeliverySending)
+	if err != nil {
+		return false, fmt.Errorf("requeue webhook delivery: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ResetSendingWebhookDeliveries returns every delivery being sent to the
+// queue. It is called at startup for deliveries whose worker stopped with
+// the previous process.
+func (s *Store) ResetSendingWebhookDeliveries(ctx context.Context) error {
+	const q = `UPDATE webhook_deliveries SET status = ? WHERE status = ?`
+	if _, err := s.db.ExecContext(ctx, q, model.DeliveryPending, model.DeliverySending); err != nil {
+		return fmt.Errorf("reset sending webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries removes the delivered and dead deliveries created
+// before cutoff, returning how many were removed.
+func (s *Store) PruneWebhookDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM webhook_deliveries WHERE status IN (?, ?) AND created_at < ?`
+	result, err := s.db.ExecContext(ctx, q, model.DeliveryDelivered, model.DeliveryDead, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestWebhookCRUD(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	orders := &model.Webhook{ServiceName: "mydb", Table: "orders", VerbMask: model.VerbPost,
+		URL: "https://example.com/a", Secret: "s1", Filter: "status = 'paid'", IsActive: true}
+	all := &model.Webhook{ServiceName: "mydb", Table: "*", VerbMask: model.VerbDelete,
+		URL: "https://example.com/b", Secret: "s2", IsActive: true}
+	other := &model.Webhook{ServiceName: "otherdb", Table: "orders", VerbMask: model.VerbPost,
+		URL: "https://example.com/c", Secret: "s3", IsActive: true}
+	for _, hook := range []*model.Webhook{orders, all, other} {
+		if err := store.CreateWebhook(ctx, hook); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if orders.ID == 0 || orders.CreatedAt.IsZero() {
+		t.Errorf("expected ID and timestamps to be set: %+v", orders)
+	}
+
+	got, err := store.GetWebhook(ctx, orders.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Table != "orders" || got.Secret != "s1" || got.Filter != "status = 'paid'" || !got.IsActive {
+		t.Errorf("unexpected webhook: %+v", got)
+	}
+	if _, err := store.GetWebhook(ctx, 999); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	hooks, _ := store.ListWebhooks(ctx, "")
+	if len(hooks) != 3 {
+		t.Errorf("expected 3 webhooks, got %d", len(hooks))
+	}
+	hooks, _ = store.ListWebhooks(ctx, "mydb")
+	if len(hooks) != 2 {
+		t.Errorf("expected 2 mydb webhooks, got %d", len(hooks))
+	}
+
+	// Table webhooks and "*" webhooks both match a table; inactive ones do not.
+	hooks, err = store.ListWebhooksFor(ctx, "mydb", "orders")
+	if err != nil || len(hooks) != 2 {
+		t.Fatalf("expected 2 webhooks for orders, got %v (%v)", hooks, err)
+	}
+	hooks, _ = store.ListWebhooksFor(ctx, "mydb", "users")
+	if len(hooks) != 1 || hooks[0].ID != all.ID {
+		t.Errorf("expected only the * webhook for users, got %v", hooks)
+	}
+	all.IsActive = false
+	if err := store.UpdateWebhook(ctx, all); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	hooks, _ = store.ListWebhooksFor(ctx, "mydb", "users")
+	if len(hooks) != 0 {
+		t.Errorf("expected an inactive webhook not to match, got %v", hooks)
+	}
+	if err := store.UpdateWebhook(ctx, &model.Webhook{ID: 999}); err != ErrNotFound {
+		t.Errorf("update missing: expected ErrNotFound, got %v", err)
+	}
+
+	// Deleting a webhook removes its deliveries.
+	d := &model.WebhookDelivery{WebhookID: orders.ID, Event: "insert", Payload: []byte(`{}`)}
+	if err := store.CreateWebhookDelivery(ctx, d); err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+	if err := store.DeleteWebhook(ctx, orders.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.GetWebhookDelivery(ctx, d.ID); err != ErrNotFound {
+		t.Errorf("expected the delivery to be deleted, got %v", err)
+	}
+	if err := store.DeleteWebhook(ctx, orders.ID); err != ErrNotFound {
+		t.Errorf("delete again: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestWebhookDeliveryQueue(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	first := &model.WebhookDelivery{WebhookID: 1, Event: "insert", Payload: []byte(`{"n":1}`)}
+	second := &model.WebhookDelivery{WebhookID: 1, Event: "delete", Payload: []byte(`{"n":2}`)}
+	for _, d := range []*model.WebhookDelivery{first, second} {
+		if err := store.CreateWebhookDelivery(ctx, d); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if first.Status != model.DeliveryPending {
+		t.Errorf("expected pending, got %q", first.Status)
+	}
+
+	// Deliveries are claimed oldest first, once.
+	claimed, err := store.ClaimWebhookDelivery(ctx, time.Now())
+	if err != nil || claimed == nil || claimed.ID != first.ID || claimed.Status != model.DeliverySending {
+		t.Fatalf("claim: expected sending delivery %d, got %+v (%v)", first.ID, claimed, err)
+	}
+	if string(claimed.Payload) != `{"n":1}` {
+		t.Errorf("unexpected payload %s", claimed.Payload)
+	}
+
+	// A failed attempt is not due again until its next attempt.
+	claimed.Status, claimed.Attempts, claimed.ResponseStatus, claimed.Error = model.DeliveryPending, 1, 500, "HTTP 500"
+	claimed.NextAttemptAt = time.Now().Add(time.Hour)
+	if err := store.FinishWebhookDelivery(ctx, claimed); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	next, _ := store.ClaimWebhookDelivery(ctx, time.Now())
+	if next == nil || next.ID != second.ID {
+		t.Fatalf("expected delivery %d to be claimed next, got %+v", second.ID, next)
+	}
+	if d, _ := store.ClaimWebhookDelivery(ctx, time.Now()); d != nil {
+		t.Errorf("expected nothing due, got %+v", d)
+	}
+	if d, _ := store.ClaimWebhookDelivery(ctx, time.Now().Add(2*time.Hour)); d == nil || d.ID != first.ID {
+		t.Errorf("expected the retry to be due later, got %+v", d)
+	}
+
+	got, _ := store.GetWebhookDelivery(ctx, first.ID)
+	if got.Attempts != 1 || got.ResponseStatus != 500 || got.LastAttemptAt == nil {
+		t.Errorf("unexpected delivery: %+v", got)
+	}
+
+	// Deliveries being sent are neither requeued nor listed as dead.
+	if ok, err := store.RequeueWebhookDelivery(ctx, second.ID); err != nil || ok {
+		t.Errorf("requeue sending: expected false, got %v (%v)", ok, err)
+	}
+	next.Status, next.Attempts, next.Error = model.DeliveryDead, 8, "HTTP 410"
+	store.FinishWebhookDelivery(ctx, next)
+	dead, err := store.ListWebhookDeliveries(ctx, 1, model.DeliveryDead, 10)
+	if err != nil || len(dead) != 1 || dead[0].ID != second.ID {
+		t.Fatalf("expected delivery %d to be dead, got %v (%v)", second.ID, dead, err)
+	}
+	all, _ := store.ListWebhookDeliveries(ctx, 1, "", 10)
+	if len(all) != 2 || all[0].ID != second.ID {
+		t.Errorf("expected 2 deliveries, newest first, got %v", all)
+	}
+
+	// Redelivery starts over with fresh attempts.
+	if ok, err := store.RequeueWebhookDelivery(ctx, second.ID); err != nil || !ok {
+		t.Fatalf("requeue: %v %v", ok, err)
+	}
+	got, _ = store.GetWebhookDelivery(ctx, second.ID)
+	if got.Status != model.DeliveryPending || got.Attempts != 0 {
+		t.Errorf("unexpected requeued delivery: %+v", got)
+	}
+
+	// Deliveries left sending are queued again at startup.
+	first, _ = store.ClaimWebhookDelivery(ctx, time.Now().Add(2*time.Hour))
+	if err := store.ResetSendingWebhookDeliveries(ctx); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if got, _ := store.GetWebhookDelivery(ctx, first.ID); got.Status != model.DeliveryPending {
+		t.Errorf("expected reset delivery to be pending, got %q", got.Status)
+	}
+
+	// Only finished deliveries are pruned.
+	claimed, _ = store.ClaimWebhookDelivery(ctx, time.Now().Add(2*time.Hour))
+	claimed.Status = model.DeliveryDelivered
+	store.FinishWebhookDelivery(ctx, claimed)
+	n, err := store.PruneWebhookDeliveries(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Errorf("prune: expected 1 removed, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,22 @@
+package connector
+
+import "context"
+
+// RowSource streams rows into a bulk load. Next advances to the next row,
+// Values returns it in the load's column order, and Err reports why Next
+// returned false (nil at the end of the input). It has the shape of
+// pgx.CopyFromSource so it can be handed to pgx unchanged.
+type RowSource interface {
+	Next() bool
+	Values() ([]interface{}, error)
+	Err() error
+}
+
+// BulkLoader is implemented by connectors with a native bulk-load protocol
+// that is faster than multi-row INSERTs, such as PostgreSQL COPY or SQL
+// Server bulk copy. BulkLoad writes every row of src into table in its own
+// transaction and returns the number of rows written; on error nothing is
+// written.
+type BulkLoader interface {
+	BulkLoad(ctx context.Context, table string, columns []string, src RowSource) (int64, error)
+}
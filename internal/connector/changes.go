@@ -0,0 +1,189 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// ChangeLogTable is the table that trigger-based change capture writes to.
+// Connectors hide it from schema introspection.
+const ChangeLogTable = "_faucet_changes"
+
+// Change operations.
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// ErrInvalidOffset is returned by ReadChanges for an offset the connector
+// did not produce.
+var ErrInvalidOffset = errors.New("invalid change offset")
+
+// ErrCaptureNotEnabled is returned by EnableChanges when the database's own
+// change capture must be switched on for the table by an administrator.
+var ErrCaptureNotEnabled = errors.New("change capture is not enabled for this table")
+
+// Change is one captured row change. Row is the row after an insert or
+// update and before a delete; Old is the row before an update, when the
+// capture mechanism records it. Offsets are opaque, increase with every
+// change of a table, and resume a stream after the change they belong to.
+type Change struct {
+	Offset string
+	Op     string
+	Row    map[string]interface{}
+	Old    map[string]interface{}
+	Time   time.Time
+}
+
+// ChangeSource is implemented by connectors that can capture row changes.
+//
+// EnableChanges starts capturing changes of a table, or refreshes the
+// capture after the table's columns changed; it is idempotent.
+// ReadChanges returns up to limit changes of table after the given offset
+// (the empty offset reads from the oldest change still recorded), and
+// LastChangeOffset the offset of the latest change, or "" when there is
+// none. PruneChanges drops changes recorded before the given time.
+type ChangeSource interface {
+	EnableChanges(ctx context.Context, def model.TableSchema) error
+	DisableChanges(ctx context.Context, table string) error
+	ReadChanges(ctx context.Context, table, after string, limit int) ([]Change, error)
+	LastChangeOffset(ctx context.Context, table string) (string, error)
+	PruneChanges(ctx context.Context, before time.Time) error
+}
+
+// ChangeListener is implemented by change sources that can push a wake-up
+// when a table changes, such as PostgreSQL LISTEN/NOTIFY, so readers need
+// not poll. ListenChanges calls fn with the name of each changed table
+// until ctx is done or the connection fails.
+type ChangeListener interface {
+	ListenChanges(ctx context.Context, fn func(table string)) error
+}
+
+// ChangeLog reads the log table written by trigger-based change capture.
+// The log has the columns id (increasing), table_name, op, row_data and
+// old_data (JSON text) and changed_at (Unix milliseconds); offsets are ids.
+type ChangeLog struct {
+	DB          *sqlx.DB
+	Table       string // quoted, qualified name of the log table
+	Placeholder func(index int) string
+}
+
+// Read returns up to limit changes of table with an id above after.
+func (l ChangeLog) Read(ctx context.Context, table, after string, limit int) ([]Change, error) {
+	var since int64
+	if after != "" {
+		n, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidOffset
+		}
+		since = n
+	}
+
+	q := fmt.Sprintf(`SELECT id, op, row_data, old_data, changed_at FROM %s
+		WHERE table_name = %s AND id > %s ORDER BY id LIMIT %d`,
+		l.Table, l.Placeholder(1), l.Placeholder(2), limit)
+	rows, err := l.DB.QueryContext(ctx, q, table, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var (
+			id       int64
+			op       string
+			row, old []byte
+			at       int64
+		)
+		if err := rows.Scan(&id, &op, &row, &old, &at); err != nil {
+			return nil, err
+		}
+		c := Change{Offset: strconv.FormatInt(id, 10), Op: op, Time: time.UnixMilli(at).UTC()}
+		if c.Row, err = DecodeChangeRow(row); err != nil {
+			return nil, fmt.Errorf("change %d: %w", id, err)
+		}
+		if c.Old, err = DecodeChangeRow(old); err != nil {
+			return nil, fmt.Errorf("change %d: %w", id, err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Last returns the id of the latest change of table, or "" when there is
+// none.
+func (l ChangeLog) Last(ctx context.Context, table string) (string, error) {
+	var id sql.NullInt64
+	q := fmt.Sprintf(`SELECT MAX(id) FROM %s WHERE table_name = %s`, l.Table, l.Placeholder(1))
+	if err := l.DB.QueryRowContext(ctx, q, table).Scan(&id); err != nil {
+		return "", err
+	}
+	if !id.Valid {
+		return "", nil
+	}
+	return strconv.FormatInt(id.Int64, 10), nil
+}
+
+// Prune deletes the changes recorded before the given time.
+func (l ChangeLog) Prune(ctx context.Context, before time.Time) error {
+	q := fmt.Sprintf(`DELETE FROM %s WHERE changed_at < %s`, l.Table, l.Placeholder(1))
+	_, err := l.DB.ExecContext(ctx, q, before.UnixMilli())
+	return err
+}
+
+// DecodeChangeRow decodes a row image captured as a JSON object. Integral
+// numbers become int64 and other numbers strings, the forms drivers scan
+// them as, so that a ValueEncoder encodes captured rows like queried ones.
+// Empty input decodes to nil.
+func DecodeChangeRow(data []byte) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var row map[string]interface{}
+	if err := dec.Decode(&row); err != nil {
+		return nil, err
+	}
+	for k, v := range row {
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				row[k] = i
+			} else {
+				row[k] = n.String()
+			}
+		}
+	}
+	return row, nil
+}
+
+// CaptureColumns returns the columns of def that change capture records in
+// row images. Binary columns are left out: they would bloat the log and
+// cannot be represented in JSON without an encoding the reader must guess.
+func CaptureColumns(def model.TableSchema) []string {
+	cols := make([]string, 0, len(def.Columns))
+	for _, col := range def.Columns {
+		if col.JsonType != "string(byte)" {
+			cols = append(cols, col.Name)
+		}
+	}
+	return cols
+}
+
+// QuoteLiteral renders s as a standard SQL string literal.
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
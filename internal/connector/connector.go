@@ -24,23 +24,32 @@ type QueryExecutor interface {
 }
 
 // SelectRequest represents a query for records.
+//
+// Placeholders in Having are numbered after those in Filter, so HavingArgs
+// are bound immediately after FilterArgs.
 type SelectRequest struct {
 	Table      string
 	Fields     []string
 	Projection []query.SelectItem // structured SELECT list (plain columns and/or aggregates); takes precedence over Fields when set
+	Distinct   bool               // SELECT DISTINCT
 	Filter     string
 	FilterArgs []interface{}
 	Order      string
 	GroupBy    []string
+	Having     string // parameterized HAVING fragment (see query.ParseHaving)
+	HavingArgs []interface{}
 	Limit      int
 	Offset     int
 	Cursor     string
+	Lock       bool // lock the selected rows until the transaction ends (SELECT ... FOR UPDATE); ignored where the dialect has no row locks
 }
 
-// InsertRequest represents an insert operation.
+// InsertRequest represents an insert operation. When Upsert is set the
+// statement updates (or skips) rows that conflict with existing ones.
 type InsertRequest struct {
 	Table   string
 	Records []map[string]interface{}
+	Upsert  *UpsertOptions
 }
 
 // UpdateRequest represents an update operation.
@@ -49,7 +58,8 @@ type UpdateRequest struct {
 	Filter     string
 	FilterArgs []interface{}
 	Record     map[string]interface{}
-	IDs        []interface{} // for updating by primary key
+	IDs        []interface{} // for updating by primary key (see BuildKeyClause)
+	KeyColumns []string      // primary key columns the IDs refer to; defaults to "id"
 }
 
 // DeleteRequest represents a delete operation.
@@ -57,7 +67,8 @@ type DeleteRequest struct {
 	Table      string
 	Filter     string
 	FilterArgs []interface{}
-	IDs        []interface{}
+	IDs        []interface{} // for deleting by primary key (see BuildKeyClause)
+	KeyColumns []string      // primary key columns the IDs refer to; defaults to "id"
 }
 
 // CountRequest represents a count query.
@@ -106,6 +117,11 @@ type Connector interface {
 	AlterTable(ctx context.Context, tableName string, changes []SchemaChange) error
 	DropTable(ctx context.Context, tableName string) error
 
+	// Schema modification SQL, rendered without executing it (dry runs)
+	BuildCreateTable(def model.TableSchema) (string, error)
+	BuildAlterTable(tableName string, changes []SchemaChange) ([]string, error)
+	BuildDropTable(tableName string) (string, error)
+
 	// Stored procedures
 	CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error)
 
@@ -114,9 +130,17 @@ type Connector interface {
 	QuoteIdentifier(name string) string
 	SupportsReturning() bool
 	SupportsUpsert() bool
+	SupportsTransactionalDDL() bool
 	ParameterPlaceholder(index int) string
 }
 
+// TxProcedureCaller is implemented by connectors that can call a stored
+// procedure on a caller-supplied executor, so that the call joins a
+// transaction instead of running on its own connection.
+type TxProcedureCaller interface {
+	CallProcedureTx(ctx context.Context, exec QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error)
+}
+
 // SanitizeDSN ensures that URL-style DSNs (postgres://, sqlserver://) have
 // their userinfo (especially the password) properly percent-encoded. Raw
 // passwords containing @, #, %, or other URL-special characters cause the
@@ -0,0 +1,111 @@
+// Package connectortest provides the query builder test cases shared by the
+// database connectors. Each connector's tests supply the SQL of its own
+// dialect; the cases that do not depend on the dialect are checked here once.
+package connectortest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+// UpsertSQL holds a dialect's expected upsert statements for the two users
+// inserted by RunUpsertTests, whose columns are email, id and name.
+type UpsertSQL struct {
+	UpdateAll    string // conflict on id; every other column is updated
+	UpdateSubset string // conflict on email; only name is updated
+	Ignore       string // conflict on id; duplicates are left untouched
+}
+
+// RunUpsertTests checks the upserts c.BuildInsert builds against want, and
+// the cases every dialect must handle alike.
+func RunUpsertTests(t *testing.T, c connector.Connector, want UpsertSQL) {
+	t.Helper()
+	ctx := context.Background()
+
+	records := []map[string]interface{}{
+		{"id": 1, "email": "alice@example.com", "name": "Alice"},
+		{"id": 2, "email": "bob@example.com", "name": "Bob"},
+	}
+	wantArgs := []interface{}{"alice@example.com", 1, "Alice", "bob@example.com", 2, "Bob"}
+
+	tests := []struct {
+		name    string
+		upsert  *connector.UpsertOptions
+		wantSQL string
+		wantErr bool
+	}{
+		{
+			name:    "updates every non-conflict column by default",
+			upsert:  &connector.UpsertOptions{ConflictColumns: []string{"id"}},
+			wantSQL: want.UpdateAll,
+		},
+		{
+			name:    "update column subset",
+			upsert:  &connector.UpsertOptions{ConflictColumns: []string{"email"}, UpdateColumns: []string{"name"}},
+			wantSQL: want.UpdateSubset,
+		},
+		{
+			name:    "ignore duplicates",
+			upsert:  &connector.UpsertOptions{ConflictColumns: []string{"id"}, IgnoreDuplicates: true},
+			wantSQL: want.Ignore,
+		},
+		{
+			name:    "no conflict columns",
+			upsert:  &connector.UpsertOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "conflict column missing from records",
+			upsert:  &connector.UpsertOptions{ConflictColumns: []string{"sku"}},
+			wantErr: true,
+		},
+		{
+			name:    "update column is a conflict column",
+			upsert:  &connector.UpsertOptions{ConflictColumns: []string{"id"}, UpdateColumns: []string{"id"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := c.BuildInsert(ctx, connector.InsertRequest{Table: "users", Records: records, Upsert: tt.upsert})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("SQL mismatch\n  got:  %s\n  want: %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("args mismatch\n  got:  %v\n  want: %v", args, wantArgs)
+			}
+		})
+	}
+
+	// When every inserted column is a conflict column there is nothing to
+	// update, so the upsert leaves duplicates untouched.
+	t.Run("all key columns", func(t *testing.T) {
+		keys := []map[string]interface{}{{"id": 1}, {"id": 2}}
+		sql, args, err := c.BuildInsert(ctx, connector.InsertRequest{Table: "users", Records: keys,
+			Upsert: &connector.UpsertOptions{ConflictColumns: []string{"id"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ignoreSQL, ignoreArgs, err := c.BuildInsert(ctx, connector.InsertRequest{Table: "users", Records: keys,
+			Upsert: &connector.UpsertOptions{ConflictColumns: []string{"id"}, IgnoreDuplicates: true}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if sql != ignoreSQL || !reflect.DeepEqual(args, ignoreArgs) {
+			t.Errorf("expected the ignore-duplicates statement\n  got:  %s\n  want: %s", sql, ignoreSQL)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// DryRun is a QueryExecutor over a transaction that is never committed. It
+// records every statement run through it, so that a dry run can report the
+// exact SQL a request would have sent along with the rows it affected.
+type DryRun struct {
+	tx         *sqlx.Tx
+	Statements []model.DryRunStatement
+	// MaxStatements, when positive, caps the recorded statements; later
+	// statements still run.
+	MaxStatements int
+}
+
+// BeginDryRun opens the transaction of a dry run. The caller must call
+// Rollback once the statements have run.
+func BeginDryRun(ctx context.Context, conn Connector) (*DryRun, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRun{tx: tx}, nil
+}
+
+// Rollback discards everything the dry run wrote.
+func (d *DryRun) Rollback() error {
+	return d.tx.Rollback()
+}
+
+// Record adds a statement that was built but not executed, such as DDL on a
+// database that cannot roll it back.
+func (d *DryRun) Record(query string, args []interface{}) {
+	d.record(query, args)
+}
+
+func (d *DryRun) record(query string, args []interface{}) bool {
+	if d.MaxStatements > 0 && len(d.Statements) >= d.MaxStatements {
+		return false
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	d.Statements = append(d.Statements, model.DryRunStatement{SQL: query, Args: args})
+	return true
+}
+
+// Affected sums the rows affected by the statements run with ExecContext or
+// Run, ignoring the negative counts some drivers report for session
+// statements.
+// Statements run with QueryxContext report no count.
+func (d *DryRun) Affected() int64 {
+	var n int64
+	for _, stmt := range d.Statements {
+		if stmt.RowsAffected != nil && *stmt.RowsAffected > 0 {
+			n += *stmt.RowsAffected
+		}
+	}
+	return n
+}
+
+// QueryxContext runs a query inside the dry run's transaction.
+func (d *DryRun) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	d.record(query, args)
+	return d.tx.QueryxContext(ctx, query, args...)
+}
+
+// ExecContext runs a statement inside the dry run's transaction and records
+// the number of rows it affected.
+func (d *DryRun) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	recorded := d.record(query, args)
+	result, err := d.tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil && recorded {
+		d.Statements[len(d.Statements)-1].RowsAffected = &n
+	}
+	return result, nil
+}
+
+// Run executes a write and records the rows it affected. When returnsRows
+// is set the statement carries a RETURNING (or OUTPUT) clause and its result
+// rows are counted, since drivers may otherwise report the first row only.
+func (d *DryRun) Run(ctx context.Context, returnsRows bool, query string, args ...interface{}) error {
+	if !returnsRows {
+		_, err := d.ExecContext(ctx, query, args...)
+		return err
+	}
+	recorded := d.record(query, args)
+	rows, err := d.tx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var n int64
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if recorded {
+		d.Statements[len(d.Statements)-1].RowsAffected = &n
+	}
+	return nil
+}
+
+// QueryRowxContext runs a single-row query inside the dry run's transaction.
+func (d *DryRun) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	d.record(query, args)
+	return d.tx.QueryRowxContext(ctx, query, args...)
+}
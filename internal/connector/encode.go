@@ -0,0 +1,305 @@
+package connector
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Numeric formats control how decimal values that the driver returns as text
+// (NUMERIC, DECIMAL, NUMBER) are encoded.
+const (
+	// NumericNumber encodes them as JSON numbers, digit for digit. It is
+	// the default.
+	NumericNumber = "number"
+	// NumericString encodes them as exact decimal strings, for clients that
+	// would lose precision parsing large numbers as doubles.
+	NumericString = "string"
+)
+
+// IsNumericFormat reports whether s is a numeric format. The empty string
+// selects the default.
+func IsNumericFormat(s string) bool {
+	return s == "" || s == NumericNumber || s == NumericString
+}
+
+// TypeMapper is implemented by connectors that can map a result column's
+// database type name (sql.ColumnType.DatabaseTypeName) to Go and JSON types,
+// so that rows of ad-hoc queries encode like table rows.
+type TypeMapper interface {
+	MapColumnType(dbType string) (goType, jsonType string)
+}
+
+// ValueDecoder is implemented by connectors whose drivers return some column
+// types in a form the generic rules cannot encode, such as PostgreSQL array
+// literals. DecodeValue returns the encoded value, or false to fall back to
+// the generic rules. elem encodes a nested value as the given column.
+type ValueDecoder interface {
+	DecodeValue(col *model.Column, v interface{}, elem func(*model.Column, interface{}) interface{}) (interface{}, bool)
+}
+
+// ValueEncoder converts scanned row values into their JSON representation
+// according to the column metadata. Every response path (REST, NDJSON,
+// CSV and MCP) encodes rows with it, so a value looks the same everywhere:
+//
+//   - timestamps are RFC 3339 with a zone, dates are YYYY-MM-DD
+//   - decimals are JSON numbers or exact strings (see NumericString)
+//   - booleans stored as integers or text are JSON booleans
+//   - binary columns are base64
+//   - JSON columns are nested JSON rather than strings
+//   - PostgreSQL arrays are JSON arrays
+//
+// Values of columns without metadata get the generic rules: valid UTF-8
+// bytes become strings, other bytes base64, and times RFC 3339.
+type ValueEncoder struct {
+	columns        map[string]*model.Column
+	decoder        ValueDecoder
+	numericStrings bool
+}
+
+// NewValueEncoder returns an encoder for rows of table, which may be nil for
+// result sets of no particular table. numeric is a numeric format.
+func NewValueEncoder(conn Connector, table *model.TableSchema, numeric string) *ValueEncoder {
+	e := newValueEncoder(conn, numeric)
+	if table != nil {
+		for i := range table.Columns {
+			e.columns[table.Columns[i].Name] = &table.Columns[i]
+		}
+	}
+	return e
+}
+
+// NewResultEncoder returns an encoder for the rows of a query result, typed
+// from the driver's column types when the connector is a TypeMapper.
+func NewResultEncoder(conn Connector, types []*sql.ColumnType, numeric string) *ValueEncoder {
+	e := newValueEncoder(conn, numeric)
+	mapper, ok := conn.(TypeMapper)
+	if !ok {
+		return e
+	}
+	for _, ct := range types {
+		dbType := ct.DatabaseTypeName()
+		if dbType == "" {
+			continue
+		}
+		goType, jsonType := mapper.MapColumnType(dbType)
+		e.columns[ct.Name()] = &model.Column{Name: ct.Name(), Type: dbType, GoType: goType, JsonType: jsonType}
+	}
+	return e
+}
+
+func newValueEncoder(conn Connector, numeric string) *ValueEncoder {
+	e := &ValueEncoder{
+		columns:        make(map[string]*model.Column),
+		numericStrings: numeric == NumericString,
+	}
+	if d, ok := conn.(ValueDecoder); ok {
+		e.decoder = d
+	}
+	return e
+}
+
+// EncodeRow encodes every value of row in place.
+func (e *ValueEncoder) EncodeRow(row map[string]interface{}) {
+	for k, v := range row {
+		row[k] = e.Encode(e.columns[k], v)
+	}
+}
+
+// Encode encodes one value of col, which may be nil when the column is
+// unknown.
+func (e *ValueEncoder) Encode(col *model.Column, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if col == nil {
+		return encodeGeneric(v)
+	}
+	if e.decoder != nil {
+		if out, ok := e.decoder.DecodeValue(col, v, e.Encode); ok {
+			return out
+		}
+	}
+
+	switch col.JsonType {
+	case "integer":
+		if s, ok := textValue(v); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n
+			}
+			if isJSONNumber(s) {
+				return json.Number(s)
+			}
+			return s
+		}
+	case "number":
+		if s, ok := textValue(v); ok {
+			if e.numericStrings || !isJSONNumber(s) {
+				return s
+			}
+			return json.Number(s)
+		}
+	case "boolean":
+		switch b := v.(type) {
+		case int64:
+			return b != 0
+		case int32:
+			return b != 0
+		case []byte, string:
+			s, _ := textValue(b)
+			if parsed, err := strconv.ParseBool(s); err == nil {
+				return parsed
+			}
+			return s
+		}
+	case "string(date-time)":
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(time.RFC3339Nano)
+		case []byte, string:
+			s, _ := textValue(t)
+			if parsed, ok := parseTimestamp(s); ok {
+				return parsed.Format(time.RFC3339Nano)
+			}
+			return s
+		}
+	case "string(date)":
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(time.DateOnly)
+		case []byte, string:
+			s, _ := textValue(t)
+			if len(s) > len(time.DateOnly) {
+				if _, err := time.Parse(time.DateOnly, s[:len(time.DateOnly)]); err == nil {
+					return s[:len(time.DateOnly)]
+				}
+			}
+			return s
+		}
+	case "string(time)":
+		if t, ok := v.(time.Time); ok {
+			return t.Format("15:04:05.999999999")
+		}
+	case "string(uuid)":
+		switch u := v.(type) {
+		case []byte:
+			if len(u) == 16 {
+				return FormatUUID(u)
+			}
+			return strings.ToLower(string(u))
+		case string:
+			return strings.ToLower(u)
+		}
+	case "string(byte)":
+		if b, ok := v.([]byte); ok {
+			return base64.StdEncoding.EncodeToString(b)
+		}
+	case "object", "array":
+		if s, ok := textValue(v); ok {
+			if json.Valid([]byte(s)) {
+				return json.RawMessage(s)
+			}
+			return s
+		}
+	}
+	return encodeGeneric(v)
+}
+
+// DecodeBinary reverses the base64 encoding of a value written to a binary
+// column, so that a record read from the API can be written back as it was
+// read. Other values are returned unchanged. SQLite columns declared without
+// a type also map to binary but hold text as often as bytes, so their
+// strings are left alone.
+func DecodeBinary(col *model.Column, v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok || col == nil || col.JsonType != "string(byte)" || col.Type == "" {
+		return v, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("expected base64-encoded binary")
+	}
+	return b, nil
+}
+
+// encodeGeneric encodes a value without column metadata.
+func encodeGeneric(v interface{}) interface{} {
+	switch t := v.(type) {
+	case []byte:
+		if utf8.Valid(t) {
+			return string(t)
+		}
+		return base64.StdEncoding.EncodeToString(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// textValue returns v as a string when it is text or bytes.
+func textValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	}
+	return "", false
+}
+
+// isJSONNumber reports whether s is a number in JSON syntax, so that it can
+// be emitted verbatim as a json.Number.
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	if last := s[len(s)-1]; last < '0' || last > '9' {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+// timestampLayouts are the textual timestamp forms drivers return, tried in
+// order. Layouts without a zone are read as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FormatUUID formats 16 bytes in RFC 4122 byte order as a canonical
+// lowercase UUID. Connectors whose drivers use another byte order reorder
+// the bytes first.
+func FormatUUID(b []byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:16])
+	return string(buf[:])
+}
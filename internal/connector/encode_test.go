@@ -0,0 +1,170 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func encodeTestTable() *model.TableSchema {
+	return &model.TableSchema{
+		Name: "items",
+		Columns: []model.Column{
+			{Name: "id", Type: "bigint", JsonType: "integer"},
+			{Name: "price", Type: "numeric", JsonType: "number"},
+			{Name: "ratio", Type: "float8", JsonType: "number"},
+			{Name: "active", Type: "tinyint", JsonType: "boolean"},
+			{Name: "flag", Type: "bool", JsonType: "boolean"},
+			{Name: "created_at", Type: "timestamptz", JsonType: "string(date-time)"},
+			{Name: "logged_at", Type: "datetime", JsonType: "string(date-time)"},
+			{Name: "due", Type: "date", JsonType: "string(date)"},
+			{Name: "ref", Type: "uuid", JsonType: "string(uuid)"},
+			{Name: "data", Type: "bytea", JsonType: "string(byte)"},
+			{Name: "doc", Type: "jsonb", JsonType: "object"},
+			{Name: "note", Type: "text", JsonType: "string"},
+		},
+	}
+}
+
+func TestValueEncoder(t *testing.T) {
+	zone := time.FixedZone("", 2*60*60)
+	row := map[string]interface{}{
+		"id":         []byte("42"),
+		"price":      "12345678901234567890.50",
+		"ratio":      0.25,
+		"active":     int64(1),
+		"flag":       "f",
+		"created_at": time.Date(2024, 5, 1, 10, 0, 0, 500, zone),
+		"logged_at":  []byte("2024-05-01 10:00:00"),
+		"due":        time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"ref":        []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+		"data":       []byte{0x00, 0xff},
+		"doc":        []byte(`{"a": [1, 2]}`),
+		"note":       []byte("café"),
+		"extra":      []byte{0xff, 0xfe},
+		"missing":    nil,
+	}
+	NewValueEncoder(nil, encodeTestTable(), "").EncodeRow(row)
+
+	want := map[string]interface{}{
+		"id":         int64(42),
+		"price":      json.Number("12345678901234567890.50"),
+		"ratio":      0.25,
+		"active":     true,
+		"flag":       false,
+		"created_at": "2024-05-01T10:00:00.0000005+02:00",
+		"logged_at":  "2024-05-01T10:00:00Z",
+		"due":        "2024-05-01",
+		"ref":        "12345678-9abc-def0-1234-56789abcdef0",
+		"data":       "AP8=",
+		"doc":        json.RawMessage(`{"a": [1, 2]}`),
+		"note":       "café",
+		"extra":      "//4=",
+		"missing":    nil,
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(row[k], v) {
+			t.Errorf("%s: expected %#v, got %#v", k, v, row[k])
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"price": row["price"], "doc": row["doc"]})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got := string(b); got != `{"doc":{"a":[1,2]},"price":12345678901234567890.50}` {
+		t.Errorf("unexpected JSON %s", got)
+	}
+}
+
+func TestValueEncoder_NumericString(t *testing.T) {
+	enc := NewValueEncoder(nil, encodeTestTable(), NumericString)
+	row := map[string]interface{}{"price": []byte("12.50"), "ratio": 0.5, "id": int64(7)}
+	enc.EncodeRow(row)
+	if row["price"] != "12.50" {
+		t.Errorf("expected exact decimal string, got %#v", row["price"])
+	}
+	if row["ratio"] != 0.5 || row["id"] != int64(7) {
+		t.Errorf("expected floats and integers to stay numbers, got %#v and %#v", row["ratio"], row["id"])
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	table := encodeTestTable()
+	data, note := &table.Columns[9], &table.Columns[11]
+	enc := NewValueEncoder(nil, table, "")
+	raw := []byte{0, 1, 0xfe, 0xff}
+
+	got, err := DecodeBinary(data, enc.Encode(data, raw))
+	if err != nil || !bytes.Equal(got.([]byte), raw) {
+		t.Errorf("expected the encoded bytes back, got %#v (%v)", got, err)
+	}
+	if _, err := DecodeBinary(data, "not base64!"); err == nil {
+		t.Error("expected an error for invalid base64")
+	}
+	untyped := &model.Column{Name: "any", JsonType: "string(byte)"}
+	for _, tt := range []struct {
+		col *model.Column
+		in  interface{}
+	}{{note, "AAE="}, {untyped, "AAE="}, {data, nil}, {nil, "AAE="}} {
+		if got, err := DecodeBinary(tt.col, tt.in); err != nil || got != tt.in {
+			t.Errorf("%v: expected %#v unchanged, got %#v (%v)", tt.col, tt.in, got, err)
+		}
+	}
+}
+
+func TestValueEncoder_Fallbacks(t *testing.T) {
+	enc := NewValueEncoder(nil, encodeTestTable(), "")
+	for _, tt := range []struct {
+		column string
+		in     interface{}
+		want   interface{}
+	}{
+		{"price", "NaN", "NaN"},
+		{"id", "18446744073709551615", json.Number("18446744073709551615")},
+		{"flag", "maybe", "maybe"},
+		{"logged_at", "yesterday", "yesterday"},
+		{"due", "2024-05-01 00:00:00", "2024-05-01"},
+		{"doc", "not json", "not json"},
+		{"ref", "1234ABCD-0000-0000-0000-000000000000", "1234abcd-0000-0000-0000-000000000000"},
+	} {
+		var col *model.Column
+		for i, c := range encodeTestTable().Columns {
+			if c.Name == tt.column {
+				col = &encodeTestTable().Columns[i]
+			}
+		}
+		if got := enc.Encode(col, tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: expected %#v, got %#v", tt.column, tt.in, tt.want, got)
+		}
+	}
+}
+
+// upperDecoder decodes every "shout" column to upper case.
+type upperDecoder struct{ mockConnector }
+
+func (upperDecoder) DecodeValue(col *model.Column, v interface{}, elem func(*model.Column, interface{}) interface{}) (interface{}, bool) {
+	if col.Type != "shout" {
+		return nil, false
+	}
+	return []interface{}{elem(&model.Column{JsonType: "integer"}, "1"), "LOUD"}, true
+}
+
+func TestValueEncoder_Decoder(t *testing.T) {
+	table := &model.TableSchema{Columns: []model.Column{
+		{Name: "a", Type: "shout", JsonType: "array"},
+		{Name: "b", Type: "text", JsonType: "string"},
+	}}
+	row := map[string]interface{}{"a": "quiet", "b": []byte("quiet")}
+	NewValueEncoder(&upperDecoder{}, table, "").EncodeRow(row)
+	if !reflect.DeepEqual(row["a"], []interface{}{int64(1), "LOUD"}) {
+		t.Errorf("expected decoded array, got %#v", row["a"])
+	}
+	if row["b"] != "quiet" {
+		t.Errorf("expected other columns to use the generic rules, got %#v", row["b"])
+	}
+}
@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BuildKeyClause renders the WHERE fragment that selects rows by primary key.
+// keyColumns defaults to the conventional "id" column when empty. With a
+// single key column each ID is a scalar and the clause is an IN list; with a
+// composite key each ID is a []interface{} holding one value per key column,
+// in keyColumns order, and the clause is an OR of per-row equalities.
+// Placeholders are numbered from startIdx.
+func BuildKeyClause(keyColumns []string, ids []interface{}, quote func(string) string, placeholder func(int) string, startIdx int) (string, []interface{}, error) {
+	if len(keyColumns) == 0 {
+		keyColumns = []string{"id"}
+	}
+	args := make([]interface{}, 0, len(ids)*len(keyColumns))
+	paramIdx := startIdx
+
+	if len(keyColumns) == 1 {
+		placeholders := make([]string, len(ids))
+		for i, id := range ids {
+			placeholders[i] = placeholder(paramIdx)
+			args = append(args, id)
+			paramIdx++
+		}
+		return fmt.Sprintf("%s IN (%s)", quote(keyColumns[0]), strings.Join(placeholders, ", ")), args, nil
+	}
+
+	rows := make([]string, len(ids))
+	for i, id := range ids {
+		tuple, ok := id.([]interface{})
+		if !ok || len(tuple) != len(keyColumns) {
+			return "", nil, fmt.Errorf("composite key (%s) requires %d values per id", strings.Join(keyColumns, ", "), len(keyColumns))
+		}
+		parts := make([]string, len(keyColumns))
+		for j, col := range keyColumns {
+			parts[j] = quote(col) + " = " + placeholder(paramIdx)
+			args = append(args, tuple[j])
+			paramIdx++
+		}
+		rows[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(rows, " OR ") + ")", args, nil
+}
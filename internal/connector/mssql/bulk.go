@@ -0,0 +1,54 @@
+package mssql
+
+import (
+	"context"
+	"fmt"
+
+	mssqldb "github.com/microsoft/go-mssqldb"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+// BulkLoad streams src into the table with the TDS bulk copy protocol
+// inside a single transaction.
+func (c *MSSQLConnector) BulkLoad(ctx context.Context, table string, columns []string, src connector.RowSource) (int64, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("mssql bulk load: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	target := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(table)
+	stmt, err := tx.PrepareContext(ctx, mssqldb.CopyIn(target, mssqldb.BulkOptions{}, columns...))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, err
+		}
+	}
+	if err := src.Err(); err != nil {
+		return 0, err
+	}
+
+	// An Exec without arguments flushes the buffered rows.
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
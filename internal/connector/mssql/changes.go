@@ -0,0 +1,175 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Changes are read from SQL Server change data capture. Enabling CDC needs
+// sysadmin and db_owner rights and the SQL Server Agent, so Faucet does not
+// switch it on itself: EnableChanges only checks that the table is tracked.
+// Offsets are the hex-encoded start LSN and sequence value of a change.
+
+// CDC __$operation values.
+const (
+	cdcDelete       = 1
+	cdcInsert       = 2
+	cdcUpdateBefore = 3
+	cdcUpdateAfter  = 4
+)
+
+// EnableChanges checks that the table is tracked by change data capture.
+func (c *MSSQLConnector) EnableChanges(ctx context.Context, def model.TableSchema) error {
+	const query = `SELECT t.is_tracked_by_cdc FROM sys.tables t
+		JOIN sys.schemas s ON s.schema_id = t.schema_id
+		WHERE s.name = @p1 AND t.name = @p2`
+
+	var tracked bool
+	if err := c.db.QueryRowContext(ctx, query, c.schemaName, def.Name).Scan(&tracked); err != nil {
+		return fmt.Errorf("enable changes on %q: %w", def.Name, err)
+	}
+	if !tracked {
+		return fmt.Errorf("%w: run sys.sp_cdc_enable_table for %s.%s", connector.ErrCaptureNotEnabled, c.schemaName, def.Name)
+	}
+	return nil
+}
+
+// DisableChanges does nothing: CDC is administered in the database.
+func (c *MSSQLConnector) DisableChanges(_ context.Context, _ string) error {
+	return nil
+}
+
+// ReadChanges returns up to limit changes of the table after the given
+// offset from the table's newest capture instance. The before and after
+// images of an update are merged into one change.
+func (c *MSSQLConnector) ReadChanges(ctx context.Context, table, after string, limit int) ([]connector.Change, error) {
+	lsn, seq := make([]byte, 10), make([]byte, 10)
+	if after != "" {
+		var err error
+		if lsn, seq, err = parseCDCOffset(after); err != nil {
+			return nil, err
+		}
+	}
+	ct, err := c.changeTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// An update is two rows; fetch one extra so the last one is complete.
+	q := fmt.Sprintf(`SELECT TOP (%d) sys.fn_cdc_map_lsn_to_time(__$start_lsn) AS __$changed_at, *
+		FROM %s
+		WHERE __$start_lsn > @p1 OR (__$start_lsn = @p1 AND __$seqval > @p2)
+		ORDER BY __$start_lsn, __$seqval, __$operation`, limit+1, ct)
+	rows, err := c.db.QueryxContext(ctx, q, lsn, seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []connector.Change
+	var before map[string]interface{}
+	for rows.Next() {
+		raw := make(map[string]interface{})
+		if err := rows.MapScan(raw); err != nil {
+			return nil, err
+		}
+		startLSN, _ := raw["__$start_lsn"].([]byte)
+		seqval, _ := raw["__$seqval"].([]byte)
+		op, _ := raw["__$operation"].(int64)
+		at, _ := raw["__$changed_at"].(time.Time)
+		row := make(map[string]interface{}, len(raw))
+		for k, v := range raw {
+			if !strings.HasPrefix(k, "__$") {
+				row[k] = v
+			}
+		}
+
+		change := connector.Change{
+			Offset: hex.EncodeToString(startLSN) + ":" + hex.EncodeToString(seqval),
+			Row:    row,
+			Time:   at.UTC(),
+		}
+		switch op {
+		case cdcUpdateBefore:
+			before = row
+			continue
+		case cdcUpdateAfter:
+			change.Op, change.Old, before = connector.ChangeUpdate, before, nil
+		case cdcInsert:
+			change.Op = connector.ChangeInsert
+		case cdcDelete:
+			change.Op = connector.ChangeDelete
+		default:
+			continue
+		}
+		if len(changes) == limit {
+			break
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// LastChangeOffset returns the offset of the table's latest captured change.
+func (c *MSSQLConnector) LastChangeOffset(ctx context.Context, table string) (string, error) {
+	ct, err := c.changeTable(ctx, table)
+	if err != nil {
+		return "", err
+	}
+	var lsn, seq []byte
+	err = c.db.QueryRowContext(ctx, `SELECT TOP 1 __$start_lsn, __$seqval FROM `+ct+`
+		ORDER BY __$start_lsn DESC, __$seqval DESC`).Scan(&lsn, &seq)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(lsn) + ":" + hex.EncodeToString(seq), nil
+}
+
+// PruneChanges does nothing: the CDC cleanup job enforces the retention
+// configured in the database.
+func (c *MSSQLConnector) PruneChanges(_ context.Context, _ time.Time) error {
+	return nil
+}
+
+// changeTable returns the quoted change table of the table's newest capture
+// instance.
+func (c *MSSQLConnector) changeTable(ctx context.Context, table string) (string, error) {
+	const query = `SELECT TOP 1 capture_instance FROM cdc.change_tables
+		WHERE source_object_id = OBJECT_ID(@p1)
+		ORDER BY create_date DESC`
+
+	var instance string
+	err := c.db.QueryRowContext(ctx, query, c.QuoteIdentifier(c.schemaName)+"."+c.QuoteIdentifier(table)).Scan(&instance)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: run sys.sp_cdc_enable_table for %s.%s", connector.ErrCaptureNotEnabled, c.schemaName, table)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "cdc." + c.QuoteIdentifier(instance+"_CT"), nil
+}
+
+// parseCDCOffset splits an offset into its LSN and sequence value.
+func parseCDCOffset(offset string) (lsn, seq []byte, err error) {
+	l, s, ok := strings.Cut(offset, ":")
+	if !ok {
+		return nil, nil, connector.ErrInvalidOffset
+	}
+	if lsn, err = hex.DecodeString(l); err != nil || len(lsn) != 10 {
+		return nil, nil, connector.ErrInvalidOffset
+	}
+	if seq, err = hex.DecodeString(s); err != nil || len(seq) != 10 {
+		return nil, nil, connector.ErrInvalidOffset
+	}
+	return lsn, seq, nil
+}
@@ -0,0 +1,24 @@
+package mssql
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+func TestParseCDCOffset(t *testing.T) {
+	lsn, seq, err := parseCDCOffset("0000002a000001f00003:0000002a000001f00002")
+	if err != nil {
+		t.Fatalf("parseCDCOffset: %v", err)
+	}
+	if !bytes.Equal(lsn, []byte{0, 0, 0, 0x2a, 0, 0, 0x01, 0xf0, 0, 0x03}) || len(seq) != 10 {
+		t.Errorf("got lsn %x, seq %x", lsn, seq)
+	}
+
+	for _, bad := range []string{"42", "0000002a000001f00003", "zz:zz", "0000002a:0000002a000001f00002"} {
+		if _, _, err := parseCDCOffset(bad); err != connector.ErrInvalidOffset {
+			t.Errorf("parseCDCOffset(%q): err = %v", bad, err)
+		}
+	}
+}
@@ -92,6 +92,9 @@ func (c *MSSQLConnector) SupportsReturning() bool { return false }
 // SupportsUpsert indicates that SQL Server supports upsert via MERGE.
 func (c *MSSQLConnector) SupportsUpsert() bool { return true }
 
+// SupportsTransactionalDDL indicates that SQL Server DDL can be rolled back.
+func (c *MSSQLConnector) SupportsTransactionalDDL() bool { return true }
+
 // ParameterPlaceholder returns a SQL Server-style numbered parameter
 // placeholder (e.g., @p1, @p2, @p3).
 func (c *MSSQLConnector) ParameterPlaceholder(index int) string {
@@ -0,0 +1,28 @@
+package mssql
+
+import (
+	"strings"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// MapColumnType maps a result column's type name to Go and JSON types.
+func (c *MSSQLConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	return mapMSSQLType(dbType)
+}
+
+// DecodeValue formats uniqueidentifier columns, which the driver returns as
+// 16 bytes with the first three groups little-endian, as canonical UUIDs.
+func (c *MSSQLConnector) DecodeValue(col *model.Column, v interface{}, _ func(*model.Column, interface{}) interface{}) (interface{}, bool) {
+	b, ok := v.([]byte)
+	if !ok || len(b) != 16 || !strings.EqualFold(col.Type, "uniqueidentifier") {
+		return nil, false
+	}
+	u := make([]byte, 16)
+	u[0], u[1], u[2], u[3] = b[3], b[2], b[1], b[0]
+	u[4], u[5] = b[5], b[4]
+	u[6], u[7] = b[7], b[6]
+	copy(u[8:], b[8:])
+	return connector.FormatUUID(u), true
+}
@@ -0,0 +1,22 @@
+package mssql
+
+import (
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestEncodeUniqueIdentifier(t *testing.T) {
+	table := &model.TableSchema{Columns: []model.Column{
+		{Name: "id", Type: "uniqueidentifier", JsonType: "string(uuid)"},
+	}}
+	// 6F9619FF-8B86-D011-B42D-00C04FC964FF as the driver returns it.
+	row := map[string]interface{}{
+		"id": []byte{0xFF, 0x19, 0x96, 0x6F, 0x86, 0x8B, 0x11, 0xD0, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF},
+	}
+	connector.NewValueEncoder(&MSSQLConnector{}, table, "").EncodeRow(row)
+	if want := "6f9619ff-8b86-d011-b42d-00c04fc964ff"; row["id"] != want {
+		t.Errorf("expected %s, got %v", want, row["id"])
+	}
+}
@@ -22,10 +22,14 @@ func (c *MSSQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 	paramIdx := len(args) + 1
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause
@@ -33,6 +37,10 @@ func (c *MSSQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 	b.WriteString(c.QuoteIdentifier(c.schemaName))
 	b.WriteString(".")
 	b.WriteString(c.QuoteIdentifier(req.Table))
+	if req.Lock {
+		// SQL Server locks rows with table hints rather than FOR UPDATE
+		b.WriteString(" WITH (UPDLOCK, ROWLOCK)")
+	}
 
 	// WHERE clause
 	if req.Filter != "" {
@@ -46,6 +54,12 @@ func (c *MSSQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause (required for OFFSET/FETCH NEXT)
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -90,6 +104,11 @@ func (c *MSSQLConnector) BuildInsert(_ context.Context, req connector.InsertRequ
 	}
 	sortStrings(columns)
 
+	// Upserts are expressed as MERGE; SQL Server has no ON CONFLICT clause.
+	if req.Upsert != nil {
+		return c.buildMerge(req, columns)
+	}
+
 	var b strings.Builder
 	var args []interface{}
 	paramIdx := 1
@@ -133,6 +152,82 @@ func (c *MSSQLConnector) BuildInsert(_ context.Context, req connector.InsertRequ
 	return b.String(), args, nil
 }
 
+// buildMerge constructs the MERGE statement used for upserts. The records
+// become a VALUES-derived source table matched against the target on the
+// conflict columns. HOLDLOCK keeps concurrent upserts of the same key from
+// racing between the match and the insert.
+func (c *MSSQLConnector) buildMerge(req connector.InsertRequest, columns []string) (string, []interface{}, error) {
+	update, err := connector.UpsertUpdateColumns(columns, req.Upsert)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	paramIdx := 1
+
+	quotedCols := make([]string, len(columns))
+	sourceCols := make([]string, len(columns))
+	for i, col := range columns {
+		quotedCols[i] = c.QuoteIdentifier(col)
+		sourceCols[i] = "source." + quotedCols[i]
+	}
+
+	b.WriteString("MERGE INTO ")
+	b.WriteString(c.QuoteIdentifier(c.schemaName))
+	b.WriteString(".")
+	b.WriteString(c.QuoteIdentifier(req.Table))
+	b.WriteString(" WITH (HOLDLOCK) AS target USING (VALUES ")
+	for rowIdx, record := range req.Records {
+		if rowIdx > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for colIdx, col := range columns {
+			if colIdx > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(fmt.Sprintf("@p%d", paramIdx))
+			args = append(args, record[col])
+			paramIdx++
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(") AS source (")
+	b.WriteString(strings.Join(quotedCols, ", "))
+	b.WriteString(")")
+
+	// Match on the conflict columns
+	matches := make([]string, len(req.Upsert.ConflictColumns))
+	for i, col := range req.Upsert.ConflictColumns {
+		quoted := c.QuoteIdentifier(col)
+		matches[i] = "target." + quoted + " = source." + quoted
+	}
+	b.WriteString(" ON ")
+	b.WriteString(strings.Join(matches, " AND "))
+
+	if len(update) > 0 {
+		sets := make([]string, len(update))
+		for i, col := range update {
+			quoted := c.QuoteIdentifier(col)
+			sets[i] = "target." + quoted + " = source." + quoted
+		}
+		b.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		b.WriteString(strings.Join(sets, ", "))
+	}
+
+	b.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	b.WriteString(strings.Join(quotedCols, ", "))
+	b.WriteString(") VALUES (")
+	b.WriteString(strings.Join(sourceCols, ", "))
+	b.WriteString(")")
+
+	// MERGE must be terminated with a semicolon
+	b.WriteString(" OUTPUT INSERTED.*;")
+
+	return b.String(), args, nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
 // Uses OUTPUT INSERTED.* to return updated rows.
 func (c *MSSQLConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
@@ -191,13 +286,11 @@ func (c *MSSQLConnector) BuildUpdate(_ context.Context, req connector.UpdateRequ
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf("@p%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -237,13 +330,11 @@ func (c *MSSQLConnector) BuildDelete(_ context.Context, req connector.DeleteRequ
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf("@p%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -273,11 +364,23 @@ func (c *MSSQLConnector) BuildCount(_ context.Context, req connector.CountReques
 	return b.String(), nil, nil
 }
 
-// CreateTable creates a new table from a TableSchema definition, translating
-// Go/model types to SQL Server column types.
+// CreateTable creates a new table from a TableSchema definition.
 func (c *MSSQLConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition, translating Go/model types to SQL Server column types.
+func (c *MSSQLConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -351,31 +454,39 @@ func (c *MSSQLConnector) CreateTable(ctx context.Context, def model.TableSchema)
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *MSSQLConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
-func (c *MSSQLConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
+func (c *MSSQLConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(tableName)
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToMSSQL(*change.Definition)
 			nullStr := ""
@@ -402,7 +513,7 @@ func (c *MSSQLConnector) AlterTable(ctx context.Context, tableName string, chang
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			// SQL Server uses sp_rename for column renames
 			stmt = fmt.Sprintf("EXEC sp_rename '%s.%s.%s', '%s', 'COLUMN'",
@@ -414,7 +525,7 @@ func (c *MSSQLConnector) AlterTable(ctx context.Context, tableName string, chang
 
 		case "modify_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for modify_column")
+				return nil, fmt.Errorf("column definition required for modify_column")
 			}
 			colType := goTypeToMSSQL(*change.Definition)
 			stmt = fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s",
@@ -424,21 +535,31 @@ func (c *MSSQLConnector) AlterTable(ctx context.Context, tableName string, chang
 			)
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *MSSQLConnector) DropTable(ctx context.Context, tableName string) error {
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("drop table %q: %w", tableName, err)
+	}
+	return nil
+}
+
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *MSSQLConnector) BuildDropTable(tableName string) (string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	stmt := fmt.Sprintf("DROP TABLE %s.%s",
@@ -446,14 +567,16 @@ func (c *MSSQLConnector) DropTable(ctx context.Context, tableName string) error
 		c.QuoteIdentifier(tableName),
 	)
 
-	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("drop table %q: %w", tableName, err)
-	}
-	return nil
+	return stmt, nil
 }
 
 // CallProcedure executes a stored procedure using EXEC notation for SQL Server.
 func (c *MSSQLConnector) CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return c.CallProcedureTx(ctx, c.db, name, params)
+}
+
+// CallProcedureTx is CallProcedure run on exec, which may be a transaction.
+func (c *MSSQLConnector) CallProcedureTx(ctx context.Context, exec connector.QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
 	if name == "" {
 		return nil, fmt.Errorf("procedure name is required")
 	}
@@ -478,7 +601,7 @@ func (c *MSSQLConnector) CallProcedure(ctx context.Context, name string, params
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := c.db.QueryxContext(ctx, query, args...)
+	rows, err := exec.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("call procedure %q: %w", name, err)
 	}
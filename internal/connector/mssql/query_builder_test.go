@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
 )
 
 // newTestConnector creates a MSSQLConnector with a known schema name
@@ -57,6 +58,17 @@ func TestBuildSelect(t *testing.T) {
 			wantSQL:  "SELECT [name] FROM [dbo].[users]",
 			wantArgs: nil,
 		},
+		{
+			name: "select with row lock",
+			req: connector.SelectRequest{
+				Table:      "users",
+				Filter:     "[id] = @p1",
+				FilterArgs: []interface{}{1},
+				Lock:       true,
+			},
+			wantSQL:  "SELECT * FROM [dbo].[users] WITH (UPDLOCK, ROWLOCK) WHERE [id] = @p1",
+			wantArgs: []interface{}{1},
+		},
 		{
 			name: "select with filter",
 			req: connector.SelectRequest{
@@ -790,3 +802,13 @@ func searchSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as MERGE
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    `MERGE INTO [dbo].[users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)) AS source ([email], [id], [name]) ON target.[id] = source.[id] WHEN MATCHED THEN UPDATE SET target.[email] = source.[email], target.[name] = source.[name] WHEN NOT MATCHED THEN INSERT ([email], [id], [name]) VALUES (source.[email], source.[id], source.[name]) OUTPUT INSERTED.*;`,
+		UpdateSubset: `MERGE INTO [dbo].[users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)) AS source ([email], [id], [name]) ON target.[email] = source.[email] WHEN MATCHED THEN UPDATE SET target.[name] = source.[name] WHEN NOT MATCHED THEN INSERT ([email], [id], [name]) VALUES (source.[email], source.[id], source.[name]) OUTPUT INSERTED.*;`,
+		Ignore:       `MERGE INTO [dbo].[users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)) AS source ([email], [id], [name]) ON target.[id] = source.[id] WHEN NOT MATCHED THEN INSERT ([email], [id], [name]) VALUES (source.[email], source.[id], source.[name]) OUTPUT INSERTED.*;`,
+	})
+}
@@ -0,0 +1,128 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Changes are captured by AFTER triggers that append a JSON image of the
+// row to the change log table. Reading the binary log would avoid the
+// triggers but needs REPLICATION SLAVE privileges and a binlog client; the
+// log table works on any server with binlog disabled and on managed
+// services that do not grant replication.
+
+// EnableChanges creates the change log if needed and (re)creates the
+// capture triggers of the table, so that they cover its current columns.
+// MySQL DDL is not transactional, so a failure can leave some of the
+// triggers in place; enabling again repairs them.
+func (c *MySQLConnector) EnableChanges(ctx context.Context, def model.TableSchema) error {
+	cols := connector.CaptureColumns(def)
+	image := func(ref string) string {
+		args := make([]string, 0, 2*len(cols))
+		for _, col := range cols {
+			args = append(args, quoteString(col), ref+"."+c.QuoteIdentifier(col))
+		}
+		return "JSON_OBJECT(" + strings.Join(args, ", ") + ")"
+	}
+
+	logTable := c.QuoteIdentifier(connector.ChangeLogTable)
+	trigger := func(event, op, row, old string) string {
+		return fmt.Sprintf(`CREATE TRIGGER %s AFTER %s ON %s FOR EACH ROW
+			INSERT INTO %s (table_name, op, row_data, old_data, changed_at)
+			VALUES (%s, '%s', %s, %s, ROUND(UNIX_TIMESTAMP(NOW(3)) * 1000))`,
+			c.changeTriggerName(def.Name, op), event, c.QuoteIdentifier(def.Name), logTable,
+			quoteString(def.Name), op, row, old)
+	}
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + logTable + ` (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			table_name VARCHAR(255) NOT NULL,
+			op VARCHAR(10) NOT NULL,
+			row_data JSON,
+			old_data JSON,
+			changed_at BIGINT NOT NULL,
+			INDEX ` + c.QuoteIdentifier(connector.ChangeLogTable+"_table") + ` (table_name, id)
+		)`,
+	}
+	stmts = append(stmts, c.dropChangeTriggers(def.Name)...)
+	stmts = append(stmts,
+		trigger("INSERT", connector.ChangeInsert, image("NEW"), "NULL"),
+		trigger("UPDATE", connector.ChangeUpdate, image("NEW"), image("OLD")),
+		trigger("DELETE", connector.ChangeDelete, image("OLD"), "NULL"),
+	)
+	for _, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("enable changes on %q: %w", def.Name, err)
+		}
+	}
+	return nil
+}
+
+// DisableChanges drops the capture triggers of the table. Changes already
+// logged age out with PruneChanges.
+func (c *MySQLConnector) DisableChanges(ctx context.Context, table string) error {
+	for _, stmt := range c.dropChangeTriggers(table) {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("disable changes on %q: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// ReadChanges returns up to limit logged changes of the table after the
+// given offset.
+func (c *MySQLConnector) ReadChanges(ctx context.Context, table, after string, limit int) ([]connector.Change, error) {
+	return c.changeLog().Read(ctx, table, after, limit)
+}
+
+// LastChangeOffset returns the offset of the table's latest logged change.
+func (c *MySQLConnector) LastChangeOffset(ctx context.Context, table string) (string, error) {
+	return c.changeLog().Last(ctx, table)
+}
+
+// PruneChanges deletes the changes logged before the given time.
+func (c *MySQLConnector) PruneChanges(ctx context.Context, before time.Time) error {
+	return c.changeLog().Prune(ctx, before)
+}
+
+func (c *MySQLConnector) changeLog() connector.ChangeLog {
+	return connector.ChangeLog{
+		DB:          c.db,
+		Table:       c.QuoteIdentifier(connector.ChangeLogTable),
+		Placeholder: c.ParameterPlaceholder,
+	}
+}
+
+func (c *MySQLConnector) dropChangeTriggers(table string) []string {
+	var stmts []string
+	for _, op := range []string{connector.ChangeInsert, connector.ChangeUpdate, connector.ChangeDelete} {
+		stmts = append(stmts, "DROP TRIGGER IF EXISTS "+c.changeTriggerName(table, op))
+	}
+	return stmts
+}
+
+// changeTriggerName names a capture trigger. Trigger names are limited to
+// 64 characters, so long names are truncated and suffixed with a hash of
+// the table name to keep them distinct.
+func (c *MySQLConnector) changeTriggerName(table, op string) string {
+	name := connector.ChangeLogTable + "_" + op + "_" + table
+	if len(name) > 64 {
+		h := fnv.New32a()
+		h.Write([]byte(table))
+		name = fmt.Sprintf("%s_%08x", name[:55], h.Sum32())
+	}
+	return c.QuoteIdentifier(name)
+}
+
+// quoteString renders s as a MySQL string literal, escaping backslashes,
+// which MySQL treats as escapes unless NO_BACKSLASH_ESCAPES is set.
+func quoteString(s string) string {
+	return connector.QuoteLiteral(strings.ReplaceAll(s, `\`, `\\`))
+}
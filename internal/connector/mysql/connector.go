@@ -99,6 +99,10 @@ func (c *MySQLConnector) SupportsReturning() bool { return false }
 // SupportsUpsert indicates that MySQL supports ON DUPLICATE KEY UPDATE (upsert).
 func (c *MySQLConnector) SupportsUpsert() bool { return true }
 
+// SupportsTransactionalDDL indicates that MySQL DDL commits implicitly and
+// cannot be rolled back.
+func (c *MySQLConnector) SupportsTransactionalDDL() bool { return false }
+
 // ParameterPlaceholder returns a MySQL-style positional parameter
 // placeholder (?). MySQL ignores the index.
 func (c *MySQLConnector) ParameterPlaceholder(_ int) string {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/model"
 )
 
@@ -307,6 +308,7 @@ func (c *MySQLConnector) IntrospectTable(ctx context.Context, tableName string)
 func (c *MySQLConnector) GetTableNames(ctx context.Context) ([]string, error) {
 	const query = `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES
 		WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+		AND TABLE_NAME <> '` + connector.ChangeLogTable + `'
 		ORDER BY TABLE_NAME`
 
 	var names []string
@@ -346,7 +348,7 @@ func (c *MySQLConnector) GetStoredProcedures(ctx context.Context) ([]model.Store
 func (c *MySQLConnector) fetchTables(ctx context.Context) ([]tableRow, error) {
 	const query = `SELECT TABLE_NAME, TABLE_TYPE
 		FROM INFORMATION_SCHEMA.TABLES
-		WHERE TABLE_SCHEMA = ?
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME <> '` + connector.ChangeLogTable + `'
 		ORDER BY TABLE_NAME`
 
 	var rows []tableRow
@@ -476,3 +478,8 @@ func mapMySQLType(dataType, columnType string) (goType, jsonType string) {
 		return "interface{}", "string"
 	}
 }
+
+// MapColumnType maps a result column's type name to Go and JSON types.
+func (c *MySQLConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	return mapMySQLType(dbType, "")
+}
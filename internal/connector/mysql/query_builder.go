@@ -21,9 +21,13 @@ func (c *MySQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause
@@ -44,6 +48,12 @@ func (c *MySQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -62,6 +72,11 @@ func (c *MySQLConnector) BuildSelect(_ context.Context, req connector.SelectRequ
 		args = append(args, req.Offset)
 	}
 
+	// Row lock for a read-modify-write transaction
+	if req.Lock {
+		b.WriteString(" FOR UPDATE")
+	}
+
 	return b.String(), args, nil
 }
 
@@ -119,9 +134,40 @@ func (c *MySQLConnector) BuildInsert(_ context.Context, req connector.InsertRequ
 		b.WriteString(")")
 	}
 
+	// ON DUPLICATE KEY UPDATE clause for upserts
+	if req.Upsert != nil {
+		clause, err := c.buildOnDuplicateKey(columns, req.Upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(clause)
+	}
+
 	return b.String(), args, nil
 }
 
+// buildOnDuplicateKey renders the ON DUPLICATE KEY UPDATE clause of an
+// upsert. MySQL matches conflicts on any unique key, so the conflict columns
+// only serve validation. Ignored duplicates use a self-assignment rather than
+// INSERT IGNORE, which would also swallow unrelated errors.
+func (c *MySQLConnector) buildOnDuplicateKey(columns []string, opts *connector.UpsertOptions) (string, error) {
+	update, err := connector.UpsertUpdateColumns(columns, opts)
+	if err != nil {
+		return "", err
+	}
+	if len(update) == 0 {
+		quoted := c.QuoteIdentifier(opts.ConflictColumns[0])
+		return " ON DUPLICATE KEY UPDATE " + quoted + " = " + quoted, nil
+	}
+
+	sets := make([]string, len(update))
+	for i, col := range update {
+		quoted := c.QuoteIdentifier(col)
+		sets[i] = quoted + " = VALUES(" + quoted + ")"
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
 // MySQL does not support RETURNING; callers should check RowsAffected().
 func (c *MySQLConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
@@ -174,12 +220,11 @@ func (c *MySQLConnector) BuildUpdate(_ context.Context, req connector.UpdateRequ
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -218,12 +263,11 @@ func (c *MySQLConnector) BuildDelete(_ context.Context, req connector.DeleteRequ
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -253,11 +297,23 @@ func (c *MySQLConnector) BuildCount(_ context.Context, req connector.CountReques
 	return b.String(), nil, nil
 }
 
-// CreateTable creates a new table from a TableSchema definition, translating
-// Go/model types to MySQL column types.
+// CreateTable creates a new table from a TableSchema definition.
 func (c *MySQLConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition, translating Go/model types to MySQL column types.
+func (c *MySQLConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -335,31 +391,39 @@ func (c *MySQLConnector) CreateTable(ctx context.Context, def model.TableSchema)
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *MySQLConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
-func (c *MySQLConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
+func (c *MySQLConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(tableName)
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToMySQL(*change.Definition)
 			nullStr := ""
@@ -386,7 +450,7 @@ func (c *MySQLConnector) AlterTable(ctx context.Context, tableName string, chang
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			stmt = fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
 				qualifiedTable,
@@ -396,7 +460,7 @@ func (c *MySQLConnector) AlterTable(ctx context.Context, tableName string, chang
 
 		case "modify_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for modify_column")
+				return nil, fmt.Errorf("column definition required for modify_column")
 			}
 			colType := goTypeToMySQL(*change.Definition)
 			stmt = fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s",
@@ -406,21 +470,31 @@ func (c *MySQLConnector) AlterTable(ctx context.Context, tableName string, chang
 			)
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *MySQLConnector) DropTable(ctx context.Context, tableName string) error {
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("drop table %q: %w", tableName, err)
+	}
+	return nil
+}
+
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *MySQLConnector) BuildDropTable(tableName string) (string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	stmt := fmt.Sprintf("DROP TABLE %s.%s",
@@ -428,14 +502,16 @@ func (c *MySQLConnector) DropTable(ctx context.Context, tableName string) error
 		c.QuoteIdentifier(tableName),
 	)
 
-	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("drop table %q: %w", tableName, err)
-	}
-	return nil
+	return stmt, nil
 }
 
 // CallProcedure executes a stored procedure using CALL notation for MySQL.
 func (c *MySQLConnector) CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return c.CallProcedureTx(ctx, c.db, name, params)
+}
+
+// CallProcedureTx is CallProcedure run on exec, which may be a transaction.
+func (c *MySQLConnector) CallProcedureTx(ctx context.Context, exec connector.QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
 	if name == "" {
 		return nil, fmt.Errorf("procedure name is required")
 	}
@@ -460,7 +536,7 @@ func (c *MySQLConnector) CallProcedure(ctx context.Context, name string, params
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := c.db.QueryxContext(ctx, query, args...)
+	rows, err := exec.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("call procedure %q: %w", name, err)
 	}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
 )
 
 // newTestConnector creates a MySQLConnector with a known schema name
@@ -57,6 +58,17 @@ func TestBuildSelect(t *testing.T) {
 			wantSQL:  "SELECT `name` FROM `testdb`.`users`",
 			wantArgs: nil,
 		},
+		{
+			name: "select with row lock",
+			req: connector.SelectRequest{
+				Table:      "users",
+				Filter:     "`id` = ?",
+				FilterArgs: []interface{}{1},
+				Lock:       true,
+			},
+			wantSQL:  "SELECT * FROM `testdb`.`users` WHERE `id` = ? FOR UPDATE",
+			wantArgs: []interface{}{1},
+		},
 		{
 			name: "select with filter",
 			req: connector.SelectRequest{
@@ -718,3 +730,13 @@ func searchSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as ON DUPLICATE KEY UPDATE
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    "INSERT INTO `testdb`.`users` (`email`, `id`, `name`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `email` = VALUES(`email`), `name` = VALUES(`name`)",
+		UpdateSubset: "INSERT INTO `testdb`.`users` (`email`, `id`, `name`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+		Ignore:       "INSERT INTO `testdb`.`users` (`email`, `id`, `name`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `id` = `id`",
+	})
+}
@@ -98,9 +98,13 @@ func (c *OracleConnector) QuoteIdentifier(name string) string {
 // variables, incompatible with the standard query-rows pattern.
 func (c *OracleConnector) SupportsReturning() bool { return false }
 
-// SupportsUpsert indicates that Oracle does NOT support simple upsert syntax.
-// Oracle uses MERGE statements which have a substantially different structure.
-func (c *OracleConnector) SupportsUpsert() bool { return false }
+// SupportsUpsert indicates that Oracle supports upserts. BuildInsert renders
+// them as MERGE statements since Oracle has no ON CONFLICT clause.
+func (c *OracleConnector) SupportsUpsert() bool { return true }
+
+// SupportsTransactionalDDL indicates that Oracle DDL commits implicitly and
+// cannot be rolled back.
+func (c *OracleConnector) SupportsTransactionalDDL() bool { return false }
 
 // ParameterPlaceholder returns an Oracle-style numbered parameter
 // placeholder (e.g., :1, :2, :3).
@@ -479,3 +479,11 @@ func mapOracleType(dataType string, dataScale *int) (goType, jsonType string) {
 		return "interface{}", "string"
 	}
 }
+
+// MapColumnType maps a result column's type name to Go and JSON types. The
+// scale of a NUMBER result column is unknown, so it maps to number rather
+// than integer.
+func (c *OracleConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	scale := -1
+	return mapOracleType(dbType, &scale)
+}
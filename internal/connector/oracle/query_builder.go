@@ -22,10 +22,14 @@ func (c *OracleConnector) BuildSelect(_ context.Context, req connector.SelectReq
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 	paramIdx := len(args) + 1
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause
@@ -46,6 +50,12 @@ func (c *OracleConnector) BuildSelect(_ context.Context, req connector.SelectReq
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -71,6 +81,11 @@ func (c *OracleConnector) BuildSelect(_ context.Context, req connector.SelectReq
 		paramIdx++ //nolint:ineffassign // keep paramIdx consistent for future clauses
 	}
 
+	// Row lock for a read-modify-write transaction
+	if req.Lock {
+		b.WriteString(" FOR UPDATE")
+	}
+
 	return b.String(), args, nil
 }
 
@@ -95,6 +110,11 @@ func (c *OracleConnector) BuildInsert(_ context.Context, req connector.InsertReq
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(req.Table)
 
+	// Upserts are expressed as MERGE; Oracle has no ON CONFLICT clause.
+	if req.Upsert != nil {
+		return c.buildMerge(qualifiedTable, req, columns)
+	}
+
 	var b strings.Builder
 	var args []interface{}
 	paramIdx := 1
@@ -155,6 +175,75 @@ func (c *OracleConnector) BuildInsert(_ context.Context, req connector.InsertReq
 	return b.String(), args, nil
 }
 
+// buildMerge constructs the MERGE statement used for upserts. Each record
+// becomes a SELECT ... FROM DUAL row of the source, matched against the
+// target on the conflict columns.
+func (c *OracleConnector) buildMerge(qualifiedTable string, req connector.InsertRequest, columns []string) (string, []interface{}, error) {
+	update, err := connector.UpsertUpdateColumns(columns, req.Upsert)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	paramIdx := 1
+
+	quotedCols := make([]string, len(columns))
+	sourceCols := make([]string, len(columns))
+	for i, col := range columns {
+		quotedCols[i] = c.QuoteIdentifier(col)
+		sourceCols[i] = "source." + quotedCols[i]
+	}
+
+	b.WriteString("MERGE INTO ")
+	b.WriteString(qualifiedTable)
+	b.WriteString(" target USING (")
+	for rowIdx, record := range req.Records {
+		if rowIdx > 0 {
+			b.WriteString(" UNION ALL ")
+		}
+		b.WriteString("SELECT ")
+		for colIdx, col := range columns {
+			if colIdx > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(fmt.Sprintf(":%d AS %s", paramIdx, quotedCols[colIdx]))
+			args = append(args, record[col])
+			paramIdx++
+		}
+		b.WriteString(" FROM DUAL")
+	}
+	b.WriteString(") source")
+
+	// Match on the conflict columns
+	matches := make([]string, len(req.Upsert.ConflictColumns))
+	for i, col := range req.Upsert.ConflictColumns {
+		quoted := c.QuoteIdentifier(col)
+		matches[i] = "target." + quoted + " = source." + quoted
+	}
+	b.WriteString(" ON (")
+	b.WriteString(strings.Join(matches, " AND "))
+	b.WriteString(")")
+
+	if len(update) > 0 {
+		sets := make([]string, len(update))
+		for i, col := range update {
+			quoted := c.QuoteIdentifier(col)
+			sets[i] = "target." + quoted + " = source." + quoted
+		}
+		b.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		b.WriteString(strings.Join(sets, ", "))
+	}
+
+	b.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	b.WriteString(strings.Join(quotedCols, ", "))
+	b.WriteString(") VALUES (")
+	b.WriteString(strings.Join(sourceCols, ", "))
+	b.WriteString(")")
+
+	return b.String(), args, nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
 // It supports both filter-based and ID-based updates.
 func (c *OracleConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
@@ -210,13 +299,11 @@ func (c *OracleConnector) BuildUpdate(_ context.Context, req connector.UpdateReq
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf(":%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -255,13 +342,11 @@ func (c *OracleConnector) BuildDelete(_ context.Context, req connector.DeleteReq
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf(":%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -291,11 +376,23 @@ func (c *OracleConnector) BuildCount(_ context.Context, req connector.CountReque
 	return b.String(), nil, nil
 }
 
-// CreateTable creates a new table from a TableSchema definition, translating
-// Go/model types back to Oracle column types.
+// CreateTable creates a new table from a TableSchema definition.
 func (c *OracleConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition, translating Go/model types back to Oracle column types.
+func (c *OracleConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -361,31 +458,39 @@ func (c *OracleConnector) CreateTable(ctx context.Context, def model.TableSchema
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *OracleConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
-func (c *OracleConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
+func (c *OracleConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(tableName)
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToOracle(*change.Definition)
 			nullStr := ""
@@ -412,7 +517,7 @@ func (c *OracleConnector) AlterTable(ctx context.Context, tableName string, chan
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			stmt = fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
 				qualifiedTable,
@@ -422,7 +527,7 @@ func (c *OracleConnector) AlterTable(ctx context.Context, tableName string, chan
 
 		case "modify_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for modify_column")
+				return nil, fmt.Errorf("column definition required for modify_column")
 			}
 			colType := goTypeToOracle(*change.Definition)
 			stmt = fmt.Sprintf("ALTER TABLE %s MODIFY %s %s",
@@ -432,21 +537,31 @@ func (c *OracleConnector) AlterTable(ctx context.Context, tableName string, chan
 			)
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *OracleConnector) DropTable(ctx context.Context, tableName string) error {
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("drop table %q: %w", tableName, err)
+	}
+	return nil
+}
+
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *OracleConnector) BuildDropTable(tableName string) (string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	stmt := fmt.Sprintf("DROP TABLE %s.%s",
@@ -454,14 +569,16 @@ func (c *OracleConnector) DropTable(ctx context.Context, tableName string) error
 		c.QuoteIdentifier(tableName),
 	)
 
-	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("drop table %q: %w", tableName, err)
-	}
-	return nil
+	return stmt, nil
 }
 
 // CallProcedure executes a stored procedure using Oracle's BEGIN ... END; block.
 func (c *OracleConnector) CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return c.CallProcedureTx(ctx, c.db, name, params)
+}
+
+// CallProcedureTx is CallProcedure run on exec, which may be a transaction.
+func (c *OracleConnector) CallProcedureTx(ctx context.Context, exec connector.QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
 	if name == "" {
 		return nil, fmt.Errorf("procedure name is required")
 	}
@@ -487,7 +604,7 @@ func (c *OracleConnector) CallProcedure(ctx context.Context, name string, params
 		strings.Join(placeholders, ", "),
 	)
 
-	_, err := c.db.ExecContext(ctx, query, args...)
+	_, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("call procedure %q: %w", name, err)
 	}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
 )
 
 // newTestConnector creates an OracleConnector with a known schema name
@@ -58,6 +59,17 @@ func TestBuildSelect(t *testing.T) {
 			wantSQL:  `SELECT "name" FROM "TESTUSER"."users"`,
 			wantArgs: nil,
 		},
+		{
+			name: "select with row lock",
+			req: connector.SelectRequest{
+				Table:      "users",
+				Filter:     `"id" = :1`,
+				FilterArgs: []interface{}{1},
+				Lock:       true,
+			},
+			wantSQL:  `SELECT * FROM "TESTUSER"."users" WHERE "id" = :1 FOR UPDATE`,
+			wantArgs: []interface{}{1},
+		},
 		{
 			name: "select with filter",
 			req: connector.SelectRequest{
@@ -565,9 +577,9 @@ func TestOracleDialect(t *testing.T) {
 		}
 	})
 
-	t.Run("SupportsUpsert is false", func(t *testing.T) {
-		if c.SupportsUpsert() {
-			t.Error("expected SupportsUpsert() == false")
+	t.Run("SupportsUpsert is true", func(t *testing.T) {
+		if !c.SupportsUpsert() {
+			t.Error("expected SupportsUpsert() == true")
 		}
 	})
 
@@ -682,3 +694,13 @@ func TestBuildSelectPagination(t *testing.T) {
 		t.Errorf("unexpected args: %v", args)
 	}
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as MERGE
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    `MERGE INTO "TESTUSER"."users" target USING (SELECT :1 AS "email", :2 AS "id", :3 AS "name" FROM DUAL UNION ALL SELECT :4 AS "email", :5 AS "id", :6 AS "name" FROM DUAL) source ON (target."id" = source."id") WHEN MATCHED THEN UPDATE SET target."email" = source."email", target."name" = source."name" WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+		UpdateSubset: `MERGE INTO "TESTUSER"."users" target USING (SELECT :1 AS "email", :2 AS "id", :3 AS "name" FROM DUAL UNION ALL SELECT :4 AS "email", :5 AS "id", :6 AS "name" FROM DUAL) source ON (target."email" = source."email") WHEN MATCHED THEN UPDATE SET target."name" = source."name" WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+		Ignore:       `MERGE INTO "TESTUSER"."users" target USING (SELECT :1 AS "email", :2 AS "id", :3 AS "name" FROM DUAL UNION ALL SELECT :4 AS "email", :5 AS "id", :6 AS "name" FROM DUAL) source ON (target."id" = source."id") WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+	})
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+// BulkLoad streams src into the table with COPY FROM STDIN inside a single
+// transaction on one pooled connection.
+func (c *PostgresConnector) BulkLoad(ctx context.Context, table string, columns []string, src connector.RowSource) (int64, error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("postgres bulk load: %w", err)
+	}
+	defer conn.Close()
+
+	var n int64
+	err = conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("postgres bulk load: unexpected driver connection %T", driverConn)
+		}
+		tx, err := sc.Conn().Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		n, err = tx.CopyFrom(ctx, pgx.Identifier{c.schemaName, table}, columns, src)
+		if err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
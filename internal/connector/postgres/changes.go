@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Changes are captured by a row trigger that appends a JSONB image of the
+// row to the change log table and wakes readers with NOTIFY, so streams
+// neither poll nor need the wal_level=logical and replication privileges
+// that logical decoding would.
+
+// changeChannel is the NOTIFY channel; the payload is the table name.
+const changeChannel = "faucet_changes"
+
+// captureFunction is the trigger function shared by every captured table.
+const captureFunction = "_faucet_capture_change"
+
+// EnableChanges creates the change log and capture function if needed and
+// (re)creates the capture trigger of the table. Binary columns, passed to
+// the trigger as arguments, are left out of the row images.
+func (c *PostgresConnector) EnableChanges(ctx context.Context, def model.TableSchema) error {
+	var skip []string
+	captured := make(map[string]bool)
+	for _, col := range connector.CaptureColumns(def) {
+		captured[col] = true
+	}
+	for _, col := range def.Columns {
+		if !captured[col.Name] {
+			skip = append(skip, connector.QuoteLiteral(col.Name))
+		}
+	}
+
+	logTable := c.qualified(connector.ChangeLogTable)
+	table := c.qualified(def.Name)
+	trigger := c.QuoteIdentifier(connector.ChangeLogTable)
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + logTable + ` (
+			id BIGSERIAL PRIMARY KEY,
+			table_name TEXT NOT NULL,
+			op TEXT NOT NULL,
+			row_data JSONB,
+			old_data JSONB,
+			changed_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + c.QuoteIdentifier(connector.ChangeLogTable+"_table") +
+			` ON ` + logTable + ` (table_name, id)`,
+		`CREATE OR REPLACE FUNCTION ` + c.qualified(captureFunction) + `() RETURNS trigger
+		LANGUAGE plpgsql AS $$
+		DECLARE
+			new_row JSONB;
+			old_row JSONB;
+		BEGIN
+			IF TG_OP <> 'DELETE' THEN
+				new_row := to_jsonb(NEW) - COALESCE(TG_ARGV, '{}'::text[]);
+			END IF;
+			IF TG_OP <> 'INSERT' THEN
+				old_row := to_jsonb(OLD) - COALESCE(TG_ARGV, '{}'::text[]);
+			END IF;
+			INSERT INTO ` + logTable + ` (table_name, op, row_data, old_data, changed_at)
+			VALUES (TG_TABLE_NAME, lower(TG_OP), COALESCE(new_row, old_row),
+				CASE WHEN TG_OP = 'UPDATE' THEN old_row END,
+				(extract(epoch FROM clock_timestamp()) * 1000)::bigint);
+			PERFORM pg_notify('` + changeChannel + `', TG_TABLE_NAME);
+			RETURN NULL;
+		END
+		$$`,
+		`DROP TRIGGER IF EXISTS ` + trigger + ` ON ` + table,
+		`CREATE TRIGGER ` + trigger + ` AFTER INSERT OR UPDATE OR DELETE ON ` + table +
+			` FOR EACH ROW EXECUTE PROCEDURE ` + c.qualified(captureFunction) + `(` + strings.Join(skip, ", ") + `)`,
+	}
+
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("enable changes on %q: %w", def.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DisableChanges drops the capture trigger of the table. Changes already
+// logged age out with PruneChanges.
+func (c *PostgresConnector) DisableChanges(ctx context.Context, table string) error {
+	stmt := `DROP TRIGGER IF EXISTS ` + c.QuoteIdentifier(connector.ChangeLogTable) + ` ON ` + c.qualified(table)
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("disable changes on %q: %w", table, err)
+	}
+	return nil
+}
+
+// ReadChanges returns up to limit logged changes of the table after the
+// given offset.
+func (c *PostgresConnector) ReadChanges(ctx context.Context, table, after string, limit int) ([]connector.Change, error) {
+	return c.changeLog().Read(ctx, table, after, limit)
+}
+
+// LastChangeOffset returns the offset of the table's latest logged change.
+func (c *PostgresConnector) LastChangeOffset(ctx context.Context, table string) (string, error) {
+	return c.changeLog().Last(ctx, table)
+}
+
+// PruneChanges deletes the changes logged before the given time.
+func (c *PostgresConnector) PruneChanges(ctx context.Context, before time.Time) error {
+	return c.changeLog().Prune(ctx, before)
+}
+
+// ListenChanges holds a pooled connection in LISTEN and calls fn with the
+// table named by each notification. The connection is discarded afterwards
+// rather than returned to the pool still listening.
+func (c *PostgresConnector) ListenChanges(ctx context.Context, fn func(table string)) error {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres listen: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("postgres listen: unexpected driver connection %T", driverConn)
+		}
+		pc := sc.Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+changeChannel); err != nil {
+			return errors.Join(err, driver.ErrBadConn)
+		}
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+			fn(n.Payload)
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *PostgresConnector) changeLog() connector.ChangeLog {
+	return connector.ChangeLog{
+		DB:          c.db,
+		Table:       c.qualified(connector.ChangeLogTable),
+		Placeholder: c.ParameterPlaceholder,
+	}
+}
+
+// qualified returns the schema-qualified, quoted name of an object.
+func (c *PostgresConnector) qualified(name string) string {
+	return c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(name)
+}
@@ -91,6 +91,9 @@ func (c *PostgresConnector) SupportsReturning() bool { return true }
 // SupportsUpsert indicates that PostgreSQL supports ON CONFLICT (upsert).
 func (c *PostgresConnector) SupportsUpsert() bool { return true }
 
+// SupportsTransactionalDDL indicates that PostgreSQL DDL can be rolled back.
+func (c *PostgresConnector) SupportsTransactionalDDL() bool { return true }
+
 // ParameterPlaceholder returns a PostgreSQL-style numbered parameter
 // placeholder (e.g., $1, $2, $3).
 func (c *PostgresConnector) ParameterPlaceholder(index int) string {
@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// MapColumnType maps a result column's type name, as pgx reports it (the
+// upper-cased type name, with a leading underscore for arrays), to Go and
+// JSON types.
+func (c *PostgresConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	udt := strings.ToLower(dbType)
+	if strings.HasPrefix(udt, "_") {
+		return mapPostgresType(udt, "ARRAY")
+	}
+	return mapPostgresType(udt, "")
+}
+
+// DecodeValue decodes array columns, which pgx returns as array literals
+// such as {1,2,NULL}, into JSON arrays whose elements are encoded as the
+// element type.
+func (c *PostgresConnector) DecodeValue(col *model.Column, v interface{}, elem func(*model.Column, interface{}) interface{}) (interface{}, bool) {
+	if col.JsonType != "array" {
+		return nil, false
+	}
+	var literal string
+	switch t := v.(type) {
+	case string:
+		literal = t
+	case []byte:
+		literal = string(t)
+	default:
+		return nil, false
+	}
+	values, err := parseArrayLiteral(literal)
+	if err != nil {
+		return nil, false
+	}
+
+	elemType := strings.TrimPrefix(strings.ToLower(col.Type), "_")
+	goType, jsonType := mapPostgresType(elemType, "")
+	elemCol := &model.Column{Name: col.Name, Type: elemType, GoType: goType, JsonType: jsonType}
+	return encodeArray(values, elemCol, elem), true
+}
+
+// encodeArray encodes the elements of a parsed array literal, recursing into
+// the sub-arrays of multidimensional arrays.
+func encodeArray(values []interface{}, col *model.Column, elem func(*model.Column, interface{}) interface{}) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		if sub, ok := v.([]interface{}); ok {
+			out[i] = encodeArray(sub, col, elem)
+			continue
+		}
+		out[i] = elem(col, v)
+	}
+	return out
+}
+
+// parseArrayLiteral parses the text form of a PostgreSQL array into nested
+// slices of strings, with nil for NULL elements. An optional dimension
+// decoration such as [0:2]= is skipped.
+func parseArrayLiteral(s string) ([]interface{}, error) {
+	if strings.HasPrefix(s, "[") {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed array literal")
+		}
+		s = s[eq+1:]
+	}
+	p := arrayParser{s: s}
+	values, err := p.array()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q after array literal", p.s[p.pos:])
+	}
+	return values, nil
+}
+
+type arrayParser struct {
+	s   string
+	pos int
+}
+
+func (p *arrayParser) array() ([]interface{}, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '{' {
+		return nil, fmt.Errorf("expected '{' at offset %d", p.pos)
+	}
+	p.pos++
+	values := []interface{}{}
+	if p.pos < len(p.s) && p.s[p.pos] == '}' {
+		p.pos++
+		return values, nil
+	}
+	for {
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array literal")
+		}
+		switch p.s[p.pos] {
+		case '{':
+			sub, err := p.array()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, sub)
+		case '"':
+			v, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		default:
+			v := p.unquoted()
+			if strings.EqualFold(v, "NULL") {
+				values = append(values, nil)
+			} else {
+				values = append(values, v)
+			}
+		}
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array literal")
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return values, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos], p.pos)
+		}
+	}
+}
+
+func (p *arrayParser) quoted() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		ch := p.s[p.pos]
+		switch ch {
+		case '\\':
+			p.pos++
+			if p.pos < len(p.s) {
+				b.WriteByte(p.s[p.pos])
+			}
+		case '"':
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(ch)
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("unterminated quoted array element")
+}
+
+func (p *arrayParser) unquoted() string {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ',' && p.s[p.pos] != '}' {
+		p.pos++
+	}
+	return strings.TrimSpace(p.s[start:p.pos])
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestParseArrayLiteral(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []interface{}
+		wantErr bool
+	}{
+		{in: "{}", want: []interface{}{}},
+		{in: "{1,2,3}", want: []interface{}{"1", "2", "3"}},
+		{in: `{a,"b c",NULL,"NULL","q\"uote\\"}`, want: []interface{}{"a", "b c", nil, "NULL", `q"uote\`}},
+		{in: "{{1,2},{3,4}}", want: []interface{}{[]interface{}{"1", "2"}, []interface{}{"3", "4"}}},
+		{in: "[0:1]={7,8}", want: []interface{}{"7", "8"}},
+		{in: "{1,2", wantErr: true},
+		{in: "{1}x", wantErr: true},
+		{in: "not an array", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseArrayLiteral(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %#v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %#v, got %#v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestEncodeArrays(t *testing.T) {
+	c := newTestConnector()
+	table := &model.TableSchema{Columns: []model.Column{
+		{Name: "ids", Type: "_int4", JsonType: "array"},
+		{Name: "prices", Type: "_numeric", JsonType: "array"},
+		{Name: "flags", Type: "_bool", JsonType: "array"},
+		{Name: "docs", Type: "_jsonb", JsonType: "array"},
+		{Name: "grid", Type: "_text", JsonType: "array"},
+	}}
+	row := map[string]interface{}{
+		"ids":    "{1,NULL,3}",
+		"prices": "{1.50,2}",
+		"flags":  "{t,f}",
+		"docs":   `{"{\"a\": 1}"}`,
+		"grid":   "{{a,b},{c,d}}",
+	}
+	connector.NewValueEncoder(c, table, "").EncodeRow(row)
+
+	want := map[string]interface{}{
+		"ids":    []interface{}{int64(1), nil, int64(3)},
+		"prices": []interface{}{json.Number("1.50"), json.Number("2")},
+		"flags":  []interface{}{true, false},
+		"docs":   []interface{}{json.RawMessage(`{"a": 1}`)},
+		"grid":   []interface{}{[]interface{}{"a", "b"}, []interface{}{"c", "d"}},
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(row[k], v) {
+			t.Errorf("%s: expected %#v, got %#v", k, v, row[k])
+		}
+	}
+}
+
+func TestMapColumnType(t *testing.T) {
+	c := newTestConnector()
+	for dbType, want := range map[string]string{
+		"NUMERIC":     "number",
+		"_INT4":       "array",
+		"JSONB":       "object",
+		"TIMESTAMPTZ": "string(date-time)",
+		"UUID":        "string(uuid)",
+	} {
+		if _, got := c.MapColumnType(dbType); got != want {
+			t.Errorf("%s: expected %s, got %s", dbType, want, got)
+		}
+	}
+}
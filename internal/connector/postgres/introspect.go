@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/model"
 )
 
@@ -311,6 +312,7 @@ func (c *PostgresConnector) IntrospectTable(ctx context.Context, tableName strin
 func (c *PostgresConnector) GetTableNames(ctx context.Context) ([]string, error) {
 	const query = `SELECT table_name FROM information_schema.tables
 		WHERE table_schema = $1 AND table_type = 'BASE TABLE'
+		AND table_name <> '` + connector.ChangeLogTable + `'
 		ORDER BY table_name`
 
 	var names []string
@@ -350,7 +352,7 @@ func (c *PostgresConnector) GetStoredProcedures(ctx context.Context) ([]model.St
 func (c *PostgresConnector) fetchTables(ctx context.Context) ([]tableRow, error) {
 	const query = `SELECT table_name, table_type
 		FROM information_schema.tables
-		WHERE table_schema = $1
+		WHERE table_schema = $1 AND table_name <> '` + connector.ChangeLogTable + `'
 		ORDER BY table_name`
 
 	var rows []tableRow
@@ -21,10 +21,14 @@ func (c *PostgresConnector) BuildSelect(_ context.Context, req connector.SelectR
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 	paramIdx := len(args) + 1
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause
@@ -45,6 +49,12 @@ func (c *PostgresConnector) BuildSelect(_ context.Context, req connector.SelectR
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -65,6 +75,11 @@ func (c *PostgresConnector) BuildSelect(_ context.Context, req connector.SelectR
 		paramIdx++ //nolint:ineffassign // keep paramIdx consistent for future clauses
 	}
 
+	// Row lock for a read-modify-write transaction
+	if req.Lock {
+		b.WriteString(" FOR UPDATE")
+	}
+
 	return b.String(), args, nil
 }
 
@@ -126,16 +141,50 @@ func (c *PostgresConnector) BuildInsert(_ context.Context, req connector.InsertR
 		b.WriteString(")")
 	}
 
+	// ON CONFLICT clause for upserts
+	if req.Upsert != nil {
+		clause, err := c.buildOnConflict(columns, req.Upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(clause)
+	}
+
 	// RETURNING * for PostgreSQL
 	b.WriteString(" RETURNING *")
 
 	return b.String(), args, nil
 }
 
+// buildOnConflict renders the ON CONFLICT clause of an upsert. Conflicting
+// rows are updated from EXCLUDED, or left alone with DO NOTHING when there
+// is nothing to update.
+func (c *PostgresConnector) buildOnConflict(columns []string, opts *connector.UpsertOptions) (string, error) {
+	update, err := connector.UpsertUpdateColumns(columns, opts)
+	if err != nil {
+		return "", err
+	}
+
+	target := make([]string, len(opts.ConflictColumns))
+	for i, col := range opts.ConflictColumns {
+		target[i] = c.QuoteIdentifier(col)
+	}
+	clause := " ON CONFLICT (" + strings.Join(target, ", ") + ")"
+	if len(update) == 0 {
+		return clause + " DO NOTHING", nil
+	}
+
+	sets := make([]string, len(update))
+	for i, col := range update {
+		quoted := c.QuoteIdentifier(col)
+		sets[i] = quoted + " = EXCLUDED." + quoted
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", "), nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
-// It supports both filter-based and ID-based updates. When IDs are provided
-// without a filter, the first primary key column would need to be specified;
-// for now, IDs are applied as an IN clause on the "id" column by convention.
+// It supports both filter-based and ID-based updates. IDs are matched against
+// req.KeyColumns (the "id" column by convention when unset).
 func (c *PostgresConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
 	if req.Table == "" {
 		return "", nil, fmt.Errorf("table name is required")
@@ -189,13 +238,11 @@ func (c *PostgresConnector) BuildUpdate(_ context.Context, req connector.UpdateR
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf("$%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -239,13 +286,11 @@ func (c *PostgresConnector) BuildDelete(_ context.Context, req connector.DeleteR
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = fmt.Sprintf("$%d", paramIdx)
-			args = append(args, id)
-			paramIdx++
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, paramIdx)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -275,11 +320,23 @@ func (c *PostgresConnector) BuildCount(_ context.Context, req connector.CountReq
 	return b.String(), nil, nil
 }
 
-// CreateTable creates a new table from a TableSchema definition, translating
-// Go/model types back to PostgreSQL column types.
+// CreateTable creates a new table from a TableSchema definition.
 func (c *PostgresConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition, translating Go/model types back to PostgreSQL column types.
+func (c *PostgresConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -354,31 +411,39 @@ func (c *PostgresConnector) CreateTable(ctx context.Context, def model.TableSche
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *PostgresConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
-func (c *PostgresConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
+func (c *PostgresConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(tableName)
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToPostgres(*change.Definition)
 			nullStr := ""
@@ -405,7 +470,7 @@ func (c *PostgresConnector) AlterTable(ctx context.Context, tableName string, ch
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			stmt = fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
 				qualifiedTable,
@@ -415,7 +480,7 @@ func (c *PostgresConnector) AlterTable(ctx context.Context, tableName string, ch
 
 		case "modify_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for modify_column")
+				return nil, fmt.Errorf("column definition required for modify_column")
 			}
 			colType := goTypeToPostgres(*change.Definition)
 			stmt = fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s",
@@ -425,37 +490,47 @@ func (c *PostgresConnector) AlterTable(ctx context.Context, tableName string, ch
 			)
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *PostgresConnector) DropTable(ctx context.Context, tableName string) error {
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
 	}
-
-	stmt := fmt.Sprintf("DROP TABLE %s.%s",
-		c.QuoteIdentifier(c.schemaName),
-		c.QuoteIdentifier(tableName),
-	)
-
 	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
 		return fmt.Errorf("drop table %q: %w", tableName, err)
 	}
 	return nil
 }
 
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *PostgresConnector) BuildDropTable(tableName string) (string, error) {
+	if tableName == "" {
+		return "", fmt.Errorf("table name is required")
+	}
+
+	return fmt.Sprintf("DROP TABLE %s.%s",
+		c.QuoteIdentifier(c.schemaName),
+		c.QuoteIdentifier(tableName),
+	), nil
+}
+
 // CallProcedure executes a stored procedure or function using SELECT * FROM
 // notation, which works for PostgreSQL functions that return result sets.
 func (c *PostgresConnector) CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return c.CallProcedureTx(ctx, c.db, name, params)
+}
+
+// CallProcedureTx is CallProcedure run on exec, which may be a transaction.
+func (c *PostgresConnector) CallProcedureTx(ctx context.Context, exec connector.QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
 	if name == "" {
 		return nil, fmt.Errorf("procedure name is required")
 	}
@@ -480,7 +555,7 @@ func (c *PostgresConnector) CallProcedure(ctx context.Context, name string, para
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := c.db.QueryxContext(ctx, query, args...)
+	rows, err := exec.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("call procedure %q: %w", name, err)
 	}
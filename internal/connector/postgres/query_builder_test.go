@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
+	"github.com/faucetdb/faucet/internal/query"
 )
 
 // newTestConnector creates a PostgresConnector with a known schema name
@@ -57,6 +59,17 @@ func TestBuildSelect(t *testing.T) {
 			wantSQL:  `SELECT "name" FROM "public"."users"`,
 			wantArgs: nil,
 		},
+		{
+			name: "select with row lock",
+			req: connector.SelectRequest{
+				Table:      "users",
+				Filter:     `"id" = $1`,
+				FilterArgs: []interface{}{1},
+				Lock:       true,
+			},
+			wantSQL:  `SELECT * FROM "public"."users" WHERE "id" = $1 FOR UPDATE`,
+			wantArgs: []interface{}{1},
+		},
 		{
 			name: "select with filter",
 			req: connector.SelectRequest{
@@ -135,6 +148,24 @@ func TestBuildSelect(t *testing.T) {
 			},
 			wantSQL:  `SELECT "first name", "last name" FROM "public"."users"`,
 			wantArgs: nil,
+		},		{
+			name: "having and distinct number placeholders after filter",
+			req: connector.SelectRequest{
+				Table: "orders",
+				Projection: []query.SelectItem{
+					{Column: "region"},
+					{Func: "COUNT", Column: "customer_id", Alias: "customers", Distinct: true},
+				},
+				Distinct:   true,
+				Filter:     "status = $1",
+				FilterArgs: []interface{}{"paid"},
+				GroupBy:    []string{"region"},
+				Having:     `COUNT(DISTINCT "customer_id") > $2`,
+				HavingArgs: []interface{}{int64(3)},
+				Limit:      10,
+			},
+			wantSQL:  `SELECT DISTINCT "region", COUNT(DISTINCT "customer_id") AS "customers" FROM "public"."orders" WHERE status = $1 GROUP BY "region" HAVING COUNT(DISTINCT "customer_id") > $2 LIMIT $3`,
+			wantArgs: []interface{}{"paid", int64(3), 10},
 		},
 	}
 
@@ -350,6 +381,17 @@ func TestBuildUpdate(t *testing.T) {
 			wantSQL:  `UPDATE "public"."users" SET "name" = $1 WHERE status = 'active' AND "id" IN ($2) RETURNING *`,
 			wantArgs: []interface{}{"Test", 5},
 		},
+		{
+			name: "update with composite ID",
+			req: connector.UpdateRequest{
+				Table:      "stock",
+				Record:     map[string]interface{}{"qty": 7},
+				IDs:        []interface{}{[]interface{}{1, "sku-1"}},
+				KeyColumns: []string{"tenant_id", "sku"},
+			},
+			wantSQL:  `UPDATE "public"."stock" SET "qty" = $1 WHERE (("tenant_id" = $2 AND "sku" = $3)) RETURNING *`,
+			wantArgs: []interface{}{7, 1, "sku-1"},
+		},
 	}
 
 	c := newTestConnector()
@@ -437,6 +479,35 @@ func TestBuildDelete(t *testing.T) {
 			wantSQL:  `DELETE FROM "public"."users" WHERE active = false AND "id" IN ($1, $2)`,
 			wantArgs: []interface{}{10, 20},
 		},
+		{
+			name: "delete with IDs on a named key column",
+			req: connector.DeleteRequest{
+				Table:      "orders",
+				IDs:        []interface{}{"A-1", "A-2"},
+				KeyColumns: []string{"order_no"},
+			},
+			wantSQL:  `DELETE FROM "public"."orders" WHERE "order_no" IN ($1, $2)`,
+			wantArgs: []interface{}{"A-1", "A-2"},
+		},
+		{
+			name: "delete with composite IDs",
+			req: connector.DeleteRequest{
+				Table:      "stock",
+				IDs:        []interface{}{[]interface{}{1, "sku-1"}, []interface{}{2, "sku-2"}},
+				KeyColumns: []string{"tenant_id", "sku"},
+			},
+			wantSQL:  `DELETE FROM "public"."stock" WHERE (("tenant_id" = $1 AND "sku" = $2) OR ("tenant_id" = $3 AND "sku" = $4))`,
+			wantArgs: []interface{}{1, "sku-1", 2, "sku-2"},
+		},
+		{
+			name: "composite IDs with the wrong arity returns error",
+			req: connector.DeleteRequest{
+				Table:      "stock",
+				IDs:        []interface{}{1},
+				KeyColumns: []string{"tenant_id", "sku"},
+			},
+			wantErr: true,
+		},
 	}
 
 	c := newTestConnector()
@@ -709,3 +780,13 @@ func TestBuildSelectParameterIndexing(t *testing.T) {
 		t.Errorf("unexpected args: %v", args)
 	}
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as ON CONFLICT
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    `INSERT INTO "public"."users" ("email", "id", "name") VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT ("id") DO UPDATE SET "email" = EXCLUDED."email", "name" = EXCLUDED."name" RETURNING *`,
+		UpdateSubset: `INSERT INTO "public"."users" ("email", "id", "name") VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" RETURNING *`,
+		Ignore:       `INSERT INTO "public"."users" ("email", "id", "name") VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT ("id") DO NOTHING RETURNING *`,
+	})
+}
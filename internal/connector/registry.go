@@ -13,6 +13,7 @@ type Registry struct {
 	mu        sync.RWMutex
 	factories map[string]Factory
 	active    map[string]Connector // keyed by service name
+	schemas   schemaCache
 }
 
 // NewRegistry creates a new empty Registry.
@@ -51,6 +52,7 @@ func (r *Registry) Connect(serviceName string, cfg ConnectionConfig) error {
 	}
 
 	r.active[serviceName] = conn
+	r.schemas.invalidate(serviceName, "")
 	return nil
 }
 
@@ -78,6 +80,7 @@ func (r *Registry) Disconnect(serviceName string) error {
 
 	err := conn.Disconnect()
 	delete(r.active, serviceName)
+	r.schemas.invalidate(serviceName, "")
 	return err
 }
 
@@ -89,6 +92,7 @@ func (r *Registry) CloseAll() {
 	for name, conn := range r.active {
 		conn.Disconnect()
 		delete(r.active, name)
+		r.schemas.invalidate(name, "")
 	}
 }
 
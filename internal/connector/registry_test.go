@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -17,6 +18,7 @@ type mockConnector struct {
 	connected    bool
 	disconnected bool
 	cfg          ConnectionConfig
+	introspected int
 }
 
 func (m *mockConnector) Connect(cfg ConnectionConfig) error {
@@ -40,8 +42,9 @@ func (m *mockConnector) BeginTx(_ context.Context, _ *sql.TxOptions) (*sqlx.Tx,
 func (m *mockConnector) IntrospectSchema(_ context.Context) (*model.Schema, error) {
 	return nil, nil
 }
-func (m *mockConnector) IntrospectTable(_ context.Context, _ string) (*model.TableSchema, error) {
-	return nil, nil
+func (m *mockConnector) IntrospectTable(_ context.Context, name string) (*model.TableSchema, error) {
+	m.introspected++
+	return &model.TableSchema{Name: name}, nil
 }
 func (m *mockConnector) GetTableNames(_ context.Context) ([]string, error) { return nil, nil }
 func (m *mockConnector) GetStoredProcedures(_ context.Context) ([]model.StoredProcedure, error) {
@@ -67,6 +70,11 @@ func (m *mockConnector) AlterTable(_ context.Context, _ string, _ []SchemaChange
 	return nil
 }
 func (m *mockConnector) DropTable(_ context.Context, _ string) error { return nil }
+func (m *mockConnector) BuildCreateTable(_ model.TableSchema) (string, error) { return "", nil }
+func (m *mockConnector) BuildAlterTable(_ string, _ []SchemaChange) ([]string, error) {
+	return nil, nil
+}
+func (m *mockConnector) BuildDropTable(_ string) (string, error) { return "", nil }
 func (m *mockConnector) CallProcedure(_ context.Context, _ string, _ map[string]interface{}) ([]map[string]interface{}, error) {
 	return nil, nil
 }
@@ -74,6 +82,7 @@ func (m *mockConnector) DriverName() string              { return "mock" }
 func (m *mockConnector) QuoteIdentifier(name string) string { return `"` + name + `"` }
 func (m *mockConnector) SupportsReturning() bool         { return false }
 func (m *mockConnector) SupportsUpsert() bool            { return false }
+func (m *mockConnector) SupportsTransactionalDDL() bool  { return false }
 func (m *mockConnector) ParameterPlaceholder(_ int) string { return "?" }
 
 // ---------------------------------------------------------------------------
@@ -234,3 +243,60 @@ func TestListServices(t *testing.T) {
 		t.Errorf("expected [alpha beta], got %v", services)
 	}
 }
+
+func TestTableSchemaCache(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterDriver("mock", func() Connector { return &mockConnector{} })
+	if err := r.Connect("svc", ConnectionConfig{Driver: "mock"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn, _ := r.Get("svc")
+	mc := conn.(*mockConnector)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ts, err := r.TableSchema(ctx, "svc", "users")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ts.Name != "users" {
+			t.Errorf("expected users, got %s", ts.Name)
+		}
+	}
+	if mc.introspected != 1 {
+		t.Errorf("expected 1 introspection, got %d", mc.introspected)
+	}
+
+	before := r.SchemaVersion("svc")
+	r.InvalidateSchema("svc", "users")
+	if r.SchemaVersion("svc") == before {
+		t.Error("expected invalidation to change the schema version")
+	}
+	r.TableSchema(ctx, "svc", "users")
+	r.TableSchema(ctx, "svc", "orders")
+	if mc.introspected != 3 {
+		t.Errorf("expected 3 introspections after invalidation, got %d", mc.introspected)
+	}
+
+	r.InvalidateSchema("svc", "")
+	r.TableSchema(ctx, "svc", "orders")
+	if mc.introspected != 4 {
+		t.Errorf("expected 4 introspections after service invalidation, got %d", mc.introspected)
+	}
+
+	// A refresh leaves a schema younger than minAge in place.
+	if r.RefreshSchema("svc", "orders", time.Minute) {
+		t.Error("expected a fresh schema to be kept")
+	}
+	if !r.RefreshSchema("svc", "orders", 0) {
+		t.Error("expected a refresh past minAge")
+	}
+	r.TableSchema(ctx, "svc", "orders")
+	if mc.introspected != 5 {
+		t.Errorf("expected 5 introspections after refresh, got %d", mc.introspected)
+	}
+
+	if _, err := r.TableSchema(ctx, "missing", "users"); err == nil {
+		t.Error("expected error for unknown service")
+	}
+}
@@ -0,0 +1,126 @@
+package connector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// schemaCacheTTL bounds how long an introspected table schema is reused.
+// DDL issued through the API invalidates entries immediately; the TTL covers
+// changes made directly against the database.
+const schemaCacheTTL = 5 * time.Minute
+
+// schemaCache memoizes IntrospectTable results per service and table.
+type schemaCache struct {
+	mu       sync.Mutex
+	entries  map[string]map[string]schemaCacheEntry // service -> table -> entry
+	versions map[string]uint64                      // service -> invalidation count
+}
+
+type schemaCacheEntry struct {
+	table   *model.TableSchema
+	fetched time.Time
+}
+
+func (c *schemaCache) get(service, table string) (*model.TableSchema, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[service][table]
+	if !ok || time.Since(e.fetched) > schemaCacheTTL {
+		return nil, false
+	}
+	return e.table, true
+}
+
+func (c *schemaCache) put(service, table string, ts *model.TableSchema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]map[string]schemaCacheEntry)
+	}
+	if c.entries[service] == nil {
+		c.entries[service] = make(map[string]schemaCacheEntry)
+	}
+	c.entries[service][table] = schemaCacheEntry{table: ts, fetched: time.Now()}
+}
+
+// expire drops a table's entry when it is older than minAge, reporting
+// whether there is no longer an entry.
+func (c *schemaCache) expire(service, table string, minAge time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[service][table]
+	if !ok {
+		return true
+	}
+	if time.Since(e.fetched) < minAge {
+		return false
+	}
+	if c.versions == nil {
+		c.versions = make(map[string]uint64)
+	}
+	c.versions[service]++
+	delete(c.entries[service], table)
+	return true
+}
+
+// invalidate drops one table, or every table of the service when table is "".
+func (c *schemaCache) invalidate(service, table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions == nil {
+		c.versions = make(map[string]uint64)
+	}
+	c.versions[service]++
+	if table == "" {
+		delete(c.entries, service)
+		return
+	}
+	delete(c.entries[service], table)
+}
+
+// TableSchema returns the introspected schema of a table, served from a
+// short-lived cache so hot request paths (such as query validation) do not
+// re-introspect on every call. The returned value must not be modified.
+func (r *Registry) TableSchema(ctx context.Context, serviceName, tableName string) (*model.TableSchema, error) {
+	if ts, ok := r.schemas.get(serviceName, tableName); ok {
+		return ts, nil
+	}
+	conn, err := r.Get(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := conn.IntrospectTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	r.schemas.put(serviceName, tableName, ts)
+	return ts, nil
+}
+
+// InvalidateSchema discards cached schema for a table, or for every table of
+// the service when tableName is empty. Call it after DDL.
+func (r *Registry) InvalidateSchema(serviceName, tableName string) {
+	r.schemas.invalidate(serviceName, tableName)
+}
+
+// RefreshSchema discards the cached schema of a table unless it was fetched
+// less than minAge ago, and reports whether the next lookup introspects it
+// again. Call it when a request names a column the cached schema lacks,
+// which may have been added outside the API; minAge keeps such requests
+// from forcing an introspection each.
+func (r *Registry) RefreshSchema(serviceName, tableName string, minAge time.Duration) bool {
+	return r.schemas.expire(serviceName, tableName, minAge)
+}
+
+// SchemaVersion returns a counter that changes whenever cached schema of the
+// service is invalidated, so that values derived from the whole schema (such
+// as a generated GraphQL schema) know when to rebuild.
+func (r *Registry) SchemaVersion(serviceName string) uint64 {
+	r.schemas.mu.Lock()
+	defer r.schemas.mu.Unlock()
+	return r.schemas.versions[serviceName]
+}
@@ -106,9 +106,14 @@ func (c *SnowflakeConnector) QuoteIdentifier(name string) string {
 // SupportsReturning indicates that Snowflake does NOT support RETURNING clauses.
 func (c *SnowflakeConnector) SupportsReturning() bool { return false }
 
-// SupportsUpsert indicates that Snowflake does NOT support standard upsert.
-// Snowflake has MERGE but it requires a staging table pattern.
-func (c *SnowflakeConnector) SupportsUpsert() bool { return false }
+// SupportsUpsert indicates that Snowflake supports upserts. BuildInsert
+// renders them as MERGE statements with the records as an inline VALUES
+// source.
+func (c *SnowflakeConnector) SupportsUpsert() bool { return true }
+
+// SupportsTransactionalDDL indicates that Snowflake DDL commits implicitly
+// and cannot be rolled back.
+func (c *SnowflakeConnector) SupportsTransactionalDDL() bool { return false }
 
 // ParameterPlaceholder returns a Snowflake-style positional parameter
 // placeholder (?). Snowflake ignores the index.
@@ -494,3 +494,8 @@ func mapSnowflakeType(dataType string) (goType, jsonType string) {
 		return "interface{}", "string"
 	}
 }
+
+// MapColumnType maps a result column's type name to Go and JSON types.
+func (c *SnowflakeConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	return mapSnowflakeType(dbType)
+}
@@ -21,9 +21,13 @@ func (c *SnowflakeConnector) BuildSelect(_ context.Context, req connector.Select
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause
@@ -44,6 +48,12 @@ func (c *SnowflakeConnector) BuildSelect(_ context.Context, req connector.Select
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -84,6 +94,11 @@ func (c *SnowflakeConnector) BuildInsert(_ context.Context, req connector.Insert
 	}
 	sortStrings(columns)
 
+	// Upserts are expressed as MERGE; Snowflake has no ON CONFLICT clause.
+	if req.Upsert != nil {
+		return c.buildMerge(req, columns)
+	}
+
 	var b strings.Builder
 	var args []interface{}
 
@@ -122,6 +137,78 @@ func (c *SnowflakeConnector) BuildInsert(_ context.Context, req connector.Insert
 	return b.String(), args, nil
 }
 
+// buildMerge constructs the MERGE statement used for upserts. The records
+// form an inline VALUES source whose positional columns (column1, column2,
+// ...) are aliased back to the table's column names.
+func (c *SnowflakeConnector) buildMerge(req connector.InsertRequest, columns []string) (string, []interface{}, error) {
+	update, err := connector.UpsertUpdateColumns(columns, req.Upsert)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+
+	quotedCols := make([]string, len(columns))
+	aliases := make([]string, len(columns))
+	sourceCols := make([]string, len(columns))
+	for i, col := range columns {
+		quotedCols[i] = c.QuoteIdentifier(col)
+		aliases[i] = fmt.Sprintf("column%d AS %s", i+1, quotedCols[i])
+		sourceCols[i] = "source." + quotedCols[i]
+	}
+
+	b.WriteString("MERGE INTO ")
+	b.WriteString(c.QuoteIdentifier(c.schemaName))
+	b.WriteString(".")
+	b.WriteString(c.QuoteIdentifier(req.Table))
+	b.WriteString(" AS target USING (SELECT ")
+	b.WriteString(strings.Join(aliases, ", "))
+	b.WriteString(" FROM VALUES ")
+	for rowIdx, record := range req.Records {
+		if rowIdx > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for colIdx, col := range columns {
+			if colIdx > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("?")
+			args = append(args, record[col])
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(") AS source")
+
+	// Match on the conflict columns
+	matches := make([]string, len(req.Upsert.ConflictColumns))
+	for i, col := range req.Upsert.ConflictColumns {
+		quoted := c.QuoteIdentifier(col)
+		matches[i] = "target." + quoted + " = source." + quoted
+	}
+	b.WriteString(" ON ")
+	b.WriteString(strings.Join(matches, " AND "))
+
+	if len(update) > 0 {
+		sets := make([]string, len(update))
+		for i, col := range update {
+			quoted := c.QuoteIdentifier(col)
+			sets[i] = "target." + quoted + " = source." + quoted
+		}
+		b.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		b.WriteString(strings.Join(sets, ", "))
+	}
+
+	b.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	b.WriteString(strings.Join(quotedCols, ", "))
+	b.WriteString(") VALUES (")
+	b.WriteString(strings.Join(sourceCols, ", "))
+	b.WriteString(")")
+
+	return b.String(), args, nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
 // Snowflake does not support RETURNING; callers should check RowsAffected().
 func (c *SnowflakeConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
@@ -174,12 +261,11 @@ func (c *SnowflakeConnector) BuildUpdate(_ context.Context, req connector.Update
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -216,12 +302,11 @@ func (c *SnowflakeConnector) BuildDelete(_ context.Context, req connector.Delete
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -251,11 +336,23 @@ func (c *SnowflakeConnector) BuildCount(_ context.Context, req connector.CountRe
 	return b.String(), nil, nil
 }
 
-// CreateTable creates a new table from a TableSchema definition, translating
-// Go/model types to Snowflake column types.
+// CreateTable creates a new table from a TableSchema definition.
 func (c *SnowflakeConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition, translating Go/model types to Snowflake column types.
+func (c *SnowflakeConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -332,31 +429,39 @@ func (c *SnowflakeConnector) CreateTable(ctx context.Context, def model.TableSch
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *SnowflakeConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
-func (c *SnowflakeConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
+func (c *SnowflakeConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
 	qualifiedTable := c.QuoteIdentifier(c.schemaName) + "." + c.QuoteIdentifier(tableName)
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToSnowflake(*change.Definition)
 			nullStr := ""
@@ -383,7 +488,7 @@ func (c *SnowflakeConnector) AlterTable(ctx context.Context, tableName string, c
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			stmt = fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
 				qualifiedTable,
@@ -393,7 +498,7 @@ func (c *SnowflakeConnector) AlterTable(ctx context.Context, tableName string, c
 
 		case "modify_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for modify_column")
+				return nil, fmt.Errorf("column definition required for modify_column")
 			}
 			colType := goTypeToSnowflake(*change.Definition)
 			stmt = fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s",
@@ -403,21 +508,31 @@ func (c *SnowflakeConnector) AlterTable(ctx context.Context, tableName string, c
 			)
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *SnowflakeConnector) DropTable(ctx context.Context, tableName string) error {
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("drop table %q: %w", tableName, err)
+	}
+	return nil
+}
+
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *SnowflakeConnector) BuildDropTable(tableName string) (string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	stmt := fmt.Sprintf("DROP TABLE %s.%s",
@@ -425,14 +540,16 @@ func (c *SnowflakeConnector) DropTable(ctx context.Context, tableName string) er
 		c.QuoteIdentifier(tableName),
 	)
 
-	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("drop table %q: %w", tableName, err)
-	}
-	return nil
+	return stmt, nil
 }
 
 // CallProcedure executes a stored procedure using CALL notation for Snowflake.
 func (c *SnowflakeConnector) CallProcedure(ctx context.Context, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return c.CallProcedureTx(ctx, c.db, name, params)
+}
+
+// CallProcedureTx is CallProcedure run on exec, which may be a transaction.
+func (c *SnowflakeConnector) CallProcedureTx(ctx context.Context, exec connector.QueryExecutor, name string, params map[string]interface{}) ([]map[string]interface{}, error) {
 	if name == "" {
 		return nil, fmt.Errorf("procedure name is required")
 	}
@@ -457,7 +574,7 @@ func (c *SnowflakeConnector) CallProcedure(ctx context.Context, name string, par
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := c.db.QueryxContext(ctx, query, args...)
+	rows, err := exec.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("call procedure %q: %w", name, err)
 	}
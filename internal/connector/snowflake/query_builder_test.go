@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
 )
 
 // newTestConnector creates a SnowflakeConnector with a known schema name
@@ -572,9 +573,9 @@ func TestSnowflakeDialect(t *testing.T) {
 		}
 	})
 
-	t.Run("SupportsUpsert is false", func(t *testing.T) {
-		if c.SupportsUpsert() {
-			t.Error("expected SupportsUpsert() == false")
+	t.Run("SupportsUpsert is true", func(t *testing.T) {
+		if !c.SupportsUpsert() {
+			t.Error("expected SupportsUpsert() == true")
 		}
 	})
 
@@ -742,3 +743,13 @@ func searchSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as MERGE
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    `MERGE INTO "PUBLIC"."users" AS target USING (SELECT column1 AS "email", column2 AS "id", column3 AS "name" FROM VALUES (?, ?, ?), (?, ?, ?)) AS source ON target."id" = source."id" WHEN MATCHED THEN UPDATE SET target."email" = source."email", target."name" = source."name" WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+		UpdateSubset: `MERGE INTO "PUBLIC"."users" AS target USING (SELECT column1 AS "email", column2 AS "id", column3 AS "name" FROM VALUES (?, ?, ?), (?, ?, ?)) AS source ON target."email" = source."email" WHEN MATCHED THEN UPDATE SET target."name" = source."name" WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+		Ignore:       `MERGE INTO "PUBLIC"."users" AS target USING (SELECT column1 AS "email", column2 AS "id", column3 AS "name" FROM VALUES (?, ?, ?), (?, ?, ?)) AS source ON target."id" = source."id" WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (source."email", source."id", source."name")`,
+	})
+}
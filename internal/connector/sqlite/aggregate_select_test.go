@@ -70,6 +70,43 @@ func TestBuildSelectAggregates(t *testing.T) {
 			},
 			wantSQL: `SELECT "region", "status", AVG("amount") AS "avg_amount" FROM "invoices" GROUP BY "region", "status"`,
 		},
+		{
+			name: "having binds after filter args and before limit",
+			req: connector.SelectRequest{
+				Table: "invoices",
+				Projection: []query.SelectItem{
+					{Column: "region"},
+					{Func: "SUM", Column: "amount", Alias: "total"},
+				},
+				Filter:     `status = ?`,
+				FilterArgs: []interface{}{"paid"},
+				GroupBy:    []string{"region"},
+				Having:     `SUM("amount") > ?`,
+				HavingArgs: []interface{}{int64(1000)},
+				Limit:      10,
+			},
+			wantSQL:  `SELECT "region", SUM("amount") AS "total" FROM "invoices" WHERE status = ? GROUP BY "region" HAVING SUM("amount") > ? LIMIT ?`,
+			wantArgs: []interface{}{"paid", int64(1000), 10},
+		},
+		{
+			name: "distinct rows",
+			req: connector.SelectRequest{
+				Table:    "invoices",
+				Fields:   []string{"region"},
+				Distinct: true,
+			},
+			wantSQL: `SELECT DISTINCT "region" FROM "invoices"`,
+		},
+		{
+			name: "count distinct",
+			req: connector.SelectRequest{
+				Table: "invoices",
+				Projection: []query.SelectItem{
+					{Func: "COUNT", Column: "customer_id", Alias: "customers", Distinct: true},
+				},
+			},
+			wantSQL: `SELECT COUNT(DISTINCT "customer_id") AS "customers" FROM "invoices"`,
+		},
 	}
 
 	for _, tt := range tests {
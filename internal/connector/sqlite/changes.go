@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// SQLite has no change feed of its own, so changes are captured by AFTER
+// triggers that append a JSON image of the row to the change log table.
+
+// changeLogDDL creates the change log table and its lookup index.
+var changeLogDDL = []string{
+	`CREATE TABLE IF NOT EXISTS "` + connector.ChangeLogTable + `" (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		op TEXT NOT NULL,
+		row_data TEXT,
+		old_data TEXT,
+		changed_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS "` + connector.ChangeLogTable + `_table" ON "` +
+		connector.ChangeLogTable + `" (table_name, id)`,
+}
+
+// nowMillis is the current time in Unix milliseconds.
+const nowMillis = `CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)`
+
+// EnableChanges creates the change log if needed and (re)creates the
+// capture triggers of the table, so that they cover its current columns.
+func (c *SQLiteConnector) EnableChanges(ctx context.Context, def model.TableSchema) error {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := append([]string{}, changeLogDDL...)
+	stmts = append(stmts, c.dropChangeTriggers(def.Name)...)
+	stmts = append(stmts, c.changeTriggers(def)...)
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("enable changes on %q: %w", def.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DisableChanges drops the capture triggers of the table. Changes already
+// logged age out with PruneChanges.
+func (c *SQLiteConnector) DisableChanges(ctx context.Context, table string) error {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range c.dropChangeTriggers(table) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("disable changes on %q: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReadChanges returns up to limit logged changes of the table after the
+// given offset.
+func (c *SQLiteConnector) ReadChanges(ctx context.Context, table, after string, limit int) ([]connector.Change, error) {
+	return c.changeLog().Read(ctx, table, after, limit)
+}
+
+// LastChangeOffset returns the offset of the table's latest logged change.
+func (c *SQLiteConnector) LastChangeOffset(ctx context.Context, table string) (string, error) {
+	return c.changeLog().Last(ctx, table)
+}
+
+// PruneChanges deletes the changes logged before the given time.
+func (c *SQLiteConnector) PruneChanges(ctx context.Context, before time.Time) error {
+	return c.changeLog().Prune(ctx, before)
+}
+
+func (c *SQLiteConnector) changeLog() connector.ChangeLog {
+	return connector.ChangeLog{
+		DB:          c.db,
+		Table:       c.QuoteIdentifier(connector.ChangeLogTable),
+		Placeholder: c.ParameterPlaceholder,
+	}
+}
+
+// changeTriggers renders the AFTER INSERT, UPDATE and DELETE triggers that
+// log the changes of a table.
+func (c *SQLiteConnector) changeTriggers(def model.TableSchema) []string {
+	cols := connector.CaptureColumns(def)
+	image := func(ref string) string {
+		args := make([]string, 0, 2*len(cols))
+		for _, col := range cols {
+			args = append(args, connector.QuoteLiteral(col), ref+"."+c.QuoteIdentifier(col))
+		}
+		return "json_object(" + strings.Join(args, ", ") + ")"
+	}
+
+	table := c.QuoteIdentifier(def.Name)
+	name := connector.QuoteLiteral(def.Name)
+	logTable := c.QuoteIdentifier(connector.ChangeLogTable)
+	trigger := func(event, op, row, old string) string {
+		return fmt.Sprintf(`CREATE TRIGGER %s AFTER %s ON %s BEGIN
+	INSERT INTO %s (table_name, op, row_data, old_data, changed_at)
+	VALUES (%s, '%s', %s, %s, %s);
+END`, c.changeTriggerName(def.Name, op), event, table, logTable, name, op, row, old, nowMillis)
+	}
+	return []string{
+		trigger("INSERT", connector.ChangeInsert, image("NEW"), "NULL"),
+		trigger("UPDATE", connector.ChangeUpdate, image("NEW"), image("OLD")),
+		trigger("DELETE", connector.ChangeDelete, image("OLD"), "NULL"),
+	}
+}
+
+func (c *SQLiteConnector) dropChangeTriggers(table string) []string {
+	var stmts []string
+	for _, op := range []string{connector.ChangeInsert, connector.ChangeUpdate, connector.ChangeDelete} {
+		stmts = append(stmts, "DROP TRIGGER IF EXISTS "+c.changeTriggerName(table, op))
+	}
+	return stmts
+}
+
+func (c *SQLiteConnector) changeTriggerName(table, op string) string {
+	return c.QuoteIdentifier(connector.ChangeLogTable + "_" + table + "_" + op)
+}
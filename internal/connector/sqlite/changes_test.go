@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+func TestChangeCapture(t *testing.T) {
+	ctx := context.Background()
+	c := &SQLiteConnector{schemaName: "main"}
+	if err := c.Connect(connector.ConnectionConfig{DSN: filepath.Join(t.TempDir(), "changes.db")}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Disconnect()
+
+	if _, err := c.db.ExecContext(ctx, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, photo BLOB)`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	def, err := c.IntrospectTable(ctx, "items")
+	if err != nil {
+		t.Fatalf("IntrospectTable: %v", err)
+	}
+	if err := c.EnableChanges(ctx, *def); err != nil {
+		t.Fatalf("EnableChanges: %v", err)
+	}
+	// Enabling again must replace the triggers rather than fail or double
+	// the log entries.
+	if err := c.EnableChanges(ctx, *def); err != nil {
+		t.Fatalf("EnableChanges (again): %v", err)
+	}
+
+	last, err := c.LastChangeOffset(ctx, "items")
+	if err != nil || last != "" {
+		t.Fatalf("LastChangeOffset on an empty log = %q, %v", last, err)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO items (id, name, price, photo) VALUES (1, 'pen', 1.5, x'00ff')`,
+		`UPDATE items SET name = 'ink pen' WHERE id = 1`,
+		`DELETE FROM items WHERE id = 1`,
+	} {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	changes, err := c.ReadChanges(ctx, "items", "", 10)
+	if err != nil {
+		t.Fatalf("ReadChanges: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3: %+v", len(changes), changes)
+	}
+	ins, upd, del := changes[0], changes[1], changes[2]
+	if ins.Op != connector.ChangeInsert || ins.Row["id"] != int64(1) || ins.Row["price"] != "1.5" || ins.Old != nil {
+		t.Errorf("insert = %+v", ins)
+	}
+	if _, ok := ins.Row["photo"]; ok {
+		t.Errorf("binary column captured: %+v", ins.Row)
+	}
+	if upd.Op != connector.ChangeUpdate || upd.Row["name"] != "ink pen" || upd.Old["name"] != "pen" {
+		t.Errorf("update = %+v", upd)
+	}
+	if del.Op != connector.ChangeDelete || del.Row["name"] != "ink pen" {
+		t.Errorf("delete = %+v", del)
+	}
+	if time.Since(ins.Time) > time.Minute || ins.Time.After(time.Now().Add(time.Second)) {
+		t.Errorf("change time = %v", ins.Time)
+	}
+
+	rest, err := c.ReadChanges(ctx, ins.Offset, "", 10)
+	if err != nil || len(rest) != 0 {
+		t.Errorf("changes of another table = %+v, %v", rest, err)
+	}
+	rest, err = c.ReadChanges(ctx, "items", ins.Offset, 10)
+	if err != nil || len(rest) != 2 || rest[0].Offset != upd.Offset {
+		t.Errorf("ReadChanges after %s = %+v, %v", ins.Offset, rest, err)
+	}
+	if last, _ := c.LastChangeOffset(ctx, "items"); last != del.Offset {
+		t.Errorf("LastChangeOffset = %q, want %q", last, del.Offset)
+	}
+	if _, err := c.ReadChanges(ctx, "items", "nope", 10); err != connector.ErrInvalidOffset {
+		t.Errorf("bad offset: err = %v", err)
+	}
+
+	names, err := c.GetTableNames(ctx)
+	if err != nil || len(names) != 1 || names[0] != "items" {
+		t.Errorf("GetTableNames = %v, %v; the change log must stay hidden", names, err)
+	}
+
+	if err := c.PruneChanges(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PruneChanges: %v", err)
+	}
+	if err := c.DisableChanges(ctx, "items"); err != nil {
+		t.Fatalf("DisableChanges: %v", err)
+	}
+	if _, err := c.db.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (2, 'cap')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if changes, _ := c.ReadChanges(ctx, "items", "", 10); len(changes) != 0 {
+		t.Errorf("changes after prune and disable = %+v", changes)
+	}
+}
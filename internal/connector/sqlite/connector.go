@@ -91,6 +91,9 @@ func (c *SQLiteConnector) SupportsReturning() bool { return true }
 // SupportsUpsert indicates that SQLite supports ON CONFLICT (upsert).
 func (c *SQLiteConnector) SupportsUpsert() bool { return true }
 
+// SupportsTransactionalDDL indicates that SQLite DDL can be rolled back.
+func (c *SQLiteConnector) SupportsTransactionalDDL() bool { return true }
+
 // ParameterPlaceholder returns a SQLite-style positional parameter
 // placeholder (?). SQLite ignores the index.
 func (c *SQLiteConnector) ParameterPlaceholder(_ int) string {
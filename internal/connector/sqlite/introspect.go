@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/model"
 )
 
@@ -52,6 +53,7 @@ func (c *SQLiteConnector) IntrospectSchema(ctx context.Context) (*model.Schema,
 	// Fetch table and view names
 	const query = `SELECT name, type FROM sqlite_master
 		WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+		AND name <> '` + connector.ChangeLogTable + `'
 		ORDER BY name`
 
 	type masterRow struct {
@@ -211,6 +213,7 @@ func (c *SQLiteConnector) IntrospectTable(ctx context.Context, tableName string)
 func (c *SQLiteConnector) GetTableNames(ctx context.Context) ([]string, error) {
 	const query = `SELECT name FROM sqlite_master
 		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		AND name <> '` + connector.ChangeLogTable + `'
 		ORDER BY name`
 
 	var names []string
@@ -289,3 +292,8 @@ func mapSQLiteType(typeName string) (goType, jsonType string) {
 		return "interface{}", "string"
 	}
 }
+
+// MapColumnType maps a result column's declared type to Go and JSON types.
+func (c *SQLiteConnector) MapColumnType(dbType string) (goType, jsonType string) {
+	return mapSQLiteType(dbType)
+}
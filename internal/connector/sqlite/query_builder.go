@@ -20,9 +20,13 @@ func (c *SQLiteConnector) BuildSelect(_ context.Context, req connector.SelectReq
 	var b strings.Builder
 	var args []interface{}
 	args = append(args, req.FilterArgs...)
+	args = append(args, req.HavingArgs...)
 
 	// SELECT clause
 	b.WriteString("SELECT ")
+	if req.Distinct {
+		b.WriteString("DISTINCT ")
+	}
 	b.WriteString(query.BuildSelectList(req.Projection, req.Fields, c.QuoteIdentifier))
 
 	// FROM clause — SQLite doesn't use schema-qualified names for the main db
@@ -41,6 +45,12 @@ func (c *SQLiteConnector) BuildSelect(_ context.Context, req connector.SelectReq
 		b.WriteString(gb)
 	}
 
+	// HAVING clause
+	if req.Having != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(req.Having)
+	}
+
 	// ORDER BY clause
 	if req.Order != "" {
 		b.WriteString(" ORDER BY ")
@@ -113,12 +123,47 @@ func (c *SQLiteConnector) BuildInsert(_ context.Context, req connector.InsertReq
 		b.WriteString(")")
 	}
 
+	// ON CONFLICT clause for upserts
+	if req.Upsert != nil {
+		clause, err := c.buildOnConflict(columns, req.Upsert)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(clause)
+	}
+
 	// RETURNING clause
 	b.WriteString(" RETURNING *")
 
 	return b.String(), args, nil
 }
 
+// buildOnConflict renders the ON CONFLICT clause of an upsert. Conflicting
+// rows are updated from EXCLUDED, or left alone with DO NOTHING when there
+// is nothing to update.
+func (c *SQLiteConnector) buildOnConflict(columns []string, opts *connector.UpsertOptions) (string, error) {
+	update, err := connector.UpsertUpdateColumns(columns, opts)
+	if err != nil {
+		return "", err
+	}
+
+	target := make([]string, len(opts.ConflictColumns))
+	for i, col := range opts.ConflictColumns {
+		target[i] = c.QuoteIdentifier(col)
+	}
+	clause := " ON CONFLICT (" + strings.Join(target, ", ") + ")"
+	if len(update) == 0 {
+		return clause + " DO NOTHING", nil
+	}
+
+	sets := make([]string, len(update))
+	for i, col := range update {
+		quoted := c.QuoteIdentifier(col)
+		sets[i] = quoted + " = EXCLUDED." + quoted
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", "), nil
+}
+
 // BuildUpdate constructs an UPDATE query with parameterized SET values.
 // Includes RETURNING clause since SQLite 3.35+ supports it.
 func (c *SQLiteConnector) BuildUpdate(_ context.Context, req connector.UpdateRequest) (string, []interface{}, error) {
@@ -169,12 +214,11 @@ func (c *SQLiteConnector) BuildUpdate(_ context.Context, req connector.UpdateReq
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -214,12 +258,11 @@ func (c *SQLiteConnector) BuildDelete(_ context.Context, req connector.DeleteReq
 	}
 
 	if len(req.IDs) > 0 {
-		placeholders := make([]string, len(req.IDs))
-		for i, id := range req.IDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+		idClause, idArgs, err := connector.BuildKeyClause(req.KeyColumns, req.IDs, c.QuoteIdentifier, c.ParameterPlaceholder, len(args)+1)
+		if err != nil {
+			return "", nil, err
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", c.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		args = append(args, idArgs...)
 		whereParts = append(whereParts, idClause)
 	}
 
@@ -249,8 +292,21 @@ func (c *SQLiteConnector) BuildCount(_ context.Context, req connector.CountReque
 
 // CreateTable creates a new table from a TableSchema definition.
 func (c *SQLiteConnector) CreateTable(ctx context.Context, def model.TableSchema) error {
+	stmt, err := c.BuildCreateTable(def)
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create table %q: %w", def.Name, err)
+	}
+	return nil
+}
+
+// BuildCreateTable renders the CREATE TABLE statement for a TableSchema
+// definition.
+func (c *SQLiteConnector) BuildCreateTable(def model.TableSchema) (string, error) {
 	if def.Name == "" {
-		return fmt.Errorf("table name is required")
+		return "", fmt.Errorf("table name is required")
 	}
 
 	var b strings.Builder
@@ -323,31 +379,39 @@ func (c *SQLiteConnector) CreateTable(ctx context.Context, def model.TableSchema
 
 	b.WriteString("\n)")
 
-	_, err := c.db.ExecContext(ctx, b.String())
+	return b.String(), nil
+}
+
+// AlterTable applies a list of schema changes to an existing table.
+func (c *SQLiteConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+	stmts, err := c.BuildAlterTable(tableName, changes)
 	if err != nil {
-		return fmt.Errorf("create table %q: %w", def.Name, err)
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("alter table %q (%s %s): %w", tableName, changes[i].Type, changes[i].Column, err)
+		}
 	}
 	return nil
 }
 
-// AlterTable applies a list of schema changes to an existing table.
+// BuildAlterTable renders one ALTER TABLE statement per schema change.
 // Note: SQLite has limited ALTER TABLE support (no DROP COLUMN before 3.35,
 // no MODIFY COLUMN). We use the supported operations where possible.
-func (c *SQLiteConnector) AlterTable(ctx context.Context, tableName string, changes []connector.SchemaChange) error {
+func (c *SQLiteConnector) BuildAlterTable(tableName string, changes []connector.SchemaChange) ([]string, error) {
 	if tableName == "" {
-		return fmt.Errorf("table name is required")
-	}
-	if len(changes) == 0 {
-		return nil
+		return nil, fmt.Errorf("table name is required")
 	}
 
+	stmts := make([]string, 0, len(changes))
 	for _, change := range changes {
 		var stmt string
 
 		switch change.Type {
 		case "add_column":
 			if change.Definition == nil {
-				return fmt.Errorf("column definition required for add_column")
+				return nil, fmt.Errorf("column definition required for add_column")
 			}
 			colType := goTypeToSQLite(*change.Definition)
 			nullStr := ""
@@ -375,7 +439,7 @@ func (c *SQLiteConnector) AlterTable(ctx context.Context, tableName string, chan
 
 		case "rename_column":
 			if change.NewName == "" {
-				return fmt.Errorf("new name required for rename_column")
+				return nil, fmt.Errorf("new name required for rename_column")
 			}
 			// SQLite 3.25.0+ supports RENAME COLUMN
 			stmt = fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
@@ -385,34 +449,41 @@ func (c *SQLiteConnector) AlterTable(ctx context.Context, tableName string, chan
 			)
 
 		case "modify_column":
-			return fmt.Errorf("SQLite does not support MODIFY COLUMN; recreate the table instead")
+			return nil, fmt.Errorf("SQLite does not support MODIFY COLUMN; recreate the table instead")
 
 		default:
-			return fmt.Errorf("unsupported schema change type: %s", change.Type)
+			return nil, fmt.Errorf("unsupported schema change type: %s", change.Type)
 		}
 
-		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
-			return fmt.Errorf("alter table %q (%s %s): %w", tableName, change.Type, change.Column, err)
-		}
+		stmts = append(stmts, stmt)
 	}
 
-	return nil
+	return stmts, nil
 }
 
 // DropTable drops a table from the database.
 func (c *SQLiteConnector) DropTable(ctx context.Context, tableName string) error {
-	if tableName == "" {
-		return fmt.Errorf("table name is required")
+	stmt, err := c.BuildDropTable(tableName)
+	if err != nil {
+		return err
 	}
-
-	stmt := fmt.Sprintf("DROP TABLE %s", c.QuoteIdentifier(tableName))
-
 	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
 		return fmt.Errorf("drop table %q: %w", tableName, err)
 	}
 	return nil
 }
 
+// BuildDropTable renders the DROP TABLE statement for a table.
+func (c *SQLiteConnector) BuildDropTable(tableName string) (string, error) {
+	if tableName == "" {
+		return "", fmt.Errorf("table name is required")
+	}
+
+	stmt := fmt.Sprintf("DROP TABLE %s", c.QuoteIdentifier(tableName))
+
+	return stmt, nil
+}
+
 // CallProcedure is not supported for SQLite (no stored procedures).
 func (c *SQLiteConnector) CallProcedure(_ context.Context, name string, _ map[string]interface{}) ([]map[string]interface{}, error) {
 	return nil, fmt.Errorf("SQLite does not support stored procedures (attempted to call %q)", name)
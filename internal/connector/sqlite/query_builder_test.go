@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/connectortest"
 )
 
 // newTestConnector creates a SQLiteConnector with a known schema name
@@ -786,3 +787,13 @@ func searchSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestBuildInsertUpsert verifies that upsert options render as ON CONFLICT
+// with the default, explicit and ignore-duplicates update sets.
+func TestBuildInsertUpsert(t *testing.T) {
+	connectortest.RunUpsertTests(t, newTestConnector(), connectortest.UpsertSQL{
+		UpdateAll:    `INSERT INTO "users" ("email", "id", "name") VALUES (?, ?, ?), (?, ?, ?) ON CONFLICT ("id") DO UPDATE SET "email" = EXCLUDED."email", "name" = EXCLUDED."name" RETURNING *`,
+		UpdateSubset: `INSERT INTO "users" ("email", "id", "name") VALUES (?, ?, ?), (?, ?, ?) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" RETURNING *`,
+		Ignore:       `INSERT INTO "users" ("email", "id", "name") VALUES (?, ?, ?), (?, ?, ?) ON CONFLICT ("id") DO NOTHING RETURNING *`,
+	})
+}
@@ -0,0 +1,61 @@
+package connector
+
+import "fmt"
+
+// UpsertOptions turns an insert into an upsert. Rows that collide with an
+// existing row on ConflictColumns are updated (or skipped when
+// IgnoreDuplicates is set) instead of failing the statement.
+type UpsertOptions struct {
+	// ConflictColumns identify an existing row, normally the primary key or
+	// the columns of a unique constraint. They must be present in the
+	// inserted records. MySQL ignores them and matches on any unique key.
+	ConflictColumns []string
+	// UpdateColumns restricts which columns are overwritten on conflict.
+	// Empty means every inserted column except the conflict columns.
+	UpdateColumns []string
+	// IgnoreDuplicates leaves conflicting rows untouched.
+	IgnoreDuplicates bool
+}
+
+// UpsertUpdateColumns validates opts against the inserted columns and
+// returns the columns to overwrite on conflict, in the order given by
+// UpdateColumns or, by default, in the order of columns. An empty result
+// means conflicting rows are left untouched.
+func UpsertUpdateColumns(columns []string, opts *UpsertOptions) ([]string, error) {
+	if len(opts.ConflictColumns) == 0 {
+		return nil, fmt.Errorf("upsert requires at least one conflict column")
+	}
+	inserted := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		inserted[col] = true
+	}
+	conflict := make(map[string]bool, len(opts.ConflictColumns))
+	for _, col := range opts.ConflictColumns {
+		if !inserted[col] {
+			return nil, fmt.Errorf("conflict column %q must be present in every record", col)
+		}
+		conflict[col] = true
+	}
+	if opts.IgnoreDuplicates {
+		return nil, nil
+	}
+
+	if len(opts.UpdateColumns) == 0 {
+		update := make([]string, 0, len(columns))
+		for _, col := range columns {
+			if !conflict[col] {
+				update = append(update, col)
+			}
+		}
+		return update, nil
+	}
+	for _, col := range opts.UpdateColumns {
+		if !inserted[col] {
+			return nil, fmt.Errorf("update column %q is not present in the records", col)
+		}
+		if conflict[col] {
+			return nil, fmt.Errorf("update column %q is also a conflict column", col)
+		}
+	}
+	return opts.UpdateColumns, nil
+}
@@ -0,0 +1,453 @@
+// Package export writes query results in columnar formats: Apache Arrow IPC
+// streams and Parquet files. Rows are scanned straight into Arrow record
+// batches typed from the introspected schema, so values keep their types
+// and memory stays bounded by the batch size.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Supported formats.
+const (
+	Arrow   = "arrow"   // Arrow IPC stream
+	Parquet = "parquet" // Parquet file, one row group per record batch
+)
+
+// DefaultBatchSize is the number of rows per record batch when none is given.
+const DefaultBatchSize = 64 * 1024
+
+// Schema metadata keys.
+const (
+	MetaTable      = "faucet.table"
+	MetaDBType     = "faucet.db_type"
+	MetaPrimaryKey = "faucet.primary_key"
+)
+
+// IsFormat reports whether format is one this package writes.
+func IsFormat(format string) bool {
+	return format == Arrow || format == Parquet
+}
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	if format == Parquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "application/vnd.apache.arrow.stream"
+}
+
+// Extension returns the conventional file extension of format.
+func Extension(format string) string {
+	if format == Parquet {
+		return ".parquet"
+	}
+	return ".arrows"
+}
+
+// Rows is the subset of *sql.Rows (and *sqlx.Rows) an export reads.
+type Rows interface {
+	ColumnTypes() ([]*sql.ColumnType, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// Schema builds the Arrow schema of a query's result columns. Columns of
+// table are typed from their introspected Go type and carry their database
+// type in the field metadata; other columns, such as aggregates, are typed
+// from what the driver reports. table may be nil.
+func Schema(table *model.TableSchema, columns []*sql.ColumnType) *arrow.Schema {
+	byName := make(map[string]*model.Column)
+	pk := make(map[string]bool)
+	var meta arrow.Metadata
+	if table != nil {
+		for i := range table.Columns {
+			byName[table.Columns[i].Name] = &table.Columns[i]
+		}
+		for _, name := range table.PrimaryKey {
+			pk[name] = true
+		}
+		meta = arrow.NewMetadata([]string{MetaTable}, []string{table.Name})
+	}
+
+	fields := make([]arrow.Field, len(columns))
+	for i, ct := range columns {
+		name := ct.Name()
+		if col, ok := byName[name]; ok {
+			keys, values := []string{MetaDBType}, []string{col.Type}
+			if pk[name] || col.IsPrimaryKey {
+				keys, values = append(keys, MetaPrimaryKey), append(values, "true")
+			}
+			fields[i] = arrow.Field{
+				Name:     name,
+				Type:     arrowType(col.GoType),
+				Nullable: col.Nullable,
+				Metadata: arrow.NewMetadata(keys, values),
+			}
+			continue
+		}
+		fields[i] = arrow.Field{Name: name, Type: arrowType(scanGoType(ct)), Nullable: true}
+	}
+	return arrow.NewSchema(fields, &meta)
+}
+
+// arrowType maps a model.Column GoType to an Arrow type. Types without a
+// faithful mapping (decimals, JSON, interface{}) are written as strings.
+func arrowType(goType string) arrow.DataType {
+	switch goType {
+	case "int64":
+		return arrow.PrimitiveTypes.Int64
+	case "int32":
+		return arrow.PrimitiveTypes.Int32
+	case "float64":
+		return arrow.PrimitiveTypes.Float64
+	case "float32":
+		return arrow.PrimitiveTypes.Float32
+	case "bool":
+		return arrow.FixedWidthTypes.Boolean
+	case "time.Time":
+		return arrow.FixedWidthTypes.Timestamp_us
+	case "[]byte":
+		return arrow.BinaryTypes.Binary
+	}
+	return arrow.BinaryTypes.String
+}
+
+// scanGoType returns the Go type a driver scans a column into, in the
+// notation of model.Column.GoType.
+func scanGoType(ct *sql.ColumnType) string {
+	t := ct.ScanType()
+	if t == nil {
+		return "string"
+	}
+	switch t.String() {
+	case "int64", "sql.NullInt64", "int", "uint32", "sql.NullInt32", "int32", "int16", "sql.NullInt16", "int8", "uint16", "uint8", "sql.NullByte":
+		return "int64"
+	case "float64", "sql.NullFloat64", "float32":
+		return "float64"
+	case "bool", "sql.NullBool":
+		return "bool"
+	case "time.Time", "sql.NullTime":
+		return "time.Time"
+	}
+	return "string"
+}
+
+// batchWriter is the common interface of the IPC and Parquet writers.
+type batchWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// Writer writes rows to an io.Writer in record batches.
+type Writer struct {
+	schema    *arrow.Schema
+	out       batchWriter
+	builder   *array.RecordBuilder
+	batchSize int
+	pending   int
+}
+
+// NewWriter returns a Writer of format to w. batchSize is the number of rows
+// per record batch (and Parquet row group); zero selects DefaultBatchSize.
+// The caller must Close the writer to complete the output.
+func NewWriter(w io.Writer, format string, schema *arrow.Schema, batchSize int) (*Writer, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	mem := memory.NewGoAllocator()
+
+	var out batchWriter
+	switch format {
+	case Arrow:
+		out = ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	case Parquet:
+		props := parquet.NewWriterProperties(
+			parquet.WithCompression(compress.Codecs.Snappy),
+			parquet.WithAllocator(mem),
+			parquet.WithMaxRowGroupLength(int64(batchSize)),
+		)
+		fw, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema(), pqarrow.WithAllocator(mem)))
+		if err != nil {
+			return nil, fmt.Errorf("create parquet writer: %w", err)
+		}
+		out = fw
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+
+	return &Writer{
+		schema:    schema,
+		out:       out,
+		builder:   array.NewRecordBuilder(mem, schema),
+		batchSize: batchSize,
+	}, nil
+}
+
+// WriteRows scans every row of rows into record batches and writes them. It
+// returns the number of rows written. The columns of rows must be those the
+// writer's schema was built from.
+func (w *Writer) WriteRows(ctx context.Context, rows Rows) (int64, error) {
+	n := len(w.schema.Fields())
+	values := make([]interface{}, n)
+	dest := make([]interface{}, n)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var written int64
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return written, err
+		}
+		for i, v := range values {
+			if err := appendValue(w.builder.Field(i), v); err != nil {
+				return written, fmt.Errorf("row %d, column %q: %w", written+1, w.schema.Field(i).Name, err)
+			}
+		}
+		written++
+		w.pending++
+		if w.pending == w.batchSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return written, err
+	}
+	return written, w.flush()
+}
+
+// flush writes the buffered rows as one record batch.
+func (w *Writer) flush() error {
+	if w.pending == 0 {
+		return nil
+	}
+	rec := w.builder.NewRecord()
+	defer rec.Release()
+	w.pending = 0
+	return w.out.Write(rec)
+}
+
+// Close writes any buffered rows and completes the output: the end of the
+// IPC stream, or the Parquet footer.
+func (w *Writer) Close() error {
+	err := w.flush()
+	w.builder.Release()
+	if cerr := w.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// appendValue appends a scanned database value to b, converting it to the
+// builder's type. Drivers return some types as text (decimals, and times
+// on SQLite), which are parsed.
+func appendValue(b array.Builder, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		n, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *array.Int32Builder:
+		n, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return fmt.Errorf("%d overflows int32", n)
+		}
+		b.Append(int32(n))
+	case *array.Float64Builder:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(f)
+	case *array.Float32Builder:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(float32(f))
+	case *array.BooleanBuilder:
+		t, err := toBool(v)
+		if err != nil {
+			return err
+		}
+		b.Append(t)
+	case *array.TimestampBuilder:
+		t, err := toTime(v)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Timestamp(t.UnixMicro()))
+	case *array.BinaryBuilder:
+		switch v := v.(type) {
+		case []byte:
+			b.Append(v)
+		case string:
+			b.AppendString(v)
+		default:
+			return fmt.Errorf("cannot convert %T to binary", v)
+		}
+	case *array.StringBuilder:
+		b.Append(toString(v))
+	default:
+		return fmt.Errorf("unsupported column type %s", b.Type())
+	}
+	return nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", v)
+		}
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to an integer", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("cannot convert %T to a float", v)
+	}
+	return float64(n), nil
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case []byte:
+		return strconv.ParseBool(string(v))
+	case string:
+		return strconv.ParseBool(v)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return false, fmt.Errorf("cannot convert %T to a boolean", v)
+	}
+	return n != 0, nil
+}
+
+// timeLayouts are the text forms of timestamps that drivers return.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return time.Time{}, fmt.Errorf("cannot convert %T to a timestamp", v)
+	}
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a timestamp", s)
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,238 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+)
+
+func newTestConn(t *testing.T) connector.Connector {
+	t.Helper()
+	conn := sqlite.New()
+	if err := conn.Connect(connector.ConnectionConfig{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Disconnect() })
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TABLE readings (
+			id INTEGER PRIMARY KEY,
+			sensor TEXT NOT NULL,
+			value REAL,
+			ok BOOLEAN,
+			taken_at DATETIME,
+			raw BLOB
+		)`,
+		`INSERT INTO readings VALUES (1, 'a', 1.5, 1, '2024-05-01 10:00:00', x'0102')`,
+		`INSERT INTO readings VALUES (2, 'b', NULL, 0, '2024-05-02T11:30:00Z', NULL)`,
+		`INSERT INTO readings VALUES (3, 'c', -2, NULL, NULL, x'')`,
+	} {
+		if _, err := conn.DB().ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("setup %q: %v", stmt, err)
+		}
+	}
+	return conn
+}
+
+// exportTable exports a query on readings and returns the output and schema.
+func exportTable(t *testing.T, conn connector.Connector, format, sqlStr string, batchSize int) ([]byte, *arrow.Schema) {
+	t.Helper()
+	ctx := context.Background()
+	table, err := conn.IntrospectTable(ctx, "readings")
+	if err != nil {
+		t.Fatalf("introspect: %v", err)
+	}
+	rows, err := conn.DB().QueryContext(ctx, sqlStr)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("column types: %v", err)
+	}
+	schema := Schema(table, cols)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, schema, batchSize)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	n, err := w.WriteRows(ctx, rows)
+	if err != nil {
+		t.Fatalf("WriteRows: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 rows written, got %d", n)
+	}
+	return buf.Bytes(), schema
+}
+
+func TestSchema(t *testing.T) {
+	conn := newTestConn(t)
+	_, schema := exportTable(t, conn, Arrow, "SELECT id, sensor, value, ok, taken_at, raw, COUNT(*) OVER () AS n FROM readings", 0)
+
+	want := []arrow.DataType{
+		arrow.PrimitiveTypes.Int64,
+		arrow.BinaryTypes.String,
+		arrow.PrimitiveTypes.Float64,
+		arrow.FixedWidthTypes.Boolean,
+		arrow.FixedWidthTypes.Timestamp_us,
+		arrow.BinaryTypes.Binary,
+	}
+	for i, dt := range want {
+		if f := schema.Field(i); !arrow.TypeEqual(f.Type, dt) {
+			t.Errorf("field %s: expected %s, got %s", f.Name, dt, f.Type)
+		}
+	}
+	if f := schema.Field(1); f.Nullable {
+		t.Error("expected NOT NULL column to be non-nullable")
+	}
+	if v, _ := schema.Field(0).Metadata.GetValue(MetaPrimaryKey); v != "true" {
+		t.Error("expected primary key metadata on id")
+	}
+	if v, _ := schema.Field(2).Metadata.GetValue(MetaDBType); v != "REAL" {
+		t.Errorf("expected db_type REAL, got %q", v)
+	}
+	if v, _ := schema.Metadata().GetValue(MetaTable); v != "readings" {
+		t.Errorf("expected table metadata, got %q", v)
+	}
+	if f := schema.Field(6); f.Name != "n" || !f.Nullable {
+		t.Errorf("unexpected computed field %v", f)
+	}
+}
+
+func TestWriteArrow(t *testing.T) {
+	conn := newTestConn(t)
+	data, _ := exportTable(t, conn, Arrow, "SELECT id, sensor, value, ok, taken_at, raw FROM readings ORDER BY id", 2)
+
+	r, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ipc.NewReader: %v", err)
+	}
+	defer r.Release()
+
+	var batches, rows int
+	var ids []int64
+	var taken []arrow.Timestamp
+	var nullValue bool
+	for r.Next() {
+		rec := r.Record()
+		batches++
+		rows += int(rec.NumRows())
+		idCol := rec.Column(0).(*array.Int64)
+		for i := 0; i < idCol.Len(); i++ {
+			ids = append(ids, idCol.Value(i))
+		}
+		valueCol := rec.Column(2).(*array.Float64)
+		takenCol := rec.Column(4).(*array.Timestamp)
+		for i := 0; i < takenCol.Len(); i++ {
+			taken = append(taken, takenCol.Value(i))
+		}
+		if batches == 1 {
+			nullValue = valueCol.IsNull(1)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if batches != 2 || rows != 3 {
+		t.Errorf("expected 3 rows in 2 batches, got %d in %d", rows, batches)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+	if !nullValue {
+		t.Error("expected NULL value to be null")
+	}
+	want := time.Date(2024, 5, 2, 11, 30, 0, 0, time.UTC)
+	if len(taken) < 2 || taken[1] != arrow.Timestamp(want.UnixMicro()) {
+		t.Errorf("unexpected timestamps %v", taken)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	conn := newTestConn(t)
+	data, _ := exportTable(t, conn, Parquet, "SELECT id, sensor, value, ok, taken_at, raw FROM readings ORDER BY id", 2)
+
+	pf, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewParquetReader: %v", err)
+	}
+	defer pf.Close()
+	if pf.NumRowGroups() != 2 {
+		t.Errorf("expected 2 row groups, got %d", pf.NumRowGroups())
+	}
+
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatalf("NewFileReader: %v", err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatalf("ReadTable: %v", err)
+	}
+	defer tbl.Release()
+
+	if tbl.NumRows() != 3 {
+		t.Errorf("expected 3 rows, got %d", tbl.NumRows())
+	}
+	if dt := tbl.Schema().Field(4).Type; !arrow.TypeEqual(dt, arrow.FixedWidthTypes.Timestamp_us) {
+		t.Errorf("expected timestamp to round-trip, got %s", dt)
+	}
+	ok := tbl.Column(3).Data().Chunk(0).(*array.Boolean)
+	if !ok.Value(0) || ok.Value(1) {
+		t.Errorf("unexpected booleans %v", ok)
+	}
+}
+
+func TestAppendValueConversions(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "i", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "f", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "s", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+
+	if err := appendValue(b.Field(0), []byte("42")); err != nil {
+		t.Errorf("int from text: %v", err)
+	}
+	if err := appendValue(b.Field(0), int64(1)<<40); err == nil {
+		t.Error("expected int32 overflow error")
+	}
+	if err := appendValue(b.Field(1), []byte("12.50")); err != nil {
+		t.Errorf("decimal text: %v", err)
+	}
+	if err := appendValue(b.Field(1), "abc"); err == nil {
+		t.Error("expected float parse error")
+	}
+	if err := appendValue(b.Field(2), map[string]interface{}{"a": 1}); err != nil {
+		t.Errorf("json value: %v", err)
+	}
+	if got := b.Field(2).(*array.StringBuilder).NewStringArray().Value(0); got != `{"a":1}` {
+		t.Errorf("expected JSON text, got %q", got)
+	}
+}
+
+func TestSchemaWithoutTable(t *testing.T) {
+	if s := Schema(nil, nil); len(s.Fields()) != 0 || s.HasMetadata() {
+		t.Errorf("expected an empty schema, got %v", s)
+	}
+}
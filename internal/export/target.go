@@ -0,0 +1,232 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Target stores the files written by background export jobs. Files are
+// staged in TempDir and handed to Put once complete.
+type Target interface {
+	// TempDir is the directory to stage files in before Put, or "" for the
+	// system default.
+	TempDir() string
+	// Put moves the finished file at localPath to key. The local file is
+	// gone afterwards, whether or not Put succeeds.
+	Put(ctx context.Context, key, localPath string) error
+	// Delete removes the file at key. Deleting a missing file is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Opener is implemented by targets whose files are served by Faucet itself.
+type Opener interface {
+	Open(key string) (*os.File, error)
+}
+
+// Linker is implemented by targets that hand out links for clients to
+// download files from directly.
+type Linker interface {
+	// Link returns a URL for downloading key, valid for ttl, that saves the
+	// file as filename.
+	Link(ctx context.Context, key, filename string, ttl time.Duration) (string, error)
+}
+
+// DirTarget stores export files in a local directory.
+type DirTarget struct {
+	dir string
+}
+
+// NewDirTarget returns a target that stores files in dir, creating it if
+// necessary.
+func NewDirTarget(dir string) (*DirTarget, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create export directory: %w", err)
+	}
+	return &DirTarget{dir: dir}, nil
+}
+
+// TempDir stages files in the target directory, so Put is a rename.
+func (t *DirTarget) TempDir() string { return t.dir }
+
+// Put renames localPath into the directory, copying it when it is on
+// another file system.
+func (t *DirTarget) Put(ctx context.Context, key, localPath string) error {
+	dst, err := t.path(key)
+	if err != nil {
+		os.Remove(localPath)
+		return err
+	}
+	if err := os.Rename(localPath, dst); err == nil {
+		return nil
+	}
+	defer os.Remove(localPath)
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("store export: %w", err)
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("store export: %w", err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("store export: %w", err)
+	}
+	return out.Close()
+}
+
+// Open opens the file at key.
+func (t *DirTarget) Open(key string) (*os.File, error) {
+	p, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Delete removes the file at key.
+func (t *DirTarget) Delete(ctx context.Context, key string) error {
+	p, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete export: %w", err)
+	}
+	return nil
+}
+
+// path returns the file name of key, which must name a file directly in
+// the directory.
+func (t *DirTarget) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid export key %q", key)
+	}
+	return filepath.Join(t.dir, key), nil
+}
+
+// S3Config configures an S3-compatible export target.
+type S3Config struct {
+	Bucket    string
+	Prefix    string // key prefix, e.g. "exports/"
+	Region    string
+	Endpoint  string // custom endpoint of an S3-compatible store; path-style addressing is used
+	AccessKey string // static credentials; the default AWS credential chain is used when empty
+	SecretKey string
+}
+
+// S3Target stores export files in an S3 bucket and hands out presigned
+// download links.
+type S3Target struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	prefix  string
+}
+
+// NewS3Target returns a target that stores files in cfg.Bucket.
+func NewS3Target(ctx context.Context, cfg S3Config) (*S3Target, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 export target needs a bucket")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+	if cfg.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Target{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  cfg.Bucket,
+		prefix:  cfg.Prefix,
+	}, nil
+}
+
+// TempDir stages files in the system temporary directory.
+func (t *S3Target) TempDir() string { return "" }
+
+// Put uploads localPath to key and removes the local file.
+func (t *S3Target) Put(ctx context.Context, key, localPath string) error {
+	defer os.Remove(localPath)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("store export: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("store export: %w", err)
+	}
+	if _, err := t.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(t.bucket),
+		Key:           aws.String(t.key(key)),
+		Body:          f,
+		ContentLength: aws.Int64(info.Size()),
+	}); err != nil {
+		return fmt.Errorf("upload export: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the object at key.
+func (t *S3Target) Delete(ctx context.Context, key string) error {
+	if _, err := t.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(t.bucket),
+		Key:    aws.String(t.key(key)),
+	}); err != nil {
+		return fmt.Errorf("delete export: %w", err)
+	}
+	return nil
+}
+
+// Link returns a presigned GET URL for key.
+func (t *S3Target) Link(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	req, err := t.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(t.bucket),
+		Key:                        aws.String(t.key(key)),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presign export: %w", err)
+	}
+	return req.URL, nil
+}
+
+func (t *S3Target) key(key string) string {
+	if t.prefix == "" {
+		return key
+	}
+	return path.Join(strings.TrimSuffix(t.prefix, "/"), key)
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stageFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	f, err := os.CreateTemp(dir, "staged-*")
+	if err != nil {
+		t.Fatalf("create staged file: %v", err)
+	}
+	f.WriteString(content)
+	f.Close()
+	return f.Name()
+}
+
+func TestDirTarget(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "exports")
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatalf("NewDirTarget: %v", err)
+	}
+	ctx := context.Background()
+
+	staged := stageFile(t, target.TempDir(), "id,name\r\n1,a\r\n")
+	if err := target.Put(ctx, "job.csv", staged); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("expected the staged file to be moved, got %v", err)
+	}
+
+	// Files staged outside the directory are moved in as well.
+	staged = stageFile(t, t.TempDir(), "copied")
+	if err := target.Put(ctx, "other.csv", staged); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	f, err := target.Open("job.csv")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	b, _ := io.ReadAll(f)
+	f.Close()
+	if string(b) != "id,name\r\n1,a\r\n" {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	for _, key := range []string{"", "..", "../escape.csv", "sub/job.csv"} {
+		if _, err := target.Open(key); err == nil || !strings.Contains(err.Error(), "invalid export key") {
+			t.Errorf("%q: expected invalid key error, got %v", key, err)
+		}
+	}
+
+	if err := target.Delete(ctx, "job.csv"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := target.Delete(ctx, "job.csv"); err != nil {
+		t.Errorf("expected deleting a missing file to succeed, got %v", err)
+	}
+	if _, err := target.Open("job.csv"); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be gone, got %v", err)
+	}
+}
+
+// fakeS3 is a minimal stand-in for an S3-compatible store: it keeps the
+// bodies of PUT requests by path and removes them on DELETE.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") == "" {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = string(b)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Target(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string]string)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	target, err := NewS3Target(ctx, S3Config{
+		Bucket:    "exports",
+		Prefix:    "faucet/",
+		Endpoint:  srv.URL,
+		AccessKey: "test",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Target: %v", err)
+	}
+
+	staged := stageFile(t, t.TempDir(), `{"id":1}`+"\n")
+	if err := target.Put(ctx, "job.ndjson", staged); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got := fake.objects["/exports/faucet/job.ndjson"]; got != `{"id":1}`+"\n" {
+		t.Errorf("unexpected object %q (objects: %v)", got, fake.objects)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("expected the staged file to be removed, got %v", err)
+	}
+
+	link, err := target.Link(ctx, "job.ndjson", "orders.ndjson", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	q := u.Query()
+	if u.Path != "/exports/faucet/job.ndjson" || q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Expires") != "300" {
+		t.Errorf("unexpected link %s", link)
+	}
+	if !strings.Contains(q.Get("response-content-disposition"), "orders.ndjson") {
+		t.Errorf("expected the link to name the download, got %s", link)
+	}
+
+	if err := target.Delete(ctx, "job.ndjson"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("expected the object to be deleted, got %v", fake.objects)
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// verbForMethod maps an HTTP method to its role access verb bit.
+func verbForMethod(method string) int {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return model.VerbGet
+	case http.MethodPost:
+		return model.VerbPost
+	case http.MethodPut:
+		return model.VerbPut
+	case http.MethodPatch:
+		return model.VerbPatch
+	case http.MethodDelete:
+		return model.VerbDelete
+	}
+	return 0
+}
+
+// defaultMaxLimit caps the rows a table read returns unless the principal's
+// role sets a max_limit.
+const defaultMaxLimit = 1000
+
+// maxLimit returns the largest limit the request's principal may ask a table
+// read for: its role's max_limit when set, otherwise defaultMaxLimit. List
+// responses are streamed, so a higher cap costs no server memory.
+func maxLimit(ctx context.Context, store *config.Store) int {
+	p := middleware.GetPrincipal(ctx)
+	if p == nil || p.IsAdmin || store == nil {
+		return defaultMaxLimit
+	}
+	role, err := store.GetRole(ctx, p.RoleID)
+	if err != nil || role.MaxLimit <= 0 {
+		return defaultMaxLimit
+	}
+	return role.MaxLimit
+}
+
+// authorizeComponent reports whether the request's principal may perform verb
+// on a service component. Admins are always allowed. API keys need an active
+// role with an access rule whose service is serviceName or "*", whose
+// component is "*" or one of components, and whose verb mask includes verb.
+//
+// Requests without a principal are allowed: they can only reach a handler
+// when it is mounted without the Authenticate middleware.
+func authorizeComponent(ctx context.Context, store *config.Store, serviceName string, components []string, verb int) (bool, error) {
+	p := middleware.GetPrincipal(ctx)
+	if p == nil || p.IsAdmin {
+		return true, nil
+	}
+
+	role, err := store.GetRole(ctx, p.RoleID)
+	if err != nil {
+		if err == config.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !role.IsActive {
+		return false, nil
+	}
+
+	for _, rule := range role.Access {
+		if rule.ServiceName != serviceName && rule.ServiceName != "*" {
+			continue
+		}
+		if rule.VerbMask&verb != 0 && grantsComponent(rule.Component, components) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rowFilter returns the row-level filter the request's principal is bound
+// by on a service component, in filter syntax: a row is visible when it
+// matches the filters of any access rule that grants verb on one of
+// components. It returns "" when the principal is unrestricted, i.e. an
+// admin, or some granting rule has no filters.
+func rowFilter(ctx context.Context, store *config.Store, serviceName string, components []string, verb int) (string, error) {
+	p := middleware.GetPrincipal(ctx)
+	if p == nil || p.IsAdmin {
+		return "", nil
+	}
+	role, err := store.GetRole(ctx, p.RoleID)
+	if err != nil {
+		return "", err
+	}
+
+	var alternatives []string
+	for _, rule := range role.Access {
+		if rule.ServiceName != serviceName && rule.ServiceName != "*" {
+			continue
+		}
+		if rule.VerbMask&verb == 0 || !grantsComponent(rule.Component, components) {
+			continue
+		}
+		if len(rule.Filters) == 0 {
+			return "", nil
+		}
+		join := " AND "
+		if strings.EqualFold(rule.FilterOp, "OR") {
+			join = " OR "
+		}
+		terms := make([]string, len(rule.Filters))
+		for i, f := range rule.Filters {
+			term, err := filterTerm(f)
+			if err != nil {
+				return "", fmt.Errorf("role %q: %w", role.Name, err)
+			}
+			terms[i] = term
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, join)+")")
+	}
+	return strings.Join(alternatives, " OR "), nil
+}
+
+func grantsComponent(component string, components []string) bool {
+	if component == "*" {
+		return true
+	}
+	for _, c := range components {
+		if component == c {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTerm renders a role filter as a filter expression.
+func filterTerm(f model.Filter) (string, error) {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	op := strings.ToUpper(strings.Join(strings.Fields(f.Operator), " "))
+	switch op {
+	case "=", "!=", "<>", ">", ">=", "<", "<=", "LIKE", "NOT LIKE", "CONTAINS", "STARTS WITH", "ENDS WITH":
+		return f.Name + " " + op + " " + quote(f.Value), nil
+	case "IN", "NOT IN":
+		var values []string
+		for _, v := range strings.Split(f.Value, ",") {
+			values = append(values, quote(strings.TrimSpace(v)))
+		}
+		return f.Name + " " + op + " (" + strings.Join(values, ", ") + ")", nil
+	case "IS NULL", "IS NOT NULL":
+		return f.Name + " " + op, nil
+	}
+	return "", fmt.Errorf("unsupported filter operator %q on %s", f.Operator, f.Name)
+}
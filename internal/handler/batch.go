@@ -0,0 +1,592 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// maxBatchOperations caps the operations of one _batch request.
+const maxBatchOperations = 1000
+
+// batchOperation is one entry of a _batch request body.
+type batchOperation struct {
+	Op               string                   `json:"op"` // insert, upsert, update, delete or procedure
+	Table            string                   `json:"table"`
+	Records          []map[string]interface{} `json:"records"` // insert, upsert
+	Record           map[string]interface{}   `json:"record"`  // update; a single insert or upsert
+	IDs              []interface{}            `json:"ids"`     // update, delete
+	Filter           string                   `json:"filter"`  // update, delete
+	OnConflict       []string                 `json:"on_conflict"`
+	UpdateColumns    []string                 `json:"update_columns"`
+	IgnoreDuplicates bool                     `json:"ignore_duplicates"`
+	Procedure        string                   `json:"procedure"`
+	Params           map[string]interface{}   `json:"params"`
+
+	// where is a structured filter (see query.ParseFilterJSON) for update
+	// and delete, in place of Filter. It is only set by the PostgREST routes.
+	where json.RawMessage
+
+	// Resolved by prepareBatchOperation before the transaction opens.
+	upsert     *connector.UpsertOptions
+	keyColumns []string
+	cols       query.ColumnSet
+	filterSQL  string
+	filterArgs []interface{}
+}
+
+// batchRef matches a value that refers to a column of an earlier operation's
+// result: $ops[N].column for its first row, $ops[N][M].column for row M.
+var batchRef = regexp.MustCompile(`^\$ops\[(\d+)\](?:\[(\d+)\])?\.([A-Za-z_][A-Za-z0-9_]*)$`)
+
+// ExecuteBatch runs an ordered list of operations across the tables and
+// procedures of a service in a single transaction: every operation is
+// applied, or none is.
+// POST /api/v1/{serviceName}/_batch
+//
+// Body: {"operations": [{"op": "insert", "table": "orders", "records": [...]}, ...]}
+//
+// Operations:
+//   - insert     records (or record)
+//   - upsert     records (or record), on_conflict, update_columns, ignore_duplicates
+//   - update     record, and ids or filter
+//   - delete     ids or filter
+//   - procedure  procedure, params
+//
+// A string value of the form "$ops[N].column" (or "$ops[N][M].column" for
+// row M) in records, ids or params is replaced by that column of an earlier
+// operation's result, e.g. a generated key.
+//
+// Access for API keys is checked per operation against role rules on the
+// components "_table/{table}" or "_table/*" (POST for insert, POST and PATCH
+// for upsert, PATCH for update, DELETE for delete) and "_proc/{procedure}"
+// or "_proc/*" (POST).
+func (h *TableHandler) ExecuteBatch(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	serviceName := chi.URLParam(r, "serviceName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	var body struct {
+		Operations []*batchOperation `json:"operations"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	ops := body.Operations
+	if len(ops) == 0 {
+		writeError(w, http.StatusBadRequest, "No operations provided")
+		return
+	}
+	if len(ops) > maxBatchOperations {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many operations: %d (max %d)", len(ops), maxBatchOperations))
+		return
+	}
+
+	if h.store != nil {
+		svc, err := h.store.GetServiceByName(ctx, serviceName)
+		if err == nil && svc.ReadOnly {
+			writeError(w, http.StatusForbidden, "Service is read-only")
+			return
+		}
+	}
+
+	for i, op := range ops {
+		opCtx := map[string]interface{}{"operation": i}
+		if err := h.prepareBatchOperation(ctx, conn, serviceName, op); err != nil {
+			var ruleErr *columnRuleError
+			if errors.As(err, &ruleErr) {
+				opCtx["column"] = ruleErr.column
+				writeError(w, ruleErr.code, fmt.Sprintf("Invalid operation %d: %s", i, ruleErr.msg), opCtx)
+				return
+			}
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid operation %d: %s", i, err.Error()), opCtx)
+			return
+		}
+		allowed, err := h.authorizeBatchOperation(ctx, serviceName, op)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check access: "+err.Error())
+			return
+		}
+		if !allowed {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("Access denied to operation %d: %s %s", i, op.Op, op.target()), opCtx)
+			return
+		}
+	}
+
+	// A dry run executes the batch on a transaction that is never committed.
+	var exec connector.QueryExecutor
+	var tx *sqlx.Tx
+	var dry *connector.DryRun
+	if dryRunRequested(r) {
+		dry, err = connector.BeginDryRun(ctx, conn)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+			return
+		}
+		defer func() { _ = dry.Rollback() }()
+		exec = dry
+	} else {
+		tx, err = conn.BeginTx(ctx, nil)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+			return
+		}
+		defer func() { _ = tx.Rollback() }()
+		exec = tx
+	}
+
+	results := make([]model.BatchOperationResult, len(ops))
+	for i, op := range ops {
+		opCtx := map[string]interface{}{"operation": i}
+		if err := resolveBatchOperation(op, results[:i]); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid operation %d: %s", i, err.Error()), opCtx)
+			return
+		}
+		if invalid := h.checkBatchOperation(ctx, serviceName, op); invalid != nil {
+			errCtx := invalid.context()
+			errCtx["operation"] = i
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid operation %d: %s", i, invalid.Error()), errCtx)
+			return
+		}
+		res, err := h.execBatchOperation(ctx, exec, conn, serviceName, op)
+		if err != nil {
+			code, msg := classifyDBError(err, fmt.Sprintf("Operation %d (%s %s) failed", i, op.Op, op.target()))
+			writeError(w, code, msg, opCtx)
+			return
+		}
+		results[i] = res
+	}
+
+	if dry != nil {
+		if err := dry.Rollback(); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to roll back dry run: "+err.Error())
+			return
+		}
+		var affected int64
+		for _, res := range results {
+			if res.Op != "procedure" {
+				affected += int64(res.Count)
+			}
+		}
+		writeJSON(w, http.StatusOK, model.DryRunResponse{
+			DryRun:     true,
+			Statements: dry.Statements,
+			Affected:   &affected,
+			RolledBack: true,
+			Result:     results,
+		})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	resource := make([]interface{}, len(results))
+	for i, res := range results {
+		resource[i] = res
+	}
+	took := time.Since(start)
+	writeJSON(w, http.StatusOK, model.BatchResponse{
+		Resource: resource,
+		Meta: &model.BatchResponseMeta{
+			Count:     len(ops),
+			Succeeded: len(ops),
+			TookMs:    float64(took.Microseconds()) / 1000.0,
+		},
+	})
+}
+
+// target names the table or procedure an operation acts on.
+func (op *batchOperation) target() string {
+	if op.Op == "procedure" {
+		return op.Procedure
+	}
+	return op.Table
+}
+
+// prepareBatchOperation validates an operation against the schema and
+// resolves everything that does not depend on earlier results: key columns,
+// upsert options and the filter. Returned errors are client errors.
+func (h *TableHandler) prepareBatchOperation(ctx context.Context, conn connector.Connector, serviceName string, op *batchOperation) error {
+	op.Op = strings.ToLower(op.Op)
+
+	if op.Op == "procedure" {
+		if op.Procedure == "" {
+			return fmt.Errorf("procedure is required")
+		}
+		if _, ok := conn.(connector.TxProcedureCaller); !ok {
+			return fmt.Errorf("stored procedures cannot run in a batch on the %s driver", conn.DriverName())
+		}
+		return nil
+	}
+
+	switch op.Op {
+	case "insert", "upsert", "update", "delete":
+	case "":
+		return fmt.Errorf("op is required")
+	default:
+		return fmt.Errorf("unknown op %q (expected insert, upsert, update, delete or procedure)", op.Op)
+	}
+	if op.Table == "" {
+		return fmt.Errorf("table is required")
+	}
+	ts, err := h.registry.TableSchema(ctx, serviceName, op.Table)
+	if err != nil || ts == nil {
+		return fmt.Errorf("table not found: %s", op.Table)
+	}
+	op.cols = h.tableColumns(ctx, serviceName, op.Table)
+	op.keyColumns = h.primaryKey(ctx, serviceName, op.Table)
+
+	switch op.Op {
+	case "insert", "upsert":
+		if op.Record != nil && len(op.Records) == 0 {
+			op.Records = []map[string]interface{}{op.Record}
+		}
+		if len(op.Records) == 0 {
+			return fmt.Errorf("records are required")
+		}
+		for _, rec := range op.Records {
+			if err := checkRecordColumns(op.cols, rec); err != nil {
+				return err
+			}
+		}
+		if _, ruleErr := h.applyColumnRules(ctx, serviceName, op.Table, model.ColumnRuleOnCreate, op.Records); ruleErr != nil {
+			return ruleErr
+		}
+		if op.Op == "upsert" {
+			op.upsert, err = h.upsertOptions(ctx, conn, serviceName, op.Table, op.OnConflict, op.UpdateColumns, op.IgnoreDuplicates, op.Records)
+			if err != nil {
+				return err
+			}
+			excludeFromUpsert(op.upsert, op.Records, h.createOnlyColumns(ctx, serviceName, op.Table))
+		}
+		return nil
+
+	case "update":
+		if len(op.Record) == 0 {
+			return fmt.Errorf("record is required")
+		}
+		if err := checkRecordColumns(op.cols, op.Record); err != nil {
+			return err
+		}
+		if _, ruleErr := h.applyColumnRules(ctx, serviceName, op.Table, model.ColumnRuleOnUpdate, []map[string]interface{}{op.Record}); ruleErr != nil {
+			return ruleErr
+		}
+		return op.parseFilter(conn, len(op.Record)+1)
+
+	default: // delete
+		return op.parseFilter(conn, 1)
+	}
+}
+
+// checkBatchOperation validates the records of an insert, upsert or update
+// against the table schema once references to earlier results have been
+// resolved.
+func (h *TableHandler) checkBatchOperation(ctx context.Context, serviceName string, op *batchOperation) *recordValidationError {
+	switch op.Op {
+	case "insert", "upsert":
+		v := h.recordValidator(ctx, serviceName, op.Table)
+		for i, rec := range op.Records {
+			if fields := v.checkInsert(rec, upsertKeys(op.upsert), nil); fields != nil {
+				return newRecordValidationError(i, len(op.Records), fields)
+			}
+		}
+	case "update":
+		if fields := h.recordValidator(ctx, serviceName, op.Table).checkUpdate(op.Record); fields != nil {
+			return newRecordValidationError(0, 1, fields)
+		}
+	}
+	return nil
+}
+
+// parseFilter compiles the filter of an update or delete, numbering its
+// placeholders from startIdx, and requires a filter or ids.
+func (op *batchOperation) parseFilter(conn connector.Connector, startIdx int) error {
+	if op.Filter == "" && len(op.where) == 0 && len(op.IDs) == 0 {
+		return fmt.Errorf("filter or ids required for %s", op.Op)
+	}
+	var parsed *query.ParsedFilter
+	var err error
+	switch {
+	case len(op.where) > 0:
+		parsed, err = query.ParseFilterJSONForColumns(op.where, conn.ParameterPlaceholder, startIdx, op.cols)
+	case op.Filter != "":
+		parsed, err = query.ParseFilterForColumns(op.Filter, conn.ParameterPlaceholder, startIdx, op.cols)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	if parsed != nil {
+		op.filterSQL = parsed.SQL
+		op.filterArgs = parsed.Params
+	}
+	return nil
+}
+
+// checkRecordColumns rejects record fields that are not columns of the table.
+func checkRecordColumns(cols query.ColumnSet, record map[string]interface{}) error {
+	for col := range record {
+		if err := cols.Check(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeBatchOperation checks the principal's role against the component
+// and verbs of one operation.
+func (h *TableHandler) authorizeBatchOperation(ctx context.Context, serviceName string, op *batchOperation) (bool, error) {
+	components := []string{"_table/*", "_table/" + op.Table}
+	var verbs []int
+	switch op.Op {
+	case "procedure":
+		components = []string{"_proc/*", "_proc/" + op.Procedure}
+		verbs = []int{model.VerbPost}
+	case "insert":
+		verbs = []int{model.VerbPost}
+	case "upsert":
+		verbs = []int{model.VerbPost}
+		if !op.IgnoreDuplicates {
+			verbs = append(verbs, model.VerbPatch)
+		}
+	case "update":
+		verbs = []int{model.VerbPatch}
+	case "delete":
+		verbs = []int{model.VerbDelete}
+	}
+	for _, verb := range verbs {
+		allowed, err := authorizeComponent(ctx, h.store, serviceName, components, verb)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// resolveBatchOperation replaces references to earlier results in the
+// operation's records, ids and params, then coerces the ids to the key
+// column types. Returned errors are client errors.
+func resolveBatchOperation(op *batchOperation, results []model.BatchOperationResult) error {
+	for i, rec := range op.Records {
+		v, err := resolveBatchRefs(rec, results)
+		if err != nil {
+			return err
+		}
+		op.Records[i] = v.(map[string]interface{})
+	}
+	if op.Record != nil {
+		v, err := resolveBatchRefs(op.Record, results)
+		if err != nil {
+			return err
+		}
+		op.Record = v.(map[string]interface{})
+	}
+	for i, id := range op.IDs {
+		v, err := resolveBatchRefs(id, results)
+		if err != nil {
+			return err
+		}
+		op.IDs[i] = v
+	}
+	if len(op.IDs) > 0 && op.Op != "procedure" {
+		ids, err := resolveIDs(op.keyColumns, op.IDs, op.cols)
+		if err != nil {
+			return fmt.Errorf("invalid ids: %w", err)
+		}
+		op.IDs = ids
+	}
+	if op.Params != nil {
+		v, err := resolveBatchRefs(op.Params, results)
+		if err != nil {
+			return err
+		}
+		op.Params = v.(map[string]interface{})
+	}
+	return nil
+}
+
+// resolveBatchRefs returns v with every "$ops[...]" reference string, at any
+// depth, replaced by the value it names in results.
+func resolveBatchRefs(v interface{}, results []model.BatchOperationResult) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		m := batchRef.FindStringSubmatch(val)
+		if m == nil {
+			return val, nil
+		}
+		opIdx, _ := strconv.Atoi(m[1])
+		rowIdx := 0
+		if m[2] != "" {
+			rowIdx, _ = strconv.Atoi(m[2])
+		}
+		if opIdx >= len(results) {
+			return nil, fmt.Errorf("%s refers to an operation that has not run yet", val)
+		}
+		rows := results[opIdx].Resource
+		if rowIdx >= len(rows) {
+			return nil, fmt.Errorf("%s: operation %d returned %d rows", val, opIdx, len(rows))
+		}
+		ref, ok := rows[rowIdx][m[3]]
+		if !ok {
+			return nil, fmt.Errorf("%s: operation %d returned no column %q", val, opIdx, m[3])
+		}
+		return ref, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			resolved, err := resolveBatchRefs(item, results)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			resolved, err := resolveBatchRefs(item, results)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// execBatchOperation runs one resolved operation on tx.
+func (h *TableHandler) execBatchOperation(ctx context.Context, tx connector.QueryExecutor, conn connector.Connector, serviceName string, op *batchOperation) (model.BatchOperationResult, error) {
+	res := model.BatchOperationResult{Op: op.Op, Table: op.Table}
+
+	switch op.Op {
+	case "procedure":
+		res.Table, res.Procedure = "", op.Procedure
+		params := op.Params
+		if params == nil {
+			params = make(map[string]interface{})
+		}
+		rows, err := conn.(connector.TxProcedureCaller).CallProcedureTx(ctx, tx, op.Procedure, params)
+		if err != nil {
+			return res, err
+		}
+		// Procedure results carry no column types, so the generic rules apply.
+		enc := connector.NewValueEncoder(conn, nil, numericFormat(ctx, h.store, serviceName))
+		for _, row := range rows {
+			enc.EncodeRow(row)
+		}
+		res.Resource = rows
+
+	case "insert":
+		// One statement per record so every generated key can be referenced,
+		// including on drivers without RETURNING.
+		enc := h.encoder(ctx, conn, serviceName, op.Table)
+		for _, rec := range op.Records {
+			row, err := h.insertRow(ctx, tx, conn, serviceName, op.Table, rec)
+			if err != nil {
+				return res, err
+			}
+			enc.EncodeRow(row)
+			res.Resource = append(res.Resource, row)
+		}
+
+	case "upsert":
+		sqlStr, args, err := conn.BuildInsert(ctx, connector.InsertRequest{
+			Table:   op.Table,
+			Records: op.Records,
+			Upsert:  op.upsert,
+		})
+		if err != nil {
+			return res, err
+		}
+		created, err := execInsert(ctx, tx, conn, h.encoder(ctx, conn, serviceName, op.Table), sqlStr, args)
+		if err != nil {
+			return res, err
+		}
+		if created == nil {
+			created = op.Records
+		}
+		res.Resource = created
+
+	case "update", "delete":
+		var sqlStr string
+		var args []interface{}
+		var err error
+		if op.Op == "update" {
+			sqlStr, args, err = conn.BuildUpdate(ctx, connector.UpdateRequest{
+				Table:      op.Table,
+				Record:     op.Record,
+				Filter:     op.filterSQL,
+				FilterArgs: op.filterArgs,
+				IDs:        op.IDs,
+				KeyColumns: op.keyColumns,
+			})
+		} else {
+			sqlStr, args, err = conn.BuildDelete(ctx, connector.DeleteRequest{
+				Table:      op.Table,
+				Filter:     op.filterSQL,
+				FilterArgs: op.filterArgs,
+				IDs:        op.IDs,
+				KeyColumns: op.keyColumns,
+			})
+		}
+		if err != nil {
+			return res, err
+		}
+
+		if op.Op == "update" && conn.SupportsReturning() {
+			rows, err := tx.QueryxContext(ctx, sqlStr, args...)
+			if err != nil {
+				return res, err
+			}
+			defer rows.Close()
+			enc := h.encoder(ctx, conn, serviceName, op.Table)
+			for rows.Next() {
+				row := make(map[string]interface{})
+				if err := rows.MapScan(row); err != nil {
+					return res, err
+				}
+				enc.EncodeRow(row)
+				res.Resource = append(res.Resource, row)
+			}
+			if err := rows.Err(); err != nil {
+				return res, err
+			}
+			res.Count = len(res.Resource)
+			return res, nil
+		}
+		result, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			return res, err
+		}
+		affected, _ := result.RowsAffected()
+		res.Count = int(affected)
+		return res, nil
+	}
+
+	res.Count = len(res.Resource)
+	return res, nil
+}
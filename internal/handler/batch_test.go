@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -14,6 +16,7 @@ import (
 	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/connector/sqlite"
 	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
 )
 
 // ---------------------------------------------------------------------------
@@ -98,11 +101,23 @@ func newBatchTestEnv(t *testing.T) *batchTestEnv {
 	r := chi.NewRouter()
 	r.Route("/api/v1/{serviceName}/_table/{tableName}", func(r chi.Router) {
 		r.Get("/", th.QueryRecords)
+		r.Post("/_query", th.QueryRecordsJSON)
+		r.Post("/_import", th.ImportRecords)
 		r.Post("/", th.CreateRecords)
 		r.Put("/", th.ReplaceRecords)
 		r.Patch("/", th.UpdateRecords)
 		r.Delete("/", th.DeleteRecords)
+		r.Get("/{id}", th.GetRecord)
+		r.Put("/{id}", th.UpdateRecord)
+		r.Patch("/{id}", th.UpdateRecord)
+		r.Delete("/{id}", th.DeleteRecord)
 	})
+	r.Post("/api/v1/{serviceName}/_batch", th.ExecuteBatch)
+
+	sh := NewSchemaHandler(registry, store)
+	r.Post("/api/v1/{serviceName}/_schema", sh.CreateTable)
+	r.Put("/api/v1/{serviceName}/_schema/{tableName}", sh.AlterTable)
+	r.Delete("/api/v1/{serviceName}/_schema/{tableName}", sh.DropTable)
 
 	return &batchTestEnv{
 		store:    store,
@@ -275,6 +290,267 @@ func TestCreateRecords_RollbackMode_Success(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// POST (CreateRecords) upsert tests
+// ---------------------------------------------------------------------------
+
+func TestCreateRecords_Upsert(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	// Conflict columns default to the primary key.
+	body := []map[string]interface{}{
+		{"id": 1, "name": "Alicia", "email": "alice@example.com"},
+		{"id": 3, "name": "Carol", "email": "carol@example.com"},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/users?upsert=true", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("upsert: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if count := env.countRows(t); count != 3 {
+		t.Errorf("upsert: expected 3 rows, got %d", count)
+	}
+
+	// Upsert on a unique column, overwriting only the listed columns.
+	body = []map[string]interface{}{
+		{"name": "Robert", "email": "bob@example.com"},
+	}
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/users?upsert=true&on_conflict=email&update_columns=name", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("upsert on email: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	conn, _ := env.registry.Get("testdb")
+	var names []string
+	conn.DB().SelectContext(context.Background(), &names, "SELECT name FROM users ORDER BY id")
+	if want := []string{"Alicia", "Robert", "Carol"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names: got %v, want %v", names, want)
+	}
+}
+
+func TestCreateRecords_UpsertIgnoreDuplicates(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	body := []map[string]interface{}{
+		{"name": "Impostor", "email": "alice@example.com"},
+		{"name": "Dave", "email": "dave@example.com"},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/users?ignore_duplicates=true&on_conflict=email", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("ignore duplicates: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	// Only the new record is inserted and returned.
+	var resp model.ListResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Resource) != 1 || resp.Resource[0]["name"] != "Dave" {
+		t.Errorf("expected only Dave in response, got %v", resp.Resource)
+	}
+	if count := env.countRows(t); count != 3 {
+		t.Errorf("expected 3 rows, got %d", count)
+	}
+}
+
+func TestCreateRecords_UpsertInvalid(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	body := []map[string]interface{}{
+		{"name": "Alice", "email": "alice@test.com"},
+	}
+	paths := []string{
+		"/api/v1/testdb/_table/users?on_conflict=email",                                            // missing upsert=true
+		"/api/v1/testdb/_table/users?upsert=true",                                                  // primary key not in records
+		"/api/v1/testdb/_table/users?upsert=true&on_conflict=nope",                                 // unknown column
+		"/api/v1/testdb/_table/users?upsert=true&on_conflict=email&update_columns=email",           // updates a conflict column
+		"/api/v1/testdb/_table/users?ignore_duplicates=true&on_conflict=email&update_columns=name", // ignore with updates
+	}
+	for _, path := range paths {
+		rr := env.do(t, "POST", path, body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d; body: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+	if count := env.countRows(t); count != 0 {
+		t.Errorf("expected 0 rows, got %d", count)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST (CreateRecords) nested write tests
+// ---------------------------------------------------------------------------
+
+// createOrderTables adds orders (referencing users) and order_items
+// (referencing orders) to the test database.
+func (e *batchTestEnv) createOrderTables(t *testing.T) {
+	t.Helper()
+	conn, _ := e.registry.Get("testdb")
+	for _, ddl := range []string{
+		`CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			status TEXT NOT NULL DEFAULT 'new'
+		)`,
+		`CREATE TABLE order_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL REFERENCES orders(id),
+			sku TEXT NOT NULL,
+			qty INTEGER NOT NULL CHECK (qty > 0)
+		)`,
+	} {
+		if _, err := conn.DB().ExecContext(context.Background(), ddl); err != nil {
+			t.Fatalf("create table: %v", err)
+		}
+	}
+}
+
+func (e *batchTestEnv) countTable(t *testing.T, table string) int {
+	t.Helper()
+	conn, _ := e.registry.Get("testdb")
+	var count int
+	conn.DB().QueryRowxContext(context.Background(), "SELECT COUNT(*) FROM "+table).Scan(&count)
+	return count
+}
+
+func TestCreateRecords_Nested(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+
+	// A parent object (users, via user_id) and child rows (order_items).
+	body := []map[string]interface{}{
+		{
+			"user": map[string]interface{}{"name": "Alice", "email": "alice@example.com"},
+			"order_items": []interface{}{
+				map[string]interface{}{"sku": "A-1", "qty": 2},
+				map[string]interface{}{"sku": "B-2", "qty": 1},
+			},
+		},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/orders", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("nested create: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp model.ListResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Resource) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(resp.Resource))
+	}
+	order := resp.Resource[0]
+	if order["status"] != "new" {
+		t.Errorf("status default not returned: %v", order)
+	}
+	user, _ := order["user"].(map[string]interface{})
+	if user == nil || user["id"] != order["user_id"] {
+		t.Errorf("parent key not propagated: order %v", order)
+	}
+	items, _ := order["order_items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 order_items, got %v", order["order_items"])
+	}
+	for _, it := range items {
+		if it.(map[string]interface{})["order_id"] != order["id"] {
+			t.Errorf("child key not propagated: %v", it)
+		}
+	}
+
+	if n := env.countTable(t, "users"); n != 1 {
+		t.Errorf("expected 1 user, got %d", n)
+	}
+	if n := env.countTable(t, "order_items"); n != 2 {
+		t.Errorf("expected 2 order_items, got %d", n)
+	}
+}
+
+func TestCreateRecords_NestedAtomic(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+
+	// The second child violates the CHECK constraint: nothing is kept.
+	body := []map[string]interface{}{
+		{
+			"user": map[string]interface{}{"name": "Alice", "email": "alice@example.com"},
+			"order_items": []interface{}{
+				map[string]interface{}{"sku": "A-1", "qty": 2},
+				map[string]interface{}{"sku": "B-2", "qty": 0},
+			},
+		},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/orders", body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	for _, table := range []string{"users", "orders", "order_items"} {
+		if n := env.countTable(t, table); n != 0 {
+			t.Errorf("%s: expected 0 rows after rollback, got %d", table, n)
+		}
+	}
+
+	// Malformed nesting is rejected before anything is written.
+	body = []map[string]interface{}{
+		{"user_id": 1, "order_items": []interface{}{"A-1"}},
+	}
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/orders", body)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("malformed children: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/orders?continue=true", []map[string]interface{}{
+		{"user_id": 1, "order_items": []interface{}{map[string]interface{}{"sku": "A-1", "qty": 1}}},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("continue mode: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	// A key that is neither a column nor a relationship is rejected.
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/orders", []map[string]interface{}{
+		{"user_id": 1, "shipments": []interface{}{map[string]interface{}{"carrier": "x"}}},
+	})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "shipments") {
+		t.Errorf("unknown key: expected 400 naming it, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateRecords_NestedBinaryKey(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	for _, ddl := range []string{
+		`CREATE TABLE files (hash BLOB PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE chunks (id INTEGER PRIMARY KEY, file_hash BLOB NOT NULL REFERENCES files(hash), n INTEGER)`,
+	} {
+		if _, err := conn.DB().ExecContext(context.Background(), ddl); err != nil {
+			t.Fatalf("create table: %v", err)
+		}
+	}
+
+	// The parent's key is copied into the children as stored, not as its
+	// base64 response encoding.
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/files", []map[string]interface{}{
+		{"hash": "AQID", "name": "a", "chunks": []interface{}{
+			map[string]interface{}{"n": 1},
+			map[string]interface{}{"n": 2},
+		}},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var linked int
+	conn.DB().QueryRowxContext(context.Background(),
+		"SELECT COUNT(*) FROM chunks c JOIN files f ON c.file_hash = f.hash").Scan(&linked)
+	if linked != 2 {
+		t.Errorf("expected 2 chunks linked to the file, got %d", linked)
+	}
+
+	var resp model.ListResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Resource) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(resp.Resource))
+	}
+	chunks, _ := resp.Resource[0]["chunks"].([]interface{})
+	if len(chunks) != 2 || chunks[0].(map[string]interface{})["file_hash"] != "AQID" {
+		t.Errorf("expected encoded keys in the response, got %v", resp.Resource[0])
+	}
+}
+
 // ---------------------------------------------------------------------------
 // PUT (ReplaceRecords) batch mode tests
 // ---------------------------------------------------------------------------
@@ -370,3 +646,178 @@ func TestDeleteRecords_RollbackMode(t *testing.T) {
 	}
 }
 
+
+// ---------------------------------------------------------------------------
+// Multi-table _batch tests
+// ---------------------------------------------------------------------------
+
+func TestExecuteBatch(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+	env.insertSeedData(t)
+
+	body := map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Carol", "email": "carol@example.com"}},
+			map[string]interface{}{"op": "insert", "table": "orders", "record": map[string]interface{}{"user_id": "$ops[0].id"}},
+			map[string]interface{}{"op": "insert", "table": "order_items", "records": []interface{}{
+				map[string]interface{}{"order_id": "$ops[1].id", "sku": "A-1", "qty": 2},
+				map[string]interface{}{"order_id": "$ops[1].id", "sku": "B-2", "qty": 1},
+			}},
+			map[string]interface{}{"op": "update", "table": "orders", "ids": []interface{}{"$ops[1].id"}, "record": map[string]interface{}{"status": "paid"}},
+			map[string]interface{}{"op": "delete", "table": "users", "filter": "name = 'Bob'"},
+			map[string]interface{}{"op": "upsert", "table": "users", "on_conflict": []string{"email"},
+				"record": map[string]interface{}{"name": "Alice Smith", "email": "alice@example.com"}},
+		},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_batch", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Resource []model.BatchOperationResult `json:"resource"`
+		Meta     model.BatchResponseMeta      `json:"meta"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Meta.Count != 6 || resp.Meta.Succeeded != 6 || len(resp.Resource) != 6 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Resource[2].Op != "insert" || resp.Resource[2].Table != "order_items" || resp.Resource[2].Count != 2 {
+		t.Errorf("unexpected order_items result: %+v", resp.Resource[2])
+	}
+	if resp.Resource[4].Count != 1 {
+		t.Errorf("expected delete to remove 1 row, got %d", resp.Resource[4].Count)
+	}
+
+	conn, _ := env.registry.Get("testdb")
+	var status string
+	var items int
+	conn.DB().QueryRowxContext(context.Background(), `
+		SELECT o.status, (SELECT COUNT(*) FROM order_items i WHERE i.order_id = o.id)
+		FROM orders o JOIN users u ON u.id = o.user_id WHERE u.email = 'carol@example.com'`).Scan(&status, &items)
+	if status != "paid" || items != 2 {
+		t.Errorf("expected a paid order with 2 items for Carol, got %q with %d", status, items)
+	}
+	var name string
+	conn.DB().QueryRowxContext(context.Background(), "SELECT name FROM users WHERE email = 'alice@example.com'").Scan(&name)
+	if name != "Alice Smith" {
+		t.Errorf("expected upserted name, got %q", name)
+	}
+	if count := env.countRows(t); count != 2 {
+		t.Errorf("expected 2 users, got %d", count)
+	}
+}
+
+func TestExecuteBatch_Atomic(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+
+	// The last operation violates the qty CHECK constraint.
+	body := map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Carol", "email": "carol@example.com"}},
+			map[string]interface{}{"op": "insert", "table": "orders", "record": map[string]interface{}{"user_id": "$ops[0].id"}},
+			map[string]interface{}{"op": "insert", "table": "order_items", "record": map[string]interface{}{"order_id": "$ops[1].id", "sku": "A-1", "qty": 0}},
+		},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_batch", body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var errResp model.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&errResp)
+	if errResp.Error.Context["operation"] != float64(2) {
+		t.Errorf("expected failing operation 2, got %v", errResp.Error.Context)
+	}
+	if count := env.countRows(t); count != 0 {
+		t.Errorf("expected no users after rollback, got %d", count)
+	}
+	if count := env.countTable(t, "orders"); count != 0 {
+		t.Errorf("expected no orders after rollback, got %d", count)
+	}
+}
+
+func TestExecuteBatch_Invalid(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	insert := map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Carol", "email": "carol@example.com"}}
+	tests := []struct {
+		name string
+		ops  []interface{}
+	}{
+		{"no operations", []interface{}{}},
+		{"unknown op", []interface{}{map[string]interface{}{"op": "merge", "table": "users"}}},
+		{"unknown table", []interface{}{map[string]interface{}{"op": "delete", "table": "nope", "ids": []interface{}{1}}}},
+		{"unknown column", []interface{}{map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"nmae": "x"}}}},
+		{"delete without filter", []interface{}{map[string]interface{}{"op": "delete", "table": "users"}}},
+		{"forward reference", []interface{}{
+			map[string]interface{}{"op": "update", "table": "users", "ids": []interface{}{"$ops[1].id"}, "record": map[string]interface{}{"name": "x"}},
+			insert,
+		}},
+		{"missing column reference", []interface{}{insert,
+			map[string]interface{}{"op": "delete", "table": "users", "ids": []interface{}{"$ops[0].user_id"}},
+		}},
+		{"procedure unsupported", []interface{}{map[string]interface{}{"op": "procedure", "procedure": "refresh"}}},
+		{"unknown filter column", []interface{}{insert,
+			map[string]interface{}{"op": "delete", "table": "users", "filter": "nmae = 'Carol'"},
+		}},
+	}
+	for _, tt := range tests {
+		rr := env.do(t, "POST", "/api/v1/testdb/_batch", map[string]interface{}{"operations": tt.ops})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d; body: %s", tt.name, rr.Code, rr.Body.String())
+		}
+		if tt.name == "unknown filter column" && !strings.Contains(rr.Body.String(), "unknown column") {
+			t.Errorf("%s: expected the filter to be checked against the table, got %s", tt.name, rr.Body.String())
+		}
+	}
+	if count := env.countRows(t); count != 0 {
+		t.Errorf("expected 0 rows, got %d", count)
+	}
+}
+
+func TestExecuteBatch_RoleAccess(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+	ctx := context.Background()
+
+	role := &model.Role{Name: "writer", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbPost},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+
+	run := func(ops ...interface{}) int {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(map[string]interface{}{"operations": ops})
+		req := httptest.NewRequest("POST", "/api/v1/testdb/_batch", &buf)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey,
+			&middleware.Principal{Type: "api_key", RoleID: role.ID}))
+		rr := httptest.NewRecorder()
+		env.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	insert := map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Carol", "email": "carol@example.com"}}
+	del := map[string]interface{}{"op": "delete", "table": "users", "ids": []interface{}{1}}
+	upsert := map[string]interface{}{"op": "upsert", "table": "users", "on_conflict": []string{"email"},
+		"record": map[string]interface{}{"name": "Dave", "email": "dave@example.com"}}
+
+	if got := run(insert, del); got != http.StatusForbidden {
+		t.Errorf("delete without DELETE: expected 403, got %d", got)
+	}
+	if got := run(upsert); got != http.StatusForbidden {
+		t.Errorf("upsert without PATCH: expected 403, got %d", got)
+	}
+	if count := env.countRows(t); count != 2 {
+		t.Errorf("denied batches must not write: expected 2 rows, got %d", count)
+	}
+	if got := run(insert); got != http.StatusOK {
+		t.Errorf("granted insert: expected 200, got %d", got)
+	}
+}
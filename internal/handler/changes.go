@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/changes"
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// ChangeHandler streams the inserts, updates and deletes of a table as
+// server-sent events or over a WebSocket. Streams are opt-in per service
+// (the change_streams setting), because the first stream of a table
+// installs change capture on it.
+type ChangeHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	hub      *changes.Hub
+}
+
+// NewChangeHandler creates a ChangeHandler reading changes from hub.
+func NewChangeHandler(registry *connector.Registry, store *config.Store, hub *changes.Hub) *ChangeHandler {
+	return &ChangeHandler{registry: registry, store: store, hub: hub}
+}
+
+// StreamChanges streams a table's changes. Without an offset the stream
+// starts with the next change; with one (the after parameter or the
+// Last-Event-ID header an EventSource sends on reconnect) it resumes after
+// that change, for as long as the change log retains it.
+// GET /api/v1/{serviceName}/_table/{tableName}/_changes
+//
+//	?filter=status = 'paid'   only changes whose row matches
+//	?after=<offset>           resume after a change
+func (h *ChangeHandler) StreamChanges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	view, ok := h.view(w, r, serviceName, tableName)
+	if !ok {
+		return
+	}
+
+	sub, err := h.hub.Subscribe(ctx, serviceName, tableName)
+	if err != nil {
+		writeChangeError(w, err)
+		return
+	}
+	defer sub.Close()
+
+	after := queryString(r, "after")
+	if after == "" {
+		after = r.Header.Get("Last-Event-ID")
+	}
+	if after == "" {
+		// Pin the start now, so that changes made while the client is
+		// still connecting are not lost.
+		if after, err = sub.Last(ctx); err != nil {
+			writeChangeError(w, err)
+			return
+		}
+	} else if _, err := sub.Read(ctx, after); err != nil {
+		writeChangeError(w, err)
+		return
+	}
+
+	// A stream outlives the server's write timeout, and a WebSocket its read
+	// timeout too.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	_ = rc.SetReadDeadline(time.Time{})
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		h.streamWebSocket(w, r, sub, view, after)
+		return
+	}
+	h.streamSSE(w, r, rc, sub, view, after)
+}
+
+// view resolves what the request may see of the table's changes, writing
+// an error response when the request is not allowed or invalid.
+func (h *ChangeHandler) view(w http.ResponseWriter, r *http.Request, serviceName, tableName string) (*changes.View, bool) {
+	ctx := r.Context()
+	svc, err := h.store.GetServiceByName(ctx, serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return nil, false
+	}
+	if !svc.ChangeStreams {
+		writeError(w, http.StatusForbidden, "Change streams are not enabled for service "+serviceName)
+		return nil, false
+	}
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return nil, false
+	}
+
+	components := []string{"_table/*", "_table/" + tableName}
+	allowed, err := authorizeComponent(ctx, h.store, serviceName, components, model.VerbGet)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to check access: "+err.Error())
+		return nil, false
+	}
+	if !allowed {
+		writeError(w, http.StatusForbidden, "Access denied to table "+tableName)
+		return nil, false
+	}
+
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Table not found: "+tableName)
+		return nil, false
+	}
+	cols := make(query.ColumnSet, len(ts.Columns))
+	for _, c := range ts.Columns {
+		cols[c.Name] = c.JsonType
+	}
+
+	var filters []query.RowFilter
+	if filter := queryString(r, "filter"); filter != "" {
+		match, err := query.CompileRowFilter(filter, cols)
+		if err != nil {
+			writeQueryError(w, err)
+			return nil, false
+		}
+		filters = append(filters, match)
+	}
+	security, err := rowFilter(ctx, h.store, serviceName, components, model.VerbGet)
+	if err == nil && security != "" {
+		var match query.RowFilter
+		if match, err = query.CompileRowFilter(security, cols); err == nil {
+			filters = append(filters, match)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Invalid row filter: "+err.Error())
+		return nil, false
+	}
+
+	view := &changes.View{
+		Table:   tableName,
+		Encoder: connector.NewValueEncoder(conn, ts, svc.NumericFormat),
+	}
+	switch len(filters) {
+	case 1:
+		view.Match = filters[0]
+	case 2:
+		view.Match = func(row map[string]interface{}) bool { return filters[0](row) && filters[1](row) }
+	}
+	return view, true
+}
+
+// streamSSE writes the stream as server-sent events. Each event is named
+// by its operation and carries its offset as the event ID, so that an
+// EventSource resumes where it left off; idle streams get a comment line
+// every heartbeat interval.
+func (h *ChangeHandler) streamSSE(w http.ResponseWriter, r *http.Request, rc *http.ResponseController, sub *changes.Subscription, view *changes.View, after string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	_ = rc.Flush()
+
+	err := sub.Stream(r.Context(), after, func(batch []connector.Change) error {
+		if batch == nil {
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		for _, ev := range view.Events(batch) {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.Offset, ev.Op, data); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	})
+	if err != nil && r.Context().Err() == nil {
+		// The status is sent; report why the stream ended as an event.
+		data, _ := json.Marshal(map[string]string{"message": err.Error()})
+		_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+		_ = rc.Flush()
+	}
+}
+
+// streamWebSocket writes the stream as one JSON text message per change.
+// Messages from the client are ignored; idle streams are pinged.
+func (h *ChangeHandler) streamWebSocket(w http.ResponseWriter, r *http.Request, sub *changes.Subscription, view *changes.View, after string) {
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept has written the error response
+	}
+	defer c.CloseNow()
+
+	// CloseRead answers pings and closes, and cancels ctx when the client
+	// goes away.
+	ctx := c.CloseRead(context.WithoutCancel(r.Context()))
+	err = sub.Stream(ctx, after, func(batch []connector.Change) error {
+		if batch == nil {
+			pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			return c.Ping(pingCtx)
+		}
+		for _, ev := range view.Events(batch) {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if err := c.Write(ctx, websocket.MessageText, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+	case errors.Is(err, changes.ErrClosed):
+		c.Close(websocket.StatusNormalClosure, err.Error())
+	default:
+		c.Close(websocket.StatusGoingAway, "change stream ended")
+	}
+}
+
+// DisableChanges removes the change capture installed on a table, ending
+// its streams. It is mounted for admins only.
+// DELETE /api/v1/{serviceName}/_table/{tableName}/_changes
+func (h *ChangeHandler) DisableChanges(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	if err := h.hub.Disable(r.Context(), serviceName, tableName); err != nil {
+		writeChangeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeChangeError maps errors from opening a change stream to responses.
+func writeChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, changes.ErrUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, changes.ErrNotTable), errors.Is(err, connector.ErrInvalidOffset):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, connector.ErrCaptureNotEnabled):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		code, msg := classifyDBError(err, "Failed to stream changes")
+		writeError(w, code, msg)
+	}
+}
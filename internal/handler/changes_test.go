@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/changes"
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+type changeTestEnv struct {
+	store  *config.Store
+	conn   connector.Connector
+	server *httptest.Server
+}
+
+// newChangeTestEnv serves change streams of a file-backed SQLite service
+// (change capture needs every pooled connection to see the same database).
+// Requests carrying an X-Test-Role header are made as an API key of that
+// role; all others as an admin.
+func newChangeTestEnv(t *testing.T, enabled bool) *changeTestEnv {
+	t.Helper()
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	dsn := filepath.Join(t.TempDir(), "changes.db")
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{Driver: "sqlite", DSN: dsn}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	t.Cleanup(func() { registry.Disconnect("testdb") })
+	conn, _ := registry.Get("testdb")
+	if _, err := conn.DB().Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT NOT NULL, total REAL)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if err := store.CreateService(context.Background(), &model.ServiceConfig{
+		Name: "testdb", Driver: "sqlite", DSN: dsn, IsActive: true, ChangeStreams: enabled,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	hub := changes.NewHub(registry, changes.Config{PollInterval: 10 * time.Millisecond})
+	ch := NewChangeHandler(registry, store, hub)
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := &middleware.Principal{Type: "admin", IsAdmin: true}
+			if role := r.Header.Get("X-Test-Role"); role != "" {
+				id, _ := strconv.ParseInt(role, 10, 64)
+				p = &middleware.Principal{Type: "api_key", RoleID: id, KeyID: 1}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.AuthPrincipalKey, p)))
+		})
+	})
+	r.Get("/api/v1/{serviceName}/_table/{tableName}/_changes", ch.StreamChanges)
+	r.Delete("/api/v1/{serviceName}/_table/{tableName}/_changes", ch.DisableChanges)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	t.Cleanup(hub.Close)
+	return &changeTestEnv{store: store, conn: conn, server: srv}
+}
+
+func (e *changeTestEnv) exec(t *testing.T, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := e.conn.DB().Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+type sseEvent struct {
+	id, name string
+	data     changes.Event
+}
+
+// sseStream is an open change stream read as server-sent events.
+type sseStream struct {
+	resp   *http.Response
+	events chan sseEvent
+}
+
+// stream opens a change stream and waits for it to be connected. A non-200
+// response is returned with a nil stream.
+func (e *changeTestEnv) stream(t *testing.T, query string, header http.Header) (*sseStream, *http.Response) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", e.server.URL+"/api/v1/testdb/_table/orders/_changes"+query, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("GET _changes: %v", err)
+	}
+	t.Cleanup(func() { cancel(); resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	s := &sseStream{resp: resp, events: make(chan sseEvent, 20)}
+	connected := make(chan struct{})
+	go func() {
+		defer close(s.events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == ": connected":
+				close(connected)
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data)
+			case line == "" && ev.name != "":
+				s.events <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not connect")
+	}
+	return s, resp
+}
+
+// next returns the stream's next event.
+func (s *sseStream) next(t *testing.T) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-s.events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return sseEvent{}
+}
+
+func TestStreamChangesSSE(t *testing.T) {
+	env := newChangeTestEnv(t, true)
+	all, _ := env.stream(t, "", nil)
+	paid, _ := env.stream(t, "?filter="+url.QueryEscape("status = 'paid'"), nil)
+
+	env.exec(t,
+		`INSERT INTO orders (id, status, total) VALUES (1, 'new', 10.5)`,
+		`UPDATE orders SET status = 'paid' WHERE id = 1`,
+		`INSERT INTO orders (id, status, total) VALUES (2, 'paid', 4)`,
+		`DELETE FROM orders WHERE id = 1`,
+	)
+
+	var offsets []string
+	for i, want := range []string{"insert", "update", "insert", "delete"} {
+		ev := all.next(t)
+		if ev.name != want || ev.data.Op != want || ev.data.Table != "orders" || ev.id != ev.data.Offset {
+			t.Errorf("event %d = %+v, want %s", i, ev, want)
+		}
+		// An update carries both row images.
+		if want == "update" && (ev.data.Old["status"] != "new" || ev.data.Row["status"] != "paid") {
+			t.Errorf("update images: old %v, new %v", ev.data.Old, ev.data.Row)
+		}
+		offsets = append(offsets, ev.id)
+	}
+
+	// The filtered stream sees the update that made order 1 paid as its
+	// insert.
+	for i, want := range []struct {
+		op string
+		id float64
+	}{{"insert", 1}, {"insert", 2}, {"delete", 1}} {
+		ev := paid.next(t)
+		if ev.data.Op != want.op || ev.data.Row["id"] != want.id {
+			t.Errorf("filtered event %d = %s %v, want %s of %v", i, ev.data.Op, ev.data.Row, want.op, want.id)
+		}
+	}
+
+	// Reconnecting with Last-Event-ID resumes after that change.
+	resumed, _ := env.stream(t, "", http.Header{"Last-Event-Id": {offsets[1]}})
+	if ev := resumed.next(t); ev.id != offsets[2] || ev.data.Row["id"] != float64(2) {
+		t.Errorf("resumed with %+v, want offset %s", ev, offsets[2])
+	}
+}
+
+func TestStreamChangesRowSecurity(t *testing.T) {
+	env := newChangeTestEnv(t, true)
+	ctx := context.Background()
+	role := &model.Role{Name: "paid-reader", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{{
+		ServiceName: "testdb", Component: "_table/orders", VerbMask: model.VerbGet,
+		Filters: []model.Filter{{Name: "status", Operator: "=", Value: "paid"}},
+	}}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	header := http.Header{"X-Test-Role": {strconv.FormatInt(role.ID, 10)}}
+	s, _ := env.stream(t, "", header)
+
+	env.exec(t,
+		`INSERT INTO orders (id, status) VALUES (1, 'new')`,
+		`INSERT INTO orders (id, status) VALUES (2, 'paid')`,
+		`UPDATE orders SET status = 'refunded' WHERE id = 2`,
+	)
+	if ev := s.next(t); ev.data.Op != "insert" || ev.data.Row["id"] != float64(2) {
+		t.Errorf("first event = %+v, want the insert of order 2", ev)
+	}
+	// The row left the role's view: the role sees the paid row deleted.
+	if ev := s.next(t); ev.data.Op != "delete" || ev.data.Row["status"] != "paid" || ev.data.Old != nil {
+		t.Errorf("second event = %+v, want a delete of the paid row", ev)
+	}
+
+	other := &model.Role{Name: "none", IsActive: true}
+	if err := env.store.CreateRole(ctx, other); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if _, resp := env.stream(t, "", http.Header{"X-Test-Role": {strconv.FormatInt(other.ID, 10)}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("role without access: status %d", resp.StatusCode)
+	}
+}
+
+func TestStreamChangesErrors(t *testing.T) {
+	disabled := newChangeTestEnv(t, false)
+	if _, resp := disabled.stream(t, "", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("service without change streams: status %d", resp.StatusCode)
+	}
+
+	env := newChangeTestEnv(t, true)
+	for query, want := range map[string]int{
+		"?after=not-an-offset":                         http.StatusBadRequest,
+		"?filter=" + url.QueryEscape("missing = 1"):    http.StatusBadRequest,
+		"?filter=" + url.QueryEscape("status = 'paid"): http.StatusBadRequest,
+	} {
+		if _, resp := env.stream(t, query, nil); resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+
+	// Disabling capture ends open streams.
+	s, _ := env.stream(t, "", nil)
+	req, _ := http.NewRequest("DELETE", env.server.URL+"/api/v1/testdb/_table/orders/_changes", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE _changes: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE _changes: status %d", resp.StatusCode)
+	}
+	if ev := s.next(t); ev.name != "error" {
+		t.Errorf("after disable got %+v, want an error event", ev)
+	}
+}
+
+func TestStreamChangesWebSocket(t *testing.T) {
+	env := newChangeTestEnv(t, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wsURL := "ws" + strings.TrimPrefix(env.server.URL, "http") + "/api/v1/testdb/_table/orders/_changes"
+	c, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.CloseNow()
+
+	// The stream is pinned when the connection is accepted.
+	env.exec(t, `INSERT INTO orders (id, status) VALUES (7, 'new')`)
+	typ, data, err := c.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	var ev changes.Event
+	if err := json.Unmarshal(data, &ev); err != nil || typ != websocket.MessageText {
+		t.Fatalf("message %q: %v", data, err)
+	}
+	if ev.Op != "insert" || ev.Row["id"] != float64(7) || ev.Offset == "" {
+		t.Errorf("event = %+v", ev)
+	}
+}
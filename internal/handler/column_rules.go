@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// columnRuleError reports a write refused by a column rule: a client value
+// for a column whose rule rejects one (400), or a rule whose value the
+// request cannot provide, such as a missing claim (403).
+type columnRuleError struct {
+	code   int
+	column string
+	msg    string
+}
+
+func (e *columnRuleError) Error() string { return e.msg }
+
+func writeColumnRuleError(w http.ResponseWriter, err *columnRuleError) {
+	writeError(w, err.code, err.msg, map[string]interface{}{"column": err.column})
+}
+
+// checkColumnRules validates the column rules of a service configuration.
+func checkColumnRules(rules []model.ColumnRule) error {
+	for i, rule := range rules {
+		if rule.Table == "" || rule.Column == "" {
+			return fmt.Errorf("rule %d: table and column are required", i)
+		}
+		switch rule.On {
+		case "", model.ColumnRuleOnCreate, model.ColumnRuleOnUpdate:
+		default:
+			return fmt.Errorf("rule %d: on must be %q, %q or empty", i, model.ColumnRuleOnCreate, model.ColumnRuleOnUpdate)
+		}
+		switch rule.Mode {
+		case "", model.ColumnRuleOverride, model.ColumnRuleReject:
+		default:
+			return fmt.Errorf("rule %d: mode must be %q or %q", i, model.ColumnRuleOverride, model.ColumnRuleReject)
+		}
+		switch {
+		case rule.Value == "now",
+			rule.Value == "principal.id", rule.Value == "principal.type", rule.Value == "principal.role_id",
+			rule.Value == "principal.email", rule.Value == "principal.label":
+		case strings.HasPrefix(rule.Value, "claim.") && len(rule.Value) > len("claim."):
+		case strings.HasPrefix(rule.Value, "key.") && len(rule.Value) > len("key."):
+		default:
+			return fmt.Errorf("rule %d: unknown value source %q", i, rule.Value)
+		}
+	}
+	return nil
+}
+
+// columnRules returns the rules of a service that fill columns of tableName
+// on a create or an update. Rules for "*" apply to every table that has the
+// column.
+func (h *TableHandler) columnRules(ctx context.Context, serviceName, tableName, on string) []model.ColumnRule {
+	if h.store == nil {
+		return nil
+	}
+	svc, err := h.store.GetServiceByName(ctx, serviceName)
+	if err != nil || len(svc.ColumnRules) == 0 {
+		return nil
+	}
+	var rules []model.ColumnRule
+	for _, rule := range svc.ColumnRules {
+		if rule.On != "" && rule.On != on {
+			continue
+		}
+		if rule.Table == "*" {
+			cols := h.tableColumns(ctx, serviceName, tableName)
+			if cols == nil || cols.Check(rule.Column) != nil {
+				continue
+			}
+		} else if rule.Table != tableName {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// columnValues resolves the values of rules from the request context, keyed
+// by column. Rules whose value the request lacks are skipped for admins, who
+// may then supply the column themselves, and refused for everyone else.
+func columnValues(ctx context.Context, rules []model.ColumnRule) (map[string]interface{}, *columnRuleError) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := middleware.GetPrincipal(ctx)
+	now := time.Now().UTC()
+	values := make(map[string]interface{}, len(rules))
+	for _, rule := range rules {
+		v, ok := columnRuleValue(p, rule.Value, now)
+		if !ok {
+			if p != nil && p.IsAdmin {
+				continue
+			}
+			return nil, &columnRuleError{
+				code:   http.StatusForbidden,
+				column: rule.Column,
+				msg:    fmt.Sprintf("Cannot fill column %s: the request has no %s", rule.Column, rule.Value),
+			}
+		}
+		values[rule.Column] = v
+	}
+	return values, nil
+}
+
+// columnRuleValue returns the value of a rule source for principal p.
+func columnRuleValue(p *middleware.Principal, source string, now time.Time) (interface{}, bool) {
+	if source == "now" {
+		return now, true
+	}
+	if p == nil {
+		return nil, false
+	}
+	switch {
+	case source == "principal.type":
+		return p.Type, p.Type != ""
+	case source == "principal.id":
+		if p.IsAdmin {
+			return p.AdminID, p.AdminID != 0
+		}
+		return p.KeyID, p.KeyID != 0
+	case source == "principal.role_id":
+		return p.RoleID, p.RoleID != 0
+	case source == "principal.email":
+		return p.Email, p.Email != ""
+	case source == "principal.label":
+		return p.Label, p.Label != ""
+	case strings.HasPrefix(source, "claim."):
+		v, ok := p.Claims[strings.TrimPrefix(source, "claim.")]
+		return v, ok && v != nil
+	case strings.HasPrefix(source, "key."):
+		v, ok := p.Metadata[strings.TrimPrefix(source, "key.")]
+		return v, ok
+	}
+	return nil, false
+}
+
+// applyColumnRules sets the rule-filled columns of records, replacing any
+// client value, or refuses the write when a reject rule's column was
+// supplied. Client fields are matched case-insensitively so that a
+// differently cased field cannot slip past a rule.
+func (h *TableHandler) applyColumnRules(ctx context.Context, serviceName, tableName, on string, records []map[string]interface{}) (map[string]interface{}, *columnRuleError) {
+	rules := h.columnRules(ctx, serviceName, tableName, on)
+	values, err := columnValues(ctx, rules)
+	if err != nil || len(values) == 0 {
+		return values, err
+	}
+	// Bind values as the column's type, e.g. a numeric key ID as "42" for
+	// a text created_by column.
+	cols := h.tableColumns(ctx, serviceName, tableName)
+	for col, v := range values {
+		if coerced, err := cols.Coerce(col, v); err == nil {
+			values[col] = coerced
+		}
+	}
+	for _, rule := range rules {
+		if _, ok := values[rule.Column]; !ok || rule.Mode != model.ColumnRuleReject {
+			continue
+		}
+		for _, rec := range records {
+			for field := range rec {
+				if strings.EqualFold(field, rule.Column) {
+					return nil, &columnRuleError{
+						code:   http.StatusBadRequest,
+						column: rule.Column,
+						msg:    fmt.Sprintf("Column %s is set by the server and cannot be supplied", rule.Column),
+					}
+				}
+			}
+		}
+	}
+	for _, rec := range records {
+		for col, v := range values {
+			for field := range rec {
+				if strings.EqualFold(field, col) {
+					delete(rec, field)
+				}
+			}
+			rec[col] = v
+		}
+	}
+	return values, nil
+}
+
+// rejectColumns returns the columns among values, as returned by
+// applyColumnRules, whose rules refuse a client value. Imports without a
+// header check them row by row.
+func (h *TableHandler) rejectColumns(ctx context.Context, serviceName, tableName string, values map[string]interface{}) []string {
+	var cols []string
+	for _, rule := range h.columnRules(ctx, serviceName, tableName, model.ColumnRuleOnCreate) {
+		if _, ok := values[rule.Column]; ok && rule.Mode == model.ColumnRuleReject {
+			cols = append(cols, rule.Column)
+		}
+	}
+	return cols
+}
+
+// createOnlyColumns returns the columns of tableName that rules fill on
+// create but not on update.
+func (h *TableHandler) createOnlyColumns(ctx context.Context, serviceName, tableName string) []string {
+	var cols []string
+	for _, rule := range h.columnRules(ctx, serviceName, tableName, model.ColumnRuleOnCreate) {
+		if rule.On == model.ColumnRuleOnCreate {
+			cols = append(cols, rule.Column)
+		}
+	}
+	return cols
+}
+
+// excludeFromUpsert keeps columns filled only on create (such as created_by)
+// from being overwritten when an upsert updates an existing row. It only
+// narrows the default update columns; explicit update_columns are kept.
+func excludeFromUpsert(opts *connector.UpsertOptions, records []map[string]interface{}, createOnly []string) {
+	if opts == nil || opts.IgnoreDuplicates || opts.UpdateColumns != nil || len(createOnly) == 0 {
+		return
+	}
+	columns := make([]string, 0, len(records[0]))
+	for col := range records[0] {
+		columns = append(columns, col)
+	}
+	update, err := connector.UpsertUpdateColumns(columns, opts)
+	if err != nil {
+		return
+	}
+	kept := make([]string, 0, len(update))
+	for _, col := range update {
+		if !containsFold(createOnly, col) {
+			kept = append(kept, col)
+		}
+	}
+	if len(kept) == len(update) {
+		return
+	}
+	if len(kept) == 0 {
+		opts.IgnoreDuplicates = true
+		return
+	}
+	opts.UpdateColumns = kept
+}
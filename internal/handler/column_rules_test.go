@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// newColumnRulesTestEnv adds a docs table and column rules that fill its
+// tenant, author and timestamp columns.
+func newColumnRulesTestEnv(t *testing.T) *batchTestEnv {
+	t.Helper()
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(context.Background(), `CREATE TABLE docs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		tenant_id TEXT NOT NULL,
+		created_by TEXT,
+		created_at TEXT,
+		updated_by TEXT
+	)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if err := env.store.CreateService(context.Background(), &model.ServiceConfig{
+		Name:     "testdb",
+		Driver:   "sqlite",
+		DSN:      ":memory:",
+		IsActive: true,
+		ColumnRules: []model.ColumnRule{
+			{Table: "docs", Column: "tenant_id", Value: "key.tenant", Mode: model.ColumnRuleReject},
+			{Table: "*", Column: "created_by", Value: "principal.id", On: model.ColumnRuleOnCreate},
+			{Table: "docs", Column: "created_at", Value: "now", On: model.ColumnRuleOnCreate},
+			{Table: "*", Column: "updated_by", Value: "principal.label"},
+		},
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	return env
+}
+
+func (e *batchTestEnv) doAs(t *testing.T, p *middleware.Principal, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, p))
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestColumnRules(t *testing.T) {
+	env := newColumnRulesTestEnv(t)
+	ctx := context.Background()
+	role := &model.Role{Name: "writer", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/*", VerbMask: model.VerbAll},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	key := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 7, Label: "ci",
+		Metadata: map[string]string{"tenant": "acme"}}
+
+	// Client values for override columns are replaced.
+	rr := env.doAs(t, key, "POST", "/api/v1/testdb/_table/docs", map[string]interface{}{"title": "a", "created_by": "mallory"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.ListResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	doc := resp.Resource[0]
+	if doc["tenant_id"] != "acme" || doc["created_by"] != "7" || doc["updated_by"] != "ci" || doc["created_at"] == nil {
+		t.Errorf("create: unexpected row %v", doc)
+	}
+
+	// Updates fill update columns and leave create-only ones alone, also
+	// when an upsert hits an existing row.
+	ops := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 9, Label: "ops",
+		Metadata: map[string]string{"tenant": "acme"}}
+	rr = env.doAs(t, ops, "PATCH", "/api/v1/testdb/_table/docs/1", map[string]interface{}{"title": "b"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	rr = env.doAs(t, ops, "POST", "/api/v1/testdb/_table/docs?upsert=true", map[string]interface{}{"id": 1, "title": "c"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("upsert: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	conn, _ := env.registry.Get("testdb")
+	var title, createdBy, updatedBy string
+	conn.DB().QueryRowxContext(ctx, "SELECT title, created_by, updated_by FROM docs WHERE id = 1").
+		Scan(&title, &createdBy, &updatedBy)
+	if title != "c" || createdBy != "7" || updatedBy != "ops" {
+		t.Errorf("after update: title=%q created_by=%q updated_by=%q", title, createdBy, updatedBy)
+	}
+
+	// A reject column cannot be supplied, and a missing source refuses the write.
+	rr = env.doAs(t, key, "POST", "/api/v1/testdb/_table/docs", map[string]interface{}{"title": "d", "TENANT_ID": "evil"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("reject: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	anon := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 8}
+	rr = env.doAs(t, anon, "POST", "/api/v1/testdb/_table/docs", map[string]interface{}{"title": "e"})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("missing metadata: expected 403, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	// Batches and imports apply the same rules.
+	rr = env.doAs(t, key, "POST", "/api/v1/testdb/_batch", map[string]interface{}{"operations": []interface{}{
+		map[string]interface{}{"op": "insert", "table": "docs", "record": map[string]interface{}{"title": "f"}},
+	}})
+	if rr.Code != http.StatusOK {
+		t.Errorf("batch: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	req := httptest.NewRequest("POST", "/api/v1/testdb/_table/docs/_import", bytes.NewBufferString("title\ng\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, key))
+	rr = httptest.NewRecorder()
+	env.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("import: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var tenants int
+	conn.DB().QueryRowxContext(ctx, "SELECT COUNT(*) FROM docs WHERE tenant_id = 'acme' AND created_by = '7'").Scan(&tenants)
+	if tenants != 3 {
+		t.Errorf("expected 3 rows filled by rules, got %d", tenants)
+	}
+}
+
+func TestCheckColumnRules(t *testing.T) {
+	bad := []model.ColumnRule{
+		{Column: "created_by", Value: "principal.id"},
+		{Table: "*", Column: "created_by", Value: "principal.name"},
+		{Table: "*", Column: "created_by", Value: "claim."},
+		{Table: "*", Column: "created_by", Value: "now", On: "delete"},
+		{Table: "*", Column: "created_by", Value: "now", Mode: "ignore"},
+	}
+	for _, rule := range bad {
+		if err := checkColumnRules([]model.ColumnRule{rule}); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+	if err := checkColumnRules([]model.ColumnRule{{Table: "t", Column: "tenant_id", Value: "claim.tenant"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
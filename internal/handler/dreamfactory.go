@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+	"github.com/faucetdb/faucet/internal/service"
+)
+
+// dreamFactorySessionTTL is how long a session token issued at
+// /api/v2/user/session is valid, as for admin logins.
+const dreamFactorySessionTTL = 24 * time.Hour
+
+// DreamFactoryHandler serves the parts of the DreamFactory 2.x API that
+// differ from Faucet's own, for the /api/v2 compatibility routes: table reads
+// with related records and DreamFactory's include_count, and user sessions.
+// Writes and the other routes are served by the usual handlers, and the
+// middleware.DreamFactory middleware translates credentials and errors.
+type DreamFactoryHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	authSvc  *service.AuthService
+	tables   *TableHandler
+	odata    *ODataHandler
+}
+
+// NewDreamFactoryHandler creates a new DreamFactoryHandler.
+func NewDreamFactoryHandler(registry *connector.Registry, store *config.Store, authSvc *service.AuthService) *DreamFactoryHandler {
+	return &DreamFactoryHandler{
+		registry: registry,
+		store:    store,
+		authSvc:  authSvc,
+		tables:   NewTableHandler(registry, store),
+		odata:    NewODataHandler(registry, store),
+	}
+}
+
+// dreamFactoryList is the envelope of a DreamFactory read. meta is only
+// present with include_count, and its count is the number of matching
+// records rather than the number returned.
+type dreamFactoryList struct {
+	Resource []map[string]interface{} `json:"resource"`
+	Meta     *dreamFactoryMeta        `json:"meta,omitempty"`
+}
+
+type dreamFactoryMeta struct {
+	Count int64 `json:"count"`
+}
+
+// ---------------------------------------------------------------------------
+// Table reads
+// ---------------------------------------------------------------------------
+
+// QueryRecords retrieves records as DreamFactory does.
+// GET /api/v2/{serviceName}/_table/{tableName}
+//
+// It takes the parameters of the v1 route, with these differences: without
+// limit every record is returned up to the principal's max limit, ids can be
+// matched against other columns with id_field, related embeds related
+// records, and include_count reports the total as meta.count.
+func (h *DreamFactoryHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	q := h.tables.recordQueryParams(r)
+	if queryString(r, "limit") == "" {
+		q.Limit = maxLimit(ctx, h.store)
+	}
+	if idField := queryString(r, "id_field"); idField != "" && len(q.IDs) > 0 {
+		for _, col := range strings.Split(idField, ",") {
+			q.KeyColumns = append(q.KeyColumns, strings.TrimSpace(col))
+		}
+	}
+	rel, err := h.relations(ctx, conn, serviceName, tableName, queryString(r, "related"), &q.Fields)
+	if err != nil {
+		writeDreamFactoryError(w, err)
+		return
+	}
+
+	selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, tableName, q)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	rows, err := h.tables.queryRows(ctx, conn.DB(), conn, serviceName, selectReq)
+	if err != nil {
+		code, msg := classifyDBError(err, "Query failed")
+		writeError(w, code, msg)
+		return
+	}
+
+	var meta *dreamFactoryMeta
+	if q.IncludeCount {
+		// Grouped and distinct rows are not counted by the database, as
+		// on the v1 route.
+		meta = &dreamFactoryMeta{Count: int64(q.Offset + len(rows))}
+		if len(selectReq.GroupBy) == 0 && !selectReq.Distinct {
+			if meta.Count, err = countSelect(ctx, conn, selectReq); err != nil {
+				code, msg := classifyDBError(err, "Count failed")
+				writeError(w, code, msg)
+				return
+			}
+		}
+	}
+
+	if err := h.embed(ctx, conn, serviceName, rel, rows); err != nil {
+		writeDreamFactoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dreamFactoryList{Resource: rows, Meta: meta})
+}
+
+// GetRecord retrieves a single record by primary key, with its related
+// records when related is given.
+// GET /api/v2/{serviceName}/_table/{tableName}/{id}
+func (h *DreamFactoryHandler) GetRecord(w http.ResponseWriter, r *http.Request) {
+	related := queryString(r, "related")
+	if related == "" {
+		h.tables.GetRecord(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	conn, keyColumns, key, ok := h.tables.recordTarget(w, r)
+	if !ok {
+		return
+	}
+	serviceName := chi.URLParam(r, "serviceName")
+	fields := queryString(r, "fields")
+	rel, err := h.relations(ctx, conn, serviceName, chi.URLParam(r, "tableName"), related, &fields)
+	if err != nil {
+		writeDreamFactoryError(w, err)
+		return
+	}
+	selectReq, err := h.tables.recordSelect(r, conn, keyColumns, key, fields)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	rows, err := h.tables.queryRows(ctx, conn.DB(), conn, serviceName, selectReq)
+	if err != nil {
+		code, msg := classifyDBError(err, "Query failed")
+		writeError(w, code, msg)
+		return
+	}
+	if len(rows) == 0 {
+		writeError(w, http.StatusNotFound, "Record not found: "+chi.URLParam(r, "id"))
+		return
+	}
+	if err := h.embed(ctx, conn, serviceName, rel, rows[:1]); err != nil {
+		writeDreamFactoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rows[0])
+}
+
+// dreamFactoryRelations are the relationships a read embeds.
+type dreamFactoryRelations struct {
+	set     *odataEntitySet
+	expands []odataExpand
+	extra   []string // columns selected only to join on, removed afterwards
+}
+
+// relations resolves the related parameter of a read, a comma-separated
+// list of relationship names or "*". fields is extended with the columns
+// the relationships join on. It returns nil when nothing is related.
+func (h *DreamFactoryHandler) relations(ctx context.Context, conn connector.Connector, serviceName, tableName, related string, fields *string) (*dreamFactoryRelations, error) {
+	if related == "" {
+		return nil, nil
+	}
+	snap, err := h.odata.schemas.get(ctx, conn, serviceName)
+	if err != nil {
+		return nil, &odataStatusError{code: http.StatusInternalServerError, msg: "Failed to introspect schema: " + err.Error()}
+	}
+	set := buildDreamFactoryModel(snap.schema).byName[tableName]
+	if set == nil {
+		return nil, &odataStatusError{code: http.StatusNotFound, msg: "Table not found: " + tableName}
+	}
+
+	rel := &dreamFactoryRelations{set: set}
+	for _, name := range strings.Split(related, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			rel.expands = append(rel.expands, odataExpand{nav: name, opts: odataOptions{top: -1}})
+		}
+	}
+	rel.expands = set.expansions(rel.expands)
+
+	selected := make(map[string]bool)
+	all := *fields == "" || *fields == "*"
+	if !all {
+		for _, f := range strings.Split(*fields, ",") {
+			selected[strings.TrimSpace(f)] = true
+		}
+	}
+	for _, ex := range rel.expands {
+		nav := set.nav[ex.nav]
+		if nav == nil {
+			return nil, badOData("Invalid relationship %s on %s", ex.nav, tableName)
+		}
+		if err := h.odata.authorize(ctx, serviceName, nav.target); err != nil {
+			return nil, err
+		}
+		for _, col := range nav.columns {
+			if !all && !selected[col] {
+				selected[col] = true
+				rel.extra = append(rel.extra, col)
+				*fields += "," + col
+			}
+		}
+	}
+	return rel, nil
+}
+
+// embed adds the related records to rows: a parent record as an object and
+// child records as an array.
+func (h *DreamFactoryHandler) embed(ctx context.Context, conn connector.Connector, serviceName string, rel *dreamFactoryRelations, rows []map[string]interface{}) error {
+	if rel == nil {
+		return nil
+	}
+	if err := h.odata.expand(ctx, conn, serviceName, rel.set, rows, rel.expands); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for _, col := range rel.extra {
+			delete(row, col)
+		}
+	}
+	return nil
+}
+
+// buildDreamFactoryModel generates the entity model of a schema with the
+// relationships named as DreamFactory names them: a parent after its table
+// and the foreign key columns (customers_by_customer_id on orders), children
+// after their table and the columns referencing the row (orders_by_customer_id
+// on customers). Many-to-many relationships through junction tables are not
+// generated.
+func buildDreamFactoryModel(schema *model.Schema) *odataModel {
+	m := buildODataModel(schema)
+	for _, set := range m.sets {
+		set.nav = make(map[string]*odataNav, len(set.navs))
+		for _, nav := range set.navs {
+			columns := nav.columns
+			if nav.collection {
+				columns = nav.targetColumns
+			}
+			nav.name = nav.target.def.Name + "_by_" + strings.Join(columns, "_")
+			if set.nav[nav.name] == nil {
+				set.nav[nav.name] = nav
+			}
+		}
+	}
+	return m
+}
+
+// writeDreamFactoryError writes the error of a failed relationship lookup:
+// client errors with their status, anything else as a database error.
+func writeDreamFactoryError(w http.ResponseWriter, err error) {
+	var status *odataStatusError
+	if errors.As(err, &status) {
+		writeError(w, status.code, status.msg)
+		return
+	}
+	code, msg := classifyDBError(err, "Query failed")
+	writeError(w, code, msg)
+}
+
+// ---------------------------------------------------------------------------
+// Sessions
+// ---------------------------------------------------------------------------
+
+// dreamFactorySession describes a session as DreamFactory does. Faucet's
+// accounts are admins, so every session is a system admin's.
+type dreamFactorySession struct {
+	SessionToken  string     `json:"session_token"`
+	SessionID     string     `json:"session_id"`
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Email         string     `json:"email"`
+	IsSysAdmin    bool       `json:"is_sys_admin"`
+	LastLoginDate *time.Time `json:"last_login_date"`
+	Host          string     `json:"host"`
+}
+
+func newDreamFactorySession(r *http.Request, admin *model.Admin, token string) dreamFactorySession {
+	first, last, _ := strings.Cut(admin.Name, " ")
+	return dreamFactorySession{
+		SessionToken:  token,
+		SessionID:     token,
+		ID:            admin.ID,
+		Name:          admin.Name,
+		FirstName:     first,
+		LastName:      last,
+		Email:         admin.Email,
+		IsSysAdmin:    true,
+		LastLoginDate: admin.LastLoginAt,
+		Host:          r.Host,
+	}
+}
+
+// Login authenticates an admin with email and password and returns a
+// session. The session_token authenticates later requests in the
+// X-DreamFactory-Session-Token header.
+// POST /api/v2/user/session
+// POST /api/v2/system/admin/session
+func (h *DreamFactoryHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	admin, status, msg := verifyLogin(r.Context(), h.store, req)
+	if admin == nil {
+		writeError(w, status, msg)
+		return
+	}
+	h.issueSession(w, r, admin)
+}
+
+// GetSession returns the session of the request's session token.
+// GET /api/v2/user/session
+// GET /api/v2/system/admin/session
+func (h *DreamFactoryHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	writeJSON(w, http.StatusOK, newDreamFactorySession(r, admin, token))
+}
+
+// RefreshSession replaces the request's session token with a new one.
+// PUT /api/v2/user/session
+// PUT /api/v2/system/admin/session
+func (h *DreamFactoryHandler) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+	h.issueSession(w, r, admin)
+}
+
+// Logout ends a session. Session tokens are stateless JWTs, so this is a
+// no-op on the server side, as for admin logouts.
+// DELETE /api/v2/user/session
+// DELETE /api/v2/system/admin/session
+func (h *DreamFactoryHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// sessionAdmin returns the admin whose session token authenticated the
+// request. API keys have no session.
+func (h *DreamFactoryHandler) sessionAdmin(w http.ResponseWriter, r *http.Request) (*model.Admin, bool) {
+	p := middleware.GetPrincipal(r.Context())
+	if p == nil || p.Type != "admin" {
+		writeError(w, http.StatusUnauthorized, "There is no valid session for the current request.")
+		return nil, false
+	}
+	admin, err := h.store.GetAdminByEmail(r.Context(), p.Email)
+	if err != nil || !admin.IsActive {
+		writeError(w, http.StatusUnauthorized, "There is no valid session for the current request.")
+		return nil, false
+	}
+	return admin, true
+}
+
+// issueSession issues a session token for admin and writes the session.
+func (h *DreamFactoryHandler) issueSession(w http.ResponseWriter, r *http.Request, admin *model.Admin) {
+	token, err := h.authSvc.IssueJWT(r.Context(), admin.ID, admin.Email, dreamFactorySessionTTL)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token: "+err.Error())
+		return
+	}
+	_ = h.store.UpdateAdminLastLogin(r.Context(), admin.ID)
+	now := time.Now().UTC()
+	admin.LastLoginAt = &now
+	writeJSON(w, http.StatusOK, newDreamFactorySession(r, admin, token))
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+func newDreamFactoryTestEnv(t *testing.T) *batchTestEnv {
+	t.Helper()
+	env := newGraphQLTestEnv(t)
+	dh := NewDreamFactoryHandler(env.registry, env.store, nil)
+	env.router.Route("/api/v2/{serviceName}/_table/{tableName}", func(r chi.Router) {
+		r.Use(middleware.DreamFactory)
+		r.Get("/", dh.QueryRecords)
+		r.Get("/{id}", dh.GetRecord)
+	})
+	return env
+}
+
+// dreamFactory issues a GET against the /api/v2 table routes and decodes
+// the response body.
+func (e *batchTestEnv) dreamFactory(t *testing.T, path string, wantStatus int) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/v2/testdb/_table"+path, nil)
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	if rr.Code != wantStatus {
+		t.Fatalf("GET %s: expected %d, got %d; body: %s", path, wantStatus, rr.Code, rr.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	return body
+}
+
+func dreamFactoryResource(t *testing.T, body map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	items, _ := body["resource"].([]interface{})
+	rows := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		rows[i], _ = item.(map[string]interface{})
+	}
+	return rows
+}
+
+func TestDreamFactoryQueryRecords(t *testing.T) {
+	env := newDreamFactoryTestEnv(t)
+
+	// meta is only present with include_count, and counts every match.
+	body := env.dreamFactory(t, "/orders?limit=1&include_count=true", http.StatusOK)
+	if rows := dreamFactoryResource(t, body); len(rows) != 1 {
+		t.Errorf("limit=1: got %d rows", len(rows))
+	}
+	if meta, _ := body["meta"].(map[string]interface{}); meta == nil || meta["count"] != float64(3) {
+		t.Errorf("include_count: got meta %v", body["meta"])
+	}
+	body = env.dreamFactory(t, "/orders", http.StatusOK)
+	if _, ok := body["meta"]; ok {
+		t.Errorf("unexpected meta without include_count: %v", body["meta"])
+	}
+	if rows := dreamFactoryResource(t, body); len(rows) != 3 {
+		t.Errorf("no limit: got %d rows, want all 3", len(rows))
+	}
+
+	rows := dreamFactoryResource(t, env.dreamFactory(t, "/orders?ids=paid&id_field=status&order=id", http.StatusOK))
+	if len(rows) != 2 || rows[0]["id"] != float64(1) || rows[1]["id"] != float64(3) {
+		t.Errorf("id_field: got %v", rows)
+	}
+}
+
+func TestDreamFactoryRelated(t *testing.T) {
+	env := newDreamFactoryTestEnv(t)
+
+	rows := dreamFactoryResource(t, env.dreamFactory(t, "/orders?related=users_by_user_id&order=id", http.StatusOK))
+	if len(rows) != 3 {
+		t.Fatalf("got %d orders", len(rows))
+	}
+	if user, _ := rows[2]["users_by_user_id"].(map[string]interface{}); user == nil || user["name"] != "Bob" {
+		t.Errorf("belongs-to: got %v", rows[2])
+	}
+
+	// Join columns missing from fields are selected and dropped again.
+	rows = dreamFactoryResource(t, env.dreamFactory(t, "/users?fields=name&related=orders_by_user_id&order=id", http.StatusOK))
+	if len(rows) != 2 {
+		t.Fatalf("got %d users", len(rows))
+	}
+	if _, ok := rows[0]["id"]; ok {
+		t.Errorf("fields: unexpected id in %v", rows[0])
+	}
+	if orders, _ := rows[0]["orders_by_user_id"].([]interface{}); len(orders) != 2 {
+		t.Errorf("has-many: got %v", rows[0])
+	}
+
+	record := env.dreamFactory(t, "/orders/1?related=*", http.StatusOK)
+	if items, _ := record["order_items_by_order_id"].([]interface{}); len(items) != 2 {
+		t.Errorf("related=*: got %v", record)
+	}
+	if user, _ := record["users_by_user_id"].(map[string]interface{}); user == nil || user["name"] != "Alice" {
+		t.Errorf("related=*: got %v", record)
+	}
+
+	// Errors use DreamFactory's envelope.
+	body := env.dreamFactory(t, "/orders?related=customers_by_id", http.StatusBadRequest)
+	e, _ := body["error"].(map[string]interface{})
+	if e == nil || e["status_code"] != float64(400) || e["code"] != float64(400) {
+		t.Errorf("error envelope: got %v", body)
+	}
+	if _, ok := e["context"]; !ok {
+		t.Errorf("error envelope: missing context in %v", e)
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// dryRunRequested reports whether a request asks for a dry run
+// (?dry_run=true). A dry run builds the request's SQL exactly as a real
+// request would and returns a model.DryRunResponse instead of the usual
+// response. Writes are executed in a transaction that is always rolled
+// back, so constraint errors and affected row counts are real; reads are
+// only built, and report how many rows their filter matches.
+func dryRunRequested(r *http.Request) bool {
+	return queryBool(r, "dry_run")
+}
+
+// sqlStatement is a built statement and its bound arguments. returnsRows
+// marks writes that return the rows they wrote.
+type sqlStatement struct {
+	sql         string
+	args        []interface{}
+	returnsRows bool
+}
+
+// returnsRows reports whether the INSERT and UPDATE statements conn builds
+// return the written rows: RETURNING, or OUTPUT on SQL Server.
+func returnsRows(conn connector.Connector) bool {
+	return conn.SupportsReturning() || conn.DriverName() == "mssql"
+}
+
+// execDryRun runs stmts in a rolled-back transaction and writes them with
+// the number of rows each affected. failMsg prefixes database errors.
+func execDryRun(w http.ResponseWriter, r *http.Request, conn connector.Connector, failMsg string, stmts ...sqlStatement) {
+	runDryRun(w, r, conn, failMsg, func(d *connector.DryRun) (interface{}, *int64, error) {
+		for _, stmt := range stmts {
+			if err := d.Run(r.Context(), stmt.returnsRows, stmt.sql, stmt.args...); err != nil {
+				return nil, nil, err
+			}
+		}
+		affected := d.Affected()
+		return nil, &affected, nil
+	})
+}
+
+// runDryRun calls fn with a dry run executor, rolls back whatever it wrote
+// and writes the recorded statements with the result and row count fn
+// reports. A failing statement is reported like the real request would
+// report it, with the statements run so far in the error context.
+func runDryRun(w http.ResponseWriter, r *http.Request, conn connector.Connector, failMsg string, fn func(d *connector.DryRun) (interface{}, *int64, error)) {
+	d, err := connector.BeginDryRun(r.Context(), conn)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+		return
+	}
+	result, affected, err := fn(d)
+	rbErr := d.Rollback()
+	if err != nil {
+		code, msg := classifyDBError(err, failMsg)
+		writeError(w, code, msg, map[string]interface{}{"dry_run": true, "statements": d.Statements})
+		return
+	}
+	if rbErr != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to roll back dry run: "+rbErr.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, model.DryRunResponse{
+		DryRun:     true,
+		Statements: d.Statements,
+		Affected:   affected,
+		RolledBack: true,
+		Result:     result,
+	})
+}
+
+// dryRunSelect writes the SELECT a read would run without running it,
+// along with the number of rows matching its filter. Grouped and distinct
+// reads, whose result rows are not table rows, report no count.
+func dryRunSelect(w http.ResponseWriter, r *http.Request, conn connector.Connector, selectReq connector.SelectRequest) {
+	sqlStr, args, err := conn.BuildSelect(r.Context(), selectReq)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build query: "+err.Error())
+		return
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	resp := model.DryRunResponse{
+		DryRun:     true,
+		Statements: []model.DryRunStatement{{SQL: sqlStr, Args: args}},
+	}
+
+	if len(selectReq.GroupBy) == 0 && !selectReq.Distinct {
+		countSQL, countArgs, err := conn.BuildCount(r.Context(), connector.CountRequest{
+			Table:  selectReq.Table,
+			Filter: selectReq.Filter,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to build query: "+err.Error())
+			return
+		}
+		var count int64
+		allCountArgs := append(append([]interface{}{}, selectReq.FilterArgs...), countArgs...)
+		if err := conn.DB().QueryRowxContext(r.Context(), countSQL, allCountArgs...).Scan(&count); err != nil {
+			code, msg := classifyDBError(err, "Query failed")
+			writeError(w, code, msg)
+			return
+		}
+		resp.Affected = &count
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dryRunDDL writes the statements of a schema change. Where DDL is
+// transactional they run in a rolled-back transaction, which surfaces
+// errors such as a missing table; elsewhere DDL commits implicitly, so the
+// statements are only built.
+func dryRunDDL(w http.ResponseWriter, r *http.Request, conn connector.Connector, failMsg string, stmts []string) {
+	if !conn.SupportsTransactionalDDL() {
+		d := &connector.DryRun{}
+		for _, stmt := range stmts {
+			d.Record(stmt, nil)
+		}
+		writeJSON(w, http.StatusOK, model.DryRunResponse{DryRun: true, Statements: d.Statements})
+		return
+	}
+	runDryRun(w, r, conn, failMsg, func(d *connector.DryRun) (interface{}, *int64, error) {
+		for _, stmt := range stmts {
+			if _, err := d.ExecContext(r.Context(), stmt); err != nil {
+				return nil, nil, err
+			}
+		}
+		// DDL affects no rows; drivers report zero.
+		for i := range d.Statements {
+			d.Statements[i].RowsAffected = nil
+		}
+		return nil, nil, nil
+	})
+}
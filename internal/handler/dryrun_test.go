@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func decodeDryRun(t *testing.T, code int, body []byte) model.DryRunResponse {
+	t.Helper()
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", code, body)
+	}
+	var resp model.DryRunResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.DryRun || len(resp.Statements) == 0 {
+		t.Fatalf("not a dry run response: %s", body)
+	}
+	return resp
+}
+
+func TestDryRun_Writes(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	cases := []struct {
+		method, path string
+		body         interface{}
+		verb         string
+		affected     int64
+	}{
+		{"DELETE", "/api/v1/testdb/_table/users?filter=" + "name%20LIKE%20%27%25o%25%27&dry_run=true", nil, "DELETE", 1},
+		{"DELETE", "/api/v1/testdb/_table/users/1?dry_run=true", nil, "DELETE", 1},
+		{"PATCH", "/api/v1/testdb/_table/users?ids=1,2&dry_run=true", map[string]interface{}{"name": "X"}, "UPDATE", 2},
+		{"PATCH", "/api/v1/testdb/_table/users/2?dry_run=true", map[string]interface{}{"name": "X"}, "UPDATE", 1},
+		{"PUT", "/api/v1/testdb/_table/users?dry_run=true", []map[string]interface{}{{"id": 1, "name": "X", "email": "x@example.com"}}, "UPDATE", 1},
+		{"POST", "/api/v1/testdb/_table/users?dry_run=true", []map[string]interface{}{{"name": "C", "email": "c@example.com"}, {"name": "D", "email": "d@example.com"}}, "INSERT", 2},
+	}
+	for _, c := range cases {
+		rr := env.do(t, c.method, c.path, c.body)
+		resp := decodeDryRun(t, rr.Code, rr.Body.Bytes())
+		stmt := resp.Statements[0]
+		if !strings.HasPrefix(stmt.SQL, c.verb) || len(stmt.Args) == 0 {
+			t.Errorf("%s %s: unexpected statement %+v", c.method, c.path, stmt)
+		}
+		if !resp.RolledBack || resp.Affected == nil || *resp.Affected != c.affected {
+			t.Errorf("%s %s: expected %d affected and a rollback, got %+v", c.method, c.path, c.affected, resp)
+		}
+	}
+
+	// Nothing was written.
+	conn, _ := env.registry.Get("testdb")
+	var names string
+	conn.DB().QueryRowxContext(context.Background(), "SELECT group_concat(name) FROM users ORDER BY id").Scan(&names)
+	if names != "Alice,Bob" {
+		t.Errorf("expected rows to be untouched, got %q", names)
+	}
+
+	// Database errors are reported like the real request would.
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/users?dry_run=true", map[string]interface{}{"name": "A", "email": "alice@example.com"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("duplicate: expected 409, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDryRun_Reads(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users?filter=name%3D%27Bob%27&limit=1&dry_run=true", nil)
+	resp := decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if !strings.HasPrefix(resp.Statements[0].SQL, "SELECT") || resp.Affected == nil || *resp.Affected != 1 || resp.RolledBack {
+		t.Errorf("query: unexpected response %+v", resp)
+	}
+
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/users/1?dry_run=true", nil)
+	resp = decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if resp.Affected == nil || *resp.Affected != 1 {
+		t.Errorf("record: unexpected response %+v", resp)
+	}
+}
+
+func TestDryRun_NestedAndBatch(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/orders?dry_run=true", map[string]interface{}{
+		"user": map[string]interface{}{"name": "Alice", "email": "alice@example.com"},
+		"order_items": []interface{}{
+			map[string]interface{}{"sku": "A-1", "qty": 2},
+			map[string]interface{}{"sku": "B-2", "qty": 1},
+		},
+	})
+	resp := decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if len(resp.Statements) != 4 || *resp.Affected != 4 || resp.Result == nil {
+		t.Errorf("nested: unexpected response %s", rr.Body.String())
+	}
+
+	rr = env.do(t, "POST", "/api/v1/testdb/_batch?dry_run=true", map[string]interface{}{"operations": []interface{}{
+		map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Bob", "email": "bob@example.com"}},
+		map[string]interface{}{"op": "update", "table": "users", "ids": []interface{}{"$ops[0].id"}, "record": map[string]interface{}{"name": "Robert"}},
+	}})
+	resp = decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if len(resp.Statements) != 2 || *resp.Affected != 2 {
+		t.Errorf("batch: unexpected response %s", rr.Body.String())
+	}
+
+	for _, table := range []string{"users", "orders", "order_items"} {
+		if n := env.countTable(t, table); n != 0 {
+			t.Errorf("expected %s to be empty, got %d rows", table, n)
+		}
+	}
+}
+
+func TestDryRun_Schema(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	rr := env.do(t, "DELETE", "/api/v1/testdb/_schema/users?dry_run=true", nil)
+	resp := decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if resp.Statements[0].SQL != `DROP TABLE "users"` || !resp.RolledBack {
+		t.Errorf("drop: unexpected response %s", rr.Body.String())
+	}
+	if n := env.countRows(t); n != 0 {
+		t.Errorf("expected users to survive, got %d rows", n)
+	}
+
+	// A failing statement surfaces its error.
+	rr = env.do(t, "DELETE", "/api/v1/testdb/_schema/missing?dry_run=true", nil)
+	if rr.Code == http.StatusOK {
+		t.Errorf("drop missing: expected an error, got 200; body: %s", rr.Body.String())
+	}
+
+	rr = env.do(t, "POST", "/api/v1/testdb/_schema?dry_run=true", model.TableSchema{
+		Name:    "notes",
+		Columns: []model.Column{{Name: "body", GoType: "string", Nullable: true}},
+	})
+	resp = decodeDryRun(t, rr.Code, rr.Body.Bytes())
+	if !strings.HasPrefix(resp.Statements[0].SQL, `CREATE TABLE "notes"`) {
+		t.Errorf("create: unexpected response %s", rr.Body.String())
+	}
+	conn, _ := env.registry.Get("testdb")
+	if names, _ := conn.GetTableNames(context.Background()); len(names) != 1 {
+		t.Errorf("expected only users, got %v", names)
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// numericFormat returns the service's numeric_format setting, or "" for the
+// default when the service has no stored configuration.
+func numericFormat(ctx context.Context, store *config.Store, serviceName string) string {
+	if store == nil {
+		return ""
+	}
+	svc, err := store.GetServiceByName(ctx, serviceName)
+	if err != nil {
+		return ""
+	}
+	return svc.NumericFormat
+}
+
+// encoder returns the value encoder for rows of a table. Without an
+// introspected schema the generic rules apply.
+func (h *TableHandler) encoder(ctx context.Context, conn connector.Connector, serviceName, tableName string) *connector.ValueEncoder {
+	var table *model.TableSchema
+	if ts, err := h.registry.TableSchema(ctx, serviceName, tableName); err == nil {
+		table = ts
+	}
+	return connector.NewValueEncoder(conn, table, numericFormat(ctx, h.store, serviceName))
+}
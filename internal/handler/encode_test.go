@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Column-typed value encoding tests
+// ---------------------------------------------------------------------------
+
+func createTypedItems(t *testing.T, env *batchTestEnv) {
+	t.Helper()
+	conn, _ := env.registry.Get("testdb")
+	for _, stmt := range []string{
+		`CREATE TABLE items (id INTEGER PRIMARY KEY, active BOOLEAN, tags JSON, data BLOB, created_at DATETIME)`,
+		`INSERT INTO items VALUES (1, 1, '["a","b"]', x'00ff', '2024-05-01 10:00:00')`,
+	} {
+		if _, err := conn.DB().ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("setup %q: %v", stmt, err)
+		}
+	}
+}
+
+func TestQueryRecords_TypedValues(t *testing.T) {
+	env := newBatchTestEnv(t)
+	createTypedItems(t, env)
+
+	want := map[string]interface{}{
+		"id":         float64(1),
+		"active":     true,
+		"tags":       []interface{}{"a", "b"},
+		"data":       "AP8=",
+		"created_at": "2024-05-01T10:00:00Z",
+	}
+
+	rr := env.get(t, "/api/v1/testdb/_table/items", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if got := decodeResources(t, rr); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("unexpected records %#v", got)
+	}
+
+	// NDJSON and single-record reads encode values the same way.
+	rr = env.get(t, "/api/v1/testdb/_table/items?format=ndjson", "")
+	var line map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &line); err != nil || !reflect.DeepEqual(line, want) {
+		t.Errorf("unexpected NDJSON %s (%v)", rr.Body.String(), err)
+	}
+	rr = env.get(t, "/api/v1/testdb/_table/items/1", "")
+	var record map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &record); err != nil || !reflect.DeepEqual(record, want) {
+		t.Errorf("unexpected record %s (%v)", rr.Body.String(), err)
+	}
+
+	rr = env.get(t, "/api/v1/testdb/_table/items?format=csv", "")
+	lines := strings.Split(rr.Body.String(), "\r\n")
+	if len(lines) < 2 || lines[1] != `1,true,"[""a"",""b""]",AP8=,2024-05-01T10:00:00Z` {
+		t.Errorf("unexpected CSV %q", rr.Body.String())
+	}
+}
+
+func TestNamedQuery_TypedValues(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+	createTypedItems(t, env.batchTestEnv)
+	env.create(t, map[string]interface{}{"name": "item_flags", "sql": "SELECT active, tags, 1 + 1 AS two FROM items"})
+
+	rr := env.get(t, "/api/v1/testdb/_query/item_flags", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	got := decodeResources(t, rr)
+	want := map[string]interface{}{"active": true, "tags": []interface{}{"a", "b"}, "two": float64(2)}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("unexpected records %#v", got)
+	}
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/connector"
+)
+
+// etagMember is the member that carries a record's ETag in list responses
+// with include_etags.
+const etagMember = "_etag"
+
+// versionColumn returns the service's configured version column when the
+// table has it, or "" when record ETags fall back to a row hash.
+func (h *TableHandler) versionColumn(ctx context.Context, serviceName, tableName string) string {
+	if h.store == nil {
+		return ""
+	}
+	svc, err := h.store.GetServiceByName(ctx, serviceName)
+	if err != nil || svc.VersionColumn == "" {
+		return ""
+	}
+	cols := h.tableColumns(ctx, serviceName, tableName)
+	if cols == nil || cols.Check(svc.VersionColumn) != nil {
+		return ""
+	}
+	return svc.VersionColumn
+}
+
+// recordETag returns the strong entity tag of a record: a digest of the
+// version column value when versionColumn is set, otherwise of the whole
+// row. ok is false when the record lacks the version column.
+func recordETag(record map[string]interface{}, versionColumn string) (string, bool) {
+	var v interface{} = record
+	if versionColumn != "" {
+		var ok bool
+		if v, ok = record[versionColumn]; !ok {
+			return "", false
+		}
+	}
+	// encoding/json sorts map keys, so equal rows encode identically.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, true
+}
+
+// etagMatches reports whether etag satisfies an If-Match or If-None-Match
+// header value: "*" or a comma-separated list of entity tags. Weak tags
+// compare by their opaque value.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectIfMatch answers 400 when a collection write carries If-Match. Only
+// the single-record routes evaluate it, and ignoring it would apply the
+// write unguarded. It reports whether the request was rejected.
+func rejectIfMatch(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("If-Match") == "" {
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "If-Match is only supported on /_table/{table}/{id}")
+	return true
+}
+
+// nextVersion returns the value a conditional write stores in the version
+// column: the current value plus one for numeric columns, otherwise the
+// current time. Bumping it is what makes a stale If-Match fail.
+func nextVersion(current interface{}) interface{} {
+	switch v := current.(type) {
+	case int64:
+		return v + 1
+	case int32:
+		return int64(v) + 1
+	case int:
+		return int64(v) + 1
+	case float64:
+		return v + 1
+	}
+	return time.Now().UTC()
+}
+
+// lockRecord reads and locks the record addressed by a single-record route
+// inside tx and checks it against the If-Match header. It writes 412 and
+// returns ok=false when the record is gone or its ETag does not match.
+//
+// The lock holds concurrent writers off until tx ends, which is what guards
+// row-hash ETags: they have no version column for the write to match. SQLite
+// needs no row lock: its writers take the database write lock, and a write
+// that meets another transaction's lock fails (see writeConditionalError).
+func (h *TableHandler) lockRecord(w http.ResponseWriter, r *http.Request, tx connector.QueryExecutor, conn connector.Connector, keyColumns []string, key interface{}, versionCol string) (map[string]interface{}, bool) {
+	selectReq, err := h.recordSelect(r, conn, keyColumns, key, "")
+	if err != nil {
+		writeQueryError(w, err)
+		return nil, false
+	}
+	selectReq.Lock = true
+	// The row is read as scanned so the version predicate binds the value
+	// the database returned; the ETag is computed on the encoded row that
+	// clients see.
+	current, err := fetchRecord(r.Context(), tx, conn, nil, selectReq)
+	if err != nil {
+		code, msg := classifyDBError(err, "Query failed")
+		writeError(w, code, msg)
+		return nil, false
+	}
+	if current == nil {
+		writeError(w, http.StatusPreconditionFailed, "Precondition failed: record not found: "+chi.URLParam(r, "id"))
+		return nil, false
+	}
+	encoded := make(map[string]interface{}, len(current))
+	for k, v := range current {
+		encoded[k] = v
+	}
+	h.encoder(r.Context(), conn, chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName")).EncodeRow(encoded)
+	if etag, ok := recordETag(encoded, versionCol); !ok || !etagMatches(r.Header.Get("If-Match"), etag) {
+		writeError(w, http.StatusPreconditionFailed, "Precondition failed: record has been modified")
+		return nil, false
+	}
+	return current, true
+}
+
+// writeConditionalError reports a failed conditional write. A write that
+// lost a lock conflict to a concurrent transaction is a 409, which the
+// client resolves by reading the record again.
+func writeConditionalError(w http.ResponseWriter, err error, fallbackMsg string) {
+	lower := strings.ToLower(err.Error())
+	if strings.Contains(lower, "database is locked") || strings.Contains(lower, "deadlock") {
+		writeError(w, http.StatusConflict, "Conflict: record is being modified concurrently")
+		return
+	}
+	code, msg := classifyDBError(err, fallbackMsg)
+	writeError(w, code, msg)
+}
+
+// versionPredicate returns a WHERE fragment matching the version the record
+// had when it was read, numbered from placeholder index startIdx.
+func versionPredicate(conn connector.Connector, versionCol string, current map[string]interface{}, startIdx int) (string, []interface{}) {
+	if versionCol == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("%s = %s", conn.QuoteIdentifier(versionCol), conn.ParameterPlaceholder(startIdx)),
+		[]interface{}{current[versionCol]}
+}
+
+// updateRecordIfMatch applies a single-record update guarded by If-Match.
+// The record is locked and compared in a transaction; with a version column
+// the UPDATE also matches the version that was read and stores the next
+// one, so a concurrent writer holding the same ETag gets 412.
+func (h *TableHandler) updateRecordIfMatch(w http.ResponseWriter, r *http.Request, conn connector.Connector, keyColumns []string, key interface{}, record map[string]interface{}) {
+	ctx := r.Context()
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+	versionCol := h.versionColumn(ctx, serviceName, tableName)
+	enc := h.encoder(ctx, conn, serviceName, tableName)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, ok := h.lockRecord(w, r, tx, conn, keyColumns, key, versionCol)
+	if !ok {
+		return
+	}
+	if versionCol != "" {
+		if _, set := record[versionCol]; !set {
+			record[versionCol] = nextVersion(current[versionCol])
+		}
+	}
+
+	filter, filterArgs := versionPredicate(conn, versionCol, current, len(record)+1)
+	sqlStr, args, err := conn.BuildUpdate(ctx, connector.UpdateRequest{
+		Table:      tableName,
+		Record:     record,
+		Filter:     filter,
+		FilterArgs: filterArgs,
+		IDs:        []interface{}{key},
+		KeyColumns: keyColumns,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build update: "+err.Error())
+		return
+	}
+
+	var updated map[string]interface{}
+	if conn.SupportsReturning() {
+		rows, err := tx.QueryxContext(ctx, sqlStr, args...)
+		if err == nil {
+			if rows.Next() {
+				updated = make(map[string]interface{})
+				err = rows.MapScan(updated)
+				enc.EncodeRow(updated)
+			}
+			// A driver error ends the rows like a missed version does; it
+			// must not be reported as a failed precondition.
+			if err == nil {
+				err = rows.Err()
+			}
+			rows.Close()
+		}
+		if err != nil {
+			writeConditionalError(w, err, "Update failed")
+			return
+		}
+	} else {
+		result, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			writeConditionalError(w, err, "Update failed")
+			return
+		}
+		// Without a version predicate the row is known to exist, and MySQL
+		// reports zero affected rows for an update that changed nothing.
+		if affected, err := result.RowsAffected(); err != nil || affected > 0 || versionCol == "" {
+			selectReq, err := h.recordSelect(r, conn, keyColumns, key, "")
+			if err == nil {
+				updated, err = fetchRecord(ctx, tx, conn, enc, selectReq)
+			}
+			if err != nil {
+				code, msg := classifyDBError(err, "Query failed")
+				writeError(w, code, msg)
+				return
+			}
+		}
+	}
+	if len(updated) == 0 {
+		writeError(w, http.StatusPreconditionFailed, "Precondition failed: record has been modified")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	if etag, ok := recordETag(updated, versionCol); ok {
+		w.Header().Set("ETag", etag)
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// deleteRecordIfMatch deletes a single record guarded by If-Match, reporting
+// 412 when the record changed since the client read it.
+func (h *TableHandler) deleteRecordIfMatch(w http.ResponseWriter, r *http.Request, conn connector.Connector, keyColumns []string, key interface{}) bool {
+	ctx := r.Context()
+	versionCol := h.versionColumn(ctx, chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName"))
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+		return false
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, ok := h.lockRecord(w, r, tx, conn, keyColumns, key, versionCol)
+	if !ok {
+		return false
+	}
+
+	filter, filterArgs := versionPredicate(conn, versionCol, current, 1)
+	sqlStr, args, err := conn.BuildDelete(ctx, connector.DeleteRequest{
+		Table:      chi.URLParam(r, "tableName"),
+		Filter:     filter,
+		FilterArgs: filterArgs,
+		IDs:        []interface{}{key},
+		KeyColumns: keyColumns,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build delete: "+err.Error())
+		return false
+	}
+	result, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		writeConditionalError(w, err, "Delete failed")
+		return false
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		writeError(w, http.StatusPreconditionFailed, "Precondition failed: record has been modified")
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to commit transaction: "+err.Error())
+		return false
+	}
+	return true
+}
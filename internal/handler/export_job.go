@@ -0,0 +1,803 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/export"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// Export job defaults.
+const (
+	DefaultExportWorkers   = 2
+	DefaultExportRetention = 24 * time.Hour
+
+	exportPollInterval     = 5 * time.Second  // how often idle workers look for jobs they were not woken for
+	exportProgressInterval = time.Second      // how often a running job stores its row count
+	exportCleanupInterval  = time.Minute      // how often expired jobs are removed
+	exportLinkTTL          = 15 * time.Minute // validity of presigned download links
+)
+
+// ExportConfig configures background export jobs.
+type ExportConfig struct {
+	Target    export.Target // where finished files are kept (default: a directory under os.TempDir())
+	Workers   int           // exports run at the same time (default DefaultExportWorkers)
+	Retention time.Duration // how long finished jobs and their files are kept (default DefaultExportRetention)
+	Logger    *slog.Logger
+}
+
+// ExportHandler runs exports of tables and saved queries as background
+// jobs, so exports of any size are not bound by the server's write timeout.
+// Jobs are tracked in the config store: a pool of workers claims pending
+// jobs, writes each to a staged file and hands it to the export target.
+// Finished jobs and their files are removed once they expire.
+//
+// NewExportHandler starts the workers; Close stops them.
+type ExportHandler struct {
+	registry  *connector.Registry
+	store     *config.Store
+	tables    *TableHandler
+	target    export.Target
+	retention time.Duration
+	logger    *slog.Logger
+
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	wake    chan struct{}
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // by job ID
+}
+
+// NewExportHandler creates an ExportHandler and starts its workers. Jobs
+// left running by a previous process are marked failed; pending jobs are
+// picked up.
+func NewExportHandler(registry *connector.Registry, store *config.Store, cfg ExportConfig) (*ExportHandler, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultExportWorkers
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultExportRetention
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Target == nil {
+		target, err := export.NewDirTarget(filepath.Join(os.TempDir(), "faucet-exports"))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Target = target
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	h := &ExportHandler{
+		registry:  registry,
+		store:     store,
+		tables:    NewTableHandler(registry, store),
+		target:    cfg.Target,
+		retention: cfg.Retention,
+		logger:    cfg.Logger,
+		ctx:       ctx,
+		stop:      stop,
+		wake:      make(chan struct{}, cfg.Workers),
+		running:   make(map[string]context.CancelFunc),
+	}
+
+	if err := store.FailRunningExportJobs(ctx, "Interrupted by a server restart", h.expiry()); err != nil {
+		stop()
+		return nil, err
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		h.wg.Add(1)
+		go h.work()
+	}
+	h.wg.Add(1)
+	go h.cleanupLoop()
+	return h, nil
+}
+
+// Close stops the workers and waits for them to exit. Running jobs are
+// interrupted and marked failed.
+func (h *ExportHandler) Close() {
+	h.stop()
+	h.wg.Wait()
+}
+
+// ---------------------------------------------------------------------------
+// HTTP endpoints
+// ---------------------------------------------------------------------------
+
+// exportRequest is the body accepted by CreateExport.
+type exportRequest struct {
+	Table  string                 `json:"table"`
+	Query  string                 `json:"query"`
+	Args   map[string]interface{} `json:"args"`
+	Filter string                 `json:"filter"`
+	Fields []string               `json:"fields"`
+	Order  []string               `json:"order"`
+	Format string                 `json:"format"`
+	Gzip   bool                   `json:"gzip"`
+}
+
+// CreateExport queues an export of a table or saved query and returns the
+// job with 202 Accepted. The request is checked up front: unknown columns,
+// a bad filter or missing query arguments are rejected with 400 rather than
+// failing the job later.
+// POST /api/v1/{serviceName}/_export
+//
+//	{"table": "orders", "filter": "status = 'paid'", "format": "parquet"}
+//	{"query": "top_customers", "args": {"region": "EU"}, "format": "csv", "gzip": true}
+//
+// API keys need GET access to the table or saved query.
+func (h *ExportHandler) CreateExport(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	var req exportRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&req)
+	r.Body.Close()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if (req.Table == "") == (req.Query == "") {
+		writeError(w, http.StatusBadRequest, "Provide either table or query")
+		return
+	}
+	switch req.Format {
+	case "":
+		req.Format = formatCSV
+	case formatCSV, formatNDJSON, formatParquet:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid format: use csv, ndjson or parquet")
+		return
+	}
+
+	job := &model.ExportJob{
+		ID:          uuid.NewString(),
+		ServiceName: serviceName,
+		Format:      req.Format,
+		Gzip:        req.Gzip,
+		Owner:       exportOwner(r.Context()),
+	}
+
+	if req.Table != "" {
+		allowed, err := authorizeComponent(r.Context(), h.store, serviceName,
+			[]string{"_table/*", "_table/" + req.Table}, model.VerbGet)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check access: "+err.Error())
+			return
+		}
+		if !allowed {
+			writeError(w, http.StatusForbidden, "Access denied to table: "+req.Table)
+			return
+		}
+		if _, err := h.registry.TableSchema(r.Context(), serviceName, req.Table); err != nil {
+			writeError(w, http.StatusNotFound, "Table not found: "+req.Table)
+			return
+		}
+
+		job.Table = req.Table
+		job.Filter = req.Filter
+		job.Fields = strings.Join(req.Fields, ",")
+		job.Order = strings.Join(req.Order, ",")
+		if _, err := h.tables.compileQuery(r.Context(), conn, serviceName, job.Table, exportQuery(job)); err != nil {
+			writeQueryError(w, err)
+			return
+		}
+	} else {
+		if req.Filter != "" || len(req.Fields) > 0 || len(req.Order) > 0 {
+			writeError(w, http.StatusBadRequest, "filter, fields and order apply to table exports only")
+			return
+		}
+		allowed, err := authorizeComponent(r.Context(), h.store, serviceName,
+			[]string{"_query/*", "_query/" + req.Query}, model.VerbGet)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check access: "+err.Error())
+			return
+		}
+		if !allowed {
+			writeError(w, http.StatusForbidden, "Access denied to query: "+req.Query)
+			return
+		}
+		nq, err := h.store.GetNamedQuery(r.Context(), serviceName, req.Query)
+		if err != nil {
+			if err == config.ErrNotFound {
+				writeError(w, http.StatusNotFound, "Query not found: "+req.Query)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to get query: "+err.Error())
+			return
+		}
+		if nq.Write {
+			writeError(w, http.StatusBadRequest, "Query "+req.Query+" modifies data and cannot be exported")
+			return
+		}
+		if _, _, err := query.BindNamedQuery(nq, req.Args, conn.ParameterPlaceholder); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid query arguments: "+err.Error())
+			return
+		}
+
+		job.Query = req.Query
+		job.Args = req.Args
+	}
+
+	if err := h.store.CreateExportJob(r.Context(), job); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create export: "+err.Error())
+		return
+	}
+	h.notify()
+
+	w.Header().Set("Location", exportPath(job))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// ListExports returns the export jobs of a service, newest first. API keys
+// only see the jobs they created.
+// GET /api/v1/{serviceName}/_export
+func (h *ExportHandler) ListExports(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+
+	owner := ""
+	if p := middleware.GetPrincipal(r.Context()); p != nil && !p.IsAdmin {
+		owner = exportOwner(r.Context())
+	}
+	jobs, err := h.store.ListExportJobs(r.Context(), serviceName, owner)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list exports: "+err.Error())
+		return
+	}
+	for i := range jobs {
+		setDownloadURL(&jobs[i])
+	}
+	if jobs == nil {
+		jobs = []model.ExportJob{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resource": jobs})
+}
+
+// GetExport returns an export job: its status, the rows written so far and,
+// once completed, a download_url.
+// GET /api/v1/{serviceName}/_export/{exportId}
+func (h *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+	setDownloadURL(job)
+	writeJSON(w, http.StatusOK, job)
+}
+
+// DownloadExport serves the file of a completed export job. Files in a
+// local directory are served directly, with no write timeout; files in S3
+// are served by a redirect to a presigned URL.
+// GET /api/v1/{serviceName}/_export/{exportId}/download
+func (h *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != model.ExportCompleted {
+		writeError(w, http.StatusConflict, "Export is not complete: "+job.Status)
+		return
+	}
+	filename := exportFilename(job)
+
+	if linker, ok := h.target.(export.Linker); ok {
+		url, err := linker.Link(r.Context(), job.Location, filename, exportLinkTTL)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to create download link: "+err.Error())
+			return
+		}
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	opener, ok := h.target.(export.Opener)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Export target does not support downloads")
+		return
+	}
+	f, err := opener.Open(job.Location)
+	if err != nil {
+		writeError(w, http.StatusGone, "Export file is no longer available")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read export: "+err.Error())
+		return
+	}
+
+	// Large files take longer to send than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", exportContentType(job))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
+// CancelExport cancels a pending or running export job, which is then kept
+// with status "cancelled" until it expires. A finished job is deleted
+// together with its file.
+// DELETE /api/v1/{serviceName}/_export/{exportId}
+func (h *ExportHandler) CancelExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+
+	if !job.Done() {
+		cancelled, err := h.store.CancelExportJob(r.Context(), job.ID, h.expiry())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to cancel export: "+err.Error())
+			return
+		}
+		if cancelled {
+			h.mu.Lock()
+			if cancel, ok := h.running[job.ID]; ok {
+				cancel()
+			}
+			h.mu.Unlock()
+
+			job, err = h.store.GetExportJob(r.Context(), job.ID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to get export: "+err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, job)
+			return
+		}
+		// The job finished in the meantime; delete it instead.
+	}
+
+	if err := h.remove(r.Context(), job); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete export: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Export removed: " + job.ID,
+	})
+}
+
+// visibleJob loads the export job named in the URL, writing a 404 when it
+// does not exist, belongs to another service or, for API keys, was created
+// by another principal.
+func (h *ExportHandler) visibleJob(w http.ResponseWriter, r *http.Request) (*model.ExportJob, bool) {
+	id := chi.URLParam(r, "exportId")
+	job, err := h.store.GetExportJob(r.Context(), id)
+	if err != nil && err != config.ErrNotFound {
+		writeError(w, http.StatusInternalServerError, "Failed to get export: "+err.Error())
+		return nil, false
+	}
+	if job == nil || job.ServiceName != chi.URLParam(r, "serviceName") || !canSeeExport(r.Context(), job) {
+		writeError(w, http.StatusNotFound, "Export not found: "+id)
+		return nil, false
+	}
+	return job, true
+}
+
+// exportOwner names the principal that creates an export job.
+func exportOwner(ctx context.Context) string {
+	p := middleware.GetPrincipal(ctx)
+	switch {
+	case p == nil:
+		return "anonymous"
+	case p.IsAdmin:
+		return fmt.Sprintf("admin:%d", p.AdminID)
+	default:
+		return fmt.Sprintf("key:%d", p.KeyID)
+	}
+}
+
+// canSeeExport reports whether the request's principal may see job. Admins
+// see every job; API keys see their own.
+func canSeeExport(ctx context.Context, job *model.ExportJob) bool {
+	p := middleware.GetPrincipal(ctx)
+	if p == nil || p.IsAdmin {
+		return true
+	}
+	return job.Owner == exportOwner(ctx)
+}
+
+func exportPath(job *model.ExportJob) string {
+	return "/api/v1/" + job.ServiceName + "/_export/" + job.ID
+}
+
+// setDownloadURL fills in the download link of a completed job.
+func setDownloadURL(job *model.ExportJob) {
+	if job.Status == model.ExportCompleted {
+		job.DownloadURL = exportPath(job) + "/download"
+	}
+}
+
+// exportExtension returns the file extension of a job's output.
+func exportExtension(job *model.ExportJob) string {
+	ext := "." + job.Format
+	if job.Format == formatParquet {
+		ext = export.Extension(export.Parquet)
+	}
+	if job.Gzip {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// exportFilename is the name a job's file is downloaded as: the table or
+// query name with the format's extension.
+func exportFilename(job *model.ExportJob) string {
+	name := job.Table
+	if name == "" {
+		name = job.Query
+	}
+	return name + exportExtension(job)
+}
+
+func exportContentType(job *model.ExportJob) string {
+	switch {
+	case job.Gzip:
+		return "application/gzip"
+	case job.Format == formatNDJSON:
+		return "application/x-ndjson"
+	case job.Format == formatParquet:
+		return export.ContentType(export.Parquet)
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// exportQuery is the table read an export job runs: every matching row.
+func exportQuery(job *model.ExportJob) recordQuery {
+	return recordQuery{Filter: job.Filter, Fields: job.Fields, Order: job.Order}
+}
+
+// ---------------------------------------------------------------------------
+// Workers
+// ---------------------------------------------------------------------------
+
+// notify wakes an idle worker to claim a new job.
+func (h *ExportHandler) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// expiry returns when a job finishing now expires.
+func (h *ExportHandler) expiry() time.Time {
+	return time.Now().UTC().Add(h.retention)
+}
+
+// work claims and runs pending jobs until the handler is closed.
+func (h *ExportHandler) work() {
+	defer h.wg.Done()
+	for {
+		job, err := h.store.ClaimExportJob(h.ctx)
+		if err != nil && h.ctx.Err() == nil {
+			h.logger.Error("claim export job", "error", err)
+		}
+		if job != nil {
+			h.run(job)
+			continue
+		}
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-h.wake:
+		case <-time.After(exportPollInterval):
+		}
+	}
+}
+
+// run runs a claimed job and stores its outcome. The job can be cancelled
+// through CancelExport while it runs.
+func (h *ExportHandler) run(job *model.ExportJob) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	h.mu.Lock()
+	h.running[job.ID] = cancel
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.running, job.ID)
+		h.mu.Unlock()
+		cancel()
+	}()
+
+	key := job.ID + exportExtension(job)
+	progress := &exportProgress{ctx: ctx, store: h.store, id: job.ID, last: time.Now()}
+	size, err := h.write(ctx, job, key, progress)
+
+	job.Rows, job.Bytes = progress.rows, size
+	expires := h.expiry()
+	job.ExpiresAt = &expires
+	switch {
+	case err == nil:
+		job.Status = model.ExportCompleted
+		job.Location = key
+	case h.ctx.Err() != nil:
+		job.Status = model.ExportFailed
+		job.Error = "Interrupted by server shutdown"
+	default:
+		job.Status = model.ExportFailed
+		job.Error = err.Error()
+	}
+
+	// The job context may be cancelled; the outcome must still be stored.
+	stored, ferr := h.store.FinishExportJob(context.Background(), job)
+	if ferr != nil {
+		h.logger.Error("finish export job", "id", job.ID, "error", ferr)
+	}
+	if !stored && err == nil {
+		// Cancelled after the file was stored.
+		h.target.Delete(context.Background(), key)
+	}
+}
+
+// write runs the job's query, writes the result to a staged file and hands
+// it to the target under key. It returns the size of the file.
+func (h *ExportHandler) write(ctx context.Context, job *model.ExportJob, key string, progress *exportProgress) (int64, error) {
+	conn, err := h.registry.Get(job.ServiceName)
+	if err != nil {
+		return 0, fmt.Errorf("service not found: %s", job.ServiceName)
+	}
+
+	rows, table, enc, err := h.open(ctx, conn, job)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	f, err := os.CreateTemp(h.target.TempDir(), ".faucet-export-*")
+	if err != nil {
+		return 0, fmt.Errorf("create export file: %w", err)
+	}
+	staged := f.Name()
+
+	var out io.Writer = f
+	var gz *gzip.Writer
+	if job.Gzip {
+		gz = gzip.NewWriter(f)
+		out = gz
+	}
+	buf := bufio.NewWriterSize(out, 64*1024)
+
+	err = writeExportRows(ctx, job.Format, buf, rows, table, enc, progress)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(staged); err == nil {
+			size = info.Size()
+		}
+	}
+	if err != nil {
+		os.Remove(staged)
+		return 0, err
+	}
+
+	if err := h.target.Put(ctx, key, staged); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// open runs the read behind a job and returns its rows, the table schema
+// (nil for saved queries) and the encoder of its values.
+func (h *ExportHandler) open(ctx context.Context, conn connector.Connector, job *model.ExportJob) (*sqlx.Rows, *model.TableSchema, *connector.ValueEncoder, error) {
+	if job.Table != "" {
+		table, err := h.registry.TableSchema(ctx, job.ServiceName, job.Table)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("introspect table %s: %w", job.Table, err)
+		}
+		selectReq, err := h.tables.compileQuery(ctx, conn, job.ServiceName, job.Table, exportQuery(job))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sqlStr, args, err := conn.BuildSelect(ctx, selectReq)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("build query: %w", err)
+		}
+		rows, err := conn.DB().QueryxContext(ctx, sqlStr, args...)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return rows, table, h.tables.encoder(ctx, conn, job.ServiceName, job.Table), nil
+	}
+
+	nq, err := h.store.GetNamedQuery(ctx, job.ServiceName, job.Query)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("get query %s: %w", job.Query, err)
+	}
+	if nq.Write {
+		return nil, nil, nil, fmt.Errorf("query %s modifies data and cannot be exported", job.Query)
+	}
+	sqlStr, params, err := query.BindNamedQuery(nq, job.Args, conn.ParameterPlaceholder)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rows, err := conn.DB().QueryxContext(ctx, sqlStr, params...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, nil, nil, err
+	}
+	return rows, nil, connector.NewResultEncoder(conn, types, numericFormat(ctx, h.store, job.ServiceName)), nil
+}
+
+// writeExportRows writes every row in format to out.
+func writeExportRows(ctx context.Context, format string, out io.Writer, rows *sqlx.Rows, table *model.TableSchema, enc *connector.ValueEncoder, progress *exportProgress) error {
+	if format == formatParquet {
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+		ew, err := export.NewWriter(out, export.Parquet, export.Schema(table, types), 0)
+		if err != nil {
+			return err
+		}
+		if _, err := ew.WriteRows(ctx, &progressRows{Rows: rows, progress: progress}); err != nil {
+			ew.Close()
+			return err
+		}
+		return ew.Close()
+	}
+
+	var write func(map[string]interface{}) error
+	var flush func() error
+	switch format {
+	case formatNDJSON:
+		lines := json.NewEncoder(out)
+		write = func(row map[string]interface{}) error { return lines.Encode(row) }
+		flush = func() error { return nil }
+	default:
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		tabular := newFileTabularWriter(out, format)
+		if err := tabular.header(columns); err != nil {
+			return err
+		}
+		write = tabular.write
+		flush = tabular.flush
+	}
+
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return err
+		}
+		enc.EncodeRow(row)
+		if err := write(row); err != nil {
+			return err
+		}
+		progress.add()
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// exportProgress counts the rows a job has written and stores the count
+// at most once per exportProgressInterval.
+type exportProgress struct {
+	ctx   context.Context
+	store *config.Store
+	id    string
+	rows  int64
+	last  time.Time
+}
+
+func (p *exportProgress) add() {
+	p.rows++
+	if now := time.Now(); now.Sub(p.last) >= exportProgressInterval {
+		p.last = now
+		p.store.UpdateExportProgress(p.ctx, p.id, p.rows)
+	}
+}
+
+// progressRows counts the rows an export.Writer reads.
+type progressRows struct {
+	export.Rows
+	progress *exportProgress
+}
+
+func (r *progressRows) Next() bool {
+	if !r.Rows.Next() {
+		return false
+	}
+	r.progress.add()
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// Retention
+// ---------------------------------------------------------------------------
+
+// cleanupLoop removes expired jobs until the handler is closed.
+func (h *ExportHandler) cleanupLoop() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(exportCleanupInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.Cleanup(h.ctx); err != nil && h.ctx.Err() == nil {
+			h.logger.Error("clean up exports", "error", err)
+		}
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup deletes the jobs whose retention has passed, with their files.
+func (h *ExportHandler) Cleanup(ctx context.Context) error {
+	jobs, err := h.store.ExpiredExportJobs(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		if err := h.remove(ctx, &jobs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes a finished job and its file.
+func (h *ExportHandler) remove(ctx context.Context, job *model.ExportJob) error {
+	if job.Location != "" {
+		if err := h.target.Delete(ctx, job.Location); err != nil {
+			return err
+		}
+	}
+	if err := h.store.DeleteExportJob(ctx, job.ID); err != nil && err != config.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/export"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// ---------------------------------------------------------------------------
+// Background export jobs
+// ---------------------------------------------------------------------------
+
+func newExportTestEnv(t *testing.T) (*batchTestEnv, *ExportHandler) {
+	t.Helper()
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	target, err := export.NewDirTarget(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirTarget: %v", err)
+	}
+	eh, err := NewExportHandler(env.registry, env.store, ExportConfig{Target: target, Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewExportHandler: %v", err)
+	}
+	t.Cleanup(eh.Close)
+
+	env.router.Post("/api/v1/{serviceName}/_export", eh.CreateExport)
+	env.router.Get("/api/v1/{serviceName}/_export", eh.ListExports)
+	env.router.Get("/api/v1/{serviceName}/_export/{exportId}", eh.GetExport)
+	env.router.Get("/api/v1/{serviceName}/_export/{exportId}/download", eh.DownloadExport)
+	env.router.Delete("/api/v1/{serviceName}/_export/{exportId}", eh.CancelExport)
+	return env, eh
+}
+
+// createExport posts an export request as p and returns the queued job.
+func createExport(t *testing.T, env *batchTestEnv, p *middleware.Principal, body map[string]interface{}) *model.ExportJob {
+	t.Helper()
+	rr := env.doAs(t, p, "POST", "/api/v1/testdb/_export", body)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("create export: expected 202, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var job model.ExportJob
+	json.Unmarshal(rr.Body.Bytes(), &job)
+	if loc := rr.Header().Get("Location"); loc != "/api/v1/testdb/_export/"+job.ID {
+		t.Errorf("unexpected Location %q", loc)
+	}
+	return &job
+}
+
+// waitExport polls a job until it has finished.
+func waitExport(t *testing.T, env *batchTestEnv, p *middleware.Principal, id string) *model.ExportJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rr := env.doAs(t, p, "GET", "/api/v1/testdb/_export/"+id, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("get export: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+		}
+		var job model.ExportJob
+		json.Unmarshal(rr.Body.Bytes(), &job)
+		if job.Done() {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("export %s still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func downloadExport(t *testing.T, env *batchTestEnv, p *middleware.Principal, job *model.ExportJob) []byte {
+	t.Helper()
+	rr := env.doAs(t, p, "GET", job.DownloadURL, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("download: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	return rr.Body.Bytes()
+}
+
+func TestExport_TableCSV(t *testing.T) {
+	env, _ := newExportTestEnv(t)
+	admin := &middleware.Principal{Type: "admin", IsAdmin: true, AdminID: 1}
+
+	job := createExport(t, env, admin, map[string]interface{}{
+		"table":  "users",
+		"fields": []string{"id", "name"},
+		"order":  []string{"id DESC"},
+	})
+	if job.Status != model.ExportPending || job.Format != "csv" {
+		t.Errorf("unexpected queued job %+v", job)
+	}
+
+	job = waitExport(t, env, admin, job.ID)
+	if job.Status != model.ExportCompleted || job.Rows != 2 || job.Bytes == 0 || job.ExpiresAt == nil {
+		t.Fatalf("unexpected finished job %+v", job)
+	}
+	if job.DownloadURL != "/api/v1/testdb/_export/"+job.ID+"/download" {
+		t.Errorf("unexpected download_url %q", job.DownloadURL)
+	}
+
+	rr := env.doAs(t, admin, "GET", job.DownloadURL, nil)
+	if got := rr.Body.String(); got != "id,name\r\n2,Bob\r\n1,Alice\r\n" {
+		t.Errorf("unexpected CSV %q", got)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=users.csv` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	rr = env.doAs(t, admin, "GET", "/api/v1/testdb/_export", nil)
+	var list struct {
+		Resource []model.ExportJob `json:"resource"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Resource) != 1 || list.Resource[0].ID != job.ID {
+		t.Errorf("unexpected list %s", rr.Body.String())
+	}
+}
+
+func TestExport_GzipNDJSONAndParquet(t *testing.T) {
+	env, _ := newExportTestEnv(t)
+	admin := &middleware.Principal{Type: "admin", IsAdmin: true, AdminID: 1}
+
+	job := createExport(t, env, admin, map[string]interface{}{
+		"table": "users", "filter": "name = 'Bob'", "format": "ndjson", "gzip": true,
+	})
+	job = waitExport(t, env, admin, job.ID)
+	if job.Status != model.ExportCompleted {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(downloadExport(t, env, admin, job)))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	b, _ := io.ReadAll(zr)
+	if got := string(b); got != `{"email":"bob@example.com","id":2,"name":"Bob"}`+"\n" {
+		t.Errorf("unexpected NDJSON %q", got)
+	}
+
+	job = createExport(t, env, admin, map[string]interface{}{"table": "users", "format": "parquet"})
+	job = waitExport(t, env, admin, job.ID)
+	body := downloadExport(t, env, admin, job)
+	if job.Rows != 2 || !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Errorf("expected a Parquet file of 2 rows, got %d rows and %d bytes", job.Rows, len(body))
+	}
+}
+
+func TestExport_SavedQuery(t *testing.T) {
+	env, _ := newExportTestEnv(t)
+	admin := &middleware.Principal{Type: "admin", IsAdmin: true, AdminID: 1}
+	ctx := context.Background()
+	env.store.CreateNamedQuery(ctx, &model.NamedQuery{
+		ServiceName: "testdb",
+		Name:        "by_name",
+		SQL:         "SELECT email FROM users WHERE name = :name",
+		Params:      []model.QueryParam{{Name: "name", Type: "string", Required: true}},
+	})
+	env.store.CreateNamedQuery(ctx, &model.NamedQuery{ServiceName: "testdb", Name: "purge", SQL: "DELETE FROM users", Write: true})
+
+	rr := env.doAs(t, admin, "POST", "/api/v1/testdb/_export", map[string]interface{}{"query": "by_name"})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid query arguments") {
+		t.Errorf("missing argument: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	rr = env.doAs(t, admin, "POST", "/api/v1/testdb/_export", map[string]interface{}{"query": "purge"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("write query: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	job := createExport(t, env, admin, map[string]interface{}{"query": "by_name", "args": map[string]interface{}{"name": "Alice"}})
+	job = waitExport(t, env, admin, job.ID)
+	if got := string(downloadExport(t, env, admin, job)); got != "email\r\nalice@example.com\r\n" {
+		t.Errorf("unexpected CSV %q", got)
+	}
+}
+
+func TestExport_Validation(t *testing.T) {
+	env, _ := newExportTestEnv(t)
+	admin := &middleware.Principal{Type: "admin", IsAdmin: true, AdminID: 1}
+
+	for _, tt := range []struct {
+		name string
+		body map[string]interface{}
+		code int
+	}{
+		{"no source", map[string]interface{}{"format": "csv"}, http.StatusBadRequest},
+		{"both sources", map[string]interface{}{"table": "users", "query": "q"}, http.StatusBadRequest},
+		{"bad format", map[string]interface{}{"table": "users", "format": "xlsx"}, http.StatusBadRequest},
+		{"unknown column", map[string]interface{}{"table": "users", "fields": []string{"nope"}}, http.StatusBadRequest},
+		{"bad filter", map[string]interface{}{"table": "users", "filter": "name = "}, http.StatusBadRequest},
+		{"unknown field", map[string]interface{}{"table": "users", "limit": 5}, http.StatusBadRequest},
+		{"missing table", map[string]interface{}{"table": "nope"}, http.StatusNotFound},
+		{"missing query", map[string]interface{}{"query": "nope"}, http.StatusNotFound},
+	} {
+		rr := env.doAs(t, admin, "POST", "/api/v1/testdb/_export", tt.body)
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d; body: %s", tt.name, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestExport_Access(t *testing.T) {
+	env, _ := newExportTestEnv(t)
+	ctx := context.Background()
+	role := &model.Role{Name: "reader", IsActive: true}
+	env.store.CreateRole(ctx, role)
+	env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbGet},
+	})
+	alice := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 1}
+	bob := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 2}
+	noAccess := &middleware.Principal{Type: "api_key", RoleID: 999, KeyID: 3}
+
+	rr := env.doAs(t, noAccess, "POST", "/api/v1/testdb/_export", map[string]interface{}{"table": "users"})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without table access, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	job := createExport(t, env, alice, map[string]interface{}{"table": "users"})
+	waitExport(t, env, alice, job.ID)
+
+	// Other API keys cannot see, download or delete the job.
+	for _, path := range []string{"/api/v1/testdb/_export/" + job.ID, "/api/v1/testdb/_export/" + job.ID + "/download"} {
+		if rr := env.doAs(t, bob, "GET", path, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404 for another key, got %d", path, rr.Code)
+		}
+	}
+	if rr := env.doAs(t, bob, "DELETE", "/api/v1/testdb/_export/"+job.ID, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("delete: expected 404 for another key, got %d", rr.Code)
+	}
+	rr = env.doAs(t, bob, "GET", "/api/v1/testdb/_export", nil)
+	if strings.Contains(rr.Body.String(), job.ID) {
+		t.Errorf("expected another key's list to omit the job, got %s", rr.Body.String())
+	}
+
+	// The owner can delete a finished job, which removes its file.
+	rr = env.doAs(t, alice, "DELETE", "/api/v1/testdb/_export/"+job.ID, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := env.doAs(t, alice, "GET", "/api/v1/testdb/_export/"+job.ID, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the deleted job to be gone, got %d", rr.Code)
+	}
+}
+
+func TestExport_CancelAndCleanup(t *testing.T) {
+	env, eh := newExportTestEnv(t)
+	admin := &middleware.Principal{Type: "admin", IsAdmin: true, AdminID: 1}
+	ctx := context.Background()
+
+	// Stop the workers so the job stays pending.
+	eh.Close()
+	job := createExport(t, env, admin, map[string]interface{}{"table": "users"})
+
+	rr := env.doAs(t, admin, "DELETE", "/api/v1/testdb/_export/"+job.ID, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("cancel: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var cancelled model.ExportJob
+	json.Unmarshal(rr.Body.Bytes(), &cancelled)
+	if cancelled.Status != model.ExportCancelled || cancelled.ExpiresAt == nil {
+		t.Errorf("unexpected cancelled job %+v", cancelled)
+	}
+	if rr := env.doAs(t, admin, "GET", "/api/v1/testdb/_export/"+job.ID+"/download", nil); rr.Code != http.StatusConflict {
+		t.Errorf("download: expected 409 for a cancelled job, got %d", rr.Code)
+	}
+
+	// Expired jobs are removed by the cleanup.
+	if err := eh.Cleanup(ctx); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if _, err := env.store.GetExportJob(ctx, job.ID); err != nil {
+		t.Errorf("expected the job to be kept until it expires, got %v", err)
+	}
+	eh.retention = -time.Minute
+	done := createExport(t, env, admin, map[string]interface{}{"table": "users"})
+	env.doAs(t, admin, "DELETE", "/api/v1/testdb/_export/"+done.ID, nil)
+	if err := eh.Cleanup(ctx); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if _, err := env.store.GetExportJob(ctx, done.ID); err == nil {
+		t.Error("expected the expired job to be removed")
+	}
+}
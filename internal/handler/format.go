@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/faucetdb/faucet/internal/export"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// Output formats for record results. The format is chosen with ?format= or,
+// failing that, the first supported media type in the Accept header. Table
+// reads may also be exported as Arrow or Parquet (see package export).
+const (
+	formatJSON    = "json"
+	formatNDJSON  = "ndjson"
+	formatCSV     = "csv"
+	formatTSV     = "tsv"
+	formatArrow   = export.Arrow
+	formatParquet = export.Parquet
+)
+
+// outputParams are the query parameters that shape the output format rather
+// than the rows. Named queries and procedures, which take arguments from the
+// query string, do not receive them.
+var outputParams = []string{"format", "delimiter", "null", "bom"}
+
+// isOutputParam reports whether key is one of outputParams.
+func isOutputParam(key string) bool {
+	for _, p := range outputParams {
+		if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateFormat returns the output format a request asks for, after
+// checking the options of CSV and TSV output.
+func negotiateFormat(r *http.Request) (string, error) {
+	format := acceptedFormat(r)
+	switch format {
+	case formatJSON, formatNDJSON:
+		return format, nil
+	case formatCSV, formatTSV:
+		if _, err := tabularComma(r, format); err != nil {
+			return "", err
+		}
+		return format, nil
+	case "jsonl":
+		return formatNDJSON, nil
+	case formatArrow, formatParquet:
+		return "", fmt.Errorf("format %q is only available for table reads", format)
+	}
+	return "", fmt.Errorf("unsupported format %q (use json, ndjson, csv or tsv)", format)
+}
+
+// negotiateSelectFormat is negotiateFormat for table reads, which can also
+// be exported as Arrow or Parquet.
+func negotiateSelectFormat(r *http.Request) (string, error) {
+	if format := acceptedFormat(r); export.IsFormat(format) {
+		return format, nil
+	}
+	return negotiateFormat(r)
+}
+
+// acceptedFormat returns ?format=, or the format of the first supported
+// media type in the Accept header.
+func acceptedFormat(r *http.Request) string {
+	if f := queryString(r, "format"); f != "" {
+		return strings.ToLower(f)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return formatJSON
+		case "application/x-ndjson", "application/jsonl":
+			return formatNDJSON
+		case "text/csv":
+			return formatCSV
+		case "text/tab-separated-values":
+			return formatTSV
+		case "application/vnd.apache.arrow.stream":
+			return formatArrow
+		case "application/vnd.apache.parquet", "application/x-parquet":
+			return formatParquet
+		}
+	}
+	return formatJSON
+}
+
+// tabularComma returns the field delimiter of CSV or TSV output.
+func tabularComma(r *http.Request, format string) (rune, error) {
+	d := queryString(r, "delimiter")
+	if d == "" {
+		if format == formatTSV {
+			return '\t', nil
+		}
+		return ',', nil
+	}
+	comma, err := parseDelimiter(d)
+	if err != nil {
+		return 0, err
+	}
+	if comma == '"' || comma == '\r' || comma == '\n' || comma == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q", d)
+	}
+	return comma, nil
+}
+
+// parseDelimiter parses a delimiter parameter: a single character, or "tab".
+func parseDelimiter(d string) (rune, error) {
+	if d == "tab" || d == `\t` {
+		d = "\t"
+	}
+	if utf8.RuneCountInString(d) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character")
+	}
+	comma, _ := utf8.DecodeRuneInString(d)
+	return comma, nil
+}
+
+// tabularWriter streams rows as CSV or TSV: a header row, then one record
+// per row, quoted per RFC 4180 with CRLF line endings.
+//
+// Options (query parameters):
+//   - ?delimiter=;    field delimiter (default "," for CSV, tab for TSV)
+//   - ?null=NULL      text written for NULL (default: an empty field)
+//   - ?bom=true       start with a UTF-8 byte order mark, which Excel needs
+//     to read non-ASCII text correctly
+type tabularWriter struct {
+	w           http.ResponseWriter
+	csv         *csv.Writer
+	contentType string
+	ext         string
+	null        string
+	bom         bool
+	columns     []string
+	fields      []string
+}
+
+// newTabularWriter applies the output options of r, which negotiateFormat
+// has checked. Nothing is written until begin.
+func newTabularWriter(w http.ResponseWriter, r *http.Request, format string) *tabularWriter {
+	t := &tabularWriter{
+		w:           w,
+		csv:         csv.NewWriter(w),
+		contentType: "text/csv",
+		ext:         ".csv",
+		null:        queryString(r, "null"),
+		bom:         queryBool(r, "bom"),
+	}
+	if format == formatTSV {
+		t.contentType, t.ext = "text/tab-separated-values", ".tsv"
+	}
+	t.csv.Comma, _ = tabularComma(r, format)
+	t.csv.UseCRLF = true
+	return t
+}
+
+// newFileTabularWriter returns a tabularWriter of format with the default
+// options that writes to out rather than an HTTP response.
+func newFileTabularWriter(out io.Writer, format string) *tabularWriter {
+	t := &tabularWriter{csv: csv.NewWriter(out)}
+	if format == formatTSV {
+		t.csv.Comma = '\t'
+	}
+	t.csv.UseCRLF = true
+	return t
+}
+
+// begin writes the response headers and the header row. name is the
+// download's file name, without extension.
+func (t *tabularWriter) begin(name string, columns []string) error {
+	t.w.Header().Set("Content-Type", t.contentType+"; charset=utf-8")
+	t.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + t.ext}))
+	t.w.WriteHeader(http.StatusOK)
+	if t.bom {
+		if _, err := t.w.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+	}
+	return t.header(columns)
+}
+
+// header writes the header row and fixes the column order of later rows.
+func (t *tabularWriter) header(columns []string) error {
+	t.columns = columns
+	t.fields = make([]string, len(columns))
+	return t.csv.Write(columns)
+}
+
+// write writes one row, in the column order given to begin.
+func (t *tabularWriter) write(row map[string]interface{}) error {
+	for i, col := range t.columns {
+		t.fields[i] = t.cell(row[col])
+	}
+	return t.csv.Write(t.fields)
+}
+
+// flush writes any buffered rows to the response.
+func (t *tabularWriter) flush() error {
+	t.csv.Flush()
+	return t.csv.Error()
+}
+
+// cell renders a scanned value as text. Floats are written without an
+// exponent and times as RFC 3339, which spreadsheets parse; JSON values are
+// written as JSON.
+func (t *tabularWriter) cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return t.null
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// recordColumns returns the columns of records whose column order is not
+// known, such as procedure results: every key, sorted.
+func recordColumns(records []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, rec := range records {
+		for col := range rec {
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, col)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// writeRecords writes materialized records in format: a ListResponse with
+// meta for JSON, otherwise one line per record. name is the file name
+// offered for CSV and TSV downloads; columns, if nil, are derived from the
+// records.
+func writeRecords(w http.ResponseWriter, r *http.Request, format, name string, columns []string, records []map[string]interface{}, meta *model.ResponseMeta) {
+	switch format {
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			enc.Encode(rec)
+		}
+	case formatCSV, formatTSV:
+		t := newTabularWriter(w, r, format)
+		if columns == nil {
+			columns = recordColumns(records)
+		}
+		if err := t.begin(name, columns); err != nil {
+			return
+		}
+		for _, rec := range records {
+			if err := t.write(rec); err != nil {
+				return
+			}
+		}
+		t.flush()
+	default:
+		writeJSON(w, http.StatusOK, model.ListResponse{Resource: records, Meta: meta})
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+// ---------------------------------------------------------------------------
+// Output format (?format= and Accept) tests
+// ---------------------------------------------------------------------------
+
+func (e *batchTestEnv) get(t *testing.T, path, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestQueryRecords_CSV(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	conn.DB().ExecContext(context.Background(), `INSERT INTO users (name, email) VALUES ('Smith, "Al"', 'al@example.com'), ('Bob', 'bob@example.com')`)
+
+	rr := env.get(t, "/api/v1/testdb/_table/users?fields=email,name,id&order=id", "text/csv")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=users.csv` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	want := "email,name,id\r\nal@example.com,\"Smith, \"\"Al\"\"\",1\r\nbob@example.com,Bob,2\r\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("unexpected body:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestQueryRecords_TSVOptions(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	conn.DB().ExecContext(context.Background(), `CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)`)
+	conn.DB().ExecContext(context.Background(), `INSERT INTO notes (id, body) VALUES (1, 'café'), (2, NULL)`)
+
+	rr := env.get(t, "/api/v1/testdb/_table/notes?format=tsv&null=NULL&bom=true&order=id", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/tab-separated-values; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	want := "\ufeffid\tbody\r\n1\tcafé\r\n2\tNULL\r\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("unexpected body:\n%q\nwant:\n%q", got, want)
+	}
+
+	rr = env.get(t, "/api/v1/testdb/_table/notes?format=csv&delimiter=%3B&order=id", "")
+	if want := "id;body\r\n1;café\r\n2;\r\n"; rr.Body.String() != want {
+		t.Errorf("unexpected body:\n%q\nwant:\n%q", rr.Body.String(), want)
+	}
+
+	// ?format= takes precedence over Accept.
+	rr = env.get(t, "/api/v1/testdb/_table/notes?format=ndjson", "text/csv")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected NDJSON, got %q", ct)
+	}
+}
+
+func TestQueryRecords_FormatInvalid(t *testing.T) {
+	env := newBatchTestEnv(t)
+	for _, path := range []string{
+		"/api/v1/testdb/_table/users?format=xml",
+		"/api/v1/testdb/_table/users?format=csv&delimiter=ab",
+		"/api/v1/testdb/_table/users?format=csv&delimiter=%22",
+	} {
+		if rr := env.get(t, path, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d; body: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+	// Unsupported Accept types fall back to JSON.
+	rr := env.get(t, "/api/v1/testdb/_table/users", "application/xml, */*")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON, got %q", ct)
+	}
+}
+
+func TestNamedQuery_CSV(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+	env.create(t, map[string]interface{}{
+		"name": "user_list",
+		"sql":  "SELECT name, id FROM users WHERE id >= :min_id ORDER BY id",
+		"params": []map[string]interface{}{
+			{"name": "min_id", "type": "integer", "default": 1},
+		},
+		"cache_ttl": 60,
+	})
+
+	// The output options are not passed to the query as arguments.
+	for i := 0; i < 2; i++ { // MISS, then HIT from the cache
+		rr := env.get(t, "/api/v1/testdb/_query/user_list?format=csv&min_id=2", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+		}
+		if want := "name,id\r\nBob,2\r\n"; rr.Body.String() != want {
+			t.Errorf("unexpected body:\n%q\nwant:\n%q", rr.Body.String(), want)
+		}
+		if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=user_list.csv` {
+			t.Errorf("unexpected Content-Disposition %q", cd)
+		}
+	}
+}
+
+func TestQueryRecords_Arrow(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	// More rows than the limit cap allows; exports are capped too.
+	conn.DB().ExecContext(context.Background(), `
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1500)
+		INSERT INTO users (name, email) SELECT 'user' || i, 'user' || i || '@example.com' FROM n`)
+
+	rr := env.get(t, "/api/v1/testdb/_table/users?format=arrow&batch_size=1000&filter=id%3E10", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/vnd.apache.arrow.stream" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=users.arrows` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	r, err := ipc.NewReader(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("ipc.NewReader: %v", err)
+	}
+	defer r.Release()
+	if dt := r.Schema().Field(0).Type; !arrow.TypeEqual(dt, arrow.PrimitiveTypes.Int64) {
+		t.Errorf("expected id to be int64, got %s", dt)
+	}
+	var rows, batches int64
+	for r.Next() {
+		rows += r.Record().NumRows()
+		batches++
+	}
+	if rows != 1000 || batches != 1 {
+		t.Errorf("expected 1000 rows in 1 batch, got %d in %d", rows, batches)
+	}
+
+	// An explicit limit still applies.
+	rr = env.get(t, "/api/v1/testdb/_table/users?limit=5", "application/vnd.apache.parquet")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/vnd.apache.parquet" {
+		t.Fatalf("unexpected Content-Type %q; body: %s", ct, rr.Body.String())
+	}
+	if body := rr.Body.Bytes(); !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Error("expected a complete Parquet file")
+	}
+}
+
+func TestNamedQuery_ArrowUnsupported(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+	env.create(t, map[string]interface{}{"name": "all_users", "sql": "SELECT * FROM users"})
+
+	if rr := env.get(t, "/api/v1/testdb/_query/all_users?format=parquet", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRecordColumns(t *testing.T) {
+	got := recordColumns([]map[string]interface{}{{"b": 1, "a": 2}, {"c": 3, "a": 4}})
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("unexpected columns %v", got)
+	}
+}
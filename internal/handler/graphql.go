@@ -0,0 +1,739 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// GraphQLHandler serves a GraphQL API per service, generated at runtime from
+// the introspected schema. Reads compile to the same SelectRequests as the
+// REST table routes and writes run like _batch operations, so filters, value
+// encoding, column rules, validation and role access behave identically.
+type GraphQLHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	tables   *TableHandler
+	schemas  *serviceSchemas
+
+	mu    sync.Mutex
+	built map[string]*graphQLSchema // by service name
+}
+
+// graphQLSchema is a generated schema and the digest of the service schema
+// snapshot it was generated from.
+type graphQLSchema struct {
+	schema graphql.Schema
+	digest string
+}
+
+// NewGraphQLHandler creates a new GraphQLHandler.
+func NewGraphQLHandler(registry *connector.Registry, store *config.Store) *GraphQLHandler {
+	return &GraphQLHandler{
+		registry: registry,
+		store:    store,
+		tables:   NewTableHandler(registry, store),
+		schemas:  newServiceSchemas(registry, store),
+		built:    make(map[string]*graphQLSchema),
+	}
+}
+
+// graphQLRequestBody is a GraphQL request as posted in a JSON body.
+type graphQLRequestBody struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Serve executes a GraphQL request against the service's generated schema.
+// GET  /api/v1/{serviceName}/graphql?query=...&variables=...&operationName=...
+// POST /api/v1/{serviceName}/graphql
+//
+// POST bodies are JSON ({"query", "variables", "operationName"}) or, with
+// Content-Type application/graphql, the bare query. Mutations are only
+// accepted over POST. Executed requests answer 200 with the standard
+// {"data", "errors"} result; field errors carry the status code a REST
+// request would have returned in extensions.status.
+func (h *GraphQLHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	var body graphQLRequestBody
+	switch r.Method {
+	case http.MethodGet:
+		body.Query = queryString(r, "query")
+		body.OperationName = queryString(r, "operationName")
+		if vars := queryString(r, "variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &body.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid variables: "+err.Error())
+				return
+			}
+		}
+	default:
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			b, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+				return
+			}
+			body.Query = string(b)
+		} else if err := readJSON(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+	if strings.TrimSpace(body.Query) == "" {
+		writeError(w, http.StatusBadRequest, "No GraphQL query provided")
+		return
+	}
+	if r.Method == http.MethodGet && isMutation(body.Query, body.OperationName) {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "Mutations must be sent with POST")
+		return
+	}
+
+	schema, err := h.schemaFor(r.Context(), conn, serviceName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build GraphQL schema: "+err.Error())
+		return
+	}
+
+	req := &gqlRequest{
+		tables:      h.tables,
+		conn:        conn,
+		serviceName: serviceName,
+		maxLimit:    maxLimit(r.Context(), h.store),
+		access:      make(map[string]bool),
+		loaders:     make(map[string]*gqlLoader),
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  body.Query,
+		VariableValues: body.Variables,
+		OperationName:  body.OperationName,
+		Context:        context.WithValue(r.Context(), gqlRequestKey{}, req),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+// isMutation reports whether the operation a request selects is a mutation.
+// Documents that do not parse are left for execution to report.
+func isMutation(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			if op.Operation == ast.OperationTypeMutation {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaFor returns the GraphQL schema of a service, generated from its
+// schema snapshot (see serviceSchemas) and regenerated whenever a refreshed
+// snapshot differs.
+func (h *GraphQLHandler) schemaFor(ctx context.Context, conn connector.Connector, serviceName string) (graphql.Schema, error) {
+	snap, err := h.schemas.get(ctx, conn, serviceName)
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+
+	h.mu.Lock()
+	built := h.built[serviceName]
+	h.mu.Unlock()
+	if built != nil && built.digest == snap.digest {
+		return built.schema, nil
+	}
+
+	schema, err := buildGraphQLSchema(snap.schema)
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	h.mu.Lock()
+	h.built[serviceName] = &graphQLSchema{schema: schema, digest: snap.digest}
+	h.mu.Unlock()
+	return schema, nil
+}
+
+// ---------------------------------------------------------------------------
+// Scalars
+// ---------------------------------------------------------------------------
+
+// gqlBigInt carries 64-bit integer columns, which overflow GraphQL's 32-bit
+// Int. Values are serialized as JSON numbers and accepted as integers or
+// decimal strings.
+var gqlBigInt = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "BigInt",
+	Description: "A 64-bit integer. Input values may also be given as decimal strings.",
+	Serialize:   func(v interface{}) interface{} { return v },
+	ParseValue:  parseBigInt,
+	ParseLiteral: func(v ast.Value) interface{} {
+		switch v := v.(type) {
+		case *ast.IntValue:
+			return parseBigInt(v.Value)
+		case *ast.StringValue:
+			return parseBigInt(v.Value)
+		}
+		return nil
+	},
+})
+
+func parseBigInt(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+			return int64(n)
+		}
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i
+		}
+	}
+	return nil
+}
+
+// gqlDecimal carries exact numeric columns. Values are serialized as the
+// value encoder renders them: JSON numbers, or strings under the service's
+// "string" numeric format.
+var gqlDecimal = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Decimal",
+	Description: "An exact decimal number, as a JSON number or a string.",
+	Serialize:   func(v interface{}) interface{} { return v },
+	ParseValue:  parseDecimal,
+	ParseLiteral: func(v ast.Value) interface{} {
+		switch v := v.(type) {
+		case *ast.IntValue:
+			return parseDecimal(v.Value)
+		case *ast.FloatValue:
+			return parseDecimal(v.Value)
+		case *ast.StringValue:
+			return parseDecimal(v.Value)
+		}
+		return nil
+	},
+})
+
+func parseDecimal(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return json.Number(strconv.Itoa(n))
+	case float64:
+		return json.Number(strconv.FormatFloat(n, 'f', -1, 64))
+	case string:
+		if isJSONNumberText(n) {
+			return json.Number(n)
+		}
+	}
+	return nil
+}
+
+func isJSONNumberText(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+// gqlJSON carries JSON columns and structured filters.
+var gqlJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value.",
+	Serialize:    func(v interface{}) interface{} { return v },
+	ParseValue:   func(v interface{}) interface{} { return v },
+	ParseLiteral: jsonLiteral,
+})
+
+func jsonLiteral(v ast.Value) interface{} {
+	switch v := v.(type) {
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			out[f.Name.Value] = jsonLiteral(f.Value)
+		}
+		return out
+	case *ast.ListValue:
+		out := make([]interface{}, len(v.Values))
+		for i, item := range v.Values {
+			out[i] = jsonLiteral(item)
+		}
+		return out
+	case *ast.IntValue:
+		if i, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return i
+		}
+		return json.Number(v.Value)
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	}
+	return nil
+}
+
+// gqlScalarFor maps a column to the scalar its values are served as.
+func gqlScalarFor(col model.Column) *graphql.Scalar {
+	switch col.JsonType {
+	case "integer":
+		if isWideInteger(col) {
+			return gqlBigInt
+		}
+		return graphql.Int
+	case "number":
+		if isFloatingPoint(col) {
+			return graphql.Float
+		}
+		return gqlDecimal
+	case "boolean":
+		return graphql.Boolean
+	case "object", "array":
+		return gqlJSON
+	}
+	return graphql.String
+}
+
+// isWideInteger reports whether an integer column may hold values beyond
+// 32 bits.
+func isWideInteger(col model.Column) bool {
+	dbType := strings.ToLower(col.Type)
+	for _, wide := range []string{"bigint", "int8", "bigserial", "serial8", "long", "unsigned", "number"} {
+		if strings.Contains(dbType, wide) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFloatingPoint reports whether a numeric column is binary floating point
+// rather than exact.
+func isFloatingPoint(col model.Column) bool {
+	dbType := strings.ToLower(col.Type)
+	for _, float := range []string{"float", "double", "real"} {
+		if strings.Contains(dbType, float) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Schema generation
+// ---------------------------------------------------------------------------
+
+// gqlName turns a database identifier into a valid GraphQL name: characters
+// outside [_A-Za-z0-9] become underscores, a leading digit is prefixed with
+// one, and the "__" prefix reserved for introspection is shortened.
+func gqlName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// gqlNames hands out unique names within one GraphQL scope (the type names,
+// or the fields of one type), numbering later claims of a taken name.
+type gqlNames map[string]bool
+
+func (n gqlNames) claim(name string) string {
+	unique := name
+	for i := 2; n[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	n[unique] = true
+	return unique
+}
+
+// gqlTable is a table or view of the generated schema.
+type gqlTable struct {
+	def  model.TableSchema
+	name string // GraphQL type name, also used for its root query field
+	view bool
+
+	columns map[string]string // GraphQL field name -> column, for scalar fields
+	object  *graphql.Object
+	insert  *graphql.InputObject // nil for views
+	set     *graphql.InputObject // nil for views
+}
+
+// gqlRelation is a field that follows a foreign key: to the parent row a
+// row references, or to the child rows that reference it.
+type gqlRelation struct {
+	target *gqlTable
+	child  bool
+	// columns are the key columns on the source row and targetColumns the
+	// matching columns of target, in the same order.
+	columns       []string
+	targetColumns []string
+}
+
+// gqlMutationResult reports the rows a filtered update or delete affected.
+var gqlMutationResult = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MutationResult",
+	Fields: graphql.Fields{
+		"affected_rows": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+// buildGraphQLSchema generates the GraphQL schema of a service:
+//
+//   - Query: {table}(filter, where, order, limit, offset), {table}_by_pk and
+//     {table}_count for every table and view
+//   - each row type: a field per column, a field per foreign key to the
+//     referenced row, and a list field per foreign key referencing it
+//   - Mutation: insert_{table}, update_{table}, delete_{table},
+//     update_{table}_by_pk and delete_{table}_by_pk for every table
+func buildGraphQLSchema(schema *model.Schema) (graphql.Schema, error) {
+	typeNames := gqlNames{}
+	for _, reserved := range []string{"Query", "Mutation", "String", "Int", "Float", "Boolean", "ID", "BigInt", "Decimal", "JSON", "MutationResult"} {
+		typeNames[reserved] = true
+	}
+
+	var tables []*gqlTable
+	byName := make(map[string]*gqlTable)
+	add := func(def model.TableSchema, view bool) {
+		t := &gqlTable{def: def, view: view, columns: make(map[string]string)}
+		t.name = typeNames.claim(gqlName(def.Name))
+		tables = append(tables, t)
+		byName[def.Name] = t
+	}
+	for _, def := range schema.Tables {
+		add(def, false)
+	}
+	for _, def := range schema.Views {
+		add(def, true)
+	}
+
+	// Resolve relations up front; object fields are generated lazily since
+	// row types refer to each other.
+	relations := make(map[*gqlTable][]*gqlRelation)
+	for _, t := range tables {
+		for _, group := range groupForeignKeys(t.def.ForeignKeys) {
+			parent, ok := byName[group[0].ReferencedTable]
+			if !ok {
+				continue
+			}
+			rel := relationFromGroup(parent.def.Name, false, group)
+			relations[t] = append(relations[t], &gqlRelation{target: parent, columns: rel.columns, targetColumns: rel.refColumns})
+			relations[parent] = append(relations[parent], &gqlRelation{target: t, child: true, columns: rel.refColumns, targetColumns: rel.columns})
+		}
+	}
+
+	for _, t := range tables {
+		t := t
+		t.object = graphql.NewObject(graphql.ObjectConfig{
+			Name:        t.name,
+			Description: fmt.Sprintf("A row of the %s %q.", t.def.Type, t.def.Name),
+			Fields: graphql.FieldsThunk(func() graphql.Fields {
+				return t.fields(relations[t])
+			}),
+		})
+		// Columns are named when the type is built, before its fields are
+		// generated, so that insert and set inputs share the names.
+		names := gqlNames{}
+		for _, col := range t.def.Columns {
+			t.columns[names.claim(gqlName(col.Name))] = col.Name
+		}
+		if !t.view {
+			t.insert = t.inputObject(typeNames.claim(t.name+"_insert_input"), "Values of a row to insert.")
+			t.set = t.inputObject(typeNames.claim(t.name+"_set_input"), "Column values to update.")
+		}
+	}
+
+	queryFields := graphql.Fields{}
+	mutationFields := graphql.Fields{}
+	queryNames, mutationNames := gqlNames{}, gqlNames{}
+	for _, t := range tables {
+		t.addQueryFields(queryFields, queryNames)
+		if !t.view {
+			t.addMutationFields(mutationFields, mutationNames)
+		}
+	}
+	if len(queryFields) == 0 {
+		// A schema needs at least one query field.
+		queryFields["_service"] = &graphql.Field{
+			Type:        graphql.String,
+			Description: "The service has no tables.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return gqlRequestFrom(p.Context).serviceName, nil
+			},
+		}
+	}
+
+	cfg := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queryFields}),
+	}
+	if len(mutationFields) > 0 {
+		cfg.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutationFields})
+	}
+	return graphql.NewSchema(cfg)
+}
+
+// column returns the definition of a column.
+func (t *gqlTable) column(name string) model.Column {
+	for _, col := range t.def.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return model.Column{Name: name}
+}
+
+// fieldName returns the GraphQL name of a column.
+func (t *gqlTable) fieldName(column string) string {
+	for field, col := range t.columns {
+		if col == column {
+			return field
+		}
+	}
+	return gqlName(column)
+}
+
+// fields generates the fields of the row type: one per column, then one per
+// relation. A parent relation is named after its foreign key column without
+// the "_id" suffix (customer for customer_id) or else after the referenced
+// table; a child relation after the referencing table.
+func (t *gqlTable) fields(relations []*gqlRelation) graphql.Fields {
+	fields := graphql.Fields{}
+	names := gqlNames{}
+	for _, col := range t.def.Columns {
+		field := t.fieldName(col.Name)
+		names[field] = true
+		var typ graphql.Output = gqlScalarFor(col)
+		if !col.Nullable {
+			typ = graphql.NewNonNull(typ)
+		}
+		column := col.Name
+		fields[field] = &graphql.Field{
+			Type:        typ,
+			Description: col.Comment,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				row, _ := p.Source.(map[string]interface{})
+				return row[column], nil
+			},
+		}
+	}
+
+	for _, rel := range relations {
+		rel := rel
+		if !rel.child {
+			name := rel.target.name
+			if len(rel.columns) == 1 && strings.HasSuffix(rel.columns[0], "_id") {
+				name = gqlName(strings.TrimSuffix(rel.columns[0], "_id"))
+			}
+			if names[name] {
+				name = rel.target.name + "_by_" + gqlName(strings.Join(rel.columns, "_"))
+			}
+			fields[names.claim(name)] = &graphql.Field{
+				Type:        rel.target.object,
+				Description: fmt.Sprintf("The %s row referenced by %s.", rel.target.def.Name, strings.Join(rel.columns, ", ")),
+				Resolve:     rel.resolveParent,
+			}
+			continue
+		}
+		name := rel.target.name
+		if names[name] {
+			name = rel.target.name + "_by_" + gqlName(strings.Join(rel.targetColumns, "_"))
+		}
+		fields[names.claim(name)] = &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rel.target.object))),
+			Description: fmt.Sprintf("The %s rows whose %s reference this row.", rel.target.def.Name, strings.Join(rel.targetColumns, ", ")),
+			Args:        listArgs(),
+			Resolve:     rel.resolveChildren,
+		}
+	}
+	return fields
+}
+
+// inputObject generates an input type with an optional field per column.
+// Required columns are checked by record validation, which knows about
+// defaults and server-populated columns.
+func (t *gqlTable) inputObject(name, description string) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for field, column := range t.columns {
+		fields[field] = &graphql.InputObjectFieldConfig{Type: gqlScalarFor(t.column(column))}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Description: description, Fields: fields})
+}
+
+// record maps the fields of an input object value to columns.
+func (t *gqlTable) record(input interface{}) map[string]interface{} {
+	values, _ := input.(map[string]interface{})
+	record := make(map[string]interface{}, len(values))
+	for field, v := range values {
+		if column, ok := t.columns[field]; ok {
+			record[column] = v
+		}
+	}
+	return record
+}
+
+// keyArgs returns an argument per primary key column, or nil when the table
+// has no primary key.
+func (t *gqlTable) keyArgs() graphql.FieldConfigArgument {
+	if len(t.def.PrimaryKey) == 0 {
+		return nil
+	}
+	args := graphql.FieldConfigArgument{}
+	for _, col := range t.def.PrimaryKey {
+		args[t.fieldName(col)] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(gqlScalarFor(t.column(col)))}
+	}
+	return args
+}
+
+// key returns the primary key named by the arguments of a _by_pk field, a
+// scalar or, for composite keys, a tuple in key order.
+func (t *gqlTable) key(args map[string]interface{}) interface{} {
+	tuple := make([]interface{}, len(t.def.PrimaryKey))
+	for i, col := range t.def.PrimaryKey {
+		tuple[i] = args[t.fieldName(col)]
+	}
+	if len(tuple) == 1 {
+		return tuple[0]
+	}
+	return tuple
+}
+
+// listArgs are the arguments of list fields, mapped onto the REST query
+// parameters of the same names.
+func listArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"filter": &graphql.ArgumentConfig{Type: graphql.String, Description: "Filter expression, as the REST filter parameter."},
+		"where":  &graphql.ArgumentConfig{Type: gqlJSON, Description: "Structured filter, as the where field of a _query body."},
+		"order":  &graphql.ArgumentConfig{Type: graphql.String, Description: "Sort order, e.g. \"name desc, id\"."},
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, Description: "Maximum rows (default 25, capped at 1000 or the role's max_limit)."},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Rows to skip."},
+	}
+}
+
+// addQueryFields adds the root query fields of the table.
+func (t *gqlTable) addQueryFields(fields graphql.Fields, names gqlNames) {
+	fields[names.claim(t.name)] = &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t.object))),
+		Description: fmt.Sprintf("Rows of %s.", t.def.Name),
+		Args:        listArgs(),
+		Resolve:     t.resolveList,
+	}
+	if args := t.keyArgs(); args != nil {
+		fields[names.claim(t.name+"_by_pk")] = &graphql.Field{
+			Type:        t.object,
+			Description: fmt.Sprintf("The %s row with the given primary key.", t.def.Name),
+			Args:        args,
+			Resolve:     t.resolveByKey,
+		}
+	}
+	fields[names.claim(t.name+"_count")] = &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.Int),
+		Description: fmt.Sprintf("The number of %s rows matching the filter.", t.def.Name),
+		Args: graphql.FieldConfigArgument{
+			"filter": listArgs()["filter"],
+			"where":  listArgs()["where"],
+		},
+		Resolve: t.resolveCount,
+	}
+}
+
+// addMutationFields adds the root mutation fields of the table.
+func (t *gqlTable) addMutationFields(fields graphql.Fields, names gqlNames) {
+	filterArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "Filter expression selecting the rows."}
+
+	fields[names.claim("insert_"+t.name)] = &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t.object))),
+		Description: fmt.Sprintf("Insert rows into %s and return them as stored.", t.def.Name),
+		Args: graphql.FieldConfigArgument{
+			"objects": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t.insert)))},
+		},
+		Resolve: t.resolveInsert,
+	}
+	fields[names.claim("update_"+t.name)] = &graphql.Field{
+		Type:        graphql.NewNonNull(gqlMutationResult),
+		Description: fmt.Sprintf("Update the %s rows matching the filter.", t.def.Name),
+		Args: graphql.FieldConfigArgument{
+			"filter": filterArg,
+			"set":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(t.set)},
+		},
+		Resolve: t.resolveUpdate,
+	}
+	fields[names.claim("delete_"+t.name)] = &graphql.Field{
+		Type:        graphql.NewNonNull(gqlMutationResult),
+		Description: fmt.Sprintf("Delete the %s rows matching the filter.", t.def.Name),
+		Args:        graphql.FieldConfigArgument{"filter": filterArg},
+		Resolve:     t.resolveDelete,
+	}
+
+	keyArgs := t.keyArgs()
+	if keyArgs == nil {
+		return
+	}
+	updateArgs := graphql.FieldConfigArgument{"set": &graphql.ArgumentConfig{Type: graphql.NewNonNull(t.set)}}
+	for name, arg := range keyArgs {
+		updateArgs[name] = arg
+	}
+	fields[names.claim("update_"+t.name+"_by_pk")] = &graphql.Field{
+		Type:        t.object,
+		Description: fmt.Sprintf("Update the %s row with the given primary key and return it, or null when there is none.", t.def.Name),
+		Args:        updateArgs,
+		Resolve:     t.resolveUpdateByKey,
+	}
+	fields[names.claim("delete_"+t.name+"_by_pk")] = &graphql.Field{
+		Type:        t.object,
+		Description: fmt.Sprintf("Delete the %s row with the given primary key and return it, or null when there is none.", t.def.Name),
+		Args:        keyArgs,
+		Resolve:     t.resolveDeleteByKey,
+	}
+}
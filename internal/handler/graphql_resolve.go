@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// gqlDefaultLimit is the number of rows a list field returns without a
+// limit argument.
+const gqlDefaultLimit = 25
+
+// gqlLoadChunk caps the keys a relation loads with one query.
+const gqlLoadChunk = 500
+
+// gqlRequestKey is the context key of the gqlRequest being executed.
+type gqlRequestKey struct{}
+
+// gqlRequest is the state of one GraphQL request, shared by its resolvers.
+type gqlRequest struct {
+	tables      *TableHandler
+	conn        connector.Connector
+	serviceName string
+	maxLimit    int
+
+	mu      sync.Mutex
+	access  map[string]bool       // "table verb" -> allowed
+	loaders map[string]*gqlLoader // by relation and arguments
+}
+
+func gqlRequestFrom(ctx context.Context) *gqlRequest {
+	req, _ := ctx.Value(gqlRequestKey{}).(*gqlRequest)
+	return req
+}
+
+// gqlError is a field error with the status code and context a REST request
+// would have answered with, reported in the error's extensions.
+type gqlError struct {
+	code int
+	msg  string
+	ctx  map[string]interface{}
+}
+
+func (e *gqlError) Error() string { return e.msg }
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *gqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"status": e.code}
+	for k, v := range e.ctx {
+		ext[k] = v
+	}
+	return ext
+}
+
+// queryError converts an error compiling a query or validating a record into
+// a field error, with the context writeQueryError would add.
+func queryError(err error) error {
+	var invalid *recordValidationError
+	if errors.As(err, &invalid) {
+		return &gqlError{code: http.StatusBadRequest, msg: err.Error(), ctx: invalid.context()}
+	}
+	var unknown *query.UnknownColumnError
+	if errors.As(err, &unknown) {
+		ctx := map[string]interface{}{"column": unknown.Column}
+		if unknown.Suggestion != "" {
+			ctx["suggestion"] = unknown.Suggestion
+		}
+		return &gqlError{code: http.StatusBadRequest, msg: err.Error(), ctx: ctx}
+	}
+	return &gqlError{code: http.StatusBadRequest, msg: err.Error()}
+}
+
+// dbError converts a database error into a field error.
+func dbError(err error, fallbackMsg string) error {
+	code, msg := classifyDBError(err, fallbackMsg)
+	return &gqlError{code: code, msg: msg}
+}
+
+// authorize checks, once per request, that the principal may read (or
+// otherwise act on) a table, as the REST routes and _batch do.
+func (req *gqlRequest) authorize(ctx context.Context, table string, verb int) error {
+	key := fmt.Sprintf("%s %d", table, verb)
+	req.mu.Lock()
+	allowed, checked := req.access[key]
+	req.mu.Unlock()
+	if !checked {
+		var err error
+		allowed, err = authorizeComponent(ctx, req.tables.store, req.serviceName, []string{"_table/*", "_table/" + table}, verb)
+		if err != nil {
+			return &gqlError{code: http.StatusInternalServerError, msg: "Failed to check access: " + err.Error()}
+		}
+		req.mu.Lock()
+		req.access[key] = allowed
+		req.mu.Unlock()
+	}
+	if !allowed {
+		return &gqlError{code: http.StatusForbidden, msg: "Access denied to table: " + table}
+	}
+	return nil
+}
+
+// listQuery maps the arguments of a list field onto a recordQuery. The limit
+// defaults to gqlDefaultLimit and is capped at the principal's max limit.
+func (req *gqlRequest) listQuery(args map[string]interface{}) (recordQuery, error) {
+	q := recordQuery{Limit: gqlDefaultLimit}
+	q.Filter, _ = args["filter"].(string)
+	q.Order, _ = args["order"].(string)
+	if where, ok := args["where"]; ok && where != nil {
+		raw, err := json.Marshal(where)
+		if err != nil {
+			return q, &gqlError{code: http.StatusBadRequest, msg: "Invalid where argument: " + err.Error()}
+		}
+		q.Where = raw
+	}
+	if limit, ok := args["limit"].(int); ok {
+		q.Limit = limit
+	}
+	q.Limit = clampInt(q.Limit, 1, req.maxLimit)
+	if offset, ok := args["offset"].(int); ok && offset > 0 {
+		q.Offset = offset
+	}
+	return q, nil
+}
+
+// selectRows runs a compiled query and returns its encoded rows.
+func (req *gqlRequest) selectRows(ctx context.Context, exec connector.QueryExecutor, selectReq connector.SelectRequest) ([]map[string]interface{}, error) {
+	rows, err := req.tables.queryRows(ctx, exec, req.conn, req.serviceName, selectReq)
+	if err != nil {
+		return nil, dbError(err, "Query failed")
+	}
+	return rows, nil
+}
+
+// gqlArg converts an argument value to the form query.ColumnSet coerces:
+// GraphQL Ints arrive as int, and Decimals as json.Number.
+func gqlArg(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case json.Number:
+		return n.String()
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			out[i] = gqlArg(item)
+		}
+		return out
+	}
+	return v
+}
+
+// ---------------------------------------------------------------------------
+// Queries
+// ---------------------------------------------------------------------------
+
+func (t *gqlTable) resolveList(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	if err := req.authorize(p.Context, t.def.Name, model.VerbGet); err != nil {
+		return nil, err
+	}
+	q, err := req.listQuery(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	selectReq, err := req.tables.compileQuery(p.Context, req.conn, req.serviceName, t.def.Name, q)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	return req.selectRows(p.Context, req.conn.DB(), selectReq)
+}
+
+func (t *gqlTable) resolveByKey(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	if err := req.authorize(p.Context, t.def.Name, model.VerbGet); err != nil {
+		return nil, err
+	}
+	row, err := req.fetchByKey(p.Context, req.conn.DB(), t.def.Name, t.def.PrimaryKey, gqlArg(t.key(p.Args)))
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// fetchByKey reads one row by key on exec, returning nil when none matches.
+func (req *gqlRequest) fetchByKey(ctx context.Context, exec connector.QueryExecutor, table string, keyColumns []string, key interface{}) (map[string]interface{}, error) {
+	selectReq, err := req.tables.compileQuery(ctx, req.conn, req.serviceName, table, recordQuery{
+		IDs:        []interface{}{key},
+		KeyColumns: keyColumns,
+		Limit:      1,
+	})
+	if err != nil {
+		return nil, queryError(err)
+	}
+	row, err := fetchRecord(ctx, exec, req.conn, req.tables.encoder(ctx, req.conn, req.serviceName, table), selectReq)
+	if err != nil {
+		return nil, dbError(err, "Query failed")
+	}
+	return row, nil
+}
+
+func (t *gqlTable) resolveCount(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	if err := req.authorize(p.Context, t.def.Name, model.VerbGet); err != nil {
+		return nil, err
+	}
+	q, err := req.listQuery(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	selectReq, err := req.tables.compileQuery(p.Context, req.conn, req.serviceName, t.def.Name, recordQuery{Filter: q.Filter, Where: q.Where})
+	if err != nil {
+		return nil, queryError(err)
+	}
+	countSQL, countArgs, err := req.conn.BuildCount(p.Context, connector.CountRequest{
+		Table:  selectReq.Table,
+		Filter: selectReq.Filter,
+	})
+	if err != nil {
+		return nil, &gqlError{code: http.StatusInternalServerError, msg: "Failed to build query: " + err.Error()}
+	}
+	var count int64
+	allCountArgs := append(append([]interface{}{}, selectReq.FilterArgs...), countArgs...)
+	if err := req.conn.DB().QueryRowxContext(p.Context, countSQL, allCountArgs...).Scan(&count); err != nil {
+		return nil, dbError(err, "Query failed")
+	}
+	return count, nil
+}
+
+// ---------------------------------------------------------------------------
+// Relations
+// ---------------------------------------------------------------------------
+
+// gqlLoader batches the reads of one relation field within a request. Each
+// resolver registers the key it needs and returns a thunk; the executor
+// resolves all fields of a level before forcing thunks, so the first forced
+// thunk loads every pending key with one query per gqlLoadChunk keys.
+type gqlLoader struct {
+	load func(keys [][]interface{}) (map[string][]map[string]interface{}, error)
+
+	pending [][]interface{}
+	queued  map[string]bool
+	rows    map[string][]map[string]interface{}
+	errs    map[string]error
+}
+
+func newGQLLoader(load func(keys [][]interface{}) (map[string][]map[string]interface{}, error)) *gqlLoader {
+	return &gqlLoader{
+		load:   load,
+		queued: make(map[string]bool),
+		rows:   make(map[string][]map[string]interface{}),
+		errs:   make(map[string]error),
+	}
+}
+
+// gqlKey renders key values as a map key. Both sides of a relation are
+// encoded with the same rules, so equal keys render equally.
+func gqlKey(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// get returns a thunk yielding the rows of key.
+func (l *gqlLoader) get(key []interface{}) func() ([]map[string]interface{}, error) {
+	k := gqlKey(key)
+	if !l.queued[k] {
+		l.queued[k] = true
+		l.pending = append(l.pending, key)
+	}
+	return func() ([]map[string]interface{}, error) {
+		if len(l.pending) > 0 {
+			l.flush()
+		}
+		return l.rows[k], l.errs[k]
+	}
+}
+
+// flush loads all pending keys.
+func (l *gqlLoader) flush() {
+	keys := l.pending
+	l.pending = nil
+	for start := 0; start < len(keys); start += gqlLoadChunk {
+		chunk := keys[start:min(start+gqlLoadChunk, len(keys))]
+		rows, err := l.load(chunk)
+		for _, key := range chunk {
+			k := gqlKey(key)
+			if err != nil {
+				l.errs[k] = err
+				continue
+			}
+			l.rows[k] = rows[k]
+		}
+	}
+}
+
+// loader returns the request's loader for a relation read with the given
+// query, creating it on first use.
+func (req *gqlRequest) loader(ctx context.Context, rel *gqlRelation, q recordQuery) *gqlLoader {
+	id, _ := json.Marshal([]interface{}{rel.target.def.Name, rel.targetColumns, q.Filter, q.Where, q.Order})
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	if l, ok := req.loaders[string(id)]; ok {
+		return l
+	}
+	l := newGQLLoader(func(keys [][]interface{}) (map[string][]map[string]interface{}, error) {
+		ids := make([]interface{}, len(keys))
+		for i, key := range keys {
+			if len(key) == 1 {
+				ids[i] = key[0]
+			} else {
+				ids[i] = key
+			}
+		}
+		q := q
+		q.IDs, q.KeyColumns, q.Limit, q.Offset = ids, rel.targetColumns, 0, 0
+		selectReq, err := req.tables.compileQuery(ctx, req.conn, req.serviceName, rel.target.def.Name, q)
+		if err != nil {
+			return nil, queryError(err)
+		}
+		rows, err := req.selectRows(ctx, req.conn.DB(), selectReq)
+		if err != nil {
+			return nil, err
+		}
+		grouped := make(map[string][]map[string]interface{})
+		for _, row := range rows {
+			k := gqlKey(rowValues(row, rel.targetColumns))
+			grouped[k] = append(grouped[k], row)
+		}
+		return grouped, nil
+	})
+	req.loaders[string(id)] = l
+	return l
+}
+
+// rowValues returns the values of columns in row, or nil when any is null.
+func rowValues(row map[string]interface{}, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		if row[col] == nil {
+			return nil
+		}
+		values[i] = row[col]
+	}
+	return values
+}
+
+// resolveParent resolves the row a foreign key references.
+func (rel *gqlRelation) resolveParent(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	row, _ := p.Source.(map[string]interface{})
+	key := rowValues(row, rel.columns)
+	if key == nil {
+		return nil, nil
+	}
+	if err := req.authorize(p.Context, rel.target.def.Name, model.VerbGet); err != nil {
+		return nil, err
+	}
+	thunk := req.loader(p.Context, rel, recordQuery{}).get(key)
+	return func() (interface{}, error) {
+		rows, err := thunk()
+		if err != nil || len(rows) == 0 {
+			return nil, err
+		}
+		return rows[0], nil
+	}, nil
+}
+
+// resolveChildren resolves the rows whose foreign key references the row.
+// The filter, where and order arguments apply to the batched query; limit
+// and offset apply to the rows of each parent.
+func (rel *gqlRelation) resolveChildren(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	row, _ := p.Source.(map[string]interface{})
+	key := rowValues(row, rel.columns)
+	if key == nil {
+		return []interface{}{}, nil
+	}
+	if err := req.authorize(p.Context, rel.target.def.Name, model.VerbGet); err != nil {
+		return nil, err
+	}
+	q, err := req.listQuery(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	thunk := req.loader(p.Context, rel, q).get(key)
+	return func() (interface{}, error) {
+		rows, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		if q.Offset >= len(rows) {
+			return []interface{}{}, nil
+		}
+		rows = rows[q.Offset:]
+		if len(rows) > q.Limit {
+			rows = rows[:q.Limit]
+		}
+		return rows, nil
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Mutations
+// ---------------------------------------------------------------------------
+
+// mutate runs one mutation field as a _batch operation would run: the
+// operation is validated, authorized and checked against the schema, then
+// run executes it in its own transaction.
+func (req *gqlRequest) mutate(ctx context.Context, op *batchOperation, run func(tx connector.QueryExecutor) (interface{}, error)) (interface{}, error) {
+	h := req.tables
+	if h.store != nil {
+		svc, err := h.store.GetServiceByName(ctx, req.serviceName)
+		if err == nil && svc.ReadOnly {
+			return nil, &gqlError{code: http.StatusForbidden, msg: "Service is read-only"}
+		}
+	}
+
+	if err := h.prepareBatchOperation(ctx, req.conn, req.serviceName, op); err != nil {
+		var ruleErr *columnRuleError
+		if errors.As(err, &ruleErr) {
+			return nil, &gqlError{code: ruleErr.code, msg: ruleErr.msg, ctx: map[string]interface{}{"column": ruleErr.column}}
+		}
+		return nil, queryError(err)
+	}
+	allowed, err := h.authorizeBatchOperation(ctx, req.serviceName, op)
+	if err != nil {
+		return nil, &gqlError{code: http.StatusInternalServerError, msg: "Failed to check access: " + err.Error()}
+	}
+	if !allowed {
+		return nil, &gqlError{code: http.StatusForbidden, msg: fmt.Sprintf("Access denied to %s on table: %s", op.Op, op.Table)}
+	}
+	if len(op.IDs) > 0 {
+		ids, err := resolveIDs(op.keyColumns, op.IDs, op.cols)
+		if err != nil {
+			return nil, queryError(fmt.Errorf("invalid ids: %w", err))
+		}
+		op.IDs = ids
+	}
+	if invalid := h.checkBatchOperation(ctx, req.serviceName, op); invalid != nil {
+		return nil, queryError(invalid)
+	}
+
+	tx, err := req.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, &gqlError{code: http.StatusInternalServerError, msg: "Failed to begin transaction: " + err.Error()}
+	}
+	defer func() { _ = tx.Rollback() }()
+	result, err := run(tx)
+	if err != nil {
+		var gqlErr *gqlError
+		if errors.As(err, &gqlErr) {
+			return nil, err
+		}
+		return nil, dbError(err, fmt.Sprintf("Failed to %s %s", op.Op, op.Table))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &gqlError{code: http.StatusInternalServerError, msg: "Failed to commit transaction: " + err.Error()}
+	}
+	return result, nil
+}
+
+func (t *gqlTable) resolveInsert(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	objects, _ := p.Args["objects"].([]interface{})
+	op := &batchOperation{Op: "insert", Table: t.def.Name}
+	for _, obj := range objects {
+		op.Records = append(op.Records, t.record(obj))
+	}
+	return req.mutate(p.Context, op, func(tx connector.QueryExecutor) (interface{}, error) {
+		res, err := req.tables.execBatchOperation(p.Context, tx, req.conn, req.serviceName, op)
+		if err != nil {
+			return nil, err
+		}
+		return res.Resource, nil
+	})
+}
+
+func (t *gqlTable) resolveUpdate(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	filter, _ := p.Args["filter"].(string)
+	op := &batchOperation{Op: "update", Table: t.def.Name, Record: t.record(p.Args["set"]), Filter: filter}
+	return req.mutate(p.Context, op, func(tx connector.QueryExecutor) (interface{}, error) {
+		res, err := req.tables.execBatchOperation(p.Context, tx, req.conn, req.serviceName, op)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"affected_rows": res.Count}, nil
+	})
+}
+
+func (t *gqlTable) resolveDelete(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	filter, _ := p.Args["filter"].(string)
+	op := &batchOperation{Op: "delete", Table: t.def.Name, Filter: filter}
+	return req.mutate(p.Context, op, func(tx connector.QueryExecutor) (interface{}, error) {
+		res, err := req.tables.execBatchOperation(p.Context, tx, req.conn, req.serviceName, op)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"affected_rows": res.Count}, nil
+	})
+}
+
+// resolveUpdateByKey updates one row and returns it as stored, read back by
+// its new key when the update changes the primary key.
+func (t *gqlTable) resolveUpdateByKey(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	op := &batchOperation{
+		Op:     "update",
+		Table:  t.def.Name,
+		Record: t.record(p.Args["set"]),
+		IDs:    []interface{}{gqlArg(t.key(p.Args))},
+	}
+	result, err := req.mutate(p.Context, op, func(tx connector.QueryExecutor) (interface{}, error) {
+		res, err := req.tables.execBatchOperation(p.Context, tx, req.conn, req.serviceName, op)
+		if err != nil || res.Count == 0 {
+			return nil, err
+		}
+		key := op.IDs[0]
+		if newKey, ok := recordKey(op.Record, op.keyColumns); ok {
+			key = gqlArg(newKey)
+		}
+		return req.fetchByKey(p.Context, tx, t.def.Name, op.keyColumns, key)
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if row, ok := result.(map[string]interface{}); ok && row == nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// resolveDeleteByKey deletes one row and returns it as it was.
+func (t *gqlTable) resolveDeleteByKey(p graphql.ResolveParams) (interface{}, error) {
+	req := gqlRequestFrom(p.Context)
+	op := &batchOperation{Op: "delete", Table: t.def.Name, IDs: []interface{}{gqlArg(t.key(p.Args))}}
+	result, err := req.mutate(p.Context, op, func(tx connector.QueryExecutor) (interface{}, error) {
+		row, err := req.fetchByKey(p.Context, tx, t.def.Name, op.keyColumns, op.IDs[0])
+		if err != nil || row == nil {
+			return nil, err
+		}
+		if _, err := req.tables.execBatchOperation(p.Context, tx, req.conn, req.serviceName, op); err != nil {
+			return nil, err
+		}
+		return row, nil
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if row, ok := result.(map[string]interface{}); ok && row == nil {
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// newGraphQLTestEnv mounts the GraphQL handler on a batch test env with
+// users, orders and order_items, and seeds two users with an order each.
+func newGraphQLTestEnv(t *testing.T) *batchTestEnv {
+	t.Helper()
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+	env.insertSeedData(t)
+	conn, _ := env.registry.Get("testdb")
+	for _, stmt := range []string{
+		`INSERT INTO orders (user_id, status) VALUES (1, 'paid'), (1, 'new'), (2, 'paid')`,
+		`INSERT INTO order_items (order_id, sku, qty) VALUES (1, 'A-1', 2), (1, 'B-2', 1), (3, 'C-3', 5)`,
+	} {
+		if _, err := conn.DB().ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	gh := NewGraphQLHandler(env.registry, env.store)
+	env.router.Get("/api/v1/{serviceName}/graphql", gh.Serve)
+	env.router.Post("/api/v1/{serviceName}/graphql", gh.Serve)
+	return env
+}
+
+type graphQLTestResult struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// graphql posts a query as principal p (nil for none) and decodes the result.
+func (e *batchTestEnv) graphql(t *testing.T, p *middleware.Principal, query string, variables map[string]interface{}) graphQLTestResult {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest("POST", "/api/v1/testdb/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if p != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, p))
+	}
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("graphql: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var res graphQLTestResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	return res
+}
+
+func requireNoGraphQLErrors(t *testing.T, res graphQLTestResult) {
+	t.Helper()
+	for _, e := range res.Errors {
+		t.Errorf("unexpected error: %s (%v)", e.Message, e.Extensions)
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	env := newGraphQLTestEnv(t)
+
+	res := env.graphql(t, nil, `{
+		users(order: "id") { id name orders(filter: "status = 'paid'") { id order_items(order: "sku") { sku qty } } }
+		orders(limit: 2, offset: 1, order: "id") { id user { name } }
+		users_by_pk(id: 2) { email }
+		missing: users_by_pk(id: 99) { email }
+		paid: orders_count(filter: "status = 'paid'")
+	}`, nil)
+	requireNoGraphQLErrors(t, res)
+
+	got, _ := json.Marshal(res.Data)
+	want := `{"missing":null,` +
+		`"orders":[{"id":2,"user":{"name":"Alice"}},{"id":3,"user":{"name":"Bob"}}],` +
+		`"paid":2,` +
+		`"users":[{"id":1,"name":"Alice","orders":[{"id":1,"order_items":[{"qty":2,"sku":"A-1"},{"qty":1,"sku":"B-2"}]}]},` +
+		`{"id":2,"name":"Bob","orders":[{"id":3,"order_items":[{"qty":5,"sku":"C-3"}]}]}],` +
+		`"users_by_pk":{"email":"bob@example.com"}}`
+	if string(got) != want {
+		t.Errorf("data:\n got %s\nwant %s", got, want)
+	}
+
+	// Child limits apply per parent row.
+	res = env.graphql(t, nil, `{ users(order: "id") { orders(order: "id", limit: 1) { id } } }`, nil)
+	requireNoGraphQLErrors(t, res)
+	got, _ = json.Marshal(res.Data)
+	if want := `{"users":[{"orders":[{"id":1}]},{"orders":[{"id":3}]}]}`; string(got) != want {
+		t.Errorf("limited children: got %s, want %s", got, want)
+	}
+
+	// Unknown columns fail like REST filters, with the status in extensions.
+	res = env.graphql(t, nil, `{ users(filter: "nmae = 'x'") { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(400) || res.Errors[0].Extensions["column"] != "nmae" {
+		t.Errorf("unknown column: got %+v", res.Errors)
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	env := newGraphQLTestEnv(t)
+
+	res := env.graphql(t, nil, `mutation($users: [users_insert_input!]!) {
+		insert_users(objects: $users) { id name }
+	}`, map[string]interface{}{"users": []interface{}{
+		map[string]interface{}{"name": "Carol", "email": "carol@example.com"},
+		map[string]interface{}{"name": "Dave", "email": "dave@example.com"},
+	}})
+	requireNoGraphQLErrors(t, res)
+	inserted := res.Data["insert_users"].([]interface{})
+	if len(inserted) != 2 || inserted[1].(map[string]interface{})["id"] != float64(4) {
+		t.Fatalf("insert: got %v", inserted)
+	}
+
+	res = env.graphql(t, nil, `mutation {
+		update_users_by_pk(id: 3, set: {name: "Caroline"}) { id name }
+		update_users(filter: "id > 3", set: {name: "D"}) { affected_rows }
+		delete_order_items(filter: "order_id = 1") { affected_rows }
+		delete_orders_by_pk(id: 2) { status }
+		none: delete_users_by_pk(id: 99) { id }
+	}`, nil)
+	requireNoGraphQLErrors(t, res)
+	got, _ := json.Marshal(res.Data)
+	want := `{"delete_order_items":{"affected_rows":2},"delete_orders_by_pk":{"status":"new"},"none":null,` +
+		`"update_users":{"affected_rows":1},"update_users_by_pk":{"id":3,"name":"Caroline"}}`
+	if string(got) != want {
+		t.Errorf("data:\n got %s\nwant %s", got, want)
+	}
+	if n := env.countTable(t, "orders"); n != 2 {
+		t.Errorf("orders: got %d rows, want 2", n)
+	}
+
+	// Records are validated like REST writes and nothing is inserted.
+	res = env.graphql(t, nil, `mutation { insert_users(objects: [{name: "Eve"}]) { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(400) {
+		t.Fatalf("invalid record: got %+v", res.Errors)
+	}
+	if fields, _ := res.Errors[0].Extensions["fields"].(map[string]interface{}); fields["email"] == nil {
+		t.Errorf("invalid record: expected a field error for email, got %v", res.Errors[0].Extensions)
+	}
+	if n := env.countRows(t); n != 4 {
+		t.Errorf("users: got %d rows, want 4", n)
+	}
+
+	// Constraint violations map to the REST status codes.
+	res = env.graphql(t, nil, `mutation { insert_users(objects: [{name: "A", email: "alice@example.com"}]) { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(409) {
+		t.Errorf("duplicate: got %+v", res.Errors)
+	}
+}
+
+func TestGraphQLAccess(t *testing.T) {
+	env := newGraphQLTestEnv(t)
+	ctx := context.Background()
+	role := &model.Role{Name: "reader", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbGet},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	key := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 1}
+
+	res := env.graphql(t, key, `{ users(order: "id") { name } }`, nil)
+	requireNoGraphQLErrors(t, res)
+
+	res = env.graphql(t, key, `{ orders { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(403) {
+		t.Errorf("orders: expected 403, got %+v", res.Errors)
+	}
+	res = env.graphql(t, key, `mutation { insert_users(objects: [{name: "Eve", email: "eve@example.com"}]) { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(403) {
+		t.Errorf("insert: expected 403, got %+v", res.Errors)
+	}
+
+	// Read-only services reject every mutation.
+	if err := env.store.CreateService(ctx, &model.ServiceConfig{
+		Name: "testdb", Driver: "sqlite", DSN: ":memory:", IsActive: true, ReadOnly: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	res = env.graphql(t, nil, `mutation { delete_users(filter: "id > 0") { affected_rows } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Message != "Service is read-only" {
+		t.Errorf("read-only: got %+v", res.Errors)
+	}
+	if n := env.countRows(t); n != 2 {
+		t.Errorf("users: got %d rows, want 2", n)
+	}
+}
+
+func TestGraphQLTransport(t *testing.T) {
+	env := newGraphQLTestEnv(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/graphql?query="+url.QueryEscape(`{ users_count }`), nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"users_count":2`) {
+		t.Errorf("GET query: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = env.do(t, "GET", "/api/v1/testdb/graphql?query="+url.QueryEscape(`mutation { delete_users(filter: "id > 0") { affected_rows } }`), nil)
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "POST" {
+		t.Errorf("GET mutation: expected 405, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/testdb/graphql", strings.NewReader(`{ users_by_pk(id: 1) { name } }`))
+	req.Header.Set("Content-Type", "application/graphql")
+	rr = httptest.NewRecorder()
+	env.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"Alice"`) {
+		t.Errorf("application/graphql: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := env.do(t, "POST", "/api/v1/testdb/graphql", map[string]interface{}{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("empty query: expected 400, got %d", rr.Code)
+	}
+	if rr := env.do(t, "POST", "/api/v1/nope/graphql", map[string]interface{}{"query": "{ x }"}); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown service: expected 404, got %d", rr.Code)
+	}
+}
+
+func TestGraphQLSchemaRebuild(t *testing.T) {
+	env := newGraphQLTestEnv(t)
+
+	// Building the schema once caches it.
+	if res := env.graphql(t, nil, `{ users_count }`, nil); len(res.Errors) > 0 {
+		t.Fatalf("users_count: %+v", res.Errors)
+	}
+
+	// DDL through the API invalidates the service schema.
+	rr := env.do(t, "POST", "/api/v1/testdb/_schema", map[string]interface{}{
+		"name":    "tags",
+		"columns": []map[string]interface{}{{"name": "id", "type": "INTEGER", "primary_key": true}, {"name": "label", "type": "TEXT"}},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create table: got %d %s", rr.Code, rr.Body.String())
+	}
+	res := env.graphql(t, nil, `{ tags { label } }`, nil)
+	requireNoGraphQLErrors(t, res)
+}
+
+func TestGQLLoaderBatches(t *testing.T) {
+	var calls [][][]interface{}
+	l := newGQLLoader(func(keys [][]interface{}) (map[string][]map[string]interface{}, error) {
+		calls = append(calls, keys)
+		rows := make(map[string][]map[string]interface{})
+		for _, key := range keys {
+			rows[gqlKey(key)] = []map[string]interface{}{{"id": key[0]}}
+		}
+		return rows, nil
+	})
+
+	var thunks []func() ([]map[string]interface{}, error)
+	for _, id := range []int64{1, 2, 1, 3} {
+		thunks = append(thunks, l.get([]interface{}{id}))
+	}
+	for i, thunk := range thunks {
+		rows, err := thunk()
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("thunk %d: rows %v, err %v", i, rows, err)
+		}
+	}
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Errorf("expected one load of 3 keys, got %v", calls)
+	}
+}
+
+func TestGQLName(t *testing.T) {
+	for in, want := range map[string]string{
+		"users":       "users",
+		"order items": "order_items",
+		"2fa":         "_2fa",
+		"__secret":    "_secret",
+		"naïve":       "na_ve",
+	} {
+		if got := gqlName(in); got != want {
+			t.Errorf("gqlName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
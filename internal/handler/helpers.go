@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,6 +36,20 @@ func writeError(w http.ResponseWriter, code int, message string, ctx ...map[stri
 	})
 }
 
+// requestOrigin returns the scheme and host the client addressed, honoring
+// X-Forwarded-Proto and X-Forwarded-Host from a reverse proxy.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
+}
+
 // readJSON decodes the request body as JSON into v. The body is closed after
 // decoding regardless of success or failure.
 func readJSON(r *http.Request, v interface{}) error {
@@ -152,3 +169,32 @@ func clampInt(val, min, max int) int {
 	}
 	return val
 }
+
+// encodeCursor returns the opaque pagination cursor for the given offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor reverses encodeCursor.
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || !strings.HasPrefix(string(raw), "offset:") || n < 0 {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	return n, nil
+}
+
+// normalizeJSONIDs converts integral JSON numbers (decoded as float64) back to
+// int64 so primary-key lookups bind with an integer type.
+func normalizeJSONIDs(ids []interface{}) []interface{} {
+	for i, id := range ids {
+		if f, ok := id.(float64); ok && f == math.Trunc(f) {
+			ids[i] = int64(f)
+		}
+	}
+	return ids
+}
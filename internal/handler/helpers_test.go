@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
 )
 
 // ---------------------------------------------------------------------------
@@ -150,17 +152,17 @@ func TestStringsToResources(t *testing.T) {
 }
 
 // ---------------------------------------------------------------------------
-// cleanMapValues tests
+// Generic value encoding tests
 // ---------------------------------------------------------------------------
 
-func TestCleanMapValues(t *testing.T) {
+func TestEncodeRowGeneric(t *testing.T) {
 	t.Run("converts byte slices to strings", func(t *testing.T) {
 		m := map[string]interface{}{
 			"name":  []byte("Alice"),
 			"email": []byte("alice@example.com"),
 			"id":    42,
 		}
-		cleanMapValues(m)
+		connector.NewValueEncoder(nil, nil, "").EncodeRow(m)
 
 		if m["name"] != "Alice" {
 			t.Errorf("expected name 'Alice', got %v", m["name"])
@@ -179,7 +181,7 @@ func TestCleanMapValues(t *testing.T) {
 			"active": true,
 			"name":   "Bob",
 		}
-		cleanMapValues(m)
+		connector.NewValueEncoder(nil, nil, "").EncodeRow(m)
 		if m["count"] != 100 || m["active"] != true || m["name"] != "Bob" {
 			t.Error("non-byte values should be unchanged")
 		}
@@ -324,3 +326,24 @@ func TestParseRecordsBody(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// encodeCursor / decodeCursor tests
+// ---------------------------------------------------------------------------
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 1, 25, 100000} {
+		got, err := decodeCursor(encodeCursor(offset))
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%d)): %v", offset, err)
+		}
+		if got != offset {
+			t.Errorf("round trip: got %d, want %d", got, offset)
+		}
+	}
+	for _, bad := range []string{"", "!!", "b2Zmc2V0Oi0x", "Zm9vOjE"} {
+		if _, err := decodeCursor(bad); err == nil {
+			t.Errorf("decodeCursor(%q): expected error", bad)
+		}
+	}
+}
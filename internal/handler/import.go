@@ -0,0 +1,860 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+const (
+	importDefaultBatchSize = 1000
+	importMaxBatchSize     = 10000
+	// importMaxReportedErrors caps the rejected rows listed in a response;
+	// the failed count keeps going.
+	importMaxReportedErrors = 1000
+)
+
+// ImportRecords streams CSV or NDJSON rows into a table in chunks.
+// POST /api/v1/{serviceName}/_table/{tableName}/_import
+//
+// The body is read as a stream and never buffered whole. Chunks are written
+// with the driver's bulk-load protocol where there is one (COPY on
+// PostgreSQL, bulk copy on SQL Server) and multi-row INSERTs otherwise.
+// Values are coerced to the column types of the introspected schema.
+//
+// Content types: text/csv, text/tab-separated-values, application/x-ndjson.
+//
+// Query parameters:
+//   - ?map=src:col,...      rename input fields to columns; "src:" drops a field
+//   - ?header=false         the CSV has no header row (use ?columns=)
+//   - ?columns=a,b,c        CSV field names in order (overrides the header row)
+//   - ?delimiter=;          CSV field delimiter ("tab" for tabs)
+//   - ?null=NULL            CSV field text that loads as NULL (default: empty)
+//   - ?batch_size=1000      rows per chunk (max 10000)
+//
+// Batch modes:
+//   - (default)         halt at the first bad row; prior chunks are committed
+//   - ?rollback=true    all-or-nothing in one transaction
+//   - ?continue=true    skip bad rows and report them
+func (h *TableHandler) ImportRecords(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	// A large upload takes longer to read and load than the server's read
+	// and write timeouts.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+	defer r.Body.Close()
+
+	src, err := newImportSource(r)
+	if err != nil {
+		var unsupported *unsupportedMediaTypeError
+		if errors.As(err, &unsupported) {
+			writeError(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid import: "+err.Error())
+		return
+	}
+
+	imp := &importer{
+		ctx:   r.Context(),
+		conn:  conn,
+		table: tableName,
+		cols:  h.tableColumns(r.Context(), serviceName, tableName),
+		types: h.recordValidator(r.Context(), serviceName, tableName),
+		src:   src,
+	}
+	if err := imp.prepare(); err != nil {
+		var rowErr *importRowError
+		if errors.As(err, &rowErr) {
+			writeError(w, http.StatusBadRequest, rowErr.Error(), map[string]interface{}{"row": rowErr.row})
+			return
+		}
+		writeQueryError(w, err)
+		return
+	}
+	probe := make(map[string]interface{}, len(imp.columns))
+	for _, col := range imp.columns {
+		probe[col] = nil
+	}
+	values, ruleErr := h.applyColumnRules(r.Context(), serviceName, tableName, model.ColumnRuleOnCreate, []map[string]interface{}{probe})
+	if ruleErr != nil {
+		writeColumnRuleError(w, ruleErr)
+		return
+	}
+	imp.fill(values)
+	imp.rejects = h.rejectColumns(r.Context(), serviceName, tableName, values)
+
+	batchSize := clampInt(queryInt(r, "batch_size", importDefaultBatchSize), 1, importMaxBatchSize)
+	if dryRunRequested(r) {
+		dryRunImport(w, imp, batchSize, start)
+		return
+	}
+	switch parseBatchMode(r) {
+	case BatchModeRollback:
+		err = imp.runAtomic(batchSize)
+	case BatchModeContinue:
+		err = imp.runContinue(batchSize)
+	default:
+		err = imp.runHalt(batchSize)
+	}
+	if err != nil {
+		writeImportError(w, err, imp.resp.Meta.Inserted)
+		return
+	}
+
+	imp.resp.Meta.TookMs = float64(time.Since(start).Microseconds()) / 1000.0
+	status := http.StatusCreated
+	if imp.resp.Meta.Failed > 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, imp.resp)
+}
+
+// dryRunImport loads the input atomically in a transaction that is always
+// rolled back. Only the first INSERT is listed, since an import can run
+// thousands of them; the would-be import response is returned as the result.
+func dryRunImport(w http.ResponseWriter, imp *importer, batchSize int, start time.Time) {
+	d, err := connector.BeginDryRun(imp.ctx, imp.conn)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+		return
+	}
+	d.MaxStatements = 1
+	err = imp.runDryRun(d, batchSize)
+	_ = d.Rollback()
+	if err != nil {
+		writeImportError(w, err, 0)
+		return
+	}
+	imp.resp.Meta.TookMs = float64(time.Since(start).Microseconds()) / 1000.0
+	inserted := imp.resp.Meta.Inserted
+	writeJSON(w, http.StatusOK, model.DryRunResponse{
+		DryRun:     true,
+		Statements: d.Statements,
+		Affected:   &inserted,
+		RolledBack: true,
+		Result:     imp.resp,
+	})
+}
+
+// writeImportError reports an import that stopped early. inserted counts
+// the rows committed before the failure (zero in rollback mode).
+func writeImportError(w http.ResponseWriter, err error, inserted int64) {
+	ctx := map[string]interface{}{"inserted": inserted}
+	var rowErr *importRowError
+	if errors.As(err, &rowErr) {
+		ctx["row"] = rowErr.row
+		if rowErr.db {
+			code, msg := classifyDBError(rowErr.err, "Import failed")
+			writeError(w, code, msg, ctx)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Import failed: "+rowErr.Error(), ctx)
+		return
+	}
+	code, msg := classifyDBError(err, "Import failed")
+	writeError(w, code, msg, ctx)
+}
+
+// importRowError reports an input row that cannot be loaded. db marks
+// errors returned by the database rather than by parsing or coercion.
+type importRowError struct {
+	row int64
+	err error
+	db  bool
+}
+
+func (e *importRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.row, e.err)
+}
+
+func (e *importRowError) Unwrap() error { return e.err }
+
+type unsupportedMediaTypeError struct {
+	mediaType string
+}
+
+func (e *unsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("unsupported content type %q (use text/csv, text/tab-separated-values or application/x-ndjson)", e.mediaType)
+}
+
+// importSource yields the rows of an import body as values keyed by column.
+type importSource interface {
+	// Columns returns the target columns when they are known before the
+	// first row (from a CSV header), or nil.
+	Columns() []string
+	// Next returns the next row, io.EOF at the end of the input, or a
+	// non-nil error wrapping a malformed row that may be skipped.
+	Next() (map[string]interface{}, error)
+}
+
+// errMalformedRow marks source errors that affect a single row.
+var errMalformedRow = errors.New("malformed row")
+
+// newImportSource builds the row source for the request's content type.
+func newImportSource(r *http.Request) (importSource, error) {
+	mapping, err := parseImportMapping(queryString(r, "map"))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, &unsupportedMediaTypeError{mediaType: r.Header.Get("Content-Type")}
+	}
+	switch mediaType {
+	case "text/csv", "text/tab-separated-values":
+		comma := ','
+		if mediaType == "text/tab-separated-values" {
+			comma = '\t'
+		}
+		if d := queryString(r, "delimiter"); d != "" {
+			if comma, err = parseDelimiter(d); err != nil {
+				return nil, err
+			}
+		}
+		var names []string
+		if s := queryString(r, "columns"); s != "" {
+			for _, name := range strings.Split(s, ",") {
+				names = append(names, strings.TrimSpace(name))
+			}
+		}
+		header := r.URL.Query().Get("header") != "false"
+		if !header && len(names) == 0 {
+			return nil, fmt.Errorf("header=false requires the columns parameter")
+		}
+		return newCSVImportSource(r.Body, comma, header, names, mapping, queryString(r, "null"))
+	case "application/x-ndjson", "application/jsonl", "application/jsonlines":
+		return &ndjsonImportSource{r: bufio.NewReaderSize(r.Body, 64*1024), mapping: mapping}, nil
+	}
+	return nil, &unsupportedMediaTypeError{mediaType: mediaType}
+}
+
+// parseImportMapping parses the map parameter: comma-separated src:column
+// pairs. An empty column drops the field.
+func parseImportMapping(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid map entry %q (expected source:column)", pair)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+// mapField returns the column an input field loads into, or "" to drop it.
+func mapField(name string, mapping map[string]string) string {
+	if to, ok := mapping[name]; ok {
+		return to
+	}
+	return name
+}
+
+// csvImportSource reads delimited text. Every record must have as many
+// fields as the header (or the columns parameter).
+type csvImportSource struct {
+	r       *csv.Reader
+	columns []string // target column of each field; "" drops the field
+	null    string
+}
+
+func newCSVImportSource(body io.Reader, comma rune, header bool, names []string, mapping map[string]string, null string) (*csvImportSource, error) {
+	r := csv.NewReader(body)
+	r.Comma = comma
+	r.ReuseRecord = true
+	if header {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty input")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		if len(names) == 0 {
+			names = append([]string(nil), fields...)
+			names[0] = strings.TrimPrefix(names[0], "\ufeff") // UTF-8 byte order mark
+		}
+	}
+	r.FieldsPerRecord = len(names)
+
+	columns := make([]string, len(names))
+	for i, name := range names {
+		columns[i] = mapField(strings.TrimSpace(name), mapping)
+	}
+	return &csvImportSource{r: r, columns: columns, null: null}, nil
+}
+
+func (s *csvImportSource) Columns() []string {
+	columns := make([]string, 0, len(s.columns))
+	for _, col := range s.columns {
+		if col != "" {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+func (s *csvImportSource) Next() (map[string]interface{}, error) {
+	fields, err := s.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("%w: %v", errMalformedRow, parseErr.Err)
+		}
+		return nil, err
+	}
+	rec := make(map[string]interface{}, len(fields))
+	for i, v := range fields {
+		col := s.columns[i]
+		if col == "" {
+			continue
+		}
+		if v == s.null {
+			rec[col] = nil
+		} else {
+			rec[col] = v
+		}
+	}
+	return rec, nil
+}
+
+// ndjsonImportSource reads one JSON object per line. Blank lines are
+// skipped. Nested objects and arrays load as JSON text.
+type ndjsonImportSource struct {
+	r       *bufio.Reader
+	mapping map[string]string
+}
+
+func (s *ndjsonImportSource) Columns() []string { return nil }
+
+func (s *ndjsonImportSource) Next() (map[string]interface{}, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var obj map[string]interface{}
+		if derr := dec.Decode(&obj); derr != nil {
+			return nil, fmt.Errorf("%w: %v", errMalformedRow, derr)
+		}
+
+		rec := make(map[string]interface{}, len(obj))
+		for k, v := range obj {
+			col := mapField(k, s.mapping)
+			if col == "" {
+				continue
+			}
+			switch val := v.(type) {
+			case json.Number:
+				if n, err := val.Int64(); err == nil {
+					v = n
+				} else if f, err := val.Float64(); err == nil {
+					v = f
+				} else {
+					v = val.String()
+				}
+			case map[string]interface{}, []interface{}:
+				b, _ := json.Marshal(val)
+				v = string(b)
+			}
+			rec[col] = v
+		}
+		return rec, nil
+	}
+}
+
+// importRow is one converted input row and its 1-based position.
+type importRow struct {
+	row     int64
+	columns []string // the columns values are for
+	values  []interface{}
+}
+
+// importer drives one import: it converts source rows to column values and
+// writes them in chunks according to the batch mode.
+type importer struct {
+	ctx     context.Context
+	conn    connector.Connector
+	table   string
+	cols    query.ColumnSet
+	types   *recordValidator // decodes binary columns; nil without a schema
+	src     importSource
+	columns []string // header columns, then those filled by column rules
+	index   map[string]int
+	fixed   map[int]interface{}    // values set by column rules, by column index
+	rejects []string               // rule-filled columns whose client values are refused
+	perRow  bool                   // no header: each row names its own columns
+	pending map[string]interface{} // first NDJSON row, read to reject an empty input
+	row     int64                  // rows read so far
+	resp    model.ImportResponse
+}
+
+// prepare fixes the target columns of a source with a header and checks
+// them against the table schema. Without a header, each row's columns are
+// checked as it is read (see rowColumns).
+func (imp *importer) prepare() error {
+	imp.columns = imp.src.Columns()
+	imp.index = make(map[string]int, len(imp.columns))
+	if imp.columns == nil {
+		rec, err := imp.src.Next()
+		if err == io.EOF {
+			return fmt.Errorf("empty input")
+		}
+		if err != nil {
+			if errors.Is(err, errMalformedRow) {
+				return &importRowError{row: 1, err: err}
+			}
+			return err
+		}
+		imp.pending = rec
+		imp.perRow = true
+		return nil
+	}
+	if len(imp.columns) == 0 {
+		return fmt.Errorf("no columns to import")
+	}
+
+	for i, col := range imp.columns {
+		if _, dup := imp.index[col]; dup {
+			return fmt.Errorf("column %q is mapped more than once", col)
+		}
+		if err := imp.cols.Check(col); err != nil {
+			return err
+		}
+		imp.index[col] = i
+	}
+	return nil
+}
+
+// fill sets columns filled by column rules on every row, adding them to the
+// target columns when the input lacks them.
+func (imp *importer) fill(values map[string]interface{}) {
+	for col, v := range values {
+		i := -1
+		for j, name := range imp.columns {
+			if strings.EqualFold(name, col) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			i = len(imp.columns)
+			imp.columns = append(imp.columns, col)
+			imp.index[col] = i
+		}
+		if imp.fixed == nil {
+			imp.fixed = make(map[int]interface{}, len(values))
+		}
+		imp.fixed[i] = v
+	}
+}
+
+// rowColumns returns the columns of a row from a source without a header:
+// those filled by column rules, then the row's own fields in sorted order.
+// Columns the row lacks are left out, so that they keep their defaults.
+func (imp *importer) rowColumns(rec map[string]interface{}) ([]string, map[string]int, error) {
+	fields := make([]string, 0, len(rec))
+	for k := range rec {
+		if containsFold(imp.rejects, k) {
+			return nil, nil, fmt.Errorf("column %s is set by the server and cannot be supplied", k)
+		}
+		if containsFold(imp.columns, k) {
+			continue // replaced by the column rule's value
+		}
+		if err := imp.cols.Check(k); err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	columns := append(imp.columns[:len(imp.columns):len(imp.columns)], fields...)
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("no columns to import")
+	}
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+	}
+	return columns, index, nil
+}
+
+// next returns the next converted row, io.EOF at the end of the input, an
+// *importRowError for a row that cannot be loaded, or a fatal read error.
+func (imp *importer) next() (importRow, error) {
+	var rec map[string]interface{}
+	var err error
+	if imp.pending != nil {
+		rec, imp.pending = imp.pending, nil
+	} else {
+		rec, err = imp.src.Next()
+	}
+	if err == io.EOF {
+		return importRow{}, io.EOF
+	}
+	imp.row++
+	imp.resp.Meta.Rows = imp.row
+	if err != nil {
+		if errors.Is(err, errMalformedRow) {
+			return importRow{}, &importRowError{row: imp.row, err: err}
+		}
+		return importRow{}, err
+	}
+
+	columns, index := imp.columns, imp.index
+	if imp.perRow {
+		if columns, index, err = imp.rowColumns(rec); err != nil {
+			return importRow{}, &importRowError{row: imp.row, err: err}
+		}
+	}
+	values := make([]interface{}, len(columns))
+	for k, v := range rec {
+		i, ok := index[k]
+		if !ok && imp.perRow {
+			continue
+		}
+		if !ok {
+			return importRow{}, &importRowError{row: imp.row, err: fmt.Errorf("unexpected field %q", k)}
+		}
+		coerced, err := imp.cols.Coerce(k, v)
+		if err == nil {
+			coerced, err = imp.types.decodeBinary(k, coerced)
+		}
+		if err != nil {
+			return importRow{}, &importRowError{row: imp.row, err: err}
+		}
+		values[i] = coerced
+	}
+	for i, v := range imp.fixed {
+		values[i] = v
+	}
+	return importRow{row: imp.row, columns: columns, values: values}, nil
+}
+
+// readChunk reads up to size rows. With skipBad, rows that cannot be
+// converted are recorded as failures and skipped; otherwise the first one
+// is returned as the error, along with the rows read before it. done
+// reports the end of the input.
+func (imp *importer) readChunk(size int, skipBad bool) (chunk []importRow, done bool, err error) {
+	chunk = make([]importRow, 0, size)
+	for len(chunk) < size {
+		row, err := imp.next()
+		if err == io.EOF {
+			return chunk, true, nil
+		}
+		var rowErr *importRowError
+		if errors.As(err, &rowErr) && skipBad {
+			imp.fail(rowErr)
+			continue
+		}
+		if err != nil {
+			return chunk, false, err
+		}
+		chunk = append(chunk, row)
+	}
+	return chunk, false, nil
+}
+
+// fail records a rejected row.
+func (imp *importer) fail(rowErr *importRowError) {
+	imp.resp.Meta.Failed++
+	if len(imp.resp.Errors) < importMaxReportedErrors {
+		msg := rowErr.err.Error()
+		if rowErr.db {
+			_, msg = classifyDBError(rowErr.err, "Insert failed")
+		}
+		imp.resp.Errors = append(imp.resp.Errors, model.ImportError{Row: rowErr.row, Message: msg})
+	}
+}
+
+// runHalt writes chunk after chunk, each committed on its own, and stops at
+// the first row that cannot be loaded. Rows before it are written.
+func (imp *importer) runHalt(batchSize int) error {
+	for {
+		chunk, done, readErr := imp.readChunk(batchSize, false)
+		if err := imp.loadChunk(chunk); err != nil {
+			return err
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// runContinue writes chunk after chunk, skipping bad rows. A chunk the
+// database rejects is retried row by row to isolate the failing rows.
+func (imp *importer) runContinue(batchSize int) error {
+	for {
+		chunk, done, err := imp.readChunk(batchSize, true)
+		if err != nil {
+			return err
+		}
+		if err := imp.loadChunk(chunk); err != nil {
+			var rowErr *importRowError
+			if !errors.As(err, &rowErr) || !rowErr.db {
+				return err
+			}
+			for _, row := range chunk {
+				n, err := imp.insertRows(imp.conn.DB(), []importRow{row})
+				if err != nil {
+					imp.fail(&importRowError{row: row.row, err: err, db: true})
+					continue
+				}
+				imp.resp.Meta.Inserted += n
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// runAtomic writes every row in one transaction. With a bulk loader and a
+// header, the whole input is streamed through a single load.
+func (imp *importer) runAtomic(batchSize int) error {
+	if loader, ok := imp.conn.(connector.BulkLoader); ok && !imp.perRow {
+		src := &importRowSource{imp: imp}
+		n, err := loader.BulkLoad(imp.ctx, imp.table, imp.columns, src)
+		if src.err != nil {
+			return src.err
+		}
+		if err != nil {
+			return err
+		}
+		imp.resp.Meta.Inserted = n
+		return nil
+	}
+
+	tx, err := imp.conn.BeginTx(imp.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	inserted, err := imp.insertAll(tx, batchSize)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	imp.resp.Meta.Inserted = inserted
+	return nil
+}
+
+// runDryRun writes every row like runAtomic, always with INSERT statements,
+// on a dry run executor whose transaction the caller rolls back.
+func (imp *importer) runDryRun(d *connector.DryRun, batchSize int) error {
+	inserted, err := imp.insertAll(d, batchSize)
+	if err != nil {
+		return err
+	}
+	imp.resp.Meta.Inserted = inserted
+	return nil
+}
+
+// insertAll writes the whole input on exec in chunks of batchSize rows.
+func (imp *importer) insertAll(exec connector.QueryExecutor, batchSize int) (int64, error) {
+	var inserted int64
+	for {
+		chunk, done, err := imp.readChunk(batchSize, false)
+		if err != nil {
+			return inserted, err
+		}
+		n, err := imp.insertRows(exec, chunk)
+		if err != nil {
+			return inserted, &importRowError{row: chunk[0].row, err: err, db: true}
+		}
+		inserted += n
+		if done {
+			return inserted, nil
+		}
+	}
+}
+
+// loadChunk writes one chunk, through the bulk loader when the driver has
+// one. A database error is reported against the chunk's first row.
+func (imp *importer) loadChunk(chunk []importRow) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	var n int64
+	var err error
+	if loader, ok := imp.conn.(connector.BulkLoader); ok {
+		for _, group := range groupImportRows(chunk) {
+			var loaded int64
+			loaded, err = loader.BulkLoad(imp.ctx, imp.table, group[0].columns, &importRowSource{rows: group})
+			n += loaded
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		n, err = imp.insertRows(imp.conn.DB(), chunk)
+	}
+	if err != nil {
+		return &importRowError{row: chunk[0].row, err: err, db: true}
+	}
+	imp.resp.Meta.Inserted += n
+	return nil
+}
+
+// insertRows writes rows with multi-row INSERTs, one set of statements per
+// group of rows with the same columns.
+func (imp *importer) insertRows(exec connector.QueryExecutor, rows []importRow) (int64, error) {
+	var inserted int64
+	for _, group := range groupImportRows(rows) {
+		n, err := imp.insertGroup(exec, group)
+		inserted += n
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+// groupImportRows splits rows into groups with the same columns, in the
+// order each group first appears.
+func groupImportRows(rows []importRow) [][]importRow {
+	var groups [][]importRow
+	index := make(map[string]int)
+	for _, row := range rows {
+		key := strings.Join(row.columns, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], row)
+	}
+	return groups
+}
+
+// insertGroup writes rows with the same columns, as many rows per statement
+// as the driver's bind parameter limit allows.
+func (imp *importer) insertGroup(exec connector.QueryExecutor, rows []importRow) (int64, error) {
+	columns := rows[0].columns
+	perStatement := importParamLimit(imp.conn.DriverName()) / len(columns)
+	if perStatement < 1 {
+		perStatement = 1
+	}
+
+	var inserted int64
+	for len(rows) > 0 {
+		n := min(perStatement, len(rows))
+		records := make([]map[string]interface{}, n)
+		for i, row := range rows[:n] {
+			rec := make(map[string]interface{}, len(columns))
+			for j, col := range columns {
+				rec[col] = row.values[j]
+			}
+			records[i] = rec
+		}
+
+		// RETURNING (or OUTPUT) rows, where the INSERT carries them, are
+		// discarded: only the row count is reported.
+		sqlStr, args, err := imp.conn.BuildInsert(imp.ctx, connector.InsertRequest{Table: imp.table, Records: records})
+		if err != nil {
+			return inserted, err
+		}
+		if _, err := exec.ExecContext(imp.ctx, sqlStr, args...); err != nil {
+			return inserted, err
+		}
+		inserted += int64(n)
+		rows = rows[n:]
+	}
+	return inserted, nil
+}
+
+// importParamLimit is the number of bind parameters a single statement may
+// carry on each driver.
+func importParamLimit(driver string) int {
+	switch driver {
+	case "mssql":
+		return 2000 // hard limit 2100
+	case "sqlite":
+		return 32766
+	default:
+		return 65535
+	}
+}
+
+// importRowSource feeds a bulk load either from buffered rows or, when imp
+// is set, straight from the import stream. A row that cannot be converted
+// stops the load and is kept in err.
+type importRowSource struct {
+	rows []importRow
+	imp  *importer
+	cur  []interface{}
+	err  error
+}
+
+func (s *importRowSource) Next() bool {
+	if s.imp == nil {
+		if len(s.rows) == 0 {
+			return false
+		}
+		s.cur, s.rows = s.rows[0].values, s.rows[1:]
+		return true
+	}
+	row, err := s.imp.next()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.cur = row.values
+	return true
+}
+
+func (s *importRowSource) Values() ([]interface{}, error) { return s.cur, nil }
+
+func (s *importRowSource) Err() error { return s.err }
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func (e *batchTestEnv) importBody(t *testing.T, path, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestImportRecords_CSV(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	body := "Full Name,email,ignored\nAlice,alice@example.com,x\n\"Bob, Jr.\",bob@example.com,y\nCarol,carol@example.com,z\n"
+	rr := env.importBody(t, "/api/v1/testdb/_table/users/_import?map=Full%20Name:name,ignored:&batch_size=2", "text/csv", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Meta.Rows != 3 || resp.Meta.Inserted != 3 || resp.Meta.Failed != 0 {
+		t.Errorf("unexpected meta: %+v", resp.Meta)
+	}
+
+	conn, _ := env.registry.Get("testdb")
+	var name string
+	conn.DB().QueryRowxContext(context.Background(), "SELECT name FROM users WHERE email = 'bob@example.com'").Scan(&name)
+	if name != "Bob, Jr." {
+		t.Errorf("expected quoted field to load, got %q", name)
+	}
+}
+
+func TestImportRecords_NDJSONCoercion(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	// Headerless TSV: ids arrive as text and are coerced to integers.
+	rr := env.importBody(t, "/api/v1/testdb/_table/users/_import?header=false&columns=id,name,email",
+		"text/tab-separated-values", "10\tAlice\talice@example.com\n11\tBob\tbob@example.com\n")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("tsv: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	body := `{"id": 12, "name": "Carol", "email": "carol@example.com"}
+
+{"id": 13, "name": "Dave", "email": "dave@example.com"}`
+	rr = env.importBody(t, "/api/v1/testdb/_table/users/_import", "application/x-ndjson", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("ndjson: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	conn, _ := env.registry.Get("testdb")
+	var typ string
+	conn.DB().QueryRowxContext(context.Background(), "SELECT typeof(id) FROM users WHERE name = 'Alice'").Scan(&typ)
+	if typ != "integer" {
+		t.Errorf("expected id stored as integer, got %s", typ)
+	}
+	if count := env.countRows(t); count != 4 {
+		t.Errorf("expected 4 rows, got %d", count)
+	}
+}
+
+func TestImportRecords_NDJSONOwnColumns(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(context.Background(),
+		`CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'open', priority INTEGER)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	// Each row writes only its own fields: a row without status takes the
+	// default, and a later row may name a column the first one lacks.
+	body := `{"title": "a"}
+{"title": "b", "priority": 2}
+{"title": "c", "status": "done"}
+{"priority": 3, "title": "d"}`
+	rr := env.importBody(t, "/api/v1/testdb/_table/tasks/_import?batch_size=10", "application/x-ndjson", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Meta.Inserted != 4 {
+		t.Errorf("unexpected meta: %+v", resp.Meta)
+	}
+	var status string
+	var priority int
+	conn.DB().QueryRowxContext(context.Background(), "SELECT status FROM tasks WHERE title = 'a'").Scan(&status)
+	if status != "open" {
+		t.Errorf("expected the default status, got %q", status)
+	}
+	conn.DB().QueryRowxContext(context.Background(), "SELECT priority FROM tasks WHERE title = 'd'").Scan(&priority)
+	if priority != 3 {
+		t.Errorf("expected priority 3, got %d", priority)
+	}
+
+	// A field that is not a column of the table fails its row.
+	rr = env.importBody(t, "/api/v1/testdb/_table/tasks/_import", "application/x-ndjson", `{"title": "e"}
+{"title": "f", "nope": 1}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "nope") {
+		t.Errorf("expected 400 naming the unknown field, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// badImport has a non-integer id on row 2 and a duplicate email on row 4.
+const badImport = "id,name,email\n1,Alice,alice@example.com\nx,Bob,bob@example.com\n3,Carol,carol@example.com\n4,Dup,alice@example.com\n5,Eve,eve@example.com\n"
+
+func TestImportRecords_Continue(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	rr := env.importBody(t, "/api/v1/testdb/_table/users/_import?continue=true", "text/csv", badImport)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Meta.Rows != 5 || resp.Meta.Inserted != 3 || resp.Meta.Failed != 2 {
+		t.Errorf("unexpected meta: %+v", resp.Meta)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Row != 2 || resp.Errors[1].Row != 4 {
+		t.Errorf("unexpected error rows: %+v", resp.Errors)
+	}
+	if count := env.countRows(t); count != 3 {
+		t.Errorf("expected 3 rows, got %d", count)
+	}
+}
+
+func TestImportRecords_HaltAndRollback(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	// Halt: rows before the bad one are kept.
+	rr := env.importBody(t, "/api/v1/testdb/_table/users/_import", "text/csv", badImport)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("halt: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var errResp model.ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &errResp)
+	if errResp.Error.Context["row"] != float64(2) || errResp.Error.Context["inserted"] != float64(1) {
+		t.Errorf("halt: unexpected error context %v", errResp.Error.Context)
+	}
+	if count := env.countRows(t); count != 1 {
+		t.Errorf("halt: expected 1 row, got %d", count)
+	}
+
+	// Rollback: a database error discards the whole import.
+	body := "name,email\nBob,bob@example.com\nCarol,carol@example.com\nDup,alice@example.com\n"
+	rr = env.importBody(t, "/api/v1/testdb/_table/users/_import?rollback=true&batch_size=2", "text/csv", body)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("rollback: expected 409, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if count := env.countRows(t); count != 1 {
+		t.Errorf("rollback: expected 1 row, got %d", count)
+	}
+}
+
+func TestImportRecords_Invalid(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	cases := []struct {
+		path, contentType, body string
+		want                    int
+	}{
+		{"/api/v1/testdb/_table/users/_import", "application/json", `[]`, http.StatusUnsupportedMediaType},
+		{"/api/v1/testdb/_table/users/_import", "text/csv", "nmae,email\nA,a@example.com\n", http.StatusBadRequest},
+		{"/api/v1/testdb/_table/users/_import?header=false", "text/csv", "A,a@example.com\n", http.StatusBadRequest},
+		{"/api/v1/testdb/_table/users/_import?map=name", "text/csv", "name,email\n", http.StatusBadRequest},
+		{"/api/v1/testdb/_table/users/_import", "application/x-ndjson", "{not json}\n", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		rr := env.importBody(t, c.path, c.contentType, c.body)
+		if rr.Code != c.want {
+			t.Errorf("%s (%s): expected %d, got %d; body: %s", c.path, c.contentType, c.want, rr.Code, rr.Body.String())
+		}
+	}
+	if count := env.countRows(t); count != 0 {
+		t.Errorf("expected 0 rows, got %d", count)
+	}
+}
@@ -0,0 +1,480 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// NamedQueryHandler manages admin-defined parameterized queries and executes
+// them at /api/v1/{serviceName}/_query/{queryName}. A single instance should
+// serve both the admin and the execution routes so that edits invalidate the
+// result cache.
+type NamedQueryHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	cache    *queryResultCache
+	onChange func()
+}
+
+// NewNamedQueryHandler creates a new NamedQueryHandler.
+func NewNamedQueryHandler(registry *connector.Registry, store *config.Store) *NamedQueryHandler {
+	return &NamedQueryHandler{
+		registry: registry,
+		store:    store,
+		cache:    newQueryResultCache(),
+	}
+}
+
+// OnChange registers fn to be called after a named query is created, updated
+// or deleted, e.g. to refresh the MCP tools derived from saved queries.
+func (h *NamedQueryHandler) OnChange(fn func()) {
+	h.onChange = fn
+}
+
+// ListQueries returns all named queries defined for a service.
+// GET /api/v1/system/query/{serviceName}
+func (h *NamedQueryHandler) ListQueries(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+
+	queries, err := h.store.ListNamedQueries(r.Context(), serviceName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list queries: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"service": serviceName,
+		"queries": queries,
+	})
+}
+
+// CreateQuery saves a new named query for a service.
+// POST /api/v1/system/query/{serviceName}
+func (h *NamedQueryHandler) CreateQuery(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+
+	if _, err := h.store.GetServiceByName(r.Context(), serviceName); err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	var nq model.NamedQuery
+	if err := readJSON(r, &nq); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	nq.ServiceName = serviceName
+
+	if err := query.ValidateNamedQuery(&nq); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+
+	existing, err := h.store.GetNamedQuery(r.Context(), serviceName, nq.Name)
+	if err == nil && existing != nil {
+		writeError(w, http.StatusConflict, "Query already exists: "+nq.Name)
+		return
+	}
+
+	if err := h.store.CreateNamedQuery(r.Context(), &nq); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create query: "+err.Error())
+		return
+	}
+	h.changed(serviceName, nq.Name)
+
+	writeJSON(w, http.StatusCreated, nq)
+}
+
+// GetQuery returns a single named query definition.
+// GET /api/v1/system/query/{serviceName}/{queryName}
+func (h *NamedQueryHandler) GetQuery(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+	queryName := chi.URLParam(r, "queryName")
+
+	nq, err := h.store.GetNamedQuery(r.Context(), serviceName, queryName)
+	if err != nil {
+		if err == config.ErrNotFound {
+			writeError(w, http.StatusNotFound, "Query not found: "+queryName)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get query: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nq)
+}
+
+// UpdateQuery replaces the definition of a named query. The query's service
+// and name are taken from the URL.
+// PUT /api/v1/system/query/{serviceName}/{queryName}
+func (h *NamedQueryHandler) UpdateQuery(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+	queryName := chi.URLParam(r, "queryName")
+
+	existing, err := h.store.GetNamedQuery(r.Context(), serviceName, queryName)
+	if err != nil {
+		if err == config.ErrNotFound {
+			writeError(w, http.StatusNotFound, "Query not found: "+queryName)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get query: "+err.Error())
+		return
+	}
+
+	var nq model.NamedQuery
+	if err := readJSON(r, &nq); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	nq.ID = existing.ID
+	nq.ServiceName = serviceName
+	nq.Name = queryName
+	nq.CreatedAt = existing.CreatedAt
+
+	if err := query.ValidateNamedQuery(&nq); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+
+	if err := h.store.UpdateNamedQuery(r.Context(), &nq); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update query: "+err.Error())
+		return
+	}
+	h.changed(serviceName, queryName)
+
+	writeJSON(w, http.StatusOK, nq)
+}
+
+// DeleteQuery removes a named query.
+// DELETE /api/v1/system/query/{serviceName}/{queryName}
+func (h *NamedQueryHandler) DeleteQuery(w http.ResponseWriter, r *http.Request) {
+	serviceName := chi.URLParam(r, "serviceName")
+	queryName := chi.URLParam(r, "queryName")
+
+	if err := h.store.DeleteNamedQuery(r.Context(), serviceName, queryName); err != nil {
+		if err == config.ErrNotFound {
+			writeError(w, http.StatusNotFound, "Query not found: "+queryName)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to delete query: "+err.Error())
+		return
+	}
+	h.changed(serviceName, queryName)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Query removed: " + serviceName + "/" + queryName,
+	})
+}
+
+// RunQuery executes a named query. Arguments come from the query string for
+// GET and from a flat JSON object body for POST (query string values fill in
+// any argument the body omits). Queries marked as writes must use POST and
+// are refused on read-only services. Read results are cached for the query's
+// cache_ttl; the X-Cache response header reports HIT or MISS.
+//
+// Access for API keys is governed by role rules on the components
+// "_query/{queryName}", "_query/*" or "*".
+// GET|POST /api/v1/{serviceName}/_query/{queryName}
+func (h *NamedQueryHandler) RunQuery(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	serviceName := chi.URLParam(r, "serviceName")
+	queryName := chi.URLParam(r, "queryName")
+
+	allowed, err := authorizeComponent(r.Context(), h.store, serviceName,
+		[]string{"_query/*", "_query/" + queryName}, verbForMethod(r.Method))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to check access: "+err.Error())
+		return
+	}
+	if !allowed {
+		writeError(w, http.StatusForbidden, "Access denied to query: "+queryName)
+		return
+	}
+
+	nq, err := h.store.GetNamedQuery(r.Context(), serviceName, queryName)
+	if err != nil {
+		if err == config.ErrNotFound {
+			writeError(w, http.StatusNotFound, "Query not found: "+queryName)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get query: "+err.Error())
+		return
+	}
+
+	if nq.Write {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "Query "+queryName+" modifies data and must be called with POST")
+			return
+		}
+		svc, err := h.store.GetServiceByName(r.Context(), serviceName)
+		if err == nil && svc.ReadOnly {
+			writeError(w, http.StatusForbidden, "Service is read-only")
+			return
+		}
+	}
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid output format: "+err.Error())
+		return
+	}
+
+	args, err := queryArguments(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	// Output options are not arguments, unless the query declares them.
+	for _, key := range outputParams {
+		if !namedQueryHasParam(nq, key) && r.URL.Query().Has(key) {
+			delete(args, key)
+		}
+	}
+
+	sqlStr, params, err := query.BindNamedQuery(nq, args, conn.ParameterPlaceholder)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query arguments: "+err.Error())
+		return
+	}
+
+	if nq.Write {
+		result, err := conn.DB().ExecContext(r.Context(), sqlStr, params...)
+		if err != nil {
+			code, msg := classifyDBError(err, "Query failed")
+			writeError(w, code, msg)
+			return
+		}
+		affected, _ := result.RowsAffected()
+		h.cache.invalidate(serviceName, "")
+
+		took := time.Since(start)
+		writeRecords(w, r, format, queryName, []string{"rows_affected"}, []map[string]interface{}{{"rows_affected": affected}}, &model.ResponseMeta{
+			Count:  1,
+			TookMs: float64(took.Microseconds()) / 1000.0,
+		})
+		return
+	}
+
+	key := queryCacheKey(serviceName, queryName, sqlStr, params)
+	columns, records, gen, hit := h.cache.get(key)
+	if !hit {
+		columns, records, err = runReadQuery(r.Context(), conn, numericFormat(r.Context(), h.store, serviceName), sqlStr, params)
+		if err != nil {
+			code, msg := classifyDBError(err, "Query failed")
+			writeError(w, code, msg)
+			return
+		}
+		if nq.CacheTTL > 0 {
+			h.cache.put(key, gen, serviceName, queryName, columns, records, time.Duration(nq.CacheTTL)*time.Second)
+		}
+	}
+	if nq.CacheTTL > 0 {
+		if hit {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
+		}
+	}
+
+	took := time.Since(start)
+	writeRecords(w, r, format, queryName, columns, records, &model.ResponseMeta{
+		Count:  len(records),
+		TookMs: float64(took.Microseconds()) / 1000.0,
+	})
+}
+
+// namedQueryHasParam reports whether nq declares a parameter called name.
+func namedQueryHasParam(nq *model.NamedQuery, name string) bool {
+	for _, p := range nq.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// changed drops cached results for a query and notifies the OnChange hook.
+func (h *NamedQueryHandler) changed(serviceName, queryName string) {
+	h.cache.invalidate(serviceName, queryName)
+	if h.onChange != nil {
+		h.onChange()
+	}
+}
+
+// queryArguments collects named query arguments from the request.
+func queryArguments(r *http.Request) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	if r.Method == http.MethodPost && r.Body != nil && r.ContentLength != 0 {
+		if err := readJSON(r, &args); err != nil {
+			return nil, err
+		}
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+	}
+	for key, values := range r.URL.Query() {
+		if _, exists := args[key]; !exists && len(values) > 0 {
+			args[key] = values[0]
+		}
+	}
+	return args, nil
+}
+
+// runReadQuery executes a read-only statement and returns its columns, in
+// select order, and all rows, encoded by their result column types.
+func runReadQuery(ctx context.Context, conn connector.Connector, numeric, sqlStr string, params []interface{}) ([]string, []map[string]interface{}, error) {
+	rows, err := conn.DB().QueryxContext(ctx, sqlStr, params...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	enc := connector.NewResultEncoder(conn, types, numeric)
+	records := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return nil, nil, err
+		}
+		enc.EncodeRow(row)
+		records = append(records, row)
+	}
+	return columns, records, rows.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Result cache
+// ---------------------------------------------------------------------------
+
+const (
+	queryCacheMaxEntries    = 1000        // results held at once; a full cache evicts one at random
+	queryCacheSweepInterval = time.Minute // how often put drops expired results
+)
+
+// queryResultCache holds the results of read-only named queries for their
+// configured TTL. Entries are keyed by service, query name and bound
+// arguments.
+//
+// The cache holds at most queryCacheMaxEntries results, and expired ones are
+// swept out as new ones are stored.
+type queryResultCache struct {
+	mu        sync.Mutex
+	entries   map[string]cachedQueryResult
+	gen       uint64 // advanced by invalidate, so results read before it are not stored
+	nextSweep time.Time
+}
+
+type cachedQueryResult struct {
+	service string
+	name    string
+	columns []string
+	records []map[string]interface{}
+	expires time.Time
+}
+
+func newQueryResultCache() *queryResultCache {
+	return &queryResultCache{entries: make(map[string]cachedQueryResult)}
+}
+
+func queryCacheKey(serviceName, queryName, sqlStr string, params []interface{}) string {
+	var b strings.Builder
+	b.WriteString(serviceName)
+	b.WriteByte(0)
+	b.WriteString(queryName)
+	b.WriteByte(0)
+	b.WriteString(sqlStr)
+	for _, p := range params {
+		fmt.Fprintf(&b, "\x00%T:%v", p, p)
+	}
+	return b.String()
+}
+
+// get returns the cached result for key. On a miss it returns the cache
+// generation to pass to put with the result of running the query.
+func (c *queryResultCache) get(key string) ([]string, []map[string]interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, nil, c.gen, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, nil, c.gen, false
+	}
+	return e.columns, e.records, 0, true
+}
+
+// put stores a result read at generation gen. It is dropped when the cache
+// was invalidated since, as it may predate the change.
+func (c *queryResultCache) put(key string, gen uint64, serviceName, queryName string, columns []string, records []map[string]interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(queryCacheSweepInterval)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= queryCacheMaxEntries {
+		// Map iteration order is random, which makes this a random eviction.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cachedQueryResult{
+		service: serviceName,
+		name:    queryName,
+		columns: columns,
+		records: records,
+		expires: now.Add(ttl),
+	}
+}
+
+// invalidate drops cached results for a query, or for every query of the
+// service when queryName is empty.
+func (c *queryResultCache) invalidate(serviceName, queryName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key, e := range c.entries {
+		if e.service == serviceName && (queryName == "" || e.name == queryName) {
+			delete(c.entries, key)
+		}
+	}
+}
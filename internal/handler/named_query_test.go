@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// ---------------------------------------------------------------------------
+// Saved query (system/query and {service}/_query/{name}) tests
+// ---------------------------------------------------------------------------
+
+type namedQueryTestEnv struct {
+	*batchTestEnv
+	queries *NamedQueryHandler
+	changes int
+}
+
+func newNamedQueryTestEnv(t *testing.T) *namedQueryTestEnv {
+	t.Helper()
+	base := newBatchTestEnv(t)
+	base.insertSeedData(t)
+
+	if err := base.store.CreateService(context.Background(), &model.ServiceConfig{
+		Name:     "testdb",
+		Driver:   "sqlite",
+		DSN:      ":memory:",
+		IsActive: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	env := &namedQueryTestEnv{
+		batchTestEnv: base,
+		queries:      NewNamedQueryHandler(base.registry, base.store),
+	}
+	env.queries.OnChange(func() { env.changes++ })
+
+	r := chi.NewRouter()
+	r.Route("/api/v1/system/query", func(r chi.Router) {
+		r.Get("/{serviceName}", env.queries.ListQueries)
+		r.Post("/{serviceName}", env.queries.CreateQuery)
+		r.Get("/{serviceName}/{queryName}", env.queries.GetQuery)
+		r.Put("/{serviceName}/{queryName}", env.queries.UpdateQuery)
+		r.Delete("/{serviceName}/{queryName}", env.queries.DeleteQuery)
+	})
+	r.Get("/api/v1/{serviceName}/_query/{queryName}", env.queries.RunQuery)
+	r.Post("/api/v1/{serviceName}/_query/{queryName}", env.queries.RunQuery)
+	env.router = r
+	return env
+}
+
+func (e *namedQueryTestEnv) create(t *testing.T, nq map[string]interface{}) {
+	t.Helper()
+	rr := e.do(t, "POST", "/api/v1/system/query/testdb", nq)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create query: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func decodeResources(t *testing.T, rr *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	var resp struct {
+		Resource []map[string]interface{} `json:"resource"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v; body: %s", err, rr.Body.String())
+	}
+	return resp.Resource
+}
+
+func TestNamedQuery_CRUDAndRun(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+
+	env.create(t, map[string]interface{}{
+		"name":        "users_by_name",
+		"description": "Users whose name starts with a prefix",
+		"sql":         "SELECT id, name FROM users WHERE name LIKE :prefix || '%' AND id >= :min_id ORDER BY id",
+		"params": []map[string]interface{}{
+			{"name": "prefix", "type": "string", "required": true},
+			{"name": "min_id", "type": "integer", "default": 1},
+		},
+	})
+	if env.changes != 1 {
+		t.Errorf("expected OnChange to fire once, got %d", env.changes)
+	}
+
+	// Duplicate names conflict.
+	rr := env.do(t, "POST", "/api/v1/system/query/testdb", map[string]interface{}{
+		"name": "users_by_name", "sql": "SELECT 1",
+	})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("duplicate: expected 409, got %d", rr.Code)
+	}
+
+	// GET with query string arguments; the integer default applies.
+	rr = env.do(t, "GET", "/api/v1/testdb/_query/users_by_name?prefix=B", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("run: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	records := decodeResources(t, rr)
+	if len(records) != 1 || records[0]["name"] != "Bob" {
+		t.Errorf("expected [Bob], got %v", records)
+	}
+
+	// POST with a JSON body; string "2" is coerced to the integer param.
+	rr = env.do(t, "POST", "/api/v1/testdb/_query/users_by_name", map[string]interface{}{
+		"prefix": "", "min_id": "2",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("run POST: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if records := decodeResources(t, rr); len(records) != 1 || records[0]["name"] != "Bob" {
+		t.Errorf("expected [Bob], got %v", records)
+	}
+
+	// Argument errors.
+	for _, path := range []string{
+		"/api/v1/testdb/_query/users_by_name",                     // missing required
+		"/api/v1/testdb/_query/users_by_name?prefix=A&min_id=abc", // bad integer
+		"/api/v1/testdb/_query/users_by_name?prefix=A&extra=1",    // undeclared
+	} {
+		if rr := env.do(t, "GET", path, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+
+	// Update, then delete.
+	rr = env.do(t, "PUT", "/api/v1/system/query/testdb/users_by_name", map[string]interface{}{
+		"sql": "SELECT COUNT(*) AS n FROM users",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	rr = env.do(t, "GET", "/api/v1/testdb/_query/users_by_name", nil)
+	if records := decodeResources(t, rr); len(records) != 1 || records[0]["n"] != float64(2) {
+		t.Errorf("after update: expected n=2, got %v", records)
+	}
+
+	rr = env.do(t, "DELETE", "/api/v1/system/query/testdb/users_by_name", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", rr.Code)
+	}
+	if rr := env.do(t, "GET", "/api/v1/testdb/_query/users_by_name", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("after delete: expected 404, got %d", rr.Code)
+	}
+	if env.changes != 3 {
+		t.Errorf("expected 3 change notifications, got %d", env.changes)
+	}
+}
+
+func TestNamedQuery_Validation(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+
+	bad := []map[string]interface{}{
+		{"name": "q", "sql": "SELECT * FROM users WHERE id = :id"},                                                                // undeclared
+		{"name": "q", "sql": "SELECT 1", "params": []map[string]interface{}{{"name": "id", "type": "integer"}}},                   // unused
+		{"name": "q", "sql": "SELECT :id", "params": []map[string]interface{}{{"name": "id", "type": "date"}}},                    // bad type
+		{"name": "bad-name", "sql": "SELECT 1"},                                                                                   // bad name
+		{"name": "q", "sql": "SELECT :id", "params": []map[string]interface{}{{"name": "id", "type": "integer", "default": "x"}}}, // bad default
+	}
+	for i, body := range bad {
+		if rr := env.do(t, "POST", "/api/v1/system/query/testdb", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("case %d: expected 400, got %d; body: %s", i, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := env.do(t, "POST", "/api/v1/system/query/nosuchdb", map[string]interface{}{
+		"name": "q", "sql": "SELECT 1",
+	}); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown service: expected 404, got %d", rr.Code)
+	}
+}
+
+func TestNamedQuery_WriteQuery(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+
+	env.create(t, map[string]interface{}{
+		"name":  "rename_user",
+		"sql":   "UPDATE users SET name = :name WHERE id = :id",
+		"write": true,
+		"params": []map[string]interface{}{
+			{"name": "id", "type": "integer", "required": true},
+			{"name": "name", "type": "string", "required": true},
+		},
+	})
+
+	if rr := env.do(t, "GET", "/api/v1/testdb/_query/rename_user?id=1&name=Al", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET on write query: expected 405, got %d", rr.Code)
+	}
+
+	rr := env.do(t, "POST", "/api/v1/testdb/_query/rename_user", map[string]interface{}{"id": 1, "name": "Al"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if records := decodeResources(t, rr); len(records) != 1 || records[0]["rows_affected"] != float64(1) {
+		t.Errorf("expected rows_affected=1, got %v", records)
+	}
+
+	// Read-only services refuse write queries.
+	svc, _ := env.store.GetServiceByName(context.Background(), "testdb")
+	svc.ReadOnly = true
+	if err := env.store.UpdateService(context.Background(), svc); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	rr = env.do(t, "POST", "/api/v1/testdb/_query/rename_user", map[string]interface{}{"id": 1, "name": "Al"})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("read-only service: expected 403, got %d", rr.Code)
+	}
+}
+
+func TestNamedQuery_Cache(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+
+	env.create(t, map[string]interface{}{
+		"name":      "user_count",
+		"sql":       "SELECT COUNT(*) AS n FROM users",
+		"cache_ttl": 60,
+	})
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_query/user_count", nil)
+	if got := rr.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("first call: X-Cache = %q, want MISS", got)
+	}
+
+	// A new row is not visible while the cached result is fresh.
+	conn, _ := env.registry.Get("testdb")
+	conn.DB().ExecContext(context.Background(), `INSERT INTO users (name, email) VALUES ('Cy', 'cy@example.com')`)
+
+	rr = env.do(t, "GET", "/api/v1/testdb/_query/user_count", nil)
+	if got := rr.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("second call: X-Cache = %q, want HIT", got)
+	}
+	if records := decodeResources(t, rr); records[0]["n"] != float64(2) {
+		t.Errorf("cached result: expected n=2, got %v", records)
+	}
+
+	// Editing the query drops its cached results.
+	env.do(t, "PUT", "/api/v1/system/query/testdb/user_count", map[string]interface{}{
+		"sql":       "SELECT COUNT(*) AS n FROM users",
+		"cache_ttl": 60,
+	})
+	rr = env.do(t, "GET", "/api/v1/testdb/_query/user_count", nil)
+	if got := rr.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("after update: X-Cache = %q, want MISS", got)
+	}
+	if records := decodeResources(t, rr); records[0]["n"] != float64(3) {
+		t.Errorf("after update: expected n=3, got %v", records)
+	}
+}
+
+func TestNamedQuery_RoleAccess(t *testing.T) {
+	env := newNamedQueryTestEnv(t)
+	ctx := context.Background()
+
+	env.create(t, map[string]interface{}{"name": "user_count", "sql": "SELECT COUNT(*) AS n FROM users"})
+	env.create(t, map[string]interface{}{"name": "user_names", "sql": "SELECT name FROM users"})
+
+	role := &model.Role{Name: "reporting", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_query/user_count", VerbMask: model.VerbGet},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+
+	run := func(method, path string, principal *middleware.Principal) int {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, principal))
+		rr := httptest.NewRecorder()
+		env.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	apiKey := &middleware.Principal{Type: "api_key", RoleID: role.ID}
+	admin := &middleware.Principal{Type: "admin", AdminID: 1, IsAdmin: true}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		principal *middleware.Principal
+		want      int
+	}{
+		{"granted component", "GET", "/api/v1/testdb/_query/user_count", apiKey, http.StatusOK},
+		{"other query", "GET", "/api/v1/testdb/_query/user_names", apiKey, http.StatusForbidden},
+		{"verb not granted", "POST", "/api/v1/testdb/_query/user_count", apiKey, http.StatusForbidden},
+		{"admin bypasses rules", "GET", "/api/v1/testdb/_query/user_names", admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.method, tt.path, tt.principal); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	// A wildcard component grants every saved query of the service.
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_query/*", VerbMask: model.VerbGet | model.VerbPost},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	if got := run("POST", "/api/v1/testdb/_query/user_names", apiKey); got != http.StatusOK {
+		t.Errorf("wildcard: expected 200, got %d", got)
+	}
+}
+
+func TestQueryResultCache_Bounds(t *testing.T) {
+	c := newQueryResultCache()
+	rows := []map[string]interface{}{{"n": 1}}
+
+	// A result read before an invalidation is not stored.
+	_, _, gen, _ := c.get("k")
+	c.invalidate("testdb", "q")
+	c.put("k", gen, "testdb", "q", nil, rows, time.Minute)
+	if _, _, _, hit := c.get("k"); hit {
+		t.Error("stale put: expected a miss")
+	}
+
+	_, _, gen, _ = c.get("k")
+	for i := 0; i < queryCacheMaxEntries+10; i++ {
+		c.put(strconv.Itoa(i), gen, "testdb", "q", nil, rows, time.Minute)
+	}
+	if len(c.entries) != queryCacheMaxEntries {
+		t.Errorf("cap: expected %d entries, got %d", queryCacheMaxEntries, len(c.entries))
+	}
+
+	// Expired results are swept out by the next put after the interval.
+	for k, e := range c.entries {
+		e.expires = time.Now().Add(-time.Second)
+		c.entries[k] = e
+	}
+	c.nextSweep = time.Time{}
+	c.put("fresh", gen, "testdb", "q", nil, rows, time.Minute)
+	if len(c.entries) != 1 {
+		t.Errorf("sweep: expected 1 entry, got %d", len(c.entries))
+	}
+}
@@ -0,0 +1,511 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// nestedRelation describes an embedded value of a record being created that
+// names a related table rather than a column.
+type nestedRelation struct {
+	table string
+	// child is true for rows of table that reference the record (embedded
+	// as an array or a single object) and false for a parent row the record
+	// references (embedded as an object).
+	child bool
+	// columns are the foreign key columns on the referencing side and
+	// refColumns the columns they reference, in the same order.
+	columns    []string
+	refColumns []string
+}
+
+// hasNestedValues reports whether any record embeds an object or an array,
+// the only shapes that can carry a nested write.
+func hasNestedValues(records []map[string]interface{}) bool {
+	for _, rec := range records {
+		for _, v := range rec {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupForeignKeys folds per-column foreign key entries into one entry per
+// constraint. Each returned group lists its column pairs in declaration order.
+func groupForeignKeys(fks []model.ForeignKey) [][]model.ForeignKey {
+	var groups [][]model.ForeignKey
+	index := make(map[string]int)
+	for _, fk := range fks {
+		name := fk.Name
+		if name == "" {
+			name = fk.ReferencedTable + "." + fk.ColumnName
+		}
+		if i, ok := index[name]; ok {
+			groups[i] = append(groups[i], fk)
+			continue
+		}
+		index[name] = len(groups)
+		groups = append(groups, []model.ForeignKey{fk})
+	}
+	return groups
+}
+
+func relationFromGroup(table string, child bool, group []model.ForeignKey) nestedRelation {
+	rel := nestedRelation{table: table, child: child}
+	for _, fk := range group {
+		rel.columns = append(rel.columns, fk.ColumnName)
+		rel.refColumns = append(rel.refColumns, fk.ReferencedColumn)
+	}
+	return rel
+}
+
+// nestedRelations resolves the embedded values of record that are not
+// columns of tableName. A key names a parent when it matches a table
+// referenced by one of tableName's foreign keys, or a foreign key column
+// without its "_id" suffix (customer for customer_id). Otherwise a key names
+// a child when it is a table with a foreign key referencing tableName. Keys
+// that match neither are rejected, and only keys naming existing tables are
+// introspected.
+func (h *TableHandler) nestedRelations(ctx context.Context, serviceName, tableName string, record map[string]interface{}) (map[string]nestedRelation, error) {
+	cols := h.tableColumns(ctx, serviceName, tableName)
+	var ts *model.TableSchema
+	var tables map[string]bool
+	rels := make(map[string]nestedRelation)
+
+	for key, v := range record {
+		_, isObject := v.(map[string]interface{})
+		_, isArray := v.([]interface{})
+		if !isObject && !isArray {
+			continue
+		}
+		if cols != nil && cols.Check(key) == nil {
+			continue // a JSON (or array) column value
+		}
+
+		if isObject {
+			if ts == nil {
+				var err error
+				ts, err = h.registry.TableSchema(ctx, serviceName, tableName)
+				if err != nil || ts == nil {
+					return nil, fmt.Errorf("cannot resolve nested field %q: table %q could not be introspected", key, tableName)
+				}
+			}
+			found := false
+			for _, group := range groupForeignKeys(ts.ForeignKeys) {
+				if key == group[0].ReferencedTable || (len(group) == 1 && key == strings.TrimSuffix(group[0].ColumnName, "_id")) {
+					rels[key] = relationFromGroup(group[0].ReferencedTable, false, group)
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+		}
+
+		if tables == nil {
+			var err error
+			if tables, err = h.tableNames(ctx, serviceName); err != nil {
+				return nil, fmt.Errorf("cannot resolve nested field %q: %v", key, err)
+			}
+		}
+		if tables[key] {
+			child, err := h.registry.TableSchema(ctx, serviceName, key)
+			if err == nil && child != nil {
+				for _, group := range groupForeignKeys(child.ForeignKeys) {
+					if group[0].ReferencedTable == tableName {
+						rels[key] = relationFromGroup(key, true, group)
+						break
+					}
+				}
+			}
+		}
+		if _, ok := rels[key]; !ok {
+			return nil, fmt.Errorf("unknown nested field %q: not a column of %s or a table related to it", key, tableName)
+		}
+	}
+	return rels, nil
+}
+
+// tableNames returns the set of tables of a service.
+func (h *TableHandler) tableNames(ctx context.Context, serviceName string) (map[string]bool, error) {
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	names, err := conn.GetTableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]bool, len(names))
+	for _, name := range names {
+		tables[name] = true
+	}
+	return tables, nil
+}
+
+// nestedWrite is one row of a nested create, planned before the transaction
+// opens so that every schema lookup and shape error happens up front.
+type nestedWrite struct {
+	table    string
+	row      map[string]interface{} // column values, without embedded keys
+	parents  []nestedLink
+	children []nestedLink
+}
+
+// size counts the rows of the graph rooted at nw.
+func (nw *nestedWrite) size() int64 {
+	n := int64(1)
+	for _, links := range [][]nestedLink{nw.parents, nw.children} {
+		for _, link := range links {
+			for _, child := range link.writes {
+				n += child.size()
+			}
+		}
+	}
+	return n
+}
+
+// nestedLink ties the rows embedded under key to the row that embeds them.
+type nestedLink struct {
+	key    string
+	rel    nestedRelation
+	writes []*nestedWrite
+	array  bool // embedded as an array rather than a single object
+}
+
+// planGraph resolves the rows embedded in record, recursively, into a tree of
+// writes. Returned errors are client errors.
+func (h *TableHandler) planGraph(ctx context.Context, serviceName, tableName string, record map[string]interface{}) (*nestedWrite, error) {
+	rels, err := h.nestedRelations(ctx, serviceName, tableName, record)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(rels))
+	for key := range rels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nw := &nestedWrite{table: tableName, row: make(map[string]interface{}, len(record))}
+	for k, v := range record {
+		if _, ok := rels[k]; !ok {
+			nw.row[k] = v
+		}
+	}
+
+	for _, key := range keys {
+		rel := rels[key]
+		link := nestedLink{key: key, rel: rel}
+		var items []interface{}
+		switch v := record[key].(type) {
+		case []interface{}:
+			if !rel.child {
+				return nil, fmt.Errorf("nested parent %q must be an object", key)
+			}
+			items, link.array = v, true
+		default:
+			items = []interface{}{v}
+		}
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("nested %q entries must be objects", key)
+			}
+			sub, err := h.planGraph(ctx, serviceName, rel.table, obj)
+			if err != nil {
+				return nil, err
+			}
+			link.writes = append(link.writes, sub)
+		}
+		if rel.child {
+			nw.children = append(nw.children, link)
+		} else {
+			nw.parents = append(nw.parents, link)
+		}
+	}
+	return nw, nil
+}
+
+// applyGraphRules fills the rule-filled columns of every row of a planned
+// write.
+func (h *TableHandler) applyGraphRules(ctx context.Context, serviceName string, nw *nestedWrite) *columnRuleError {
+	if _, err := h.applyColumnRules(ctx, serviceName, nw.table, model.ColumnRuleOnCreate, []map[string]interface{}{nw.row}); err != nil {
+		return err
+	}
+	for _, link := range append(append([]nestedLink{}, nw.parents...), nw.children...) {
+		for _, sub := range link.writes {
+			if err := h.applyGraphRules(ctx, serviceName, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkGraph validates every row of a planned write against its table
+// schema. Foreign key columns filled in from linked rows count as supplied;
+// linked lists those of nw itself. Fields of embedded rows are reported
+// under their path from the top-level record, e.g. "order_items[1].qty".
+func (h *TableHandler) checkGraph(ctx context.Context, serviceName string, nw *nestedWrite, linked []string, prefix string) map[string]string {
+	linked = linked[:len(linked):len(linked)]
+	for _, link := range nw.parents {
+		linked = append(linked, link.rel.columns...)
+	}
+	fields := make(map[string]string)
+	for field, msg := range h.recordValidator(ctx, serviceName, nw.table).checkInsert(nw.row, nil, linked) {
+		fields[prefix+field] = msg
+	}
+
+	for _, link := range append(append([]nestedLink{}, nw.parents...), nw.children...) {
+		var childLinked []string
+		if link.rel.child {
+			childLinked = link.rel.columns
+		}
+		for i, sub := range link.writes {
+			path := prefix + link.key + "."
+			if link.array {
+				path = fmt.Sprintf("%s%s[%d].", prefix, link.key, i)
+			}
+			for field, msg := range h.checkGraph(ctx, serviceName, sub, childLinked, path) {
+				fields[field] = msg
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// execGraph inserts a planned write in dependency order: parents first so
+// their keys can be copied into the row, then the row, then its children with
+// the row's keys copied into them. Keys are copied as the database returned
+// them, before encoding. It returns the created row, encoded, with the
+// created parents and children embedded under their original keys, and the
+// row as stored.
+func (h *TableHandler) execGraph(ctx context.Context, tx connector.QueryExecutor, conn connector.Connector, serviceName string, nw *nestedWrite) (map[string]interface{}, map[string]interface{}, error) {
+	embedded := make(map[string]interface{}, len(nw.parents)+len(nw.children))
+	for _, link := range nw.parents {
+		parent, stored, err := h.execGraph(ctx, tx, conn, serviceName, link.writes[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, col := range link.rel.columns {
+			v, ok := stored[link.rel.refColumns[i]]
+			if !ok {
+				return nil, nil, fmt.Errorf("nested parent %q did not return key column %q", link.key, link.rel.refColumns[i])
+			}
+			nw.row[col] = v
+		}
+		embedded[link.key] = parent
+	}
+
+	stored, err := h.insertRow(ctx, tx, conn, serviceName, nw.table, nw.row)
+	if err != nil {
+		return nil, nil, err
+	}
+	created := make(map[string]interface{}, len(stored)+len(embedded))
+	for k, v := range stored {
+		created[k] = v
+	}
+	h.encoder(ctx, conn, serviceName, nw.table).EncodeRow(created)
+
+	for _, link := range nw.children {
+		out := make([]map[string]interface{}, 0, len(link.writes))
+		for _, child := range link.writes {
+			for i, col := range link.rel.columns {
+				v, ok := stored[link.rel.refColumns[i]]
+				if !ok {
+					return nil, nil, fmt.Errorf("cannot link %q: %s did not return key column %q", link.key, nw.table, link.rel.refColumns[i])
+				}
+				child.row[col] = v
+			}
+			row, _, err := h.execGraph(ctx, tx, conn, serviceName, child)
+			if err != nil {
+				return nil, nil, err
+			}
+			out = append(out, row)
+		}
+		if link.array {
+			embedded[link.key] = out
+		} else {
+			embedded[link.key] = out[0]
+		}
+	}
+
+	for k, v := range embedded {
+		created[k] = v
+	}
+	return created, stored, nil
+}
+
+// insertRow inserts a single row and returns it as stored, unencoded,
+// including generated keys and defaults. Drivers with RETURNING (and SQL Server, whose
+// INSERT carries an OUTPUT clause) report the row directly. Otherwise a
+// generated single-column key is taken from LastInsertId and the row is read
+// back by primary key. Tables without a primary key return the inserted values.
+func (h *TableHandler) insertRow(ctx context.Context, tx connector.QueryExecutor, conn connector.Connector, serviceName, tableName string, row map[string]interface{}) (map[string]interface{}, error) {
+	sqlStr, args, err := conn.BuildInsert(ctx, connector.InsertRequest{
+		Table:   tableName,
+		Records: []map[string]interface{}{row},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.SupportsReturning() || conn.DriverName() == "mssql" {
+		rows, err := tx.QueryxContext(ctx, sqlStr, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("insert into %s returned no row", tableName)
+		}
+		created := make(map[string]interface{})
+		if err := rows.MapScan(created); err != nil {
+			return nil, err
+		}
+		return created, nil
+	}
+
+	result, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make(map[string]interface{}, len(row)+1)
+	for k, v := range row {
+		inserted[k] = v
+	}
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil || ts == nil || len(ts.PrimaryKey) == 0 {
+		return inserted, nil
+	}
+	keyColumns := ts.PrimaryKey
+	if len(keyColumns) == 1 && inserted[keyColumns[0]] == nil {
+		if id, err := result.LastInsertId(); err == nil {
+			inserted[keyColumns[0]] = id
+		}
+	}
+	key, ok := recordKey(inserted, keyColumns)
+	if !ok {
+		return nil, fmt.Errorf("cannot determine the generated key of %s on the %s driver; supply it in the record",
+			tableName, conn.DriverName())
+	}
+
+	selectReq, err := h.compileQuery(ctx, conn, serviceName, tableName, recordQuery{
+		IDs:        []interface{}{key},
+		KeyColumns: keyColumns,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored, err := fetchRecord(ctx, tx, conn, nil, selectReq)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return inserted, nil
+	}
+	return stored, nil
+}
+
+// createNestedRecords handles a create request whose records embed related
+// rows. It reports false, writing nothing, when no embedded value names a
+// related table so the caller proceeds with a plain insert. Otherwise every
+// record graph is inserted in one transaction and the created graphs are
+// returned.
+func (h *TableHandler) createNestedRecords(w http.ResponseWriter, r *http.Request, conn connector.Connector, serviceName, tableName string, records []map[string]interface{}, start time.Time) bool {
+	plans := make([]*nestedWrite, len(records))
+	nested := false
+	for i, rec := range records {
+		nw, err := h.planGraph(r.Context(), serviceName, tableName, rec)
+		if err != nil {
+			writeQueryError(w, err)
+			return true
+		}
+		plans[i] = nw
+		nested = nested || len(nw.parents) > 0 || len(nw.children) > 0
+	}
+	if !nested {
+		return false
+	}
+
+	if queryBool(r, "upsert") || queryBool(r, "ignore_duplicates") {
+		writeError(w, http.StatusBadRequest, "Nested writes cannot be combined with upsert")
+		return true
+	}
+	if parseBatchMode(r) == BatchModeContinue {
+		writeError(w, http.StatusBadRequest, "Nested writes are always atomic and cannot be combined with continue=true")
+		return true
+	}
+
+	for _, nw := range plans {
+		if ruleErr := h.applyGraphRules(r.Context(), serviceName, nw); ruleErr != nil {
+			writeColumnRuleError(w, ruleErr)
+			return true
+		}
+	}
+	for i, nw := range plans {
+		if fields := h.checkGraph(r.Context(), serviceName, nw, nil, ""); fields != nil {
+			writeQueryError(w, newRecordValidationError(i, len(plans), fields))
+			return true
+		}
+	}
+
+	if dryRunRequested(r) {
+		runDryRun(w, r, conn, "Insert failed", func(d *connector.DryRun) (interface{}, *int64, error) {
+			created := make([]map[string]interface{}, 0, len(records))
+			var rows int64
+			for _, nw := range plans {
+				row, _, err := h.execGraph(r.Context(), d, conn, serviceName, nw)
+				if err != nil {
+					return nil, nil, err
+				}
+				created = append(created, row)
+				rows += nw.size()
+			}
+			return created, &rows, nil
+		})
+		return true
+	}
+
+	tx, err := conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to begin transaction: "+err.Error())
+		return true
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	created := make([]map[string]interface{}, 0, len(records))
+	for _, nw := range plans {
+		row, _, err := h.execGraph(r.Context(), tx, conn, serviceName, nw)
+		if err != nil {
+			code, msg := classifyDBError(err, "Insert failed")
+			writeError(w, code, msg)
+			return true
+		}
+		created = append(created, row)
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to commit transaction: "+err.Error())
+		return true
+	}
+	writeCreateResponse(w, conn, created, records, time.Since(start))
+	return true
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// odataNamespace is the CSDL namespace of every generated entity model.
+const odataNamespace = "Faucet"
+
+// ODataHandler serves a read-only OData v4 service per Faucet service, for
+// BI tools such as Power BI and Excel. The entity model is generated from
+// the service's schema snapshot, and query options compile to the same
+// SelectRequests as the REST table routes.
+type ODataHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	tables   *TableHandler
+	schemas  *serviceSchemas
+}
+
+// NewODataHandler creates a new ODataHandler.
+func NewODataHandler(registry *connector.Registry, store *config.Store) *ODataHandler {
+	return &ODataHandler{
+		registry: registry,
+		store:    store,
+		tables:   NewTableHandler(registry, store),
+		schemas:  newServiceSchemas(registry, store),
+	}
+}
+
+// odataModel is the entity model of a service: an entity set per table and
+// view whose name is a valid identifier.
+type odataModel struct {
+	sets   []*odataEntitySet
+	byName map[string]*odataEntitySet
+}
+
+// odataEntitySet is a table or view and its entity type.
+type odataEntitySet struct {
+	def   model.TableSchema
+	key   []string
+	props []model.Column // columns whose names are valid identifiers
+	navs  []*odataNav
+	nav   map[string]*odataNav // by name
+}
+
+// odataNav is a navigation property following a foreign key, to the parent
+// row a row references or to the child rows that reference it.
+type odataNav struct {
+	name       string
+	target     *odataEntitySet
+	collection bool
+	nullable   bool
+	partner    string
+	// columns are the key columns on the source row and targetColumns the
+	// matching columns of target, in the same order.
+	columns       []string
+	targetColumns []string
+}
+
+// buildODataModel generates the entity model of a schema. Keys are primary
+// keys, or for keyless tables and views every non-nullable column.
+// Navigation properties are named like GraphQL relation fields: a parent
+// after its foreign key column without the "_id" suffix (customer for
+// customer_id) or else the referenced table, a child after the referencing
+// table.
+func buildODataModel(schema *model.Schema) *odataModel {
+	m := &odataModel{byName: make(map[string]*odataEntitySet)}
+	for _, def := range append(append([]model.TableSchema{}, schema.Tables...), schema.Views...) {
+		if query.ValidateIdentifier(def.Name) != nil || m.byName[def.Name] != nil {
+			continue
+		}
+		set := &odataEntitySet{def: def, nav: make(map[string]*odataNav)}
+		for _, col := range def.Columns {
+			if query.ValidateIdentifier(col.Name) == nil {
+				set.props = append(set.props, col)
+			}
+		}
+		set.key = def.PrimaryKey
+		if len(set.key) == 0 {
+			for _, col := range set.props {
+				if !col.Nullable {
+					set.key = append(set.key, col.Name)
+				}
+			}
+		}
+		m.sets = append(m.sets, set)
+		m.byName[def.Name] = set
+	}
+
+	for _, set := range m.sets {
+		for _, group := range groupForeignKeys(set.def.ForeignKeys) {
+			parent := m.byName[group[0].ReferencedTable]
+			if parent == nil {
+				continue
+			}
+			rel := relationFromGroup(parent.def.Name, false, group)
+			up := &odataNav{target: parent, nullable: false, columns: rel.columns, targetColumns: rel.refColumns}
+			for _, col := range rel.columns {
+				if set.column(col).Nullable {
+					up.nullable = true
+				}
+			}
+			down := &odataNav{target: set, collection: true, columns: rel.refColumns, targetColumns: rel.columns}
+
+			upName := parent.def.Name
+			if len(rel.columns) == 1 && strings.HasSuffix(rel.columns[0], "_id") {
+				upName = strings.TrimSuffix(rel.columns[0], "_id")
+			}
+			up.name = set.claimNavName(upName, parent.def.Name+"_by_"+strings.Join(rel.columns, "_"))
+			down.name = parent.claimNavName(set.def.Name, set.def.Name+"_by_"+strings.Join(rel.columns, "_"))
+			up.partner, down.partner = down.name, up.name
+			set.addNav(up)
+			parent.addNav(down)
+		}
+	}
+	return m
+}
+
+func (s *odataEntitySet) column(name string) model.Column {
+	for _, col := range s.def.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return model.Column{Name: name}
+}
+
+// claimNavName returns name, or fallback when name is already a property or
+// navigation property, numbered if that is taken too.
+func (s *odataEntitySet) claimNavName(name, fallback string) string {
+	taken := func(n string) bool {
+		if s.nav[n] != nil {
+			return true
+		}
+		for _, col := range s.props {
+			if col.Name == n {
+				return true
+			}
+		}
+		return false
+	}
+	if !taken(name) {
+		return name
+	}
+	name = fallback
+	unique := name
+	for i := 2; taken(unique); i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	return unique
+}
+
+func (s *odataEntitySet) addNav(nav *odataNav) {
+	s.navs = append(s.navs, nav)
+	s.nav[nav.name] = nav
+}
+
+// odataType maps a column to its EDM primitive type.
+func odataType(col model.Column) string {
+	switch col.JsonType {
+	case "integer":
+		if isWideInteger(col) {
+			return "Edm.Int64"
+		}
+		return "Edm.Int32"
+	case "number":
+		if isFloatingPoint(col) {
+			return "Edm.Double"
+		}
+		return "Edm.Decimal"
+	case "boolean":
+		return "Edm.Boolean"
+	case "string(date-time)":
+		return "Edm.DateTimeOffset"
+	case "string(date)":
+		return "Edm.Date"
+	case "string(time)":
+		return "Edm.TimeOfDay"
+	case "string(uuid)":
+		return "Edm.Guid"
+	case "string(byte)":
+		return "Edm.Binary"
+	}
+	// Text, and JSON columns, which are served as their JSON text.
+	return "Edm.String"
+}
+
+// ---------------------------------------------------------------------------
+// Service document and $metadata
+// ---------------------------------------------------------------------------
+
+// model resolves the service of a request and its entity model, writing an
+// OData error and returning ok=false when it cannot.
+func (h *ODataHandler) model(w http.ResponseWriter, r *http.Request) (conn connector.Connector, serviceName string, m *odataModel, ok bool) {
+	serviceName = chi.URLParam(r, "serviceName")
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeODataError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return nil, "", nil, false
+	}
+	snap, err := h.schemas.get(r.Context(), conn, serviceName)
+	if err != nil {
+		writeODataError(w, http.StatusInternalServerError, "Failed to introspect schema: "+err.Error())
+		return nil, "", nil, false
+	}
+	return conn, serviceName, buildODataModel(snap.schema), true
+}
+
+// odataRoot returns the absolute URL of the OData service of a request.
+func odataRoot(r *http.Request, serviceName string) string {
+	return requestOrigin(r) + "/api/v1/" + serviceName + "/odata"
+}
+
+// ServiceDocument lists the entity sets of the service.
+// GET /api/v1/{serviceName}/odata/
+func (h *ODataHandler) ServiceDocument(w http.ResponseWriter, r *http.Request) {
+	_, serviceName, m, ok := h.model(w, r)
+	if !ok {
+		return
+	}
+	sets := make([]map[string]interface{}, len(m.sets))
+	for i, set := range m.sets {
+		sets[i] = map[string]interface{}{"name": set.def.Name, "kind": "EntitySet", "url": set.def.Name}
+	}
+	writeODataJSON(w, http.StatusOK, map[string]interface{}{
+		"@odata.context": odataRoot(r, serviceName) + "/$metadata",
+		"value":          sets,
+	})
+}
+
+// Metadata serves the CSDL document describing the entity model.
+// GET /api/v1/{serviceName}/odata/$metadata
+func (h *ODataHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	_, _, m, ok := h.model(w, r)
+	if !ok {
+		return
+	}
+	doc := csdlDocument(m)
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		writeODataError(w, http.StatusInternalServerError, "Failed to render metadata: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("OData-Version", "4.0")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+type edmxDocument struct {
+	XMLName      xml.Name `xml:"edmx:Edmx"`
+	Version      string   `xml:"Version,attr"`
+	Xmlns        string   `xml:"xmlns:edmx,attr"`
+	DataServices struct {
+		Schema csdlSchema `xml:"Schema"`
+	} `xml:"edmx:DataServices"`
+}
+
+type csdlSchema struct {
+	Xmlns       string           `xml:"xmlns,attr"`
+	Namespace   string           `xml:"Namespace,attr"`
+	EntityTypes []csdlEntityType `xml:"EntityType"`
+	Container   csdlContainer    `xml:"EntityContainer"`
+}
+
+type csdlEntityType struct {
+	Name       string           `xml:"Name,attr"`
+	Key        *csdlKey         `xml:"Key,omitempty"`
+	Properties []csdlProperty   `xml:"Property"`
+	Navigation []csdlNavigation `xml:"NavigationProperty"`
+}
+
+type csdlKey struct {
+	PropertyRefs []csdlPropertyRef `xml:"PropertyRef"`
+}
+
+type csdlPropertyRef struct {
+	Name string `xml:"Name,attr"`
+}
+
+type csdlProperty struct {
+	Name      string `xml:"Name,attr"`
+	Type      string `xml:"Type,attr"`
+	Nullable  string `xml:"Nullable,attr,omitempty"`
+	MaxLength string `xml:"MaxLength,attr,omitempty"`
+	Scale     string `xml:"Scale,attr,omitempty"`
+}
+
+type csdlNavigation struct {
+	Name        string                      `xml:"Name,attr"`
+	Type        string                      `xml:"Type,attr"`
+	Nullable    string                      `xml:"Nullable,attr,omitempty"`
+	Partner     string                      `xml:"Partner,attr,omitempty"`
+	Constraints []csdlReferentialConstraint `xml:"ReferentialConstraint"`
+}
+
+type csdlReferentialConstraint struct {
+	Property           string `xml:"Property,attr"`
+	ReferencedProperty string `xml:"ReferencedProperty,attr"`
+}
+
+type csdlContainer struct {
+	Name       string          `xml:"Name,attr"`
+	EntitySets []csdlEntitySet `xml:"EntitySet"`
+}
+
+type csdlEntitySet struct {
+	Name       string        `xml:"Name,attr"`
+	EntityType string        `xml:"EntityType,attr"`
+	Bindings   []csdlBinding `xml:"NavigationPropertyBinding"`
+}
+
+type csdlBinding struct {
+	Path   string `xml:"Path,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// csdlDocument renders an entity model as a CSDL document.
+func csdlDocument(m *odataModel) edmxDocument {
+	doc := edmxDocument{Version: "4.0", Xmlns: "http://docs.oasis-open.org/odata/ns/edmx"}
+	schema := &doc.DataServices.Schema
+	schema.Xmlns = "http://docs.oasis-open.org/odata/ns/edm"
+	schema.Namespace = odataNamespace
+	schema.Container.Name = "Container"
+
+	for _, set := range m.sets {
+		typ := csdlEntityType{Name: set.def.Name}
+		if len(set.key) > 0 {
+			typ.Key = &csdlKey{}
+			for _, col := range set.key {
+				typ.Key.PropertyRefs = append(typ.Key.PropertyRefs, csdlPropertyRef{Name: col})
+			}
+		}
+		for _, col := range set.props {
+			prop := csdlProperty{Name: col.Name, Type: odataType(col)}
+			if !col.Nullable {
+				prop.Nullable = "false"
+			}
+			if col.MaxLength != nil && *col.MaxLength > 0 && prop.Type == "Edm.String" {
+				prop.MaxLength = strconv.FormatInt(*col.MaxLength, 10)
+			}
+			if prop.Type == "Edm.Decimal" {
+				prop.Scale = "variable"
+			}
+			typ.Properties = append(typ.Properties, prop)
+		}
+
+		entitySet := csdlEntitySet{Name: set.def.Name, EntityType: odataNamespace + "." + set.def.Name}
+		for _, nav := range set.navs {
+			n := csdlNavigation{Name: nav.name, Type: odataNamespace + "." + nav.target.def.Name, Partner: nav.partner}
+			if nav.collection {
+				n.Type = "Collection(" + n.Type + ")"
+			} else {
+				if !nav.nullable {
+					n.Nullable = "false"
+				}
+				for i, col := range nav.columns {
+					n.Constraints = append(n.Constraints, csdlReferentialConstraint{Property: col, ReferencedProperty: nav.targetColumns[i]})
+				}
+			}
+			typ.Navigation = append(typ.Navigation, n)
+			entitySet.Bindings = append(entitySet.Bindings, csdlBinding{Path: nav.name, Target: nav.target.def.Name})
+		}
+		schema.EntityTypes = append(schema.EntityTypes, typ)
+		schema.Container.EntitySets = append(schema.Container.EntitySets, entitySet)
+	}
+	return doc
+}
+
+// writeODataJSON writes an OData JSON response.
+func writeODataJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("OData-Version", "4.0")
+	w.Header().Set("Content-Type", "application/json;odata.metadata=minimal")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeODataError writes an error in the OData JSON error format, whose code
+// is a string. Clients such as Power BI show the message to the user.
+func writeODataError(w http.ResponseWriter, code int, message string) {
+	writeODataJSON(w, code, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    strconv.Itoa(code),
+			"message": message,
+		},
+	})
+}
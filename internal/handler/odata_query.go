@@ -0,0 +1,685 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// odataOptions are the query options of a request or of one $expand item.
+type odataOptions struct {
+	filter  string
+	sel     []string // nil selects every property
+	orderBy string
+	top     int // -1 when absent
+	skip    int
+	count   bool
+	expand  []odataExpand
+}
+
+// odataExpand is one item of $expand: a navigation property and the options
+// applied to the related rows.
+type odataExpand struct {
+	nav  string
+	opts odataOptions
+}
+
+// odataStatusError is a client error with the status code to report.
+type odataStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *odataStatusError) Error() string { return e.msg }
+
+func badOData(format string, args ...interface{}) error {
+	return &odataStatusError{code: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// writeODataQueryError writes the error of a failed read: client errors
+// with their status, anything else as a database error.
+func writeODataQueryError(w http.ResponseWriter, err error) {
+	var status *odataStatusError
+	if errors.As(err, &status) {
+		writeODataError(w, status.code, status.msg)
+		return
+	}
+	code, msg := classifyDBError(err, "Query failed")
+	writeODataError(w, code, msg)
+}
+
+// parseODataOptions reads system query options. Options without a "$"
+// prefix are custom options and ignored. nested restricts the options to
+// those allowed inside $expand.
+func parseODataOptions(values map[string]string, nested bool) (odataOptions, error) {
+	opts := odataOptions{top: -1}
+	for name, value := range values {
+		if !strings.HasPrefix(name, "$") {
+			continue
+		}
+		var err error
+		switch name {
+		case "$filter":
+			opts.filter = value
+		case "$select":
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					opts.sel = append(opts.sel, item)
+				}
+			}
+		case "$orderby":
+			opts.orderBy = value
+		case "$top":
+			opts.top, err = strconv.Atoi(value)
+			if err != nil || opts.top < 0 {
+				return opts, badOData("Invalid $top: %s", value)
+			}
+		case "$skip":
+			opts.skip, err = strconv.Atoi(value)
+			if err != nil || opts.skip < 0 {
+				return opts, badOData("Invalid $skip: %s", value)
+			}
+		case "$count":
+			if nested {
+				return opts, badOData("$count is not supported in $expand")
+			}
+			switch value {
+			case "true":
+				opts.count = true
+			case "false":
+			default:
+				return opts, badOData("Invalid $count: %s", value)
+			}
+		case "$expand":
+			opts.expand, err = parseODataExpand(value)
+			if err != nil {
+				return opts, err
+			}
+		case "$format":
+			if nested || (value != "json" && !strings.HasPrefix(value, "application/json")) {
+				return opts, &odataStatusError{code: http.StatusNotAcceptable, msg: "Only the JSON format is supported"}
+			}
+		default:
+			return opts, &odataStatusError{code: http.StatusNotImplemented, msg: "Query option not supported: " + name}
+		}
+	}
+	return opts, nil
+}
+
+// parseODataExpand parses a $expand value such as
+// "orders($select=id,total;$top=5),customer".
+func parseODataExpand(value string) ([]odataExpand, error) {
+	var items []odataExpand
+	for _, item := range splitOData(value, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ex := odataExpand{nav: item, opts: odataOptions{top: -1}}
+		if i := strings.IndexByte(item, '('); i >= 0 {
+			if !strings.HasSuffix(item, ")") {
+				return nil, badOData("Invalid $expand item: %s", item)
+			}
+			ex.nav = strings.TrimSpace(item[:i])
+			values := make(map[string]string)
+			for _, opt := range splitOData(item[i+1:len(item)-1], ';') {
+				name, v, ok := strings.Cut(strings.TrimSpace(opt), "=")
+				if !ok {
+					return nil, badOData("Invalid $expand option: %s", opt)
+				}
+				values[strings.TrimSpace(name)] = v
+			}
+			var err error
+			if ex.opts, err = parseODataOptions(values, true); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, ex)
+	}
+	return items, nil
+}
+
+// expansions resolves "$expand=*" to every navigation property of set.
+func (set *odataEntitySet) expansions(expands []odataExpand) []odataExpand {
+	if len(expands) != 1 || expands[0].nav != "*" {
+		return expands
+	}
+	all := make([]odataExpand, len(set.navs))
+	for i, nav := range set.navs {
+		all[i] = odataExpand{nav: nav.name, opts: odataOptions{top: -1}}
+	}
+	return all
+}
+
+// splitOData splits s at sep outside of parentheses and quoted strings.
+func splitOData(s string, sep byte) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// queryValues parses the query string of a request. url.ParseQuery is not
+// used because it drops every pair containing ";", which OData uses to
+// separate the options of an $expand item. The first value of a name wins.
+func queryValues(r *http.Request) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(name)
+		if err != nil {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// recordQuery maps options onto a recordQuery for set. The columns of need
+// are selected as well; extra returns those the client did not ask for, to
+// be removed from the rows once they have served their purpose.
+func (set *odataEntitySet) recordQuery(opts odataOptions, need []string) (q recordQuery, extra []string, err error) {
+	if opts.filter != "" {
+		tree, err := query.ParseODataFilter(opts.filter)
+		if err != nil {
+			return q, nil, badOData("Invalid $filter: %s", err.Error())
+		}
+		if tree != nil {
+			q.Where, _ = json.Marshal(tree)
+		}
+	}
+
+	clauses, err := query.ParseODataOrderBy(opts.orderBy)
+	if err != nil {
+		return q, nil, badOData("Invalid $orderby: %s", err.Error())
+	}
+	// Server-driven paging needs a stable order.
+	if len(clauses) == 0 {
+		for _, col := range set.key {
+			clauses = append(clauses, query.OrderClause{Column: col, Direction: "ASC"})
+		}
+	}
+	order := make([]string, len(clauses))
+	for i, c := range clauses {
+		order[i] = c.String()
+	}
+	q.Order = strings.Join(order, ", ")
+
+	for _, expand := range set.expansions(opts.expand) {
+		if nav := set.nav[expand.nav]; nav != nil {
+			need = append(need, nav.columns...)
+		}
+	}
+	if opts.sel != nil {
+		fields := make(map[string]bool)
+		var list []string
+		for _, item := range opts.sel {
+			switch {
+			case item == "*":
+				list, fields = nil, nil
+			case set.nav[item] != nil:
+				// Selecting a navigation property has no effect without $expand.
+			case fields != nil && !fields[item]:
+				fields[item] = true
+				list = append(list, item)
+			}
+			if fields == nil {
+				break
+			}
+		}
+		if fields != nil {
+			for _, col := range need {
+				if !fields[col] {
+					fields[col] = true
+					list = append(list, col)
+					extra = append(extra, col)
+				}
+			}
+			if len(list) == 0 {
+				list = set.key
+			}
+			q.Fields = strings.Join(list, ",")
+		}
+	}
+	return q, extra, nil
+}
+
+// shapeRows prepares rows of set for the response: extra columns are
+// dropped, columns without a property are dropped, JSON columns become
+// their JSON text and binary columns are re-encoded as base64url.
+func (set *odataEntitySet) shapeRows(rows []map[string]interface{}, extra []string) {
+	props := make(map[string]string, len(set.props))
+	for _, col := range set.props {
+		props[col.Name] = col.JsonType
+	}
+	for _, row := range rows {
+		for _, col := range extra {
+			delete(row, col)
+		}
+		for name, v := range row {
+			if _, isNav := set.nav[name]; isNav {
+				continue
+			}
+			jsonType, ok := props[name]
+			if !ok {
+				delete(row, name)
+				continue
+			}
+			switch jsonType {
+			case "object", "array":
+				if raw, ok := v.(json.RawMessage); ok {
+					row[name] = string(raw)
+				} else if v != nil {
+					if b, err := json.Marshal(v); err == nil {
+						row[name] = string(b)
+					}
+				}
+			case "string(byte)":
+				if s, ok := v.(string); ok {
+					if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+						row[name] = base64.RawURLEncoding.EncodeToString(b)
+					}
+				}
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Entity sets
+// ---------------------------------------------------------------------------
+
+// EntitySet serves an entity set, or a single entity addressed by key.
+// GET /api/v1/{serviceName}/odata/{entitySet}
+// GET /api/v1/{serviceName}/odata/{entitySet}({key})
+//
+// Supports $filter, $select, $orderby, $top, $skip, $count and $expand.
+// Collections are paged by the server: a page holds at most the principal's
+// max limit of rows (or fewer with Prefer: odata.maxpagesize=N) and links to
+// the next page with @odata.nextLink.
+func (h *ODataHandler) EntitySet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, serviceName, m, ok := h.model(w, r)
+	if !ok {
+		return
+	}
+
+	segment := chi.URLParam(r, "entitySet")
+	name, keyText, hasKey := strings.Cut(segment, "(")
+	set := m.byName[name]
+	if set == nil {
+		writeODataError(w, http.StatusNotFound, "Entity set not found: "+name)
+		return
+	}
+	opts, err := parseODataOptions(queryValues(r), false)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	if err := h.authorize(ctx, serviceName, set); err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+
+	if hasKey {
+		if !strings.HasSuffix(keyText, ")") {
+			writeODataError(w, http.StatusBadRequest, "Invalid key: "+segment)
+			return
+		}
+		h.entity(w, r, conn, serviceName, set, strings.TrimSuffix(keyText, ")"), opts)
+		return
+	}
+
+	q, extra, err := set.recordQuery(opts, nil)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+
+	pageSize := maxLimit(ctx, h.store)
+	if n, ok := preferredPageSize(r); ok && n < pageSize {
+		pageSize = n
+		w.Header().Set("Preference-Applied", "odata.maxpagesize="+strconv.Itoa(n))
+	}
+	q.Offset = opts.skip
+	q.Limit = pageSize + 1
+	if opts.top >= 0 && opts.top <= pageSize {
+		q.Limit = opts.top
+	}
+
+	selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, set.def.Name, q)
+	if err != nil {
+		writeODataError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{"@odata.context": odataRoot(r, serviceName) + "/$metadata#" + set.def.Name}
+	if opts.count {
+		count, err := countSelect(ctx, conn, selectReq)
+		if err != nil {
+			writeODataQueryError(w, err)
+			return
+		}
+		resp["@odata.count"] = count
+	}
+
+	rows := []map[string]interface{}{}
+	if q.Limit > 0 {
+		rows, err = h.tables.queryRows(ctx, conn.DB(), conn, serviceName, selectReq)
+		if err != nil {
+			writeODataQueryError(w, err)
+			return
+		}
+	}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		top := -1
+		if opts.top >= 0 {
+			top = opts.top - pageSize
+		}
+		resp["@odata.nextLink"] = nextPageLink(r, opts.skip+pageSize, top)
+	}
+	if err := h.expand(ctx, conn, serviceName, set, rows, opts.expand); err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	set.shapeRows(rows, extra)
+	resp["value"] = rows
+	writeODataJSON(w, http.StatusOK, resp)
+}
+
+// entity serves the entity of set with the given key, written as in the URL:
+// a single value for a single-column key, or name=value pairs.
+func (h *ODataHandler) entity(w http.ResponseWriter, r *http.Request, conn connector.Connector, serviceName string, set *odataEntitySet, keyText string, opts odataOptions) {
+	ctx := r.Context()
+	key, err := parseODataKey(set, keyText)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	q, extra, err := set.recordQuery(odataOptions{sel: opts.sel, expand: opts.expand}, nil)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	q.IDs, q.KeyColumns, q.Limit, q.Order = []interface{}{key}, set.key, 1, ""
+	selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, set.def.Name, q)
+	if err != nil {
+		writeODataError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := h.tables.queryRows(ctx, conn.DB(), conn, serviceName, selectReq)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	if len(rows) == 0 {
+		writeODataError(w, http.StatusNotFound, "Entity not found")
+		return
+	}
+	if err := h.expand(ctx, conn, serviceName, set, rows, opts.expand); err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	set.shapeRows(rows, extra)
+
+	resp := rows[0]
+	resp["@odata.context"] = odataRoot(r, serviceName) + "/$metadata#" + set.def.Name + "/$entity"
+	writeODataJSON(w, http.StatusOK, resp)
+}
+
+// Count serves the number of entities matching $filter as plain text.
+// GET /api/v1/{serviceName}/odata/{entitySet}/$count
+func (h *ODataHandler) Count(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, serviceName, m, ok := h.model(w, r)
+	if !ok {
+		return
+	}
+	set := m.byName[chi.URLParam(r, "entitySet")]
+	if set == nil {
+		writeODataError(w, http.StatusNotFound, "Entity set not found: "+chi.URLParam(r, "entitySet"))
+		return
+	}
+	if err := h.authorize(ctx, serviceName, set); err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	q, _, err := set.recordQuery(odataOptions{filter: queryValues(r)["$filter"]}, nil)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, set.def.Name, recordQuery{Where: q.Where})
+	if err != nil {
+		writeODataError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	count, err := countSelect(ctx, conn, selectReq)
+	if err != nil {
+		writeODataQueryError(w, err)
+		return
+	}
+	w.Header().Set("OData-Version", "4.0")
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(strconv.FormatInt(count, 10)))
+}
+
+// authorize checks that the principal may read the table of set, as the
+// GET verb on "_table/{table}" or "_table/*".
+func (h *ODataHandler) authorize(ctx context.Context, serviceName string, set *odataEntitySet) error {
+	allowed, err := authorizeComponent(ctx, h.store, serviceName, []string{"_table/*", "_table/" + set.def.Name}, model.VerbGet)
+	if err != nil {
+		return &odataStatusError{code: http.StatusInternalServerError, msg: "Failed to check access: " + err.Error()}
+	}
+	if !allowed {
+		return &odataStatusError{code: http.StatusForbidden, msg: "Access denied to table: " + set.def.Name}
+	}
+	return nil
+}
+
+// parseODataKey parses the key predicate of an entity URL into the form
+// compileQuery takes: a value for a single-column key, or a tuple in key
+// order. Values are passed on as text and coerced by the column types.
+func parseODataKey(set *odataEntitySet, text string) (interface{}, error) {
+	if len(set.key) == 0 {
+		return nil, badOData("Entity set %s has no key", set.def.Name)
+	}
+	named := make(map[string]string)
+	var positional []string
+	for _, part := range splitOData(text, ',') {
+		part = strings.TrimSpace(part)
+		if name, value, ok := strings.Cut(part, "="); ok && !strings.HasPrefix(part, "'") {
+			named[strings.TrimSpace(name)] = odataKeyValue(strings.TrimSpace(value))
+			continue
+		}
+		positional = append(positional, odataKeyValue(part))
+	}
+
+	values := make([]interface{}, len(set.key))
+	switch {
+	case len(positional) == 1 && len(named) == 0 && len(set.key) == 1:
+		values[0] = positional[0]
+	case len(positional) == 0 && len(named) == len(set.key):
+		for i, col := range set.key {
+			v, ok := named[col]
+			if !ok {
+				return nil, badOData("Key property %s is missing", col)
+			}
+			values[i] = v
+		}
+	default:
+		return nil, badOData("Key must name every key property (%s)", strings.Join(set.key, ", "))
+	}
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// odataKeyValue unquotes a key literal.
+func odataKeyValue(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// preferredPageSize returns the page size asked for with
+// Prefer: odata.maxpagesize=N.
+func preferredPageSize(r *http.Request) (int, bool) {
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(prefer, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			if name == "odata.maxpagesize" || name == "maxpagesize" {
+				if n, err := strconv.Atoi(value); err == nil && n > 0 {
+					return n, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// nextPageLink returns the URL of the request with $skip advanced to skip
+// and $top lowered to top (or absent when top is negative).
+func nextPageLink(r *http.Request, skip, top int) string {
+	values := url.Values{}
+	for name, value := range queryValues(r) {
+		values.Set(name, value)
+	}
+	values.Set("$skip", strconv.Itoa(skip))
+	if top >= 0 {
+		values.Set("$top", strconv.Itoa(top))
+	} else {
+		values.Del("$top")
+	}
+	u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+	return requestOrigin(r) + u.String()
+}
+
+// ---------------------------------------------------------------------------
+// $expand
+// ---------------------------------------------------------------------------
+
+// expand embeds the related rows named by expands into rows. Each
+// navigation property is read with one query per gqlLoadChunk distinct
+// keys; $filter, $select and $orderby apply to that query and $top and
+// $skip to the related rows of each row.
+func (h *ODataHandler) expand(ctx context.Context, conn connector.Connector, serviceName string, set *odataEntitySet, rows []map[string]interface{}, expands []odataExpand) error {
+	for _, ex := range set.expansions(expands) {
+		nav := set.nav[ex.nav]
+		if nav == nil {
+			return badOData("Unknown navigation property %s on %s", ex.nav, set.def.Name)
+		}
+		if err := h.authorize(ctx, serviceName, nav.target); err != nil {
+			return err
+		}
+
+		var keys []interface{}
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			key := rowValues(row, nav.columns)
+			if key == nil || seen[gqlKey(key)] {
+				continue
+			}
+			seen[gqlKey(key)] = true
+			if len(key) == 1 {
+				keys = append(keys, key[0])
+			} else {
+				keys = append(keys, key)
+			}
+		}
+
+		q, extra, err := nav.target.recordQuery(odataOptions{filter: ex.opts.filter, sel: ex.opts.sel, orderBy: ex.opts.orderBy, expand: ex.opts.expand}, nav.targetColumns)
+		if err != nil {
+			return err
+		}
+		related := []map[string]interface{}{}
+		for start := 0; start < len(keys); start += gqlLoadChunk {
+			q.IDs, q.KeyColumns = keys[start:min(start+gqlLoadChunk, len(keys))], nav.targetColumns
+			selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, nav.target.def.Name, q)
+			if err != nil {
+				return badOData("%s", err.Error())
+			}
+			chunk, err := h.tables.queryRows(ctx, conn.DB(), conn, serviceName, selectReq)
+			if err != nil {
+				return err
+			}
+			related = append(related, chunk...)
+		}
+		if err := h.expand(ctx, conn, serviceName, nav.target, related, ex.opts.expand); err != nil {
+			return err
+		}
+
+		grouped := make(map[string][]map[string]interface{})
+		for _, child := range related {
+			k := gqlKey(rowValues(child, nav.targetColumns))
+			grouped[k] = append(grouped[k], child)
+		}
+		nav.target.shapeRows(related, extra)
+
+		for _, row := range rows {
+			var group []map[string]interface{}
+			if key := rowValues(row, nav.columns); key != nil {
+				group = grouped[gqlKey(key)]
+			}
+			if !nav.collection {
+				if len(group) > 0 {
+					row[nav.name] = group[0]
+				} else {
+					row[nav.name] = nil
+				}
+				continue
+			}
+			if ex.opts.skip >= len(group) {
+				group = nil
+			} else {
+				group = group[ex.opts.skip:]
+			}
+			if ex.opts.top >= 0 && len(group) > ex.opts.top {
+				group = group[:ex.opts.top]
+			}
+			if group == nil {
+				group = []map[string]interface{}{}
+			}
+			row[nav.name] = group
+		}
+	}
+	return nil
+}
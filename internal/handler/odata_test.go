@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+func newODataTestEnv(t *testing.T) *batchTestEnv {
+	t.Helper()
+	env := newGraphQLTestEnv(t)
+	oh := NewODataHandler(env.registry, env.store)
+	env.router.Route("/api/v1/{serviceName}/odata", func(r chi.Router) {
+		r.Get("/", oh.ServiceDocument)
+		r.Get("/$metadata", oh.Metadata)
+		r.Get("/{entitySet}", oh.EntitySet)
+		r.Get("/{entitySet}/$count", oh.Count)
+	})
+	return env
+}
+
+// odata issues a GET against the OData service as principal p (nil for
+// none) and returns the recorder.
+func (e *batchTestEnv) odata(t *testing.T, p *middleware.Principal, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/v1/testdb/odata"+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if p != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, p))
+	}
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func decodeOData(t *testing.T, rr *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	return body
+}
+
+func TestODataMetadata(t *testing.T) {
+	env := newODataTestEnv(t)
+
+	rr := env.odata(t, nil, "/$metadata", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`<EntityType Name="orders">`,
+		`<PropertyRef Name="id">`,
+		`<Property Name="user_id" Type="Edm.Int32" Nullable="false">`,
+		`<NavigationProperty Name="user" Type="Faucet.users" Nullable="false" Partner="orders">`,
+		`<ReferentialConstraint Property="user_id" ReferencedProperty="id">`,
+		`<NavigationProperty Name="orders" Type="Collection(Faucet.orders)" Partner="user">`,
+		`<EntitySet Name="orders" EntityType="Faucet.orders">`,
+		`<NavigationPropertyBinding Path="user" Target="users">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metadata is missing %s\n%s", want, body)
+		}
+	}
+
+	doc := decodeOData(t, env.odata(t, nil, "/", nil))
+	if !strings.HasSuffix(doc["@odata.context"].(string), "/api/v1/testdb/odata/$metadata") {
+		t.Errorf("context = %v", doc["@odata.context"])
+	}
+	if n := len(doc["value"].([]interface{})); n != 3 {
+		t.Errorf("service document: got %d sets, want 3", n)
+	}
+}
+
+func TestODataQueryOptions(t *testing.T) {
+	env := newODataTestEnv(t)
+
+	body := decodeOData(t, env.odata(t, nil, "/orders?$filter=status%20eq%20'paid'&$select=id,status&$orderby=id%20desc&$count=true", nil))
+	if body["@odata.count"] != float64(2) {
+		t.Errorf("count = %v, want 2", body["@odata.count"])
+	}
+	rows := body["value"].([]interface{})
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	first := rows[0].(map[string]interface{})
+	if first["id"] != float64(3) || len(first) != 2 {
+		t.Errorf("first row = %v", first)
+	}
+
+	body = decodeOData(t, env.odata(t, nil, "/users?$select=name&$expand=orders($select=status;$orderby=id;$expand=order_items($select=sku))&$top=1", nil))
+	user := body["value"].([]interface{})[0].(map[string]interface{})
+	if _, ok := user["id"]; ok {
+		t.Errorf("key column used for $expand leaked into the row: %v", user)
+	}
+	orders := user["orders"].([]interface{})
+	if len(orders) != 2 {
+		t.Fatalf("expanded orders = %v", orders)
+	}
+	items := orders[0].(map[string]interface{})["order_items"].([]interface{})
+	if len(items) != 2 || items[0].(map[string]interface{})["sku"] != "A-1" {
+		t.Errorf("nested expand = %v", items)
+	}
+	if empty := orders[1].(map[string]interface{})["order_items"].([]interface{}); len(empty) != 0 {
+		t.Errorf("order without items = %v", empty)
+	}
+
+	body = decodeOData(t, env.odata(t, nil, "/orders?$expand=user($select=name)&$orderby=id", nil))
+	order := body["value"].([]interface{})[2].(map[string]interface{})
+	if order["user"].(map[string]interface{})["name"] != "Bob" {
+		t.Errorf("single-valued expand = %v", order["user"])
+	}
+
+	body = decodeOData(t, env.odata(t, nil, "/order_items(1)?$select=sku&$expand=*", nil))
+	if body["order"].(map[string]interface{})["status"] != "paid" {
+		t.Errorf("$expand=* = %v", body)
+	}
+
+	for path, code := range map[string]int{
+		"/orders?$filter=status%20eq":      http.StatusBadRequest,
+		"/orders?$filter=nope%20eq%201":    http.StatusBadRequest,
+		"/orders?$expand=customer":         http.StatusBadRequest,
+		"/orders?$apply=groupby((status))": http.StatusNotImplemented,
+		"/missing":                         http.StatusNotFound,
+	} {
+		if rr := env.odata(t, nil, path, nil); rr.Code != code {
+			t.Errorf("%s: expected %d, got %d; body: %s", path, code, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestODataPaging(t *testing.T) {
+	env := newODataTestEnv(t)
+
+	rr := env.odata(t, nil, "/orders?$top=3", http.Header{"Prefer": {"odata.maxpagesize=2"}})
+	body := decodeOData(t, rr)
+	if got := rr.Header().Get("Preference-Applied"); got != "odata.maxpagesize=2" {
+		t.Errorf("Preference-Applied = %q", got)
+	}
+	if n := len(body["value"].([]interface{})); n != 2 {
+		t.Errorf("page 1: got %d rows, want 2", n)
+	}
+	next, _ := body["@odata.nextLink"].(string)
+	if !strings.Contains(next, "%24skip=2") || !strings.Contains(next, "%24top=1") {
+		t.Fatalf("nextLink = %q", next)
+	}
+
+	nextURL := next[strings.Index(next, "/odata")+len("/odata"):]
+	body = decodeOData(t, env.odata(t, nil, nextURL, http.Header{"Prefer": {"odata.maxpagesize=2"}}))
+	rows := body["value"].([]interface{})
+	if len(rows) != 1 || rows[0].(map[string]interface{})["id"] != float64(3) {
+		t.Errorf("page 2 = %v", rows)
+	}
+	if _, ok := body["@odata.nextLink"]; ok {
+		t.Errorf("last page has a nextLink: %v", body["@odata.nextLink"])
+	}
+}
+
+func TestODataEntityAndCount(t *testing.T) {
+	env := newODataTestEnv(t)
+
+	body := decodeOData(t, env.odata(t, nil, "/users(2)?$select=name", nil))
+	if body["name"] != "Bob" || !strings.HasSuffix(body["@odata.context"].(string), "$metadata#users/$entity") {
+		t.Errorf("entity = %v", body)
+	}
+	if rr := env.odata(t, nil, "/users(id=9)", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("missing entity: expected 404, got %d", rr.Code)
+	}
+
+	rr := env.odata(t, nil, "/orders/$count?$filter=user_id%20eq%201", nil)
+	if rr.Code != http.StatusOK || rr.Body.String() != "2" {
+		t.Errorf("$count: got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestODataAccess(t *testing.T) {
+	env := newODataTestEnv(t)
+	ctx := context.Background()
+	role := &model.Role{Name: "reader", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbGet},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	key := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 1}
+
+	decodeOData(t, env.odata(t, key, "/users", nil))
+	for _, path := range []string{"/orders", "/orders/$count", "/users?$expand=orders"} {
+		if rr := env.odata(t, key, path, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d; body: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/contract"
 	"github.com/faucetdb/faucet/internal/model"
 )
 
@@ -69,6 +70,7 @@ func (h *OpenAPIHandler) ServeCombinedSpec(w http.ResponseWriter, r *http.Reques
 		})
 
 		servicePaths, serviceSchemas := buildServiceSpec(svc.Name, schema)
+		h.addNamedQueryPaths(r, svc.Name, servicePaths)
 		for k, v := range servicePaths {
 			paths[k] = v
 		}
@@ -141,6 +143,7 @@ func (h *OpenAPIHandler) ServeServiceSpec(w http.ResponseWriter, r *http.Request
 	}
 
 	paths, schemas := buildServiceSpec(serviceName, schema)
+	h.addNamedQueryPaths(r, serviceName, paths)
 
 	spec := map[string]interface{}{
 		"openapi": "3.1.0",
@@ -196,6 +199,12 @@ func buildServiceSpec(serviceName string, schema *model.Schema) (map[string]inte
 		schemas[schemaRef+"_list"] = buildListSchema(schemaRef)
 
 		paths[tableListPath] = buildTablePaths(table, schemaRef, serviceName)
+		paths[tableListPath+"/_query"] = buildStructuredQueryPath(table.Name, schemaRef, serviceName)
+		paths[tableListPath+"/_import"] = buildImportPath(table.Name, serviceName)
+		paths[tableListPath+"/_changes"] = buildChangesPath(table.Name, serviceName)
+		if keyColumns := recordKeyColumns(table); keyColumns != nil {
+			paths[tableListPath+"/{id}"] = buildRecordPath(table.Name, keyColumns, schemaRef, serviceName)
+		}
 	}
 
 	// Generate paths for views (read-only).
@@ -226,6 +235,13 @@ func buildServiceSpec(serviceName string, schema *model.Schema) (map[string]inte
 				},
 			},
 		}
+		paths[viewPath+"/_query"] = buildStructuredQueryPath(view.Name, schemaRef, serviceName)
+	}
+
+	// Shared schemas for the structured _query endpoints.
+	if len(schema.Tables) > 0 || len(schema.Views) > 0 {
+		schemas["QueryRequest"] = buildQueryRequestSchema()
+		schemas["QueryFilter"] = buildQueryFilterSchema()
 	}
 
 	// Generate paths for stored procedures.
@@ -238,6 +254,18 @@ func buildServiceSpec(serviceName string, schema *model.Schema) (map[string]inte
 		paths[fnPath] = buildProcPath(fn, serviceName)
 	}
 
+	// Multi-table transactional batch and background exports.
+	if len(schema.Tables) > 0 {
+		paths[basePath+"/_batch"] = buildBatchPath(serviceName)
+		paths[basePath+"/_export"] = buildExportPath(serviceName)
+		paths[basePath+"/_export/{exportId}"] = buildExportJobPath(serviceName)
+	}
+	if len(schema.Tables) > 0 || len(schema.Views) > 0 {
+		paths[basePath+"/graphql"] = buildGraphQLPath(serviceName)
+		paths[basePath+"/odata/$metadata"] = buildODataMetadataPath(serviceName)
+		paths[basePath+"/odata/{entitySet}"] = buildODataEntitySetPath(serviceName)
+	}
+
 	return paths, schemas
 }
 
@@ -295,6 +323,10 @@ func buildListSchema(recordSchemaRef string) map[string]interface{} {
 					"total":  map[string]interface{}{"type": "integer"},
 					"limit":  map[string]interface{}{"type": "integer"},
 					"offset": map[string]interface{}{"type": "integer"},
+					"next_cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Cursor for the next page (structured _query endpoint only)",
+					},
 					"took_ms": map[string]interface{}{
 						"type":   "number",
 						"format": "double",
@@ -331,6 +363,7 @@ func buildTablePaths(table model.TableSchema, schemaRef, serviceName string) map
 			"summary":     fmt.Sprintf("Create %s records", table.Name),
 			"operationId": fmt.Sprintf("create_%s_%s", serviceName, table.Name),
 			"tags":        []string{serviceName},
+			"parameters":  buildUpsertParameters(),
 			"requestBody": map[string]interface{}{
 				"required": true,
 				"content": map[string]interface{}{
@@ -452,13 +485,681 @@ func buildTablePaths(table model.TableSchema, schemaRef, serviceName string) map
 	}
 }
 
+// recordKeyColumns returns the columns addressed by a table's /{id} path: the
+// primary key, or the conventional "id" column when none is declared. It
+// returns nil when the table has neither.
+func recordKeyColumns(table model.TableSchema) []string {
+	if len(table.PrimaryKey) > 0 {
+		return table.PrimaryKey
+	}
+	for _, col := range table.Columns {
+		if col.Name == "id" {
+			return []string{"id"}
+		}
+	}
+	return nil
+}
+
+// buildRecordPath generates the single-record path item (GET/PUT/PATCH/DELETE
+// on /_table/{name}/{id}) for a table with the given key columns.
+func buildRecordPath(tableName string, keyColumns []string, schemaRef, serviceName string) map[string]interface{} {
+	ref := "#/components/schemas/" + schemaRef
+	idDesc := fmt.Sprintf("Primary key value (%s)", keyColumns[0])
+	if len(keyColumns) > 1 {
+		idDesc = fmt.Sprintf("Composite primary key: comma-separated values for %s, in that order (escape commas inside a value as %%2C)",
+			strings.Join(keyColumns, ", "))
+	}
+
+	recordResponses := func(desc string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": desc,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schema},
+				},
+			},
+			"404": map[string]interface{}{"description": "Record not found"},
+		}
+	}
+	conditional := func(header, desc string) []map[string]interface{} {
+		return []map[string]interface{}{{
+			"name":        header,
+			"in":          "header",
+			"description": desc,
+			"schema":      map[string]interface{}{"type": "string"},
+		}}
+	}
+	ifMatch := conditional("If-Match", "Only write while the record's ETag matches")
+	withPrecondition := func(responses map[string]interface{}) map[string]interface{} {
+		responses["412"] = map[string]interface{}{"description": "The record's ETag does not match If-Match"}
+		return responses
+	}
+	getResponses := recordResponses("Success", map[string]interface{}{"$ref": ref})
+	getResponses["304"] = map[string]interface{}{"description": "Not modified"}
+	requestBody := map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": ref},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"parameters": []map[string]interface{}{
+			{
+				"name":        "id",
+				"in":          "path",
+				"required":    true,
+				"description": idDesc,
+				"schema":      map[string]interface{}{"type": "string"},
+			},
+		},
+		"get": map[string]interface{}{
+			"summary":     fmt.Sprintf("Get a %s record by primary key", tableName),
+			"operationId": fmt.Sprintf("get_%s_%s_by_id", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters": []map[string]interface{}{
+				{
+					"name":        "fields",
+					"in":          "query",
+					"description": "Comma-separated list of fields to return",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+				conditional("If-None-Match", "Return 304 while the record's ETag matches")[0],
+			},
+			"responses": getResponses,
+		},
+		"put": map[string]interface{}{
+			"summary":     fmt.Sprintf("Replace a %s record by primary key", tableName),
+			"operationId": fmt.Sprintf("replace_%s_%s_by_id", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters":  ifMatch,
+			"requestBody": requestBody,
+			"responses":   withPrecondition(recordResponses("Updated", map[string]interface{}{"$ref": ref})),
+		},
+		"patch": map[string]interface{}{
+			"summary":     fmt.Sprintf("Update a %s record by primary key", tableName),
+			"operationId": fmt.Sprintf("update_%s_%s_by_id", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters":  ifMatch,
+			"requestBody": requestBody,
+			"responses":   withPrecondition(recordResponses("Updated", map[string]interface{}{"$ref": ref})),
+		},
+		"delete": map[string]interface{}{
+			"summary":     fmt.Sprintf("Delete a %s record by primary key", tableName),
+			"operationId": fmt.Sprintf("delete_%s_%s_by_id", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters":  ifMatch,
+			"responses":   withPrecondition(recordResponses("Deleted; returns the key of the deleted record", map[string]interface{}{"type": "object"})),
+		},
+	}
+}
+
+// buildStructuredQueryPath generates the POST path item for a table's
+// structured JSON query endpoint (_table/{name}/_query).
+func buildStructuredQueryPath(tableName, schemaRef, serviceName string) map[string]interface{} {
+	return map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     fmt.Sprintf("Query %s records with a JSON query", tableName),
+			"operationId": fmt.Sprintf("query_%s_%s", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/QueryRequest"},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Success",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schemaRef + "_list"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildImportPath generates the POST path item for a table's streaming bulk
+// import endpoint (_table/{name}/_import).
+func buildImportPath(tableName, serviceName string) map[string]interface{} {
+	importResponse := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"meta": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"rows":     map[string]interface{}{"type": "integer"},
+					"inserted": map[string]interface{}{"type": "integer"},
+					"failed":   map[string]interface{}{"type": "integer"},
+					"took_ms":  map[string]interface{}{"type": "number"},
+				},
+			},
+			"errors": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"row":     map[string]interface{}{"type": "integer"},
+						"message": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+	textBody := map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	return map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     fmt.Sprintf("Bulk import %s records from CSV or NDJSON", tableName),
+			"operationId": fmt.Sprintf("import_%s_%s", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters": []map[string]interface{}{
+				{"name": "map", "in": "query", "description": "Rename input fields to columns as src:col pairs; 'src:' drops a field", "schema": map[string]interface{}{"type": "string"}},
+				{"name": "header", "in": "query", "description": "Whether the CSV has a header row (default true)", "schema": map[string]interface{}{"type": "boolean"}},
+				{"name": "columns", "in": "query", "description": "Comma-separated CSV field names in order", "schema": map[string]interface{}{"type": "string"}},
+				{"name": "delimiter", "in": "query", "description": "CSV field delimiter ('tab' for tabs)", "schema": map[string]interface{}{"type": "string"}},
+				{"name": "null", "in": "query", "description": "CSV field text that loads as NULL (default: empty field)", "schema": map[string]interface{}{"type": "string"}},
+				{"name": "batch_size", "in": "query", "description": "Rows written per chunk (default 1000, max 10000)", "schema": map[string]interface{}{"type": "integer"}},
+				{"name": "rollback", "in": "query", "description": "Load all rows in one transaction, or none", "schema": map[string]interface{}{"type": "boolean"}},
+				{"name": "continue", "in": "query", "description": "Skip rows that fail and report them in errors", "schema": map[string]interface{}{"type": "boolean"}},
+			},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"text/csv":                  textBody,
+					"text/tab-separated-values": textBody,
+					"application/x-ndjson":      textBody,
+				},
+			},
+			"responses": map[string]interface{}{
+				"201": map[string]interface{}{
+					"description": "All rows imported",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": importResponse}},
+				},
+				"200": map[string]interface{}{
+					"description": "Import finished with rejected rows (continue=true)",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": importResponse}},
+				},
+				"415": map[string]interface{}{"description": "Unsupported content type"},
+			},
+		},
+	}
+}
+
+// buildChangesPath generates the path item that streams a table's changes
+// (_table/{name}/_changes).
+func buildChangesPath(tableName, serviceName string) map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	return map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     fmt.Sprintf("Stream %s changes", tableName),
+			"description": "Streams inserts, updates and deletes as server-sent events, or over a WebSocket when the request asks to upgrade. Each event's ID is its offset; reconnect with Last-Event-ID or after to resume. Needs the service's change_streams setting.",
+			"operationId": fmt.Sprintf("changes_%s_%s", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"parameters": []map[string]interface{}{
+				{"name": "filter", "in": "query", "description": "Only changes whose row matches this filter", "schema": str},
+				{"name": "after", "in": "query", "description": "Resume after this offset", "schema": str},
+				{"name": "Last-Event-ID", "in": "header", "description": "Resume after this offset (sent by EventSource on reconnect)", "schema": str},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Event stream; each event is named by its op (insert, update or delete)",
+					"content":     map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": str}},
+				},
+				"400": map[string]interface{}{"description": "Invalid filter or offset"},
+				"403": map[string]interface{}{"description": "Access denied, or change streams are not enabled"},
+				"409": map[string]interface{}{"description": "Change capture must be enabled in the database"},
+				"501": map[string]interface{}{"description": "Change streams are not supported for this database"},
+			},
+		},
+		"delete": map[string]interface{}{
+			"summary":     fmt.Sprintf("Stop capturing %s changes", tableName),
+			"description": "Removes the change capture installed on the table and ends its streams. Admin only.",
+			"operationId": fmt.Sprintf("disableChanges_%s_%s", serviceName, tableName),
+			"tags":        []string{serviceName},
+			"responses": map[string]interface{}{
+				"204": map[string]interface{}{"description": "Change capture removed"},
+			},
+		},
+	}
+}
+
+// exportJobSchema is the schema of a background export job.
+func exportJobSchema() map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	integer := map[string]interface{}{"type": "integer"}
+	dateTime := map[string]interface{}{"type": "string", "format": "date-time"}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":           str,
+			"service_name": str,
+			"table":        str,
+			"query":        str,
+			"args":         map[string]interface{}{"type": "object"},
+			"filter":       str,
+			"fields":       str,
+			"order":        str,
+			"format":       map[string]interface{}{"type": "string", "enum": []string{"csv", "ndjson", "parquet"}},
+			"gzip":         map[string]interface{}{"type": "boolean"},
+			"status":       map[string]interface{}{"type": "string", "enum": []string{"pending", "running", "completed", "failed", "cancelled"}},
+			"rows":         map[string]interface{}{"type": "integer", "description": "Rows written so far"},
+			"bytes":        integer,
+			"error":        str,
+			"download_url": map[string]interface{}{"type": "string", "description": "Set once the export has completed"},
+			"created_at":   dateTime,
+			"started_at":   dateTime,
+			"finished_at":  dateTime,
+			"expires_at":   dateTime,
+		},
+	}
+}
+
+// buildGraphQLPath generates the path item of the service's GraphQL
+// endpoint.
+func buildGraphQLPath(serviceName string) map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	result := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "GraphQL result; field errors carry the equivalent REST status in extensions.status",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"data":   map[string]interface{}{"type": "object"},
+							"errors": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+						},
+					},
+				},
+			},
+		},
+		"400": map[string]interface{}{"description": "No query provided"},
+	}
+	return map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Run a GraphQL query",
+			"operationId": fmt.Sprintf("graphqlQuery_%s", serviceName),
+			"tags":        []string{serviceName},
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "query", "in": "query", "required": true, "schema": str},
+				map[string]interface{}{"name": "variables", "in": "query", "description": "JSON-encoded variables", "schema": str},
+				map[string]interface{}{"name": "operationName", "in": "query", "schema": str},
+			},
+			"responses": result,
+		},
+		"post": map[string]interface{}{
+			"summary":     "Run a GraphQL query or mutation",
+			"description": "Query and mutate the service's tables through a GraphQL schema generated from the database schema. Access rules, column rules and validation apply as they do to the REST routes.",
+			"operationId": fmt.Sprintf("graphql_%s", serviceName),
+			"tags":        []string{serviceName},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"query"},
+							"properties": map[string]interface{}{
+								"query":         str,
+								"variables":     map[string]interface{}{"type": "object"},
+								"operationName": str,
+							},
+						},
+					},
+				},
+			},
+			"responses": result,
+		},
+	}
+}
+
+// buildODataMetadataPath generates the path item of the service's OData
+// CSDL document.
+func buildODataMetadataPath(serviceName string) map[string]interface{} {
+	return map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Get the OData metadata document",
+			"operationId": fmt.Sprintf("odataMetadata_%s", serviceName),
+			"tags":        []string{serviceName},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "CSDL XML describing an entity type per table and view",
+					"content": map[string]interface{}{
+						"application/xml": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildODataEntitySetPath generates the path item that reads an OData
+// entity set.
+func buildODataEntitySetPath(serviceName string) map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	integer := map[string]interface{}{"type": "integer", "minimum": 0}
+	param := func(name, desc string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "in": "query", "description": desc, "schema": schema}
+	}
+	return map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Read an OData entity set",
+			"description": "Reads a table or view as an OData v4 entity set. Pages hold at most the caller's max limit of rows; @odata.nextLink points to the next page.",
+			"operationId": fmt.Sprintf("odataEntitySet_%s", serviceName),
+			"tags":        []string{serviceName},
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "entitySet", "in": "path", "required": true, "schema": str},
+				param("$filter", "OData filter expression", str),
+				param("$select", "Comma-separated properties", str),
+				param("$orderby", "Comma-separated properties with optional asc or desc", str),
+				param("$top", "Maximum number of entities", integer),
+				param("$skip", "Number of entities to skip", integer),
+				param("$count", "Include @odata.count", map[string]interface{}{"type": "boolean"}),
+				param("$expand", "Navigation properties to embed", str),
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Entity collection",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"@odata.context":  str,
+									"@odata.count":    map[string]interface{}{"type": "integer"},
+									"@odata.nextLink": str,
+									"value":           map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+								},
+							},
+						},
+					},
+				},
+				"400": map[string]interface{}{"description": "Invalid query option"},
+				"403": map[string]interface{}{"description": "Access denied"},
+				"404": map[string]interface{}{"description": "Entity set not found"},
+			},
+		},
+	}
+}
+
+// buildExportPath generates the path item that creates and lists a
+// service's background export jobs (_export).
+func buildExportPath(serviceName string) map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	strList := map[string]interface{}{"type": "array", "items": str}
+	job := exportJobSchema()
+	return map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     "Start a background export of a table or saved query",
+			"description": "Queue an export job that writes every matching row to a CSV, NDJSON or Parquet file. Poll the job for progress and download the file once it has completed.",
+			"operationId": fmt.Sprintf("createExport_%s", serviceName),
+			"tags":        []string{serviceName},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"table":  map[string]interface{}{"type": "string", "description": "Table to export; set table or query"},
+								"query":  map[string]interface{}{"type": "string", "description": "Saved query to export"},
+								"args":   map[string]interface{}{"type": "object", "description": "Saved query arguments"},
+								"filter": map[string]interface{}{"type": "string", "description": "Filter expression (tables only)"},
+								"fields": strList,
+								"order":  strList,
+								"format": map[string]interface{}{"type": "string", "enum": []string{"csv", "ndjson", "parquet"}, "default": "csv"},
+								"gzip":   map[string]interface{}{"type": "boolean"},
+							},
+						},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"202": map[string]interface{}{
+					"description": "Export queued",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": job}},
+				},
+				"400": map[string]interface{}{"description": "Invalid export request"},
+				"403": map[string]interface{}{"description": "No read access to the table or query"},
+			},
+		},
+		"get": map[string]interface{}{
+			"summary":     "List background exports",
+			"operationId": fmt.Sprintf("listExports_%s", serviceName),
+			"tags":        []string{serviceName},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Export jobs, newest first",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"resource": map[string]interface{}{"type": "array", "items": job},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildExportJobPath generates the path item of a single background export
+// job (_export/{exportId}). Its file is downloaded from download_url.
+func buildExportJobPath(serviceName string) map[string]interface{} {
+	idParam := []map[string]interface{}{
+		{"name": "exportId", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+	}
+	job := exportJobSchema()
+	return map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Get the status of a background export",
+			"operationId": fmt.Sprintf("getExport_%s", serviceName),
+			"tags":        []string{serviceName},
+			"parameters":  idParam,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Export job",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": job}},
+				},
+				"404": map[string]interface{}{"description": "Export not found"},
+			},
+		},
+		"delete": map[string]interface{}{
+			"summary":     "Cancel a running export, or delete a finished one and its file",
+			"operationId": fmt.Sprintf("deleteExport_%s", serviceName),
+			"tags":        []string{serviceName},
+			"parameters":  idParam,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "Export cancelled or deleted"},
+				"404": map[string]interface{}{"description": "Export not found"},
+			},
+		},
+	}
+}
+
+// buildBatchPath generates the POST path item for a service's multi-table
+// transactional batch endpoint (_batch).
+func buildBatchPath(serviceName string) map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	object := map[string]interface{}{"type": "object"}
+	return map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     "Run a multi-table transactional batch",
+			"description": "Run an ordered list of operations in one transaction. A string value \"$ops[N].column\" (or \"$ops[N][M].column\") in records, ids or params refers to a column of an earlier operation's result.",
+			"operationId": fmt.Sprintf("batch_%s", serviceName),
+			"tags":        []string{serviceName},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"operations"},
+							"properties": map[string]interface{}{
+								"operations": map[string]interface{}{
+									"type": "array",
+									"items": map[string]interface{}{
+										"type":     "object",
+										"required": []string{"op"},
+										"properties": map[string]interface{}{
+											"op":                map[string]interface{}{"type": "string", "enum": []string{"insert", "upsert", "update", "delete", "procedure"}},
+											"table":             str,
+											"records":           map[string]interface{}{"type": "array", "items": object},
+											"record":            object,
+											"ids":               map[string]interface{}{"type": "array", "items": map[string]interface{}{}},
+											"filter":            str,
+											"on_conflict":       map[string]interface{}{"type": "array", "items": str},
+											"update_columns":    map[string]interface{}{"type": "array", "items": str},
+											"ignore_duplicates": map[string]interface{}{"type": "boolean"},
+											"procedure":         str,
+											"params":            object,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Every operation applied; resource holds per-operation results in request order",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"resource": map[string]interface{}{
+										"type": "array",
+										"items": map[string]interface{}{
+											"type": "object",
+											"properties": map[string]interface{}{
+												"op":        str,
+												"table":     str,
+												"procedure": str,
+												"count":     map[string]interface{}{"type": "integer"},
+												"resource":  map[string]interface{}{"type": "array", "items": object},
+											},
+										},
+									},
+									"meta": object,
+								},
+							},
+						},
+					},
+				},
+				"400": map[string]interface{}{"description": "Invalid operation, or an operation failed; error.context.operation is its index"},
+				"403": map[string]interface{}{"description": "An operation is not permitted"},
+			},
+		},
+	}
+}
+
+// buildQueryRequestSchema describes the body accepted by _query endpoints.
+func buildQueryRequestSchema() map[string]interface{} {
+	stringList := func(desc string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": desc,
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"where":    map[string]interface{}{"$ref": "#/components/schemas/QueryFilter"},
+			"fields":   stringList("Columns or aggregate expressions, e.g. [\"status\", \"COUNT(*) AS n\"]"),
+			"order":    stringList("Sort terms, e.g. [\"name ASC\", \"id DESC\"]"),
+			"group":    stringList("GROUP BY columns"),
+			"having":   map[string]interface{}{"type": "string", "description": "HAVING filter over aggregate aliases or grouped columns"},
+			"distinct": map[string]interface{}{"type": "boolean"},
+			"ids": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{},
+				"description": "Primary key values to fetch",
+			},
+			"limit":         map[string]interface{}{"type": "integer", "default": 25, "description": "Capped at 1000, or at the role's max_limit"},
+			"offset":        map[string]interface{}{"type": "integer"},
+			"cursor":        map[string]interface{}{"type": "string", "description": "meta.next_cursor from a previous page; overrides offset"},
+			"include_count": map[string]interface{}{"type": "boolean"},
+			"include_etags": map[string]interface{}{"type": "boolean"},
+		},
+	}
+}
+
+// buildQueryFilterSchema describes the structured filter tree used by the
+// "where" property of QueryRequest (see query.ParseFilterJSON).
+func buildQueryFilterSchema() map[string]interface{} {
+	filterRef := map[string]interface{}{"$ref": "#/components/schemas/QueryFilter"}
+	return map[string]interface{}{
+		"type": "object",
+		"description": "Structured filter. Keys are column names or the logical operators and/or/not. " +
+			"A column maps to a value (equality; null means IS NULL) or to an object of operators: " +
+			"eq, ne, gt, gte, lt, lte, in, not_in, like, not_like, ilike, not_ilike, between, not_between, " +
+			"contains, starts_with, ends_with, is_null. Multiple keys are ANDed.",
+		"properties": map[string]interface{}{
+			"and": map[string]interface{}{"type": "array", "items": filterRef},
+			"or":  map[string]interface{}{"type": "array", "items": filterRef},
+			"not": filterRef,
+		},
+		"additionalProperties": true,
+		"example": map[string]interface{}{
+			"and": []interface{}{
+				map[string]interface{}{"age": map[string]interface{}{"gt": 21}},
+				map[string]interface{}{"status": map[string]interface{}{"in": []string{"active", "trial"}}},
+			},
+		},
+	}
+}
+
+// buildUpsertParameters returns the upsert query parameters for table POST endpoints.
+func buildUpsertParameters() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"name":        "upsert",
+			"in":          "query",
+			"description": "Update rows that conflict with an existing row instead of failing",
+			"schema":      map[string]interface{}{"type": "boolean"},
+		},
+		{
+			"name":        "on_conflict",
+			"in":          "query",
+			"description": "Comma-separated columns identifying an existing row (default: primary key)",
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+		{
+			"name":        "update_columns",
+			"in":          "query",
+			"description": "Comma-separated columns to overwrite on conflict (default: all but on_conflict)",
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+		{
+			"name":        "ignore_duplicates",
+			"in":          "query",
+			"description": "Leave conflicting rows untouched; implies upsert",
+			"schema":      map[string]interface{}{"type": "boolean"},
+		},
+	}
+}
+
 // buildQueryParameters returns common query parameters for table GET endpoints.
 func buildQueryParameters() []map[string]interface{} {
 	return []map[string]interface{}{
 		{
 			"name":        "fields",
 			"in":          "query",
-			"description": "Comma-separated list of fields to return; each may be a plain column or an aggregate such as SUM(amount), COUNT(*), or AVG(price) AS avg_price",
+			"description": "Comma-separated list of fields to return; each may be a plain column or an aggregate such as SUM(amount), COUNT(*), COUNT(DISTINCT customer_id), or AVG(price) AS avg_price",
 			"schema":      map[string]interface{}{"type": "string"},
 		},
 		{
@@ -467,6 +1168,18 @@ func buildQueryParameters() []map[string]interface{} {
 			"description": "Comma-separated columns to group by (e.g. 'region,status'); combine with aggregates in 'fields'",
 			"schema":      map[string]interface{}{"type": "string"},
 		},
+		{
+			"name":        "having",
+			"in":          "query",
+			"description": "Filter expression applied to groups; may reference group columns and aggregate aliases (e.g. 'total > 1000')",
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+		{
+			"name":        "distinct",
+			"in":          "query",
+			"description": "Return only distinct rows",
+			"schema":      map[string]interface{}{"type": "boolean"},
+		},
 		{
 			"name":        "filter",
 			"in":          "query",
@@ -482,11 +1195,10 @@ func buildQueryParameters() []map[string]interface{} {
 		{
 			"name":        "limit",
 			"in":          "query",
-			"description": "Maximum records to return (default 25, max 1000)",
+			"description": "Maximum records to return (default 25, max 1000 or the role's max_limit)",
 			"schema": map[string]interface{}{
 				"type":    "integer",
 				"default": 25,
-				"maximum": 1000,
 			},
 		},
 		{
@@ -504,6 +1216,21 @@ func buildQueryParameters() []map[string]interface{} {
 			"description": "Include total record count in response meta",
 			"schema":      map[string]interface{}{"type": "boolean"},
 		},
+		{
+			"name":        "include_etags",
+			"in":          "query",
+			"description": "Include each record's ETag as its _etag member",
+			"schema":      map[string]interface{}{"type": "boolean"},
+		},
+		{
+			"name":        "format",
+			"in":          "query",
+			"description": "Response format; overrides the Accept header",
+			"schema": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"json", "ndjson", "csv", "tsv", "arrow", "parquet"},
+			},
+		},
 	}
 }
 
@@ -547,6 +1274,104 @@ func buildProcPath(proc model.StoredProcedure, serviceName string) map[string]in
 	}
 }
 
+// addNamedQueryPaths adds a _query/{name} path for each saved query of the
+// service. Failures to load queries leave the spec without them.
+func (h *OpenAPIHandler) addNamedQueryPaths(r *http.Request, serviceName string, paths map[string]interface{}) {
+	queries, err := h.store.ListNamedQueries(r.Context(), serviceName)
+	if err != nil {
+		return
+	}
+	for _, nq := range queries {
+		paths[fmt.Sprintf("/api/v1/%s/_query/%s", serviceName, nq.Name)] = buildNamedQueryPath(nq, serviceName)
+	}
+}
+
+// buildNamedQueryPath generates a path item for a saved query. Read queries
+// accept GET with query string arguments as well as POST; write queries are
+// POST only.
+func buildNamedQueryPath(nq model.NamedQuery, serviceName string) map[string]interface{} {
+	summary := nq.Description
+	if summary == "" {
+		summary = fmt.Sprintf("Run saved query %s", nq.Name)
+	}
+
+	properties := make(map[string]interface{}, len(nq.Params))
+	required := make([]string, 0)
+	parameters := make([]map[string]interface{}, 0, len(nq.Params))
+	for _, p := range nq.Params {
+		paramSchema := map[string]interface{}{"type": jsonSchemaType(p.Type)}
+		if p.Default != nil {
+			paramSchema["default"] = p.Default
+		}
+		if p.Description != "" {
+			paramSchema["description"] = p.Description
+		}
+		properties[p.Name] = paramSchema
+
+		mandatory := p.Required && p.Default == nil
+		if mandatory {
+			required = append(required, p.Name)
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        p.Name,
+			"in":          "query",
+			"required":    mandatory,
+			"description": p.Description,
+			"schema":      paramSchema,
+		})
+	}
+
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "Query result",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"resource": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "object"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	item := map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     summary,
+			"operationId": fmt.Sprintf("run_query_%s_%s", serviceName, nq.Name),
+			"tags":        []string{serviceName},
+			"requestBody": map[string]interface{}{
+				"description": "Query arguments",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":       "object",
+							"properties": properties,
+							"required":   required,
+						},
+					},
+				},
+			},
+			"responses": responses,
+		},
+	}
+	if !nq.Write {
+		item["get"] = map[string]interface{}{
+			"summary":     summary,
+			"operationId": fmt.Sprintf("get_query_%s_%s", serviceName, nq.Name),
+			"tags":        []string{serviceName},
+			"parameters":  parameters,
+			"responses":   responses,
+		}
+	}
+	return item
+}
+
 // jsonSchemaType maps a Faucet JSON type string (from model.Column.JsonType)
 // to a valid JSON Schema type.
 func jsonSchemaType(jsonType string) string {
@@ -579,7 +1404,16 @@ func jsonSchemaType(jsonType string) string {
 // the stable API contract rather than the potentially-drifted live schema.
 func (h *OpenAPIHandler) applyContracts(r *http.Request, serviceName string, schema *model.Schema) *model.Schema {
 	contracts, err := h.store.ListContracts(r.Context(), serviceName)
-	if err != nil || len(contracts) == 0 {
+	if err != nil {
+		return schema
+	}
+	return lockedSchema(schema, contracts)
+}
+
+// lockedSchema returns schema with the tables that have a contract replaced
+// by their locked definitions.
+func lockedSchema(schema *model.Schema, contracts []contract.Contract) *model.Schema {
+	if len(contracts) == 0 {
 		return schema
 	}
 
@@ -0,0 +1,779 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+	"github.com/faucetdb/faucet/internal/webhook"
+)
+
+// postgrestObjectType is the media type with which a client asks for a
+// single row as an object rather than an array.
+const postgrestObjectType = "application/vnd.pgrst.object+json"
+
+// PostgRESTHandler serves the tables of a service in PostgREST's URL
+// dialect at /api/v1/{serviceName}/rest/v1, so that PostgREST clients such
+// as supabase-js keep working against any database. Filters, select, order
+// and ranges compile to the same SelectRequests as the REST table routes,
+// and writes run as _batch operations do. The routes only answer for
+// services with the postgrest setting.
+type PostgRESTHandler struct {
+	registry *connector.Registry
+	store    *config.Store
+	tables   *TableHandler
+	webhooks *webhook.Dispatcher
+}
+
+// NewPostgRESTHandler creates a new PostgRESTHandler.
+func NewPostgRESTHandler(registry *connector.Registry, store *config.Store) *PostgRESTHandler {
+	return &PostgRESTHandler{
+		registry: registry,
+		store:    store,
+		tables:   NewTableHandler(registry, store),
+	}
+}
+
+// SetWebhooks makes writes notify the webhooks registered for them. The
+// routes are not behind the Webhooks middleware, whose events are read from
+// REST responses.
+func (h *PostgRESTHandler) SetWebhooks(d *webhook.Dispatcher) {
+	h.webhooks = d
+}
+
+// ---------------------------------------------------------------------------
+// Errors
+// ---------------------------------------------------------------------------
+
+// postgrestError is an error in PostgREST's format. code is a PGRST code or
+// the SQLSTATE PostgreSQL would report.
+type postgrestError struct {
+	status  int
+	code    string
+	msg     string
+	details string
+}
+
+func (e *postgrestError) Error() string { return e.msg }
+
+func badPostgREST(format string, args ...interface{}) error {
+	return &postgrestError{status: http.StatusBadRequest, code: "PGRST100", msg: fmt.Sprintf(format, args...)}
+}
+
+// postgrestClientError converts an error compiling a query or preparing a
+// write, which is the client's fault.
+func postgrestClientError(err error) error {
+	var pgErr *postgrestError
+	if errors.As(err, &pgErr) {
+		return err
+	}
+	var unknown *query.UnknownColumnError
+	if errors.As(err, &unknown) {
+		return &postgrestError{status: http.StatusBadRequest, code: "42703", msg: err.Error()}
+	}
+	var ruleErr *columnRuleError
+	if errors.As(err, &ruleErr) {
+		code := "PGRST102"
+		if ruleErr.code == http.StatusForbidden {
+			code = "42501"
+		}
+		return &postgrestError{status: ruleErr.code, code: code, msg: ruleErr.msg}
+	}
+	var invalid *recordValidationError
+	if errors.As(err, &invalid) {
+		return &postgrestError{status: http.StatusBadRequest, code: "PGRST102", msg: err.Error()}
+	}
+	return badPostgREST("%s", err.Error())
+}
+
+// postgrestDBError converts a database error, with the SQLSTATE of the
+// constraint violations clients commonly check for.
+func postgrestDBError(err error, fallbackMsg string) error {
+	status, msg := classifyDBError(err, fallbackMsg)
+	lower := strings.ToLower(err.Error())
+	code := "XX000"
+	switch {
+	case status == http.StatusConflict:
+		code = "23505"
+	case strings.Contains(lower, "foreign key") || strings.Contains(lower, "fk constraint"):
+		code = "23503"
+	case status == http.StatusBadRequest && strings.Contains(lower, "null"):
+		code = "23502"
+	}
+	return &postgrestError{status: status, code: code, msg: msg}
+}
+
+// writePostgRESTError writes err as PostgREST does. Errors other than
+// *postgrestError are reported as database errors.
+func writePostgRESTError(w http.ResponseWriter, err error) {
+	var pgErr *postgrestError
+	if !errors.As(err, &pgErr) {
+		errors.As(postgrestDBError(err, "Query failed"), &pgErr)
+	}
+	body := map[string]interface{}{
+		"code":    pgErr.code,
+		"message": pgErr.msg,
+		"details": nil,
+		"hint":    nil,
+	}
+	if pgErr.details != "" {
+		body["details"] = pgErr.details
+	}
+	writePostgRESTJSON(w, pgErr.status, body)
+}
+
+// writePostgRESTJSON writes a JSON response.
+func writePostgRESTJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ---------------------------------------------------------------------------
+// Request parsing
+// ---------------------------------------------------------------------------
+
+// postgrestParams are the query parameters of a request.
+type postgrestParams struct {
+	columns    []string      // select; nil selects every column
+	filters    []interface{} // horizontal and logical filters, ANDed
+	order      []query.OrderClause
+	limit      int // -1 when absent
+	offset     int
+	onConflict []string
+	insert     []string // columns: the keys inserted from each record
+}
+
+// parsePostgRESTParams reads the query parameters of a request. Every
+// parameter that is not a reserved name is a column filter.
+func parsePostgRESTParams(r *http.Request) (postgrestParams, error) {
+	p := postgrestParams{limit: -1}
+	values := r.URL.Query()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	// Sorted, so that the compiled filter is the same for every request.
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range values[name] {
+			var err error
+			switch name {
+			case "select":
+				p.columns, err = query.ParsePostgRESTColumns(value)
+			case "columns":
+				p.insert, err = query.ParsePostgRESTColumns(value)
+			case "on_conflict":
+				p.onConflict, err = query.ParsePostgRESTColumns(value)
+			case "order":
+				p.order, err = query.ParsePostgRESTOrder(value)
+			case "limit":
+				p.limit, err = strconv.Atoi(value)
+				if err != nil || p.limit < 0 {
+					return p, badPostgREST("Invalid limit: %s", value)
+				}
+			case "offset":
+				p.offset, err = strconv.Atoi(value)
+				if err != nil || p.offset < 0 {
+					return p, badPostgREST("Invalid offset: %s", value)
+				}
+			case "and", "or", "not.and", "not.or":
+				var node map[string]interface{}
+				if node, err = query.ParsePostgRESTLogic(name, value); err == nil {
+					p.filters = append(p.filters, node)
+				}
+			default:
+				var node map[string]interface{}
+				if node, err = query.ParsePostgRESTFilter(name, value); err == nil {
+					p.filters = append(p.filters, node)
+				}
+			}
+			if err != nil {
+				return p, badPostgREST("%s", err.Error())
+			}
+		}
+	}
+	return p, nil
+}
+
+// where returns the filters as a structured filter (see
+// query.ParseFilterJSON), or nil when there are none.
+func (p postgrestParams) where() json.RawMessage {
+	var tree interface{}
+	switch len(p.filters) {
+	case 0:
+		return nil
+	case 1:
+		tree = p.filters[0]
+	default:
+		tree = map[string]interface{}{"and": p.filters}
+	}
+	data, _ := json.Marshal(tree)
+	return data
+}
+
+// postgrestPreferences reads the Prefer header, such as
+// "return=representation,count=exact", into a map.
+func postgrestPreferences(r *http.Request) map[string]string {
+	prefs := make(map[string]string)
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(prefer, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			if name != "" {
+				prefs[name] = value
+			}
+		}
+	}
+	return prefs
+}
+
+// requestRange reads a Range header such as "items=0-9" or "0-" into an
+// offset and a limit, -1 when the range is open-ended.
+func requestRange(r *http.Request) (offset, limit int, ok bool, err error) {
+	header := r.Header.Get("Range")
+	if header == "" {
+		return 0, -1, false, nil
+	}
+	spec := strings.TrimPrefix(strings.TrimSpace(header), "items=")
+	first, last, found := strings.Cut(spec, "-")
+	offset, err = strconv.Atoi(first)
+	if !found || err != nil || offset < 0 {
+		return 0, -1, false, &postgrestError{status: http.StatusRequestedRangeNotSatisfiable, code: "PGRST103", msg: "Invalid range: " + header}
+	}
+	if last == "" {
+		return offset, -1, true, nil
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil || end < offset {
+		return 0, -1, false, &postgrestError{status: http.StatusRequestedRangeNotSatisfiable, code: "PGRST103", msg: "Invalid range: " + header}
+	}
+	return offset, end - offset + 1, true, nil
+}
+
+// contentRange formats a Content-Range header for n rows starting at
+// offset, with the total when it was counted.
+func contentRange(offset, n int, total *int64) string {
+	size := "*"
+	if total != nil {
+		size = strconv.FormatInt(*total, 10)
+	}
+	if n == 0 {
+		return "*/" + size
+	}
+	return fmt.Sprintf("%d-%d/%s", offset, offset+n-1, size)
+}
+
+// wantsObject reports whether the client asked for a single row as an
+// object.
+func wantsObject(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), postgrestObjectType)
+}
+
+// singleRow checks that a response asked for as an object has exactly one
+// row.
+func singleRow(rows []map[string]interface{}) error {
+	if len(rows) == 1 {
+		return nil
+	}
+	return &postgrestError{
+		status:  http.StatusNotAcceptable,
+		code:    "PGRST116",
+		msg:     "JSON object requested, multiple (or no) rows returned",
+		details: fmt.Sprintf("The result contains %d rows", len(rows)),
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Routes
+// ---------------------------------------------------------------------------
+
+// target resolves the service and table of a request. Services without the
+// postgrest setting are reported as not found.
+func (h *PostgRESTHandler) target(r *http.Request) (connector.Connector, *model.ServiceConfig, *model.TableSchema, error) {
+	ctx := r.Context()
+	serviceName := chi.URLParam(r, "serviceName")
+	svc, err := h.store.GetServiceByName(ctx, serviceName)
+	if err != nil && !errors.Is(err, config.ErrNotFound) {
+		return nil, nil, nil, &postgrestError{status: http.StatusInternalServerError, code: "PGRST000", msg: "Failed to get service: " + err.Error()}
+	}
+	if svc == nil || !svc.PostgREST {
+		return nil, nil, nil, &postgrestError{status: http.StatusNotFound, code: "PGRST125", msg: "Invalid path specified in request URL"}
+	}
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		return nil, nil, nil, &postgrestError{status: http.StatusServiceUnavailable, code: "PGRST000", msg: "Service not connected: " + serviceName}
+	}
+	tableName := chi.URLParam(r, "tableName")
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil || ts == nil {
+		return nil, nil, nil, &postgrestError{status: http.StatusNotFound, code: "PGRST205",
+			msg: fmt.Sprintf("Could not find the table '%s' in the schema cache", tableName)}
+	}
+	return conn, svc, ts, nil
+}
+
+// Read serves the rows of a table matching the filters of a request as a
+// JSON array, or as one object when the client accepts
+// application/vnd.pgrst.object+json.
+// GET  /api/v1/{serviceName}/rest/v1/{tableName}
+// HEAD /api/v1/{serviceName}/rest/v1/{tableName}
+//
+// Rows are paged with limit and offset or a Range header, at most the
+// principal's max limit at a time, in primary key order unless ordered.
+// Content-Range reports the range, and its total with Prefer: count=exact.
+func (h *PostgRESTHandler) Read(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, svc, ts, err := h.target(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	allowed, err := authorizeComponent(ctx, h.store, svc.Name, []string{"_table/*", "_table/" + ts.Name}, model.VerbGet)
+	if err != nil {
+		writePostgRESTError(w, &postgrestError{status: http.StatusInternalServerError, code: "PGRST000", msg: "Failed to check access: " + err.Error()})
+		return
+	}
+	if !allowed {
+		writePostgRESTError(w, &postgrestError{status: http.StatusForbidden, code: "42501", msg: "permission denied for table " + ts.Name})
+		return
+	}
+	params, err := parsePostgRESTParams(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	prefs := postgrestPreferences(r)
+
+	offset, limit := params.offset, params.limit
+	if limit < 0 && offset == 0 {
+		rangeOffset, rangeLimit, ok, err := requestRange(r)
+		if err != nil {
+			writePostgRESTError(w, err)
+			return
+		}
+		if ok {
+			offset, limit = rangeOffset, rangeLimit
+		}
+	}
+	if pageSize := maxLimit(ctx, h.store); limit < 0 || limit > pageSize {
+		limit = pageSize
+	}
+
+	order := params.order
+	if len(order) == 0 {
+		for _, col := range ts.PrimaryKey {
+			order = append(order, query.OrderClause{Column: col, Direction: "ASC"})
+		}
+	}
+	clauses := make([]string, len(order))
+	for i, c := range order {
+		clauses[i] = c.String()
+	}
+	q := recordQuery{
+		Where:  params.where(),
+		Fields: strings.Join(params.columns, ","),
+		Order:  strings.Join(clauses, ", "),
+		Limit:  limit,
+		Offset: offset,
+	}
+	selectReq, err := h.tables.compileQuery(ctx, conn, svc.Name, ts.Name, q)
+	if err != nil {
+		writePostgRESTError(w, postgrestClientError(err))
+		return
+	}
+
+	var total *int64
+	if prefs["count"] != "" {
+		count, err := countSelect(ctx, conn, selectReq)
+		if err != nil {
+			writePostgRESTError(w, err)
+			return
+		}
+		total = &count
+		if offset > 0 && int64(offset) > count {
+			writePostgRESTError(w, &postgrestError{
+				status:  http.StatusRequestedRangeNotSatisfiable,
+				code:    "PGRST103",
+				msg:     "Requested range not satisfiable",
+				details: fmt.Sprintf("An offset of %d was requested, but there are only %d rows.", offset, count),
+			})
+			return
+		}
+	}
+
+	rows := []map[string]interface{}{}
+	if limit > 0 && (r.Method != http.MethodHead || total == nil) {
+		rows, err = h.tables.queryRows(ctx, conn.DB(), conn, svc.Name, selectReq)
+		if err != nil {
+			writePostgRESTError(w, err)
+			return
+		}
+	} else if total != nil {
+		// A HEAD request only needs the size of the range.
+		n := min(int64(limit), max(*total-int64(offset), 0))
+		rows = make([]map[string]interface{}, n)
+	}
+
+	status := http.StatusOK
+	if total != nil && int64(offset+len(rows)) < *total {
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Range", contentRange(offset, len(rows), total))
+	if wantsObject(r) {
+		if err := singleRow(rows); err != nil {
+			writePostgRESTError(w, err)
+			return
+		}
+		writePostgRESTJSON(w, http.StatusOK, rows[0])
+		return
+	}
+	writePostgRESTJSON(w, status, rows)
+}
+
+// Insert inserts the body, an object or an array of objects.
+// POST /api/v1/{serviceName}/rest/v1/{tableName}
+//
+// Prefer: resolution=merge-duplicates upserts on the primary key or the
+// on_conflict columns, and resolution=ignore-duplicates skips conflicting
+// records. With columns, only the named keys of each record are inserted;
+// absent keys are NULL, or the column default with Prefer: missing=default.
+func (h *PostgRESTHandler) Insert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, svc, ts, err := h.target(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	params, err := parsePostgRESTParams(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	prefs := postgrestPreferences(r)
+
+	records, err := postgrestRecords(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	if params.insert != nil {
+		records = insertColumns(records, params.insert, prefs["missing"] == "default")
+	}
+
+	op := &batchOperation{Op: "insert", Table: ts.Name, Records: records}
+	switch prefs["resolution"] {
+	case "merge-duplicates":
+		op.Op, op.OnConflict = "upsert", params.onConflict
+	case "ignore-duplicates":
+		op.Op, op.OnConflict, op.IgnoreDuplicates = "upsert", params.onConflict, true
+	}
+	rows, count, err := h.mutate(ctx, conn, svc, op, func(tx connector.QueryExecutor) ([]map[string]interface{}, int, error) {
+		res, err := h.tables.execBatchOperation(ctx, tx, conn, svc.Name, op)
+		if err != nil {
+			return nil, 0, err
+		}
+		if wantsObject(r) && prefs["return"] == "representation" {
+			if err := singleRow(res.Resource); err != nil {
+				return nil, 0, err
+			}
+		}
+		return res.Resource, res.Count, nil
+	})
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	h.emit(ctx, svc.Name, ts.Name, model.VerbPost, "insert", rows, count)
+	writePostgRESTWrite(w, r, params, prefs, http.StatusCreated, rows, count)
+}
+
+// Update applies the body, an object, to the rows matching the filters,
+// which are required.
+// PATCH /api/v1/{serviceName}/rest/v1/{tableName}
+func (h *PostgRESTHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, svc, ts, err := h.target(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	params, err := parsePostgRESTParams(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	prefs := postgrestPreferences(r)
+
+	var record map[string]interface{}
+	if err := readJSON(r, &record); err != nil || len(record) == 0 {
+		writePostgRESTError(w, &postgrestError{status: http.StatusBadRequest, code: "PGRST102", msg: "Empty or invalid json"})
+		return
+	}
+	if len(params.filters) == 0 {
+		writePostgRESTError(w, &postgrestError{status: http.StatusBadRequest, code: "21000", msg: "UPDATE requires a WHERE clause"})
+		return
+	}
+
+	represent := prefs["return"] == "representation"
+	op := &batchOperation{Op: "update", Table: ts.Name, Record: record, where: params.where()}
+	rows, count, err := h.mutate(ctx, conn, svc, op, func(tx connector.QueryExecutor) ([]map[string]interface{}, int, error) {
+		// Without RETURNING, updated rows are read back by key.
+		var keys []interface{}
+		if represent && !conn.SupportsReturning() {
+			if len(ts.PrimaryKey) == 0 {
+				return nil, 0, badPostgREST("return=representation needs a primary key on the %s driver", conn.DriverName())
+			}
+			matched, err := h.selectRows(ctx, tx, conn, svc.Name, ts.Name, recordQuery{Where: op.where, Fields: strings.Join(ts.PrimaryKey, ",")})
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, row := range matched {
+				for col, v := range record {
+					if _, isKey := row[col]; isKey {
+						row[col] = v
+					}
+				}
+				if key, ok := recordKey(row, ts.PrimaryKey); ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+
+		res, err := h.tables.execBatchOperation(ctx, tx, conn, svc.Name, op)
+		if err != nil {
+			return nil, 0, err
+		}
+		rows := res.Resource
+		if len(keys) > 0 {
+			rows, err = h.selectRows(ctx, tx, conn, svc.Name, ts.Name, recordQuery{IDs: keys, KeyColumns: ts.PrimaryKey})
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		if wantsObject(r) && represent {
+			if err := singleRow(rows); err != nil {
+				return nil, 0, err
+			}
+		}
+		return rows, res.Count, nil
+	})
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	h.emit(ctx, svc.Name, ts.Name, model.VerbPatch, "update", rows, count)
+	writePostgRESTWrite(w, r, params, prefs, http.StatusOK, rows, count)
+}
+
+// Delete removes the rows matching the filters, which are required.
+// DELETE /api/v1/{serviceName}/rest/v1/{tableName}
+func (h *PostgRESTHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, svc, ts, err := h.target(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	params, err := parsePostgRESTParams(r)
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	prefs := postgrestPreferences(r)
+	if len(params.filters) == 0 {
+		writePostgRESTError(w, &postgrestError{status: http.StatusBadRequest, code: "21000", msg: "DELETE requires a WHERE clause"})
+		return
+	}
+
+	represent := prefs["return"] == "representation"
+	op := &batchOperation{Op: "delete", Table: ts.Name, where: params.where()}
+	rows, count, err := h.mutate(ctx, conn, svc, op, func(tx connector.QueryExecutor) ([]map[string]interface{}, int, error) {
+		// Deleted rows are read before they go.
+		var rows []map[string]interface{}
+		if represent {
+			var err error
+			if rows, err = h.selectRows(ctx, tx, conn, svc.Name, ts.Name, recordQuery{Where: op.where}); err != nil {
+				return nil, 0, err
+			}
+			if wantsObject(r) {
+				if err := singleRow(rows); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+		res, err := h.tables.execBatchOperation(ctx, tx, conn, svc.Name, op)
+		if err != nil {
+			return nil, 0, err
+		}
+		return rows, res.Count, nil
+	})
+	if err != nil {
+		writePostgRESTError(w, err)
+		return
+	}
+	h.emit(ctx, svc.Name, ts.Name, model.VerbDelete, "delete", rows, count)
+	writePostgRESTWrite(w, r, params, prefs, http.StatusOK, rows, count)
+}
+
+// mutate runs a write as a _batch operation would run: the operation is
+// validated, authorized and checked against the schema, then run executes
+// it in its own transaction and returns the written rows and their count.
+func (h *PostgRESTHandler) mutate(ctx context.Context, conn connector.Connector, svc *model.ServiceConfig, op *batchOperation, run func(tx connector.QueryExecutor) ([]map[string]interface{}, int, error)) ([]map[string]interface{}, int, error) {
+	if svc.ReadOnly {
+		return nil, 0, &postgrestError{status: http.StatusForbidden, code: "25006", msg: "Service is read-only"}
+	}
+	if err := h.tables.prepareBatchOperation(ctx, conn, svc.Name, op); err != nil {
+		return nil, 0, postgrestClientError(err)
+	}
+	allowed, err := h.tables.authorizeBatchOperation(ctx, svc.Name, op)
+	if err != nil {
+		return nil, 0, &postgrestError{status: http.StatusInternalServerError, code: "PGRST000", msg: "Failed to check access: " + err.Error()}
+	}
+	if !allowed {
+		return nil, 0, &postgrestError{status: http.StatusForbidden, code: "42501", msg: "permission denied for table " + op.Table}
+	}
+	if invalid := h.tables.checkBatchOperation(ctx, svc.Name, op); invalid != nil {
+		return nil, 0, postgrestClientError(invalid)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, &postgrestError{status: http.StatusInternalServerError, code: "PGRST000", msg: "Failed to begin transaction: " + err.Error()}
+	}
+	defer func() { _ = tx.Rollback() }()
+	rows, count, err := run(tx)
+	if err != nil {
+		var pgErr *postgrestError
+		if errors.As(err, &pgErr) {
+			return nil, 0, err
+		}
+		return nil, 0, postgrestDBError(err, fmt.Sprintf("Failed to %s %s", op.Op, op.Table))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, &postgrestError{status: http.StatusInternalServerError, code: "PGRST000", msg: "Failed to commit transaction: " + err.Error()}
+	}
+	return rows, count, nil
+}
+
+// selectRows reads the rows of a table matching q on tx.
+func (h *PostgRESTHandler) selectRows(ctx context.Context, tx connector.QueryExecutor, conn connector.Connector, serviceName, tableName string, q recordQuery) ([]map[string]interface{}, error) {
+	selectReq, err := h.tables.compileQuery(ctx, conn, serviceName, tableName, q)
+	if err != nil {
+		return nil, postgrestClientError(err)
+	}
+	return h.tables.queryRows(ctx, tx, conn, serviceName, selectReq)
+}
+
+// emit notifies webhooks of a successful write.
+func (h *PostgRESTHandler) emit(ctx context.Context, service, table string, verb int, op string, records []map[string]interface{}, count int) {
+	if h.webhooks == nil || count == 0 {
+		return
+	}
+	h.webhooks.Emit(ctx, model.WebhookEvent{
+		Service: service,
+		Table:   table,
+		Verb:    verb,
+		Op:      op,
+		Records: records,
+		Count:   count,
+	})
+}
+
+// postgrestRecords reads the body of an insert: an object or an array of
+// objects.
+func postgrestRecords(r *http.Request) ([]map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := readJSON(r, &raw); err == nil {
+		var records []map[string]interface{}
+		if json.Unmarshal(raw, &records) == nil && len(records) > 0 {
+			return records, nil
+		}
+		var single map[string]interface{}
+		if json.Unmarshal(raw, &single) == nil && len(single) > 0 {
+			return []map[string]interface{}{single}, nil
+		}
+	}
+	return nil, &postgrestError{status: http.StatusBadRequest, code: "PGRST102", msg: "Empty or invalid json"}
+}
+
+// insertColumns keeps the named keys of each record. Absent keys are set to
+// NULL, or left out for the column default when missingDefault is set.
+func insertColumns(records []map[string]interface{}, columns []string, missingDefault bool) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(records))
+	for i, rec := range records {
+		row := make(map[string]interface{}, len(columns))
+		for _, col := range columns {
+			if v, ok := rec[col]; ok {
+				row[col] = v
+			} else if !missingDefault {
+				row[col] = nil
+			}
+		}
+		out[i] = row
+	}
+	return out
+}
+
+// writePostgRESTWrite writes the response of a successful write: the
+// written rows (restricted to select) with Prefer: return=representation,
+// or no body with status 201 for inserts and 204 otherwise.
+func writePostgRESTWrite(w http.ResponseWriter, r *http.Request, params postgrestParams, prefs map[string]string, status int, rows []map[string]interface{}, count int) {
+	var applied []string
+	for _, name := range []string{"return", "resolution", "missing", "count"} {
+		if v := prefs[name]; v != "" {
+			applied = append(applied, name+"="+v)
+		}
+	}
+	if len(applied) > 0 {
+		w.Header().Set("Preference-Applied", strings.Join(applied, ", "))
+	}
+	var total *int64
+	if prefs["count"] != "" {
+		n := int64(count)
+		total = &n
+	}
+
+	if prefs["return"] != "representation" {
+		w.Header().Set("Content-Range", contentRange(0, 0, total))
+		if status != http.StatusCreated {
+			status = http.StatusNoContent
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	if params.columns != nil {
+		keep := make(map[string]bool, len(params.columns))
+		for _, col := range params.columns {
+			keep[col] = true
+		}
+		for _, row := range rows {
+			for col := range row {
+				if !keep[col] {
+					delete(row, col)
+				}
+			}
+		}
+	}
+	w.Header().Set("Content-Range", contentRange(0, len(rows), total))
+	if wantsObject(r) && len(rows) == 1 {
+		writePostgRESTJSON(w, status, rows[0])
+		return
+	}
+	writePostgRESTJSON(w, status, rows)
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+func newPostgRESTTestEnv(t *testing.T) *batchTestEnv {
+	t.Helper()
+	env := newGraphQLTestEnv(t)
+	if err := env.store.CreateService(context.Background(), &model.ServiceConfig{
+		Name: "testdb", Driver: "sqlite", DSN: ":memory:", IsActive: true, PostgREST: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	ph := NewPostgRESTHandler(env.registry, env.store)
+	env.router.Route("/api/v1/{serviceName}/rest/v1", func(r chi.Router) {
+		r.Get("/{tableName}", ph.Read)
+		r.Head("/{tableName}", ph.Read)
+		r.Post("/{tableName}", ph.Insert)
+		r.Patch("/{tableName}", ph.Update)
+		r.Delete("/{tableName}", ph.Delete)
+	})
+	return env
+}
+
+// postgrest issues a request against the PostgREST routes as principal p
+// (nil for none) and returns the recorder.
+func (e *batchTestEnv) postgrest(t *testing.T, p *middleware.Principal, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1/testdb/rest/v1"+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if p != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthPrincipalKey, p))
+	}
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func decodePostgRESTRows(t *testing.T, rr *httptest.ResponseRecorder, wantStatus int) []map[string]interface{} {
+	t.Helper()
+	if rr.Code != wantStatus {
+		t.Fatalf("expected %d, got %d; body: %s", wantStatus, rr.Code, rr.Body.String())
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	return rows
+}
+
+func TestPostgRESTRead(t *testing.T) {
+	env := newPostgRESTTestEnv(t)
+
+	rows := decodePostgRESTRows(t, env.postgrest(t, nil, "GET", "/orders?select=id,status&status=eq.paid&order=id.desc", "", nil), http.StatusOK)
+	if len(rows) != 2 || rows[0]["id"] != float64(3) || rows[1]["id"] != float64(1) {
+		t.Errorf("filtered orders: got %v", rows)
+	}
+	if _, ok := rows[0]["user_id"]; ok {
+		t.Errorf("select: unexpected user_id in %v", rows[0])
+	}
+
+	rows = decodePostgRESTRows(t, env.postgrest(t, nil, "GET", "/orders?or=(status.eq.new,user_id.eq.2)&id=gte.2", "", nil), http.StatusOK)
+	if len(rows) != 2 || rows[0]["id"] != float64(2) || rows[1]["id"] != float64(3) {
+		t.Errorf("or filter: got %v", rows)
+	}
+
+	rows = decodePostgRESTRows(t, env.postgrest(t, nil, "GET", "/users?name=ilike.*LIC*", "", nil), http.StatusOK)
+	if len(rows) != 1 || rows[0]["name"] != "Alice" {
+		t.Errorf("ilike: got %v", rows)
+	}
+
+	// A Range with an exact count is a partial response.
+	rr := env.postgrest(t, nil, "GET", "/orders", "", http.Header{"Range": {"0-1"}, "Prefer": {"count=exact"}})
+	rows = decodePostgRESTRows(t, rr, http.StatusPartialContent)
+	if len(rows) != 2 || rr.Header().Get("Content-Range") != "0-1/3" {
+		t.Errorf("range: got %d rows, Content-Range %q", len(rows), rr.Header().Get("Content-Range"))
+	}
+	rr = env.postgrest(t, nil, "HEAD", "/orders?offset=2", "", http.Header{"Prefer": {"count=exact"}})
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Range") != "2-2/3" {
+		t.Errorf("head: got %d, Content-Range %q", rr.Code, rr.Header().Get("Content-Range"))
+	}
+	rr = env.postgrest(t, nil, "GET", "/orders?offset=10", "", http.Header{"Prefer": {"count=exact"}})
+	if rr.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("offset beyond count: expected 416, got %d", rr.Code)
+	}
+
+	// A single object is returned only when exactly one row matches.
+	object := http.Header{"Accept": {"application/vnd.pgrst.object+json"}}
+	rr = env.postgrest(t, nil, "GET", "/users?id=eq.2", "", object)
+	var user map[string]interface{}
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &user) != nil || user["name"] != "Bob" {
+		t.Errorf("object: got %d %s", rr.Code, rr.Body.String())
+	}
+	rr = env.postgrest(t, nil, "GET", "/users", "", object)
+	if rr.Code != http.StatusNotAcceptable || !strings.Contains(rr.Body.String(), `"PGRST116"`) {
+		t.Errorf("object with two rows: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/orders?status=paid", http.StatusBadRequest, "PGRST100"},
+		{"/orders?select=id,users(name)", http.StatusBadRequest, "PGRST100"},
+		{"/orders?nope=eq.1", http.StatusBadRequest, "42703"},
+		{"/missing", http.StatusNotFound, "PGRST205"},
+	} {
+		rr := env.postgrest(t, nil, "GET", tc.path, "", nil)
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), `"code":"`+tc.code+`"`) {
+			t.Errorf("%s: expected %d %s, got %d %s", tc.path, tc.status, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestPostgRESTWrites(t *testing.T) {
+	env := newPostgRESTTestEnv(t)
+	represent := http.Header{"Prefer": {"return=representation"}}
+
+	rows := decodePostgRESTRows(t, env.postgrest(t, nil, "POST", "/users?select=id,name",
+		`[{"name":"Carol","email":"carol@example.com"},{"name":"Dan","email":"dan@example.com"}]`, represent), http.StatusCreated)
+	if len(rows) != 2 || rows[0]["name"] != "Carol" || rows[0]["email"] != nil {
+		t.Errorf("insert: got %v", rows)
+	}
+	rr := env.postgrest(t, nil, "POST", "/users", `{"name":"Eve","email":"alice@example.com"}`, nil)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), `"23505"`) {
+		t.Errorf("duplicate insert: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Upserts merge on the on_conflict columns.
+	rr = env.postgrest(t, nil, "POST", "/users?on_conflict=email", `{"name":"Alicia","email":"alice@example.com"}`,
+		http.Header{"Prefer": {"resolution=merge-duplicates"}})
+	if rr.Code != http.StatusCreated || rr.Body.Len() != 0 {
+		t.Errorf("upsert: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rows = decodePostgRESTRows(t, env.postgrest(t, nil, "PATCH", "/orders?status=eq.paid", `{"status":"shipped"}`, represent), http.StatusOK)
+	if len(rows) != 2 || rows[0]["status"] != "shipped" {
+		t.Errorf("update: got %v", rows)
+	}
+	rr = env.postgrest(t, nil, "PATCH", "/orders", `{"status":"lost"}`, nil)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"21000"`) {
+		t.Errorf("update without filters: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// A single object write that matches several rows is rolled back.
+	rr = env.postgrest(t, nil, "DELETE", "/order_items?order_id=eq.1", "",
+		http.Header{"Prefer": {"return=representation"}, "Accept": {"application/vnd.pgrst.object+json"}})
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("object delete: expected 406, got %d %s", rr.Code, rr.Body.String())
+	}
+	rows = decodePostgRESTRows(t, env.postgrest(t, nil, "DELETE", "/order_items?order_id=eq.1", "", represent), http.StatusOK)
+	if len(rows) != 2 {
+		t.Errorf("delete: got %v", rows)
+	}
+	rr = env.postgrest(t, nil, "DELETE", "/order_items?id=eq.3", "", http.Header{"Prefer": {"count=exact"}})
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Content-Range") != "*/1" {
+		t.Errorf("delete: got %d, Content-Range %q", rr.Code, rr.Header().Get("Content-Range"))
+	}
+
+	rows = decodePostgRESTRows(t, env.postgrest(t, nil, "GET", "/users?select=name&order=id", "", nil), http.StatusOK)
+	if len(rows) != 4 || rows[0]["name"] != "Alicia" {
+		t.Errorf("users after writes: got %v", rows)
+	}
+}
+
+func TestPostgRESTAccess(t *testing.T) {
+	env := newPostgRESTTestEnv(t)
+	ctx := context.Background()
+	role := &model.Role{Name: "reader", IsActive: true}
+	if err := env.store.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbGet},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+	key := &middleware.Principal{Type: "api_key", RoleID: role.ID, KeyID: 1}
+
+	decodePostgRESTRows(t, env.postgrest(t, key, "GET", "/users", "", nil), http.StatusOK)
+	for _, tc := range []struct{ method, path, body string }{
+		{"GET", "/orders", ""},
+		{"POST", "/users", `{"name":"Carol","email":"carol@example.com"}`},
+		{"DELETE", "/users?id=eq.1", ""},
+	} {
+		rr := env.postgrest(t, key, tc.method, tc.path, tc.body, nil)
+		if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), `"42501"`) {
+			t.Errorf("%s %s: expected 403, got %d %s", tc.method, tc.path, rr.Code, rr.Body.String())
+		}
+	}
+
+	// The routes only answer for services that enable them.
+	if err := env.store.CreateService(ctx, &model.ServiceConfig{
+		Name: "other", Driver: "sqlite", DSN: ":memory:", IsActive: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/other/rest/v1/users", nil)
+	rr := httptest.NewRecorder()
+	env.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"PGRST125"`) {
+		t.Errorf("disabled service: got %d %s", rr.Code, rr.Body.String())
+	}
+}
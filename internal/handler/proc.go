@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/model"
 )
@@ -13,12 +14,14 @@ import (
 // ProcHandler handles stored procedure listing and execution.
 type ProcHandler struct {
 	registry *connector.Registry
+	store    *config.Store
 }
 
 // NewProcHandler creates a new ProcHandler.
-func NewProcHandler(registry *connector.Registry) *ProcHandler {
+func NewProcHandler(registry *connector.Registry, store *config.Store) *ProcHandler {
 	return &ProcHandler{
 		registry: registry,
+		store:    store,
 	}
 }
 
@@ -58,7 +61,8 @@ func (h *ProcHandler) ListProcedures(w http.ResponseWriter, r *http.Request) {
 }
 
 // CallProcedure executes a stored procedure with the provided parameters and
-// returns the result set.
+// returns the result set, as JSON or in the format negotiated by ?format= or
+// the Accept header.
 // POST /api/v1/{serviceName}/_proc/{procName}
 func (h *ProcHandler) CallProcedure(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -72,6 +76,12 @@ func (h *ProcHandler) CallProcedure(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid output format: "+err.Error())
+		return
+	}
+
 	// Parse parameters from the request body. An empty body is acceptable
 	// for procedures with no parameters.
 	var params map[string]interface{}
@@ -86,31 +96,57 @@ func (h *ProcHandler) CallProcedure(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Also merge query parameters into the params map so that simple
-	// procedure calls can be made via GET-style query strings.
+	// procedure calls can be made via GET-style query strings. Output options
+	// are never parameters; pass parameters of those names in the body.
+	dryRun := dryRunRequested(r)
 	for key, values := range r.URL.Query() {
+		if key == "dry_run" && dryRun || isOutputParam(key) {
+			continue
+		}
 		if _, exists := params[key]; !exists && len(values) > 0 {
 			params[key] = values[0]
 		}
 	}
 
+	// Procedure results carry no column types, so the generic rules apply.
+	enc := connector.NewValueEncoder(conn, nil, numericFormat(r.Context(), h.store, serviceName))
+
+	// A dry run calls the procedure in a transaction that is rolled back,
+	// which needs a driver that can call procedures on a transaction.
+	if dryRun {
+		caller, ok := conn.(connector.TxProcedureCaller)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Dry runs of stored procedures are not supported by the "+conn.DriverName()+" driver")
+			return
+		}
+		runDryRun(w, r, conn, "Procedure call failed", func(d *connector.DryRun) (interface{}, *int64, error) {
+			results, err := caller.CallProcedureTx(r.Context(), d, procName, params)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, row := range results {
+				enc.EncodeRow(row)
+			}
+			return results, nil, nil
+		})
+		return
+	}
+
 	results, err := conn.CallProcedure(r.Context(), procName, params)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Procedure call failed: "+err.Error())
 		return
 	}
 
-	// Clean up []byte values for JSON serialization.
 	for _, row := range results {
-		cleanMapValues(row)
+		enc.EncodeRow(row)
 	}
 
 	took := time.Since(start)
 
-	writeJSON(w, http.StatusOK, model.ListResponse{
-		Resource: results,
-		Meta: &model.ResponseMeta{
-			Count:  len(results),
-			TookMs: float64(took.Microseconds()) / 1000.0,
-		},
+	// Procedures report no column order, so CSV and TSV columns are sorted.
+	writeRecords(w, r, format, procName, nil, results, &model.ResponseMeta{
+		Count:  len(results),
+		TookMs: float64(took.Microseconds()) / 1000.0,
 	})
 }
@@ -0,0 +1,418 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// primaryKey returns the primary key columns of a table, falling back to the
+// conventional "id" column when the table declares none or cannot be
+// introspected.
+func (h *TableHandler) primaryKey(ctx context.Context, serviceName, tableName string) []string {
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil || ts == nil || len(ts.PrimaryKey) == 0 {
+		return []string{"id"}
+	}
+	return ts.PrimaryKey
+}
+
+// resolveIDs normalizes ids against the key columns and coerces every value
+// to its column type. With a single key column each ID is a scalar. With a
+// composite key each ID must be an array of values in key order or an object
+// naming every key column; it is returned as a []interface{} tuple, the form
+// expected by connector.BuildKeyClause.
+func resolveIDs(keyColumns []string, ids []interface{}, cols query.ColumnSet) ([]interface{}, error) {
+	if len(keyColumns) == 0 {
+		keyColumns = []string{"id"}
+	}
+	for _, col := range keyColumns {
+		if err := cols.Check(col); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]interface{}, len(ids))
+	for i, id := range ids {
+		if len(keyColumns) == 1 {
+			v, err := cols.Coerce(keyColumns[0], id)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+			continue
+		}
+
+		var parts []interface{}
+		switch v := id.(type) {
+		case []interface{}:
+			parts = v
+		case map[string]interface{}:
+			if key, ok := recordKey(v, keyColumns); ok {
+				parts = key.([]interface{})
+			}
+		}
+		if len(parts) != len(keyColumns) {
+			return nil, fmt.Errorf("id %v does not match the primary key (%s)", id, strings.Join(keyColumns, ", "))
+		}
+		tuple := make([]interface{}, len(parts))
+		for j, part := range parts {
+			v, err := cols.Coerce(keyColumns[j], part)
+			if err != nil {
+				return nil, err
+			}
+			tuple[j] = v
+		}
+		out[i] = tuple
+	}
+	return out, nil
+}
+
+// recordKey returns the primary key of a record: the value of the single key
+// column, or a []interface{} tuple for a composite key. ok is false unless
+// the record carries every key column.
+func recordKey(record map[string]interface{}, keyColumns []string) (interface{}, bool) {
+	if len(keyColumns) == 0 {
+		keyColumns = []string{"id"}
+	}
+	tuple := make([]interface{}, len(keyColumns))
+	for i, col := range keyColumns {
+		v, ok := record[col]
+		if !ok || v == nil {
+			return nil, false
+		}
+		tuple[i] = v
+	}
+	if len(tuple) == 1 {
+		return tuple[0], true
+	}
+	return tuple, true
+}
+
+// recordKeyParam returns the {id} path segment of a single-record route still
+// escaped, so that parseRecordKey can split it before unescaping each value
+// once. chi matches routes on URL.RawPath when the request has one, which
+// leaves parameters escaped; otherwise it matches on URL.Path, whose
+// segments are already unescaped and can contain no escaped comma.
+func recordKeyParam(r *http.Request) string {
+	id := chi.URLParam(r, "id")
+	if r.URL.RawPath == "" {
+		return strings.ReplaceAll(id, "%", "%25")
+	}
+	return id
+}
+
+// parseRecordKey decodes the escaped {id} path segment of a single-record
+// route (see recordKeyParam). Composite keys list their values in primary
+// key order separated by commas (e.g. /_table/stock/42,ABC-1); a comma
+// inside a value is escaped as %2C.
+func parseRecordKey(raw string, keyColumns []string) (interface{}, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != len(keyColumns) {
+		return nil, fmt.Errorf("expected %d comma-separated key values (%s), got %d",
+			len(keyColumns), strings.Join(keyColumns, ", "), len(parts))
+	}
+	tuple := make([]interface{}, len(parts))
+	for i, part := range parts {
+		v, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("malformed key value %q", part)
+		}
+		tuple[i] = v
+	}
+	if len(tuple) == 1 {
+		return tuple[0], nil
+	}
+	return tuple, nil
+}
+
+// recordTarget resolves the service connection and primary key addressed by a
+// single-record route, writing the error response and returning ok=false on
+// failure. The returned key is coerced to the key column types.
+func (h *TableHandler) recordTarget(w http.ResponseWriter, r *http.Request) (conn connector.Connector, keyColumns []string, key interface{}, ok bool) {
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return nil, nil, nil, false
+	}
+
+	keyColumns = h.primaryKey(r.Context(), serviceName, tableName)
+	key, err = parseRecordKey(recordKeyParam(r), keyColumns)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid record id: "+err.Error())
+		return nil, nil, nil, false
+	}
+	ids, err := resolveIDs(keyColumns, []interface{}{key}, h.tableColumns(r.Context(), serviceName, tableName))
+	if err != nil {
+		writeQueryError(w, err)
+		return nil, nil, nil, false
+	}
+	return conn, keyColumns, ids[0], true
+}
+
+// recordSelect compiles the SELECT for the record with the given primary key.
+// fields optionally restricts the returned columns.
+func (h *TableHandler) recordSelect(r *http.Request, conn connector.Connector, keyColumns []string, key interface{}, fields string) (connector.SelectRequest, error) {
+	return h.compileQuery(r.Context(), conn, chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName"), recordQuery{
+		Fields:     fields,
+		IDs:        []interface{}{key},
+		KeyColumns: keyColumns,
+	})
+}
+
+// fetchRecord runs a single-record SELECT on exec, returning nil when no row
+// matches. The row is encoded with enc, or returned as scanned when enc is
+// nil.
+func fetchRecord(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, enc *connector.ValueEncoder, selectReq connector.SelectRequest) (map[string]interface{}, error) {
+	sqlStr, args, err := conn.BuildSelect(ctx, selectReq)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.QueryxContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	row := make(map[string]interface{})
+	if err := rows.MapScan(row); err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		enc.EncodeRow(row)
+	}
+	return row, nil
+}
+
+// queryRows runs a SELECT on exec and returns every row, encoded for the
+// columns of the table it reads. It is meant for bounded reads that are
+// post-processed before they are written, such as embedded related rows.
+func (h *TableHandler) queryRows(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, serviceName string, selectReq connector.SelectRequest) ([]map[string]interface{}, error) {
+	sqlStr, args, err := conn.BuildSelect(ctx, selectReq)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := exec.QueryxContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enc := h.encoder(ctx, conn, serviceName, selectReq.Table)
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		enc.EncodeRow(row)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// countSelect counts the rows matching the filter of selectReq.
+func countSelect(ctx context.Context, conn connector.Connector, selectReq connector.SelectRequest) (int64, error) {
+	countSQL, countArgs, err := conn.BuildCount(ctx, connector.CountRequest{
+		Table:  selectReq.Table,
+		Filter: selectReq.Filter,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	allCountArgs := append(append([]interface{}{}, selectReq.FilterArgs...), countArgs...)
+	err = conn.DB().QueryRowxContext(ctx, countSQL, allCountArgs...).Scan(&count)
+	return count, err
+}
+
+// GetRecord retrieves a single record by primary key.
+// GET /api/v1/{serviceName}/_table/{tableName}/{id}
+//
+// Composite keys are passed as comma-separated values in key order. The
+// fields query parameter restricts the returned columns. The response carries
+// the record's ETag, and If-None-Match yields 304 while it is unchanged.
+func (h *TableHandler) GetRecord(w http.ResponseWriter, r *http.Request) {
+	conn, keyColumns, key, ok := h.recordTarget(w, r)
+	if !ok {
+		return
+	}
+
+	selectReq, err := h.recordSelect(r, conn, keyColumns, key, queryString(r, "fields"))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if dryRunRequested(r) {
+		dryRunSelect(w, r, conn, selectReq)
+		return
+	}
+	enc := h.encoder(r.Context(), conn, chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName"))
+	record, err := fetchRecord(r.Context(), conn.DB(), conn, enc, selectReq)
+	if err != nil {
+		code, msg := classifyDBError(err, "Query failed")
+		writeError(w, code, msg)
+		return
+	}
+	if record == nil {
+		writeError(w, http.StatusNotFound, "Record not found: "+chi.URLParam(r, "id"))
+		return
+	}
+
+	// A field selection changes the row hash, so it is only tagged when the
+	// ETag comes from a version column.
+	versionCol := h.versionColumn(r.Context(), chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName"))
+	if versionCol != "" || queryString(r, "fields") == "" {
+		if etag, ok := recordETag(record, versionCol); ok {
+			w.Header().Set("ETag", etag)
+			if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// UpdateRecord updates a single record by primary key and returns the
+// updated record. Key fields in the body are ignored; the path names the row.
+// With If-Match the update only applies while the record's ETag matches;
+// otherwise it fails with 412.
+// PUT   /api/v1/{serviceName}/_table/{tableName}/{id}
+// PATCH /api/v1/{serviceName}/_table/{tableName}/{id}
+func (h *TableHandler) UpdateRecord(w http.ResponseWriter, r *http.Request) {
+	conn, keyColumns, key, ok := h.recordTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var record map[string]interface{}
+	if err := readJSON(r, &record); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	for _, col := range keyColumns {
+		delete(record, col)
+	}
+	if len(record) == 0 {
+		writeError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+	serviceName, tableName := chi.URLParam(r, "serviceName"), chi.URLParam(r, "tableName")
+	if err := h.prepareUpdate(r.Context(), serviceName, tableName, record); err != nil {
+		writePrepareError(w, err)
+		return
+	}
+
+	// A dry run previews the UPDATE alone; If-Match is not evaluated.
+	if dryRunRequested(r) {
+		sqlStr, args, err := buildSingleUpdate(r.Context(), conn, tableName, record, "", keyColumns, []interface{}{key})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to build update: "+err.Error())
+			return
+		}
+		execDryRun(w, r, conn, "Update failed", sqlStatement{sqlStr, args, returnsRows(conn)})
+		return
+	}
+
+	if r.Header.Get("If-Match") != "" {
+		h.updateRecordIfMatch(w, r, conn, keyColumns, key, record)
+		return
+	}
+
+	enc := h.encoder(r.Context(), conn, serviceName, tableName)
+	updated, err := execSingleUpdate(r.Context(), conn.DB(), conn, enc, tableName, record, "", keyColumns, []interface{}{key})
+	if err != nil {
+		code, msg := classifyDBError(err, "Update failed")
+		writeError(w, code, msg)
+		return
+	}
+
+	// Without RETURNING the update reports nothing useful (MySQL does not
+	// even count rows whose values were unchanged), so read the row back.
+	if !conn.SupportsReturning() {
+		selectReq, err := h.recordSelect(r, conn, keyColumns, key, "")
+		if err == nil {
+			updated, err = fetchRecord(r.Context(), conn.DB(), conn, enc, selectReq)
+		}
+		if err != nil {
+			code, msg := classifyDBError(err, "Query failed")
+			writeError(w, code, msg)
+			return
+		}
+	}
+	if len(updated) == 0 {
+		writeError(w, http.StatusNotFound, "Record not found: "+chi.URLParam(r, "id"))
+		return
+	}
+	if etag, ok := recordETag(updated, h.versionColumn(r.Context(), serviceName, tableName)); ok {
+		w.Header().Set("ETag", etag)
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRecord deletes a single record by primary key and returns its key.
+// With If-Match the delete only happens while the record's ETag matches;
+// otherwise it fails with 412.
+// DELETE /api/v1/{serviceName}/_table/{tableName}/{id}
+func (h *TableHandler) DeleteRecord(w http.ResponseWriter, r *http.Request) {
+	conn, keyColumns, key, ok := h.recordTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("If-Match") != "" && !dryRunRequested(r) {
+		if !h.deleteRecordIfMatch(w, r, conn, keyColumns, key) {
+			return
+		}
+	} else {
+		sqlStr, args, err := conn.BuildDelete(r.Context(), connector.DeleteRequest{
+			Table:      chi.URLParam(r, "tableName"),
+			IDs:        []interface{}{key},
+			KeyColumns: keyColumns,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to build delete: "+err.Error())
+			return
+		}
+		// A dry run previews the DELETE alone; If-Match is not evaluated.
+		if dryRunRequested(r) {
+			execDryRun(w, r, conn, "Delete failed", sqlStatement{sqlStr, args, false})
+			return
+		}
+
+		result, err := conn.DB().ExecContext(r.Context(), sqlStr, args...)
+		if err != nil {
+			code, msg := classifyDBError(err, "Delete failed")
+			writeError(w, code, msg)
+			return
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			writeError(w, http.StatusNotFound, "Record not found: "+chi.URLParam(r, "id"))
+			return
+		}
+	}
+
+	deleted := make(map[string]interface{}, len(keyColumns))
+	if len(keyColumns) == 1 {
+		deleted[keyColumns[0]] = key
+	} else {
+		for i, col := range keyColumns {
+			deleted[col] = key.([]interface{})[i]
+		}
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
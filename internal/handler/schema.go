@@ -130,10 +130,21 @@ func (h *SchemaHandler) CreateTable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if dryRunRequested(r) {
+		stmt, err := conn.BuildCreateTable(def)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid table definition: "+err.Error())
+			return
+		}
+		dryRunDDL(w, r, conn, "Failed to create table", []string{stmt})
+		return
+	}
+
 	if err := conn.CreateTable(r.Context(), def); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to create table: "+err.Error())
 		return
 	}
+	h.registry.InvalidateSchema(serviceName, def.Name)
 
 	// Return the schema of the newly created table.
 	created, err := conn.IntrospectTable(r.Context(), def.Name)
@@ -232,10 +243,21 @@ func (h *SchemaHandler) AlterTable(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if dryRunRequested(r) {
+		stmts, err := conn.BuildAlterTable(tableName, changes)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid schema change: "+err.Error())
+			return
+		}
+		dryRunDDL(w, r, conn, "Failed to alter table", stmts)
+		return
+	}
+
 	if err := conn.AlterTable(r.Context(), tableName, changes); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to alter table: "+err.Error())
 		return
 	}
+	h.registry.InvalidateSchema(serviceName, tableName)
 
 	// Return the updated table schema.
 	updated, err := conn.IntrospectTable(r.Context(), tableName)
@@ -287,10 +309,21 @@ func (h *SchemaHandler) DropTable(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if dryRunRequested(r) {
+		stmt, err := conn.BuildDropTable(tableName)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid table: "+err.Error())
+			return
+		}
+		dryRunDDL(w, r, conn, "Failed to drop table", []string{stmt})
+		return
+	}
+
 	if err := conn.DropTable(r.Context(), tableName); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to drop table: "+err.Error())
 		return
 	}
+	h.registry.InvalidateSchema(serviceName, tableName)
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/contract"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// serviceSchemaTTL bounds how long a service schema snapshot is served
+// without re-introspecting the database. DDL issued through the API and
+// contract changes refresh it on the next request; the TTL covers changes
+// made directly against the database.
+const serviceSchemaTTL = time.Minute
+
+// serviceSchemas caches whole-service schema snapshots for the handlers that
+// generate an API from one (GraphQL, OData). Snapshots carry the locked
+// contract definitions in place of the live tables when schema locking is
+// enabled, the same shapes the OpenAPI spec describes.
+type serviceSchemas struct {
+	registry *connector.Registry
+	store    *config.Store
+
+	mu      sync.Mutex
+	entries map[string]*serviceSchema // by service name
+}
+
+// serviceSchema is a schema snapshot and what it was taken from.
+type serviceSchema struct {
+	schema    *model.Schema
+	digest    string    // hash of schema, ignoring row counts
+	version   uint64    // registry schema version at the last check
+	contracts string    // lock mode and contract versions at the last check
+	checked   time.Time // when the database was last introspected
+}
+
+func newServiceSchemas(registry *connector.Registry, store *config.Store) *serviceSchemas {
+	return &serviceSchemas{
+		registry: registry,
+		store:    store,
+		entries:  make(map[string]*serviceSchema),
+	}
+}
+
+// get returns the current snapshot of a service's schema. It is refreshed
+// when the registry invalidates the service's schema (DDL through the API),
+// when contracts are locked, promoted or removed, and after serviceSchemaTTL.
+// Callers compare digests to tell whether a refresh changed anything.
+func (c *serviceSchemas) get(ctx context.Context, conn connector.Connector, serviceName string) (*serviceSchema, error) {
+	var contracts []contract.Contract
+	var contractsKey string
+	if c.store != nil {
+		if svc, err := c.store.GetServiceByName(ctx, serviceName); err == nil {
+			if svc.SchemaLock == "auto" || svc.SchemaLock == "strict" {
+				contracts, err = c.store.ListContracts(ctx, serviceName)
+				if err != nil {
+					return nil, err
+				}
+				contractsKey = contractsFingerprint(svc.SchemaLock, contracts)
+			}
+		}
+	}
+	version := c.registry.SchemaVersion(serviceName)
+
+	c.mu.Lock()
+	cached := c.entries[serviceName]
+	c.mu.Unlock()
+	if cached != nil && cached.version == version && cached.contracts == contractsKey &&
+		time.Since(cached.checked) < serviceSchemaTTL {
+		return cached, nil
+	}
+
+	schema, err := conn.IntrospectSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schema = lockedSchema(schema, contracts)
+	entry := &serviceSchema{
+		schema:    schema,
+		digest:    schemaDigest(schema),
+		version:   version,
+		contracts: contractsKey,
+		checked:   time.Now(),
+	}
+
+	c.mu.Lock()
+	c.entries[serviceName] = entry
+	c.mu.Unlock()
+	return entry, nil
+}
+
+// contractsFingerprint identifies a lock mode and the versions of a
+// service's contracts.
+func contractsFingerprint(mode string, contracts []contract.Contract) string {
+	parts := []string{mode}
+	for _, c := range contracts {
+		v := c.LockedAt.UnixNano()
+		if c.PromotedAt != nil {
+			v = c.PromotedAt.UnixNano()
+		}
+		parts = append(parts, fmt.Sprintf("%s@%d", c.TableName, v))
+	}
+	sort.Strings(parts[1:])
+	return strings.Join(parts, ",")
+}
+
+// schemaDigest hashes the tables and views of a schema. Row counts are left
+// out so that they do not count as a change.
+func schemaDigest(schema *model.Schema) string {
+	shape := struct {
+		Tables []model.TableSchema
+		Views  []model.TableSchema
+	}{}
+	for _, list := range []struct {
+		in  []model.TableSchema
+		out *[]model.TableSchema
+	}{{schema.Tables, &shape.Tables}, {schema.Views, &shape.Views}} {
+		for _, t := range list.in {
+			t.RowCount = nil
+			*list.out = append(*list.out, t)
+		}
+	}
+	b, _ := json.Marshal(shape)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
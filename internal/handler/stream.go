@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// listStream writes a model.ListResponse envelope incrementally, so list
+// responses of any size are served in bounded memory. Records are encoded as
+// they are written and the meta object, which depends on all of them, comes
+// last:
+//
+//	{"resource":[{...},{...}],"meta":{...}}
+//
+// Nothing is sent before the first record, so an error that occurs before
+// then still gets a regular error response and status.
+type listStream struct {
+	w       http.ResponseWriter
+	started bool
+	count   int
+	err     error // first write error; the client has gone away
+}
+
+func newListStream(w http.ResponseWriter) *listStream {
+	return &listStream{w: w}
+}
+
+func (s *listStream) begin() {
+	if s.started {
+		return
+	}
+	s.started = true
+	clearWriteDeadline(s.w)
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+	s.writeString(`{"resource":[`)
+}
+
+func (s *listStream) writeString(str string) {
+	if s.err == nil {
+		_, s.err = s.w.Write([]byte(str))
+	}
+}
+
+// write appends one record to the resource array. It returns the first
+// write error, after which the caller should stop producing records.
+func (s *listStream) write(record map[string]interface{}) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.begin()
+	if s.count > 0 {
+		s.writeString(",")
+	}
+	s.count++
+	if s.err == nil {
+		_, s.err = s.w.Write(b)
+	}
+	return s.err
+}
+
+// end closes the resource array and writes meta.
+func (s *listStream) end(meta *model.ResponseMeta) {
+	s.begin()
+	b, _ := json.Marshal(meta)
+	s.writeString(`],"meta":`)
+	s.writeString(string(b))
+	s.writeString("}\n")
+}
+
+// clearWriteDeadline lifts the server's write timeout from a streamed
+// response, whose duration grows with the result rather than being fixed.
+func clearWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// fail reports an error. Before the first record it writes a regular error
+// response; afterwards the status has been sent, so the resource array is
+// closed and the envelope ends with an "error" member in place of "meta".
+func (s *listStream) fail(code int, message string) {
+	if !s.started {
+		writeError(s.w, code, message)
+		return
+	}
+	b, _ := json.Marshal(model.ErrorDetail{Code: code, Message: message})
+	s.writeString(`],"error":`)
+	s.writeString(string(b))
+	s.writeString("}\n")
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+)
+
+// ---------------------------------------------------------------------------
+// Streamed JSON list responses
+// ---------------------------------------------------------------------------
+
+func TestQueryRecords_StreamedEnvelope(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users?order=id&limit=1&include_count=true&include_etags=true", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	var resp model.ListResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	if len(resp.Resource) != 1 || resp.Resource[0]["name"] != "Alice" {
+		t.Errorf("unexpected records %v", resp.Resource)
+	}
+	if resp.Meta == nil || resp.Meta.Count != 1 || resp.Meta.Total == nil || *resp.Meta.Total != 2 {
+		t.Fatalf("unexpected meta %+v", resp.Meta)
+	}
+	if etag, _ := resp.Resource[0]["_etag"].(string); etag == "" {
+		t.Errorf("expected the record's etag, got %v", resp.Resource[0])
+	}
+
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/users?filter=id%3D99", nil)
+	if got := rr.Body.String(); len(got) < 15 || got[:15] != `{"resource":[],` {
+		t.Errorf("expected an empty resource array, got %s", got)
+	}
+}
+
+func TestListStream_FailAfterStart(t *testing.T) {
+	rr := httptest.NewRecorder()
+	stream := newListStream(rr)
+	stream.write(map[string]interface{}{"id": 1})
+	stream.fail(http.StatusInternalServerError, "Row iteration error: boom")
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the status already sent, got %d", rr.Code)
+	}
+	var resp struct {
+		Resource []map[string]interface{} `json:"resource"`
+		Meta     *model.ResponseMeta      `json:"meta"`
+		Error    *model.ErrorDetail       `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rr.Body.String())
+	}
+	if len(resp.Resource) != 1 || resp.Meta != nil || resp.Error == nil || resp.Error.Code != 500 {
+		t.Errorf("unexpected envelope %s", rr.Body.String())
+	}
+}
+
+// deadlineRecorder records whether a handler cleared its write deadline
+// through http.ResponseController.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	cleared bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	d.cleared = deadline.IsZero()
+	return nil
+}
+
+func TestQueryRecords_StreamsClearWriteDeadline(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	for _, query := range []string{"", "?format=ndjson", "?format=csv", "?format=arrow", "?format=parquet"} {
+		rr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		env.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/testdb/_table/users"+query, nil))
+		if rr.Code != http.StatusOK || !rr.cleared {
+			t.Errorf("%q: expected 200 with the write deadline cleared, got %d (cleared %v)", query, rr.Code, rr.cleared)
+		}
+	}
+}
+
+func TestQueryRecords_RoleMaxLimit(t *testing.T) {
+	env := newBatchTestEnv(t)
+	ctx := context.Background()
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(ctx, `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1500)
+		INSERT INTO users (name, email) SELECT 'user' || i, 'user' || i || '@example.com' FROM n`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	roleKey := func(name string, maxLimit int) *middleware.Principal {
+		role := &model.Role{Name: name, IsActive: true, MaxLimit: maxLimit}
+		if err := env.store.CreateRole(ctx, role); err != nil {
+			t.Fatalf("CreateRole: %v", err)
+		}
+		if err := env.store.SetRoleAccess(ctx, role.ID, []model.RoleAccess{
+			{ServiceName: "testdb", Component: "_table/*", VerbMask: model.VerbGet},
+		}); err != nil {
+			t.Fatalf("SetRoleAccess: %v", err)
+		}
+		return &middleware.Principal{Type: "api_key", RoleID: role.ID}
+	}
+
+	for _, tt := range []struct {
+		name string
+		p    *middleware.Principal
+		want int
+	}{
+		{"default", roleKey("reader", 0), 1000},
+		{"raised", roleKey("exporter", 5000), 1500},
+		{"lowered", roleKey("sampler", 10), 10},
+		{"admin", &middleware.Principal{Type: "admin", IsAdmin: true}, 1000},
+	} {
+		rr := env.doAs(t, tt.p, "GET", "/api/v1/testdb/_table/users?limit=5000", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d; body: %s", tt.name, rr.Code, rr.Body.String())
+		}
+		var resp model.ListResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if len(resp.Resource) != tt.want {
+			t.Errorf("%s: expected %d records, got %d (meta %+v)", tt.name, tt.want, len(resp.Resource), resp.Meta)
+		}
+	}
+}
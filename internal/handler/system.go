@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -159,33 +160,9 @@ func (h *SystemHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		writeError(w, http.StatusBadRequest, "Email and password are required")
-		return
-	}
-
-	// Look up the admin by email.
-	admin, err := h.store.GetAdminByEmail(r.Context(), req.Email)
-	if err != nil {
-		if errors.Is(err, config.ErrNotFound) {
-			writeError(w, http.StatusUnauthorized, "Invalid credentials")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "Authentication error: "+err.Error())
-		return
-	}
-
-	if !admin.IsActive {
-		writeError(w, http.StatusUnauthorized, "Account is disabled")
-		return
-	}
-
-	// Verify the password hash. In production the AuthService performs bcrypt
-	// comparison and issues the JWT in one flow. For now, we verify the SHA-256
-	// hash and delegate token issuance to AuthService.IssueJWT.
-	candidateHash := config.HashAPIKey(req.Password)
-	if candidateHash != admin.PasswordHash {
-		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+	admin, status, msg := verifyLogin(r.Context(), h.store, req)
+	if admin == nil {
+		writeError(w, status, msg)
 		return
 	}
 
@@ -209,6 +186,36 @@ func (h *SystemHandler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// verifyLogin checks the credentials of a login request. It returns the
+// admin they belong to, or the status and message to reject them with.
+func verifyLogin(ctx context.Context, store *config.Store, req loginRequest) (*model.Admin, int, string) {
+	if req.Email == "" || req.Password == "" {
+		return nil, http.StatusBadRequest, "Email and password are required"
+	}
+
+	// Look up the admin by email.
+	admin, err := store.GetAdminByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, config.ErrNotFound) {
+			return nil, http.StatusUnauthorized, "Invalid credentials"
+		}
+		return nil, http.StatusInternalServerError, "Authentication error: " + err.Error()
+	}
+
+	if !admin.IsActive {
+		return nil, http.StatusUnauthorized, "Account is disabled"
+	}
+
+	// Verify the password hash. In production the AuthService performs bcrypt
+	// comparison and issues the JWT in one flow. For now, we verify the SHA-256
+	// hash and delegate token issuance to AuthService.IssueJWT.
+	candidateHash := config.HashAPIKey(req.Password)
+	if candidateHash != admin.PasswordHash {
+		return nil, http.StatusUnauthorized, "Invalid credentials"
+	}
+	return admin, 0, ""
+}
+
 // Logout invalidates the current session. Since JWTs are stateless, this is
 // a no-op on the server side. Clients should discard their token.
 // DELETE /api/v1/system/admin/session
@@ -278,6 +285,14 @@ func (h *SystemHandler) CreateService(w http.ResponseWriter, r *http.Request) {
 	if svc.SchemaLock == "" {
 		svc.SchemaLock = "none"
 	}
+	if err := checkColumnRules(svc.ColumnRules); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid column_rules: "+err.Error())
+		return
+	}
+	if !connector.IsNumericFormat(svc.NumericFormat) {
+		writeError(w, http.StatusBadRequest, "Invalid numeric_format: use number or string")
+		return
+	}
 
 	// Sanitize the DSN to ensure special characters in passwords are properly
 	// URL-encoded for URL-style DSNs (postgres://, sqlserver://).
@@ -363,9 +378,28 @@ func (h *SystemHandler) UpdateService(w http.ResponseWriter, r *http.Request) {
 	if updates.Schema != "" {
 		existing.Schema = updates.Schema
 	}
+	if updates.VersionColumn != "" {
+		existing.VersionColumn = updates.VersionColumn
+	}
+	if updates.NumericFormat != "" {
+		if !connector.IsNumericFormat(updates.NumericFormat) {
+			writeError(w, http.StatusBadRequest, "Invalid numeric_format: use number or string")
+			return
+		}
+		existing.NumericFormat = updates.NumericFormat
+	}
+	if updates.ColumnRules != nil {
+		if err := checkColumnRules(updates.ColumnRules); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid column_rules: "+err.Error())
+			return
+		}
+		existing.ColumnRules = updates.ColumnRules
+	}
 	existing.ReadOnly = updates.ReadOnly
 	existing.RawSQL = updates.RawSQL
 	existing.IsActive = updates.IsActive
+	existing.ChangeStreams = updates.ChangeStreams
+	existing.PostgREST = updates.PostgREST
 
 	if err := h.store.UpdateService(r.Context(), existing); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to update service: "+err.Error())
@@ -507,6 +541,10 @@ func (h *SystemHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Role name is required")
 		return
 	}
+	if role.MaxLimit < 0 {
+		writeError(w, http.StatusBadRequest, "max_limit must not be negative")
+		return
+	}
 
 	role.IsActive = true
 	if role.Access == nil {
@@ -584,6 +622,13 @@ func (h *SystemHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
 	if updates.Description != "" {
 		existing.Description = updates.Description
 	}
+	if updates.MaxLimit < 0 {
+		writeError(w, http.StatusBadRequest, "max_limit must not be negative")
+		return
+	}
+	if updates.MaxLimit != 0 {
+		existing.MaxLimit = updates.MaxLimit
+	}
 	existing.IsActive = updates.IsActive
 
 	if err := h.store.UpdateRole(r.Context(), existing); err != nil {
@@ -742,9 +787,10 @@ func (h *SystemHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 
 // createAPIKeyRequest is the expected payload for CreateAPIKey.
 type createAPIKeyRequest struct {
-	Label     string     `json:"label"`
-	RoleID    int64      `json:"role_id"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Label     string            `json:"label"`
+	RoleID    int64             `json:"role_id"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
 }
 
 // createAPIKeyResponse includes the plaintext key (shown once only).
@@ -752,11 +798,12 @@ type createAPIKeyResponse struct {
 	ID        int64      `json:"id"`
 	Key       string     `json:"api_key"` // Plaintext, shown ONCE.
 	KeyPrefix string     `json:"key_prefix"`
-	Label     string     `json:"label"`
-	RoleID    int64      `json:"role_id"`
-	IsActive  bool       `json:"is_active"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	Label     string            `json:"label"`
+	RoleID    int64             `json:"role_id"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	IsActive  bool              `json:"is_active"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
 }
 
 // CreateAPIKey generates a new API key, hashes it, stores the hash, and
@@ -801,6 +848,7 @@ func (h *SystemHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		KeyPrefix: keyPrefix,
 		Label:     req.Label,
 		RoleID:    req.RoleID,
+		Metadata:  req.Metadata,
 		IsActive:  true,
 		ExpiresAt: req.ExpiresAt,
 	}
@@ -817,6 +865,7 @@ func (h *SystemHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		KeyPrefix: keyPrefix,
 		Label:     apiKey.Label,
 		RoleID:    apiKey.RoleID,
+		Metadata:  apiKey.Metadata,
 		IsActive:  apiKey.IsActive,
 		ExpiresAt: apiKey.ExpiresAt,
 		CreatedAt: apiKey.CreatedAt,
@@ -886,15 +935,7 @@ func (h *SystemHandler) MCPInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Derive the MCP endpoint URL from the incoming request.
-	scheme := "http"
-	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
-		scheme = "https"
-	}
-	host := r.Host
-	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
-		host = fwd
-	}
-	mcpEndpoint := fmt.Sprintf("%s://%s/mcp", scheme, host)
+	mcpEndpoint := requestOrigin(r) + "/mcp"
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"server_name":    "Faucet Database API",
@@ -933,12 +974,19 @@ func serviceToMap(svc *model.ServiceConfig) map[string]interface{} {
 		"raw_sql_allowed": svc.RawSQL,
 		"is_active":       svc.IsActive,
 		"schema_lock":     svc.SchemaLock,
+		"version_column":  svc.VersionColumn,
+		"numeric_format":  svc.NumericFormat,
+		"change_streams":  svc.ChangeStreams,
+		"postgrest":       svc.PostgREST,
 		"created_at":      svc.CreatedAt,
 		"updated_at":      svc.UpdatedAt,
 	}
 	if svc.PrivateKeyPath != "" {
 		m["private_key_path"] = svc.PrivateKeyPath
 	}
+	if len(svc.ColumnRules) > 0 {
+		m["column_rules"] = svc.ColumnRules
+	}
 	return m
 }
 
@@ -948,6 +996,7 @@ func roleToMap(role *model.Role) map[string]interface{} {
 		"name":        role.Name,
 		"description": role.Description,
 		"is_active":   role.IsActive,
+		"max_limit":   role.MaxLimit,
 		"access":      role.Access,
 		"created_at":  role.CreatedAt,
 		"updated_at":  role.UpdatedAt,
@@ -985,5 +1034,8 @@ func apiKeyToMap(key *model.APIKey) map[string]interface{} {
 	if key.LastUsed != nil {
 		m["last_used"] = key.LastUsed
 	}
+	if len(key.Metadata) > 0 {
+		m["metadata"] = key.Metadata
+	}
 	return m
 }
@@ -232,6 +232,7 @@ func TestCreateService_Validation(t *testing.T) {
 		{"missing name", map[string]interface{}{"driver": "postgres", "dsn": "postgres://localhost/test"}},
 		{"missing driver", map[string]interface{}{"name": "test", "dsn": "postgres://localhost/test"}},
 		{"missing dsn", map[string]interface{}{"name": "test", "driver": "postgres"}},
+		{"invalid numeric_format", map[string]interface{}{"name": "test", "driver": "sqlite", "dsn": ":memory:", "numeric_format": "float"}},
 	}
 
 	for _, tt := range tests {
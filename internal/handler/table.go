@@ -3,7 +3,9 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/export"
 	"github.com/faucetdb/faucet/internal/model"
 	"github.com/faucetdb/faucet/internal/query"
 )
@@ -67,70 +70,299 @@ func (h *TableHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters.
-	filterStr := queryString(r, "filter")
-	fieldsStr := queryString(r, "fields")
-	groupStr := queryString(r, "group")
-	orderStr := queryString(r, "order")
-	idsStr := queryString(r, "ids")
-	limit := clampInt(queryInt(r, "limit", 25), 0, 1000)
-	offset := queryInt(r, "offset", 0)
-	includeCount := queryBool(r, "include_count")
+	q := h.recordQueryParams(r)
+	// Columnar exports default to a full page rather than 25 rows.
+	if export.IsFormat(acceptedFormat(r)) {
+		q.Limit = exportLimit(r.Context(), h.store, queryInt(r, "limit", 0))
+	}
+
+	selectReq, err := h.compileQuery(r.Context(), conn, serviceName, tableName, q)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	h.executeSelect(w, r, conn, selectReq, q, start)
+}
+
+// recordQueryParams reads the query-string parameters of a read. limit
+// defaults to 25 and is capped at the principal's max limit.
+func (h *TableHandler) recordQueryParams(r *http.Request) recordQuery {
+	q := recordQuery{
+		Filter:       queryString(r, "filter"),
+		Fields:       queryString(r, "fields"),
+		Group:        queryString(r, "group"),
+		Having:       queryString(r, "having"),
+		Order:        queryString(r, "order"),
+		Distinct:     queryBool(r, "distinct"),
+		Limit:        clampInt(queryInt(r, "limit", 25), 0, maxLimit(r.Context(), h.store)),
+		Offset:       queryInt(r, "offset", 0),
+		IncludeCount: queryBool(r, "include_count"),
+		IncludeETags: queryBool(r, "include_etags"),
+	}
+	if idsStr := queryString(r, "ids"); idsStr != "" {
+		for _, id := range strings.Split(idsStr, ",") {
+			q.IDs = append(q.IDs, strings.TrimSpace(id))
+		}
+	}
+	return q
+}
+
+// exportLimit returns the row limit of an Arrow or Parquet read: limit when
+// it is positive, otherwise the principal's max limit, and never more than
+// it. Larger exports run as export jobs.
+func exportLimit(ctx context.Context, store *config.Store, limit int) int {
+	max := maxLimit(ctx, store)
+	if limit <= 0 || limit > max {
+		return max
+	}
+	return limit
+}
+
+// QueryRecordsJSON retrieves records using a structured JSON query instead of
+// query-string parameters, so typed clients never have to build and quote
+// filter strings. The body compiles to the same SelectRequest as QueryRecords.
+// POST /api/v1/{serviceName}/_table/{tableName}/_query
+//
+//	{
+//	  "where":  {"and": [{"age": {"gt": 21}}, {"status": "active"}]},
+//	  "fields": ["id", "name"],
+//	  "order":  ["name ASC"],
+//	  "limit":  50,
+//	  "cursor": "<meta.next_cursor from the previous page>"
+//	}
+func (h *TableHandler) QueryRecordsJSON(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	serviceName := chi.URLParam(r, "serviceName")
+	tableName := chi.URLParam(r, "tableName")
+
+	conn, err := h.registry.Get(serviceName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
+		return
+	}
+
+	var body queryBody
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&body)
+	r.Body.Close()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query body: "+err.Error())
+		return
+	}
+
+	q := recordQuery{
+		Where:        body.Where,
+		Fields:       strings.Join(body.Fields, ","),
+		Group:        strings.Join(body.Group, ","),
+		Having:       body.Having,
+		Order:        strings.Join(body.Order, ","),
+		IDs:          normalizeJSONIDs(body.IDs),
+		Distinct:     body.Distinct,
+		Limit:        25,
+		Offset:       body.Offset,
+		IncludeCount: body.IncludeCount,
+		IncludeETags: body.IncludeETags,
+		Paginate:     true,
+	}
+	if body.Limit != nil {
+		q.Limit = clampInt(*body.Limit, 0, maxLimit(r.Context(), h.store))
+	}
+	if export.IsFormat(acceptedFormat(r)) {
+		limit := 0
+		if body.Limit != nil {
+			limit = *body.Limit
+		}
+		q.Limit = exportLimit(r.Context(), h.store, limit)
+	}
+	if body.Cursor != "" {
+		offset, err := decodeCursor(body.Cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cursor: "+err.Error())
+			return
+		}
+		q.Offset = offset
+	}
+
+	selectReq, err := h.compileQuery(r.Context(), conn, serviceName, tableName, q)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	h.executeSelect(w, r, conn, selectReq, q, start)
+}
+
+// queryBody is the JSON body accepted by QueryRecordsJSON.
+type queryBody struct {
+	Where        json.RawMessage `json:"where"`
+	Fields       []string        `json:"fields"`
+	Order        []string        `json:"order"`
+	Group        []string        `json:"group"`
+	Having       string          `json:"having"`
+	Distinct     bool            `json:"distinct"`
+	IDs          []interface{}   `json:"ids"`
+	Limit        *int            `json:"limit"`
+	Offset       int             `json:"offset"`
+	Cursor       string          `json:"cursor"`
+	IncludeCount bool            `json:"include_count"`
+	IncludeETags bool            `json:"include_etags"`
+}
+
+// recordQuery describes a read against a table independently of how it was
+// expressed on the wire (query string or JSON body).
+type recordQuery struct {
+	Filter       string          // DreamFactory-style filter string
+	Where        json.RawMessage // structured filter (see query.ParseFilterJSON)
+	Fields       string
+	Group        string
+	Having       string
+	Order        string
+	IDs          []interface{}
+	KeyColumns   []string // primary key the IDs refer to; defaults to "id"
+	Distinct     bool
+	Limit        int
+	Offset       int
+	IncludeCount bool
+	IncludeETags bool // add each returned record's ETag as its _etag member
+	Paginate     bool // emit meta.next_cursor when a full page is returned
+}
+
+// schemaRefreshFloor is how old a table's cached schema must be before an
+// unknown column refreshes it.
+var schemaRefreshFloor = 15 * time.Second
+
+// compileQuery compiles q into a SelectRequest, validating every referenced
+// column against the table's cached schema. An unknown column refreshes a
+// schema that is not itself fresh before the query is rejected, so columns
+// added outside the API are picked up without waiting for the cache to
+// expire (see connector.Registry.RefreshSchema).
+func (h *TableHandler) compileQuery(ctx context.Context, conn connector.Connector, serviceName, tableName string, q recordQuery) (connector.SelectRequest, error) {
+	if len(q.IDs) > 0 && q.KeyColumns == nil {
+		q.KeyColumns = h.primaryKey(ctx, serviceName, tableName)
+	}
+	selectReq, err := buildSelectRequest(conn, tableName, q, h.tableColumns(ctx, serviceName, tableName))
+	var unknown *query.UnknownColumnError
+	if errors.As(err, &unknown) && h.registry.RefreshSchema(serviceName, tableName, schemaRefreshFloor) {
+		selectReq, err = buildSelectRequest(conn, tableName, q, h.tableColumns(ctx, serviceName, tableName))
+	}
+	return selectReq, err
+}
+
+// tableColumns returns the column set of a table for schema-aware
+// validation, or nil when the schema cannot be introspected (validation is
+// then skipped and the database reports any problem itself).
+func (h *TableHandler) tableColumns(ctx context.Context, serviceName, tableName string) query.ColumnSet {
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil || ts == nil || len(ts.Columns) == 0 {
+		return nil
+	}
+	cols := make(query.ColumnSet, len(ts.Columns))
+	for _, c := range ts.Columns {
+		cols[c.Name] = c.JsonType
+	}
+	return cols
+}
+
+// writeQueryError writes a 400 for a query that failed to compile. Unknown
+// columns carry the column and any suggested alternative in the error context;
+// records that fail schema validation carry the per-field errors.
+func writeQueryError(w http.ResponseWriter, err error) {
+	var invalid *recordValidationError
+	if errors.As(err, &invalid) {
+		writeError(w, http.StatusBadRequest, err.Error(), invalid.context())
+		return
+	}
+	var unknown *query.UnknownColumnError
+	if errors.As(err, &unknown) {
+		ctx := map[string]interface{}{"column": unknown.Column}
+		if unknown.Suggestion != "" {
+			ctx["suggestion"] = unknown.Suggestion
+		}
+		writeError(w, http.StatusBadRequest, err.Error(), ctx)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// buildSelectRequest validates q and compiles it into a SelectRequest for
+// conn. When cols is non-nil, every referenced column must exist in it and
+// filter and id literals are coerced to their column types. Returned errors
+// are client errors and carry a message suitable for a 400 response.
+func buildSelectRequest(conn connector.Connector, tableName string, q recordQuery, cols query.ColumnSet) (connector.SelectRequest, error) {
+	var err error
 
 	// Parse the projection (plain columns and/or aggregates like SUM(amount)).
 	var projection []query.SelectItem
-	if fieldsStr != "" {
-		projection, err = query.ParseProjection(fieldsStr)
+	if q.Fields != "" {
+		projection, err = query.ParseProjection(q.Fields)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid fields parameter: "+err.Error())
-			return
+			return connector.SelectRequest{}, fmt.Errorf("Invalid fields parameter: %w", err)
 		}
 	}
 
 	// Parse GROUP BY columns.
 	var groupBy []string
-	if groupStr != "" {
-		groupBy, err = query.ParseGroupBy(groupStr)
+	if q.Group != "" {
+		groupBy, err = query.ParseGroupBy(q.Group)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid group parameter: "+err.Error())
-			return
+			return connector.SelectRequest{}, fmt.Errorf("Invalid group parameter: %w", err)
 		}
 	}
 
 	// Reject projections that mix aggregates and plain columns inconsistently,
 	// so the SQL is well-defined and portable across dialects.
 	if err := query.ValidateGroupedProjection(projection, groupBy); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
-		return
+		return connector.SelectRequest{}, err
 	}
 
-	// Parse and parameterize the filter expression.
-	var filterSQL string
-	var filterParams []interface{}
-	if filterStr != "" {
-		phFunc := func(index int) string {
-			return conn.ParameterPlaceholder(index)
-		}
-		parsed, err := query.ParseFilter(filterStr, phFunc, 1)
+	// Parse and validate order clause.
+	var orderClauses []query.OrderClause
+	if q.Order != "" {
+		orderClauses, err = query.ParseOrderClause(q.Order)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid filter: "+err.Error())
-			return
-		}
-		if parsed != nil {
-			filterSQL = parsed.SQL
-			filterParams = parsed.Params
+			return connector.SelectRequest{}, fmt.Errorf("Invalid order parameter: %w", err)
 		}
 	}
 
-	// Apply IDs filter if provided (filters by primary key "id" column).
-	if idsStr != "" {
-		idParts := strings.Split(idsStr, ",")
-		placeholders := make([]string, len(idParts))
-		for i, id := range idParts {
-			placeholders[i] = conn.ParameterPlaceholder(len(filterParams) + i + 1)
-			filterParams = append(filterParams, strings.TrimSpace(id))
+	// Reject columns the table does not have before any SQL is built.
+	if err := cols.CheckQuery(projection, groupBy, orderClauses); err != nil {
+		return connector.SelectRequest{}, err
+	}
+
+	// Parse and parameterize the filter expression, from either grammar.
+	var parsed *query.ParsedFilter
+	switch {
+	case len(q.Where) > 0 && q.Filter != "":
+		return connector.SelectRequest{}, fmt.Errorf("Invalid filter: filter and where cannot be combined")
+	case len(q.Where) > 0:
+		parsed, err = query.ParseFilterJSONForColumns(q.Where, conn.ParameterPlaceholder, 1, cols)
+	case q.Filter != "":
+		parsed, err = query.ParseFilterForColumns(q.Filter, conn.ParameterPlaceholder, 1, cols)
+	}
+	if err != nil {
+		return connector.SelectRequest{}, fmt.Errorf("Invalid filter: %w", err)
+	}
+	var filterSQL string
+	var filterParams []interface{}
+	if parsed != nil {
+		filterSQL = parsed.SQL
+		filterParams = parsed.Params
+	}
+
+	// Apply IDs filter if provided (filters by the primary key columns).
+	if len(q.IDs) > 0 {
+		ids, err := resolveIDs(q.KeyColumns, q.IDs, cols)
+		if err != nil {
+			return connector.SelectRequest{}, fmt.Errorf("Invalid ids parameter: %w", err)
 		}
-		idClause := fmt.Sprintf("%s IN (%s)", conn.QuoteIdentifier("id"), strings.Join(placeholders, ", "))
+		idClause, idArgs, err := connector.BuildKeyClause(q.KeyColumns, ids, conn.QuoteIdentifier, conn.ParameterPlaceholder, len(filterParams)+1)
+		if err != nil {
+			return connector.SelectRequest{}, fmt.Errorf("Invalid ids parameter: %w", err)
+		}
+		filterParams = append(filterParams, idArgs...)
 		if filterSQL != "" {
 			filterSQL = filterSQL + " AND " + idClause
 		} else {
@@ -138,29 +370,56 @@ func (h *TableHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Parse and validate order clause.
-	var orderSQL string
-	if orderStr != "" {
-		clauses, err := query.ParseOrderClause(orderStr)
+	// Parse the HAVING expression. Its placeholders continue numbering after
+	// the filter's so that indexed dialects ($N, @pN, :N) stay consistent.
+	var havingSQL string
+	var havingParams []interface{}
+	if q.Having != "" {
+		parsed, err := query.ParseHaving(q.Having, projection, groupBy, conn.QuoteIdentifier, conn.ParameterPlaceholder, len(filterParams)+1)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid order parameter: "+err.Error())
-			return
+			return connector.SelectRequest{}, fmt.Errorf("Invalid having parameter: %w", err)
+		}
+		if parsed != nil {
+			havingSQL = parsed.SQL
+			havingParams = parsed.Params
 		}
-		orderSQL = query.BuildOrderSQL(clauses, conn.QuoteIdentifier)
+	}
+
+	var orderSQL string
+	if len(orderClauses) > 0 {
+		orderSQL = query.BuildOrderSQL(orderClauses, conn.QuoteIdentifier)
 		// Strip the "ORDER BY " prefix since the connector adds it.
 		orderSQL = strings.TrimPrefix(orderSQL, "ORDER BY ")
 	}
 
-	// Build and execute the SELECT query.
-	selectReq := connector.SelectRequest{
+	return connector.SelectRequest{
 		Table:      tableName,
 		Projection: projection,
+		Distinct:   q.Distinct,
 		Filter:     filterSQL,
 		FilterArgs: filterParams,
 		Order:      orderSQL,
 		GroupBy:    groupBy,
-		Limit:      limit,
-		Offset:     offset,
+		Having:     havingSQL,
+		HavingArgs: havingParams,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+	}, nil
+}
+
+// executeSelect runs selectReq and streams the result as a ListResponse
+// envelope (see listStream), or as NDJSON, CSV, TSV, Arrow or Parquet when
+// the client asks for one of those with ?format= or the Accept header.
+func (h *TableHandler) executeSelect(w http.ResponseWriter, r *http.Request, conn connector.Connector, selectReq connector.SelectRequest, q recordQuery, start time.Time) {
+	format, err := negotiateSelectFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid output format: "+err.Error())
+		return
+	}
+
+	if dryRunRequested(r) {
+		dryRunSelect(w, r, conn, selectReq)
+		return
 	}
 
 	sqlStr, args, err := conn.BuildSelect(r.Context(), selectReq)
@@ -177,55 +436,107 @@ func (h *TableHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer rows.Close()
+	enc := h.encoder(r.Context(), conn, chi.URLParam(r, "serviceName"), selectReq.Table)
 
-	// Check Accept header for NDJSON streaming.
-	acceptNDJSON := strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
-
-	if acceptNDJSON {
+	switch format {
+	case formatNDJSON:
 		// Stream results as newline-delimited JSON.
+		clearWriteDeadline(w)
 		w.Header().Set("Content-Type", "application/x-ndjson")
 		w.WriteHeader(http.StatusOK)
 
-		enc := json.NewEncoder(w)
+		out := json.NewEncoder(w)
 		for rows.Next() {
 			row := make(map[string]interface{})
 			if err := rows.MapScan(row); err != nil {
 				// Can't change status code mid-stream; log and stop.
 				return
 			}
-			cleanMapValues(row)
-			enc.Encode(row)
+			enc.EncodeRow(row)
+			out.Encode(row)
+		}
+		return
+
+	case formatCSV, formatTSV:
+		// Stream results as delimited text, in the query's column order.
+		columns, err := rows.Columns()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to read columns: "+err.Error())
+			return
+		}
+		clearWriteDeadline(w)
+		tabular := newTabularWriter(w, r, format)
+		if err := tabular.begin(selectReq.Table, columns); err != nil {
+			return
+		}
+		for rows.Next() {
+			row := make(map[string]interface{})
+			if err := rows.MapScan(row); err != nil {
+				// Can't change status code mid-stream; stop.
+				break
+			}
+			enc.EncodeRow(row)
+			if err := tabular.write(row); err != nil {
+				return
+			}
 		}
+		tabular.flush()
+		return
+
+	case formatArrow, formatParquet:
+		h.exportRows(w, r, rows, format, selectReq.Table)
 		return
 	}
 
-	// Collect results into a slice.
-	records := make([]map[string]interface{}, 0)
+	// Record ETags match those of the single-record routes, so they are
+	// only computed for plain (ungrouped) rows. Without a version column
+	// only full rows can be tagged. Each tag is added to its record as the
+	// _etag member.
+	tagRecords := q.IncludeETags && len(selectReq.GroupBy) == 0
+	var versionCol string
+	if tagRecords {
+		versionCol = h.versionColumn(r.Context(), chi.URLParam(r, "serviceName"), selectReq.Table)
+		tagRecords = versionCol != "" || (len(selectReq.Projection) == 0 && len(selectReq.Fields) == 0)
+	}
+
+	// Stream the envelope: rows are written as they are scanned and meta
+	// follows them. A client that disconnects cancels the request context,
+	// which ends the query.
+	stream := newListStream(w)
 	for rows.Next() {
 		row := make(map[string]interface{})
 		if err := rows.MapScan(row); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to scan row: "+err.Error())
+			stream.fail(http.StatusInternalServerError, "Failed to scan row: "+err.Error())
+			return
+		}
+		enc.EncodeRow(row)
+		if tagRecords {
+			if etag, ok := recordETag(row, versionCol); ok {
+				row[etagMember] = etag
+			}
+		}
+		if err := stream.write(row); err != nil {
 			return
 		}
-		cleanMapValues(row)
-		records = append(records, row)
 	}
 	if err := rows.Err(); err != nil {
-		writeError(w, http.StatusInternalServerError, "Row iteration error: "+err.Error())
+		if r.Context().Err() != nil {
+			return
+		}
+		stream.fail(http.StatusInternalServerError, "Row iteration error: "+err.Error())
 		return
 	}
-
-	// Optionally fetch total count. Skipped for grouped queries, where a plain
-	// COUNT(*) would count underlying rows rather than result groups.
+	// Optionally fetch total count. Skipped for grouped and distinct queries,
+	// where a plain COUNT(*) would count underlying rows rather than result rows.
 	var total *int64
-	if includeCount && len(groupBy) == 0 {
+	if q.IncludeCount && len(selectReq.GroupBy) == 0 && !selectReq.Distinct {
 		countReq := connector.CountRequest{
-			Table:  tableName,
-			Filter: filterSQL,
+			Table:  selectReq.Table,
+			Filter: selectReq.Filter,
 		}
 		countSQL, countArgs, err := conn.BuildCount(r.Context(), countReq)
 		if err == nil {
-			allCountArgs := append(filterParams, countArgs...)
+			allCountArgs := append(selectReq.FilterArgs, countArgs...)
 			var count int64
 			if err := db.QueryRowxContext(r.Context(), countSQL, allCountArgs...).Scan(&count); err == nil {
 				total = &count
@@ -233,20 +544,57 @@ func (h *TableHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// A full page may have more rows behind it; hand back an opaque cursor.
+	var nextCursor string
+	if q.Paginate && q.Limit > 0 && stream.count == q.Limit {
+		nextCursor = encodeCursor(q.Offset + stream.count)
+	}
+
 	took := time.Since(start)
 
-	writeJSON(w, http.StatusOK, model.ListResponse{
-		Resource: records,
-		Meta: &model.ResponseMeta{
-			Count:  len(records),
-			Total:  total,
-			Limit:  limit,
-			Offset: offset,
-			TookMs: float64(took.Microseconds()) / 1000.0,
-		},
+	stream.end(&model.ResponseMeta{
+		Count:      stream.count,
+		Total:      total,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		NextCursor: nextCursor,
+		TookMs:     float64(took.Microseconds()) / 1000.0,
 	})
 }
 
+// exportRows streams rows as an Arrow IPC stream or a Parquet file, typed
+// from the table's introspected schema. Rows are scanned straight into
+// record batches of ?batch_size= rows (default export.DefaultBatchSize).
+func (h *TableHandler) exportRows(w http.ResponseWriter, r *http.Request, rows *sqlx.Rows, format, tableName string) {
+	table, err := h.registry.TableSchema(r.Context(), chi.URLParam(r, "serviceName"), tableName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to introspect table: "+err.Error())
+		return
+	}
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read columns: "+err.Error())
+		return
+	}
+	schema := export.Schema(table, columns)
+
+	clearWriteDeadline(w)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": tableName + export.Extension(format)}))
+	w.WriteHeader(http.StatusOK)
+
+	// Can't change status code mid-stream; a failed export ends early and
+	// leaves the output incomplete.
+	ew, err := export.NewWriter(w, format, schema, queryInt(r, "batch_size", 0))
+	if err != nil {
+		return
+	}
+	if _, err := ew.WriteRows(r.Context(), rows); err != nil {
+		return
+	}
+	ew.Close()
+}
+
 // CreateRecords inserts one or more records into a table.
 // POST /api/v1/{serviceName}/_table/{tableName}
 //
@@ -254,6 +602,18 @@ func (h *TableHandler) QueryRecords(w http.ResponseWriter, r *http.Request) {
 //   - (default)         halt on first error; prior inserts are committed
 //   - ?rollback=true    wrap in transaction; all-or-nothing
 //   - ?continue=true    insert each record independently; report mixed results
+//
+// Upserts (query parameters):
+//   - ?upsert=true                update rows that collide on the conflict columns
+//   - ?on_conflict=col1,col2      conflict columns (default: the primary key)
+//   - ?update_columns=col1,col2   columns to overwrite (default: all but the conflict columns)
+//   - ?ignore_duplicates=true     leave colliding rows untouched (implies upsert)
+//
+// Nested writes: a field named after a related table embeds rows to create
+// with the record. Child rows (e.g. "order_items": [...]) and parent objects
+// (e.g. "customer": {...}) are discovered through foreign keys and inserted
+// in dependency order in a single transaction, with generated keys copied
+// into the referencing rows. The full created graph is returned.
 func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
@@ -276,11 +636,40 @@ func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Records embedding related rows are written as one graph per record.
+	if hasNestedValues(records) && h.createNestedRecords(w, r, conn, serviceName, tableName, records, start) {
+		return
+	}
+
+	if _, ruleErr := h.applyColumnRules(r.Context(), serviceName, tableName, model.ColumnRuleOnCreate, records); ruleErr != nil {
+		writeColumnRuleError(w, ruleErr)
+		return
+	}
+
+	upsert, err := h.parseUpsert(r, conn, serviceName, tableName, records)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	excludeFromUpsert(upsert, records, h.createOnlyColumns(r.Context(), serviceName, tableName))
+
+	// Records are validated against the schema before any SQL is built.
+	validator := h.recordValidator(r.Context(), serviceName, tableName)
 	mode := parseBatchMode(r)
 
+	if dryRunRequested(r) {
+		dryRunInsert(w, r, conn, tableName, records, upsert, validator, mode)
+		return
+	}
+
 	// Continue mode: insert each record individually, collecting per-record results.
 	if mode == BatchModeContinue {
-		h.createRecordsContinue(w, r, conn, tableName, records, start)
+		h.createRecordsContinue(w, r, conn, tableName, records, upsert, validator, start)
+		return
+	}
+
+	if err := validator.checkRecords(records, upsertKeys(upsert)); err != nil {
+		writeQueryError(w, err)
 		return
 	}
 
@@ -288,6 +677,7 @@ func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 	insertReq := connector.InsertRequest{
 		Table:   tableName,
 		Records: records,
+		Upsert:  upsert,
 	}
 	sqlStr, args, err := conn.BuildInsert(r.Context(), insertReq)
 	if err != nil {
@@ -308,7 +698,7 @@ func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 		exec = tx
 
 		// Execute + commit in rollback mode.
-		created, err := execInsert(r.Context(), exec, conn, sqlStr, args)
+		created, err := execInsert(r.Context(), exec, conn, h.encoder(r.Context(), conn, serviceName, tableName), sqlStr, args)
 		if err != nil {
 			code, msg := classifyDBError(err, "Insert failed")
 			writeError(w, code, msg)
@@ -324,7 +714,7 @@ func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Halt mode (default): execute directly.
-	created, err := execInsert(r.Context(), exec, conn, sqlStr, args)
+	created, err := execInsert(r.Context(), exec, conn, h.encoder(r.Context(), conn, serviceName, tableName), sqlStr, args)
 	if err != nil {
 		code, msg := classifyDBError(err, "Insert failed")
 		writeError(w, code, msg)
@@ -334,17 +724,133 @@ func (h *TableHandler) CreateRecords(w http.ResponseWriter, r *http.Request) {
 	writeCreateResponse(w, conn, created, records, took)
 }
 
+// dryRunInsert previews a create request: one multi-row INSERT, or one
+// INSERT per record in continue mode.
+func dryRunInsert(w http.ResponseWriter, r *http.Request, conn connector.Connector, tableName string, records []map[string]interface{}, upsert *connector.UpsertOptions, validator *recordValidator, mode BatchMode) {
+	if err := validator.checkRecords(records, upsertKeys(upsert)); err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	batches := [][]map[string]interface{}{records}
+	if mode == BatchModeContinue {
+		batches = make([][]map[string]interface{}, len(records))
+		for i, rec := range records {
+			batches[i] = []map[string]interface{}{rec}
+		}
+	}
+	stmts := make([]sqlStatement, len(batches))
+	for i, batch := range batches {
+		sqlStr, args, err := conn.BuildInsert(r.Context(), connector.InsertRequest{
+			Table:   tableName,
+			Records: batch,
+			Upsert:  upsert,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to build insert: "+err.Error())
+			return
+		}
+		stmts[i] = sqlStatement{sqlStr, args, returnsRows(conn)}
+	}
+	execDryRun(w, r, conn, "Insert failed", stmts...)
+}
+
+// parseUpsert reads the upsert query parameters of a create request. It
+// returns nil for a plain insert. Conflict columns default to the table's
+// primary key, and every named column is checked against the table schema
+// and the columns of the first record.
+func (h *TableHandler) parseUpsert(r *http.Request, conn connector.Connector, serviceName, tableName string, records []map[string]interface{}) (*connector.UpsertOptions, error) {
+	ignore := queryBool(r, "ignore_duplicates")
+	onConflict, err := query.ParseFieldSelection(queryString(r, "on_conflict"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid on_conflict: %w", err)
+	}
+	updateCols, err := query.ParseFieldSelection(queryString(r, "update_columns"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid update_columns: %w", err)
+	}
+
+	if !queryBool(r, "upsert") && !ignore {
+		if onConflict != nil || updateCols != nil {
+			return nil, fmt.Errorf("on_conflict and update_columns require upsert=true")
+		}
+		return nil, nil
+	}
+	return h.upsertOptions(r.Context(), conn, serviceName, tableName, onConflict, updateCols, ignore, records)
+}
+
+// upsertOptions validates the options of an upsert into tableName and fills
+// in the default conflict columns.
+func (h *TableHandler) upsertOptions(ctx context.Context, conn connector.Connector, serviceName, tableName string, onConflict, updateCols []string, ignore bool, records []map[string]interface{}) (*connector.UpsertOptions, error) {
+	if !conn.SupportsUpsert() {
+		return nil, fmt.Errorf("upsert is not supported by the %s driver", conn.DriverName())
+	}
+	if ignore && updateCols != nil {
+		return nil, fmt.Errorf("update_columns cannot be combined with ignore_duplicates")
+	}
+
+	if onConflict == nil {
+		ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+		if err == nil && ts != nil {
+			onConflict = ts.PrimaryKey
+		}
+		if len(onConflict) == 0 {
+			return nil, fmt.Errorf("on_conflict is required: table %q has no primary key", tableName)
+		}
+	}
+
+	cols := h.tableColumns(ctx, serviceName, tableName)
+	for _, col := range append(append([]string{}, onConflict...), updateCols...) {
+		if err := cols.Check(col); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &connector.UpsertOptions{
+		ConflictColumns:  onConflict,
+		UpdateColumns:    updateCols,
+		IgnoreDuplicates: ignore,
+	}
+	columns := make([]string, 0, len(records[0]))
+	for col := range records[0] {
+		columns = append(columns, col)
+	}
+	if _, err := connector.UpsertUpdateColumns(columns, opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// upsertKeys returns the conflict columns of an upsert, which may be written
+// even when auto-increment since they address existing rows.
+func upsertKeys(upsert *connector.UpsertOptions) []string {
+	if upsert == nil {
+		return nil
+	}
+	return upsert.ConflictColumns
+}
+
 // createRecordsContinue inserts each record individually, collecting successes and errors.
-func (h *TableHandler) createRecordsContinue(w http.ResponseWriter, r *http.Request, conn connector.Connector, tableName string, records []map[string]interface{}, start time.Time) {
+// Records failing schema validation are reported without being sent.
+func (h *TableHandler) createRecordsContinue(w http.ResponseWriter, r *http.Request, conn connector.Connector, tableName string, records []map[string]interface{}, upsert *connector.UpsertOptions, validator *recordValidator, start time.Time) {
 	db := conn.DB()
+	enc := h.encoder(r.Context(), conn, chi.URLParam(r, "serviceName"), tableName)
 	results := make([]interface{}, len(records))
 	var errIndices []int
 	succeeded := 0
 
+	keys := upsertKeys(upsert)
 	for i, rec := range records {
+		if fields := validator.checkInsert(rec, keys, nil); fields != nil {
+			invalid := &recordValidationError{Record: i, Fields: fields}
+			results[i] = map[string]interface{}{"error": invalid.errorDetail()}
+			errIndices = append(errIndices, i)
+			continue
+		}
+
 		singleReq := connector.InsertRequest{
 			Table:   tableName,
 			Records: []map[string]interface{}{rec},
+			Upsert:  upsert,
 		}
 		sqlStr, args, err := conn.BuildInsert(r.Context(), singleReq)
 		if err != nil {
@@ -369,7 +875,7 @@ func (h *TableHandler) createRecordsContinue(w http.ResponseWriter, r *http.Requ
 					errIndices = append(errIndices, i)
 					continue
 				}
-				cleanMapValues(row)
+				enc.EncodeRow(row)
 			}
 			rows.Close()
 			results[i] = row
@@ -405,20 +911,22 @@ func (h *TableHandler) createRecordsContinue(w http.ResponseWriter, r *http.Requ
 
 // execInsert executes an INSERT statement and returns created records (if RETURNING is supported)
 // or nil (caller uses input records as fallback).
-func execInsert(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, sqlStr string, args []interface{}) ([]map[string]interface{}, error) {
+func execInsert(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, enc *connector.ValueEncoder, sqlStr string, args []interface{}) ([]map[string]interface{}, error) {
 	if conn.SupportsReturning() {
 		rows, err := exec.QueryxContext(ctx, sqlStr, args...)
 		if err != nil {
 			return nil, err
 		}
 		defer rows.Close()
-		var created []map[string]interface{}
+		// Non-nil even when empty: an upsert that ignored every record
+		// returns no rows, which must not fall back to the input records.
+		created := []map[string]interface{}{}
 		for rows.Next() {
 			row := make(map[string]interface{})
 			if err := rows.MapScan(row); err != nil {
 				return nil, err
 			}
-			cleanMapValues(row)
+			enc.EncodeRow(row)
 			created = append(created, row)
 		}
 		if err := rows.Err(); err != nil {
@@ -467,6 +975,10 @@ func (h *TableHandler) ReplaceRecords(w http.ResponseWriter, r *http.Request) {
 	serviceName := chi.URLParam(r, "serviceName")
 	tableName := chi.URLParam(r, "tableName")
 
+	if rejectIfMatch(w, r) {
+		return
+	}
+
 	conn, err := h.registry.Get(serviceName)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
@@ -483,7 +995,34 @@ func (h *TableHandler) ReplaceRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ruleErr := h.applyColumnRules(r.Context(), serviceName, tableName, model.ColumnRuleOnUpdate, records); ruleErr != nil {
+		writeColumnRuleError(w, ruleErr)
+		return
+	}
+
 	mode := parseBatchMode(r)
+	keyColumns := h.primaryKey(r.Context(), serviceName, tableName)
+	validator := h.recordValidator(r.Context(), serviceName, tableName)
+	enc := h.encoder(r.Context(), conn, serviceName, tableName)
+
+	if dryRunRequested(r) {
+		stmts := make([]sqlStatement, len(records))
+		for i, record := range records {
+			ids, filter := extractIDsOrFilter(record, keyColumns, r)
+			if fields := validator.checkUpdate(record); fields != nil {
+				writeQueryError(w, newRecordValidationError(i, len(records), fields))
+				return
+			}
+			sqlStr, args, err := buildSingleUpdate(r.Context(), conn, tableName, record, filter, keyColumns, ids)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to build update: "+err.Error())
+				return
+			}
+			stmts[i] = sqlStatement{sqlStr, args, returnsRows(conn)}
+		}
+		execDryRun(w, r, conn, "Update failed", stmts...)
+		return
+	}
 
 	// Choose executor: transaction for rollback mode, raw DB otherwise.
 	var exec connector.QueryExecutor
@@ -507,8 +1046,14 @@ func (h *TableHandler) ReplaceRecords(w http.ResponseWriter, r *http.Request) {
 		succeeded := 0
 
 		for i, record := range records {
-			ids, filter := extractIDsOrFilter(record, r)
-			result, err := execSingleUpdate(r.Context(), exec, conn, tableName, record, filter, ids)
+			ids, filter := extractIDsOrFilter(record, keyColumns, r)
+			if fields := validator.checkUpdate(record); fields != nil {
+				invalid := &recordValidationError{Record: i, Fields: fields}
+				results[i] = map[string]interface{}{"error": invalid.errorDetail()}
+				errIndices = append(errIndices, i)
+				continue
+			}
+			result, err := execSingleUpdate(r.Context(), exec, conn, enc, tableName, record, filter, keyColumns, ids)
 			if err != nil {
 				code, msg := classifyDBError(err, "Update failed")
 				results[i] = map[string]interface{}{"error": model.ErrorDetail{Code: code, Message: msg}}
@@ -533,11 +1078,22 @@ func (h *TableHandler) ReplaceRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Halt and rollback modes: stop at first error.
+	// Halt and rollback modes: validate every record, then stop at the
+	// first error.
+	targets := make([]struct {
+		ids    []interface{}
+		filter string
+	}, len(records))
+	for i, record := range records {
+		targets[i].ids, targets[i].filter = extractIDsOrFilter(record, keyColumns, r)
+		if fields := validator.checkUpdate(record); fields != nil {
+			writeQueryError(w, newRecordValidationError(i, len(records), fields))
+			return
+		}
+	}
 	updated := make([]map[string]interface{}, 0)
-	for _, record := range records {
-		ids, filter := extractIDsOrFilter(record, r)
-		result, err := execSingleUpdate(r.Context(), exec, conn, tableName, record, filter, ids)
+	for i, record := range records {
+		result, err := execSingleUpdate(r.Context(), exec, conn, enc, tableName, record, targets[i].filter, keyColumns, targets[i].ids)
 		if err != nil {
 			code, msg := classifyDBError(err, "Update failed")
 			writeError(w, code, msg)
@@ -564,14 +1120,8 @@ func (h *TableHandler) ReplaceRecords(w http.ResponseWriter, r *http.Request) {
 }
 
 // execSingleUpdate builds and executes a single UPDATE for one record, returning the result row.
-func execSingleUpdate(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, tableName string, record map[string]interface{}, filter string, ids []interface{}) (map[string]interface{}, error) {
-	updateReq := connector.UpdateRequest{
-		Table:  tableName,
-		Record: record,
-		Filter: filter,
-		IDs:    ids,
-	}
-	sqlStr, args, err := conn.BuildUpdate(ctx, updateReq)
+func execSingleUpdate(ctx context.Context, exec connector.QueryExecutor, conn connector.Connector, enc *connector.ValueEncoder, tableName string, record map[string]interface{}, filter string, keyColumns []string, ids []interface{}) (map[string]interface{}, error) {
+	sqlStr, args, err := buildSingleUpdate(ctx, conn, tableName, record, filter, keyColumns, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -587,7 +1137,7 @@ func execSingleUpdate(ctx context.Context, exec connector.QueryExecutor, conn co
 			if err := rows.MapScan(row); err != nil {
 				return nil, err
 			}
-			cleanMapValues(row)
+			enc.EncodeRow(row)
 		}
 		return row, rows.Err()
 	}
@@ -599,6 +1149,17 @@ func execSingleUpdate(ctx context.Context, exec connector.QueryExecutor, conn co
 	return record, nil
 }
 
+// buildSingleUpdate builds the UPDATE for one record.
+func buildSingleUpdate(ctx context.Context, conn connector.Connector, tableName string, record map[string]interface{}, filter string, keyColumns []string, ids []interface{}) (string, []interface{}, error) {
+	return conn.BuildUpdate(ctx, connector.UpdateRequest{
+		Table:      tableName,
+		Record:     record,
+		Filter:     filter,
+		IDs:        ids,
+		KeyColumns: keyColumns,
+	})
+}
+
 // UpdateRecords partially updates records matching a filter or ID list.
 // PATCH /api/v1/{serviceName}/_table/{tableName}
 //
@@ -609,6 +1170,10 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 	serviceName := chi.URLParam(r, "serviceName")
 	tableName := chi.URLParam(r, "tableName")
 
+	if rejectIfMatch(w, r) {
+		return
+	}
+
 	conn, err := h.registry.Get(serviceName)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
@@ -637,6 +1202,10 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "No fields to update")
 		return
 	}
+	if err := h.prepareUpdate(r.Context(), serviceName, tableName, record); err != nil {
+		writePrepareError(w, err)
+		return
+	}
 
 	filterStr := queryString(r, "filter")
 	var filterSQL string
@@ -677,12 +1246,23 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var keyColumns []string
+	if len(ids) > 0 {
+		keyColumns = h.primaryKey(r.Context(), serviceName, tableName)
+		ids, err = resolveIDs(keyColumns, ids, h.tableColumns(r.Context(), serviceName, tableName))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid ids parameter: "+err.Error())
+			return
+		}
+	}
+
 	updateReq := connector.UpdateRequest{
 		Table:      tableName,
 		Record:     record,
 		Filter:     filterSQL,
 		FilterArgs: filterParams,
 		IDs:        ids,
+		KeyColumns: keyColumns,
 	}
 
 	sqlStr, args, err := conn.BuildUpdate(r.Context(), updateReq)
@@ -690,6 +1270,10 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to build update: "+err.Error())
 		return
 	}
+	if dryRunRequested(r) {
+		execDryRun(w, r, conn, "Update failed", sqlStatement{sqlStr, args, returnsRows(conn)})
+		return
+	}
 
 	// Choose executor: transaction for rollback mode, raw DB otherwise.
 	mode := parseBatchMode(r)
@@ -707,6 +1291,7 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 		exec = tx
 	}
 
+	enc := h.encoder(r.Context(), conn, serviceName, tableName)
 	updated := make([]map[string]interface{}, 0)
 
 	if conn.SupportsReturning() {
@@ -724,7 +1309,7 @@ func (h *TableHandler) UpdateRecords(w http.ResponseWriter, r *http.Request) {
 				writeError(w, http.StatusInternalServerError, "Failed to scan result: "+err.Error())
 				return
 			}
-			cleanMapValues(row)
+			enc.EncodeRow(row)
 			updated = append(updated, row)
 		}
 		if err := rows.Err(); err != nil {
@@ -769,6 +1354,10 @@ func (h *TableHandler) DeleteRecords(w http.ResponseWriter, r *http.Request) {
 	serviceName := chi.URLParam(r, "serviceName")
 	tableName := chi.URLParam(r, "tableName")
 
+	if rejectIfMatch(w, r) {
+		return
+	}
+
 	conn, err := h.registry.Get(serviceName)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "Service not found: "+serviceName)
@@ -800,20 +1389,19 @@ func (h *TableHandler) DeleteRecords(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	keyColumns := h.primaryKey(r.Context(), serviceName, tableName)
 	if len(ids) == 0 {
 		var body struct {
-			IDs      []interface{} `json:"ids"`
-			Resource []struct {
-				ID interface{} `json:"id"`
-			} `json:"resource"`
+			IDs      []interface{}            `json:"ids"`
+			Resource []map[string]interface{} `json:"resource"`
 		}
 		if err := readJSON(r, &body); err == nil {
 			if len(body.IDs) > 0 {
 				ids = body.IDs
 			} else if len(body.Resource) > 0 {
 				for _, res := range body.Resource {
-					if res.ID != nil {
-						ids = append(ids, res.ID)
+					if key, ok := recordKey(res, keyColumns); ok {
+						ids = append(ids, key)
 					}
 				}
 			}
@@ -824,12 +1412,20 @@ func (h *TableHandler) DeleteRecords(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Filter or IDs required for delete")
 		return
 	}
+	if len(ids) > 0 {
+		ids, err = resolveIDs(keyColumns, ids, h.tableColumns(r.Context(), serviceName, tableName))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid ids parameter: "+err.Error())
+			return
+		}
+	}
 
 	deleteReq := connector.DeleteRequest{
 		Table:      tableName,
 		Filter:     filterSQL,
 		FilterArgs: filterParams,
 		IDs:        ids,
+		KeyColumns: keyColumns,
 	}
 
 	sqlStr, args, err := conn.BuildDelete(r.Context(), deleteReq)
@@ -837,6 +1433,10 @@ func (h *TableHandler) DeleteRecords(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to build delete: "+err.Error())
 		return
 	}
+	if dryRunRequested(r) {
+		execDryRun(w, r, conn, "Delete failed", sqlStatement{sqlStr, args, false})
+		return
+	}
 
 	// Choose executor: transaction for rollback mode, raw DB otherwise.
 	mode := parseBatchMode(r)
@@ -912,27 +1512,18 @@ func parseRecordsBody(r *http.Request) ([]map[string]interface{}, error) {
 	return nil, fmt.Errorf("expected JSON object, array, or {\"resource\": [...]}")
 }
 
-// extractIDsOrFilter extracts primary key IDs from a record map (removing the
-// "id" field) for use as a WHERE condition. If no ID is present, falls back to
-// the filter query parameter. This is used by PUT (ReplaceRecords).
-func extractIDsOrFilter(record map[string]interface{}, r *http.Request) ([]interface{}, string) {
-	// Check for an "id" field in the record.
-	if id, ok := record["id"]; ok {
-		delete(record, "id")
-		return []interface{}{id}, ""
+// extractIDsOrFilter extracts the primary key from a record map (removing the
+// key fields) for use as a WHERE condition. If the record does not carry every
+// key column, falls back to the filter query parameter. This is used by PUT
+// (ReplaceRecords).
+func extractIDsOrFilter(record map[string]interface{}, keyColumns []string, r *http.Request) ([]interface{}, string) {
+	if key, ok := recordKey(record, keyColumns); ok {
+		for _, col := range keyColumns {
+			delete(record, col)
+		}
+		return []interface{}{key}, ""
 	}
 
 	// Fall back to query parameter filter.
 	return nil, queryString(r, "filter")
 }
-
-// cleanMapValues converts []byte values from database scans into strings
-// for clean JSON serialization. sqlx MapScan returns []byte for many column
-// types which would otherwise be base64-encoded in JSON.
-func cleanMapValues(m map[string]interface{}) {
-	for k, v := range m {
-		if b, ok := v.([]byte); ok {
-			m[k] = string(b)
-		}
-	}
-}
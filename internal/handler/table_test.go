@@ -0,0 +1,540 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// ---------------------------------------------------------------------------
+// POST _table/{table}/_query (structured JSON query) tests
+// ---------------------------------------------------------------------------
+
+func TestQueryRecordsJSON(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	tests := []struct {
+		name      string
+		body      interface{}
+		wantCode  int
+		wantNames []string
+	}{
+		{
+			"equality shorthand",
+			map[string]interface{}{"where": map[string]interface{}{"name": "Bob"}},
+			http.StatusOK,
+			[]string{"Bob"},
+		},
+		{
+			"or with ordering",
+			map[string]interface{}{
+				"where": map[string]interface{}{"or": []interface{}{
+					map[string]interface{}{"name": "Alice"},
+					map[string]interface{}{"email": map[string]interface{}{"ends_with": "@example.com"}},
+				}},
+				"order": []string{"name DESC"},
+			},
+			http.StatusOK,
+			[]string{"Bob", "Alice"},
+		},
+		{
+			"quotes in values need no escaping",
+			map[string]interface{}{"where": map[string]interface{}{"name": "O'Brien"}},
+			http.StatusOK,
+			[]string{},
+		},
+		{
+			"ids with fields",
+			map[string]interface{}{"ids": []int{2}, "fields": []string{"id", "name"}},
+			http.StatusOK,
+			[]string{"Bob"},
+		},
+		{
+			"unknown operator",
+			map[string]interface{}{"where": map[string]interface{}{"name": map[string]interface{}{"regex": "A.*"}}},
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"invalid column",
+			map[string]interface{}{"where": map[string]interface{}{"name; DROP TABLE users": 1}},
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"unknown body field",
+			map[string]interface{}{"filter": "name = 'Bob'"},
+			http.StatusBadRequest,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := env.do(t, "POST", "/api/v1/testdb/_table/users/_query", tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d; body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if tt.wantNames == nil {
+				return
+			}
+			var resp model.ListResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Resource) != len(tt.wantNames) {
+				t.Fatalf("expected %d records, got %d: %v", len(tt.wantNames), len(resp.Resource), resp.Resource)
+			}
+			for i, want := range tt.wantNames {
+				if got := resp.Resource[i]["name"]; got != want {
+					t.Errorf("record %d: name = %v, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryRecordsJSON_CursorPagination(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	body := map[string]interface{}{"order": []string{"id ASC"}, "limit": 1}
+	var seen []interface{}
+	for page := 0; page < 3; page++ {
+		rr := env.do(t, "POST", "/api/v1/testdb/_table/users/_query", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("page %d: expected 200, got %d; body: %s", page, rr.Code, rr.Body.String())
+		}
+		var resp model.ListResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		for _, rec := range resp.Resource {
+			seen = append(seen, rec["name"])
+		}
+		if resp.Meta.NextCursor == "" {
+			break
+		}
+		body["cursor"] = resp.Meta.NextCursor
+	}
+
+	if len(seen) != 2 || seen[0] != "Alice" || seen[1] != "Bob" {
+		t.Errorf("paged names = %v, want [Alice Bob]", seen)
+	}
+
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/users/_query", map[string]interface{}{"cursor": "not-a-cursor"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("bad cursor: expected 400, got %d", rr.Code)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Schema-aware query validation tests
+// ---------------------------------------------------------------------------
+
+func TestQueryRecords_SchemaValidation(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		wantCode       int
+		wantColumn     string
+		wantSuggestion string
+	}{
+		{"unknown field", "?fields=id,nmae", http.StatusBadRequest, "nmae", "name"},
+		{"unknown filter column", "?filter=emial%3D'x'", http.StatusBadRequest, "emial", "email"},
+		{"unknown order column", "?order=nam%20DESC", http.StatusBadRequest, "nam", "name"},
+		{"unknown group column", "?fields=COUNT(*)&group=nme", http.StatusBadRequest, "nme", "name"},
+		{"no suggestion", "?fields=zzzzzzzz", http.StatusBadRequest, "zzzzzzzz", ""},
+		{"type mismatch in filter", "?filter=id%3D'abc'", http.StatusBadRequest, "", ""},
+		{"type mismatch in ids", "?ids=1,abc", http.StatusBadRequest, "", ""},
+		{"order by aggregate alias", "?fields=name,COUNT(*)%20AS%20n&group=name&order=n%20DESC", http.StatusOK, "", ""},
+		{"valid query", "?fields=id,name&filter=id%3D'1'&order=name", http.StatusOK, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := env.do(t, "GET", "/api/v1/testdb/_table/users"+tt.path, nil)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d; body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if tt.wantColumn == "" {
+				return
+			}
+			var resp model.ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Error.Context["column"] != tt.wantColumn {
+				t.Errorf("context column = %v, want %s", resp.Error.Context["column"], tt.wantColumn)
+			}
+			if got, _ := resp.Error.Context["suggestion"].(string); got != tt.wantSuggestion {
+				t.Errorf("context suggestion = %q, want %q", got, tt.wantSuggestion)
+			}
+		})
+	}
+}
+
+func TestQueryRecords_IDsBindAsIntegers(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users?ids=1,2&order=id", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.ListResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Resource) != 2 {
+		t.Errorf("expected 2 records, got %d", len(resp.Resource))
+	}
+
+	conn, _ := env.registry.Get("testdb")
+	selectReq, err := buildSelectRequest(conn, "users", recordQuery{IDs: []interface{}{"1", "2"}}, env.handler.tableColumns(context.Background(), "testdb", "users"))
+	if err != nil {
+		t.Fatalf("buildSelectRequest: %v", err)
+	}
+	for i, arg := range selectReq.FilterArgs {
+		if _, ok := arg.(int64); !ok {
+			t.Errorf("FilterArgs[%d] = %v (%T), want int64", i, arg, arg)
+		}
+	}
+}
+
+func TestQueryRecords_SchemaRefreshOnUnknownColumn(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	// Prime the schema cache, then add a column behind the API's back.
+	if rr := env.do(t, "GET", "/api/v1/testdb/_table/users?fields=name", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(context.Background(), `ALTER TABLE users ADD COLUMN nickname TEXT`); err != nil {
+		t.Fatalf("alter table: %v", err)
+	}
+
+	// A schema fetched moments ago is not refreshed again.
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users?fields=nickname", nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 within the refresh floor, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	defer func(floor time.Duration) { schemaRefreshFloor = floor }(schemaRefreshFloor)
+	schemaRefreshFloor = 0
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/users?fields=nickname", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 after schema refresh, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRecordByKey(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users/2?fields=name", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var record map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &record)
+	if len(record) != 1 || record["name"] != "Bob" {
+		t.Errorf("get: unexpected record %v", record)
+	}
+
+	rr = env.do(t, "PATCH", "/api/v1/testdb/_table/users/2", map[string]interface{}{"id": 99, "name": "Robert"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	json.Unmarshal(rr.Body.Bytes(), &record)
+	if record["name"] != "Robert" || record["id"] != float64(2) {
+		t.Errorf("patch: unexpected record %v", record)
+	}
+
+	rr = env.do(t, "DELETE", "/api/v1/testdb/_table/users/2", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if count := env.countRows(t); count != 1 {
+		t.Errorf("delete: expected 1 row left, got %d", count)
+	}
+
+	// Missing rows are 404 on every verb.
+	for _, method := range []string{"GET", "PUT", "PATCH", "DELETE"} {
+		rr = env.do(t, method, "/api/v1/testdb/_table/users/2", map[string]interface{}{"name": "Ghost"})
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s missing record: expected 404, got %d; body: %s", method, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr = env.do(t, "GET", "/api/v1/testdb/_table/users/abc", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("non-integer id: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestRecordByKey_NamedAndCompositeKeys(t *testing.T) {
+	env := newBatchTestEnv(t)
+	conn, _ := env.registry.Get("testdb")
+	db := conn.DB()
+	for _, stmt := range []string{
+		`CREATE TABLE orders (order_no TEXT PRIMARY KEY, total REAL NOT NULL)`,
+		`INSERT INTO orders VALUES ('A-1', 10), ('A-2', 20), ('A-3', 30), ('A%41', 40), ('50%', 50)`,
+		`CREATE TABLE stock (tenant_id INTEGER NOT NULL, sku TEXT NOT NULL, qty INTEGER NOT NULL, PRIMARY KEY (tenant_id, sku))`,
+		`INSERT INTO stock VALUES (1, 'x,1', 5), (1, 'y', 6), (2, 'x,1', 7), (3, '9%,z', 8)`,
+	} {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	// ids resolve against the declared primary key rather than "id".
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/orders?ids=A-1,A-3&order=order_no", nil)
+	var resp model.ListResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.Resource) != 2 {
+		t.Errorf("orders by ids: expected 2 records, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/orders/A-2", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("order by key: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	// Key values are unescaped exactly once.
+	for path, want := range map[string]string{"A%2541": "A%41", "50%25": "50%"} {
+		rr = env.do(t, "GET", "/api/v1/testdb/_table/orders/"+path, nil)
+		var order map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &order)
+		if rr.Code != http.StatusOK || order["order_no"] != want {
+			t.Errorf("order %s: expected %q, got %d: %s", path, want, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Composite keys in the path are comma-separated in key order.
+	rr = env.do(t, "PATCH", "/api/v1/testdb/_table/stock/2,x%2C1", map[string]interface{}{"qty": 70})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("patch stock: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var qty int
+	db.QueryRowxContext(context.Background(), `SELECT qty FROM stock WHERE tenant_id = 2 AND sku = 'x,1'`).Scan(&qty)
+	if qty != 70 {
+		t.Errorf("patch stock: expected qty 70, got %d", qty)
+	}
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/stock/3,9%25%2Cz", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("stock with escaped key: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if rr = env.do(t, "GET", "/api/v1/testdb/_table/stock/1", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("partial composite key: expected 400, got %d", rr.Code)
+	}
+
+	// Composite ids in a JSON body, as arrays or objects.
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/stock/_query", map[string]interface{}{
+		"ids": []interface{}{[]interface{}{1, "y"}, map[string]interface{}{"tenant_id": 2, "sku": "x,1"}},
+	})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.Resource) != 2 {
+		t.Errorf("stock by composite ids: expected 2 records, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = env.do(t, "DELETE", "/api/v1/testdb/_table/stock", map[string]interface{}{
+		"resource": []interface{}{map[string]interface{}{"tenant_id": 1, "sku": "x,1"}},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete stock: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var left int
+	db.QueryRowxContext(context.Background(), `SELECT COUNT(*) FROM stock`).Scan(&left)
+	if left != 3 {
+		t.Errorf("delete stock: expected 3 rows left, got %d", left)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ETag / If-Match / If-None-Match tests
+// ---------------------------------------------------------------------------
+
+// doConditional sends a request carrying one conditional header.
+func (e *batchTestEnv) doConditional(t *testing.T, method, path, header, etag string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(header, etag)
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRecordETags_RowHash(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/users/1", nil)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("get: expected 200 with ETag, got %d %q", rr.Code, etag)
+	}
+	if rr = env.doConditional(t, "GET", "/api/v1/testdb/_table/users/1", "If-None-Match", etag, nil); rr.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match current: expected 304, got %d", rr.Code)
+	}
+
+	// List responses carry the same per-record ETags.
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/users?include_etags=true&order=id", nil)
+	var resp model.ListResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Resource) != 2 || resp.Resource[0]["_etag"] != etag {
+		t.Errorf("list etags: expected %q first, got %v", etag, resp.Resource)
+	}
+
+	rr = env.doConditional(t, "PATCH", "/api/v1/testdb/_table/users/1", "If-Match", etag, map[string]interface{}{"name": "Alicia"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("If-Match current: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	newETag := rr.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("patch: expected a new ETag, got %q", newETag)
+	}
+
+	// The old ETag is now stale for every write.
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		rr = env.doConditional(t, method, "/api/v1/testdb/_table/users/1", "If-Match", etag, map[string]interface{}{"name": "Stale"})
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s stale If-Match: expected 412, got %d; body: %s", method, rr.Code, rr.Body.String())
+		}
+	}
+	if rr = env.doConditional(t, "GET", "/api/v1/testdb/_table/users/1", "If-None-Match", etag, nil); rr.Code != http.StatusOK {
+		t.Errorf("If-None-Match stale: expected 200, got %d", rr.Code)
+	}
+
+	// Collection writes refuse If-Match rather than ignore it.
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		rr = env.doConditional(t, method, "/api/v1/testdb/_table/users?filter=id%3D1", "If-Match", newETag,
+			map[string]interface{}{"resource": []interface{}{map[string]interface{}{"id": 1, "name": "Bulk"}}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s collection If-Match: expected 400, got %d; body: %s", method, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr = env.doConditional(t, "DELETE", "/api/v1/testdb/_table/users/1", "If-Match", newETag, nil); rr.Code != http.StatusOK {
+		t.Errorf("delete with current ETag: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if rr = env.doConditional(t, "DELETE", "/api/v1/testdb/_table/users/1", "If-Match", "*", nil); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("If-Match * on missing record: expected 412, got %d", rr.Code)
+	}
+}
+
+func TestRecordETags_RowHashConcurrentWrite(t *testing.T) {
+	env := newBatchTestEnv(t)
+	// Two transactions need two connections to one database.
+	if err := env.registry.Connect("filedb", connector.ConnectionConfig{
+		Driver: "sqlite",
+		DSN:    filepath.Join(t.TempDir(), "etag.db"),
+	}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	t.Cleanup(func() { env.registry.Disconnect("filedb") })
+	conn, _ := env.registry.Get("filedb")
+	db := conn.DB()
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`INSERT INTO users (id, name) VALUES (1, 'Alice')`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	etag := env.do(t, "GET", "/api/v1/filedb/_table/users/1", nil).Header().Get("ETag")
+
+	// A first writer holding the same ETag has updated the row but not yet
+	// committed. The second must not overwrite its change.
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET name = 'First' WHERE id = 1`); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	rr := env.doConditional(t, "PATCH", "/api/v1/filedb/_table/users/1", "If-Match", etag, map[string]interface{}{"name": "Second"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("concurrent If-Match write: expected 409, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("first commit: %v", err)
+	}
+
+	rr = env.doConditional(t, "PATCH", "/api/v1/filedb/_table/users/1", "If-Match", etag, map[string]interface{}{"name": "Second"})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match after commit: expected 412, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var name string
+	db.QueryRowxContext(ctx, `SELECT name FROM users WHERE id = 1`).Scan(&name)
+	if name != "First" {
+		t.Errorf("expected the first write to survive, got %q", name)
+	}
+}
+
+func TestRecordETags_VersionColumn(t *testing.T) {
+	env := newBatchTestEnv(t)
+	if err := env.store.CreateService(context.Background(), &model.ServiceConfig{
+		Name:          "testdb",
+		Driver:        "sqlite",
+		DSN:           ":memory:",
+		VersionColumn: "version",
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	conn, _ := env.registry.Get("testdb")
+	db := conn.DB()
+	for _, stmt := range []string{
+		`CREATE TABLE docs (id INTEGER PRIMARY KEY, title TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 1)`,
+		`INSERT INTO docs (id, title) VALUES (1, 'Draft')`,
+	} {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	// The ETag follows the version column, even for a field selection.
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/docs/1?fields=title,version", nil)
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("get: expected an ETag")
+	}
+
+	rr = env.doConditional(t, "PATCH", "/api/v1/testdb/_table/docs/1", "If-Match", etag, map[string]interface{}{"title": "Final"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("If-Match current: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var record map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &record)
+	if record["version"] != float64(2) {
+		t.Errorf("expected version bumped to 2, got %v", record["version"])
+	}
+
+	rr = env.doConditional(t, "PATCH", "/api/v1/testdb/_table/docs/1", "If-Match", etag, map[string]interface{}{"title": "Lost update"})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: expected 412, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var title string
+	db.QueryRowxContext(context.Background(), `SELECT title FROM docs WHERE id = 1`).Scan(&title)
+	if title != "Final" {
+		t.Errorf("expected title Final, got %q", title)
+	}
+}
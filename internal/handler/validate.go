@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// recordValidationError reports the fields of a record that do not fit the
+// table schema. Fields maps each offending field to what is wrong with it.
+type recordValidationError struct {
+	// Record is the index of the record in the request body, or -1 for a
+	// request that carries a single record.
+	Record int
+	Fields map[string]string
+}
+
+func (e *recordValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + e.Fields[name]
+	}
+	if e.Record >= 0 {
+		return fmt.Sprintf("Invalid record %d: %s", e.Record, strings.Join(parts, "; "))
+	}
+	return "Invalid record: " + strings.Join(parts, "; ")
+}
+
+// context returns the error context of a validation failure: the field
+// map and, for bulk requests, the index of the failing record.
+func (e *recordValidationError) context() map[string]interface{} {
+	ctx := map[string]interface{}{"fields": e.Fields}
+	if e.Record >= 0 {
+		ctx["record"] = e.Record
+	}
+	return ctx
+}
+
+// errorDetail renders the error as a per-record result of a continue-mode
+// batch.
+func (e *recordValidationError) errorDetail() model.ErrorDetail {
+	return model.ErrorDetail{Code: 400, Message: e.Error(), Context: e.context()}
+}
+
+// recordValidator checks write payloads against an introspected table
+// schema before any SQL is built. A nil validator (schema unavailable)
+// accepts everything and leaves the checks to the database.
+type recordValidator struct {
+	columns map[string]model.Column
+	names   query.ColumnSet
+}
+
+// recordValidator returns the validator for a table, or nil when the schema
+// cannot be introspected.
+func (h *TableHandler) recordValidator(ctx context.Context, serviceName, tableName string) *recordValidator {
+	ts, err := h.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil || ts == nil || len(ts.Columns) == 0 {
+		return nil
+	}
+	return newRecordValidator(ts)
+}
+
+func newRecordValidator(ts *model.TableSchema) *recordValidator {
+	v := &recordValidator{
+		columns: make(map[string]model.Column, len(ts.Columns)),
+		names:   make(query.ColumnSet, len(ts.Columns)),
+	}
+	for _, c := range ts.Columns {
+		v.columns[c.Name] = c
+		v.names[c.Name] = c.JsonType
+	}
+	return v
+}
+
+// column resolves a field to its column, preferring an exact match and
+// falling back to a case-insensitive one like query.ColumnSet does.
+func (v *recordValidator) column(field string) (model.Column, bool) {
+	if c, ok := v.columns[field]; ok {
+		return c, true
+	}
+	for name, c := range v.columns {
+		if strings.EqualFold(name, field) {
+			return c, true
+		}
+	}
+	return model.Column{}, false
+}
+
+// checkInsert validates a record about to be inserted. Beyond the checks of
+// checkUpdate, every non-nullable column without a default must be present,
+// unless it is listed in linked (filled in by the server, e.g. a foreign key
+// of a nested write). Auto-increment columns may only be written when listed
+// in keys, the conflict columns of an upsert that address existing rows.
+func (v *recordValidator) checkInsert(record map[string]interface{}, keys, linked []string) map[string]string {
+	if v == nil {
+		return nil
+	}
+	fields := v.checkFields(record, keys)
+
+	supplied := make(map[string]bool, len(record)+len(linked))
+	for field, val := range record {
+		if c, ok := v.column(field); ok && val != nil {
+			supplied[c.Name] = true
+		}
+	}
+	for _, col := range linked {
+		supplied[col] = true
+	}
+	for _, c := range v.columns {
+		if c.Nullable || c.Default != nil || c.IsAutoIncrement || supplied[c.Name] {
+			continue
+		}
+		if _, reported := fields[c.Name]; reported {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields[c.Name] = "is required"
+	}
+	return fields
+}
+
+// checkUpdate validates the fields of a record about to be written to
+// existing rows: every field must name a column, carry a value of the
+// column's JSON type within its maximum length, not set a non-nullable
+// column to null, and not write an auto-increment column.
+func (v *recordValidator) checkUpdate(record map[string]interface{}) map[string]string {
+	if v == nil {
+		return nil
+	}
+	return v.checkFields(record, nil)
+}
+
+func (v *recordValidator) checkFields(record map[string]interface{}, keys []string) map[string]string {
+	var fields map[string]string
+	fail := func(field, msg string) {
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields[field] = msg
+	}
+
+	for field, val := range record {
+		c, ok := v.column(field)
+		if !ok {
+			msg := "unknown column"
+			var unknown *query.UnknownColumnError
+			if err := v.names.Check(field); errors.As(err, &unknown) && unknown.Suggestion != "" {
+				msg = fmt.Sprintf("unknown column (did you mean %q?)", unknown.Suggestion)
+			}
+			fail(field, msg)
+			continue
+		}
+		if c.IsAutoIncrement && !containsFold(keys, c.Name) {
+			fail(field, "is generated by the database and cannot be written")
+			continue
+		}
+		if val == nil {
+			// An explicit null for a column with a default still inserts
+			// NULL, so only nullability decides.
+			if !c.Nullable {
+				fail(field, "cannot be null")
+			}
+			continue
+		}
+		// Binary columns are read as base64 and written the same way.
+		decoded, err := connector.DecodeBinary(&c, val)
+		if err != nil {
+			fail(field, err.Error())
+			continue
+		}
+		record[field], val = decoded, decoded
+		if msg := checkJSONType(c.JsonType, val); msg != "" {
+			fail(field, msg)
+			continue
+		}
+		if s, ok := val.(string); ok && c.MaxLength != nil && *c.MaxLength > 0 {
+			if n := utf8.RuneCountInString(s); int64(n) > *c.MaxLength {
+				fail(field, fmt.Sprintf("exceeds maximum length of %d (got %d)", *c.MaxLength, n))
+			}
+		}
+	}
+	return fields
+}
+
+// decodeBinary decodes a value written to a binary column of the table
+// from base64, as checkFields does; other values are returned unchanged.
+func (v *recordValidator) decodeBinary(field string, val interface{}) (interface{}, error) {
+	if v == nil {
+		return val, nil
+	}
+	c, ok := v.column(field)
+	if !ok {
+		return val, nil
+	}
+	decoded, err := connector.DecodeBinary(&c, val)
+	if err != nil {
+		return nil, fmt.Errorf("column %q: %w", field, err)
+	}
+	return decoded, nil
+}
+
+// checkRecords validates the records of a create request and returns the
+// first failure as a *recordValidationError. The record index is omitted
+// for a request with a single record.
+func (v *recordValidator) checkRecords(records []map[string]interface{}, keys []string) error {
+	for i, rec := range records {
+		if fields := v.checkInsert(rec, keys, nil); fields != nil {
+			return newRecordValidationError(i, len(records), fields)
+		}
+	}
+	return nil
+}
+
+func newRecordValidationError(i, n int, fields map[string]string) *recordValidationError {
+	if n == 1 {
+		i = -1
+	}
+	return &recordValidationError{Record: i, Fields: fields}
+}
+
+// checkJSONType reports a value that does not match a column's JSON type,
+// or "" when it does. Columns of JSON (object and array) or unknown types
+// accept any value. Besides decoded JSON, the Go values a database returns
+// are accepted, since batch operations can copy values from earlier results,
+// and so are numeric strings, which is how numeric_format "string" returns
+// decimals.
+func checkJSONType(jsonType string, val interface{}) string {
+	ok := true
+	switch {
+	case jsonType == "integer":
+		ok = isInteger(val) || isNumericString(val, true)
+	case jsonType == "number":
+		ok = isNumber(val) || isNumericString(val, false)
+	case jsonType == "boolean":
+		_, ok = val.(bool)
+	case jsonType == "string" || strings.HasPrefix(jsonType, "string("):
+		switch val.(type) {
+		case string, []byte, time.Time:
+		default:
+			ok = false
+		}
+	}
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf("expected %s, got %s", jsonType, jsonKind(val))
+}
+
+func isInteger(val interface{}) bool {
+	switch n := val.(type) {
+	case float64:
+		return n == math.Trunc(n) && !math.IsInf(n, 0)
+	case float32:
+		return float64(n) == math.Trunc(float64(n))
+	case json.Number:
+		_, err := n.Int64()
+		return err == nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+func isNumber(val interface{}) bool {
+	switch n := val.(type) {
+	case float64, float32:
+		return true
+	case json.Number:
+		_, err := n.Float64()
+		return err == nil
+	}
+	return isInteger(val)
+}
+
+// isNumericString reports whether val is a string holding a JSON number,
+// and for integer columns a whole one. The string is written as is, so no
+// precision is lost.
+func isNumericString(val interface{}, integer bool) bool {
+	s, ok := val.(string)
+	if !ok || s == "" {
+		return false
+	}
+	if integer {
+		digits := strings.TrimPrefix(s, "-")
+		return digits != "" && strings.Trim(digits, "0123456789") == ""
+	}
+	return (s[0] == '-' || s[0] >= '0' && s[0] <= '9') && s == strings.TrimSpace(s) && json.Valid([]byte(s))
+}
+
+// jsonKind names the JSON type of a value for error messages.
+func jsonKind(val interface{}) string {
+	switch val.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if isNumber(val) {
+		return "number"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestRecordValidator(t *testing.T) {
+	maxLen := int64(5)
+	def := "'new'"
+	v := newRecordValidator(&model.TableSchema{
+		Name: "items",
+		Columns: []model.Column{
+			{Name: "id", JsonType: "integer", IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "code", JsonType: "string", MaxLength: &maxLen},
+			{Name: "qty", JsonType: "integer"},
+			{Name: "price", JsonType: "number", Nullable: true},
+			{Name: "active", JsonType: "boolean", Nullable: true},
+			{Name: "status", JsonType: "string", Default: &def},
+			{Name: "attrs", JsonType: "object", Nullable: true},
+		},
+	})
+
+	cases := []struct {
+		name   string
+		record map[string]interface{}
+		keys   []string
+		want   map[string]string
+	}{
+		{
+			name:   "valid",
+			record: map[string]interface{}{"code": "héllo", "qty": float64(2), "price": float64(2), "active": true, "attrs": []interface{}{1}},
+		},
+		{
+			name:   "numeric strings",
+			record: map[string]interface{}{"code": "a", "qty": "-12345678901234567890", "price": "1234567890.123456789"},
+		},
+		{
+			name:   "non-numeric strings",
+			record: map[string]interface{}{"code": "a", "qty": "1.5", "price": "NaN"},
+			want: map[string]string{
+				"qty":   "expected integer, got string",
+				"price": "expected number, got string",
+			},
+		},
+		{
+			name:   "type mismatches",
+			record: map[string]interface{}{"code": float64(1), "qty": 1.5, "price": "9.99 each", "active": float64(1)},
+			want: map[string]string{
+				"code":   "expected string, got number",
+				"qty":    "expected integer, got number",
+				"price":  "expected number, got string",
+				"active": "expected boolean, got number",
+			},
+		},
+		{
+			name:   "missing, null and too long",
+			record: map[string]interface{}{"code": "toolong", "status": nil},
+			want: map[string]string{
+				"code":   "exceeds maximum length of 5 (got 7)",
+				"qty":    "is required",
+				"status": "cannot be null",
+			},
+		},
+		{
+			name:   "unknown and generated columns",
+			record: map[string]interface{}{"id": float64(1), "qtty": float64(1), "code": "a", "qty": float64(1)},
+			want: map[string]string{
+				"id":   "is generated by the database and cannot be written",
+				"qtty": `unknown column (did you mean "qty"?)`,
+			},
+		},
+		{
+			name:   "upsert key",
+			record: map[string]interface{}{"id": float64(1), "code": "a", "qty": float64(1)},
+			keys:   []string{"id"},
+		},
+	}
+	for _, c := range cases {
+		if got := v.checkInsert(c.record, c.keys, nil); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// Updates do not require any column.
+	if got := v.checkUpdate(map[string]interface{}{"price": nil}); got != nil {
+		t.Errorf("update: unexpected errors %v", got)
+	}
+
+	var none *recordValidator
+	if got := none.checkInsert(map[string]interface{}{"anything": 1}, nil, nil); got != nil {
+		t.Errorf("nil validator: unexpected errors %v", got)
+	}
+}
+
+func TestCreateRecords_Validation(t *testing.T) {
+	env := newBatchTestEnv(t)
+
+	body := []map[string]interface{}{
+		{"name": "Alice", "email": "alice@example.com"},
+		{"id": 7, "name": 42},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/users", body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var errResp model.ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &errResp)
+	fields, _ := errResp.Error.Context["fields"].(map[string]interface{})
+	if errResp.Error.Context["record"] != float64(1) || len(fields) != 3 ||
+		fields["id"] == nil || fields["name"] != "expected string, got number" || fields["email"] != "is required" {
+		t.Errorf("unexpected error context %v", errResp.Error.Context)
+	}
+	if count := env.countRows(t); count != 0 {
+		t.Errorf("expected 0 rows, got %d", count)
+	}
+
+	// Continue mode reports the invalid record and inserts the others.
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/users?continue=true", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("continue: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp model.BatchResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Meta.Succeeded != 1 || len(resp.Meta.Errors) != 1 || resp.Meta.Errors[0] != 1 {
+		t.Errorf("continue: unexpected meta %+v", resp.Meta)
+	}
+	failed, _ := resp.Resource[1].(map[string]interface{})
+	detail, _ := failed["error"].(map[string]interface{})
+	if detail["code"] != float64(400) || detail["context"] == nil {
+		t.Errorf("continue: unexpected error entry %v", resp.Resource[1])
+	}
+}
+
+func TestUpdateRecords_Validation(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+
+	cases := []struct {
+		method, path string
+		body         interface{}
+		field        string
+	}{
+		{"PATCH", "/api/v1/testdb/_table/users?ids=1", map[string]interface{}{"name": nil}, "name"},
+		{"PATCH", "/api/v1/testdb/_table/users/1", map[string]interface{}{"email": true}, "email"},
+		{"PUT", "/api/v1/testdb/_table/users", []map[string]interface{}{{"id": 1, "nmae": "Al"}}, "nmae"},
+	}
+	for _, c := range cases {
+		rr := env.do(t, c.method, c.path, c.body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d; body: %s", c.method, c.path, rr.Code, rr.Body.String())
+			continue
+		}
+		var errResp model.ErrorResponse
+		json.Unmarshal(rr.Body.Bytes(), &errResp)
+		fields, _ := errResp.Error.Context["fields"].(map[string]interface{})
+		if _, ok := fields[c.field]; !ok {
+			t.Errorf("%s %s: expected error for %q, got %v", c.method, c.path, c.field, errResp.Error.Context)
+		}
+	}
+}
+
+func TestCreateRecords_NestedValidation(t *testing.T) {
+	env := newBatchTestEnv(t)
+	env.createOrderTables(t)
+
+	// Linked foreign keys (user_id, order_id) count as supplied.
+	body := map[string]interface{}{
+		"user": map[string]interface{}{"name": "Alice", "email": "alice@example.com"},
+		"order_items": []interface{}{
+			map[string]interface{}{"sku": "A-1", "qty": 2},
+			map[string]interface{}{"sku": "B-2"},
+		},
+	}
+	rr := env.do(t, "POST", "/api/v1/testdb/_table/orders", body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var errResp model.ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &errResp)
+	want := map[string]interface{}{"order_items[1].qty": "is required"}
+	if !reflect.DeepEqual(errResp.Error.Context["fields"], want) {
+		t.Errorf("unexpected fields %v", errResp.Error.Context["fields"])
+	}
+}
+
+func TestNumericStringRoundTrip(t *testing.T) {
+	env := newBatchTestEnv(t)
+	ctx := context.Background()
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(ctx, `CREATE TABLE prices (id INTEGER PRIMARY KEY AUTOINCREMENT, amount DECIMAL(12,2) NOT NULL)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if err := env.store.CreateService(ctx, &model.ServiceConfig{Name: "testdb", Driver: "sqlite", DSN: ":memory:",
+		IsActive: true, NumericFormat: "string"}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	// Drivers that return decimals as text send them as strings under
+	// numeric_format "string"; a client writes them back the same way.
+	// SQLite stores them as REAL, so they read back as numbers here.
+	writes := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/api/v1/testdb/_table/prices", map[string]interface{}{"amount": "1234.50"}},
+		{"PATCH", "/api/v1/testdb/_table/prices/1", map[string]interface{}{"amount": "1234.75"}},
+		{"PATCH", "/api/v1/testdb/_table/prices?ids=1", map[string]interface{}{"amount": "1234.25"}},
+		{"POST", "/api/v1/testdb/_batch", map[string]interface{}{"operations": []interface{}{
+			map[string]interface{}{"op": "update", "table": "prices", "ids": []interface{}{1}, "record": map[string]interface{}{"amount": "99.99"}},
+		}}},
+	}
+	for _, w := range writes {
+		if rr := env.do(t, w.method, w.path, w.body); rr.Code != http.StatusOK && rr.Code != http.StatusCreated {
+			t.Errorf("%s %s: expected success, got %d; body: %s", w.method, w.path, rr.Code, rr.Body.String())
+		}
+	}
+	rr := env.do(t, "GET", "/api/v1/testdb/_table/prices/1", nil)
+	var rec map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &rec)
+	if rec["amount"] != 99.99 {
+		t.Errorf("expected amount 99.99, got %v", rec["amount"])
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	env := newBatchTestEnv(t)
+	ctx := context.Background()
+	conn, _ := env.registry.Get("testdb")
+	if _, err := conn.DB().ExecContext(ctx, `CREATE TABLE files (id INTEGER PRIMARY KEY AUTOINCREMENT, data BLOB)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	raw := []byte{0, 1, 0xfe, 0xff}
+	encoded := base64.StdEncoding.EncodeToString(raw)
+
+	// Binary values are read as base64 and written back the same way, on
+	// every write path.
+	writes := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/api/v1/testdb/_table/files", map[string]interface{}{"data": encoded}},
+		{"PATCH", "/api/v1/testdb/_table/files/1", map[string]interface{}{"data": encoded}},
+		{"PATCH", "/api/v1/testdb/_table/files?ids=1", map[string]interface{}{"data": encoded}},
+		{"POST", "/api/v1/testdb/_batch", map[string]interface{}{"operations": []interface{}{
+			map[string]interface{}{"op": "insert", "table": "files", "record": map[string]interface{}{"data": encoded}},
+		}}},
+	}
+	for _, w := range writes {
+		if rr := env.do(t, w.method, w.path, w.body); rr.Code != http.StatusOK && rr.Code != http.StatusCreated {
+			t.Errorf("%s %s: expected success, got %d; body: %s", w.method, w.path, rr.Code, rr.Body.String())
+		}
+	}
+	req := httptest.NewRequest("POST", "/api/v1/testdb/_table/files/_import", strings.NewReader(`{"data":"`+encoded+`"}`+"\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	env.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("import: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	rows, err := conn.DB().QueryContext(ctx, `SELECT data FROM files ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var data []byte
+		rows.Scan(&data)
+		if !bytes.Equal(data, raw) {
+			t.Errorf("row %d: expected %v, got %v", n+1, raw, data)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 rows, got %d", n)
+	}
+	rr = env.do(t, "GET", "/api/v1/testdb/_table/files/1", nil)
+	var rec map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &rec)
+	if rec["data"] != encoded {
+		t.Errorf("expected %q, got %v", encoded, rec["data"])
+	}
+
+	if rr := env.do(t, "POST", "/api/v1/testdb/_table/files", map[string]interface{}{"data": "not base64!"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid base64: expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+	"github.com/faucetdb/faucet/internal/webhook"
+)
+
+// webhookVerbs are the verbs a webhook can be registered for: every verb
+// that writes.
+const webhookVerbs = model.VerbPost | model.VerbPut | model.VerbPatch | model.VerbDelete
+
+// Delivery log page sizes.
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+// WebhookHandler manages the webhooks notified of writes and their
+// delivery log, at /api/v1/system/webhook.
+type WebhookHandler struct {
+	registry   *connector.Registry
+	store      *config.Store
+	dispatcher *webhook.Dispatcher
+}
+
+// NewWebhookHandler creates a new WebhookHandler. Redelivered deliveries
+// are handed to dispatcher's workers.
+func NewWebhookHandler(registry *connector.Registry, store *config.Store, dispatcher *webhook.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		registry:   registry,
+		store:      store,
+		dispatcher: dispatcher,
+	}
+}
+
+// webhookRequest is the body accepted by CreateWebhook and UpdateWebhook.
+type webhookRequest struct {
+	ServiceName string `json:"service_name"`
+	Table       string `json:"table"`
+	VerbMask    int    `json:"verb_mask"`
+	URL         string `json:"url"`
+	Secret      string `json:"secret"`
+	Filter      string `json:"filter"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// ListWebhooks returns every webhook, or those of the service named by
+// ?service. Secrets are not included.
+// GET /api/v1/system/webhook
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.store.ListWebhooks(r.Context(), queryString(r, "service"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list webhooks: "+err.Error())
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	if hooks == nil {
+		hooks = []model.Webhook{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resource": hooks})
+}
+
+// CreateWebhook registers a webhook and returns it with its secret, which
+// is not shown again. A secret is generated when none is given; the verbs
+// default to every write.
+// POST /api/v1/system/webhook
+//
+//	{"service_name": "mydb", "table": "orders", "verb_mask": 2,
+//	 "url": "https://example.com/hooks/orders", "filter": "status = 'paid'"}
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	hook := &model.Webhook{IsActive: true}
+	if !h.applyWebhookRequest(r.Context(), w, hook, &req) {
+		return
+	}
+	if hook.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to create webhook: "+err.Error())
+			return
+		}
+		hook.Secret = secret
+	}
+
+	if err := h.store.CreateWebhook(r.Context(), hook); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create webhook: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+// GetWebhook returns a single webhook, without its secret.
+// GET /api/v1/system/webhook/{webhookId}
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// UpdateWebhook replaces a webhook's settings. An omitted secret or
+// is_active keeps the current value.
+// PUT /api/v1/system/webhook/{webhookId}
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if !h.applyWebhookRequest(r.Context(), w, hook, &req) {
+		return
+	}
+
+	if err := h.store.UpdateWebhook(r.Context(), hook); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update webhook: "+err.Error())
+		return
+	}
+	hook.Secret = ""
+
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+// DELETE /api/v1/system/webhook/{webhookId}
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete webhook: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Webhook deleted",
+	})
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first. ?status
+// selects pending, sending, delivered or dead deliveries; ?status=dead is
+// the dead-letter list. ?limit caps the entries returned (default 100, at
+// most 1000).
+// GET /api/v1/system/webhook/{webhookId}/deliveries
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	status := queryString(r, "status")
+	switch status {
+	case "", model.DeliveryPending, model.DeliverySending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status: use pending, sending, delivered or dead")
+		return
+	}
+	limit := queryInt(r, "limit", defaultDeliveryLimit)
+	if limit <= 0 || limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+
+	deliveries, err := h.store.ListWebhookDeliveries(r.Context(), hook.ID, status, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list deliveries: "+err.Error())
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resource": deliveries})
+}
+
+// Redeliver queues a delivery to be sent again with a fresh set of
+// attempts, whatever its outcome so far, and returns it with 202 Accepted.
+// POST /api/v1/system/webhook/{webhookId}/deliveries/{deliveryId}/redeliver
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "deliveryId")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid delivery ID: "+idStr)
+		return
+	}
+
+	delivery, err := h.store.GetWebhookDelivery(r.Context(), id)
+	if err != nil && !errors.Is(err, config.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, "Failed to get delivery: "+err.Error())
+		return
+	}
+	if delivery == nil || delivery.WebhookID != hook.ID {
+		writeError(w, http.StatusNotFound, "Delivery not found: "+idStr)
+		return
+	}
+
+	requeued, err := h.store.RequeueWebhookDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to redeliver: "+err.Error())
+		return
+	}
+	if !requeued {
+		writeError(w, http.StatusConflict, "Delivery is being sent")
+		return
+	}
+	if h.dispatcher != nil {
+		h.dispatcher.Wake()
+	}
+
+	delivery, err = h.store.GetWebhookDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get delivery: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// webhook loads the webhook named by the URL, writing an error response
+// when it does not exist.
+func (h *WebhookHandler) webhook(w http.ResponseWriter, r *http.Request) (*model.Webhook, bool) {
+	idStr := chi.URLParam(r, "webhookId")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid webhook ID: "+idStr)
+		return nil, false
+	}
+
+	hook, err := h.store.GetWebhook(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, config.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Webhook not found: "+idStr)
+			return nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get webhook: "+err.Error())
+		return nil, false
+	}
+	return hook, true
+}
+
+// applyWebhookRequest validates a create or update request and copies it
+// onto hook, writing a 400 response when it is invalid.
+func (h *WebhookHandler) applyWebhookRequest(ctx context.Context, w http.ResponseWriter, hook *model.Webhook, req *webhookRequest) bool {
+	if req.ServiceName == "" {
+		writeError(w, http.StatusBadRequest, "service_name is required")
+		return false
+	}
+	if _, err := h.store.GetServiceByName(ctx, req.ServiceName); err != nil {
+		writeError(w, http.StatusBadRequest, "Service not found: "+req.ServiceName)
+		return false
+	}
+	if req.Table == "" {
+		req.Table = "*"
+	}
+	if req.VerbMask == 0 {
+		req.VerbMask = webhookVerbs
+	}
+	if req.VerbMask&^webhookVerbs != 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf(
+			"Invalid verb_mask: combine POST (%d), PUT (%d), PATCH (%d) and DELETE (%d)",
+			model.VerbPost, model.VerbPut, model.VerbPatch, model.VerbDelete))
+		return false
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "Invalid url: use an absolute http or https URL")
+		return false
+	}
+
+	// Filters are checked against the table's columns; a filter on every
+	// table can only be checked for syntax.
+	var cols query.ColumnSet
+	if req.Table != "*" {
+		ts, err := h.registry.TableSchema(ctx, req.ServiceName, req.Table)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Table not found: "+req.Table)
+			return false
+		}
+		cols = make(query.ColumnSet, len(ts.Columns))
+		for _, c := range ts.Columns {
+			cols[c.Name] = c.JsonType
+		}
+	}
+	if _, err := query.CompileRowFilter(req.Filter, cols); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid filter: "+err.Error())
+		return false
+	}
+
+	hook.ServiceName = req.ServiceName
+	hook.Table = req.Table
+	hook.VerbMask = req.VerbMask
+	hook.URL = req.URL
+	hook.Filter = req.Filter
+	if req.Secret != "" {
+		hook.Secret = req.Secret
+	}
+	if req.IsActive != nil {
+		hook.IsActive = *req.IsActive
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+	"github.com/faucetdb/faucet/internal/webhook"
+)
+
+// ---------------------------------------------------------------------------
+// Webhooks
+// ---------------------------------------------------------------------------
+
+// webhookReceiver is an in-process webhook endpoint. It checks each
+// delivery's signature against secret and passes the payloads on.
+type webhookReceiver struct {
+	t        *testing.T
+	secret   atomic.Value // string
+	fail     atomic.Bool  // respond 503 while set
+	payloads chan webhook.Payload
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if secret, _ := rc.secret.Load().(string); r.Header.Get(webhook.SignatureHeader) != webhook.Sign(secret, body) {
+		rc.t.Errorf("bad signature %q", r.Header.Get(webhook.SignatureHeader))
+	}
+	if rc.fail.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var p webhook.Payload
+	json.Unmarshal(body, &p)
+	rc.payloads <- p
+}
+
+func (rc *webhookReceiver) next(t *testing.T) webhook.Payload {
+	t.Helper()
+	select {
+	case p := <-rc.payloads:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a webhook delivery")
+		return webhook.Payload{}
+	}
+}
+
+func newWebhookTestEnv(t *testing.T) (*batchTestEnv, *webhookReceiver, string) {
+	t.Helper()
+	env := newBatchTestEnv(t)
+	env.insertSeedData(t)
+	if err := env.store.CreateService(context.Background(), &model.ServiceConfig{
+		Name: "testdb", Driver: "sqlite", DSN: ":memory:", IsActive: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	d, err := webhook.NewDispatcher(env.registry, env.store, webhook.Config{
+		Workers:      1,
+		MaxAttempts:  2,
+		Backoff:      time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	t.Cleanup(d.Close)
+
+	rc := &webhookReceiver{t: t, payloads: make(chan webhook.Payload, 10)}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	wh := NewWebhookHandler(env.registry, env.store, d)
+	th := env.handler
+	r := chi.NewRouter()
+	r.Route("/api/v1/system/webhook", func(r chi.Router) {
+		r.Get("/", wh.ListWebhooks)
+		r.Post("/", wh.CreateWebhook)
+		r.Get("/{webhookId}", wh.GetWebhook)
+		r.Put("/{webhookId}", wh.UpdateWebhook)
+		r.Delete("/{webhookId}", wh.DeleteWebhook)
+		r.Get("/{webhookId}/deliveries", wh.ListDeliveries)
+		r.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", wh.Redeliver)
+	})
+	r.Route("/api/v1/{serviceName}", func(r chi.Router) {
+		r.Use(middleware.Webhooks(d))
+		r.Post("/_table/{tableName}", th.CreateRecords)
+		r.Patch("/_table/{tableName}", th.UpdateRecords)
+		r.Delete("/_table/{tableName}", th.DeleteRecords)
+		r.Patch("/_table/{tableName}/{id}", th.UpdateRecord)
+		r.Post("/_batch", th.ExecuteBatch)
+	})
+	env.router = r
+	return env, rc, srv.URL
+}
+
+func TestWebhookValidation(t *testing.T) {
+	env, _, url := newWebhookTestEnv(t)
+
+	for name, body := range map[string]map[string]interface{}{
+		"missing service": {"url": url},
+		"unknown service": {"service_name": "nope", "url": url},
+		"bad url":         {"service_name": "testdb", "url": "ftp://example.com"},
+		"bad verbs":       {"service_name": "testdb", "url": url, "verb_mask": model.VerbGet},
+		"unknown table":   {"service_name": "testdb", "table": "nope", "url": url},
+		"unknown column":  {"service_name": "testdb", "table": "users", "url": url, "filter": "nope = 1"},
+		"bad filter":      {"service_name": "testdb", "url": url, "filter": "name = "},
+	} {
+		if rr := env.do(t, "POST", "/api/v1/system/webhook", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d; body: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := env.do(t, "GET", "/api/v1/system/webhook/99", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("missing webhook: expected 404, got %d", rr.Code)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	env, rc, url := newWebhookTestEnv(t)
+
+	// Registering returns the generated secret once.
+	rr := env.do(t, "POST", "/api/v1/system/webhook", map[string]interface{}{
+		"service_name": "testdb", "table": "users", "url": url,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create webhook: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var hook model.Webhook
+	json.Unmarshal(rr.Body.Bytes(), &hook)
+	if hook.Secret == "" || hook.Table != "users" || hook.VerbMask != webhookVerbs || !hook.IsActive {
+		t.Fatalf("unexpected webhook: %+v", hook)
+	}
+	rc.secret.Store(hook.Secret)
+	hookPath := fmt.Sprintf("/api/v1/system/webhook/%d", hook.ID)
+	rr = env.do(t, "GET", hookPath, nil)
+	var got model.Webhook
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &got) != nil || got.ID != hook.ID || got.Secret != "" {
+		t.Errorf("get webhook: expected no secret, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Inserts send the created records.
+	rr = env.do(t, "POST", "/api/v1/testdb/_table/users", []map[string]interface{}{
+		{"name": "Carol", "email": "carol@example.com"},
+		{"name": "Dave", "email": "dave@example.com"},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("insert: expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	p := rc.next(t)
+	if p.Event != "insert" || p.Service != "testdb" || p.Table != "users" || p.Count != 2 ||
+		len(p.Records) != 2 || p.Records[1]["name"] != "Dave" {
+		t.Errorf("unexpected insert payload: %+v", p)
+	}
+
+	// Dry runs and failed writes send nothing; the next delivery is the update.
+	env.do(t, "POST", "/api/v1/testdb/_table/users?dry_run=true", map[string]interface{}{"name": "Eve", "email": "eve@example.com"})
+	env.do(t, "POST", "/api/v1/testdb/_table/users", map[string]interface{}{"name": "Alice", "email": "alice@example.com"})
+	if rr := env.do(t, "PATCH", "/api/v1/testdb/_table/users/1", map[string]interface{}{"name": "Alicia"}); rr.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	p = rc.next(t)
+	if p.Event != "update" || p.Count != 1 || len(p.Records) != 1 || p.Records[0]["name"] != "Alicia" {
+		t.Errorf("unexpected update payload: %+v", p)
+	}
+
+	// Deletes by filter report their count.
+	if rr := env.do(t, "DELETE", "/api/v1/testdb/_table/users?filter=name%20%3D%20'Bob'", nil); rr.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	p = rc.next(t)
+	if p.Event != "delete" || p.Count != 1 || len(p.Records) != 0 {
+		t.Errorf("unexpected delete payload: %+v", p)
+	}
+
+	// Batches send one delivery per write operation.
+	rr = env.do(t, "POST", "/api/v1/testdb/_batch", map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"op": "insert", "table": "users", "record": map[string]interface{}{"name": "Frank", "email": "frank@example.com"}},
+		},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("batch: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if p = rc.next(t); p.Event != "insert" || p.Count != 1 || p.Records[0]["name"] != "Frank" {
+		t.Errorf("unexpected batch payload: %+v", p)
+	}
+
+	// A delivery the receiver keeps rejecting ends in the dead-letter list.
+	rc.fail.Store(true)
+	env.do(t, "PATCH", "/api/v1/testdb/_table/users/1", map[string]interface{}{"name": "Ali"})
+	var dead struct {
+		Resource []model.WebhookDelivery `json:"resource"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dead.Resource) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery never went dead")
+		}
+		time.Sleep(10 * time.Millisecond)
+		rr = env.do(t, "GET", hookPath+"/deliveries?status=dead", nil)
+		json.Unmarshal(rr.Body.Bytes(), &dead)
+	}
+	d := dead.Resource[0]
+	if d.Attempts != 2 || d.ResponseStatus != http.StatusServiceUnavailable || d.Event != "update" {
+		t.Errorf("unexpected dead delivery: %+v", d)
+	}
+
+	// Redelivering it succeeds once the receiver is back.
+	rc.fail.Store(false)
+	if rr := env.do(t, "POST", fmt.Sprintf("/api/v1/system/webhook/%d/deliveries/%d/redeliver", hook.ID+1, d.ID), nil); rr.Code != http.StatusNotFound {
+		t.Errorf("redeliver via another webhook: expected 404, got %d", rr.Code)
+	}
+	rr = env.do(t, "POST", fmt.Sprintf("%s/deliveries/%d/redeliver", hookPath, d.ID), nil)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("redeliver: expected 202, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if p = rc.next(t); p.Event != "update" || p.Records[0]["name"] != "Ali" {
+		t.Errorf("unexpected redelivered payload: %+v", p)
+	}
+
+	var log struct {
+		Resource []model.WebhookDelivery `json:"resource"`
+	}
+	rr = env.do(t, "GET", hookPath+"/deliveries", nil)
+	json.Unmarshal(rr.Body.Bytes(), &log)
+	if len(log.Resource) != 5 {
+		t.Errorf("expected 5 deliveries in the log, got %d", len(log.Resource))
+	}
+	if rr := env.do(t, "GET", hookPath+"/deliveries?status=bogus", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad status: expected 400, got %d", rr.Code)
+	}
+
+	// Disabled webhooks are not notified; deleting one removes its log.
+	if rr := env.do(t, "PUT", hookPath, map[string]interface{}{"service_name": "testdb", "table": "users", "url": url, "is_active": false}); rr.Code != http.StatusOK {
+		t.Fatalf("update webhook: expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	env.do(t, "PATCH", "/api/v1/testdb/_table/users/1", map[string]interface{}{"name": "Al"})
+	rr = env.do(t, "GET", hookPath+"/deliveries", nil)
+	json.Unmarshal(rr.Body.Bytes(), &log)
+	if len(log.Resource) != 5 {
+		t.Errorf("expected no delivery for a disabled webhook, got %d deliveries", len(log.Resource))
+	}
+	if rr := env.do(t, "DELETE", hookPath, nil); rr.Code != http.StatusOK {
+		t.Errorf("delete webhook: expected 200, got %d", rr.Code)
+	}
+	if rr := env.do(t, "GET", hookPath+"/deliveries", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("deliveries of a deleted webhook: expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// prepareInsert applies the create rules of a table to records and
+// validates them against the schema. Columns filled only on create are kept
+// out of the update columns of upsert. The returned error is a
+// *columnRuleError or a *recordValidationError.
+func (h *TableHandler) prepareInsert(ctx context.Context, serviceName, tableName string, records []map[string]interface{}, upsert *connector.UpsertOptions) error {
+	if _, ruleErr := h.applyColumnRules(ctx, serviceName, tableName, model.ColumnRuleOnCreate, records); ruleErr != nil {
+		return ruleErr
+	}
+	excludeFromUpsert(upsert, records, h.createOnlyColumns(ctx, serviceName, tableName))
+	return h.recordValidator(ctx, serviceName, tableName).checkRecords(records, upsertKeys(upsert))
+}
+
+// prepareUpdate applies the update rules of a table to record and validates
+// it against the schema. The returned error is a *columnRuleError or a
+// *recordValidationError.
+func (h *TableHandler) prepareUpdate(ctx context.Context, serviceName, tableName string, record map[string]interface{}) error {
+	if _, ruleErr := h.applyColumnRules(ctx, serviceName, tableName, model.ColumnRuleOnUpdate, []map[string]interface{}{record}); ruleErr != nil {
+		return ruleErr
+	}
+	if fields := h.recordValidator(ctx, serviceName, tableName).checkUpdate(record); fields != nil {
+		return newRecordValidationError(0, 1, fields)
+	}
+	return nil
+}
+
+// writePrepareError writes the response for an error of prepareInsert or
+// prepareUpdate.
+func writePrepareError(w http.ResponseWriter, err error) {
+	var ruleErr *columnRuleError
+	if errors.As(err, &ruleErr) {
+		writeColumnRuleError(w, ruleErr)
+		return
+	}
+	writeQueryError(w, err)
+}
+
+// WritePreparer applies the column rules and schema validation of the REST
+// write endpoints to records written by other front ends, such as the MCP
+// tools, so that no write path can bypass them.
+type WritePreparer struct {
+	h *TableHandler
+}
+
+// NewWritePreparer creates a WritePreparer for the services of registry.
+func NewWritePreparer(registry *connector.Registry, store *config.Store) *WritePreparer {
+	return &WritePreparer{h: NewTableHandler(registry, store)}
+}
+
+// PrepareInsert fills and checks records about to be inserted into a table,
+// as a create request would. upsert may be nil.
+func (p *WritePreparer) PrepareInsert(ctx context.Context, serviceName, tableName string, records []map[string]interface{}, upsert *connector.UpsertOptions) error {
+	return p.h.prepareInsert(ctx, serviceName, tableName, records, upsert)
+}
+
+// PrepareUpdate fills and checks a record about to be written to existing
+// rows of a table, as an update request would.
+func (p *WritePreparer) PrepareUpdate(ctx context.Context, serviceName, tableName string, record map[string]interface{}) error {
+	return p.h.prepareUpdate(ctx, serviceName, tableName, record)
+}
@@ -0,0 +1,364 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/faucetdb/faucet/internal/changes"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// Change streams are exposed as the resource faucet://changes/{service}/{table}.
+// A client subscribes to it with resources/subscribe and is sent
+// notifications/resources/updated whenever a change it can see is captured;
+// each read then returns the changes since its previous read. mcp-go does
+// not route resources/subscribe to the server, so both transports intercept
+// it before handing messages on (see subscriptionMiddleware and
+// ServeStdio).
+
+const changesURIPrefix = "faucet://changes/"
+
+// changeFeed is one session's subscription to a change resource.
+type changeFeed struct {
+	sub    *changes.Subscription
+	view   *changes.View
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	cursor string // offset of the last change returned by a read
+}
+
+// changesResult is the content of a change resource.
+type changesResult struct {
+	Offset  string          `json:"offset"`
+	Changes []changes.Event `json:"changes"`
+}
+
+// SetChangeHub enables the change stream resources, reading changes from
+// hub.
+func (s *MCPServer) SetChangeHub(hub *changes.Hub) {
+	s.changes = hub
+	s.server.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			changesURIPrefix+"{service}/{table}{?filter,after}",
+			"Table Changes",
+			mcp.WithTemplateDescription(
+				"Inserts, updates and deletes of a table, for services with change streams "+
+					"enabled. Subscribe to be notified of new changes; each read returns the "+
+					"changes since the previous one. Optional filter (e.g. status = 'paid') "+
+					"and after (an offset to read from).",
+			),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		s.handleChangesResource,
+	)
+}
+
+// handleChangesResource returns the changes of a table after the request's
+// after offset, or after the session's previous read of a subscribed
+// resource. Otherwise it returns no changes and the offset to read from.
+func (s *MCPServer) handleChangesResource(
+	ctx context.Context,
+	request mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+
+	uri := request.Params.URI
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid changes URI %q: %w", uri, err)
+	}
+	after := u.Query().Get("after")
+
+	var result changesResult
+	if f := s.feed(sessionID(ctx), uri); f != nil && after == "" {
+		f.mu.Lock()
+		batch, err := f.sub.Read(ctx, f.cursor)
+		if err == nil && len(batch) > 0 {
+			f.cursor = batch[len(batch)-1].Offset
+		}
+		result.Offset = f.cursor
+		f.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		result.Changes = f.view.Events(batch)
+	} else {
+		sub, view, err := s.openChanges(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		defer sub.Close()
+
+		if after == "" {
+			result.Offset, err = sub.Last(ctx)
+		} else {
+			var batch []connector.Change
+			if batch, err = sub.Read(ctx, after); err == nil {
+				result.Offset = after
+				if len(batch) > 0 {
+					result.Offset = batch[len(batch)-1].Offset
+				}
+				result.Changes = view.Events(batch)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result.Changes == nil {
+		result.Changes = []changes.Event{}
+	}
+
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal changes: %w", err)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(b),
+		},
+	}, nil
+}
+
+// openChanges subscribes to the table of a change resource URI and builds
+// the view its filter parameter selects.
+func (s *MCPServer) openChanges(ctx context.Context, uri string) (*changes.Subscription, *changes.View, error) {
+	if s.changes == nil {
+		return nil, nil, errors.New("change streams are not available")
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid changes URI %q: %w", uri, err)
+	}
+	serviceName, tableName, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Scheme != "faucet" || u.Host != "changes" || !ok || serviceName == "" || tableName == "" {
+		return nil, nil, fmt.Errorf("invalid changes URI %q: expected %s{service}/{table}", uri, changesURIPrefix)
+	}
+
+	svc, err := s.store.GetServiceByName(ctx, serviceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service %q not found: %w", serviceName, err)
+	}
+	if !svc.ChangeStreams {
+		return nil, nil, fmt.Errorf("change streams are not enabled for service %q", serviceName)
+	}
+	conn, err := s.registry.Get(serviceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service %q not found: %w", serviceName, err)
+	}
+	ts, err := s.registry.TableSchema(ctx, serviceName, tableName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("table %q not found in service %q: %w", tableName, serviceName, err)
+	}
+
+	view := &changes.View{
+		Table:   tableName,
+		Encoder: connector.NewValueEncoder(conn, ts, svc.NumericFormat),
+	}
+	if filter := u.Query().Get("filter"); filter != "" {
+		cols := make(query.ColumnSet, len(ts.Columns))
+		for _, c := range ts.Columns {
+			cols[c.Name] = c.JsonType
+		}
+		if view.Match, err = query.CompileRowFilter(filter, cols); err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	sub, err := s.changes.Subscribe(ctx, serviceName, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, view, nil
+}
+
+// subscribe starts notifying a session of changes to a change resource.
+// Other resources never change, so subscribing to them does nothing.
+func (s *MCPServer) subscribe(ctx context.Context, session, uri string) error {
+	if !strings.HasPrefix(uri, changesURIPrefix) {
+		return nil
+	}
+	if session == "" {
+		return errors.New("subscriptions need a session")
+	}
+	sub, view, err := s.openChanges(ctx, uri)
+	if err != nil {
+		return err
+	}
+	cursor, err := sub.Last(ctx)
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	f := &changeFeed{sub: sub, view: view, cancel: cancel, cursor: cursor}
+	s.feedsMu.Lock()
+	if s.feeds == nil {
+		s.feeds = make(map[string]map[string]*changeFeed)
+	}
+	if s.feeds[session] == nil {
+		s.feeds[session] = make(map[string]*changeFeed)
+	}
+	if old := s.feeds[session][uri]; old != nil {
+		old.cancel()
+	}
+	s.feeds[session][uri] = f
+	s.feedsMu.Unlock()
+
+	go func() {
+		defer sub.Close()
+		err := sub.Stream(streamCtx, cursor, func(batch []connector.Change) error {
+			if len(f.view.Events(batch)) == 0 {
+				return nil
+			}
+			err := s.server.SendNotificationToSpecificClient(session,
+				mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+			if errors.Is(err, server.ErrSessionNotFound) {
+				return err
+			}
+			return nil
+		})
+		if err != nil && streamCtx.Err() == nil {
+			s.logger.Info("MCP change subscription ended", "session", session, "uri", uri, "reason", err)
+		}
+		s.unsubscribe(session, uri, f)
+	}()
+	return nil
+}
+
+// unsubscribe stops a session's subscription to uri. When f is not nil,
+// only that subscription is stopped, not one that replaced it.
+func (s *MCPServer) unsubscribe(session, uri string, f *changeFeed) {
+	s.feedsMu.Lock()
+	defer s.feedsMu.Unlock()
+	cur := s.feeds[session][uri]
+	if cur == nil || (f != nil && cur != f) {
+		return
+	}
+	cur.cancel()
+	delete(s.feeds[session], uri)
+	if len(s.feeds[session]) == 0 {
+		delete(s.feeds, session)
+	}
+}
+
+// feed returns a session's subscription to uri, if any.
+func (s *MCPServer) feed(session, uri string) *changeFeed {
+	s.feedsMu.Lock()
+	defer s.feedsMu.Unlock()
+	return s.feeds[session][uri]
+}
+
+// handleSubscription answers a resources/subscribe or resources/unsubscribe
+// request. ok is false for every other message, which is left to mcp-go.
+func (s *MCPServer) handleSubscription(ctx context.Context, session string, message []byte) (resp mcp.JSONRPCMessage, ok bool) {
+	var req struct {
+		ID     mcp.RequestId       `json:"id"`
+		Method string              `json:"method"`
+		Params mcp.SubscribeParams `json:"params"`
+	}
+	if err := json.Unmarshal(message, &req); err != nil {
+		return nil, false
+	}
+	switch req.Method {
+	case "resources/subscribe":
+		if err := s.subscribe(ctx, session, req.Params.URI); err != nil {
+			return mcp.NewJSONRPCError(req.ID, mcp.INVALID_PARAMS, err.Error(), nil), true
+		}
+	case "resources/unsubscribe":
+		s.unsubscribe(session, req.Params.URI, nil)
+	default:
+		return nil, false
+	}
+	return mcp.NewJSONRPCResultResponse(req.ID, mcp.EmptyResult{}), true
+}
+
+// subscriptionMiddleware answers subscription requests sent over the
+// Streamable HTTP transport.
+func (s *MCPServer) subscriptionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		resp, ok := s.handleSubscription(r.Context(), r.Header.Get(server.HeaderKeySessionID), body)
+		if !ok {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// interceptStdin answers subscription requests read from stdin, writing
+// the responses to out, and passes every other line on to the returned
+// reader.
+func (s *MCPServer) interceptStdin(ctx context.Context, stdin io.Reader, out io.Writer) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(stdin)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				if resp, ok := s.handleSubscription(ctx, stdioSessionID, line); ok {
+					b, _ := json.Marshal(resp)
+					_, _ = out.Write(append(b, '\n'))
+				} else if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// stdioSessionID is the ID mcp-go gives the single stdio session.
+const stdioSessionID = "stdio"
+
+// lockedWriter serializes writes, so that subscription responses and the
+// stdio server's own messages do not interleave.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// sessionID returns the ID of the MCP session handling ctx, if any.
+func sessionID(ctx context.Context) string {
+	if cs := server.ClientSessionFromContext(ctx); cs != nil {
+		return cs.SessionID()
+	}
+	return ""
+}
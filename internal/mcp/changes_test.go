@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/faucetdb/faucet/internal/changes"
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// stdioClient drives an MCPServer over the stdio transport.
+type stdioClient struct {
+	in    io.Writer
+	lines chan map[string]interface{}
+}
+
+func (c *stdioClient) send(t *testing.T, msg string) {
+	t.Helper()
+	if _, err := io.WriteString(c.in, msg+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+// await returns the next message for which match is true, skipping others.
+func (c *stdioClient) await(t *testing.T, match func(map[string]interface{}) bool) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-c.lines:
+			if match(msg) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for a message")
+		}
+	}
+}
+
+func withID(id float64) func(map[string]interface{}) bool {
+	return func(msg map[string]interface{}) bool { return msg["id"] == id }
+}
+
+func TestChangeSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	defer store.Close()
+	dsn := filepath.Join(t.TempDir(), "mcp.db")
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{Driver: "sqlite", DSN: dsn}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	defer registry.Disconnect("testdb")
+	conn, _ := registry.Get("testdb")
+	if _, err := conn.DB().Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateService(ctx, &model.ServiceConfig{
+		Name: "testdb", Driver: "sqlite", DSN: dsn, IsActive: true, ChangeStreams: true,
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	hub := changes.NewHub(registry, changes.Config{PollInterval: 10 * time.Millisecond})
+	defer hub.Close()
+	s := NewMCPServer(registry, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetChangeHub(hub)
+
+	// Wire the stdio transport the way ServeStdio does, over pipes.
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	defer inW.Close()
+	out := &lockedWriter{w: outW}
+	go func() {
+		_ = server.NewStdioServer(s.server).Listen(ctx, s.interceptStdin(ctx, inR, out), out)
+	}()
+	c := &stdioClient{in: inW, lines: make(chan map[string]interface{}, 20)}
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var msg map[string]interface{}
+			if json.Unmarshal(scanner.Bytes(), &msg) == nil {
+				c.lines <- msg
+			}
+		}
+	}()
+
+	c.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	c.await(t, withID(1))
+	c.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	const uri = "faucet://changes/testdb/orders?filter=status%20%3D%20%27paid%27"
+	c.send(t, `{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"`+uri+`"}}`)
+	if resp := c.await(t, withID(2)); resp["error"] != nil {
+		t.Fatalf("subscribe: %v", resp["error"])
+	}
+
+	if _, err := conn.DB().Exec(`INSERT INTO orders (id, status) VALUES (1, 'new'), (2, 'paid')`); err != nil {
+		t.Fatal(err)
+	}
+	note := c.await(t, func(msg map[string]interface{}) bool { return msg["method"] == "notifications/resources/updated" })
+	if params, _ := note["params"].(map[string]interface{}); params["uri"] != uri {
+		t.Errorf("notification params = %v", note["params"])
+	}
+
+	c.send(t, `{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"`+uri+`"}}`)
+	resp := c.await(t, withID(3))
+	var read struct {
+		Result struct {
+			Contents []struct {
+				Text string `json:"text"`
+			} `json:"contents"`
+		} `json:"result"`
+	}
+	b, _ := json.Marshal(resp)
+	if err := json.Unmarshal(b, &read); err != nil || len(read.Result.Contents) != 1 {
+		t.Fatalf("read: %s", b)
+	}
+	var result changesResult
+	if err := json.Unmarshal([]byte(read.Result.Contents[0].Text), &result); err != nil {
+		t.Fatalf("decode changes: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Row["id"] != float64(2) || result.Offset == "" {
+		t.Errorf("changes = %+v", result)
+	}
+
+	// The next read starts after the previous one.
+	c.send(t, `{"jsonrpc":"2.0","id":4,"method":"resources/read","params":{"uri":"`+uri+`"}}`)
+	if resp := c.await(t, withID(4)); !strings.Contains(string(mustMarshal(resp)), `\"changes\": []`) {
+		t.Errorf("second read = %s", mustMarshal(resp))
+	}
+
+	c.send(t, `{"jsonrpc":"2.0","id":5,"method":"resources/unsubscribe","params":{"uri":"`+uri+`"}}`)
+	c.await(t, withID(5))
+	if s.feed(stdioSessionID, uri) != nil {
+		t.Error("subscription survived unsubscribe")
+	}
+
+	c.send(t, `{"jsonrpc":"2.0","id":6,"method":"resources/subscribe","params":{"uri":"faucet://changes/testdb/missing"}}`)
+	if resp := c.await(t, withID(6)); resp["error"] == nil {
+		t.Error("subscribing to a missing table should fail")
+	}
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
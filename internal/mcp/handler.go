@@ -30,6 +30,11 @@ func optionalInt(request mcp.CallToolRequest, key string, defaultVal int) int {
 	return request.GetInt(key, defaultVal)
 }
 
+// optionalBool extracts an optional boolean argument from the tool request.
+func optionalBool(request mcp.CallToolRequest, key string) bool {
+	return request.GetBool(key, false)
+}
+
 // optionalStringSlice extracts an optional string slice argument from the tool request.
 func optionalStringSlice(request mcp.CallToolRequest, key string) []string {
 	return request.GetStringSlice(key, nil)
@@ -2,6 +2,8 @@ package mcp
 
 import (
 	"testing"
+
+	"github.com/faucetdb/faucet/internal/connector"
 )
 
 func TestClamp(t *testing.T) {
@@ -29,7 +31,7 @@ func TestClamp(t *testing.T) {
 	}
 }
 
-func TestCleanMapValues(t *testing.T) {
+func TestEncodeRowGeneric(t *testing.T) {
 	m := map[string]interface{}{
 		"bytes_val":  []byte("hello"),
 		"string_val": "world",
@@ -38,11 +40,11 @@ func TestCleanMapValues(t *testing.T) {
 		"bool_val":   true,
 	}
 
-	cleanMapValues(m)
+	connector.NewValueEncoder(nil, nil, "").EncodeRow(m)
 
 	// []byte should be converted to string
 	if s, ok := m["bytes_val"].(string); !ok {
-		t.Errorf("bytes_val should be string after cleaning, got %T", m["bytes_val"])
+		t.Errorf("bytes_val should be string after encoding, got %T", m["bytes_val"])
 	} else if s != "hello" {
 		t.Errorf("bytes_val = %q, want %q", s, "hello")
 	}
@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// namedQueryToolPrefix prefixes the tools generated for saved queries, so
+// they can be told apart from the built-in faucet_* tools when syncing.
+const namedQueryToolPrefix = "faucet_run_"
+
+// namedQueryToolName returns the MCP tool name for a saved query.
+func namedQueryToolName(nq *model.NamedQuery) string {
+	return namedQueryToolPrefix + nq.ServiceName + "_" + nq.Name
+}
+
+// SyncQueryTools registers one MCP tool per saved query and removes the tools
+// of queries that no longer exist. It is called once at startup and again
+// whenever an admin creates, updates or deletes a saved query.
+func (s *MCPServer) SyncQueryTools(ctx context.Context) {
+	queries, err := s.store.ListAllNamedQueries(ctx)
+	if err != nil {
+		s.logger.Error("failed to load saved queries for MCP", "error", err)
+		return
+	}
+
+	want := make(map[string]bool, len(queries))
+	for i := range queries {
+		nq := &queries[i]
+		name := namedQueryToolName(nq)
+		want[name] = true
+		s.server.AddTool(namedQueryTool(nq), s.namedQueryHandler(nq.ServiceName, nq.Name))
+	}
+
+	var stale []string
+	for name := range s.server.ListTools() {
+		if strings.HasPrefix(name, namedQueryToolPrefix) && !want[name] {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		s.server.DeleteTools(stale...)
+	}
+}
+
+// namedQueryTool builds the tool definition for a saved query, with one typed
+// argument per declared parameter.
+func namedQueryTool(nq *model.NamedQuery) mcp.Tool {
+	desc := nq.Description
+	if desc == "" {
+		desc = fmt.Sprintf("Run the saved query %q on service %q.", nq.Name, nq.ServiceName)
+	}
+	if nq.Write {
+		desc += " This query modifies data."
+	}
+
+	annotation := readOnlyAnnotation()
+	if nq.Write {
+		annotation = mutatingAnnotation()
+	}
+	opts := []mcp.ToolOption{
+		mcp.WithDescription(desc),
+		mcp.WithToolAnnotation(annotation),
+	}
+
+	for _, p := range nq.Params {
+		var props []mcp.PropertyOption
+		if p.Description != "" {
+			props = append(props, mcp.Description(p.Description))
+		}
+		if p.Required && p.Default == nil {
+			props = append(props, mcp.Required())
+		}
+		switch p.Type {
+		case "integer", "number":
+			opts = append(opts, mcp.WithNumber(p.Name, props...))
+		case "boolean":
+			opts = append(opts, mcp.WithBoolean(p.Name, props...))
+		default:
+			opts = append(opts, mcp.WithString(p.Name, props...))
+		}
+	}
+
+	return mcp.NewTool(namedQueryToolName(nq), opts...)
+}
+
+// namedQueryHandler returns the tool handler for a saved query. The query
+// definition is re-read on every call so edits take effect immediately.
+func (s *MCPServer) namedQueryHandler(serviceName, queryName string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		nq, err := s.store.GetNamedQuery(ctx, serviceName, queryName)
+		if err != nil {
+			return toolError("Saved query %q not found on service %q: %v", queryName, serviceName, err)
+		}
+
+		if nq.Write {
+			svc, err := s.store.GetServiceByName(ctx, serviceName)
+			if err == nil && svc.ReadOnly {
+				return toolError("Service %q is read-only. Query %q modifies data and is not permitted.", serviceName, queryName)
+			}
+		}
+
+		conn, err := s.registry.Get(serviceName)
+		if err != nil {
+			return toolError("Service %q not connected. Available services: %v",
+				serviceName, s.registry.ListServices())
+		}
+
+		args := request.GetArguments()
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		sqlStr, params, err := query.BindNamedQuery(nq, args, conn.ParameterPlaceholder)
+		if err != nil {
+			return toolError("Invalid arguments for query %q: %v", queryName, err)
+		}
+
+		queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		db := conn.DB()
+		if nq.Write {
+			result, err := db.ExecContext(queryCtx, sqlStr, params...)
+			if err != nil {
+				return toolError("Query %q failed: %v", queryName, err)
+			}
+			affected, _ := result.RowsAffected()
+			return successJSON(map[string]interface{}{
+				"rows_affected": affected,
+			})
+		}
+
+		rows, err := db.QueryxContext(queryCtx, sqlStr, params...)
+		if err != nil {
+			return toolError("Query %q failed: %v", queryName, err)
+		}
+		defer rows.Close()
+		enc := s.resultEncoder(ctx, conn, serviceName, rows)
+
+		records := make([]map[string]interface{}, 0)
+		for rows.Next() {
+			row := make(map[string]interface{})
+			if err := rows.MapScan(row); err != nil {
+				return toolError("Failed to scan row: %v", err)
+			}
+			enc.EncodeRow(row)
+			records = append(records, row)
+		}
+		if err := rows.Err(); err != nil {
+			return toolError("Row iteration error: %v", err)
+		}
+
+		return successJSON(map[string]interface{}{
+			"records": records,
+			"count":   len(records),
+		})
+	}
+}
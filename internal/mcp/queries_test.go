@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestNamedQueryTool(t *testing.T) {
+	nq := &model.NamedQuery{
+		ServiceName: "mydb",
+		Name:        "top_customers",
+		SQL:         "SELECT * FROM customers WHERE region = :region AND active = :active LIMIT :limit",
+		Params: []model.QueryParam{
+			{Name: "region", Type: "string", Required: true},
+			{Name: "active", Type: "boolean"},
+			{Name: "limit", Type: "integer", Required: true, Default: float64(10)},
+		},
+	}
+
+	tool := namedQueryTool(nq)
+	if tool.Name != "faucet_run_mydb_top_customers" {
+		t.Errorf("tool name = %q", tool.Name)
+	}
+	if tool.Annotations.ReadOnlyHint == nil || !*tool.Annotations.ReadOnlyHint {
+		t.Error("read query should be annotated read-only")
+	}
+
+	wantTypes := map[string]string{"region": "string", "active": "boolean", "limit": "number"}
+	for name, want := range wantTypes {
+		prop, ok := tool.InputSchema.Properties[name].(map[string]interface{})
+		if !ok {
+			t.Fatalf("missing property %q", name)
+		}
+		if prop["type"] != want {
+			t.Errorf("property %q type = %v, want %s", name, prop["type"], want)
+		}
+	}
+	// A parameter with a default is never required from the caller.
+	if len(tool.InputSchema.Required) != 1 || tool.InputSchema.Required[0] != "region" {
+		t.Errorf("required = %v, want [region]", tool.InputSchema.Required)
+	}
+
+	nq.Write = true
+	if tool := namedQueryTool(nq); tool.Annotations.ReadOnlyHint == nil || *tool.Annotations.ReadOnlyHint {
+		t.Error("write query should be annotated as mutating")
+	}
+}
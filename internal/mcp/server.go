@@ -1,15 +1,24 @@
 package mcp
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
+	"github.com/faucetdb/faucet/internal/changes"
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/handler"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/webhook"
 )
 
 // MCPServer wraps the mcp-go server with Faucet-specific tool and resource
@@ -20,6 +29,13 @@ type MCPServer struct {
 	store    *config.Store
 	logger   *slog.Logger
 	server   *server.MCPServer
+	writes   *handler.WritePreparer
+
+	changes *changes.Hub // nil until SetChangeHub
+	feedsMu sync.Mutex
+	feeds   map[string]map[string]*changeFeed // session ID -> URI -> subscription
+
+	webhooks *webhook.Dispatcher // nil until SetWebhooks
 }
 
 // NewMCPServer creates an MCPServer pre-loaded with all Faucet tools and
@@ -29,6 +45,7 @@ func NewMCPServer(registry *connector.Registry, store *config.Store, logger *slo
 		registry: registry,
 		store:    store,
 		logger:   logger,
+		writes:   handler.NewWritePreparer(registry, store),
 	}
 
 	mcpServer := server.NewMCPServer(
@@ -45,6 +62,10 @@ func NewMCPServer(registry *connector.Registry, store *config.Store, logger *slo
 	s.registerResources(mcpServer)
 
 	s.server = mcpServer
+
+	// Register one tool per admin-defined saved query.
+	s.SyncQueryTools(context.Background())
+
 	return s
 }
 
@@ -54,31 +75,57 @@ func (s *MCPServer) Server() *server.MCPServer {
 	return s.server
 }
 
+// SetWebhooks makes the write tools notify webhooks through d.
+func (s *MCPServer) SetWebhooks(d *webhook.Dispatcher) {
+	s.webhooks = d
+}
+
+// emitWrite notifies webhooks of a successful write made by a tool.
+func (s *MCPServer) emitWrite(ctx context.Context, service, table string, verb int, op string, records []map[string]interface{}, count int) {
+	if s.webhooks == nil || count == 0 {
+		return
+	}
+	s.webhooks.Emit(ctx, model.WebhookEvent{
+		Service: service,
+		Table:   table,
+		Verb:    verb,
+		Op:      op,
+		Records: records,
+		Count:   count,
+	})
+}
+
 // ServeStdio starts the MCP server in stdio mode. This is the primary
 // integration path for Claude Code, Claude Desktop, and other MCP clients
 // that launch the server as a subprocess.
 func (s *MCPServer) ServeStdio() error {
 	s.logger.Info("starting MCP server in stdio mode")
-	return server.ServeStdio(s.server)
+	if s.changes == nil {
+		return server.ServeStdio(s.server)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	out := &lockedWriter{w: os.Stdout}
+	return server.NewStdioServer(s.server).Listen(ctx, s.interceptStdin(ctx, os.Stdin, out), out)
 }
 
 // ServeHTTP starts the MCP server in Streamable HTTP mode, listening on
 // the given address (e.g. ":3001"). This is suitable for remote MCP clients.
 func (s *MCPServer) ServeHTTP(addr string) error {
-	httpServer := server.NewStreamableHTTPServer(s.server,
-		server.WithHeartbeatInterval(30*time.Second),
-	)
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", s.HTTPHandler())
 	s.logger.Info("MCP HTTP server starting", "addr", addr)
-	return httpServer.Start(addr)
+	return http.ListenAndServe(addr, mux)
 }
 
 // HTTPHandler returns an http.Handler implementing the Streamable HTTP MCP
 // transport. This is suitable for mounting on an existing HTTP server/router
 // so the MCP endpoint runs alongside the REST API on the same port.
 func (s *MCPServer) HTTPHandler() http.Handler {
-	return server.NewStreamableHTTPServer(s.server,
+	return s.subscriptionMiddleware(server.NewStreamableHTTPServer(s.server,
 		server.WithHeartbeatInterval(30*time.Second),
-	)
+	))
 }
 
 // toolAnnotation returns a standard ToolAnnotation for read-only vs
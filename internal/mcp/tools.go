@@ -6,10 +6,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
 	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
 	"github.com/faucetdb/faucet/internal/query"
 )
 
@@ -98,14 +100,22 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 			),
 			mcp.WithArray("fields",
 				mcp.Description("List of columns to return, each either a plain column or an "+
-					"aggregate such as \"SUM(amount)\", \"COUNT(*)\", or \"AVG(price) AS avg_price\". "+
-					"Omit for all columns."),
+					"aggregate such as \"SUM(amount)\", \"COUNT(*)\", \"COUNT(DISTINCT customer_id)\", "+
+					"or \"AVG(price) AS avg_price\". Omit for all columns."),
 				mcp.WithStringItems(),
 			),
 			mcp.WithString("group",
 				mcp.Description("Comma-separated columns to GROUP BY (e.g. \"region,status\"). "+
 					"Aggregate in 'fields' to compute per group; order by an aggregate's alias."),
 			),
+			mcp.WithString("having",
+				mcp.Description("Filter applied to groups, using the same syntax as 'filter'. May reference "+
+					"group columns and aggregate aliases from 'fields' (e.g. \"total > 1000\" with "+
+					"fields [\"region\", \"SUM(amount) AS total\"])."),
+			),
+			mcp.WithBoolean("distinct",
+				mcp.Description("Return only distinct rows (SELECT DISTINCT)"),
+			),
 			mcp.WithString("order",
 				mcp.Description("Order clause (e.g. \"created_at DESC, name ASC\")"),
 			),
@@ -115,6 +125,9 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 			mcp.WithNumber("offset",
 				mcp.Description("Number of records to skip for pagination"),
 			),
+			mcp.WithBoolean("dry_run",
+				mcp.Description("Return the generated SQL, its arguments and the number of matching rows without fetching them"),
+			),
 		),
 		s.handleQuery,
 	)
@@ -126,7 +139,8 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 			mcp.WithDescription(
 				"Insert one or more records into a database table. Each record is a "+
 					"JSON object mapping column names to values. Returns the inserted "+
-					"records (with auto-generated fields like IDs) if the database supports RETURNING.",
+					"records (with auto-generated fields like IDs) if the database supports RETURNING. "+
+					"Set upsert to update records that collide with an existing row instead of failing.",
 			),
 			mcp.WithToolAnnotation(mutatingAnnotation()),
 			mcp.WithString("service",
@@ -141,6 +155,23 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 				mcp.Required(),
 				mcp.Description("Array of record objects to insert (e.g. [{\"name\": \"Alice\", \"age\": 30}])"),
 			),
+			mcp.WithBoolean("upsert",
+				mcp.Description("Update existing rows that conflict on the on_conflict columns instead of failing"),
+			),
+			mcp.WithArray("on_conflict",
+				mcp.Description("Columns identifying an existing row for upsert. Defaults to the primary key."),
+				mcp.WithStringItems(),
+			),
+			mcp.WithArray("update_columns",
+				mcp.Description("Columns to overwrite on conflict. Defaults to every inserted column except the on_conflict columns."),
+				mcp.WithStringItems(),
+			),
+			mcp.WithBoolean("ignore_duplicates",
+				mcp.Description("Skip records that conflict with an existing row, leaving it untouched (implies upsert)"),
+			),
+			mcp.WithBoolean("dry_run",
+				mcp.Description("Return the generated SQL, its arguments and the number of rows it would insert. The insert runs in a transaction that is always rolled back."),
+			),
 		),
 		s.handleInsert,
 	)
@@ -169,6 +200,9 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 				mcp.Required(),
 				mcp.Description("Object with column names and new values (e.g. {\"status\": \"archived\"})"),
 			),
+			mcp.WithBoolean("dry_run",
+				mcp.Description("Return the generated SQL, its arguments and the number of rows it would update. The update runs in a transaction that is always rolled back."),
+			),
 		),
 		s.handleUpdate,
 	)
@@ -193,6 +227,9 @@ func (s *MCPServer) registerTools(srv *server.MCPServer) {
 				mcp.Required(),
 				mcp.Description("Filter expression to select records to delete (e.g. \"status = 'expired'\")"),
 			),
+			mcp.WithBoolean("dry_run",
+				mcp.Description("Return the generated SQL, its arguments and the number of rows it would delete. The delete runs in a transaction that is always rolled back."),
+			),
 		),
 		s.handleDelete,
 	)
@@ -392,6 +429,8 @@ func (s *MCPServer) handleQuery(
 	filterStr := optionalString(request, "filter")
 	fields := optionalStringSlice(request, "fields")
 	groupStr := optionalString(request, "group")
+	havingStr := optionalString(request, "having")
+	distinct := optionalBool(request, "distinct")
 	orderStr := optionalString(request, "order")
 	limit := clamp(optionalInt(request, "limit", 25), 1, 1000)
 	offset := optionalInt(request, "offset", 0)
@@ -455,6 +494,22 @@ func (s *MCPServer) handleQuery(
 		}
 	}
 
+	// Parse the HAVING expression; placeholders continue after the filter's.
+	var havingSQL string
+	var havingParams []interface{}
+	if havingStr != "" {
+		parsed, err := query.ParseHaving(havingStr, projection, groupBy, conn.QuoteIdentifier, conn.ParameterPlaceholder, len(filterParams)+1)
+		if err != nil {
+			return toolError("Invalid having expression: %v\n\n"+
+				"Having uses filter syntax over group columns and aggregate aliases.\n"+
+				"  Example: fields [\"region\", \"SUM(amount) AS total\"], group \"region\", having \"total > 1000\"", err)
+		}
+		if parsed != nil {
+			havingSQL = parsed.SQL
+			havingParams = parsed.Params
+		}
+	}
+
 	// Parse order clause.
 	var orderSQL string
 	if orderStr != "" {
@@ -472,10 +527,13 @@ func (s *MCPServer) handleQuery(
 	selectReq := connector.SelectRequest{
 		Table:      tableName,
 		Projection: projection,
+		Distinct:   distinct,
 		Filter:     filterSQL,
 		FilterArgs: filterParams,
 		Order:      orderSQL,
 		GroupBy:    groupBy,
+		Having:     havingSQL,
+		HavingArgs: havingParams,
 		Limit:      limit,
 		Offset:     offset,
 	}
@@ -485,6 +543,9 @@ func (s *MCPServer) handleQuery(
 		names, _ := conn.GetTableNames(ctx)
 		return toolError("Failed to build query: %v\n\nAvailable tables: %v", err, names)
 	}
+	if optionalBool(request, "dry_run") {
+		return dryRunSelect(ctx, conn, selectReq, sqlStr, args)
+	}
 
 	db := conn.DB()
 	rows, err := db.QueryxContext(ctx, sqlStr, args...)
@@ -492,6 +553,7 @@ func (s *MCPServer) handleQuery(
 		return toolError("Query execution failed: %v", err)
 	}
 	defer rows.Close()
+	enc := s.encoder(ctx, conn, serviceName, tableName)
 
 	records := make([]map[string]interface{}, 0)
 	for rows.Next() {
@@ -499,7 +561,7 @@ func (s *MCPServer) handleQuery(
 		if err := rows.MapScan(row); err != nil {
 			return toolError("Failed to scan row: %v", err)
 		}
-		cleanMapValues(row)
+		enc.EncodeRow(row)
 		records = append(records, row)
 	}
 	if err := rows.Err(); err != nil {
@@ -554,6 +616,32 @@ func (s *MCPServer) handleInsert(
 		Records: records,
 	}
 
+	ignore := optionalBool(request, "ignore_duplicates")
+	if optionalBool(request, "upsert") || ignore {
+		if !conn.SupportsUpsert() {
+			return toolError("Upsert is not supported by the %s driver.", conn.DriverName())
+		}
+		onConflict := optionalStringSlice(request, "on_conflict")
+		if len(onConflict) == 0 {
+			if ts, err := s.registry.TableSchema(ctx, serviceName, tableName); err == nil && ts != nil {
+				onConflict = ts.PrimaryKey
+			}
+			if len(onConflict) == 0 {
+				return toolError("Table %q has no primary key. Pass on_conflict with the columns of a unique constraint.", tableName)
+			}
+		}
+		insertReq.Upsert = &connector.UpsertOptions{
+			ConflictColumns:  onConflict,
+			UpdateColumns:    optionalStringSlice(request, "update_columns"),
+			IgnoreDuplicates: ignore,
+		}
+	}
+
+	// Column rules and schema validation apply as they do over REST.
+	if err := s.writes.PrepareInsert(ctx, serviceName, tableName, records, insertReq.Upsert); err != nil {
+		return toolError("%v", err)
+	}
+
 	sqlStr, args, err := conn.BuildInsert(ctx, insertReq)
 	if err != nil {
 		names, _ := conn.GetTableNames(ctx)
@@ -566,9 +654,19 @@ func (s *MCPServer) handleInsert(
 	// wrap the insert in a transaction with SET IDENTITY_INSERT ON/OFF.
 	if conn.DriverName() == "mssql" {
 		if needsIdentity := s.hasIdentityColumnInRecords(ctx, conn, tableName, records); needsIdentity {
-			return s.execMSSQLIdentityInsert(ctx, conn, tableName, sqlStr, args)
+			if optionalBool(request, "dry_run") {
+				return dryRun(ctx, conn, "Insert", sqlStatement{sql: "SET IDENTITY_INSERT " + conn.QuoteIdentifier(tableName) + " ON"}, sqlStatement{sqlStr, args, true})
+			}
+			result, err := s.execMSSQLIdentityInsert(ctx, conn, tableName, sqlStr, args)
+			if err == nil && !result.IsError {
+				s.emitWrite(ctx, serviceName, tableName, model.VerbPost, "insert", records, len(records))
+			}
+			return result, err
 		}
 	}
+	if optionalBool(request, "dry_run") {
+		return dryRun(ctx, conn, "Insert", sqlStatement{sqlStr, args, conn.SupportsReturning()})
+	}
 
 	if conn.SupportsReturning() {
 		rows, err := db.QueryxContext(ctx, sqlStr, args...)
@@ -576,6 +674,7 @@ func (s *MCPServer) handleInsert(
 			return toolError("Insert failed: %v", err)
 		}
 		defer rows.Close()
+		enc := s.encoder(ctx, conn, serviceName, tableName)
 
 		created := make([]map[string]interface{}, 0)
 		for rows.Next() {
@@ -583,13 +682,14 @@ func (s *MCPServer) handleInsert(
 			if err := rows.MapScan(row); err != nil {
 				return toolError("Failed to scan returned row: %v", err)
 			}
-			cleanMapValues(row)
+			enc.EncodeRow(row)
 			created = append(created, row)
 		}
 		if err := rows.Err(); err != nil {
 			return toolError("Row iteration error: %v", err)
 		}
 
+		s.emitWrite(ctx, serviceName, tableName, model.VerbPost, "insert", created, len(created))
 		return successJSON(map[string]interface{}{
 			"inserted": created,
 			"count":    len(created),
@@ -603,6 +703,7 @@ func (s *MCPServer) handleInsert(
 	}
 
 	affected, _ := result.RowsAffected()
+	s.emitWrite(ctx, serviceName, tableName, model.VerbPost, "insert", records, int(affected))
 	return successJSON(map[string]interface{}{
 		"count": affected,
 	})
@@ -646,6 +747,12 @@ func (s *MCPServer) handleUpdate(
 			serviceName, s.registry.ListServices())
 	}
 
+	// Column rules may add SET columns, so they run before the filter is
+	// parsed.
+	if err := s.writes.PrepareUpdate(ctx, serviceName, tableName, record); err != nil {
+		return toolError("%v", err)
+	}
+
 	// Parse filter with startIndex offset past the SET columns so that
 	// indexed placeholders ($N, @pN) don't collide with SET placeholders.
 	numSetCols := len(record)
@@ -675,6 +782,9 @@ func (s *MCPServer) handleUpdate(
 	if err != nil {
 		return toolError("Failed to build update: %v", err)
 	}
+	if optionalBool(request, "dry_run") {
+		return dryRun(ctx, conn, "Update", sqlStatement{sqlStr, args, conn.SupportsReturning()})
+	}
 
 	db := conn.DB()
 
@@ -684,6 +794,7 @@ func (s *MCPServer) handleUpdate(
 			return toolError("Update failed: %v", err)
 		}
 		defer rows.Close()
+		enc := s.encoder(ctx, conn, serviceName, tableName)
 
 		updated := make([]map[string]interface{}, 0)
 		for rows.Next() {
@@ -691,13 +802,14 @@ func (s *MCPServer) handleUpdate(
 			if err := rows.MapScan(row); err != nil {
 				return toolError("Failed to scan returned row: %v", err)
 			}
-			cleanMapValues(row)
+			enc.EncodeRow(row)
 			updated = append(updated, row)
 		}
 		if err := rows.Err(); err != nil {
 			return toolError("Row iteration error: %v", err)
 		}
 
+		s.emitWrite(ctx, serviceName, tableName, model.VerbPatch, "update", updated, len(updated))
 		return successJSON(map[string]interface{}{
 			"updated": updated,
 			"count":   len(updated),
@@ -710,6 +822,7 @@ func (s *MCPServer) handleUpdate(
 	}
 
 	affected, _ := result.RowsAffected()
+	s.emitWrite(ctx, serviceName, tableName, model.VerbPatch, "update", nil, int(affected))
 	return successJSON(map[string]interface{}{
 		"count": affected,
 	})
@@ -773,6 +886,9 @@ func (s *MCPServer) handleDelete(
 	if err != nil {
 		return toolError("Failed to build delete: %v", err)
 	}
+	if optionalBool(request, "dry_run") {
+		return dryRun(ctx, conn, "Delete", sqlStatement{sqlStr, args, false})
+	}
 
 	db := conn.DB()
 
@@ -782,6 +898,7 @@ func (s *MCPServer) handleDelete(
 	}
 
 	affected, _ := result.RowsAffected()
+	s.emitWrite(ctx, serviceName, tableName, model.VerbDelete, "delete", nil, int(affected))
 	return successJSON(map[string]interface{}{
 		"deleted": affected,
 	})
@@ -846,6 +963,7 @@ func (s *MCPServer) handleRawSQL(
 		return toolError("SQL execution failed: %v\n\nSQL: %s", err, sqlStr)
 	}
 	defer rows.Close()
+	enc := s.resultEncoder(ctx, conn, serviceName, rows)
 
 	records := make([]map[string]interface{}, 0)
 	rowCount := 0
@@ -857,7 +975,7 @@ func (s *MCPServer) handleRawSQL(
 		if err := rows.MapScan(row); err != nil {
 			return toolError("Failed to scan row: %v", err)
 		}
-		cleanMapValues(row)
+		enc.EncodeRow(row)
 		records = append(records, row)
 		rowCount++
 	}
@@ -942,12 +1060,90 @@ func (s *MCPServer) execMSSQLIdentityInsert(ctx context.Context, conn connector.
 	})
 }
 
-// cleanMapValues converts []byte values from database scans into strings
-// for clean JSON serialization.
-func cleanMapValues(m map[string]interface{}) {
-	for k, v := range m {
-		if b, ok := v.([]byte); ok {
-			m[k] = string(b)
+// numericFormat returns the service's numeric_format setting.
+func (s *MCPServer) numericFormat(ctx context.Context, serviceName string) string {
+	if s.store == nil {
+		return ""
+	}
+	svc, err := s.store.GetServiceByName(ctx, serviceName)
+	if err != nil {
+		return ""
+	}
+	return svc.NumericFormat
+}
+
+// encoder returns the value encoder for rows of a table, the one the REST
+// API uses, so records read through either look the same.
+func (s *MCPServer) encoder(ctx context.Context, conn connector.Connector, serviceName, tableName string) *connector.ValueEncoder {
+	var table *model.TableSchema
+	if ts, err := s.registry.TableSchema(ctx, serviceName, tableName); err == nil {
+		table = ts
+	}
+	return connector.NewValueEncoder(conn, table, s.numericFormat(ctx, serviceName))
+}
+
+// resultEncoder returns the value encoder for the rows of an ad-hoc query,
+// typed from the result's column types.
+func (s *MCPServer) resultEncoder(ctx context.Context, conn connector.Connector, serviceName string, rows *sqlx.Rows) *connector.ValueEncoder {
+	types, _ := rows.ColumnTypes()
+	return connector.NewResultEncoder(conn, types, s.numericFormat(ctx, serviceName))
+}
+
+// sqlStatement is a built statement and its bound arguments. returnsRows
+// marks writes carrying a RETURNING or OUTPUT clause.
+type sqlStatement struct {
+	sql         string
+	args        []interface{}
+	returnsRows bool
+}
+
+// dryRun runs stmts in a transaction that is always rolled back and returns
+// the SQL, its arguments and the number of rows each statement affected.
+func dryRun(ctx context.Context, conn connector.Connector, op string, stmts ...sqlStatement) (*mcp.CallToolResult, error) {
+	d, err := connector.BeginDryRun(ctx, conn)
+	if err != nil {
+		return toolError("%s dry run failed (begin transaction): %v", op, err)
+	}
+	defer func() { _ = d.Rollback() }()
+
+	for _, stmt := range stmts {
+		if err := d.Run(ctx, stmt.returnsRows, stmt.sql, stmt.args...); err != nil {
+			return toolError("%s would fail: %v\n\nSQL: %s", op, err, stmt.sql)
+		}
+	}
+	affected := d.Affected()
+	return successJSON(model.DryRunResponse{
+		DryRun:     true,
+		Statements: d.Statements,
+		Affected:   &affected,
+		RolledBack: true,
+	})
+}
+
+// dryRunSelect returns the SQL of a query and the number of rows its filter
+// matches, without fetching them.
+func dryRunSelect(ctx context.Context, conn connector.Connector, selectReq connector.SelectRequest, sqlStr string, args []interface{}) (*mcp.CallToolResult, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	resp := model.DryRunResponse{
+		DryRun:     true,
+		Statements: []model.DryRunStatement{{SQL: sqlStr, Args: args}},
+	}
+	if len(selectReq.GroupBy) == 0 && !selectReq.Distinct {
+		countSQL, countArgs, err := conn.BuildCount(ctx, connector.CountRequest{
+			Table:  selectReq.Table,
+			Filter: selectReq.Filter,
+		})
+		if err != nil {
+			return toolError("Failed to build count: %v", err)
+		}
+		var count int64
+		allCountArgs := append(append([]interface{}{}, selectReq.FilterArgs...), countArgs...)
+		if err := conn.DB().QueryRowxContext(ctx, countSQL, allCountArgs...).Scan(&count); err != nil {
+			return toolError("Query execution failed: %v", err)
 		}
+		resp.Affected = &count
 	}
+	return successJSON(resp)
 }
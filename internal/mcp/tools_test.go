@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/server/middleware"
+	"github.com/faucetdb/faucet/internal/webhook"
+)
+
+func TestWriteToolsEmitWebhooks(t *testing.T) {
+	ctx := context.Background()
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	defer store.Close()
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	defer registry.Disconnect("testdb")
+	conn, _ := registry.Get("testdb")
+	if _, err := conn.DB().Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := make(chan webhook.Payload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhook.Payload
+		json.NewDecoder(r.Body).Decode(&p)
+		payloads <- p
+	}))
+	defer receiver.Close()
+	if err := store.CreateWebhook(ctx, &model.Webhook{ServiceName: "testdb", Table: "orders",
+		VerbMask: model.VerbPost | model.VerbDelete, URL: receiver.URL, Secret: "s", IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d, err := webhook.NewDispatcher(registry, store, webhook.Config{Workers: 1, PollInterval: 10 * time.Millisecond, Logger: logger})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	defer d.Close()
+	s := NewMCPServer(registry, store, logger)
+	s.SetWebhooks(d)
+
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) {
+		t.Helper()
+		var req mcp.CallToolRequest
+		req.Params.Arguments = args
+		if res, err := handler(ctx, req); err != nil || res.IsError {
+			t.Fatalf("tool failed: %v %+v", err, res)
+		}
+	}
+	next := func() webhook.Payload {
+		t.Helper()
+		select {
+		case p := <-payloads:
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a webhook delivery")
+			return webhook.Payload{}
+		}
+	}
+
+	call(s.handleInsert, map[string]any{"service": "testdb", "table": "orders",
+		"records": []any{map[string]any{"status": "new"}}})
+	if p := next(); p.Event != "insert" || p.Count != 1 || len(p.Records) != 1 || p.Records[0]["status"] != "new" {
+		t.Errorf("unexpected insert payload: %+v", p)
+	}
+
+	// Updates are not subscribed to; dry runs send nothing.
+	call(s.handleUpdate, map[string]any{"service": "testdb", "table": "orders", "filter": "id = 1",
+		"record": map[string]any{"status": "paid"}})
+	call(s.handleDelete, map[string]any{"service": "testdb", "table": "orders", "filter": "id = 1", "dry_run": true})
+	call(s.handleDelete, map[string]any{"service": "testdb", "table": "orders", "filter": "id = 1"})
+	if p := next(); p.Event != "delete" || p.Count != 1 {
+		t.Errorf("unexpected delete payload: %+v", p)
+	}
+}
+
+func TestWriteToolsApplyColumnRules(t *testing.T) {
+	ctx := context.Background()
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	defer store.Close()
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	defer registry.Disconnect("testdb")
+	conn, _ := registry.Get("testdb")
+	if _, err := conn.DB().Exec(`CREATE TABLE docs (id INTEGER PRIMARY KEY, title TEXT NOT NULL,
+		tenant_id TEXT NOT NULL, updated_by TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateService(ctx, &model.ServiceConfig{Name: "testdb", Driver: "sqlite", DSN: ":memory:", IsActive: true,
+		ColumnRules: []model.ColumnRule{
+			{Table: "docs", Column: "tenant_id", Value: "key.tenant", Mode: model.ColumnRuleReject},
+			{Table: "docs", Column: "updated_by", Value: "principal.label"},
+		}}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	s := NewMCPServer(registry, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	keyCtx := context.WithValue(ctx, middleware.AuthPrincipalKey, &middleware.Principal{Type: "api_key", KeyID: 7,
+		Label: "ci", Metadata: map[string]string{"tenant": "acme"}})
+
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		var req mcp.CallToolRequest
+		req.Params.Arguments = args
+		res, err := handler(keyCtx, req)
+		if err != nil {
+			t.Fatalf("tool error: %v", err)
+		}
+		return res
+	}
+
+	// A client cannot supply a reject column, in any case.
+	if res := call(s.handleInsert, map[string]any{"service": "testdb", "table": "docs",
+		"records": []any{map[string]any{"title": "a", "Tenant_ID": "evil"}}}); !res.IsError {
+		t.Errorf("insert with tenant_id: expected an error, got %+v", res)
+	}
+	if res := call(s.handleUpdate, map[string]any{"service": "testdb", "table": "docs", "filter": "id = 1",
+		"record": map[string]any{"tenant_id": "evil"}}); !res.IsError {
+		t.Errorf("update with tenant_id: expected an error, got %+v", res)
+	}
+
+	// Rule columns are filled in and client values overridden.
+	if res := call(s.handleInsert, map[string]any{"service": "testdb", "table": "docs",
+		"records": []any{map[string]any{"title": "a", "updated_by": "mallory"}}}); res.IsError {
+		t.Fatalf("insert: %+v", res)
+	}
+	var tenant, updatedBy string
+	conn.DB().QueryRowxContext(ctx, "SELECT tenant_id, updated_by FROM docs WHERE id = 1").Scan(&tenant, &updatedBy)
+	if tenant != "acme" || updatedBy != "ci" {
+		t.Errorf("after insert: tenant_id=%q updated_by=%q", tenant, updatedBy)
+	}
+
+	// Schema validation applies too.
+	if res := call(s.handleUpdate, map[string]any{"service": "testdb", "table": "docs", "filter": "id = 1",
+		"record": map[string]any{"title": nil}}); !res.IsError {
+		t.Errorf("update setting title to null: expected an error, got %+v", res)
+	}
+}
@@ -11,6 +11,10 @@ type APIKey struct {
 	KeyPrefix string     `json:"key_prefix" db:"key_prefix"` // First 8 chars for identification
 	Label     string     `json:"label" db:"label"`
 	RoleID    int64      `json:"role_id" db:"role_id"`
+	// Metadata holds admin-assigned attributes of the key (e.g. a tenant ID)
+	// that column rules can copy into written rows.
+	Metadata     map[string]string `json:"metadata,omitempty" db:"-"`
+	MetadataJSON string            `json:"-" db:"metadata_json"`
 	IsActive  bool       `json:"is_active" db:"is_active"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
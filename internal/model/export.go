@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Export job statuses. A job is created pending, picked up by a worker and
+// ends completed, failed or cancelled.
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportCancelled = "cancelled"
+)
+
+// ExportJob is a background export of a table or saved query to a file,
+// created at /api/v1/{service}/_export. Exactly one of Table and Query is
+// set. The finished file is kept in the configured export target until the
+// job expires.
+type ExportJob struct {
+	ID          string                 `json:"id" db:"id"`
+	ServiceName string                 `json:"service_name" db:"service_name"`
+	Table       string                 `json:"table,omitempty" db:"table_name"`
+	Query       string                 `json:"query,omitempty" db:"query_name"`
+	Args        map[string]interface{} `json:"args,omitempty"` // saved query arguments
+	ArgsJSON    string                 `json:"-" db:"args_json"`
+	Filter      string                 `json:"filter,omitempty" db:"filter"`
+	Fields      string                 `json:"fields,omitempty" db:"fields"` // comma-separated
+	Order       string                 `json:"order,omitempty" db:"order_by"`
+	Format      string                 `json:"format" db:"format"` // csv, ndjson or parquet
+	Gzip        bool                   `json:"gzip" db:"gzip"`
+	Status      string                 `json:"status" db:"status"`
+	Rows        int64                  `json:"rows" db:"rows_written"`  // rows written so far
+	Bytes       int64                  `json:"bytes" db:"bytes_written"` // size of the finished file
+	Error       string                 `json:"error,omitempty" db:"error"`
+	Owner       string                 `json:"-" db:"owner"`    // the principal that created the job
+	Location    string                 `json:"-" db:"location"` // key of the file in the export target
+	DownloadURL string                 `json:"download_url,omitempty" db:"-"`
+	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty" db:"finished_at"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+// Done reports whether the job has stopped running.
+func (j *ExportJob) Done() bool {
+	return j.Status == ExportCompleted || j.Status == ExportFailed || j.Status == ExportCancelled
+}
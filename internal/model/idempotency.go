@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a mutating request sent with an
+// Idempotency-Key header. A retry carrying the same key and an identical
+// request is answered from it instead of being executed again.
+type IdempotencyRecord struct {
+	Scope       string    `db:"scope"`       // the principal the key belongs to
+	Key         string    `db:"idem_key"`    // the client-supplied Idempotency-Key
+	Fingerprint string    `db:"fingerprint"` // digest of method, path, query and body
+	Token       string    `db:"token"`       // identifies the reservation; only its holder completes or releases it
+	Status      int       `db:"status"`      // response status; 0 while the request is in flight
+	HeaderJSON  string    `db:"header_json"` // response headers as JSON
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// NamedQuery is an admin-defined, parameterized SQL statement exposed at
+// /api/v1/{service}/_query/{name}. It lets operators publish reporting
+// endpoints without enabling raw SQL for the service. Parameters are
+// referenced in SQL as :name and always bound, never interpolated.
+type NamedQuery struct {
+	ID          int64        `json:"id" db:"id"`
+	ServiceName string       `json:"service_name" db:"service_name"`
+	Name        string       `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	SQL         string       `json:"sql" db:"sql_text"`
+	Params      []QueryParam `json:"params"`
+	ParamsJSON  string       `json:"-" db:"params_json"`
+	Write       bool         `json:"write" db:"is_write"`              // false = read-only (GET or POST), true = mutating (POST only)
+	CacheTTL    int          `json:"cache_ttl" db:"cache_ttl_seconds"` // seconds; 0 disables caching, ignored for writes
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// QueryParam declares a typed parameter of a named query.
+type QueryParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // string, integer, number, boolean
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
@@ -33,6 +33,38 @@ type BatchResponseMeta struct {
 	TookMs    float64 `json:"took_ms"`
 }
 
+// BatchOperationResult is the outcome of one operation of a multi-table
+// batch (POST /{service}/_batch), returned in BatchResponse.Resource in
+// request order.
+type BatchOperationResult struct {
+	Op        string                   `json:"op"`
+	Table     string                   `json:"table,omitempty"`
+	Procedure string                   `json:"procedure,omitempty"`
+	Count     int                      `json:"count"` // rows written, deleted or returned
+	Resource  []map[string]interface{} `json:"resource,omitempty"`
+}
+
+// ImportResponse reports the outcome of a bulk import. Errors lists the
+// rejected rows (1-based positions in the input), capped at the first 1000.
+type ImportResponse struct {
+	Meta   ImportMeta    `json:"meta"`
+	Errors []ImportError `json:"errors,omitempty"`
+}
+
+// ImportMeta summarizes a bulk import.
+type ImportMeta struct {
+	Rows     int64   `json:"rows"` // data rows read from the input
+	Inserted int64   `json:"inserted"`
+	Failed   int64   `json:"failed"`
+	TookMs   float64 `json:"took_ms"`
+}
+
+// ImportError describes one rejected input row.
+type ImportError struct {
+	Row     int64  `json:"row"`
+	Message string `json:"message"`
+}
+
 // ErrorResponse is the standard envelope for error responses.
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
@@ -44,3 +76,23 @@ type ErrorDetail struct {
 	Message string                 `json:"message"`
 	Context map[string]interface{} `json:"context,omitempty"`
 }
+
+// DryRunResponse is returned in place of the usual response when a request
+// carries ?dry_run=true. It lists the SQL the request would run with its
+// bound arguments. Mutations are executed in a transaction that is always
+// rolled back, so Affected is the number of rows the database reported;
+// for reads it is the number of rows matching the filter.
+type DryRunResponse struct {
+	DryRun     bool              `json:"dry_run"`
+	Statements []DryRunStatement `json:"statements"`
+	Affected   *int64            `json:"affected,omitempty"`
+	RolledBack bool              `json:"rolled_back"`      // the statements ran and were rolled back
+	Result     interface{}       `json:"result,omitempty"` // would-be response of nested writes, batches and procedures
+}
+
+// DryRunStatement is one statement of a dry run.
+type DryRunStatement struct {
+	SQL          string        `json:"sql"`
+	Args         []interface{} `json:"args"`
+	RowsAffected *int64        `json:"rows_affected,omitempty"`
+}
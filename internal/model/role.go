@@ -9,6 +9,7 @@ type Role struct {
 	Name        string       `json:"name" db:"name"`
 	Description string       `json:"description" db:"description"`
 	IsActive    bool         `json:"is_active" db:"is_active"`
+	MaxLimit    int          `json:"max_limit" db:"max_limit"` // rows per table read; 0 keeps the server default of 1000
 	Access      []RoleAccess `json:"access"`
 	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
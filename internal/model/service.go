@@ -16,11 +16,45 @@ type ServiceConfig struct {
 	RawSQL     bool   `json:"raw_sql_allowed" db:"raw_sql_allowed"`
 	IsActive   bool   `json:"is_active" db:"is_active"`
 	SchemaLock string `json:"schema_lock" db:"schema_lock"`
+	VersionColumn string `json:"version_column" db:"version_column"` // e.g. "version" or "updated_at"; record ETags hash the whole row when empty or absent
+	NumericFormat string `json:"numeric_format" db:"numeric_format"` // "number" (default) or "string": how decimal columns are encoded in responses
+	ChangeStreams bool `json:"change_streams" db:"change_streams"` // allow _changes streams, which install change capture on the streamed tables
+	PostgREST bool `json:"postgrest" db:"postgrest"` // serve the PostgREST-compatible routes at rest/v1
+	ColumnRules []ColumnRule `json:"column_rules,omitempty"`
 	Pool      PoolConfig `json:"pool"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// ColumnRule names a column that Faucet fills in on every write to a table,
+// e.g. created_by from the authenticated principal or tenant_id from API key
+// metadata, so that clients cannot forget or forge it.
+type ColumnRule struct {
+	Table  string `json:"table"` // table name, or "*" for every table with the column
+	Column string `json:"column"`
+	// Value is the source of the column's value:
+	//   now               current server time (UTC)
+	//   principal.id      admin ID for JWTs, key ID for API keys
+	//   principal.type    "admin" or "api_key"
+	//   principal.role_id role of an API key
+	//   principal.email   email of an admin
+	//   principal.label   label of an API key
+	//   claim.<name>      a JWT claim
+	//   key.<name>        an API key metadata entry
+	Value string `json:"value"`
+	On    string `json:"on,omitempty"`   // create, update or "" for both
+	Mode  string `json:"mode,omitempty"` // override (default) or reject a client-supplied value
+}
+
+// Column rule timing and modes.
+const (
+	ColumnRuleOnCreate = "create"
+	ColumnRuleOnUpdate = "update"
+
+	ColumnRuleOverride = "override"
+	ColumnRuleReject   = "reject"
+)
+
 // PoolConfig controls the database connection pool behavior for a service.
 type PoolConfig struct {
 	MaxOpenConns    int           `yaml:"max_open_conns" json:"max_open_conns"`
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an admin-registered endpoint notified of writes to a service's
+// tables. Table is a table name or "*" for every table; VerbMask selects the
+// writes (VerbPost, VerbPut, VerbPatch, VerbDelete) that trigger it. When
+// Filter is set only written rows matching it are sent. Payloads are signed
+// with Secret.
+type Webhook struct {
+	ID          int64     `json:"id" db:"id"`
+	ServiceName string    `json:"service_name" db:"service_name"`
+	Table       string    `json:"table" db:"table_name"`
+	VerbMask    int       `json:"verb_mask" db:"verb_mask"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"secret,omitempty" db:"secret"` // returned only when the webhook is created
+	Filter      string    `json:"filter,omitempty" db:"filter"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Webhook delivery statuses. A delivery is created pending, claimed by a
+// worker while it is sent, and ends delivered or, once its attempts are
+// used up, dead. Failed attempts return it to pending until its next
+// attempt is due.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one payload queued for a webhook, with the outcome of
+// its latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id" db:"id"`
+	WebhookID      int64           `json:"webhook_id" db:"webhook_id"`
+	Event          string          `json:"event" db:"event"` // insert, update or delete
+	Payload        json.RawMessage `json:"payload" db:"-"`
+	PayloadJSON    string          `json:"-" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty" db:"response_status"` // HTTP status of the latest attempt
+	Error          string          `json:"error,omitempty" db:"error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty" db:"last_attempt_at"`
+}
+
+// WebhookEvent describes a successful write that webhooks are notified of.
+// Records holds the written rows as returned to the client; it is empty for
+// writes that do not return rows, such as deletes by filter, in which case
+// Count is the number of rows written.
+type WebhookEvent struct {
+	Service string
+	Table   string
+	Verb    int    // VerbPost, VerbPut, VerbPatch or VerbDelete
+	Op      string // insert, update or delete
+	Records []map[string]interface{}
+	Count   int
+}
@@ -11,10 +11,11 @@ import (
 
 // ServiceSpec holds the inputs needed to generate an OpenAPI spec for one database service.
 type ServiceSpec struct {
-	Name   string
-	Label  string
-	Driver string
-	Schema *model.Schema
+	Name    string
+	Label   string
+	Driver  string
+	Schema  *model.Schema
+	Queries []model.NamedQuery
 }
 
 // GenerateServiceSpec generates an OpenAPI 3.1 spec for a single database service.
@@ -95,6 +96,15 @@ func GenerateServiceSpec(serviceName, serviceLabel, driver, baseURL string, sche
 		addProcedurePath(doc, serviceName, fn)
 	}
 
+	if len(schema.Tables) > 0 {
+		addBatchPath(doc, serviceName)
+		addExportPaths(doc, serviceName)
+	}
+	if len(schema.Tables) > 0 || len(schema.Views) > 0 {
+		addGraphQLPath(doc, serviceName)
+		addODataPaths(doc, serviceName)
+	}
+
 	return doc
 }
 
@@ -176,6 +186,15 @@ func GenerateCombinedSpec(services []ServiceSpec, baseURL string) *openapi3.T {
 		for _, fn := range svc.Schema.Functions {
 			addServiceProcedurePath(doc, svc.Name, fn)
 		}
+		if len(svc.Schema.Tables) > 0 {
+			addBatchPath(doc, svc.Name)
+			addExportPaths(doc, svc.Name)
+		}
+		if len(svc.Schema.Tables) > 0 || len(svc.Schema.Views) > 0 {
+			addGraphQLPath(doc, svc.Name)
+			addODataPaths(doc, svc.Name)
+		}
+		AddNamedQueryPaths(doc, svc.Name, svc.Queries)
 	}
 
 	return doc
@@ -226,6 +245,36 @@ func addTablePaths(doc *openapi3.T, serviceName string, table model.TableSchema)
 	}
 	doc.Paths.Set(tablePath, pathItem)
 
+	// Single-record endpoints addressed by primary key
+	if keyColumns := recordKeyColumns(table); keyColumns != nil {
+		idParam := &openapi3.ParameterRef{Value: recordIDParameter(keyColumns)}
+		recordRef := openapi3.NewSchemaRef(schemaRef, nil)
+		doc.Paths.Set(tablePath+"/{id}", &openapi3.PathItem{
+			Parameters: openapi3.Parameters{idParam},
+			Get:        recordOperation(tag, "get", table.Name, "Retrieve", "", recordRef),
+			Put:        recordOperation(tag, "replace", table.Name, "Replace", createRef, recordRef),
+			Patch:      recordOperation(tag, "update", table.Name, "Update", updateRef, recordRef),
+			Delete:     recordOperation(tag, "delete", table.Name, "Delete", "", &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}}}),
+		})
+	}
+
+	// Structured JSON query endpoint
+	addQuerySchemas(doc)
+	doc.Paths.Set(tablePath+"/_query", &openapi3.PathItem{
+		Post: structuredQueryOperation(tag, table.Name, listResponseSchema),
+	})
+
+	// Streaming bulk import endpoint
+	doc.Paths.Set(tablePath+"/_import", &openapi3.PathItem{
+		Post: importOperation(tag, table.Name),
+	})
+
+	// Change stream endpoint
+	doc.Paths.Set(tablePath+"/_changes", &openapi3.PathItem{
+		Get:    changesOperation(tag, table.Name),
+		Delete: disableChangesOperation(tag, table.Name),
+	})
+
 	// Schema endpoint
 	doc.Paths.Set(schemaPath, &openapi3.PathItem{
 		Get: schemaOperation(tag, table.Name),
@@ -265,6 +314,11 @@ func addViewPaths(doc *openapi3.T, serviceName string, view model.TableSchema) {
 	}
 	doc.Paths.Set(viewPath, pathItem)
 
+	addQuerySchemas(doc)
+	doc.Paths.Set(viewPath+"/_query", &openapi3.PathItem{
+		Post: structuredQueryOperation(tag, view.Name, listResponseSchema),
+	})
+
 	doc.Paths.Set(schemaPath, &openapi3.PathItem{
 		Get: schemaOperation(tag, view.Name),
 	})
@@ -317,6 +371,406 @@ func addProcedurePath(doc *openapi3.T, serviceName string, proc model.StoredProc
 	doc.Paths.Set(procPath, &openapi3.PathItem{Post: op})
 }
 
+// addExportPaths generates the paths that create, list, inspect and cancel
+// a service's background export jobs.
+func addExportPaths(doc *openapi3.T, serviceName string) {
+	str := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"string"}, Description: desc}}
+	}
+	integer := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"integer"}, Description: desc}}
+	}
+	dateTime := &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"string"}, Format: "date-time"}}
+	format := &openapi3.SchemaRef{Value: &openapi3.Schema{
+		Type: &openapi3.Types{"string"},
+		Enum: []interface{}{"csv", "ndjson", "parquet"},
+	}}
+
+	job := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"id":           str(""),
+				"service_name": str(""),
+				"table":        str(""),
+				"query":        str(""),
+				"args":         &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}}},
+				"filter":       str(""),
+				"fields":       str(""),
+				"order":        str(""),
+				"format":       format,
+				"gzip":         &openapi3.SchemaRef{Value: openapi3.NewBoolSchema()},
+				"status": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type: &openapi3.Types{"string"},
+					Enum: []interface{}{"pending", "running", "completed", "failed", "cancelled"},
+				}},
+				"rows":         integer("Rows written so far."),
+				"bytes":        integer("Size of the finished file."),
+				"error":        str(""),
+				"download_url": str("Set once the export has completed."),
+				"created_at":   dateTime,
+				"started_at":   dateTime,
+				"finished_at":  dateTime,
+				"expires_at":   dateTime,
+			},
+		},
+	}
+	strList := &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"array"}, Items: str("")}}
+	request := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"table":  str("Table to export; set table or query."),
+				"query":  str("Saved query to export."),
+				"args":   &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}, Description: "Saved query arguments."}},
+				"filter": str("Filter expression (tables only)."),
+				"fields": strList,
+				"order":  strList,
+				"format": format,
+				"gzip":   &openapi3.SchemaRef{Value: openapi3.NewBoolSchema()},
+			},
+		},
+	}
+	list := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"resource": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"array"}, Items: job}},
+			},
+		},
+	}
+
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/_export", serviceName), &openapi3.PathItem{
+		Post: &openapi3.Operation{
+			Tags:    []string{serviceName},
+			Summary: "Start a background export of a table or saved query",
+			Description: "Queue an export job that writes every matching row to a CSV, NDJSON or Parquet file. " +
+				"Poll the job for progress and download the file from download_url once it has completed.",
+			OperationID: fmt.Sprintf("createExport_%s", serviceName),
+			RequestBody: &openapi3.RequestBodyRef{
+				Value: &openapi3.RequestBody{
+					Description: "Export to run",
+					Required:    true,
+					Content:     openapi3.NewContentWithJSONSchemaRef(request),
+				},
+			},
+			Responses: newResponses("202", "Export queued", job),
+		},
+		Get: &openapi3.Operation{
+			Tags:        []string{serviceName},
+			Summary:     "List background exports",
+			OperationID: fmt.Sprintf("listExports_%s", serviceName),
+			Responses:   newResponses("200", "Export jobs, newest first", list),
+		},
+	})
+
+	idParam := openapi3.Parameters{{Value: openapi3.NewPathParameter("exportId").
+		WithSchema(openapi3.NewStringSchema())}}
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/_export/{exportId}", serviceName), &openapi3.PathItem{
+		Get: &openapi3.Operation{
+			Tags:        []string{serviceName},
+			Summary:     "Get the status of a background export",
+			OperationID: fmt.Sprintf("getExport_%s", serviceName),
+			Parameters:  idParam,
+			Responses:   newResponses("200", "Export job", job),
+		},
+		Delete: &openapi3.Operation{
+			Tags:        []string{serviceName},
+			Summary:     "Cancel a running export, or delete a finished one and its file",
+			OperationID: fmt.Sprintf("deleteExport_%s", serviceName),
+			Parameters:  idParam,
+			Responses:   newResponses("200", "Export cancelled or deleted", job),
+		},
+	})
+}
+
+// addGraphQLPath generates the path of a service's GraphQL endpoint. The
+// GraphQL schema itself is served by introspection.
+func addGraphQLPath(doc *openapi3.T, serviceName string) {
+	str := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"string"}, Description: desc}}
+	}
+	object := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}, Description: desc}}
+	}
+	request := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type:     &openapi3.Types{"object"},
+			Required: []string{"query"},
+			Properties: openapi3.Schemas{
+				"query":         str("GraphQL document."),
+				"variables":     object("Variable values."),
+				"operationName": str("Operation to run when the document has several."),
+			},
+		},
+	}
+	result := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"data": object(""),
+				"errors": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type:  &openapi3.Types{"array"},
+					Items: object("Field error; extensions.status holds the equivalent REST status code."),
+				}},
+			},
+		},
+	}
+
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/graphql", serviceName), &openapi3.PathItem{
+		Get: &openapi3.Operation{
+			Tags:        []string{serviceName},
+			Summary:     "Run a GraphQL query",
+			OperationID: fmt.Sprintf("graphqlQuery_%s", serviceName),
+			Parameters: openapi3.Parameters{
+				{Value: openapi3.NewQueryParameter("query").WithRequired(true).WithSchema(openapi3.NewStringSchema())},
+				{Value: openapi3.NewQueryParameter("variables").WithDescription("JSON-encoded variables").WithSchema(openapi3.NewStringSchema())},
+				{Value: openapi3.NewQueryParameter("operationName").WithSchema(openapi3.NewStringSchema())},
+			},
+			Responses: newResponses("200", "GraphQL result", result),
+		},
+		Post: &openapi3.Operation{
+			Tags:    []string{serviceName},
+			Summary: "Run a GraphQL query or mutation",
+			Description: "Query and mutate the service's tables through a GraphQL schema generated from the database schema. " +
+				"Access rules, column rules and validation apply as they do to the REST routes.",
+			OperationID: fmt.Sprintf("graphql_%s", serviceName),
+			RequestBody: &openapi3.RequestBodyRef{
+				Value: &openapi3.RequestBody{
+					Required: true,
+					Content:  openapi3.NewContentWithJSONSchemaRef(request),
+				},
+			},
+			Responses: newResponses("200", "GraphQL result", result),
+		},
+	})
+}
+
+// addODataPaths generates the paths of a service's OData endpoint: the CSDL
+// metadata document and entity set reads.
+func addODataPaths(doc *openapi3.T, serviceName string) {
+	query := func(name, desc string, schema *openapi3.Schema) *openapi3.ParameterRef {
+		return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithDescription(desc).WithSchema(schema)}
+	}
+	collection := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"@odata.context":  &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+				"@odata.count":    &openapi3.SchemaRef{Value: openapi3.NewInt64Schema()},
+				"@odata.nextLink": &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+				"value": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type:  &openapi3.Types{"array"},
+					Items: &openapi3.SchemaRef{Value: openapi3.NewObjectSchema()},
+				}},
+			},
+		},
+	}
+
+	metadata := openapi3.NewResponses()
+	metadata.Set("200", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("CSDL XML describing an entity type per table and view").
+		WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"application/xml"}))})
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/odata/$metadata", serviceName), &openapi3.PathItem{
+		Get: &openapi3.Operation{
+			Tags:        []string{serviceName},
+			Summary:     "Get the OData metadata document",
+			OperationID: fmt.Sprintf("odataMetadata_%s", serviceName),
+			Responses:   metadata,
+		},
+	})
+
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/odata/{entitySet}", serviceName), &openapi3.PathItem{
+		Get: &openapi3.Operation{
+			Tags:    []string{serviceName},
+			Summary: "Read an OData entity set",
+			Description: "Reads a table or view as an OData v4 entity set. Pages hold at most the caller's max limit of rows; " +
+				"@odata.nextLink points to the next page.",
+			OperationID: fmt.Sprintf("odataEntitySet_%s", serviceName),
+			Parameters: openapi3.Parameters{
+				{Value: openapi3.NewPathParameter("entitySet").WithSchema(openapi3.NewStringSchema())},
+				query("$filter", "OData filter expression", openapi3.NewStringSchema()),
+				query("$select", "Comma-separated properties", openapi3.NewStringSchema()),
+				query("$orderby", "Comma-separated properties with optional asc or desc", openapi3.NewStringSchema()),
+				query("$top", "Maximum number of entities", openapi3.NewIntegerSchema().WithMin(0)),
+				query("$skip", "Number of entities to skip", openapi3.NewIntegerSchema().WithMin(0)),
+				query("$count", "Include @odata.count", openapi3.NewBoolSchema()),
+				query("$expand", "Navigation properties to embed", openapi3.NewStringSchema()),
+			},
+			Responses: newResponses("200", "Entity collection", collection),
+		},
+	})
+}
+
+// addBatchPath generates the POST path for a service's multi-table
+// transactional batch endpoint (_batch).
+func addBatchPath(doc *openapi3.T, serviceName string) {
+	str := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"string"}, Description: desc}}
+	}
+	object := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}, Description: desc}}
+	}
+	array := func(items *openapi3.SchemaRef, desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"array"}, Items: items, Description: desc}}
+	}
+
+	operation := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type:     &openapi3.Types{"object"},
+			Required: []string{"op"},
+			Properties: openapi3.Schemas{
+				"op": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type: &openapi3.Types{"string"},
+					Enum: []interface{}{"insert", "upsert", "update", "delete", "procedure"},
+				}},
+				"table":             str("Target table (all ops but procedure)."),
+				"records":           array(object(""), "Records to insert or upsert."),
+				"record":            object("Fields to update, or a single record to insert or upsert."),
+				"ids":               array(&openapi3.SchemaRef{Value: &openapi3.Schema{}}, "Primary keys of the rows to update or delete."),
+				"filter":            str("Filter expression selecting the rows to update or delete."),
+				"on_conflict":       array(str(""), "Upsert conflict columns (default: primary key)."),
+				"update_columns":    array(str(""), "Columns an upsert overwrites on conflict."),
+				"ignore_duplicates": &openapi3.SchemaRef{Value: openapi3.NewBoolSchema()},
+				"procedure":         str("Stored procedure to call."),
+				"params":            object("Procedure parameters."),
+			},
+		},
+	}
+	result := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"op":        str(""),
+				"table":     str(""),
+				"procedure": str(""),
+				"count":     &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"integer"}, Description: "Rows written, deleted or returned."}},
+				"resource":  array(object(""), "Rows returned by the operation."),
+			},
+		},
+	}
+	responseSchema := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"resource": array(result, "Per-operation results, in request order."),
+				"meta":     object(""),
+			},
+		},
+	}
+
+	doc.Paths.Set(fmt.Sprintf("/api/v1/%s/_batch", serviceName), &openapi3.PathItem{
+		Post: &openapi3.Operation{
+			Tags:    []string{serviceName},
+			Summary: "Run a multi-table transactional batch",
+			Description: "Run an ordered list of insert, upsert, update, delete and procedure operations in one transaction. " +
+				"A string value \"$ops[N].column\" (or \"$ops[N][M].column\") in records, ids or params refers to a column of an earlier operation's result.",
+			OperationID: fmt.Sprintf("batch_%s", serviceName),
+			RequestBody: &openapi3.RequestBodyRef{
+				Value: &openapi3.RequestBody{
+					Description: "Operations to run",
+					Required:    true,
+					Content: openapi3.NewContentWithJSONSchemaRef(&openapi3.SchemaRef{
+						Value: &openapi3.Schema{
+							Type:     &openapi3.Types{"object"},
+							Required: []string{"operations"},
+							Properties: openapi3.Schemas{
+								"operations": array(operation, ""),
+							},
+						},
+					}),
+				},
+			},
+			Responses: newResponses("200", "Every operation applied", responseSchema),
+		},
+	})
+}
+
+// AddNamedQueryPaths adds a _query/{name} path for each saved query of a
+// service. Read queries accept GET (arguments in the query string) and POST
+// (arguments in a JSON body); write queries accept POST only.
+func AddNamedQueryPaths(doc *openapi3.T, serviceName string, queries []model.NamedQuery) {
+	for _, nq := range queries {
+		queryPath := fmt.Sprintf("/api/v1/%s/_query/%s", serviceName, nq.Name)
+
+		summary := nq.Description
+		if summary == "" {
+			summary = fmt.Sprintf("Run saved query %s", nq.Name)
+		}
+
+		bodyProps := openapi3.Schemas{}
+		var required []string
+		var params openapi3.Parameters
+		for _, p := range nq.Params {
+			paramSchema := &openapi3.Schema{
+				Type:        &openapi3.Types{p.Type},
+				Description: p.Description,
+				Default:     p.Default,
+			}
+			bodyProps[p.Name] = &openapi3.SchemaRef{Value: paramSchema}
+			mandatory := p.Required && p.Default == nil
+			if mandatory {
+				required = append(required, p.Name)
+			}
+			params = append(params, &openapi3.ParameterRef{
+				Value: &openapi3.Parameter{
+					Name:        p.Name,
+					In:          "query",
+					Description: p.Description,
+					Required:    mandatory,
+					Schema:      &openapi3.SchemaRef{Value: paramSchema},
+				},
+			})
+		}
+
+		responses := newResponses("200", "Query result", &openapi3.SchemaRef{
+			Value: &openapi3.Schema{
+				Type: &openapi3.Types{"object"},
+				Properties: openapi3.Schemas{
+					"resource": &openapi3.SchemaRef{
+						Value: &openapi3.Schema{
+							Type:  &openapi3.Types{"array"},
+							Items: &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"object"}}},
+						},
+					},
+					"meta": metaSchema(),
+				},
+			},
+		})
+
+		item := &openapi3.PathItem{
+			Post: &openapi3.Operation{
+				Tags:        []string{serviceName},
+				Summary:     summary,
+				OperationID: fmt.Sprintf("run_query_%s_%s", serviceName, nq.Name),
+				RequestBody: &openapi3.RequestBodyRef{
+					Value: &openapi3.RequestBody{
+						Description: fmt.Sprintf("Arguments for %s", nq.Name),
+						Content: openapi3.NewContentWithJSONSchemaRef(&openapi3.SchemaRef{
+							Value: &openapi3.Schema{
+								Type:       &openapi3.Types{"object"},
+								Properties: bodyProps,
+								Required:   required,
+							},
+						}),
+					},
+				},
+				Responses: responses,
+			},
+		}
+		if !nq.Write {
+			item.Get = &openapi3.Operation{
+				Tags:        []string{serviceName},
+				Summary:     summary,
+				OperationID: fmt.Sprintf("get_query_%s_%s", serviceName, nq.Name),
+				Parameters:  params,
+				Responses:   responses,
+			}
+		}
+		doc.Paths.Set(queryPath, item)
+	}
+}
+
 // addServiceTablePaths is like addTablePaths but for combined spec (same logic, included for clarity).
 func addServiceTablePaths(doc *openapi3.T, serviceName string, table model.TableSchema) {
 	addTablePaths(doc, serviceName, table)
@@ -464,6 +918,251 @@ func listOperation(tag, tableName string, params openapi3.Parameters, responseSc
 	}
 }
 
+// recordKeyColumns returns the columns addressed by a table's /{id} path: the
+// primary key, or the conventional "id" column when none is declared. It
+// returns nil when the table has neither.
+func recordKeyColumns(table model.TableSchema) []string {
+	if len(table.PrimaryKey) > 0 {
+		return table.PrimaryKey
+	}
+	for _, col := range table.Columns {
+		if col.Name == "id" {
+			return []string{"id"}
+		}
+	}
+	return nil
+}
+
+// recordIDParameter describes the {id} path parameter for the given key columns.
+func recordIDParameter(keyColumns []string) *openapi3.Parameter {
+	desc := fmt.Sprintf("Primary key value (%s).", keyColumns[0])
+	if len(keyColumns) > 1 {
+		desc = fmt.Sprintf("Composite primary key: comma-separated values for %s, in that order. Escape commas inside a value as %%2C.",
+			strings.Join(keyColumns, ", "))
+	}
+	return openapi3.NewPathParameter("id").
+		WithDescription(desc).
+		WithSchema(openapi3.NewStringSchema())
+}
+
+// recordOperation generates an operation on a single record addressed by
+// primary key. bodyRef is the request body schema, or "" for none.
+func recordOperation(tag, verb, tableName, action, bodyRef string, responseSchema *openapi3.SchemaRef) *openapi3.Operation {
+	op := &openapi3.Operation{
+		Tags:        []string{tag},
+		Summary:     fmt.Sprintf("%s a %s record by primary key", action, tableName),
+		Description: fmt.Sprintf("%s the %s record identified by {id}. Returns 404 if no record has that key.", action, tableName),
+		OperationID: fmt.Sprintf("%s_%s_by_id", verb, tableName),
+		Responses: newResponses(
+			"200", fmt.Sprintf("The %s record", tableName), responseSchema,
+		),
+	}
+	if verb == "get" {
+		notModified := "Not modified: the record's ETag matches If-None-Match"
+		op.Responses.Set("304", &openapi3.ResponseRef{Value: &openapi3.Response{Description: &notModified}})
+		op.Parameters = openapi3.Parameters{
+			&openapi3.ParameterRef{
+				Value: openapi3.NewQueryParameter("fields").
+					WithDescription("Comma-separated list of fields to return.").
+					WithSchema(openapi3.NewStringSchema()),
+			},
+			&openapi3.ParameterRef{
+				Value: openapi3.NewHeaderParameter("If-None-Match").
+					WithDescription("Return 304 while the record's ETag matches.").
+					WithSchema(openapi3.NewStringSchema()),
+			},
+		}
+	} else {
+		precondition := "Precondition failed: the record's ETag does not match If-Match"
+		op.Responses.Set("412", &openapi3.ResponseRef{Value: &openapi3.Response{Description: &precondition}})
+		op.Parameters = openapi3.Parameters{
+			&openapi3.ParameterRef{
+				Value: openapi3.NewHeaderParameter("If-Match").
+					WithDescription("Only write while the record's ETag matches one of these.").
+					WithSchema(openapi3.NewStringSchema()),
+			},
+		}
+	}
+	if bodyRef != "" {
+		op.RequestBody = &openapi3.RequestBodyRef{
+			Value: &openapi3.RequestBody{
+				Description: fmt.Sprintf("Fields to set on the %s record; key fields are ignored", tableName),
+				Required:    true,
+				Content: openapi3.Content{
+					"application/json": &openapi3.MediaType{
+						Schema: openapi3.NewSchemaRef(bodyRef, nil),
+					},
+				},
+			},
+		}
+	}
+	return op
+}
+
+// structuredQueryOperation generates the POST operation for the structured
+// JSON query endpoint (_table/{name}/_query).
+func structuredQueryOperation(tag, tableName string, responseSchema *openapi3.SchemaRef) *openapi3.Operation {
+	return &openapi3.Operation{
+		Tags:        []string{tag},
+		Summary:     fmt.Sprintf("Query %s records with a JSON query", tableName),
+		Description: fmt.Sprintf("Retrieve records from %s using a structured JSON body instead of query-string parameters. Supports the same fields, order, group and pagination options as the list endpoint; pass meta.next_cursor back as cursor to fetch the next page.", tableName),
+		OperationID: fmt.Sprintf("query_%s", tableName),
+		RequestBody: &openapi3.RequestBodyRef{
+			Value: &openapi3.RequestBody{
+				Description: "Structured query",
+				Required:    true,
+				Content: openapi3.Content{
+					"application/json": &openapi3.MediaType{
+						Schema: openapi3.NewSchemaRef("#/components/schemas/QueryRequest", nil),
+					},
+				},
+			},
+		},
+		Responses: newResponses(
+			"200", fmt.Sprintf("List of %s records", tableName), responseSchema,
+		),
+	}
+}
+
+// changesOperation generates the GET operation that streams a table's
+// changes (_table/{name}/_changes).
+func changesOperation(tag, tableName string) *openapi3.Operation {
+	str := openapi3.NewStringSchema()
+	responses := openapi3.NewResponses()
+	for _, r := range []struct{ code, desc string }{
+		{"400", "Invalid filter or offset"},
+		{"403", "Access denied, or change streams are not enabled"},
+		{"409", "Change capture must be enabled in the database"},
+		{"501", "Change streams are not supported for this database"},
+	} {
+		responses.Set(r.code, &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription(r.desc).
+			WithContent(openapi3.NewContentWithJSONSchemaRef(openapi3.NewSchemaRef("#/components/schemas/ErrorResponse", nil)))})
+	}
+	responses.Set("200", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("Event stream; each event is named by its op (insert, update or delete)").
+		WithContent(openapi3.NewContentWithSchema(str, []string{"text/event-stream"}))})
+
+	return &openapi3.Operation{
+		Tags:    []string{tag},
+		Summary: fmt.Sprintf("Stream %s changes", tableName),
+		Description: "Streams inserts, updates and deletes as server-sent events, or over a WebSocket when the request " +
+			"asks to upgrade. Each event's ID is its offset; reconnect with Last-Event-ID or after to resume. " +
+			"Needs the service's change_streams setting.",
+		OperationID: fmt.Sprintf("changes_%s", tableName),
+		Parameters: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("filter").WithDescription("Only changes whose row matches this filter").WithSchema(str)},
+			{Value: openapi3.NewQueryParameter("after").WithDescription("Resume after this offset").WithSchema(str)},
+			{Value: openapi3.NewHeaderParameter("Last-Event-ID").WithDescription("Resume after this offset (sent by EventSource on reconnect)").WithSchema(str)},
+		},
+		Responses: responses,
+	}
+}
+
+// disableChangesOperation generates the DELETE operation that removes a
+// table's change capture.
+func disableChangesOperation(tag, tableName string) *openapi3.Operation {
+	responses := openapi3.NewResponses()
+	responses.Set("204", &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("Change capture removed")})
+	return &openapi3.Operation{
+		Tags:        []string{tag},
+		Summary:     fmt.Sprintf("Stop capturing %s changes", tableName),
+		Description: "Removes the change capture installed on the table and ends its streams. Admin only.",
+		OperationID: fmt.Sprintf("disableChanges_%s", tableName),
+		Responses:   responses,
+	}
+}
+
+// importOperation generates the POST operation for the streaming bulk import
+// endpoint (_table/{name}/_import).
+func importOperation(tag, tableName string) *openapi3.Operation {
+	intSchema := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"integer"}, Format: "int64", Description: desc}}
+	}
+	responseSchema := &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{
+				"meta": &openapi3.SchemaRef{
+					Value: &openapi3.Schema{
+						Type: &openapi3.Types{"object"},
+						Properties: openapi3.Schemas{
+							"rows":     intSchema("Data rows read from the body."),
+							"inserted": intSchema("Rows written to the table."),
+							"failed":   intSchema("Rows rejected (continue=true only)."),
+							"took_ms":  &openapi3.SchemaRef{Value: openapi3.NewFloat64Schema()},
+						},
+					},
+				},
+				"errors": &openapi3.SchemaRef{
+					Value: &openapi3.Schema{
+						Type: &openapi3.Types{"array"},
+						Items: &openapi3.SchemaRef{
+							Value: &openapi3.Schema{
+								Type: &openapi3.Types{"object"},
+								Properties: openapi3.Schemas{
+									"row":     intSchema("1-based data row number."),
+									"message": &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	query := func(name, desc string, schema *openapi3.Schema) *openapi3.ParameterRef {
+		return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithDescription(desc).WithSchema(schema)}
+	}
+	text := &openapi3.MediaType{Schema: &openapi3.SchemaRef{Value: openapi3.NewStringSchema()}}
+
+	responses := newResponses("201", fmt.Sprintf("All rows imported into %s", tableName), responseSchema)
+	partialDesc := "Import finished with rejected rows (continue=true)"
+	responses.Set("200", &openapi3.ResponseRef{
+		Value: &openapi3.Response{
+			Description: &partialDesc,
+			Content:     openapi3.NewContentWithJSONSchemaRef(responseSchema),
+		},
+	})
+	unsupportedDesc := "Unsupported content type"
+	responses.Set("415", &openapi3.ResponseRef{
+		Value: &openapi3.Response{
+			Description: &unsupportedDesc,
+			Content:     openapi3.NewContentWithJSONSchemaRef(openapi3.NewSchemaRef("#/components/schemas/ErrorResponse", nil)),
+		},
+	})
+
+	return &openapi3.Operation{
+		Tags:        []string{tag},
+		Summary:     fmt.Sprintf("Bulk import %s records", tableName),
+		Description: fmt.Sprintf("Stream CSV, TSV or NDJSON rows into %s in chunks. Values are coerced to the column types; by default the import halts at the first bad row, keeping earlier chunks.", tableName),
+		OperationID: fmt.Sprintf("import_%s", tableName),
+		Parameters: openapi3.Parameters{
+			query("map", "Rename input fields to columns as src:col pairs; \"src:\" drops a field.", openapi3.NewStringSchema()),
+			query("header", "Whether the CSV has a header row (default true).", openapi3.NewBoolSchema()),
+			query("columns", "Comma-separated CSV field names in order.", openapi3.NewStringSchema()),
+			query("delimiter", "CSV field delimiter (\"tab\" for tabs).", openapi3.NewStringSchema()),
+			query("null", "CSV field text that loads as NULL (default: empty field).", openapi3.NewStringSchema()),
+			query("batch_size", "Rows written per chunk (default 1000, max 10000).", openapi3.NewIntegerSchema()),
+			query("rollback", "Load all rows in one transaction, or none.", openapi3.NewBoolSchema()),
+			query("continue", "Skip rows that fail and report them in errors.", openapi3.NewBoolSchema()),
+		},
+		RequestBody: &openapi3.RequestBodyRef{
+			Value: &openapi3.RequestBody{
+				Description: "Rows to import",
+				Required:    true,
+				Content: openapi3.Content{
+					"text/csv":                  text,
+					"text/tab-separated-values": text,
+					"application/x-ndjson":      text,
+				},
+			},
+		},
+		Responses: responses,
+	}
+}
+
 // createOperation generates a POST operation for creating records.
 func createOperation(tag, tableName, createRef, schemaRef string) *openapi3.Operation {
 	reqBody := &openapi3.RequestBodyRef{
@@ -502,6 +1201,7 @@ func createOperation(tag, tableName, createRef, schemaRef string) *openapi3.Oper
 		Summary:     fmt.Sprintf("Create %s record(s)", tableName),
 		Description: fmt.Sprintf("Create one or more records in %s. Send a single object or {\"resource\": [...]} for batch.", tableName),
 		OperationID: fmt.Sprintf("create_%s", tableName),
+		Parameters:  upsertQueryParameters(),
 		RequestBody: reqBody,
 		Responses: newResponses(
 			"201", fmt.Sprintf("Created %s record(s)", tableName), &openapi3.SchemaRef{
@@ -708,7 +1408,8 @@ func listQueryParameters() openapi3.Parameters {
 			Value: openapi3.NewQueryParameter("fields").
 				WithDescription("Comma-separated list of fields to include in the response. " +
 					"Each field may be a plain column or an aggregate such as \"SUM(amount)\", " +
-					"\"COUNT(*)\", or \"AVG(price) AS avg_price\". Combine with 'group' to aggregate per group.").
+					"\"COUNT(*)\", \"COUNT(DISTINCT customer_id)\", or \"AVG(price) AS avg_price\". " +
+					"Combine with 'group' to aggregate per group.").
 				WithSchema(openapi3.NewStringSchema()),
 		},
 		&openapi3.ParameterRef{
@@ -717,6 +1418,20 @@ func listQueryParameters() openapi3.Parameters {
 					"Use aggregates in 'fields' to compute per-group values; order by an aggregate's alias.").
 				WithSchema(openapi3.NewStringSchema()),
 		},
+		&openapi3.ParameterRef{
+			Value: openapi3.NewQueryParameter("having").
+				WithDescription("Filter expression applied to groups, using 'filter' syntax. " +
+					"May reference group columns and aggregate aliases (e.g. \"total>1000\").").
+				WithSchema(openapi3.NewStringSchema()),
+		},
+		&openapi3.ParameterRef{
+			Value: func() *openapi3.Parameter {
+				p := openapi3.NewQueryParameter("distinct")
+				p.Description = "Return only distinct rows (\"true\" to enable)."
+				p.Schema = &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}}
+				return p
+			}(),
+		},
 		&openapi3.ParameterRef{
 			Value: openapi3.NewQueryParameter("ids").
 				WithDescription("Comma-separated list of primary key values to retrieve.").
@@ -730,6 +1445,45 @@ func listQueryParameters() openapi3.Parameters {
 				return p
 			}(),
 		},
+		&openapi3.ParameterRef{
+			Value: func() *openapi3.Parameter {
+				p := openapi3.NewQueryParameter("include_etags")
+				p.Description = "Include each record's ETag as its _etag member (\"true\" to enable)."
+				p.Schema = &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}}
+				return p
+			}(),
+		},
+		&openapi3.ParameterRef{
+			Value: openapi3.NewQueryParameter("format").
+				WithDescription("Response format, overriding the Accept header: \"json\", \"ndjson\", " +
+					"\"csv\", \"tsv\", \"arrow\" (IPC stream) or \"parquet\". CSV and TSV take 'delimiter', " +
+					"'null' and 'bom' options. Arrow and Parquet exports default to the maximum limit.").
+				WithSchema(openapi3.NewStringSchema().WithEnum("json", "ndjson", "csv", "tsv", "arrow", "parquet")),
+		},
+	}
+}
+
+// upsertQueryParameters returns the upsert query parameters for POST operations.
+func upsertQueryParameters() openapi3.Parameters {
+	boolParam := func(name, desc string) *openapi3.ParameterRef {
+		p := openapi3.NewQueryParameter(name)
+		p.Description = desc
+		p.Schema = &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}}
+		return &openapi3.ParameterRef{Value: p}
+	}
+	return openapi3.Parameters{
+		boolParam("upsert", "Update rows that conflict with an existing row instead of failing."),
+		&openapi3.ParameterRef{
+			Value: openapi3.NewQueryParameter("on_conflict").
+				WithDescription("Comma-separated columns identifying an existing row (default: primary key).").
+				WithSchema(openapi3.NewStringSchema()),
+		},
+		&openapi3.ParameterRef{
+			Value: openapi3.NewQueryParameter("update_columns").
+				WithDescription("Comma-separated columns to overwrite on conflict (default: all but on_conflict).").
+				WithSchema(openapi3.NewStringSchema()),
+		},
+		boolParam("ignore_duplicates", "Leave conflicting rows untouched; implies upsert."),
 	}
 }
 
@@ -754,6 +1508,79 @@ func writeQueryParameters() openapi3.Parameters {
 	}
 }
 
+// ─── Structured Query Schemas ───────────────────────────────────────────────
+
+// addQuerySchemas registers the QueryRequest and QueryFilter component schemas
+// shared by every _query endpoint. It is idempotent.
+func addQuerySchemas(doc *openapi3.T) {
+	if _, ok := doc.Components.Schemas["QueryRequest"]; ok {
+		return
+	}
+
+	filterRef := openapi3.NewSchemaRef("#/components/schemas/QueryFilter", nil)
+	doc.Components.Schemas["QueryFilter"] = &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type: &openapi3.Types{"object"},
+			Description: "Structured filter. Keys are column names or the logical operators and/or/not. " +
+				"A column maps to a value (equality; null means IS NULL) or to an object of operators: " +
+				"eq, ne, gt, gte, lt, lte, in, not_in, like, not_like, ilike, not_ilike, between, not_between, " +
+				"contains, starts_with, ends_with, is_null. Multiple keys are ANDed.",
+			Properties: openapi3.Schemas{
+				"and": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"array"}, Items: filterRef}},
+				"or":  &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"array"}, Items: filterRef}},
+				"not": filterRef,
+			},
+			Example: map[string]interface{}{
+				"and": []interface{}{
+					map[string]interface{}{"age": map[string]interface{}{"gt": 21}},
+					map[string]interface{}{"status": map[string]interface{}{"in": []string{"active", "trial"}}},
+				},
+			},
+		},
+	}
+
+	stringList := func(desc string) *openapi3.SchemaRef {
+		return &openapi3.SchemaRef{
+			Value: &openapi3.Schema{
+				Type:        &openapi3.Types{"array"},
+				Items:       &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+				Description: desc,
+			},
+		}
+	}
+	noExtra := false
+	doc.Components.Schemas["QueryRequest"] = &openapi3.SchemaRef{
+		Value: &openapi3.Schema{
+			Type:                 &openapi3.Types{"object"},
+			AdditionalProperties: openapi3.AdditionalProperties{Has: &noExtra},
+			Properties: openapi3.Schemas{
+				"where":  filterRef,
+				"fields": stringList("Columns or aggregate expressions (e.g. [\"status\", \"COUNT(*) AS n\"])."),
+				"order":  stringList("Sort terms (e.g. [\"name ASC\", \"id DESC\"])."),
+				"group":  stringList("Columns to group by."),
+				"having": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type:        &openapi3.Types{"string"},
+					Description: "Filter expression applied to groups, using 'filter' syntax.",
+				}},
+				"distinct": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}},
+				"ids": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type:        &openapi3.Types{"array"},
+					Items:       &openapi3.SchemaRef{Value: &openapi3.Schema{}},
+					Description: "Primary key values to retrieve.",
+				}},
+				"limit":  &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"integer"}, Format: "int32", Description: "Maximum number of records to return (default 25, max 1000 or the role's max_limit)."}},
+				"offset": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"integer"}, Format: "int32"}},
+				"cursor": &openapi3.SchemaRef{Value: &openapi3.Schema{
+					Type:        &openapi3.Types{"string"},
+					Description: "meta.next_cursor from a previous page; overrides offset.",
+				}},
+				"include_count": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}},
+				"include_etags": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"boolean"}}},
+			},
+		},
+	}
+}
+
 // ─── Response Helpers ───────────────────────────────────────────────────────
 
 // newResponses builds a Responses map with a success response and standard error responses.
@@ -834,6 +1661,12 @@ func metaSchema() *openapi3.SchemaRef {
 						Description: "Number of records skipped.",
 					},
 				},
+				"next_cursor": &openapi3.SchemaRef{
+					Value: &openapi3.Schema{
+						Type:        &openapi3.Types{"string"},
+						Description: "Cursor for the next page (structured query endpoint only).",
+					},
+				},
 			},
 		},
 	}
@@ -1,6 +1,7 @@
 package openapi
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/faucetdb/faucet/internal/model"
@@ -396,6 +397,62 @@ func TestGenerateServiceSpec_TablePaths(t *testing.T) {
 	if schemaPath == nil {
 		t.Error("Schema path /api/v1/mydb/_schema/users not found")
 	}
+
+	// Structured JSON query endpoint
+	queryPath := doc.Paths.Find("/api/v1/mydb/_table/users/_query")
+	if queryPath == nil || queryPath.Post == nil {
+		t.Fatal("POST /api/v1/mydb/_table/users/_query not found")
+	}
+	if doc.Components.Schemas["QueryRequest"] == nil || doc.Components.Schemas["QueryFilter"] == nil {
+		t.Error("QueryRequest/QueryFilter component schemas not registered")
+	}
+}
+
+func TestGenerateServiceSpec_RecordPaths(t *testing.T) {
+	schema := testSchema()
+	schema.Tables = append(schema.Tables, model.TableSchema{
+		Name: "stock",
+		Type: "table",
+		Columns: []model.Column{
+			{Name: "tenant_id", Position: 1, Type: "integer", IsPrimaryKey: true},
+			{Name: "sku", Position: 2, Type: "varchar", IsPrimaryKey: true},
+		},
+		PrimaryKey: []string{"tenant_id", "sku"},
+	}, model.TableSchema{
+		Name:    "audit_log",
+		Type:    "table",
+		Columns: []model.Column{{Name: "message", Position: 1, Type: "text"}},
+	})
+	doc := GenerateServiceSpec("mydb", "My Database", "postgres", "http://localhost:8080", schema)
+
+	recordPath := doc.Paths.Find("/api/v1/mydb/_table/users/{id}")
+	if recordPath == nil {
+		t.Fatal("Record path /api/v1/mydb/_table/users/{id} not found")
+	}
+	if recordPath.Get == nil || recordPath.Put == nil || recordPath.Patch == nil || recordPath.Delete == nil {
+		t.Error("record path should have GET, PUT, PATCH and DELETE operations")
+	}
+	if recordPath.Post != nil {
+		t.Error("record path should not have a POST operation")
+	}
+	if recordPath.Get.Responses.Value("404") == nil {
+		t.Error("record GET should document a 404 response")
+	}
+	if len(recordPath.Parameters) != 1 || recordPath.Parameters[0].Value.In != "path" {
+		t.Errorf("expected a single {id} path parameter, got %+v", recordPath.Parameters)
+	}
+
+	stockPath := doc.Paths.Find("/api/v1/mydb/_table/stock/{id}")
+	if stockPath == nil {
+		t.Fatal("Record path for composite key not found")
+	}
+	if desc := stockPath.Parameters[0].Value.Description; !strings.Contains(desc, "tenant_id, sku") {
+		t.Errorf("composite key description should list key columns, got %q", desc)
+	}
+
+	if doc.Paths.Find("/api/v1/mydb/_table/audit_log/{id}") != nil {
+		t.Error("tables without a primary key or id column should have no record path")
+	}
 }
 
 func TestGenerateServiceSpec_ViewPaths(t *testing.T) {
@@ -621,6 +678,47 @@ func TestGenerateServiceSpec_ProcedureRequestBody(t *testing.T) {
 	}
 }
 
+func TestAddNamedQueryPaths(t *testing.T) {
+	doc := GenerateServiceSpec("mydb", "My Database", "postgres", "http://localhost:8080", testSchema())
+	AddNamedQueryPaths(doc, "mydb", []model.NamedQuery{
+		{
+			Name: "top_customers",
+			SQL:  "SELECT * FROM customers WHERE region = :region LIMIT :limit",
+			Params: []model.QueryParam{
+				{Name: "region", Type: "string", Required: true},
+				{Name: "limit", Type: "integer", Default: float64(10)},
+			},
+		},
+		{Name: "purge", SQL: "DELETE FROM sessions", Write: true},
+	})
+
+	read := doc.Paths.Find("/api/v1/mydb/_query/top_customers")
+	if read == nil || read.Get == nil || read.Post == nil {
+		t.Fatal("read query should have GET and POST operations")
+	}
+	if len(read.Get.Parameters) != 2 {
+		t.Fatalf("expected 2 query parameters, got %d", len(read.Get.Parameters))
+	}
+	for _, p := range read.Get.Parameters {
+		want := p.Value.Name == "region"
+		if p.Value.Required != want {
+			t.Errorf("parameter %q required = %v, want %v", p.Value.Name, p.Value.Required, want)
+		}
+	}
+	body := read.Post.RequestBody.Value.Content["application/json"].Schema.Value
+	if len(body.Required) != 1 || body.Required[0] != "region" {
+		t.Errorf("request body required = %v, want [region]", body.Required)
+	}
+
+	write := doc.Paths.Find("/api/v1/mydb/_query/purge")
+	if write == nil || write.Post == nil {
+		t.Fatal("write query should have a POST operation")
+	}
+	if write.Get != nil {
+		t.Error("write query should not have a GET operation")
+	}
+}
+
 func TestGenerateServiceSpec_TableOperationTags(t *testing.T) {
 	schema := testSchema()
 	doc := GenerateServiceSpec("mydb", "My Database", "postgres", "http://localhost:8080", schema)
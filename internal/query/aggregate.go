@@ -19,20 +19,24 @@ var aggregateFuncs = map[string]bool{
 }
 
 // aggregateRegex matches a single aggregate projection element such as
-// "SUM(amount)", "COUNT(*)", or "AVG(price) AS avg_price".
-// Submatches: 1 = function name, 2 = column or "*", 3 = optional alias.
+// "SUM(amount)", "COUNT(*)", "COUNT(DISTINCT customer_id)", or
+// "AVG(price) AS avg_price".
+// Submatches: 1 = function name, 2 = optional DISTINCT keyword,
+// 3 = column or "*", 4 = optional alias.
 var aggregateRegex = regexp.MustCompile(
-	`(?i)^([a-z]+)\(\s*(\*|[a-z_][a-z0-9_]*)\s*\)(?:\s+as\s+([a-z_][a-z0-9_]*))?$`,
+	`(?i)^([a-z]+)\(\s*(distinct\s+)?(\*|[a-z_][a-z0-9_]*)\s*\)(?:\s+as\s+([a-z_][a-z0-9_]*))?$`,
 )
 
 // SelectItem is one entry in a SELECT projection. A plain column has an empty
 // Func; an aggregate has Func set to an allowlisted function name (uppercased),
 // Column set to the argument ("*" only for COUNT), and Alias set to the output
-// column name.
+// column name. Distinct marks an aggregate over distinct values only, e.g.
+// COUNT(DISTINCT customer_id).
 type SelectItem struct {
-	Func   string
-	Column string
-	Alias  string
+	Func     string
+	Column   string
+	Alias    string
+	Distinct bool
 }
 
 // IsAggregate reports whether this item is an aggregate expression.
@@ -62,23 +66,27 @@ func ParseProjection(fields string) ([]SelectItem, error) {
 			if !aggregateFuncs[fn] {
 				return nil, fmt.Errorf("unsupported aggregate function %q: allowed functions are AVG, COUNT, MAX, MIN, SUM", m[1])
 			}
-			arg := m[2]
+			distinct := m[2] != ""
+			arg := m[3]
 			if arg == "*" && fn != "COUNT" {
 				return nil, fmt.Errorf("%s(*) is not allowed; only COUNT supports the * argument", fn)
 			}
+			if arg == "*" && distinct {
+				return nil, fmt.Errorf("%s(DISTINCT *) is not allowed; name a column to count distinct values of", fn)
+			}
 			if arg != "*" {
 				if err := ValidateIdentifier(arg); err != nil {
 					return nil, fmt.Errorf("invalid aggregate column: %w", err)
 				}
 			}
-			alias := m[3]
+			alias := m[4]
 			if alias == "" {
-				alias = defaultAlias(fn, arg)
+				alias = defaultAlias(fn, arg, distinct)
 			}
 			if err := ValidateIdentifier(alias); err != nil {
 				return nil, fmt.Errorf("invalid aggregate alias: %w", err)
 			}
-			items = append(items, SelectItem{Func: fn, Column: arg, Alias: alias})
+			items = append(items, SelectItem{Func: fn, Column: arg, Alias: alias, Distinct: distinct})
 			continue
 		}
 
@@ -97,11 +105,15 @@ func ParseProjection(fields string) ([]SelectItem, error) {
 
 // defaultAlias derives a deterministic output name for an aggregate that has no
 // explicit alias: "count" for COUNT(*), otherwise "<func>_<column>" lowercased,
-// e.g. SUM(amount) -> "sum_amount".
-func defaultAlias(fn, arg string) string {
+// e.g. SUM(amount) -> "sum_amount". Distinct aggregates get a "_distinct"
+// infix, e.g. COUNT(DISTINCT customer_id) -> "count_distinct_customer_id".
+func defaultAlias(fn, arg string, distinct bool) string {
 	if arg == "*" {
 		return strings.ToLower(fn)
 	}
+	if distinct {
+		return strings.ToLower(fn) + "_distinct_" + arg
+	}
 	return strings.ToLower(fn) + "_" + arg
 }
 
@@ -157,15 +169,65 @@ func BuildProjection(items []SelectItem, quoteFn func(string) string) string {
 			parts[i] = quoteFn(it.Column)
 			continue
 		}
-		inner := "*"
-		if it.Column != "*" {
-			inner = quoteFn(it.Column)
-		}
-		parts[i] = it.Func + "(" + inner + ") AS " + quoteFn(it.Alias)
+		parts[i] = buildAggregateExpr(it, quoteFn) + " AS " + quoteFn(it.Alias)
 	}
 	return strings.Join(parts, ", ")
 }
 
+// buildAggregateExpr renders the bare aggregate expression for an item, e.g.
+// SUM("amount") or COUNT(DISTINCT "customer_id"), without an alias.
+func buildAggregateExpr(it SelectItem, quoteFn func(string) string) string {
+	inner := "*"
+	if it.Column != "*" {
+		inner = quoteFn(it.Column)
+	}
+	if it.Distinct {
+		inner = "DISTINCT " + inner
+	}
+	return it.Func + "(" + inner + ")"
+}
+
+// ParseHaving parses a having= expression into a parameterized SQL fragment
+// for a HAVING clause. It accepts the same grammar as ParseFilter, but every
+// column reference must be either a GROUP BY column or the alias of an
+// aggregate in the projection. Alias references are expanded to the aggregate
+// expression itself (e.g. total -> SUM("amount")), since PostgreSQL, SQL
+// Server, and Oracle do not allow output aliases inside HAVING.
+//
+// quoteFn quotes identifiers; ph and startIndex behave as in ParseFilter.
+// Returns nil, nil for an empty having string.
+func ParseHaving(having string, items []SelectItem, groupBy []string, quoteFn func(string) string, ph PlaceholderFunc, startIndex int) (*ParsedFilter, error) {
+	having = strings.TrimSpace(having)
+	if having == "" {
+		return nil, nil
+	}
+	if len(groupBy) == 0 && !HasAggregate(items) {
+		return nil, fmt.Errorf("having parameter requires aggregate fields or a group parameter")
+	}
+
+	aliases := make(map[string]string, len(items))
+	for _, it := range items {
+		if it.IsAggregate() {
+			aliases[it.Alias] = buildAggregateExpr(it, quoteFn)
+		}
+	}
+	groupSet := make(map[string]bool, len(groupBy))
+	for _, g := range groupBy {
+		groupSet[g] = true
+	}
+
+	resolve := func(col string) (string, error) {
+		if expr, ok := aliases[col]; ok {
+			return expr, nil
+		}
+		if groupSet[col] {
+			return quoteFn(col), nil
+		}
+		return "", fmt.Errorf("having may only reference group columns or aggregate aliases, got %q", col)
+	}
+	return parseFilterWith(having, ph, startIndex, resolve, nil)
+}
+
 // BuildSelectList renders the SELECT list for a query. A structured projection
 // takes precedence; otherwise the plain field list is quoted; an empty field
 // list yields "*". This preserves the original field-selection behavior while
@@ -63,6 +63,21 @@ func TestParseProjection(t *testing.T) {
 			input: "MAX(  amount  )",
 			want:  []SelectItem{{Func: "MAX", Column: "amount", Alias: "max_amount"}},
 		},
+		{
+			name:  "count distinct with default alias",
+			input: "COUNT(DISTINCT customer_id)",
+			want:  []SelectItem{{Func: "COUNT", Column: "customer_id", Alias: "count_distinct_customer_id", Distinct: true}},
+		},
+		{
+			name:  "count distinct with explicit alias, lowercase keyword",
+			input: "count(distinct customer_id) AS customers",
+			want:  []SelectItem{{Func: "COUNT", Column: "customer_id", Alias: "customers", Distinct: true}},
+		},
+		{
+			name:    "count distinct star rejected",
+			input:   "COUNT(DISTINCT *)",
+			wantErr: true,
+		},
 		{
 			name:    "unknown aggregate function rejected",
 			input:   "TOTAL(amount)",
@@ -166,6 +181,102 @@ func TestBuildProjection(t *testing.T) {
 	if got != want {
 		t.Errorf("BuildProjection() = %q, want %q", got, want)
 	}
+
+	distinct := []SelectItem{{Func: "COUNT", Column: "customer_id", Alias: "customers", Distinct: true}}
+	got = BuildProjection(distinct, dqQuote)
+	want = `COUNT(DISTINCT "customer_id") AS "customers"`
+	if got != want {
+		t.Errorf("BuildProjection(distinct) = %q, want %q", got, want)
+	}
+}
+
+func TestParseHaving(t *testing.T) {
+	items := []SelectItem{
+		{Column: "region"},
+		{Func: "SUM", Column: "amount", Alias: "total"},
+		{Func: "COUNT", Column: "customer_id", Alias: "customers", Distinct: true},
+	}
+	groupBy := []string{"region"}
+
+	tests := []struct {
+		name       string
+		having     string
+		items      []SelectItem
+		groupBy    []string
+		startIndex int
+		wantSQL    string
+		wantParams []interface{}
+		wantErr    bool
+	}{
+		{
+			name:    "empty returns nil",
+			having:  "",
+			items:   items,
+			groupBy: groupBy,
+		},
+		{
+			name:       "alias expands to aggregate expression",
+			having:     "total > 1000",
+			items:      items,
+			groupBy:    groupBy,
+			startIndex: 1,
+			wantSQL:    `SUM("amount") > $1`,
+			wantParams: []interface{}{int64(1000)},
+		},
+		{
+			name:       "distinct alias and group column, numbered after filter params",
+			having:     "customers >= 5 AND region != 'north'",
+			items:      items,
+			groupBy:    groupBy,
+			startIndex: 3,
+			wantSQL:    `COUNT(DISTINCT "customer_id") >= $3 AND "region" != $4`,
+			wantParams: []interface{}{int64(5), "north"},
+		},
+		{
+			name:    "column outside group and aliases rejected",
+			having:  "amount > 5",
+			items:   items,
+			groupBy: groupBy,
+			wantErr: true,
+		},
+		{
+			name:    "having without aggregates or group rejected",
+			having:  "id > 5",
+			items:   []SelectItem{{Column: "id"}},
+			wantErr: true,
+		},
+		{
+			name:    "syntax errors surface",
+			having:  "total >",
+			items:   items,
+			groupBy: groupBy,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHaving(tt.having, tt.items, tt.groupBy, dqQuote, DollarPlaceholder, tt.startIndex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHaving(%q) error = %v, wantErr %v", tt.having, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantSQL == "" {
+				if got != nil {
+					t.Errorf("ParseHaving(%q) = %#v, want nil", tt.having, got)
+				}
+				return
+			}
+			if got.SQL != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", got.SQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(got.Params, tt.wantParams) {
+				t.Errorf("Params = %#v, want %#v", got.Params, tt.wantParams)
+			}
+		})
+	}
 }
 
 func TestBuildSelectList(t *testing.T) {
@@ -0,0 +1,223 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ColumnSet describes the columns of the table being queried, mapping each
+// column name to its JSON type as reported by schema introspection
+// ("integer", "number", "boolean", "string", ...). It enables schema-aware
+// validation: unknown columns are rejected before any SQL reaches the
+// database, and literals are coerced to the type of the column they are
+// compared with.
+//
+// A nil ColumnSet disables both checks.
+type ColumnSet map[string]string
+
+// UnknownColumnError reports a column that does not exist in the table.
+// Suggestion holds the closest existing column name, if any is close enough.
+type UnknownColumnError struct {
+	Column     string
+	Suggestion string
+}
+
+func (e *UnknownColumnError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown column %q (did you mean %q?)", e.Column, e.Suggestion)
+	}
+	return fmt.Sprintf("unknown column %q", e.Column)
+}
+
+// lookup resolves a column name, preferring an exact match and falling back
+// to a case-insensitive one (unquoted identifiers are case-insensitive in
+// most dialects). Qualified references are resolved by their last part.
+func (cs ColumnSet) lookup(col string) (string, bool) {
+	if i := strings.LastIndexByte(col, '.'); i >= 0 {
+		col = col[i+1:]
+	}
+	if typ, ok := cs[col]; ok {
+		return typ, true
+	}
+	for name, typ := range cs {
+		if strings.EqualFold(name, col) {
+			return typ, true
+		}
+	}
+	return "", false
+}
+
+// Check returns an *UnknownColumnError if col is not in the set.
+func (cs ColumnSet) Check(col string) error {
+	if cs == nil {
+		return nil
+	}
+	if _, ok := cs.lookup(col); ok {
+		return nil
+	}
+	names := make([]string, 0, len(cs))
+	for name := range cs {
+		names = append(names, name)
+	}
+	return &UnknownColumnError{Column: col, Suggestion: Suggest(col, names)}
+}
+
+// CheckQuery validates every column referenced by a projection, GROUP BY list
+// and ORDER BY clauses. Order clauses may also name a projection alias (e.g.
+// the alias of an aggregate).
+func (cs ColumnSet) CheckQuery(projection []SelectItem, groupBy []string, order []OrderClause) error {
+	if cs == nil {
+		return nil
+	}
+	aliases := make(map[string]bool)
+	for _, it := range projection {
+		if it.Alias != "" {
+			aliases[it.Alias] = true
+		}
+		if it.Column == "*" {
+			continue
+		}
+		if err := cs.Check(it.Column); err != nil {
+			return err
+		}
+	}
+	for _, col := range groupBy {
+		if err := cs.Check(col); err != nil {
+			return err
+		}
+	}
+	for _, oc := range order {
+		if aliases[oc.Column] {
+			continue
+		}
+		if err := cs.Check(oc.Column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Coerce converts a literal to the Go type matching col's JSON type, so that
+// e.g. "42" binds as an integer for an integer column and 42 binds as "42"
+// for a text column. Columns of other or unknown types are left untouched.
+func (cs ColumnSet) Coerce(col string, val interface{}) (interface{}, error) {
+	if cs == nil || val == nil {
+		return val, nil
+	}
+	typ, ok := cs.lookup(col)
+	if !ok {
+		return val, nil
+	}
+
+	switch typ {
+	case "integer":
+		switch v := val.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			// Fractional bounds (age > 20.5) are meaningful; keep them.
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+			return v, nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("column %q expects an integer, got %v", col, describeLiteral(val))
+
+	case "number":
+		switch v := val.(type) {
+		case int64, float64:
+			return v, nil
+		case string:
+			s := strings.TrimSpace(v)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("column %q expects a number, got %v", col, describeLiteral(val))
+
+	case "boolean":
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("column %q expects a boolean, got %v", col, describeLiteral(val))
+
+	case "string":
+		switch v := val.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	}
+	return val, nil
+}
+
+// describeLiteral formats a literal for type mismatch errors.
+func describeLiteral(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(val)
+}
+
+// Suggest returns the candidate closest to name by edit distance, or "" if
+// none is close enough to be a plausible typo.
+func Suggest(name string, candidates []string) string {
+	lower := strings.ToLower(name)
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := levenshtein(lower, strings.ToLower(c))
+		if bestDist < 0 || d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	maxDist := len(name) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	if bestDist < 0 || bestDist > maxDist {
+		return ""
+	}
+	return best
+}
+
+// levenshtein computes the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,163 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testColumns() ColumnSet {
+	return ColumnSet{
+		"id":         "integer",
+		"name":       "string",
+		"email":      "string",
+		"price":      "number",
+		"active":     "boolean",
+		"created_at": "string",
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"id", "name", "email", "created_at"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"nmae", "name"},
+		{"emial", "email"},
+		{"createdat", "created_at"},
+		{"NAME", "name"},
+		{"zzzzzz", ""},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.name, candidates); got != tt.want {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestColumnSetCheck(t *testing.T) {
+	cols := testColumns()
+
+	for _, ok := range []string{"name", "Name", "users.email"} {
+		if err := cols.Check(ok); err != nil {
+			t.Errorf("Check(%q): unexpected error %v", ok, err)
+		}
+	}
+
+	err := cols.Check("nmae")
+	var unknown *UnknownColumnError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Check(nmae): expected *UnknownColumnError, got %v", err)
+	}
+	if unknown.Column != "nmae" || unknown.Suggestion != "name" {
+		t.Errorf("got %+v, want column nmae with suggestion name", unknown)
+	}
+
+	var none ColumnSet
+	if err := none.Check("anything"); err != nil {
+		t.Errorf("nil ColumnSet should accept everything, got %v", err)
+	}
+}
+
+func TestColumnSetCheckQuery(t *testing.T) {
+	cols := testColumns()
+	projection := []SelectItem{{Column: "name"}, {Func: "COUNT", Column: "*", Alias: "n"}}
+
+	if err := cols.CheckQuery(projection, []string{"name"}, []OrderClause{{Column: "n", Direction: "DESC"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := cols.CheckQuery([]SelectItem{{Column: "nope"}}, nil, nil); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if err := cols.CheckQuery(nil, []string{"nope"}, nil); err == nil {
+		t.Error("expected error for unknown group column")
+	}
+	if err := cols.CheckQuery(nil, nil, []OrderClause{{Column: "nope", Direction: "ASC"}}); err == nil {
+		t.Error("expected error for unknown order column")
+	}
+}
+
+func TestColumnSetCoerce(t *testing.T) {
+	cols := testColumns()
+	tests := []struct {
+		col     string
+		in      interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"id", "42", int64(42), false},
+		{"id", float64(7), int64(7), false},
+		{"id", 2.5, 2.5, false},
+		{"id", "abc", nil, true},
+		{"price", "9.99", 9.99, false},
+		{"price", "10", int64(10), false},
+		{"price", true, nil, true},
+		{"active", "true", true, false},
+		{"active", int64(0), false, false},
+		{"active", "maybe", nil, true},
+		{"name", int64(123), "123", false},
+		{"name", "Bob", "Bob", false},
+		{"unknown_col", "x", "x", false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%v", tt.col, tt.in), func(t *testing.T) {
+			got, err := cols.Coerce(tt.col, tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterForColumns(t *testing.T) {
+	cols := testColumns()
+
+	result, err := ParseFilterForColumns("id IN ('1', '2') AND name = 5 AND price BETWEEN '1' AND 2.5", DollarPlaceholder, 1, cols)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []interface{}{int64(1), int64(2), "5", int64(1), 2.5}
+	if fmt.Sprint(result.Params) != fmt.Sprint(want) {
+		t.Errorf("Params: got %v, want %v", result.Params, want)
+	}
+	for i, p := range result.Params {
+		if fmt.Sprintf("%T", p) != fmt.Sprintf("%T", want[i]) {
+			t.Errorf("Params[%d]: got %T, want %T", i, p, want[i])
+		}
+	}
+
+	// LIKE patterns are never coerced.
+	result, err = ParseFilterForColumns("name LIKE 'A%'", DollarPlaceholder, 1, cols)
+	if err != nil || result.Params[0] != "A%" {
+		t.Errorf("LIKE: got %v, %v", result, err)
+	}
+
+	var unknown *UnknownColumnError
+	if _, err := ParseFilterForColumns("nmae = 'x'", DollarPlaceholder, 1, cols); !errors.As(err, &unknown) {
+		t.Errorf("expected *UnknownColumnError, got %v", err)
+	}
+	if _, err := ParseFilterForColumns("id = 'abc'", DollarPlaceholder, 1, cols); err == nil {
+		t.Error("expected type error for non-integer id")
+	}
+
+	jsonResult, err := ParseFilterJSONForColumns([]byte(`{"id": {"in": ["3"]}, "active": "true"}`), DollarPlaceholder, 1, cols)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(jsonResult.Params) != fmt.Sprint([]interface{}{true, int64(3)}) {
+		t.Errorf("JSON params: got %v", jsonResult.Params)
+	}
+	if _, err := ParseFilterJSONForColumns([]byte(`{"emial": "x"}`), DollarPlaceholder, 1, cols); !errors.As(err, &unknown) {
+		t.Errorf("expected *UnknownColumnError, got %v", err)
+	}
+}
@@ -0,0 +1,406 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Structured (JSON) filters
+// ---------------------------------------------------------------------------
+
+// ParseFilterJSON compiles a structured JSON filter into the same
+// parameterized SQL WHERE fragment that ParseFilter produces for the
+// equivalent string filter. It is intended for typed clients that would
+// otherwise have to assemble and quote filter strings by hand.
+//
+// The grammar is a small Mongo/Prisma-style tree:
+//
+//	{"and": [node, ...]}                 all children must match
+//	{"or":  [node, ...]}                 any child must match
+//	{"not": node}                        negation
+//	{"status": "active"}                 shorthand for {"status": {"eq": "active"}}
+//	{"deleted_at": null}                 shorthand for IS NULL
+//	{"age": {"gte": 18, "lt": 65}}       operators on one column are ANDed
+//
+// Supported column operators: eq, ne, gt, gte, lt, lte, in, not_in, like,
+// not_like, ilike, not_ilike (case-insensitive LIKE), between, not_between,
+// contains, starts_with, ends_with and is_null (true/false). {"eq": null} and {"ne": null} compile to IS NULL and
+// IS NOT NULL. When an object has several keys they are combined with AND in
+// sorted key order, so the generated SQL and placeholder numbering are
+// deterministic.
+//
+// Column names go through the same validation as ParseFilter. ph and
+// startIndex behave exactly as in ParseFilter. Returns nil, nil for an empty
+// body, JSON null or an empty object.
+func ParseFilterJSON(data []byte, ph PlaceholderFunc, startIndex int) (*ParsedFilter, error) {
+	return parseFilterJSONWith(data, ph, startIndex, nil)
+}
+
+// ParseFilterJSONForColumns is ParseFilterJSON with the schema-aware
+// validation and literal coercion described in ParseFilterForColumns.
+func ParseFilterJSONForColumns(data []byte, ph PlaceholderFunc, startIndex int, cols ColumnSet) (*ParsedFilter, error) {
+	return parseFilterJSONWith(data, ph, startIndex, cols)
+}
+
+func parseFilterJSONWith(data []byte, ph PlaceholderFunc, startIndex int, cols ColumnSet) (*ParsedFilter, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if ph == nil {
+		ph = DollarPlaceholder
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("invalid JSON filter: %w", err)
+	}
+	obj, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("filter must be a JSON object, got %s", jsonTypeName(tree))
+	}
+	if len(obj) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{ph: ph, nextIndex: startIndex, cols: cols}
+	node, err := p.compileNode(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedFilter{
+		SQL:    node.sql,
+		Params: node.params,
+	}, nil
+}
+
+// jsonOperators maps JSON comparison operators to their SQL spelling.
+var jsonOperators = map[string]string{
+	"eq":  "=",
+	"ne":  "!=",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// compileNode compiles one JSON filter object. Multiple keys are ANDed.
+func (p *parser) compileNode(obj map[string]interface{}) (*parseResult, error) {
+	if len(obj) == 0 {
+		return nil, fmt.Errorf("empty filter object")
+	}
+
+	var parts []*parseResult
+	for _, key := range sortedKeys(obj) {
+		val := obj[key]
+		var (
+			part *parseResult
+			err  error
+		)
+		switch strings.ToLower(key) {
+		case "and", "or":
+			part, err = p.compileLogical(strings.ToUpper(key), val)
+		case "not":
+			child, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("\"not\" requires an object, got %s", jsonTypeName(val))
+			}
+			inner, cerr := p.compileNode(child)
+			if cerr != nil {
+				return nil, cerr
+			}
+			part = &parseResult{sql: "NOT (" + inner.sql + ")", params: inner.params}
+		default:
+			part, err = p.compileColumn(key, val)
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return joinResults(parts, "AND"), nil
+}
+
+// compileLogical compiles an "and"/"or" array of child nodes.
+func (p *parser) compileLogical(op string, val interface{}) (*parseResult, error) {
+	children, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q requires an array, got %s", strings.ToLower(op), jsonTypeName(val))
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("%q requires at least one condition", strings.ToLower(op))
+	}
+	parts := make([]*parseResult, 0, len(children))
+	for i, c := range children {
+		child, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q[%d] must be an object, got %s", strings.ToLower(op), i, jsonTypeName(c))
+		}
+		part, err := p.compileNode(child)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return joinResults(parts, op), nil
+}
+
+// compileColumn compiles the conditions attached to a single column.
+func (p *parser) compileColumn(col string, val interface{}) (*parseResult, error) {
+	if err := validateColumnRef(col); err != nil {
+		return nil, fmt.Errorf("invalid column name: %w", err)
+	}
+	if err := p.checkColumn(col); err != nil {
+		return nil, err
+	}
+	if p.colFn != nil {
+		var err error
+		if col, err = p.colFn(col); err != nil {
+			return nil, err
+		}
+	}
+
+	ops, ok := val.(map[string]interface{})
+	if !ok {
+		// Shorthand: {"col": value} is equality, {"col": null} is IS NULL.
+		return p.compileOperator(col, "eq", val)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operators given for column %q", col)
+	}
+	var parts []*parseResult
+	for _, op := range sortedKeys(ops) {
+		part, err := p.compileOperator(col, strings.ToLower(op), ops[op])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return joinResults(parts, "AND"), nil
+}
+
+// compileOperator compiles a single "column op value" condition.
+func (p *parser) compileOperator(col, op string, val interface{}) (*parseResult, error) {
+	switch op {
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		if val == nil {
+			switch op {
+			case "eq":
+				return &parseResult{sql: col + " IS NULL"}, nil
+			case "ne":
+				return &parseResult{sql: col + " IS NOT NULL"}, nil
+			}
+			return nil, fmt.Errorf("%s %s requires a non-null value", col, op)
+		}
+		v, err := p.jsonScalar(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", col, op, err)
+		}
+		if v, err = p.coerce(v); err != nil {
+			return nil, err
+		}
+		ph := p.addParam(v)
+		return &parseResult{
+			sql:    col + " " + jsonOperators[op] + " " + ph,
+			params: []interface{}{v},
+		}, nil
+
+	case "is_null":
+		b, ok := val.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is_null requires true or false, got %s", col, jsonTypeName(val))
+		}
+		if b {
+			return &parseResult{sql: col + " IS NULL"}, nil
+		}
+		return &parseResult{sql: col + " IS NOT NULL"}, nil
+
+	case "in", "not_in":
+		sqlOp := "IN"
+		if op == "not_in" {
+			sqlOp = "NOT IN"
+		}
+		list, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s %s requires an array, got %s", col, op, jsonTypeName(val))
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("%s %s requires at least one value", col, sqlOp)
+		}
+		placeholders := make([]string, len(list))
+		params := make([]interface{}, len(list))
+		for i, item := range list {
+			v, err := p.jsonScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s[%d]: %w", col, op, i, err)
+			}
+			if v, err = p.coerce(v); err != nil {
+				return nil, err
+			}
+			placeholders[i] = p.addParam(v)
+			params[i] = v
+		}
+		return &parseResult{
+			sql:    col + " " + sqlOp + " (" + strings.Join(placeholders, ", ") + ")",
+			params: params,
+		}, nil
+
+	case "like", "not_like":
+		sqlOp := "LIKE"
+		if op == "not_like" {
+			sqlOp = "NOT LIKE"
+		}
+		v, err := p.jsonScalar(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", col, op, err)
+		}
+		ph := p.addParam(v)
+		return &parseResult{
+			sql:    col + " " + sqlOp + " " + ph,
+			params: []interface{}{v},
+		}, nil
+
+	case "ilike", "not_ilike":
+		// LOWER on both sides rather than ILIKE, which only PostgreSQL and
+		// Snowflake have.
+		sqlOp := "LIKE"
+		if op == "not_ilike" {
+			sqlOp = "NOT LIKE"
+		}
+		v, err := p.jsonScalar(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", col, op, err)
+		}
+		ph := p.addParam(v)
+		return &parseResult{
+			sql:    "LOWER(" + col + ") " + sqlOp + " LOWER(" + ph + ")",
+			params: []interface{}{v},
+		}, nil
+
+	case "between", "not_between":
+		sqlOp := "BETWEEN"
+		if op == "not_between" {
+			sqlOp = "NOT BETWEEN"
+		}
+		bounds, ok := val.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s %s requires a [low, high] array", col, op)
+		}
+		low, err := p.jsonScalar(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s %s lower bound: %w", col, op, err)
+		}
+		high, err := p.jsonScalar(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s %s upper bound: %w", col, op, err)
+		}
+		if low, err = p.coerce(low); err != nil {
+			return nil, err
+		}
+		if high, err = p.coerce(high); err != nil {
+			return nil, err
+		}
+		phLow := p.addParam(low)
+		phHigh := p.addParam(high)
+		return &parseResult{
+			sql:    col + " " + sqlOp + " " + phLow + " AND " + phHigh,
+			params: []interface{}{low, high},
+		}, nil
+
+	case "contains", "starts_with", "ends_with":
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s %s requires a string value, got %s", col, op, jsonTypeName(val))
+		}
+		switch op {
+		case "contains":
+			s = "%" + s + "%"
+		case "starts_with":
+			s = s + "%"
+		case "ends_with":
+			s = "%" + s
+		}
+		ph := p.addParam(s)
+		return &parseResult{
+			sql:    col + " LIKE " + ph,
+			params: []interface{}{s},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operator %q for column %q", op, col)
+	}
+}
+
+// jsonScalar converts a decoded JSON scalar into the Go type ParseFilter
+// would bind for the same literal (string, int64, float64 or bool).
+func (p *parser) jsonScalar(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string, bool:
+		return v, nil
+	case json.Number:
+		n, err := p.parseNumericValue(v.String())
+		if err != nil {
+			return nil, err
+		}
+		return n.value, nil
+	default:
+		return nil, fmt.Errorf("expected a string, number or boolean, got %s", jsonTypeName(val))
+	}
+}
+
+// joinResults combines fragments with AND/OR, parenthesizing multi-part
+// operands so precedence is preserved when fragments are nested.
+func joinResults(parts []*parseResult, op string) *parseResult {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	sqls := make([]string, len(parts))
+	var params []interface{}
+	for i, part := range parts {
+		sqls[i] = "(" + part.sql + ")"
+		params = append(params, part.params...)
+	}
+	return &parseResult{
+		sql:    strings.Join(sqls, " "+op+" "),
+		params: params,
+	}
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonTypeName describes a decoded JSON value for error messages.
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
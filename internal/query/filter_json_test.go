@@ -0,0 +1,194 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseFilterJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     string
+		wantSQL    string
+		wantParams []interface{}
+		wantErr    bool
+	}{
+		{
+			"empty object",
+			`{}`,
+			"",
+			nil,
+			false,
+		},
+		{
+			"equality shorthand",
+			`{"status": "active"}`,
+			"status = $1",
+			[]interface{}{"active"},
+			false,
+		},
+		{
+			"null shorthand",
+			`{"deleted_at": null}`,
+			"deleted_at IS NULL",
+			nil,
+			false,
+		},
+		{
+			"integer and float binding",
+			`{"age": {"gt": 21}, "score": {"lte": 9.5}}`,
+			"(age > $1) AND (score <= $2)",
+			[]interface{}{int64(21), 9.5},
+			false,
+		},
+		{
+			"range on one column",
+			`{"age": {"gte": 18, "lt": 65}}`,
+			"(age >= $1) AND (age < $2)",
+			[]interface{}{int64(18), int64(65)},
+			false,
+		},
+		{
+			"and with nested or",
+			`{"and": [{"age": {"gt": 21}}, {"or": [{"status": "a"}, {"status": "b"}]}]}`,
+			"(age > $1) AND ((status = $2) OR (status = $3))",
+			[]interface{}{int64(21), "a", "b"},
+			false,
+		},
+		{
+			"not",
+			`{"not": {"role": "admin"}}`,
+			"NOT (role = $1)",
+			[]interface{}{"admin"},
+			false,
+		},
+		{
+			"in and not_in",
+			`{"id": {"in": [1, 2, 3]}, "tag": {"not_in": ["x"]}}`,
+			"(id IN ($1, $2, $3)) AND (tag NOT IN ($4))",
+			[]interface{}{int64(1), int64(2), int64(3), "x"},
+			false,
+		},
+		{
+			"ilike",
+			`{"name": {"ilike": "%smith"}}`,
+			"LOWER(name) LIKE LOWER($1)",
+			[]interface{}{"%smith"},
+			false,
+		},
+		{
+			"between",
+			`{"age": {"between": [18, 65]}}`,
+			"age BETWEEN $1 AND $2",
+			[]interface{}{int64(18), int64(65)},
+			false,
+		},
+		{
+			"string helpers",
+			`{"name": {"starts_with": "Jo"}}`,
+			"name LIKE $1",
+			[]interface{}{"Jo%"},
+			false,
+		},
+		{
+			"contains",
+			`{"name": {"contains": "oh"}}`,
+			"name LIKE $1",
+			[]interface{}{"%oh%"},
+			false,
+		},
+		{
+			"is_null false and ne null",
+			`{"a": {"is_null": false}, "b": {"ne": null}}`,
+			"(a IS NOT NULL) AND (b IS NOT NULL)",
+			nil,
+			false,
+		},
+		{
+			"boolean value",
+			`{"active": true}`,
+			"active = $1",
+			[]interface{}{true},
+			false,
+		},
+		{
+			"qualified column",
+			`{"users.age": {"gt": 1}}`,
+			"users.age > $1",
+			[]interface{}{int64(1)},
+			false,
+		},
+
+		// Errors.
+		{"not an object", `[1, 2]`, "", nil, true},
+		{"malformed JSON", `{"a":`, "", nil, true},
+		{"injection in column", `{"age; DROP TABLE users": 1}`, "", nil, true},
+		{"unknown operator", `{"age": {"regex": "x"}}`, "", nil, true},
+		{"empty and", `{"and": []}`, "", nil, true},
+		{"and not an array", `{"and": {"a": 1}}`, "", nil, true},
+		{"empty in list", `{"id": {"in": []}}`, "", nil, true},
+		{"between wrong arity", `{"age": {"between": [1]}}`, "", nil, true},
+		{"object as value", `{"age": {"eq": {"x": 1}}}`, "", nil, true},
+		{"gt null", `{"age": {"gt": null}}`, "", nil, true},
+		{"contains non-string", `{"name": {"contains": 1}}`, "", nil, true},
+		{"empty operator object", `{"age": {}}`, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFilterJSON([]byte(tt.filter), DollarPlaceholder, 1)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got SQL %q", result.SQL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantSQL == "" {
+				if result != nil {
+					t.Fatalf("expected nil result, got %+v", result)
+				}
+				return
+			}
+			if result.SQL != tt.wantSQL {
+				t.Errorf("SQL: got %q, want %q", result.SQL, tt.wantSQL)
+			}
+			if fmt.Sprint(result.Params) != fmt.Sprint(tt.wantParams) {
+				t.Errorf("Params: got %v, want %v", result.Params, tt.wantParams)
+			}
+		})
+	}
+}
+
+// TestParseFilterJSONMatchesStringFilter verifies that the JSON and string
+// grammars compile equivalent conditions to identical SQL and bind values.
+func TestParseFilterJSONMatchesStringFilter(t *testing.T) {
+	pairs := []struct {
+		filter string
+		json   string
+	}{
+		{"age > 21", `{"age": {"gt": 21}}`},
+		{"name IN ('a', 'b')", `{"name": {"in": ["a", "b"]}}`},
+		{"email IS NULL", `{"email": null}`},
+		{"name NOT LIKE 'x%'", `{"name": {"not_like": "x%"}}`},
+		{"age NOT BETWEEN 1 AND 2", `{"age": {"not_between": [1, 2]}}`},
+	}
+	for _, pair := range pairs {
+		fromString, err := ParseFilter(pair.filter, AtPPlaceholder, 3)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", pair.filter, err)
+		}
+		fromJSON, err := ParseFilterJSON([]byte(pair.json), AtPPlaceholder, 3)
+		if err != nil {
+			t.Fatalf("ParseFilterJSON(%s): %v", pair.json, err)
+		}
+		if fromString.SQL != fromJSON.SQL {
+			t.Errorf("%s: SQL %q != %q", pair.json, fromJSON.SQL, fromString.SQL)
+		}
+		if fmt.Sprint(fromString.Params) != fmt.Sprint(fromJSON.Params) {
+			t.Errorf("%s: params %v != %v", pair.json, fromJSON.Params, fromString.Params)
+		}
+	}
+}
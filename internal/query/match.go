@@ -0,0 +1,549 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RowFilter reports whether a row matches a filter. Rows map column names to
+// values as decoded from JSON or scanned by a driver.
+type RowFilter func(row map[string]interface{}) bool
+
+// CompileRowFilter compiles a filter in the syntax of ParseFilter into a
+// RowFilter, for rows that are not in the database, such as captured
+// changes. It follows SQL semantics: a comparison with a NULL value is
+// unknown, and a row matches only when the whole filter is true. LIKE,
+// CONTAINS, STARTS WITH and ENDS WITH compare case-insensitively.
+//
+// cols validates columns and coerces literals as in ParseFilterForColumns;
+// it may be nil. An empty filter matches every row.
+func CompileRowFilter(filter string, cols ColumnSet) (RowFilter, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return func(map[string]interface{}) bool { return true }, nil
+	}
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, fmt.Errorf("tokenize: %w", err)
+	}
+	m := &matcher{parser: parser{tokens: tokens, ph: QuestionPlaceholder, nextIndex: 1, cols: cols}}
+	pred, err := m.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if m.pos < len(m.tokens) {
+		return nil, fmt.Errorf("unexpected token %q at position %d", m.tokens[m.pos].value, m.tokens[m.pos].pos)
+	}
+	return func(row map[string]interface{}) bool { return pred(row) == truthTrue }, nil
+}
+
+// truth is a value of SQL's three-valued logic.
+type truth int8
+
+const (
+	truthUnknown truth = iota
+	truthFalse
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+type predicate func(row map[string]interface{}) truth
+
+// matcher compiles the filter grammar into predicates. It reuses the
+// parser's token handling, value parsing and literal coercion.
+type matcher struct {
+	parser
+}
+
+func (m *matcher) parseOr() (predicate, error) {
+	left, err := m.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := m.peek(); t != nil && t.typ == tokOR; t = m.peek() {
+		m.advance()
+		right, err := m.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row map[string]interface{}) truth {
+			a, b := l(row), right(row)
+			switch {
+			case a == truthTrue || b == truthTrue:
+				return truthTrue
+			case a == truthFalse && b == truthFalse:
+				return truthFalse
+			}
+			return truthUnknown
+		}
+	}
+	return left, nil
+}
+
+func (m *matcher) parseAnd() (predicate, error) {
+	left, err := m.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for t := m.peek(); t != nil && t.typ == tokAND; t = m.peek() {
+		m.advance()
+		right, err := m.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row map[string]interface{}) truth {
+			a, b := l(row), right(row)
+			switch {
+			case a == truthFalse || b == truthFalse:
+				return truthFalse
+			case a == truthTrue && b == truthTrue:
+				return truthTrue
+			}
+			return truthUnknown
+		}
+	}
+	return left, nil
+}
+
+func (m *matcher) parseNot() (predicate, error) {
+	if t := m.peek(); t != nil && t.typ == tokNOT {
+		m.advance()
+		inner, err := m.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return negate(inner), nil
+	}
+	if t := m.peek(); t != nil && t.typ == tokLParen {
+		m.advance()
+		inner, err := m.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := m.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return m.parseComparison()
+}
+
+func negate(p predicate) predicate {
+	return func(row map[string]interface{}) truth {
+		switch p(row) {
+		case truthTrue:
+			return truthFalse
+		case truthFalse:
+			return truthTrue
+		}
+		return truthUnknown
+	}
+}
+
+// parseComparison mirrors parser.parseComparison.
+func (m *matcher) parseComparison() (predicate, error) {
+	colTok, err := m.expect(tokIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("expected column name: %w", err)
+	}
+	if err := validateColumnRef(colTok.value); err != nil {
+		return nil, fmt.Errorf("invalid column name: %w", err)
+	}
+	if err := m.checkColumn(colTok.value); err != nil {
+		return nil, err
+	}
+	col := colTok.value
+	if i := strings.LastIndexByte(col, '.'); i >= 0 {
+		col = col[i+1:]
+	}
+
+	opTok := m.peek()
+	if opTok == nil {
+		return nil, fmt.Errorf("unexpected end of filter after column %q", col)
+	}
+	switch opTok.typ {
+	case tokOperator:
+		m.advance()
+		val, err := m.coercedValue()
+		if err != nil {
+			return nil, fmt.Errorf("expected value after %s %s: %w", col, opTok.value, err)
+		}
+		op := opTok.value
+		return func(row map[string]interface{}) truth {
+			c, ok := compareValues(rowValue(row, col), val)
+			if !ok {
+				return truthUnknown
+			}
+			switch op {
+			case "=":
+				return truthOf(c == 0)
+			case "!=", "<>":
+				return truthOf(c != 0)
+			case ">":
+				return truthOf(c > 0)
+			case ">=":
+				return truthOf(c >= 0)
+			case "<":
+				return truthOf(c < 0)
+			default:
+				return truthOf(c <= 0)
+			}
+		}, nil
+
+	case tokIS:
+		m.advance()
+		not := false
+		if t := m.peek(); t != nil && t.typ == tokNOT {
+			m.advance()
+			not = true
+		}
+		if _, err := m.expect(tokNULL); err != nil {
+			return nil, fmt.Errorf("expected NULL after %s IS: %w", col, err)
+		}
+		return func(row map[string]interface{}) truth {
+			return truthOf((rowValue(row, col) == nil) != not)
+		}, nil
+
+	case tokNOT:
+		m.advance()
+		next := m.peek()
+		if next == nil {
+			return nil, fmt.Errorf("unexpected end of filter after %s NOT", col)
+		}
+		var p predicate
+		switch next.typ {
+		case tokIN:
+			p, err = m.parseInList(col)
+		case tokLIKE:
+			p, err = m.parseLike(col)
+		case tokBETWEEN:
+			p, err = m.parseBetween(col)
+		default:
+			return nil, fmt.Errorf("expected IN, LIKE, or BETWEEN after %s NOT, got %q", col, next.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return negate(p), nil
+
+	case tokIN:
+		return m.parseInList(col)
+	case tokLIKE:
+		return m.parseLike(col)
+	case tokBETWEEN:
+		return m.parseBetween(col)
+
+	case tokCONTAINS, tokSTARTS, tokENDS:
+		m.advance()
+		if opTok.typ != tokCONTAINS {
+			if _, err := m.expect(tokWITH); err != nil {
+				return nil, fmt.Errorf("expected WITH after %s %s: %w", col, opTok.value, err)
+			}
+		}
+		val, err := m.parseValue()
+		if err != nil {
+			return nil, fmt.Errorf("expected value after %s %s: %w", col, opTok.value, err)
+		}
+		s, ok := val.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a string value, got %T", opTok.value, val.value)
+		}
+		switch opTok.typ {
+		case tokCONTAINS:
+			s = "%" + s + "%"
+		case tokSTARTS:
+			s += "%"
+		default:
+			s = "%" + s
+		}
+		return likePredicate(col, s), nil
+
+	default:
+		return nil, fmt.Errorf("unexpected token %q after column %q at position %d", opTok.value, col, opTok.pos)
+	}
+}
+
+func (m *matcher) coercedValue() (interface{}, error) {
+	val, err := m.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return m.coerce(val.value)
+}
+
+func (m *matcher) parseInList(col string) (predicate, error) {
+	m.advance() // IN
+	if _, err := m.expect(tokLParen); err != nil {
+		return nil, fmt.Errorf("expected '(' after %s IN: %w", col, err)
+	}
+	var values []interface{}
+	for {
+		v, err := m.coercedValue()
+		if err != nil {
+			return nil, fmt.Errorf("expected value in %s IN list: %w", col, err)
+		}
+		values = append(values, v)
+		next := m.peek()
+		if next == nil {
+			return nil, fmt.Errorf("unexpected end of filter in %s IN list", col)
+		}
+		m.advance()
+		if next.typ == tokRParen {
+			break
+		}
+		if next.typ != tokComma {
+			return nil, fmt.Errorf("expected ',' or ')' in %s IN list, got %q", col, next.value)
+		}
+	}
+	return func(row map[string]interface{}) truth {
+		v := rowValue(row, col)
+		if v == nil {
+			return truthUnknown
+		}
+		for _, want := range values {
+			if c, ok := compareValues(v, want); ok && c == 0 {
+				return truthTrue
+			}
+		}
+		return truthFalse
+	}, nil
+}
+
+func (m *matcher) parseLike(col string) (predicate, error) {
+	m.advance() // LIKE
+	val, err := m.parseValue()
+	if err != nil {
+		return nil, fmt.Errorf("expected value after %s LIKE: %w", col, err)
+	}
+	s, ok := val.value.(string)
+	if !ok {
+		return nil, fmt.Errorf("LIKE requires a string value, got %T", val.value)
+	}
+	return likePredicate(col, s), nil
+}
+
+func (m *matcher) parseBetween(col string) (predicate, error) {
+	m.advance() // BETWEEN
+	low, err := m.coercedValue()
+	if err != nil {
+		return nil, fmt.Errorf("expected lower bound after %s BETWEEN: %w", col, err)
+	}
+	if _, err := m.expect(tokAND); err != nil {
+		return nil, fmt.Errorf("expected AND in %s BETWEEN: %w", col, err)
+	}
+	high, err := m.coercedValue()
+	if err != nil {
+		return nil, fmt.Errorf("expected upper bound in %s BETWEEN: %w", col, err)
+	}
+	return func(row map[string]interface{}) truth {
+		v := rowValue(row, col)
+		lo, ok1 := compareValues(v, low)
+		hi, ok2 := compareValues(v, high)
+		if !ok1 || !ok2 {
+			return truthUnknown
+		}
+		return truthOf(lo >= 0 && hi <= 0)
+	}, nil
+}
+
+// rowValue returns the value of col, matching the name case-insensitively
+// when there is no exact match.
+func rowValue(row map[string]interface{}, col string) interface{} {
+	if v, ok := row[col]; ok {
+		return v
+	}
+	for name, v := range row {
+		if strings.EqualFold(name, col) {
+			return v
+		}
+	}
+	return nil
+}
+
+// compareValues compares a row value with a literal, numerically when the
+// literal is a number, as booleans when it is a boolean and as text
+// otherwise. ok is false when v is NULL or cannot be compared.
+func compareValues(v, lit interface{}) (c int, ok bool) {
+	if v == nil || lit == nil {
+		return 0, false
+	}
+	switch l := lit.(type) {
+	case int64:
+		if n, isInt := intValue(v); isInt {
+			return cmpOrdered(n, l), true
+		}
+		if f, isNum := floatValue(v); isNum {
+			return cmpOrdered(f, float64(l)), true
+		}
+		return 0, false
+	case float64:
+		if f, isNum := floatValue(v); isNum {
+			return cmpOrdered(f, l), true
+		}
+		return 0, false
+	case bool:
+		b, isBool := boolValue(v)
+		if !isBool {
+			return 0, false
+		}
+		switch {
+		case b == l:
+			return 0, true
+		case l:
+			return -1, true
+		}
+		return 1, true
+	case string:
+		s, isText := textOf(v)
+		if !isText {
+			return 0, false
+		}
+		return strings.Compare(s, l), true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func intValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	case []byte:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func floatValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	}
+	if i, ok := intValue(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// boolValue accepts booleans and the 0/1 integers that databases without a
+// boolean type store.
+func boolValue(v interface{}) (bool, bool) {
+	if b, ok := v.(bool); ok {
+		return b, true
+	}
+	if i, ok := intValue(v); ok && (i == 0 || i == 1) {
+		return i == 1, true
+	}
+	if s, ok := v.(string); ok {
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func textOf(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	case json.Number:
+		return s.String(), true
+	case bool:
+		return strconv.FormatBool(s), true
+	case int64, int, int32, float64, float32:
+		return fmt.Sprint(s), true
+	case fmt.Stringer:
+		return s.String(), true
+	}
+	return "", false
+}
+
+// likePredicate matches col against a LIKE pattern where % matches any run
+// of characters, _ one character and \ escapes the next one.
+func likePredicate(col, pattern string) predicate {
+	pat := []rune(strings.ToLower(pattern))
+	return func(row map[string]interface{}) truth {
+		s, ok := textOf(rowValue(row, col))
+		if !ok {
+			return truthUnknown
+		}
+		return truthOf(likeMatch([]rune(strings.ToLower(s)), pat))
+	}
+}
+
+func likeMatch(s, pat []rune) bool {
+	for len(pat) > 0 {
+		switch pat[0] {
+		case '%':
+			for len(pat) > 0 && pat[0] == '%' {
+				pat = pat[1:]
+			}
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if likeMatch(s[i:], pat) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			c := pat[0]
+			if c == '\\' && len(pat) > 1 {
+				pat = pat[1:]
+				c = pat[0]
+			}
+			if len(s) == 0 || s[0] != c {
+				return false
+			}
+		}
+		s, pat = s[1:], pat[1:]
+	}
+	return len(s) == 0
+}
@@ -0,0 +1,67 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompileRowFilter(t *testing.T) {
+	row := map[string]interface{}{
+		"id":         json.Number("7"),
+		"name":       "O'Neil",
+		"status":     "paid",
+		"total":      json.Number("120.5"),
+		"vip":        json.Number("1"),
+		"deleted_at": nil,
+		"Region":     "EU",
+	}
+	cols := ColumnSet{
+		"id": "integer", "name": "string", "status": "string", "total": "number",
+		"vip": "boolean", "deleted_at": "string(date-time)", "Region": "string",
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"id = 7", true},
+		{"id = '7'", true},
+		{"total > 100 AND status = 'paid'", true},
+		{"total >= 200 OR status = 'new'", false},
+		{"NOT (status = 'new')", true},
+		{"status IN ('new', 'paid')", true},
+		{"status NOT IN ('new', 'paid')", false},
+		{"id BETWEEN 1 AND 5", false},
+		{"id NOT BETWEEN 1 AND 5", true},
+		{"name LIKE 'o''%'", true},
+		{"name CONTAINS 'NEI'", true},
+		{"name STARTS WITH 'x'", false},
+		{"name ENDS WITH 'il'", true},
+		{"vip = true", true},
+		{"deleted_at IS NULL", true},
+		{"deleted_at IS NOT NULL", false},
+		// Comparisons with NULL are unknown, and so is their negation.
+		{"deleted_at = '2024-01-01'", false},
+		{"NOT (deleted_at = '2024-01-01')", false},
+		{"deleted_at = '2024-01-01' OR id = 7", true},
+		{"region = 'EU'", true},
+		{"orders.status = 'paid'", true},
+	}
+	for _, tt := range tests {
+		match, err := CompileRowFilter(tt.filter, cols)
+		if err != nil {
+			t.Errorf("CompileRowFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		if got := match(row); got != tt.want {
+			t.Errorf("CompileRowFilter(%q) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"nope = 1", "id = 'x'", "status = ", "id = 1 2", "name CONTAINS 3"} {
+		if _, err := CompileRowFilter(bad, cols); err == nil {
+			t.Errorf("CompileRowFilter(%q): expected error", bad)
+		}
+	}
+}
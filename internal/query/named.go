@@ -0,0 +1,291 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// ---------------------------------------------------------------------------
+// Named (admin-defined) queries
+// ---------------------------------------------------------------------------
+
+// namedParamTypes lists the parameter types a named query may declare. They
+// share their names with the JSON types used by ColumnSet, so literal
+// coercion is identical for filters and named query arguments.
+var namedParamTypes = map[string]bool{
+	"string":  true,
+	"integer": true,
+	"number":  true,
+	"boolean": true,
+}
+
+// NamedParams returns the distinct :name parameter references in sql, in
+// order of first appearance. References inside string literals, quoted
+// identifiers and comments are ignored, as are PostgreSQL :: casts.
+func NamedParams(sql string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	scanNamedParams(sql, func(name string) string {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return ":" + name
+	})
+	return names
+}
+
+// CompileNamed rewrites the :name references in sql into dialect
+// placeholders produced by ph (numbered from 1) and returns the bind values
+// in placeholder order. A parameter referenced more than once is bound once
+// per reference. Every referenced parameter must be present in args.
+func CompileNamed(sql string, args map[string]interface{}, ph PlaceholderFunc) (string, []interface{}, error) {
+	if ph == nil {
+		ph = DollarPlaceholder
+	}
+	var (
+		params  []interface{}
+		missing string
+	)
+	out := scanNamedParams(sql, func(name string) string {
+		val, ok := args[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return ":" + name
+		}
+		params = append(params, val)
+		return ph(len(params))
+	})
+	if missing != "" {
+		return "", nil, fmt.Errorf("missing value for parameter %q", missing)
+	}
+	return out, params, nil
+}
+
+// ValidateNamedQuery checks a named query definition before it is saved: the
+// name must be a plain identifier, parameter declarations must be unique and
+// typed, defaults must match their type, and the set of declared parameters
+// must match the :name references in the SQL exactly.
+func ValidateNamedQuery(nq *model.NamedQuery) error {
+	if !isNamedIdent(nq.Name) {
+		return fmt.Errorf("invalid query name %q: must match [a-zA-Z_][a-zA-Z0-9_]*", nq.Name)
+	}
+	if strings.TrimSpace(nq.SQL) == "" {
+		return fmt.Errorf("sql is required")
+	}
+	if nq.CacheTTL < 0 {
+		return fmt.Errorf("cache_ttl must not be negative")
+	}
+
+	declared := make(map[string]bool, len(nq.Params))
+	types := make(ColumnSet, len(nq.Params))
+	for _, p := range nq.Params {
+		if !isNamedIdent(p.Name) {
+			return fmt.Errorf("invalid parameter name %q: must match [a-zA-Z_][a-zA-Z0-9_]*", p.Name)
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("parameter %q is declared more than once", p.Name)
+		}
+		declared[p.Name] = true
+		if !namedParamTypes[p.Type] {
+			return fmt.Errorf("parameter %q has unsupported type %q (expected string, integer, number or boolean)", p.Name, p.Type)
+		}
+		types[p.Name] = p.Type
+		if p.Default != nil {
+			if _, err := coerceParam(types, p.Name, p.Default); err != nil {
+				return fmt.Errorf("default for %w", err)
+			}
+		}
+	}
+
+	referenced := NamedParams(nq.SQL)
+	for _, name := range referenced {
+		if !declared[name] {
+			return fmt.Errorf("sql references undeclared parameter :%s", name)
+		}
+		delete(declared, name)
+	}
+	for _, p := range nq.Params {
+		if declared[p.Name] {
+			return fmt.Errorf("parameter %q is declared but not used in sql", p.Name)
+		}
+	}
+	return nil
+}
+
+// BindNamedQuery resolves the arguments of a named query call and compiles
+// its SQL. Missing arguments fall back to the declared default; a missing
+// required argument, an undeclared argument or a value that cannot be
+// coerced to the declared type is an error. Optional arguments without a
+// default bind as NULL.
+func BindNamedQuery(nq *model.NamedQuery, values map[string]interface{}, ph PlaceholderFunc) (string, []interface{}, error) {
+	types := make(ColumnSet, len(nq.Params))
+	for _, p := range nq.Params {
+		types[p.Name] = p.Type
+	}
+	for name := range values {
+		if _, ok := types[name]; !ok {
+			return "", nil, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	args := make(map[string]interface{}, len(nq.Params))
+	for _, p := range nq.Params {
+		val, ok := values[p.Name]
+		if !ok || val == nil {
+			if p.Default != nil {
+				val = p.Default
+			} else if p.Required {
+				return "", nil, fmt.Errorf("parameter %q is required", p.Name)
+			}
+		}
+		v, err := coerceParam(types, p.Name, val)
+		if err != nil {
+			return "", nil, err
+		}
+		args[p.Name] = v
+	}
+	return CompileNamed(nq.SQL, args, ph)
+}
+
+// coerceParam converts a named query argument to its declared type.
+// Unlike filter literals, integer parameters must be whole numbers.
+func coerceParam(types ColumnSet, name string, val interface{}) (interface{}, error) {
+	v, err := types.Coerce(name, val)
+	if _, frac := v.(float64); err == nil && frac && types[name] == "integer" {
+		err = fmt.Errorf("fractional value")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parameter %q expects %s, got %s", name, articleType(types[name]), describeLiteral(val))
+	}
+	return v, nil
+}
+
+// articleType prefixes a type name with its indefinite article.
+func articleType(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+// scanNamedParams walks sql and replaces each :name reference with the
+// result of repl. Quoted strings ('...'), quoted identifiers ("...", `...`,
+// [...]), line and block comments, PostgreSQL dollar-quoted strings and ::
+// casts are copied through untouched.
+func scanNamedParams(sql string, repl func(name string) string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	n := len(sql)
+	for i := 0; i < n; {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := skipQuoted(sql, i, c)
+			b.WriteString(sql[i:j])
+			i = j
+		case c == '[':
+			j := strings.IndexByte(sql[i:], ']')
+			if j < 0 {
+				j = n - i - 1
+			}
+			b.WriteString(sql[i : i+j+1])
+			i += j + 1
+		case c == '-' && i+1 < n && sql[i+1] == '-':
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				j = n - i
+			}
+			b.WriteString(sql[i : i+j])
+			i += j
+		case c == '/' && i+1 < n && sql[i+1] == '*':
+			j := strings.Index(sql[i+2:], "*/")
+			end := n
+			if j >= 0 {
+				end = i + 2 + j + 2
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '$':
+			end := skipDollarQuoted(sql, i)
+			b.WriteString(sql[i:end])
+			i = end
+		case c == ':' && i+1 < n && sql[i+1] == ':':
+			b.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < n && isIdentStart(sql[i+1]):
+			j := i + 1
+			for j < n && isIdentPart(sql[j]) {
+				j++
+			}
+			b.WriteString(repl(sql[i+1 : j]))
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted returns the index just past the quoted section starting at i.
+// A doubled quote character inside the section is an escaped quote.
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != quote {
+			continue
+		}
+		if j+1 < len(sql) && sql[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the index just past a PostgreSQL dollar-quoted
+// string ($$...$$ or $tag$...$tag$) starting at i, or i+1 if the '$' does not
+// open one (e.g. a $1 placeholder).
+func skipDollarQuoted(sql string, i int) int {
+	j := i + 1
+	for j < len(sql) && isIdentPart(sql[j]) && !(j == i+1 && sql[j] >= '0' && sql[j] <= '9') {
+		j++
+	}
+	if j >= len(sql) || sql[j] != '$' {
+		return i + 1
+	}
+	tag := sql[i : j+1]
+	end := strings.Index(sql[j+1:], tag)
+	if end < 0 {
+		return len(sql)
+	}
+	return j + 1 + end + len(tag)
+}
+
+// isNamedIdent reports whether s is usable as a query or parameter name.
+// Unlike ValidateIdentifier it accepts SQL reserved words, since names never
+// reach the SQL text (":limit" is a perfectly good parameter).
+func isNamedIdent(s string) bool {
+	if s == "" || len(s) > 128 || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentPart(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
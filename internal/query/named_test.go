@@ -0,0 +1,143 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+func TestNamedParams(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT * FROM t WHERE a = :a AND b = :b OR a = :a", []string{"a", "b"}},
+		{"SELECT ':not_param', \":ident\" FROM t WHERE x = :x", []string{"x"}},
+		{"SELECT x::int FROM t -- :comment\nWHERE y = :y /* :block */", []string{"y"}},
+		{"SELECT $$ :inside $$, $tag$ :also $tag$ WHERE z = :z AND w = $1", []string{"z"}},
+		{"SELECT [:col] FROM t WHERE q = :q_1", []string{"q_1"}},
+		{"SELECT 'it''s :quoted' WHERE v = :v", []string{"v"}},
+		{"SELECT 1", nil},
+	}
+	for _, tt := range tests {
+		if got := NamedParams(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NamedParams(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestCompileNamed(t *testing.T) {
+	sql := "SELECT * FROM t WHERE a = :a AND b::text = :b OR c = ':a' OR a < :a"
+	args := map[string]interface{}{"a": 1, "b": "x"}
+
+	tests := []struct {
+		name    string
+		ph      PlaceholderFunc
+		wantSQL string
+	}{
+		{"dollar", DollarPlaceholder, "SELECT * FROM t WHERE a = $1 AND b::text = $2 OR c = ':a' OR a < $3"},
+		{"question", QuestionPlaceholder, "SELECT * FROM t WHERE a = ? AND b::text = ? OR c = ':a' OR a < ?"},
+		{"at-p", AtPPlaceholder, "SELECT * FROM t WHERE a = @p1 AND b::text = @p2 OR c = ':a' OR a < @p3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, params, err := CompileNamed(sql, args, tt.ph)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.wantSQL {
+				t.Errorf("SQL: got %q, want %q", got, tt.wantSQL)
+			}
+			if fmt.Sprint(params) != "[1 x 1]" {
+				t.Errorf("params: got %v", params)
+			}
+		})
+	}
+
+	if _, _, err := CompileNamed("SELECT :missing", nil, DollarPlaceholder); err == nil {
+		t.Error("expected error for missing argument")
+	}
+}
+
+func TestValidateNamedQuery(t *testing.T) {
+	valid := &model.NamedQuery{
+		Name: "top_customers",
+		SQL:  "SELECT * FROM customers WHERE region = :region LIMIT :limit",
+		Params: []model.QueryParam{
+			{Name: "region", Type: "string", Required: true},
+			{Name: "limit", Type: "integer", Default: float64(10)},
+		},
+	}
+	if err := ValidateNamedQuery(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		nq   model.NamedQuery
+	}{
+		{"bad name", model.NamedQuery{Name: "top-customers", SQL: "SELECT 1"}},
+		{"empty sql", model.NamedQuery{Name: "q", SQL: "  "}},
+		{"negative ttl", model.NamedQuery{Name: "q", SQL: "SELECT 1", CacheTTL: -1}},
+		{"undeclared", model.NamedQuery{Name: "q", SQL: "SELECT :a"}},
+		{"unused", model.NamedQuery{Name: "q", SQL: "SELECT 1", Params: []model.QueryParam{{Name: "a", Type: "string"}}}},
+		{"duplicate", model.NamedQuery{Name: "q", SQL: "SELECT :a", Params: []model.QueryParam{{Name: "a", Type: "string"}, {Name: "a", Type: "string"}}}},
+		{"bad type", model.NamedQuery{Name: "q", SQL: "SELECT :a", Params: []model.QueryParam{{Name: "a", Type: "date"}}}},
+		{"bad default", model.NamedQuery{Name: "q", SQL: "SELECT :a", Params: []model.QueryParam{{Name: "a", Type: "boolean", Default: "maybe"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNamedQuery(&tt.nq); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestBindNamedQuery(t *testing.T) {
+	nq := &model.NamedQuery{
+		Name: "orders",
+		SQL:  "SELECT * FROM orders WHERE customer_id = :customer AND paid = :paid AND total > :min_total",
+		Params: []model.QueryParam{
+			{Name: "customer", Type: "integer", Required: true},
+			{Name: "paid", Type: "boolean", Default: true},
+			{Name: "min_total", Type: "number"},
+		},
+	}
+
+	sql, params, err := BindNamedQuery(nq, map[string]interface{}{"customer": "42", "min_total": "9.5"}, DollarPlaceholder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sql != "SELECT * FROM orders WHERE customer_id = $1 AND paid = $2 AND total > $3" {
+		t.Errorf("SQL: got %q", sql)
+	}
+	want := []interface{}{int64(42), true, 9.5}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params: got %#v, want %#v", params, want)
+	}
+
+	// JSON numbers arrive as float64; whole values bind as integers, and an
+	// optional argument without a default binds as NULL.
+	_, params, err = BindNamedQuery(nq, map[string]interface{}{"customer": float64(7)}, DollarPlaceholder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []interface{}{int64(7), true, nil}; !reflect.DeepEqual(params, want) {
+		t.Errorf("params: got %#v, want %#v", params, want)
+	}
+
+	errCases := []map[string]interface{}{
+		{},                                 // missing required
+		{"customer": 1.5},                  // fractional integer
+		{"customer": 1, "paid": "perhaps"}, // bad boolean
+		{"customer": 1, "other": "x"},      // undeclared
+	}
+	for i, args := range errCases {
+		if _, _, err := BindNamedQuery(nq, args, DollarPlaceholder); err == nil {
+			t.Errorf("case %d: expected error for %v", i, args)
+		}
+	}
+}
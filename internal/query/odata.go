@@ -0,0 +1,386 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// OData query options
+// ---------------------------------------------------------------------------
+
+// ParseODataFilter translates an OData v4 $filter expression into the
+// structured filter tree accepted by ParseFilterJSON, so that it is
+// validated, coerced and compiled like any other filter.
+//
+// Supported: the comparison operators eq, ne, gt, ge, lt and le (with the
+// property on either side), in, and, or, not, parentheses, the functions
+// contains, startswith and endswith, and a bare boolean property. Literals
+// are strings ('O”Neil'), numbers, true, false, null, and unquoted date,
+// time and GUID literals, which are passed on as strings. Navigation paths
+// and arithmetic are not supported.
+func ParseODataFilter(expr string) (map[string]interface{}, error) {
+	tokens, err := tokenizeOData(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &odataParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].value, p.tokens[p.pos].pos)
+	}
+	return node, nil
+}
+
+// ParseODataOrderBy parses an OData $orderby value such as
+// "created_at desc,name" into validated order clauses.
+func ParseODataOrderBy(orderBy string) ([]OrderClause, error) {
+	var clauses []OrderClause
+	for _, part := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid $orderby item %q: expected 'property [asc|desc]'", strings.TrimSpace(part))
+		}
+		if strings.Contains(fields[0], "/") {
+			return nil, fmt.Errorf("ordering by navigation path %q is not supported", fields[0])
+		}
+		if err := ValidateIdentifier(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid $orderby property: %w", err)
+		}
+		dir := "ASC"
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				dir = "DESC"
+			default:
+				return nil, fmt.Errorf("invalid $orderby direction %q: must be asc or desc", fields[1])
+			}
+		}
+		clauses = append(clauses, OrderClause{Column: fields[0], Direction: dir})
+	}
+	return clauses, nil
+}
+
+type odataTokenKind int
+
+const (
+	odataIdent odataTokenKind = iota
+	odataString
+	odataLiteral // number, date, time or GUID
+	odataOpen
+	odataClose
+	odataComma
+)
+
+type odataToken struct {
+	kind  odataTokenKind
+	value string
+	pos   int
+}
+
+func tokenizeOData(input string) ([]odataToken, error) {
+	var tokens []odataToken
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, odataToken{odataOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, odataToken{odataClose, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, odataToken{odataComma, ",", i})
+			i++
+		case c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				}
+				if input[i] == '\'' {
+					if i+1 < len(input) && input[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, odataToken{odataString, b.String(), start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			for i < len(input) && isODataLiteralChar(input[i]) {
+				i++
+			}
+			tokens = append(tokens, odataToken{odataLiteral, input[start:i], start})
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(input) && (isIdentPart(input[i]) || input[i] == '/' || input[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, odataToken{odataIdent, input[start:i], start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isODataLiteralChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		c == '.' || c == '-' || c == ':' || c == '+'
+}
+
+type odataParser struct {
+	tokens []odataToken
+	pos    int
+}
+
+func (p *odataParser) peek() *odataToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// keyword reports whether the next token is the given keyword, consuming it
+// if so.
+func (p *odataParser) keyword(kw string) bool {
+	if t := p.peek(); t != nil && t.kind == odataIdent && t.value == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *odataParser) expect(kind odataTokenKind, what string) (*odataToken, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("expected %s at end of filter", what)
+	}
+	if t.kind != kind {
+		return nil, fmt.Errorf("expected %s at position %d, got %q", what, t.pos, t.value)
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *odataParser) parseOr() (map[string]interface{}, error) {
+	return p.parseLogical("or", p.parseAnd)
+}
+
+func (p *odataParser) parseAnd() (map[string]interface{}, error) {
+	return p.parseLogical("and", p.parseNot)
+}
+
+func (p *odataParser) parseLogical(op string, next func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []interface{}{first}
+	for p.keyword(op) {
+		node, err := next()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return map[string]interface{}{op: nodes}, nil
+}
+
+func (p *odataParser) parseNot() (map[string]interface{}, error) {
+	if p.keyword("not") {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"not": node}, nil
+	}
+	return p.parsePrimary()
+}
+
+// odataComparisons maps OData comparison operators to structured filter
+// operators, and mirrored gives the operator with its operands swapped.
+var (
+	odataComparisons = map[string]string{"eq": "eq", "ne": "ne", "gt": "gt", "ge": "gte", "lt": "lt", "le": "lte"}
+	odataMirrored    = map[string]string{"eq": "eq", "ne": "ne", "gt": "lt", "gte": "lte", "lt": "gt", "lte": "gte"}
+	odataFunctions   = map[string]string{"contains": "contains", "startswith": "starts_with", "endswith": "ends_with"}
+)
+
+func (p *odataParser) parsePrimary() (map[string]interface{}, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+
+	if t.kind == odataOpen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(odataClose, "')'"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	if op, ok := odataFunctions[t.value]; ok && t.kind == odataIdent &&
+		p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == odataOpen {
+		p.pos += 2
+		prop, err := p.property()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(odataComma, "','"); err != nil {
+			return nil, err
+		}
+		arg, err := p.expect(odataString, "a string")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(odataClose, "')'"); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{prop: map[string]interface{}{op: arg.value}}, nil
+	}
+
+	left, leftIsProp, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("in") {
+		if !leftIsProp {
+			return nil, fmt.Errorf("the left side of in must be a property")
+		}
+		if _, err := p.expect(odataOpen, "'('"); err != nil {
+			return nil, err
+		}
+		var list []interface{}
+		for {
+			v, isProp, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			if isProp {
+				return nil, fmt.Errorf("in requires a list of literals")
+			}
+			list = append(list, v)
+			if t := p.peek(); t != nil && t.kind == odataComma {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if _, err := p.expect(odataClose, "')'"); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{left.(string): map[string]interface{}{"in": list}}, nil
+	}
+
+	next := p.peek()
+	op, isComparison := "", false
+	if next != nil && next.kind == odataIdent {
+		op, isComparison = odataComparisons[next.value]
+	}
+	if !isComparison {
+		if leftIsProp {
+			// A bare boolean property.
+			return map[string]interface{}{left.(string): true}, nil
+		}
+		return nil, fmt.Errorf("expected a comparison after %v", left)
+	}
+	p.pos++
+
+	right, rightIsProp, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case leftIsProp && !rightIsProp:
+	case rightIsProp && !leftIsProp:
+		left, right, op = right, left, odataMirrored[op]
+	case leftIsProp:
+		return nil, fmt.Errorf("comparing two properties is not supported")
+	default:
+		return nil, fmt.Errorf("a comparison needs a property on one side")
+	}
+	return map[string]interface{}{left.(string): map[string]interface{}{op: right}}, nil
+}
+
+// property consumes a property name.
+func (p *odataParser) property() (string, error) {
+	v, isProp, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	if !isProp {
+		return "", fmt.Errorf("expected a property, got %v", v)
+	}
+	return v.(string), nil
+}
+
+// operand consumes a property or a literal. Properties are returned as
+// their name; numbers as json.Number so that ParseFilterJSON binds them as
+// it would a JSON number.
+func (p *odataParser) operand() (interface{}, bool, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, false, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	switch t.kind {
+	case odataString:
+		return t.value, false, nil
+	case odataLiteral:
+		if _, err := strconv.ParseFloat(t.value, 64); err == nil {
+			return json.Number(t.value), false, nil
+		}
+		// Dates, times and GUIDs bind as strings and are coerced by the
+		// column type.
+		return t.value, false, nil
+	case odataIdent:
+		switch t.value {
+		case "true":
+			return true, false, nil
+		case "false":
+			return false, false, nil
+		case "null":
+			return nil, false, nil
+		}
+		if strings.ContainsAny(t.value, "/.") {
+			return nil, false, fmt.Errorf("navigation path %q is not supported in $filter", t.value)
+		}
+		if err := ValidateIdentifier(t.value); err != nil {
+			return nil, false, err
+		}
+		return t.value, true, nil
+	}
+	return nil, false, fmt.Errorf("unexpected %q at position %d", t.value, t.pos)
+}
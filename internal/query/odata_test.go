@@ -0,0 +1,113 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestParseODataFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     string
+		wantSQL    string
+		wantParams []interface{}
+		wantErr    bool
+	}{
+		{
+			"comparison",
+			`Price ge 10.5`,
+			"Price >= $1",
+			[]interface{}{10.5},
+			false,
+		},
+		{
+			"precedence and grouping",
+			`status eq 'paid' and (total gt 100 or vip eq true)`,
+			"(status = $1) AND ((total > $2) OR (vip = $3))",
+			[]interface{}{"paid", int64(100), true},
+			false,
+		},
+		{
+			"literal on the left",
+			`18 le age`,
+			"age >= $1",
+			[]interface{}{int64(18)},
+			false,
+		},
+		{
+			"null and not",
+			`not (deleted_at ne null)`,
+			"NOT (deleted_at IS NOT NULL)",
+			nil,
+			false,
+		},
+		{
+			"functions and escaped quotes",
+			`contains(name,'O''Neil') or startswith(sku,'A-')`,
+			"(name LIKE $1) OR (sku LIKE $2)",
+			[]interface{}{"%O'Neil%", "A-%"},
+			false,
+		},
+		{
+			"in list",
+			`region in ('EU', 'US')`,
+			"region IN ($1, $2)",
+			[]interface{}{"EU", "US"},
+			false,
+		},
+		{
+			"date literal and bare boolean",
+			`created_at lt 2024-01-01T00:00:00Z and active`,
+			"(created_at < $1) AND (active = $2)",
+			[]interface{}{"2024-01-01T00:00:00Z", true},
+			false,
+		},
+		{"navigation path", `customer/name eq 'x'`, "", nil, true},
+		{"two properties", `a eq b`, "", nil, true},
+		{"unterminated string", `name eq 'x`, "", nil, true},
+		{"trailing tokens", `a eq 1 2`, "", nil, true},
+		{"injection attempt", `name; DROP TABLE users eq 1`, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseODataFilter(tt.filter)
+			var parsed *ParsedFilter
+			if err == nil {
+				data, _ := json.Marshal(tree)
+				parsed, err = ParseFilterJSON(data, DollarPlaceholder, 1)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed.SQL != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", parsed.SQL, tt.wantSQL)
+			}
+			if fmt.Sprint(parsed.Params) != fmt.Sprint(tt.wantParams) {
+				t.Errorf("params = %v, want %v", parsed.Params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestParseODataOrderBy(t *testing.T) {
+	clauses, err := ParseODataOrderBy("created_at desc, name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(clauses) != "[created_at DESC name ASC]" {
+		t.Errorf("clauses = %v", clauses)
+	}
+	for _, bad := range []string{"name sideways", "customer/name", "a b c", "na-me"} {
+		if _, err := ParseODataOrderBy(bad); err == nil {
+			t.Errorf("ParseODataOrderBy(%q): expected error", bad)
+		}
+	}
+}
@@ -52,6 +52,22 @@ type ParsedFilter struct {
 //
 // Returns nil, nil for an empty filter string.
 func ParseFilter(filter string, ph PlaceholderFunc, startIndex int) (*ParsedFilter, error) {
+	return parseFilterWith(filter, ph, startIndex, nil, nil)
+}
+
+// ParseFilterForColumns is ParseFilter with schema-aware validation: every
+// column must exist in cols (otherwise an *UnknownColumnError is returned) and
+// each literal compared with a column is coerced to that column's type.
+func ParseFilterForColumns(filter string, ph PlaceholderFunc, startIndex int, cols ColumnSet) (*ParsedFilter, error) {
+	return parseFilterWith(filter, ph, startIndex, nil, cols)
+}
+
+// parseFilterWith is the shared implementation behind ParseFilter and
+// ParseHaving. When colFn is non-nil, every validated column reference is
+// passed through it and replaced by the returned SQL expression; an error
+// from colFn aborts parsing. When cols is non-nil, columns are checked and
+// literals coerced against it.
+func parseFilterWith(filter string, ph PlaceholderFunc, startIndex int, colFn func(string) (string, error), cols ColumnSet) (*ParsedFilter, error) {
 	filter = strings.TrimSpace(filter)
 	if filter == "" {
 		return nil, nil
@@ -73,6 +89,8 @@ func ParseFilter(filter string, ph PlaceholderFunc, startIndex int) (*ParsedFilt
 		pos:       0,
 		ph:        ph,
 		nextIndex: startIndex,
+		colFn:     colFn,
+		cols:      cols,
 	}
 
 	node, err := p.parseExpression()
@@ -306,6 +324,14 @@ type parser struct {
 	pos       int
 	ph        PlaceholderFunc
 	nextIndex int // Next placeholder index (1-based).
+
+	// colFn optionally rewrites column references (see parseFilterWith).
+	colFn func(string) (string, error)
+
+	// cols optionally validates columns and coerces literals; curCol is the
+	// raw name of the column currently being compared.
+	cols   ColumnSet
+	curCol string
 }
 
 // peek returns the current token without advancing, or nil if at EOF.
@@ -338,6 +364,19 @@ func (p *parser) expect(typ tokenType) (*token, error) {
 	return t, nil
 }
 
+// checkColumn validates a raw column reference against p.cols and records it
+// as the target for subsequent coerce calls.
+func (p *parser) checkColumn(col string) error {
+	p.curCol = col
+	return p.cols.Check(col)
+}
+
+// coerce converts a literal to the type of the current column (see
+// ColumnSet.Coerce). It is a no-op without a ColumnSet.
+func (p *parser) coerce(val interface{}) (interface{}, error) {
+	return p.cols.Coerce(p.curCol, val)
+}
+
 // addParam registers a bind parameter and returns its placeholder string.
 func (p *parser) addParam(val interface{}) string {
 	placeholder := p.ph(p.nextIndex)
@@ -480,7 +519,17 @@ func (p *parser) parseComparison() (*parseResult, error) {
 		return nil, fmt.Errorf("invalid column name: %w", err)
 	}
 
+	if err := p.checkColumn(colTok.value); err != nil {
+		return nil, err
+	}
+
 	col := colTok.value
+	if p.colFn != nil {
+		col, err = p.colFn(col)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Look at the next token to determine the operator.
 	opTok := p.peek()
@@ -497,10 +546,14 @@ func (p *parser) parseComparison() (*parseResult, error) {
 		if err != nil {
 			return nil, fmt.Errorf("expected value after %s %s: %w", col, opTok.value, err)
 		}
-		ph := p.addParam(val.value)
+		v, err := p.coerce(val.value)
+		if err != nil {
+			return nil, err
+		}
+		ph := p.addParam(v)
 		return &parseResult{
 			sql:    col + " " + opTok.value + " " + ph,
-			params: []interface{}{val.value},
+			params: []interface{}{v},
 		}, nil
 
 	// IS NULL / IS NOT NULL
@@ -638,9 +691,13 @@ func (p *parser) parseInList(col, op string) (*parseResult, error) {
 		if err != nil {
 			return nil, fmt.Errorf("expected value in %s %s list: %w", col, op, err)
 		}
-		ph := p.addParam(val.value)
+		v, err := p.coerce(val.value)
+		if err != nil {
+			return nil, err
+		}
+		ph := p.addParam(v)
 		placeholders = append(placeholders, ph)
-		params = append(params, val.value)
+		params = append(params, v)
 
 		// Check for comma or closing paren.
 		next := p.peek()
@@ -705,11 +762,19 @@ func (p *parser) parseBetween(col, op string) (*parseResult, error) {
 		return nil, fmt.Errorf("expected upper bound in %s %s: %w", col, op, err)
 	}
 
-	phLow := p.addParam(low.value)
-	phHigh := p.addParam(high.value)
+	lowVal, err := p.coerce(low.value)
+	if err != nil {
+		return nil, err
+	}
+	highVal, err := p.coerce(high.value)
+	if err != nil {
+		return nil, err
+	}
+	phLow := p.addParam(lowVal)
+	phHigh := p.addParam(highVal)
 	return &parseResult{
 		sql:    col + " " + op + " " + phLow + " AND " + phHigh,
-		params: []interface{}{low.value, high.value},
+		params: []interface{}{lowVal, highVal},
 	}, nil
 }
 
@@ -0,0 +1,252 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// PostgREST query syntax
+// ---------------------------------------------------------------------------
+
+// postgrestOperators maps PostgREST comparison operators to structured
+// filter operators.
+var postgrestOperators = map[string]string{
+	"eq":    "eq",
+	"neq":   "ne",
+	"gt":    "gt",
+	"gte":   "gte",
+	"lt":    "lt",
+	"lte":   "lte",
+	"like":  "like",
+	"ilike": "ilike",
+}
+
+// ParsePostgRESTFilter translates one PostgREST horizontal filter, a column
+// and an operator expression such as "gt.21" from ?age=gt.21, into the
+// structured filter tree accepted by ParseFilterJSON.
+//
+// Supported operators: eq, neq, gt, gte, lt, lte, like and ilike (with "*"
+// as the wildcard), in with a parenthesized list, and is with null,
+// not_null, true, false or unknown. Any operator can be negated with a
+// "not." prefix. Values are passed on as strings and coerced by the column
+// types. Embedded resources (a dotted column) are not supported.
+func ParsePostgRESTFilter(column, expr string) (map[string]interface{}, error) {
+	return parsePostgRESTCondition(column, expr, false)
+}
+
+// parsePostgRESTCondition parses a filter. Inside logical filters, quoted
+// is set: scalar values may then be double-quoted.
+func parsePostgRESTCondition(column, expr string, quoted bool) (map[string]interface{}, error) {
+	if err := ValidateIdentifier(column); err != nil {
+		return nil, fmt.Errorf("invalid filter column: %w", err)
+	}
+	negate := false
+	if rest, ok := strings.CutPrefix(expr, "not."); ok {
+		negate, expr = true, rest
+	}
+	op, value, ok := strings.Cut(expr, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid filter %s=%s: expected operator.value", column, expr)
+	}
+
+	if quoted && op != "in" {
+		value = unquotePostgREST(value)
+	}
+
+	var cond map[string]interface{}
+	switch op {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+		cond = map[string]interface{}{postgrestOperators[op]: value}
+	case "like", "ilike":
+		cond = map[string]interface{}{postgrestOperators[op]: strings.ReplaceAll(value, "*", "%")}
+	case "in":
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return nil, fmt.Errorf("invalid filter %s=in.%s: expected a parenthesized list", column, value)
+		}
+		var list []interface{}
+		for _, item := range splitPostgREST(value[1 : len(value)-1]) {
+			list = append(list, unquotePostgREST(strings.TrimSpace(item)))
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid filter %s=in.(): the list is empty", column)
+		}
+		cond = map[string]interface{}{"in": list}
+	case "is":
+		switch strings.ToLower(value) {
+		case "null", "unknown":
+			cond = map[string]interface{}{"is_null": true}
+		case "not_null":
+			cond = map[string]interface{}{"is_null": false}
+		case "true":
+			cond = map[string]interface{}{"eq": true}
+		case "false":
+			cond = map[string]interface{}{"eq": false}
+		default:
+			return nil, fmt.Errorf("invalid filter %s=is.%s: expected null, not_null, true, false or unknown", column, value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported filter operator %q on %s", op, column)
+	}
+
+	node := map[string]interface{}{column: cond}
+	if negate {
+		node = map[string]interface{}{"not": node}
+	}
+	return node, nil
+}
+
+// ParsePostgRESTLogic translates a logical filter such as
+// ?or=(age.lt.18,and(status.eq.paid,total.gt.100)) into a filter tree. op
+// is the parameter name: and, or, not.and or not.or. Conditions inside the
+// parentheses are column.operator.value, or nested and(...), or(...),
+// not.and(...) and not.or(...) groups. Values containing commas or
+// parentheses are double-quoted.
+func ParsePostgRESTLogic(op, expr string) (map[string]interface{}, error) {
+	negate := false
+	if rest, ok := strings.CutPrefix(op, "not."); ok {
+		negate, op = true, rest
+	}
+	if op != "and" && op != "or" {
+		return nil, fmt.Errorf("unknown logical operator %q", op)
+	}
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("invalid %s filter %s: expected a parenthesized list", op, expr)
+	}
+
+	var children []interface{}
+	for _, item := range splitPostgREST(expr[1 : len(expr)-1]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var child map[string]interface{}
+		var err error
+		if name, group, ok := strings.Cut(item, "("); ok && (name == "and" || name == "or" || name == "not.and" || name == "not.or") {
+			child, err = ParsePostgRESTLogic(name, "("+group)
+		} else {
+			column, cond, found := strings.Cut(item, ".")
+			if !found {
+				return nil, fmt.Errorf("invalid %s condition %q: expected column.operator.value", op, item)
+			}
+			child, err = parsePostgRESTCondition(column, cond, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("%s filter has no conditions", op)
+	}
+
+	node := map[string]interface{}{op: children}
+	if negate {
+		node = map[string]interface{}{"not": node}
+	}
+	return node, nil
+}
+
+// ParsePostgRESTOrder parses a PostgREST order value such as
+// "created_at.desc,name" into validated order clauses. The nullsfirst and
+// nullslast modifiers are rejected: where NULLs sort differs by database.
+func ParsePostgRESTOrder(order string) ([]OrderClause, error) {
+	var clauses []OrderClause
+	for _, item := range strings.Split(order, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ".")
+		if err := ValidateIdentifier(parts[0]); err != nil {
+			return nil, fmt.Errorf("invalid order column: %w", err)
+		}
+		clause := OrderClause{Column: parts[0], Direction: "ASC"}
+		for _, mod := range parts[1:] {
+			switch mod {
+			case "asc":
+				clause.Direction = "ASC"
+			case "desc":
+				clause.Direction = "DESC"
+			case "nullsfirst", "nullslast":
+				return nil, fmt.Errorf("order modifier %s is not supported", mod)
+			default:
+				return nil, fmt.Errorf("invalid order item %q: expected column[.asc|.desc]", item)
+			}
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+// ParsePostgRESTColumns parses a select or columns value such as "id,name"
+// or `"id","name"` into validated column names. "*" returns nil. Renames
+// (alias:column), casts (column::type), JSON paths and embedded resources
+// (table(columns)) are rejected.
+func ParsePostgRESTColumns(value string) ([]string, error) {
+	var columns []string
+	for _, item := range splitPostgREST(value) {
+		item = unquotePostgREST(strings.TrimSpace(item))
+		switch {
+		case item == "":
+			continue
+		case item == "*":
+			return nil, nil
+		case strings.ContainsAny(item, "()"):
+			return nil, fmt.Errorf("embedded resources are not supported: %s", item)
+		case strings.Contains(item, ":"):
+			return nil, fmt.Errorf("renamed and cast columns are not supported: %s", item)
+		case strings.Contains(item, "->"):
+			return nil, fmt.Errorf("JSON paths are not supported: %s", item)
+		}
+		if err := ValidateIdentifier(item); err != nil {
+			return nil, fmt.Errorf("invalid column: %w", err)
+		}
+		columns = append(columns, item)
+	}
+	return columns, nil
+}
+
+// splitPostgREST splits s at commas outside of parentheses and
+// double-quoted values.
+func splitPostgREST(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquotePostgREST removes the double quotes around a value, unescaping
+// \" and \\ inside them.
+func unquotePostgREST(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestParsePostgRESTFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		param      string // column, or the logical operator for and/or
+		value      string
+		wantSQL    string
+		wantParams []interface{}
+		wantErr    bool
+	}{
+		{"comparison", "age", "gte.21", "age >= $1", []interface{}{"21"}, false},
+		{"not equal", "status", "neq.paid", "status != $1", []interface{}{"paid"}, false},
+		{"like wildcard", "name", "like.*son", "name LIKE $1", []interface{}{"%son"}, false},
+		{"ilike", "name", "ilike.ann*", "LOWER(name) LIKE LOWER($1)", []interface{}{"ann%"}, false},
+		{"in list with quotes", "city", `in.(Paris,"Rome, Italy")`, "city IN ($1, $2)", []interface{}{"Paris", "Rome, Italy"}, false},
+		{"is null", "deleted_at", "is.null", "deleted_at IS NULL", nil, false},
+		{"is true", "active", "is.true", "active = $1", []interface{}{true}, false},
+		{"negated", "id", "not.in.(1,2)", "NOT (id IN ($1, $2))", []interface{}{"1", "2"}, false},
+		{
+			"or with nested and",
+			"or",
+			`(age.lt.18,and(status.eq."a,b",vip.is.true))`,
+			"(age < $1) OR ((status = $2) AND (vip = $3))",
+			[]interface{}{"18", "a,b", true},
+			false,
+		},
+		{"not and", "not.and", "(a.eq.1,b.eq.2)", "NOT ((a = $1) AND (b = $2))", []interface{}{"1", "2"}, false},
+		{"no operator", "age", "21", "", nil, true},
+		{"unsupported operator", "tags", "cs.{a}", "", nil, true},
+		{"bad is value", "a", "is.maybe", "", nil, true},
+		{"embedded column", "orders.total", "gt.1", "", nil, true},
+		{"injection attempt", "name; DROP TABLE users", "eq.1", "", nil, true},
+		{"empty or", "or", "()", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tree map[string]interface{}
+			var err error
+			if tt.param == "or" || tt.param == "and" || tt.param == "not.and" || tt.param == "not.or" {
+				tree, err = ParsePostgRESTLogic(tt.param, tt.value)
+			} else {
+				tree, err = ParsePostgRESTFilter(tt.param, tt.value)
+			}
+			var parsed *ParsedFilter
+			if err == nil {
+				data, _ := json.Marshal(tree)
+				parsed, err = ParseFilterJSON(data, DollarPlaceholder, 1)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed.SQL != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", parsed.SQL, tt.wantSQL)
+			}
+			if fmt.Sprint(parsed.Params) != fmt.Sprint(tt.wantParams) {
+				t.Errorf("params = %v, want %v", parsed.Params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestParsePostgRESTOrderAndColumns(t *testing.T) {
+	clauses, err := ParsePostgRESTOrder("created_at.desc,name,id.asc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(clauses) != "[created_at DESC name ASC id ASC]" {
+		t.Errorf("clauses = %v", clauses)
+	}
+	for _, bad := range []string{"name.sideways", "name.desc.nullslast", "na-me"} {
+		if _, err := ParsePostgRESTOrder(bad); err == nil {
+			t.Errorf("ParsePostgRESTOrder(%q): expected error", bad)
+		}
+	}
+
+	cols, err := ParsePostgRESTColumns(`id, "name"`)
+	if err != nil || fmt.Sprint(cols) != "[id name]" {
+		t.Errorf("columns = %v (%v)", cols, err)
+	}
+	if cols, err := ParsePostgRESTColumns("*"); err != nil || cols != nil {
+		t.Errorf("* = %v (%v)", cols, err)
+	}
+	for _, bad := range []string{"id,orders(total)", "full_name:name", "id::text", "data->a"} {
+		if _, err := ParsePostgRESTColumns(bad); err == nil {
+			t.Errorf("ParsePostgRESTColumns(%q): expected error", bad)
+		}
+	}
+}
@@ -21,6 +21,12 @@ type Principal struct {
 	AdminID int64
 	RoleID  int64
 	IsAdmin bool
+
+	KeyID    int64                  // API key ID
+	Label    string                 // API key label
+	Metadata map[string]string      // API key metadata
+	Email    string                 // admin email
+	Claims   map[string]interface{} // JWT claims
 }
 
 // Authenticate returns an HTTP middleware that validates the request's
@@ -45,8 +51,11 @@ func Authenticate(authSvc *service.AuthService) func(http.Handler) http.Handler
 					return
 				}
 				principal = &Principal{
-					Type:   "api_key",
-					RoleID: p.RoleID,
+					Type:     "api_key",
+					RoleID:   p.RoleID,
+					KeyID:    p.KeyID,
+					Label:    p.Label,
+					Metadata: p.Metadata,
 				}
 			}
 
@@ -64,6 +73,8 @@ func Authenticate(authSvc *service.AuthService) func(http.Handler) http.Handler
 						Type:    "admin",
 						AdminID: p.AdminID,
 						IsAdmin: true,
+						Email:   p.Email,
+						Claims:  p.Claims,
 					}
 				}
 			}
@@ -80,6 +91,18 @@ func Authenticate(authSvc *service.AuthService) func(http.Handler) http.Handler
 	}
 }
 
+// PostgRESTAPIKey lets PostgREST clients such as supabase-js authenticate
+// the way they do against Supabase, with the API key in the apikey header.
+// It must be used before Authenticate in the middleware chain.
+func PostgRESTAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("apikey"); key != "" && r.Header.Get("X-API-Key") == "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // RequireAdmin returns an HTTP middleware that enforces admin-level access.
 // It must be used after Authenticate in the middleware chain.
 func RequireAdmin() func(http.Handler) http.Handler {
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DreamFactory adapts the /api/v2 routes to DreamFactory clients. It must be
+// used before Authenticate in the middleware chain.
+//
+// Credentials are taken from the X-DreamFactory-API-Key and
+// X-DreamFactory-Session-Token headers, or the api_key and session_token
+// query parameters. A session token takes precedence over an API key, as a
+// DreamFactory user session overrides the role of the app key sent with it.
+//
+// Error responses are rewritten into DreamFactory's envelope, which repeats
+// the status as status_code and always carries context:
+//
+//	{"error": {"code": 404, "status_code": 404, "message": "...", "context": null}}
+func DreamFactory(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if key := r.Header.Get("X-DreamFactory-API-Key"); key != "" {
+			r.Header.Set("X-API-Key", key)
+		} else if key := q.Get("api_key"); key != "" && r.Header.Get("X-API-Key") == "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		session := r.Header.Get("X-DreamFactory-Session-Token")
+		if session == "" {
+			session = q.Get("session_token")
+		}
+		if session != "" {
+			r.Header.Set("Authorization", "Bearer "+session)
+			r.Header.Del("X-API-Key")
+		}
+
+		dw := &dreamFactoryWriter{ResponseWriter: w}
+		next.ServeHTTP(dw, r)
+		dw.finish()
+	})
+}
+
+// dreamFactoryWriter holds back JSON error responses so that finish can
+// rewrite them. Other responses pass straight through.
+type dreamFactoryWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	held        *bytes.Buffer // the body of an error response
+}
+
+func (w *dreamFactoryWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if code >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.held = &bytes.Buffer{}
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *dreamFactoryWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.held != nil {
+		return w.held.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush supports streamed responses, which are never errors.
+func (w *dreamFactoryWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.held == nil {
+		f.Flush()
+	}
+}
+
+// Unwrap supports http.ResponseController.
+func (w *dreamFactoryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes a held error response in DreamFactory's envelope. Bodies
+// that are not Faucet errors are written unchanged.
+func (w *dreamFactoryWriter) finish() {
+	if w.held == nil {
+		return
+	}
+	var body struct {
+		Error *struct {
+			Message string                 `json:"message"`
+			Context map[string]interface{} `json:"context"`
+		} `json:"error"`
+	}
+	out := w.held.Bytes()
+	if json.Unmarshal(out, &body) == nil && body.Error != nil {
+		out, _ = json.Marshal(map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":        w.status,
+				"status_code": w.status,
+				"message":     body.Error.Message,
+				"context":     body.Error.Context,
+			},
+		})
+		out = append(out, '\n')
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(out)
+}
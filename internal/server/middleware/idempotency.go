@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header naming an idempotent request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses replayed from
+	// a stored earlier response.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is how long a stored response is replayed.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// idempotencyLease is how long a reservation holds a key while its
+	// request runs. A reservation left behind by a crashed server expires
+	// after it rather than after the full TTL.
+	idempotencyLease = 5 * time.Minute
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize caps the request bodies buffered to fingerprint
+	// a request.
+	maxIdempotentBodySize = 10 << 20
+	// maxIdempotentResponseSize caps the response bodies buffered for
+	// replay. A larger response is not stored.
+	maxIdempotentResponseSize = 10 << 20
+)
+
+// IdempotencyStore persists the responses of idempotent requests. It is
+// implemented by config.Store; any shared cache with the same semantics can
+// stand in for it.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims a key for a request about to run, or
+	// returns the unexpired record already holding it.
+	ReserveIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of a reserved request and
+	// its new expiry, provided rec.Token still holds the reservation.
+	CompleteIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error
+	// ReleaseIdempotencyKey drops the reservation holding token so the key
+	// can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, scope, key, token string) error
+}
+
+// Idempotency returns an HTTP middleware that makes POST, PUT, PATCH and
+// DELETE requests carrying an Idempotency-Key header safe to retry. The
+// first request with a key runs and its response is stored for ttl; a retry
+// with the same key and an identical request (method, path, query and body)
+// gets the stored response back without running again. Reusing a key for a
+// different request returns 422, and a retry that arrives while the first
+// request is still running returns 409.
+//
+// Keys are scoped to the authenticated principal, so it must run after
+// Authenticate. Server errors (5xx), responses larger than 10 MiB and
+// requests whose handler panics are not stored, leaving the key free for a
+// retry.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// A dry run changes nothing, so it neither claims nor replays a key.
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !isMutatingMethod(r.Method) || isDryRun(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeIdempotencyError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+				r.Body.Close()
+				if err != nil {
+					writeIdempotencyError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+					return
+				}
+				if len(body) > maxIdempotentBodySize {
+					writeIdempotencyError(w, http.StatusRequestEntityTooLarge, "Request body too large for an idempotent request")
+					return
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec := &model.IdempotencyRecord{
+				Scope:       idempotencyScope(r),
+				Key:         key,
+				Fingerprint: requestFingerprint(r, body),
+				Token:       uuid.NewString(),
+				ExpiresAt:   time.Now().UTC().Add(min(ttl, idempotencyLease)),
+			}
+			existing, err := store.ReserveIdempotencyKey(r.Context(), rec)
+			if err != nil {
+				writeIdempotencyError(w, http.StatusInternalServerError, "Failed to reserve idempotency key: "+err.Error())
+				return
+			}
+			if existing != nil {
+				switch {
+				case existing.Fingerprint != rec.Fingerprint:
+					writeIdempotencyError(w, http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+				case existing.Status == 0:
+					writeIdempotencyError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+				default:
+					replayResponse(w, existing)
+				}
+				return
+			}
+
+			// Store the outcome even if the client has gone away: a retry
+			// must not run the request a second time. The reservation is
+			// released unless a response is stored, including when the
+			// handler panics. Both only touch this request's reservation:
+			// once its lease has expired the key may belong to another.
+			ctx := context.WithoutCancel(r.Context())
+			stored := false
+			defer func() {
+				if !stored {
+					_ = store.ReleaseIdempotencyKey(ctx, rec.Scope, rec.Key, rec.Token)
+				}
+			}()
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK, limit: maxIdempotentResponseSize}
+			next.ServeHTTP(rw, r)
+			if rw.status >= 500 || rw.overflow {
+				return
+			}
+			header, _ := json.Marshal(w.Header())
+			rec.Status = rw.status
+			rec.HeaderJSON = string(header)
+			rec.Body = rw.body.Bytes()
+			rec.ExpiresAt = time.Now().UTC().Add(ttl)
+			stored = store.CompleteIdempotencyKey(ctx, rec) == nil
+		})
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isDryRun matches the handlers' reading of ?dry_run.
+func isDryRun(r *http.Request) bool {
+	v := r.URL.Query().Get("dry_run")
+	return v == "true" || v == "1"
+}
+
+// idempotencyScope names the caller a key belongs to, so that clients
+// cannot replay each other's responses. API keys are scoped individually,
+// not by role: keys sharing a role are still different clients. A key
+// principal without an ID is scoped by a hash of the key itself.
+func idempotencyScope(r *http.Request) string {
+	p := GetPrincipal(r.Context())
+	switch {
+	case p == nil:
+		return "anonymous"
+	case p.IsAdmin:
+		return "admin:" + strconv.FormatInt(p.AdminID, 10)
+	case p.KeyID != 0:
+		return "key:" + strconv.FormatInt(p.KeyID, 10)
+	case r.Header.Get("X-API-Key") != "":
+		sum := sha256.Sum256([]byte(r.Header.Get("X-API-Key")))
+		return "key:" + hex.EncodeToString(sum[:])
+	default:
+		return "role:" + strconv.FormatInt(p.RoleID, 10)
+	}
+}
+
+// requestFingerprint digests everything that identifies a request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"\n"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayResponse writes a stored response.
+func replayResponse(w http.ResponseWriter, rec *model.IdempotencyRecord) {
+	var header http.Header
+	if err := json.Unmarshal([]byte(rec.HeaderJSON), &header); err == nil {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// recordingWriter passes a response through while keeping a copy of its
+// status and body. With a limit, a body that outgrows it is dropped rather
+// than held in memory.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int  // maximum body bytes kept; 0 keeps all
+	overflow    bool // the body exceeded limit and was dropped
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.overflow {
+		if w.limit > 0 && w.body.Len()+len(b) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap supports http.ResponseController.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func writeIdempotencyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message},
+	})
+}
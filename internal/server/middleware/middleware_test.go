@@ -1,10 +1,18 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/model"
 )
 
 // ---------------------------------------------------------------------------
@@ -157,3 +165,210 @@ func TestGetPrincipalWithoutValue(t *testing.T) {
 		t.Error("expected nil principal from bare context")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Idempotency middleware tests
+// ---------------------------------------------------------------------------
+
+type idempotencyTestEnv struct {
+	handler http.Handler
+	calls   int
+	panics  bool // make the handler panic after counting the call
+}
+
+func newIdempotencyTestEnv(t *testing.T, ttl time.Duration, status int) *idempotencyTestEnv {
+	t.Helper()
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	env := &idempotencyTestEnv{}
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.calls++
+		if env.panics {
+			panic("handler failed")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, env.calls)
+	})
+	env.handler = Idempotency(store, ttl)(inner)
+	return env
+}
+
+func (e *idempotencyTestEnv) do(method, path, key, body string, principal *Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if principal != nil {
+		req = req.WithContext(context.WithValue(req.Context(), AuthPrincipalKey, principal))
+	}
+	rr := httptest.NewRecorder()
+	e.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Hour, http.StatusCreated)
+
+	first := env.do("POST", "/api/v1/db/_table/orders", "k1", `{"sku":"A"}`, nil)
+	retry := env.do("POST", "/api/v1/db/_table/orders", "k1", `{"sku":"A"}`, nil)
+
+	if env.calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", env.calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("expected replay of %d %q, got %d %q", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected replay headers: %v", retry.Header())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("first response must not be marked as replayed")
+	}
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Hour, http.StatusCreated)
+
+	env.do("POST", "/api/v1/db/_table/orders", "k1", `{"sku":"A"}`, nil)
+	if rr := env.do("POST", "/api/v1/db/_table/orders", "k1", `{"sku":"B"}`, nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body: expected 422, got %d", rr.Code)
+	}
+	if rr := env.do("PATCH", "/api/v1/db/_table/orders", "k1", `{"sku":"A"}`, nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different method: expected 422, got %d", rr.Code)
+	}
+	if env.calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", env.calls)
+	}
+}
+
+func TestIdempotencyScopesAndSkips(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Hour, http.StatusCreated)
+	alice := &Principal{Type: "api_key", RoleID: 1, KeyID: 1}
+	bob := &Principal{Type: "api_key", RoleID: 1, KeyID: 2}
+
+	env.do("POST", "/x", "k1", `{}`, alice)
+	env.do("POST", "/x", "k1", `{}`, bob) // same key and role, other API key
+	env.do("POST", "/x", "", `{}`, alice) // no key
+	env.do("GET", "/x", "k2", ``, alice)  // not a mutating method
+	env.do("GET", "/x", "k2", ``, alice)
+	if env.calls != 5 {
+		t.Errorf("expected 5 handler runs, got %d", env.calls)
+	}
+}
+
+func TestIdempotencyReleasesOnPanic(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Hour, http.StatusCreated)
+	env.panics = true
+	func() {
+		defer func() { recover() }()
+		env.do("POST", "/x", "k1", `{}`, nil)
+	}()
+
+	env.panics = false
+	if rr := env.do("POST", "/x", "k1", `{}`, nil); rr.Code != http.StatusCreated {
+		t.Errorf("retry after panic: expected 201, got %d", rr.Code)
+	}
+	if env.calls != 2 {
+		t.Errorf("retry after panic: expected 2 runs, got %d", env.calls)
+	}
+}
+
+func TestIdempotencyServerErrorsAndExpiry(t *testing.T) {
+	failing := newIdempotencyTestEnv(t, time.Hour, http.StatusInternalServerError)
+	failing.do("POST", "/x", "k1", `{}`, nil)
+	failing.do("POST", "/x", "k1", `{}`, nil)
+	if failing.calls != 2 {
+		t.Errorf("5xx responses must not be stored: expected 2 runs, got %d", failing.calls)
+	}
+
+	expired := newIdempotencyTestEnv(t, -time.Second, http.StatusCreated)
+	expired.do("POST", "/x", "k1", `{}`, nil)
+	if rr := expired.do("POST", "/x", "k1", `{"other":1}`, nil); rr.Code != http.StatusCreated {
+		t.Errorf("expired key: expected 201, got %d", rr.Code)
+	}
+	if expired.calls != 2 {
+		t.Errorf("expired key: expected 2 runs, got %d", expired.calls)
+	}
+
+	if rr := expired.do("POST", "/x", strings.Repeat("k", 256), `{}`, nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("long key: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestIdempotencySkipsLargeResponses(t *testing.T) {
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	defer store.Close()
+	calls := 0
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write(bytes.Repeat([]byte("x"), maxIdempotentResponseSize))
+		w.Write([]byte("y"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/x", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.Len() != maxIdempotentResponseSize+1 {
+			t.Fatalf("expected the full response, got %d with %d bytes", rr.Code, rr.Body.Len())
+		}
+	}
+	if calls != 2 {
+		t.Errorf("oversized responses must not be stored: expected 2 runs, got %d", calls)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Webhooks middleware tests
+// ---------------------------------------------------------------------------
+
+func TestWebhookEvents(t *testing.T) {
+	// Continue mode: failed items are skipped.
+	events := webhookEvents("POST", "/api/v1/{serviceName}/_table/{tableName}", "orders",
+		[]byte(`{"resource":[{"id":1},{"error":"duplicate"},{"id":12345678901234567}],"meta":{"count":3}}`))
+	if len(events) != 1 || events[0].Op != "insert" || events[0].Verb != model.VerbPost || events[0].Count != 2 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if id := events[0].Records[1]["id"]; id != json.Number("12345678901234567") {
+		t.Errorf("expected large keys to survive, got %v", id)
+	}
+
+	// Updates by filter report the rows affected.
+	events = webhookEvents("PATCH", "/api/v1/{serviceName}/_table/{tableName}", "orders",
+		[]byte(`{"resource":[{"rows_affected":4}],"meta":{"count":1}}`))
+	if len(events) != 1 || events[0].Op != "update" || events[0].Count != 4 || len(events[0].Records) != 0 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// Deletes by filter have only a count, and writes of no rows are dropped.
+	events = webhookEvents("DELETE", "/api/v1/{serviceName}/_table/{tableName}", "orders", []byte(`{"meta":{"count":2}}`))
+	if len(events) != 1 || events[0].Op != "delete" || events[0].Count != 2 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if events := webhookEvents("DELETE", "/api/v1/{serviceName}/_table/{tableName}", "orders", []byte(`{"meta":{"count":0}}`)); len(events) != 0 {
+		t.Errorf("expected no events, got %+v", events)
+	}
+
+	// Batches report each write operation, not procedure calls.
+	events = webhookEvents("POST", "/api/v1/{serviceName}/_batch", "", []byte(`{"resource":[
+		{"op":"upsert","table":"users","count":1,"resource":[{"id":1}]},
+		{"op":"procedure","procedure":"recalc","count":1},
+		{"op":"delete","table":"orders","count":3}]}`))
+	if len(events) != 2 || events[0].Table != "users" || events[0].Op != "insert" ||
+		events[1].Table != "orders" || events[1].Verb != model.VerbDelete || events[1].Count != 3 {
+		t.Errorf("unexpected batch events: %+v", events)
+	}
+
+	if events := webhookEvents("POST", "/api/v1/{serviceName}/_proc/{procName}", "", []byte(`{}`)); events != nil {
+		t.Errorf("expected other routes to be ignored, got %+v", events)
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// WebhookEmitter is notified of successful writes. It is satisfied by
+// *webhook.Dispatcher; the interface keeps this package free of the
+// delivery machinery.
+type WebhookEmitter interface {
+	Emit(ctx context.Context, ev model.WebhookEvent)
+}
+
+// Webhooks reports the writes made through the table, import and batch
+// routes to emitter, after they have succeeded. The written rows are taken
+// from the response, so webhooks see them as the client does. Dry runs and
+// failed requests are not reported. Mount it after Idempotency so that
+// replayed responses are not reported twice.
+func Webhooks(emitter WebhookEmitter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isMutatingMethod(r.Method) || isDryRun(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+			if rw.status < 200 || rw.status > 299 {
+				return
+			}
+
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil {
+				return
+			}
+			service := rctx.URLParam("serviceName")
+			pattern := strings.TrimSuffix(rctx.RoutePattern(), "/")
+			for _, ev := range webhookEvents(r.Method, pattern, rctx.URLParam("tableName"), rw.body.Bytes()) {
+				ev.Service = service
+				emitter.Emit(r.Context(), ev)
+			}
+		})
+	}
+}
+
+// webhookEvents reads the events of a write from its route and response.
+func webhookEvents(method, pattern, table string, body []byte) []model.WebhookEvent {
+	var verb int
+	var op string
+	switch method {
+	case http.MethodPost:
+		verb, op = model.VerbPost, "insert"
+	case http.MethodPut:
+		verb, op = model.VerbPut, "update"
+	case http.MethodPatch:
+		verb, op = model.VerbPatch, "update"
+	case http.MethodDelete:
+		verb, op = model.VerbDelete, "delete"
+	}
+
+	switch {
+	case strings.HasSuffix(pattern, "/_table/{tableName}"):
+		var resp struct {
+			Resource []json.RawMessage `json:"resource"`
+			Meta     struct {
+				Count int `json:"count"`
+			} `json:"meta"`
+		}
+		if decodeResponse(body, &resp) != nil {
+			return nil
+		}
+		ev := model.WebhookEvent{Table: table, Verb: verb, Op: op, Count: resp.Meta.Count}
+		if resp.Resource != nil {
+			ev.Records, ev.Count = writtenRecords(resp.Resource)
+		}
+		if ev.Count == 0 {
+			return nil
+		}
+		return []model.WebhookEvent{ev}
+
+	case strings.HasSuffix(pattern, "/_table/{tableName}/{id}"):
+		var record map[string]interface{}
+		if decodeResponse(body, &record) != nil || record == nil {
+			return nil
+		}
+		return []model.WebhookEvent{{Table: table, Verb: verb, Op: op,
+			Records: []map[string]interface{}{record}, Count: 1}}
+
+	case strings.HasSuffix(pattern, "/_table/{tableName}/_import"):
+		var resp model.ImportResponse
+		if decodeResponse(body, &resp) != nil || resp.Meta.Inserted == 0 {
+			return nil
+		}
+		return []model.WebhookEvent{{Table: table, Verb: model.VerbPost, Op: "insert", Count: int(resp.Meta.Inserted)}}
+
+	case strings.HasSuffix(pattern, "/_batch"):
+		var resp struct {
+			Resource []model.BatchOperationResult `json:"resource"`
+		}
+		if decodeResponse(body, &resp) != nil {
+			return nil
+		}
+		var events []model.WebhookEvent
+		for _, res := range resp.Resource {
+			ev := model.WebhookEvent{Table: res.Table, Records: res.Resource, Count: res.Count}
+			switch res.Op {
+			case "insert", "upsert":
+				ev.Verb, ev.Op = model.VerbPost, "insert"
+			case "update":
+				ev.Verb, ev.Op = model.VerbPatch, "update"
+			case "delete":
+				ev.Verb, ev.Op = model.VerbDelete, "delete"
+			default:
+				continue
+			}
+			if ev.Count > 0 {
+				events = append(events, ev)
+			}
+		}
+		return events
+	}
+	return nil
+}
+
+// writtenRecords returns the rows of a write response and how many rows
+// were written. Items reporting a failed record ({"error": ...}, in continue
+// mode) are skipped, as are the {"rows_affected": n} summaries of writes by
+// filter, which add to the count without carrying rows.
+func writtenRecords(resource []json.RawMessage) ([]map[string]interface{}, int) {
+	var records []map[string]interface{}
+	count := 0
+	for _, raw := range resource {
+		var rec map[string]interface{}
+		if decodeResponse(raw, &rec) != nil || rec == nil {
+			continue
+		}
+		if _, failed := rec["error"]; failed && len(rec) == 1 {
+			continue
+		}
+		if n, ok := rec["rows_affected"].(json.Number); ok && len(rec) == 1 {
+			affected, _ := n.Int64()
+			count += int(affected)
+			continue
+		}
+		records = append(records, rec)
+		count++
+	}
+	return records, count
+}
+
+// decodeResponse decodes a response body, keeping numbers as json.Number so
+// that large keys survive intact.
+func decodeResponse(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -16,6 +16,7 @@ import (
 	chimw "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 
+	"github.com/faucetdb/faucet/internal/changes"
 	"github.com/faucetdb/faucet/internal/config"
 	"github.com/faucetdb/faucet/internal/connector"
 	"github.com/faucetdb/faucet/internal/handler"
@@ -23,6 +24,7 @@ import (
 	"github.com/faucetdb/faucet/internal/server/middleware"
 	"github.com/faucetdb/faucet/internal/service"
 	"github.com/faucetdb/faucet/internal/ui"
+	"github.com/faucetdb/faucet/internal/webhook"
 )
 
 // Config holds the HTTP server configuration.
@@ -32,7 +34,11 @@ type Config struct {
 	ShutdownTimeout time.Duration
 	CORSOrigins     []string
 	EnableUI        bool
-	MaxBodySize     int64 // bytes
+	MaxBodySize     int64         // bytes
+	IdempotencyTTL  time.Duration // how long Idempotency-Key responses are replayed
+	DreamFactory    bool          // serve the DreamFactory-compatible API at /api/v2
+	Exports         handler.ExportConfig
+	Webhooks        webhook.Config
 }
 
 // DefaultConfig returns a Config with sensible production defaults.
@@ -44,6 +50,7 @@ func DefaultConfig() Config {
 		CORSOrigins:     []string{"*"},
 		EnableUI:        true,
 		MaxBodySize:     10 * 1024 * 1024, // 10MB
+		IdempotencyTTL:  middleware.DefaultIdempotencyTTL,
 	}
 }
 
@@ -56,6 +63,9 @@ type Server struct {
 	store      *config.Store
 	authSvc    *service.AuthService
 	httpServer *http.Server
+	exports    *handler.ExportHandler
+	changes    *changes.Hub
+	webhooks   *webhook.Dispatcher
 	logger     *slog.Logger
 }
 
@@ -84,8 +94,8 @@ func (s *Server) setupRouter() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   s.cfg.CORSOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Requested-With"},
-		ExposedHeaders:   []string{"X-Total-Count", "X-Request-ID", "Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Requested-With", "apikey", "Prefer", "Range", "X-Client-Info", "X-DreamFactory-API-Key", "X-DreamFactory-Session-Token"},
+		ExposedHeaders:   []string{"X-Total-Count", "X-Request-ID", "Link", "Content-Range"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -99,13 +109,56 @@ func (s *Server) setupRouter() {
 	r.Get("/openapi.json", handler.NewOpenAPIHandler(s.registry, s.store).ServeCombinedSpec)
 
 	// --- MCP Streamable HTTP endpoint (remote AI agent access) ---
+	// Change streams (REST and MCP subscriptions) share one hub, so a table
+	// is polled once however many clients follow it.
+	s.changes = changes.NewHub(s.registry, changes.Config{Logger: s.logger})
 	mcpSrv := fmcp.NewMCPServer(s.registry, s.store, s.logger)
+	mcpSrv.SetChangeHub(s.changes)
 	mcpHandler := mcpSrv.HTTPHandler()
+
+	// Saved queries are managed under /system and executed per service; MCP
+	// tools are regenerated whenever their definitions change.
+	queryHandler := handler.NewNamedQueryHandler(s.registry, s.store)
+	queryHandler.OnChange(func() { mcpSrv.SyncQueryTools(context.Background()) })
+
+	// Background export jobs run outside the request, so they are not bound
+	// by the write timeout.
+	exportCfg := s.cfg.Exports
+	if exportCfg.Logger == nil {
+		exportCfg.Logger = s.logger
+	}
+	exportHandler, err := handler.NewExportHandler(s.registry, s.store, exportCfg)
+	if err != nil {
+		s.logger.Error("background exports disabled", "error", err)
+	}
+	s.exports = exportHandler
+
+	// Webhooks are delivered from a durable queue by background workers;
+	// REST writes are reported by middleware and MCP writes by the tools.
+	webhookCfg := s.cfg.Webhooks
+	if webhookCfg.Logger == nil {
+		webhookCfg.Logger = s.logger
+	}
+	dispatcher, err := webhook.NewDispatcher(s.registry, s.store, webhookCfg)
+	if err != nil {
+		s.logger.Error("webhook deliveries disabled", "error", err)
+	}
+	s.webhooks = dispatcher
+	writeHooks := func(next http.Handler) http.Handler { return next }
+	if dispatcher != nil {
+		mcpSrv.SetWebhooks(dispatcher)
+		writeHooks = middleware.Webhooks(dispatcher)
+	}
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.Authenticate(s.authSvc))
 		r.Handle("/mcp", mcpHandler)
 	})
 
+	idempotencyTTL := s.cfg.IdempotencyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = middleware.DefaultIdempotencyTTL
+	}
+
 	// --- API routes ---
 	r.Route("/api/v1", func(r chi.Router) {
 
@@ -166,17 +219,58 @@ func (s *Server) setupRouter() {
 					r.Delete("/{serviceName}/{tableName}", contractHandler.UnlockTable)
 					r.Post("/{serviceName}/{tableName}/promote", contractHandler.PromoteTable)
 				})
+
+				// Saved (named, parameterized) queries
+				r.Route("/query", func(r chi.Router) {
+					r.Get("/{serviceName}", queryHandler.ListQueries)
+					r.Post("/{serviceName}", queryHandler.CreateQuery)
+					r.Get("/{serviceName}/{queryName}", queryHandler.GetQuery)
+					r.Put("/{serviceName}/{queryName}", queryHandler.UpdateQuery)
+					r.Delete("/{serviceName}/{queryName}", queryHandler.DeleteQuery)
+				})
+
+				// Webhooks notified of writes, and their delivery log
+				webhookHandler := handler.NewWebhookHandler(s.registry, s.store, dispatcher)
+				r.Route("/webhook", func(r chi.Router) {
+					r.Get("/", webhookHandler.ListWebhooks)
+					r.Post("/", webhookHandler.CreateWebhook)
+					r.Get("/{webhookId}", webhookHandler.GetWebhook)
+					r.Put("/{webhookId}", webhookHandler.UpdateWebhook)
+					r.Delete("/{webhookId}", webhookHandler.DeleteWebhook)
+					r.Get("/{webhookId}/deliveries", webhookHandler.ListDeliveries)
+					r.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver)
+				})
 			})
 		})
 
+		// PostgREST-compatible table routes, for services that enable them.
+		// PostgREST clients send their key in an apikey header.
+		postgrestHandler := handler.NewPostgRESTHandler(s.registry, s.store)
+		if dispatcher != nil {
+			postgrestHandler.SetWebhooks(dispatcher)
+		}
+		r.Route("/{serviceName}/rest/v1", func(r chi.Router) {
+			r.Use(middleware.PostgRESTAPIKey)
+			r.Use(middleware.Authenticate(s.authSvc))
+
+			r.Get("/{tableName}", postgrestHandler.Read)
+			r.Head("/{tableName}", postgrestHandler.Read)
+			r.Post("/{tableName}", postgrestHandler.Insert)
+			r.Patch("/{tableName}", postgrestHandler.Update)
+			r.Delete("/{tableName}", postgrestHandler.Delete)
+		})
+
 		// Dynamic database service APIs
 		r.Route("/{serviceName}", func(r chi.Router) {
 			r.Use(middleware.Authenticate(s.authSvc))
 
 			tableHandler := handler.NewTableHandler(s.registry, s.store)
 			schemaHandler := handler.NewSchemaHandler(s.registry, s.store)
-			procHandler := handler.NewProcHandler(s.registry)
+			procHandler := handler.NewProcHandler(s.registry, s.store)
 			openAPIHandler := handler.NewOpenAPIHandler(s.registry, s.store)
+			graphQLHandler := handler.NewGraphQLHandler(s.registry, s.store)
+			odataHandler := handler.NewODataHandler(s.registry, s.store)
+			changeHandler := handler.NewChangeHandler(s.registry, s.store, s.changes)
 
 			// Schema introspection and DDL
 			r.Get("/_schema", schemaHandler.ListTables)
@@ -185,23 +279,118 @@ func (s *Server) setupRouter() {
 			r.Put("/_schema/{tableName}", schemaHandler.AlterTable)
 			r.Delete("/_schema/{tableName}", schemaHandler.DropTable)
 
-			// Table CRUD
-			r.Get("/_table", tableHandler.ListTableNames)
-			r.Get("/_table/{tableName}", tableHandler.QueryRecords)
-			r.Post("/_table/{tableName}", tableHandler.CreateRecords)
-			r.Put("/_table/{tableName}", tableHandler.ReplaceRecords)
-			r.Patch("/_table/{tableName}", tableHandler.UpdateRecords)
-			r.Delete("/_table/{tableName}", tableHandler.DeleteRecords)
+			// Bulk import streams its body, so it is not replayable.
+			r.With(writeHooks).Post("/_table/{tableName}/_import", tableHandler.ImportRecords)
 
-			// Stored procedures
-			r.Get("/_proc", procHandler.ListProcedures)
-			r.Post("/_proc/{procName}", procHandler.CallProcedure)
+			// Change streams are long-lived, so they are not replayable either.
+			r.Get("/_table/{tableName}/_changes", changeHandler.StreamChanges)
+			r.With(middleware.RequireAdmin()).Delete("/_table/{tableName}/_changes", changeHandler.DisableChanges)
+
+			// Structured reads change nothing, so their streamed responses
+			// are not buffered for Idempotency-Key replay.
+			r.Post("/_table/{tableName}/_query", tableHandler.QueryRecordsJSON)
+
+			// Table CRUD, batches, GraphQL and stored procedures honor Idempotency-Key.
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Idempotency(s.store, idempotencyTTL))
+				r.Use(writeHooks)
+
+				r.Get("/_table", tableHandler.ListTableNames)
+				r.Get("/_table/{tableName}", tableHandler.QueryRecords)
+				r.Post("/_table/{tableName}", tableHandler.CreateRecords)
+				r.Put("/_table/{tableName}", tableHandler.ReplaceRecords)
+				r.Patch("/_table/{tableName}", tableHandler.UpdateRecords)
+				r.Delete("/_table/{tableName}", tableHandler.DeleteRecords)
+				r.Get("/_table/{tableName}/{id}", tableHandler.GetRecord)
+				r.Put("/_table/{tableName}/{id}", tableHandler.UpdateRecord)
+				r.Patch("/_table/{tableName}/{id}", tableHandler.UpdateRecord)
+				r.Delete("/_table/{tableName}/{id}", tableHandler.DeleteRecord)
+
+				// Multi-table transactional batch
+				r.Post("/_batch", tableHandler.ExecuteBatch)
+
+				// GraphQL over the same tables
+				r.Get("/graphql", graphQLHandler.Serve)
+				r.Post("/graphql", graphQLHandler.Serve)
+
+				// Stored procedures
+				r.Get("/_proc", procHandler.ListProcedures)
+				r.Post("/_proc/{procName}", procHandler.CallProcedure)
+			})
+
+			// Saved queries
+			r.Get("/_query/{queryName}", queryHandler.RunQuery)
+			r.Post("/_query/{queryName}", queryHandler.RunQuery)
+
+			// Background exports
+			if exportHandler != nil {
+				r.With(middleware.Idempotency(s.store, idempotencyTTL)).Post("/_export", exportHandler.CreateExport)
+				r.Get("/_export", exportHandler.ListExports)
+				r.Get("/_export/{exportId}", exportHandler.GetExport)
+				r.Get("/_export/{exportId}/download", exportHandler.DownloadExport)
+				r.Delete("/_export/{exportId}", exportHandler.CancelExport)
+			}
+
+			// OData v4 (read-only) for BI tools
+			r.Route("/odata", func(r chi.Router) {
+				r.Get("/", odataHandler.ServiceDocument)
+				r.Get("/$metadata", odataHandler.Metadata)
+				r.Get("/{entitySet}", odataHandler.EntitySet)
+				r.Get("/{entitySet}/$count", odataHandler.Count)
+			})
 
 			// Per-service OpenAPI spec
 			r.Get("/_doc", openAPIHandler.ServeServiceSpec)
 		})
 	})
 
+	// --- DreamFactory-compatible API ---
+	if s.cfg.DreamFactory {
+		dfHandler := handler.NewDreamFactoryHandler(s.registry, s.store, s.authSvc)
+		r.Route("/api/v2", func(r chi.Router) {
+			r.Use(middleware.DreamFactory)
+
+			// Sessions. DreamFactory has user and admin sessions; Faucet's
+			// accounts are admins, so both log in an admin.
+			for _, path := range []string{"/user/session", "/system/admin/session"} {
+				r.Post(path, dfHandler.Login)
+				r.Delete(path, dfHandler.Logout)
+				r.With(middleware.Authenticate(s.authSvc)).Get(path, dfHandler.GetSession)
+				r.With(middleware.Authenticate(s.authSvc)).Put(path, dfHandler.RefreshSession)
+			}
+
+			r.Route("/{serviceName}", func(r chi.Router) {
+				r.Use(middleware.Authenticate(s.authSvc))
+
+				tableHandler := handler.NewTableHandler(s.registry, s.store)
+				schemaHandler := handler.NewSchemaHandler(s.registry, s.store)
+				procHandler := handler.NewProcHandler(s.registry, s.store)
+
+				r.Get("/_schema", schemaHandler.ListTables)
+				r.Get("/_schema/{tableName}", schemaHandler.GetTableSchema)
+
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.Idempotency(s.store, idempotencyTTL))
+					r.Use(writeHooks)
+
+					r.Get("/_table", tableHandler.ListTableNames)
+					r.Get("/_table/{tableName}", dfHandler.QueryRecords)
+					r.Post("/_table/{tableName}", tableHandler.CreateRecords)
+					r.Put("/_table/{tableName}", tableHandler.ReplaceRecords)
+					r.Patch("/_table/{tableName}", tableHandler.UpdateRecords)
+					r.Delete("/_table/{tableName}", tableHandler.DeleteRecords)
+					r.Get("/_table/{tableName}/{id}", dfHandler.GetRecord)
+					r.Put("/_table/{tableName}/{id}", tableHandler.UpdateRecord)
+					r.Patch("/_table/{tableName}/{id}", tableHandler.UpdateRecord)
+					r.Delete("/_table/{tableName}/{id}", tableHandler.DeleteRecord)
+
+					r.Get("/_proc", procHandler.ListProcedures)
+					r.Post("/_proc/{procName}", procHandler.CallProcedure)
+				})
+			})
+		})
+	}
+
 	// --- Embedded admin UI ---
 	if s.cfg.EnableUI {
 		// Serve the embedded SPA. The dist/ directory is produced by
@@ -307,6 +496,8 @@ func (s *Server) ListenAndServe() error {
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	// End change streams when shutdown starts, so they do not hold it up.
+	s.httpServer.RegisterOnShutdown(s.changes.Close)
 
 	// Listen for shutdown signals
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -337,7 +528,13 @@ func (s *Server) ListenAndServe() error {
 		return fmt.Errorf("server shutdown: %w", err)
 	}
 
-	// Close all database connections
+	// Stop export and webhook workers, then close all database connections
+	if s.exports != nil {
+		s.exports.Close()
+	}
+	if s.webhooks != nil {
+		s.webhooks.Close()
+	}
 	s.registry.CloseAll()
 	s.logger.Info("server stopped")
 	return nil
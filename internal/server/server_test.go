@@ -1954,3 +1954,106 @@ func TestDataAPI_FilterDelete(t *testing.T) {
 		t.Errorf("expected 2 remaining users, got %v", resp.Meta.Total)
 	}
 }
+
+func TestDataAPI_PostgREST(t *testing.T) {
+	env, rawKey := newTestEnvWithSQLite(t)
+	ctx := context.Background()
+
+	// Disabled by default.
+	rr := env.do(t, "GET", "/api/v1/testdb/rest/v1/users", nil, map[string]string{"apikey": rawKey})
+	assertStatus(t, rr, http.StatusNotFound)
+
+	svc, err := env.store.GetServiceByName(ctx, "testdb")
+	if err != nil {
+		t.Fatalf("GetServiceByName: %v", err)
+	}
+	svc.PostgREST = true
+	if err := env.store.UpdateService(ctx, svc); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	roles, err := env.store.ListRoles(ctx)
+	if err != nil || len(roles) != 1 {
+		t.Fatalf("ListRoles: %v %v", roles, err)
+	}
+	if err := env.store.SetRoleAccess(ctx, roles[0].ID, []model.RoleAccess{
+		{ServiceName: "testdb", Component: "_table/users", VerbMask: model.VerbGet},
+	}); err != nil {
+		t.Fatalf("SetRoleAccess: %v", err)
+	}
+
+	// PostgREST clients send the key in an apikey header.
+	rr = env.do(t, "GET", "/api/v1/testdb/rest/v1/users?select=name&city=eq.Chicago", nil, map[string]string{"apikey": rawKey})
+	assertStatus(t, rr, http.StatusOK)
+	var rows []map[string]interface{}
+	decodeJSON(t, rr, &rows)
+	if len(rows) != 1 || rows[0]["name"] != "Charlie" {
+		t.Errorf("expected Charlie, got %v", rows)
+	}
+
+	rr = env.do(t, "GET", "/api/v1/testdb/rest/v1/users", nil, nil)
+	assertStatus(t, rr, http.StatusUnauthorized)
+}
+
+func TestDreamFactoryAPI(t *testing.T) {
+	env, rawKey := newTestEnvWithSQLite(t)
+
+	// The /api/v2 routes are off by default.
+	rr := env.do(t, "GET", "/api/v2/testdb/_table/users", nil, map[string]string{"X-DreamFactory-API-Key": rawKey})
+	assertStatus(t, rr, http.StatusNotFound)
+
+	cfg := DefaultConfig()
+	cfg.DreamFactory = true
+	env.server = New(cfg, env.registry, env.store, env.authSvc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rr = env.do(t, "GET", "/api/v2/testdb/_table/users?include_count=true&limit=1", nil, map[string]string{"X-DreamFactory-API-Key": rawKey})
+	assertStatus(t, rr, http.StatusOK)
+	var list struct {
+		Resource []map[string]interface{} `json:"resource"`
+		Meta     struct {
+			Count int `json:"count"`
+		} `json:"meta"`
+	}
+	decodeJSON(t, rr, &list)
+	if len(list.Resource) != 1 || list.Meta.Count != 3 {
+		t.Errorf("expected 1 record and count 3, got %d and %d", len(list.Resource), list.Meta.Count)
+	}
+
+	// Sessions log in admins; the token goes in X-DreamFactory-Session-Token.
+	rr = env.do(t, "POST", "/api/v2/user/session", jsonBody(t, map[string]string{
+		"email": "admin@example.com", "password": testPassword,
+	}), nil)
+	assertStatus(t, rr, http.StatusOK)
+	var session struct {
+		SessionToken string `json:"session_token"`
+		Email        string `json:"email"`
+		IsSysAdmin   bool   `json:"is_sys_admin"`
+	}
+	decodeJSON(t, rr, &session)
+	if session.SessionToken == "" || session.Email != "admin@example.com" || !session.IsSysAdmin {
+		t.Fatalf("unexpected session: %+v", session)
+	}
+	rr = env.do(t, "GET", "/api/v2/user/session", nil, map[string]string{"X-DreamFactory-Session-Token": session.SessionToken})
+	assertStatus(t, rr, http.StatusOK)
+	rr = env.do(t, "DELETE", "/api/v2/testdb/_table/users/2", nil, map[string]string{"X-DreamFactory-Session-Token": session.SessionToken})
+	assertStatus(t, rr, http.StatusOK)
+
+	// API keys have no session, and errors use DreamFactory's envelope.
+	rr = env.do(t, "GET", "/api/v2/user/session", nil, map[string]string{"X-DreamFactory-API-Key": rawKey})
+	assertStatus(t, rr, http.StatusUnauthorized)
+	rr = env.do(t, "POST", "/api/v2/user/session", jsonBody(t, map[string]string{
+		"email": "admin@example.com", "password": "wrong",
+	}), nil)
+	assertStatus(t, rr, http.StatusUnauthorized)
+	var errResp struct {
+		Error struct {
+			Code       int         `json:"code"`
+			StatusCode int         `json:"status_code"`
+			Message    string      `json:"message"`
+			Context    interface{} `json:"context"`
+		} `json:"error"`
+	}
+	decodeJSON(t, rr, &errResp)
+	if errResp.Error.StatusCode != http.StatusUnauthorized || errResp.Error.Message != "Invalid credentials" {
+		t.Errorf("unexpected error: %+v", errResp)
+	}
+}
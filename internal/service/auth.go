@@ -19,13 +19,16 @@ var (
 )
 
 type APIKeyPrincipal struct {
-	KeyID  int64
-	RoleID int64
+	KeyID    int64
+	RoleID   int64
+	Label    string
+	Metadata map[string]string
 }
 
 type JWTPrincipal struct {
 	AdminID int64
 	Email   string
+	Claims  map[string]interface{} // every claim of the token, including custom ones
 }
 
 type AuthService struct {
@@ -61,8 +64,10 @@ func (s *AuthService) ValidateAPIKey(ctx context.Context, rawKey string) (*APIKe
 	go s.store.UpdateAPIKeyLastUsed(context.Background(), key.ID)
 
 	return &APIKeyPrincipal{
-		KeyID:  key.ID,
-		RoleID: key.RoleID,
+		KeyID:    key.ID,
+		RoleID:   key.RoleID,
+		Label:    key.Label,
+		Metadata: key.Metadata,
 	}, nil
 }
 
@@ -84,9 +89,16 @@ func (s *AuthService) ValidateJWT(ctx context.Context, tokenStr string) (*JWTPri
 		return nil, ErrInvalidCredentials
 	}
 
+	// The signature is verified above; decode again to keep custom claims.
+	all := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenStr, all); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
 	return &JWTPrincipal{
 		AdminID: claims.AdminID,
 		Email:   claims.Email,
+		Claims:  all,
 	}, nil
 }
 
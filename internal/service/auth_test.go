@@ -44,6 +44,9 @@ func TestJWTRoundTrip(t *testing.T) {
 	if principal.Email != "admin@example.com" {
 		t.Errorf("Email: got %q, want %q", principal.Email, "admin@example.com")
 	}
+	if principal.Claims["iss"] != "faucet" || principal.Claims["email"] != "admin@example.com" {
+		t.Errorf("Claims: got %v", principal.Claims)
+	}
 }
 
 func TestJWTExpired(t *testing.T) {
@@ -90,6 +93,7 @@ func TestAPIKeyValidation(t *testing.T) {
 		KeyPrefix: rawKey[:8],
 		Label:     "test",
 		RoleID:    role.ID,
+		Metadata:  map[string]string{"tenant": "acme"},
 		IsActive:  true,
 	}
 	if err := store.CreateAPIKey(ctx, key); err != nil {
@@ -104,6 +108,9 @@ func TestAPIKeyValidation(t *testing.T) {
 	if principal.RoleID != role.ID {
 		t.Errorf("RoleID: got %d, want %d", principal.RoleID, role.ID)
 	}
+	if principal.KeyID != key.ID || principal.Label != "test" || principal.Metadata["tenant"] != "acme" {
+		t.Errorf("unexpected principal %+v", principal)
+	}
 
 	// Invalid key
 	_, err = auth.ValidateAPIKey(ctx, "wrong_key")
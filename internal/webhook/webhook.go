@@ -0,0 +1,380 @@
+// Package webhook notifies admin-registered endpoints of writes. A
+// Dispatcher turns each successful write into one delivery per matching
+// webhook, queued in the config store so that it survives restarts, and
+// sends deliveries from a pool of workers. Failed deliveries are retried
+// with exponential backoff until their attempts are used up, after which
+// they stay in the store as dead letters that can be redelivered.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/model"
+	"github.com/faucetdb/faucet/internal/query"
+)
+
+// Defaults for Config.
+const (
+	DefaultWorkers      = 2
+	DefaultMaxAttempts  = 8
+	DefaultBackoff      = 10 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultTimeout      = 10 * time.Second
+	DefaultPollInterval = time.Second
+	DefaultRetention    = 7 * 24 * time.Hour
+)
+
+// pruneInterval is how often finished deliveries past their retention are
+// removed.
+const pruneInterval = time.Hour
+
+// Headers sent with every delivery. SignatureHeader holds
+// "sha256=" followed by the hex HMAC-SHA256 of the body, keyed with the
+// webhook's secret.
+const (
+	EventHeader     = "X-Faucet-Event"
+	DeliveryHeader  = "X-Faucet-Delivery"
+	SignatureHeader = "X-Faucet-Signature"
+)
+
+// Config tunes a Dispatcher. Zero values select the defaults.
+type Config struct {
+	Workers      int           // deliveries sent at the same time
+	MaxAttempts  int           // attempts before a delivery is dead
+	Backoff      time.Duration // wait after the first failed attempt, doubled after each further one
+	MaxBackoff   time.Duration // longest wait between attempts
+	Timeout      time.Duration // time allowed for each attempt
+	PollInterval time.Duration // how often idle workers look for due deliveries
+	Retention    time.Duration // how long delivered and dead deliveries are kept
+	Client       *http.Client  // default: a client with Timeout
+	Logger       *slog.Logger
+}
+
+// Payload is the JSON body sent to a webhook.
+type Payload struct {
+	Event     string                   `json:"event"` // insert, update or delete
+	Service   string                   `json:"service"`
+	Table     string                   `json:"table"`
+	Records   []map[string]interface{} `json:"records"`
+	Count     int                      `json:"count"`
+	WebhookID int64                    `json:"webhook_id"`
+	Timestamp time.Time                `json:"timestamp"`
+}
+
+// Dispatcher queues and sends webhook deliveries. NewDispatcher starts its
+// workers; Close stops them.
+type Dispatcher struct {
+	registry *connector.Registry
+	store    *config.Store
+	cfg      Config
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+	wake chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher and starts its workers. Deliveries
+// left sending by a previous process are queued again.
+func NewDispatcher(registry *connector.Registry, store *config.Store, cfg Config) (*Dispatcher, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		registry: registry,
+		store:    store,
+		cfg:      cfg,
+		ctx:      ctx,
+		stop:     stop,
+		wake:     make(chan struct{}, cfg.Workers),
+	}
+	if err := store.ResetSendingWebhookDeliveries(ctx); err != nil {
+		stop()
+		return nil, err
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	d.wg.Add(1)
+	go d.pruneLoop()
+	return d, nil
+}
+
+// Close stops the workers and waits for them to exit. Deliveries being
+// sent are queued again at the next start.
+func (d *Dispatcher) Close() {
+	d.stop()
+	d.wg.Wait()
+}
+
+// Wake makes an idle worker look for due deliveries now.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Emit queues a delivery of a write for every active webhook that matches
+// its table and verb. Webhooks with a filter are sent only the written
+// records that match it, and nothing when none do. Emit is called after
+// the write has succeeded, so errors are logged rather than returned.
+func (d *Dispatcher) Emit(ctx context.Context, ev model.WebhookEvent) {
+	ctx = context.WithoutCancel(ctx)
+	hooks, err := d.store.ListWebhooksFor(ctx, ev.Service, ev.Table)
+	if err != nil {
+		d.cfg.Logger.Error("list webhooks", "service", ev.Service, "table", ev.Table, "error", err)
+		return
+	}
+
+	queued := false
+	for i := range hooks {
+		hook := &hooks[i]
+		if hook.VerbMask&ev.Verb == 0 {
+			continue
+		}
+		payload := Payload{
+			Event:     ev.Op,
+			Service:   ev.Service,
+			Table:     ev.Table,
+			Records:   ev.Records,
+			Count:     ev.Count,
+			WebhookID: hook.ID,
+			Timestamp: time.Now().UTC(),
+		}
+		if hook.Filter != "" {
+			match, err := d.compileFilter(ctx, hook, ev.Table)
+			if err != nil {
+				d.cfg.Logger.Warn("skip webhook with invalid filter", "webhook", hook.ID, "table", ev.Table, "error", err)
+				continue
+			}
+			payload.Records = nil
+			for _, rec := range ev.Records {
+				if match(rec) {
+					payload.Records = append(payload.Records, rec)
+				}
+			}
+			if len(payload.Records) == 0 {
+				continue
+			}
+			payload.Count = len(payload.Records)
+		}
+		if payload.Records == nil {
+			payload.Records = []map[string]interface{}{}
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			d.cfg.Logger.Error("marshal webhook payload", "webhook", hook.ID, "error", err)
+			continue
+		}
+		delivery := &model.WebhookDelivery{WebhookID: hook.ID, Event: ev.Op, Payload: body}
+		if err := d.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+			d.cfg.Logger.Error("queue webhook delivery", "webhook", hook.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		d.Wake()
+	}
+}
+
+// compileFilter compiles a webhook's filter against the columns of table.
+func (d *Dispatcher) compileFilter(ctx context.Context, hook *model.Webhook, table string) (query.RowFilter, error) {
+	ts, err := d.registry.TableSchema(ctx, hook.ServiceName, table)
+	if err != nil {
+		return nil, err
+	}
+	cols := make(query.ColumnSet, len(ts.Columns))
+	for _, c := range ts.Columns {
+		cols[c.Name] = c.JsonType
+	}
+	return query.CompileRowFilter(hook.Filter, cols)
+}
+
+// work claims and sends due deliveries until the dispatcher is closed.
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		delivery, err := d.store.ClaimWebhookDelivery(d.ctx, time.Now())
+		if err != nil && d.ctx.Err() == nil {
+			d.cfg.Logger.Error("claim webhook delivery", "error", err)
+		}
+		if delivery != nil {
+			d.deliver(delivery)
+			continue
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.wake:
+		case <-time.After(d.cfg.PollInterval):
+		}
+	}
+}
+
+// deliver makes one attempt to send a claimed delivery and stores its
+// outcome, scheduling the next attempt when it failed.
+func (d *Dispatcher) deliver(delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseStatus, delivery.Error = 0, ""
+
+	// A delivery to a deleted or disabled webhook is not retried.
+	retry := false
+	hook, err := d.store.GetWebhook(d.ctx, delivery.WebhookID)
+	switch {
+	case err == config.ErrNotFound:
+		delivery.Error = "Webhook was deleted"
+	case err != nil:
+		delivery.Error, retry = err.Error(), true
+	case !hook.IsActive:
+		delivery.Error = "Webhook is disabled"
+	default:
+		retry = true
+		delivery.ResponseStatus, err = d.send(hook, delivery)
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+	}
+	if d.ctx.Err() != nil {
+		// Interrupted by Close: leave the delivery to be reset at the next
+		// start rather than counting the attempt.
+		return
+	}
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = model.DeliveryDelivered
+	case !retry || delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = model.DeliveryDead
+	default:
+		delivery.Status = model.DeliveryPending
+		delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+	}
+	if err := d.store.FinishWebhookDelivery(d.ctx, delivery); err != nil && d.ctx.Err() == nil {
+		d.cfg.Logger.Error("store webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+	if delivery.Status == model.DeliveryDead {
+		d.cfg.Logger.Warn("webhook delivery failed permanently",
+			"webhook", delivery.WebhookID, "delivery", delivery.ID, "attempts", delivery.Attempts, "error", delivery.Error)
+	}
+}
+
+// send posts a delivery's payload to its webhook and returns the response
+// status. Any status other than 2xx is an error.
+func (d *Dispatcher) send(hook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.PayloadJSON))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Faucet-Webhook")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, []byte(delivery.PayloadJSON)))
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after a delivery's nth failed attempt.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.MaxBackoff {
+		wait = d.cfg.MaxBackoff
+	}
+	return wait
+}
+
+// pruneLoop removes finished deliveries past their retention until the
+// dispatcher is closed.
+func (d *Dispatcher) pruneLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.store.PruneWebhookDeliveries(d.ctx, time.Now().Add(-d.cfg.Retention)); err != nil && d.ctx.Err() == nil {
+			d.cfg.Logger.Error("prune webhook deliveries", "error", err)
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sign returns the signature header value of a payload: "sha256=" followed
+// by the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret for a webhook registered without one.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
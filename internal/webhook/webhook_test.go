@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/faucetdb/faucet/internal/config"
+	"github.com/faucetdb/faucet/internal/connector"
+	"github.com/faucetdb/faucet/internal/connector/sqlite"
+	"github.com/faucetdb/faucet/internal/model"
+)
+
+// receiver is an in-process webhook endpoint that checks signatures.
+type receiver struct {
+	t      *testing.T
+	secret string
+	fail   atomic.Bool // respond 500 while set
+
+	mu       sync.Mutex
+	payloads []Payload
+	attempts int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.attempts++
+	if got := r.Header.Get(SignatureHeader); got != Sign(rc.secret, body) {
+		rc.t.Errorf("bad signature %q", got)
+	}
+	if r.Header.Get(DeliveryHeader) == "" || r.Header.Get(EventHeader) == "" {
+		rc.t.Errorf("missing delivery headers: %v", r.Header)
+	}
+	if rc.fail.Load() {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		rc.t.Errorf("decode payload: %v", err)
+	}
+	rc.payloads = append(rc.payloads, p)
+}
+
+func (rc *receiver) received() []Payload {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Payload(nil), rc.payloads...)
+}
+
+func newTestDispatcher(t *testing.T, cfg Config) (*Dispatcher, *config.Store) {
+	t.Helper()
+	store, err := config.NewStore("")
+	if err != nil {
+		t.Fatalf("config.NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	registry := connector.NewRegistry()
+	registry.RegisterDriver("sqlite", func() connector.Connector { return sqlite.New() })
+	if err := registry.Connect("testdb", connector.ConnectionConfig{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("registry.Connect: %v", err)
+	}
+	t.Cleanup(func() { registry.Disconnect("testdb") })
+	conn, _ := registry.Get("testdb")
+	if _, err := conn.DB().Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	d, err := NewDispatcher(registry, store, cfg)
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	t.Cleanup(d.Close)
+	return d, store
+}
+
+// waitDelivery polls a delivery until it has the given status.
+func waitDelivery(t *testing.T, store *config.Store, webhookID int64, status string) model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := store.ListWebhookDeliveries(context.Background(), webhookID, status, 10)
+		if err != nil {
+			t.Fatalf("list deliveries: %v", err)
+		}
+		if len(deliveries) > 0 {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s delivery for webhook %d", status, webhookID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	d, store := newTestDispatcher(t, Config{})
+	ctx := context.Background()
+	rc := &receiver{t: t, secret: "s3cret"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	paid := &model.Webhook{ServiceName: "testdb", Table: "orders", VerbMask: model.VerbPost,
+		URL: srv.URL, Secret: rc.secret, Filter: "status = 'paid'", IsActive: true}
+	if err := store.CreateWebhook(ctx, paid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the records matching the filter are sent.
+	d.Emit(ctx, model.WebhookEvent{Service: "testdb", Table: "orders", Verb: model.VerbPost, Op: "insert",
+		Records: []map[string]interface{}{{"id": 1, "status": "new"}, {"id": 2, "status": "paid"}}, Count: 2})
+	// Deletes are not subscribed to, and writes matching no record send nothing.
+	d.Emit(ctx, model.WebhookEvent{Service: "testdb", Table: "orders", Verb: model.VerbDelete, Op: "delete", Count: 5})
+	d.Emit(ctx, model.WebhookEvent{Service: "testdb", Table: "orders", Verb: model.VerbPost, Op: "insert",
+		Records: []map[string]interface{}{{"id": 3, "status": "new"}}, Count: 1})
+
+	delivered := waitDelivery(t, store, paid.ID, model.DeliveryDelivered)
+	if delivered.Attempts != 1 || delivered.ResponseStatus != http.StatusOK || delivered.Event != "insert" {
+		t.Errorf("unexpected delivery: %+v", delivered)
+	}
+	got := rc.received()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(got))
+	}
+	p := got[0]
+	if p.Event != "insert" || p.Service != "testdb" || p.Table != "orders" || p.WebhookID != paid.ID ||
+		p.Count != 1 || len(p.Records) != 1 || p.Records[0]["id"] != float64(2) {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+	if all, _ := store.ListWebhookDeliveries(ctx, paid.ID, "", 10); len(all) != 1 {
+		t.Errorf("expected 1 delivery, got %d", len(all))
+	}
+}
+
+func TestDispatcherDeadLetterAndRedeliver(t *testing.T) {
+	d, store := newTestDispatcher(t, Config{MaxAttempts: 3, Backoff: time.Millisecond})
+	ctx := context.Background()
+	rc := &receiver{t: t, secret: "s3cret"}
+	rc.fail.Store(true)
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	hook := &model.Webhook{ServiceName: "testdb", Table: "*", VerbMask: model.VerbDelete,
+		URL: srv.URL, Secret: rc.secret, IsActive: true}
+	if err := store.CreateWebhook(ctx, hook); err != nil {
+		t.Fatal(err)
+	}
+	d.Emit(ctx, model.WebhookEvent{Service: "testdb", Table: "orders", Verb: model.VerbDelete, Op: "delete", Count: 2})
+
+	dead := waitDelivery(t, store, hook.ID, model.DeliveryDead)
+	if dead.Attempts != 3 || dead.ResponseStatus != http.StatusInternalServerError || dead.Error == "" {
+		t.Errorf("unexpected dead delivery: %+v", dead)
+	}
+	rc.mu.Lock()
+	attempts := rc.attempts
+	rc.mu.Unlock()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	rc.fail.Store(false)
+	if ok, err := store.RequeueWebhookDelivery(ctx, dead.ID); err != nil || !ok {
+		t.Fatalf("requeue: %v %v", ok, err)
+	}
+	d.Wake()
+	delivered := waitDelivery(t, store, hook.ID, model.DeliveryDelivered)
+	if delivered.ID != dead.ID || delivered.Attempts != 1 {
+		t.Errorf("unexpected redelivery: %+v", delivered)
+	}
+	if got := rc.received(); len(got) != 1 || got[0].Count != 2 || got[0].Records == nil {
+		t.Errorf("unexpected payloads: %+v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{cfg: Config{Backoff: time.Second, MaxBackoff: 10 * time.Second}}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 30: 10 * time.Second} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256("key", "The quick brown fox jumps over the lazy dog")
+	const want = "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got := Sign("key", []byte("The quick brown fox jumps over the lazy dog")); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}